@@ -0,0 +1,11 @@
+package cli
+
+import (
+	"github.com/scttfrdmn/lens/pkg/cli"
+	"github.com/spf13/cobra"
+)
+
+// NewRenameCmd creates the rename command for changing an instance's name
+func NewRenameCmd() *cobra.Command {
+	return cli.NewRenameCmd("lens-dcv-desktop")
+}
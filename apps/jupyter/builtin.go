@@ -0,0 +1,11 @@
+// Package jupyter holds the built-in environments and export configs of lens-jupyter.
+package jupyter
+
+import "embed"
+
+// Builtins contains the built-in Jupyter environments and export configs,
+// embedded so they are available regardless of install method or working
+// directory
+//
+//go:embed environments/*.yaml configs/*.yaml
+var Builtins embed.FS
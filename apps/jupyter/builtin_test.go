@@ -0,0 +1,43 @@
+package jupyter
+
+import (
+	"testing"
+
+	"github.com/scttfrdmn/lens/pkg/config"
+)
+
+func TestBuiltins_Load(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	defer config.UseBuiltins(Builtins)()
+
+	envs, err := config.ListEnvironments()
+	if err != nil {
+		t.Fatalf("ListEnvironments failed: %v", err)
+	}
+	if len(envs) == 0 {
+		t.Fatal("Expected built-in environments")
+	}
+	for _, name := range envs {
+		env, err := config.LoadEnvironment(name)
+		if err != nil {
+			t.Errorf("Failed to load built-in environment %s: %v", name, err)
+			continue
+		}
+		if env.InstanceType == "" || env.AMIBase == "" {
+			t.Errorf("Built-in environment %s has no instance type or AMI base", name)
+		}
+	}
+
+	// The default environment must be one of the built-ins
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if _, err := config.LoadEnvironment(cfg.GetAppConfig("jupyter").DefaultEnvironment); err != nil {
+		t.Errorf("Default environment is not built in: %v", err)
+	}
+
+	if _, err := config.LoadExportConfig(config.GetDefaultConfigForApp("jupyter")); err != nil {
+		t.Errorf("Failed to load built-in export config: %v", err)
+	}
+}
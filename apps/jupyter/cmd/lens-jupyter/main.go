@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/scttfrdmn/lens/apps/jupyter"
 	"github.com/scttfrdmn/lens/apps/jupyter/internal/cli"
 	"github.com/scttfrdmn/lens/pkg"
+	lenscli "github.com/scttfrdmn/lens/pkg/cli"
 	"github.com/scttfrdmn/lens/pkg/config"
 	"github.com/spf13/cobra"
 )
@@ -17,6 +19,8 @@ var (
 )
 
 func main() {
+	config.UseBuiltins(jupyter.Builtins)
+
 	// Migrate from legacy config directories if needed
 	if err := config.MigrateFromLegacy(); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: Failed to migrate legacy config: %v\n", err)
@@ -28,6 +32,10 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := lenscli.ConfigurePricing(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v; using offline prices\n", err)
+	}
+
 	rootCmd := &cobra.Command{
 		Use:   "lens-jupyter",
 		Short: "Secure Jupyter Lab on AWS Graviton with Session Manager & advanced networking",
@@ -74,6 +82,19 @@ Quick Start:
 	rootCmd.AddCommand(cli.NewKeyCmd())
 	rootCmd.AddCommand(cli.NewConfigCmd())
 	rootCmd.AddCommand(cli.NewCostsCmd())
+	rootCmd.AddCommand(cli.NewBudgetCmd())
+	rootCmd.AddCommand(cli.NewGCCmd())
+	rootCmd.AddCommand(cli.NewSyncCmd())
+	rootCmd.AddCommand(cli.NewRenameCmd())
+	rootCmd.AddCommand(cli.NewRecommendCmd())
+	rootCmd.AddCommand(cli.NewResizeCmd())
+	rootCmd.AddCommand(cli.NewVolumesCmd())
+	rootCmd.AddCommand(cli.NewBackupCmd())
+	rootCmd.AddCommand(cli.NewKeepaliveCmd())
+	rootCmd.AddCommand(cli.NewIdleCheckCmd())
+	rootCmd.AddCommand(cli.NewScheduleCmd())
+	rootCmd.AddCommand(cli.NewReapCmd())
+	rootCmd.AddCommand(cli.NewExtendCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -0,0 +1,11 @@
+package cli
+
+import (
+	"github.com/scttfrdmn/lens/pkg/cli"
+	"github.com/spf13/cobra"
+)
+
+// NewBudgetCmd creates the budget command for managing and enforcing budgets
+func NewBudgetCmd() *cobra.Command {
+	return cli.NewBudgetCmd("lens-jupyter")
+}
@@ -5,7 +5,9 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/scttfrdmn/lens/pkg/cli"
 	"github.com/scttfrdmn/lens/pkg/config"
 	"github.com/spf13/cobra"
 )
@@ -15,10 +17,15 @@ func NewConfigCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "config",
 		Short: "Manage user configuration",
-		Long: `Manage aws-ide user configuration settings.
+		Long: `Manage lens user configuration settings.
 
-The config file is stored at ~/.aws-ide/config.yaml and contains
-default settings for all aws-ide tools (jupyter, rstudio, vscode).
+The config file is stored at ~/.lens/config.yaml and contains
+default settings for all lens tools (jupyter, rstudio, vscode).
+
+Effective settings are resolved from built-in defaults, the system config,
+the user config, a .lens.yaml in the current directory or a parent, LENS_*
+environment variables and finally command-line flags. Use "config explain"
+to see where each value came from.
 
 Examples:
   # Initialize config with defaults
@@ -33,13 +40,17 @@ Examples:
   lens-vscode config set vscode.port 8080
 
   # Get a specific configuration value
-  lens-vscode config get default_region`,
+  lens-vscode config get default_region
+
+  # Show effective values and their sources
+  lens-vscode config explain`,
 	}
 
 	cmd.AddCommand(NewConfigInitCmd())
 	cmd.AddCommand(NewConfigShowCmd())
 	cmd.AddCommand(NewConfigSetCmd())
 	cmd.AddCommand(NewConfigGetCmd())
+	cmd.AddCommand(cli.NewConfigExplainCmd())
 
 	return cmd
 }
@@ -86,16 +97,25 @@ func NewConfigSetCmd() *cobra.Command {
 Supported keys:
   default_region              - AWS region
   default_profile             - AWS profile
+  state_backend               - State storage (local or s3://bucket/prefix)
+  state_region                - Region of the state bucket
+  state_endpoint              - Endpoint of an S3-compatible state store
   default_instance_type       - EC2 instance type
   default_ebs_size            - EBS volume size (GB)
   default_ami_base            - Base AMI name
   default_subnet_type         - Subnet type (public/private)
   prefer_ipv6                 - Prefer IPv6 (true/false)
   idle_timeout                - Idle timeout duration
-  auto_terminate              - Auto-terminate on idle (true/false)
+  auto_terminate              - Let reap terminate expired instances, not stop them (true/false)
+  default_ttl                 - Maximum lifetime of launched instances (e.g. 30d)
+  ttl_warning                 - How long before a TTL runs out to warn (e.g. 48h)
+  ttl_policy                  - What reap does with expired instances (terminate/snapshot)
   confirm_destructive         - Confirm destructive operations (true/false)
   enable_cost_tracking        - Enable cost tracking (true/false)
   cost_alert_threshold        - Cost alert threshold ($)
+  pricing_source              - Instance prices (live/offline)
+  pricing_cache_ttl           - How long live prices are cached (e.g. 168h)
+  default_project             - Project tagged on launched instances, used by budgets
   vscode.default_environment  - Default VSCode environment
   vscode.default_instance_type - Default instance type for VSCode
   vscode.default_ebs_size     - Default EBS size for VSCode
@@ -151,6 +171,15 @@ func runConfigShow() error {
 	fmt.Printf("  default_region:         %s\n", valueOrDefault(cfg.DefaultRegion, "(from AWS SDK)"))
 	fmt.Printf("  default_profile:        %s\n", valueOrDefault(cfg.DefaultProfile, "(from AWS SDK)"))
 	fmt.Println()
+	fmt.Println("State:")
+	fmt.Printf("  state_backend:          %s\n", valueOrDefault(cfg.StateBackend, "local"))
+	if cfg.StateRegion != "" {
+		fmt.Printf("  state_region:           %s\n", cfg.StateRegion)
+	}
+	if cfg.StateEndpoint != "" {
+		fmt.Printf("  state_endpoint:         %s\n", cfg.StateEndpoint)
+	}
+	fmt.Println()
 	fmt.Println("Instance Defaults:")
 	fmt.Printf("  default_instance_type:  %s\n", cfg.DefaultInstanceType)
 	fmt.Printf("  default_ebs_size:       %d GB\n", cfg.DefaultEBSSize)
@@ -163,11 +192,18 @@ func runConfigShow() error {
 	fmt.Println("Behavior:")
 	fmt.Printf("  idle_timeout:           %s\n", cfg.IdleTimeout)
 	fmt.Printf("  auto_terminate:         %t\n", cfg.AutoTerminate)
+	fmt.Printf("  default_ttl:            %s\n", cfg.DefaultTTL)
+	fmt.Printf("  ttl_warning:            %s\n", cfg.TTLWarning)
+	fmt.Printf("  ttl_policy:             %s\n", cfg.TTLPolicy)
 	fmt.Printf("  confirm_destructive:    %t\n", cfg.ConfirmDestructive)
 	fmt.Println()
 	fmt.Println("Cost Tracking:")
 	fmt.Printf("  enable_cost_tracking:   %t\n", cfg.EnableCostTracking)
 	fmt.Printf("  cost_alert_threshold:   $%.2f/month\n", cfg.CostAlertThreshold)
+	fmt.Printf("  pricing_source:         %s\n", cfg.PricingSource)
+	fmt.Printf("  pricing_cache_ttl:      %s\n", cfg.PricingCacheTTL)
+	fmt.Printf("  default_project:        %s\n", cfg.DefaultProject)
+	fmt.Printf("  budgets:                %d (see: budget list)\n", len(cfg.Budgets))
 	fmt.Println()
 	fmt.Println("VSCode Settings:")
 	if cfg.VSCode != nil {
@@ -269,6 +305,15 @@ func setGlobalConfigValue(cfg *config.UserConfig, key, value string) error {
 		cfg.DefaultRegion = value
 	case "default_profile":
 		cfg.DefaultProfile = value
+	case "state_backend":
+		if value != "local" && !strings.HasPrefix(value, "s3://") {
+			return fmt.Errorf("invalid state backend: %s (must be 'local' or s3://bucket/prefix)", value)
+		}
+		cfg.StateBackend = value
+	case "state_region":
+		cfg.StateRegion = value
+	case "state_endpoint":
+		cfg.StateEndpoint = value
 	case "default_instance_type":
 		cfg.DefaultInstanceType = value
 	case "default_ebs_size":
@@ -279,6 +324,8 @@ func setGlobalConfigValue(cfg *config.UserConfig, key, value string) error {
 		cfg.DefaultEBSSize = size
 	case "default_ami_base":
 		cfg.DefaultAMIBase = value
+	case "default_project":
+		cfg.DefaultProject = value
 	case "default_subnet_type":
 		if value != "public" && value != "private" {
 			return fmt.Errorf("invalid subnet type: %s (must be 'public' or 'private')", value)
@@ -298,6 +345,21 @@ func setGlobalConfigValue(cfg *config.UserConfig, key, value string) error {
 			return fmt.Errorf("invalid boolean: %s", value)
 		}
 		cfg.AutoTerminate = val
+	case "default_ttl":
+		if _, err := cli.ParseTTL(value); err != nil {
+			return err
+		}
+		cfg.DefaultTTL = value
+	case "ttl_warning":
+		if _, err := cli.ParseTTL(value); err != nil {
+			return fmt.Errorf("invalid duration: %s", value)
+		}
+		cfg.TTLWarning = value
+	case "ttl_policy":
+		if value != config.TTLPolicyTerminate && value != config.TTLPolicySnapshot {
+			return fmt.Errorf("invalid TTL policy: %s (must be 'terminate' or 'snapshot')", value)
+		}
+		cfg.TTLPolicy = value
 	case "confirm_destructive":
 		val, err := strconv.ParseBool(value)
 		if err != nil {
@@ -316,6 +378,16 @@ func setGlobalConfigValue(cfg *config.UserConfig, key, value string) error {
 			return fmt.Errorf("invalid number: %s", value)
 		}
 		cfg.CostAlertThreshold = threshold
+	case "pricing_source":
+		if value != config.PricingSourceLive && value != config.PricingSourceOffline {
+			return fmt.Errorf("invalid pricing source: %s (must be 'live' or 'offline')", value)
+		}
+		cfg.PricingSource = value
+	case "pricing_cache_ttl":
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("invalid duration: %s", value)
+		}
+		cfg.PricingCacheTTL = value
 	default:
 		return fmt.Errorf("unknown config key: %s", key)
 	}
@@ -354,12 +426,20 @@ func getGlobalConfigValue(cfg *config.UserConfig, key string) (string, error) {
 		return cfg.DefaultRegion, nil
 	case "default_profile":
 		return cfg.DefaultProfile, nil
+	case "state_backend":
+		return cfg.StateBackend, nil
+	case "state_region":
+		return cfg.StateRegion, nil
+	case "state_endpoint":
+		return cfg.StateEndpoint, nil
 	case "default_instance_type":
 		return cfg.DefaultInstanceType, nil
 	case "default_ebs_size":
 		return strconv.Itoa(cfg.DefaultEBSSize), nil
 	case "default_ami_base":
 		return cfg.DefaultAMIBase, nil
+	case "default_project":
+		return cfg.DefaultProject, nil
 	case "default_subnet_type":
 		return cfg.DefaultSubnetType, nil
 	case "prefer_ipv6":
@@ -368,12 +448,22 @@ func getGlobalConfigValue(cfg *config.UserConfig, key string) (string, error) {
 		return cfg.IdleTimeout, nil
 	case "auto_terminate":
 		return strconv.FormatBool(cfg.AutoTerminate), nil
+	case "default_ttl":
+		return cfg.DefaultTTL, nil
+	case "ttl_warning":
+		return cfg.TTLWarning, nil
+	case "ttl_policy":
+		return cfg.TTLPolicy, nil
 	case "confirm_destructive":
 		return strconv.FormatBool(cfg.ConfirmDestructive), nil
 	case "enable_cost_tracking":
 		return strconv.FormatBool(cfg.EnableCostTracking), nil
 	case "cost_alert_threshold":
 		return fmt.Sprintf("%.2f", cfg.CostAlertThreshold), nil
+	case "pricing_source":
+		return cfg.PricingSource, nil
+	case "pricing_cache_ttl":
+		return cfg.PricingCacheTTL, nil
 	default:
 		return "", fmt.Errorf("unknown config key: %s", key)
 	}
@@ -0,0 +1,16 @@
+package cli
+
+import (
+	"github.com/scttfrdmn/lens/pkg/cli"
+	"github.com/spf13/cobra"
+)
+
+// NewKeepaliveCmd creates the keepalive command for postponing the idle auto-stop of an instance
+func NewKeepaliveCmd() *cobra.Command {
+	return cli.NewKeepaliveCmd("lens-jupyter")
+}
+
+// NewIdleCheckCmd creates the idle-check command for warning about idle auto-stops from cron
+func NewIdleCheckCmd() *cobra.Command {
+	return cli.NewIdleCheckCmd("lens-jupyter")
+}
@@ -0,0 +1,353 @@
+package cli
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/lens/pkg/agent"
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/fakecloud"
+	"github.com/scttfrdmn/lens/pkg/transaction"
+)
+
+const testEnvironmentYAML = `name: "test"
+instance_type: "t4g.medium"
+ami_base: "ubuntu24-arm64"
+ebs_volume_size: 20
+packages:
+  - git
+pip_packages:
+  - jupyterlab
+`
+
+// setupFakeCloud isolates HOME, installs a test environment and routes all
+// AWS calls to an in-memory cloud
+func setupFakeCloud(t *testing.T) *fakecloud.Cloud {
+	t.Helper()
+
+	t.Setenv("HOME", t.TempDir())
+
+	envDir := filepath.Join(config.GetConfigDir(), "environments")
+	if err := os.MkdirAll(envDir, 0755); err != nil {
+		t.Fatalf("Failed to create environments dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(envDir, "test.yaml"), []byte(testEnvironmentYAML), 0644); err != nil {
+		t.Fatalf("Failed to write environment: %v", err)
+	}
+
+	previous := readinessPollInterval
+	readinessPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { readinessPollInterval = previous })
+
+	// Launches install a pinned lens-agent release, as make builds do
+	version := agent.Version
+	agent.Version = "1.0.0"
+	t.Cleanup(func() { agent.Version = version })
+
+	return fakecloud.Install(t)
+}
+
+// launchedInstance returns the single instance recorded in local state
+func launchedInstance(t *testing.T) *config.Instance {
+	t.Helper()
+
+	state, err := config.LoadState()
+	if err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	if len(state.Instances) != 1 {
+		t.Fatalf("Expected 1 instance in state, got %d", len(state.Instances))
+	}
+	for _, instance := range state.Instances {
+		return instance
+	}
+	return nil
+}
+
+func assertCloudState(t *testing.T, cloud *fakecloud.Cloud, instanceID string, want types.InstanceStateName) {
+	t.Helper()
+
+	inst, ok := cloud.Instance(instanceID)
+	if !ok {
+		t.Fatalf("Instance %s not found in fake cloud", instanceID)
+	}
+	if inst.State.Name != want {
+		t.Errorf("Expected instance %s to be %s, got %s", instanceID, want, inst.State.Name)
+	}
+}
+
+func TestLifecycle_LaunchStopStartTerminate(t *testing.T) {
+	cloud := setupFakeCloud(t)
+
+	err := runLaunch("test", "", "", "", "4h", "default", "", "", false, "session-manager", "public", false, "", "", false, "", false, "", false, false, "")
+	if err != nil {
+		t.Fatalf("runLaunch failed: %v", err)
+	}
+
+	instance := launchedInstance(t)
+	assertCloudState(t, cloud, instance.ID, types.InstanceStateNameRunning)
+
+	if instance.Environment != "test" || instance.InstanceType != "t4g.medium" {
+		t.Errorf("Unexpected instance in state: %+v", instance)
+	}
+	if instance.Region != fakecloud.DefaultRegion {
+		t.Errorf("Expected region %s, got %s", fakecloud.DefaultRegion, instance.Region)
+	}
+
+	inst, _ := cloud.Instance(instance.ID)
+	if inst.IamInstanceProfile == nil {
+		t.Error("Expected instance to be launched with an instance profile")
+	}
+	if instance.App != appName || instance.IdleTimeout != "4h0m0s" || instance.Owner != fakecloud.CallerARN {
+		t.Errorf("Expected launch metadata in state, got app=%q idle=%q owner=%q", instance.App, instance.IdleTimeout, instance.Owner)
+	}
+	if metadata := aws.ParseInstanceMetadata(inst.Tags); metadata.Environment != "test" || metadata.App != appName {
+		t.Errorf("Expected lens metadata tags on instance, got %+v", metadata)
+	}
+	if cloud.UserData(instance.ID) == "" {
+		t.Error("Expected instance to be launched with user data")
+	}
+
+	if err := runStop(instance.ID, false); err != nil {
+		t.Fatalf("runStop failed: %v", err)
+	}
+	assertCloudState(t, cloud, instance.ID, types.InstanceStateNameStopping)
+
+	if err := runStart(instance.ID); err != nil {
+		t.Fatalf("runStart failed: %v", err)
+	}
+	assertCloudState(t, cloud, instance.ID, types.InstanceStateNameRunning)
+
+	if err := runTerminate(instance.ID, true); err != nil {
+		t.Fatalf("runTerminate failed: %v", err)
+	}
+	assertCloudState(t, cloud, instance.ID, types.InstanceStateNameShuttingDown)
+
+	state, err := config.LoadState()
+	if err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	if len(state.Instances) != 0 {
+		t.Errorf("Expected terminated instance to be removed from state, got %d instances", len(state.Instances))
+	}
+}
+
+func TestLaunch_InstallsLatestAgentWithoutVersion(t *testing.T) {
+	cloud := setupFakeCloud(t)
+	agent.Version = ""
+
+	err := runLaunch("test", "", "", "", "4h", "default", "", "", false, "ssh", "public", false, "", "", false, "", false, "", false, false, "")
+	if err != nil {
+		t.Fatalf("Expected launch to go ahead without a lens-agent version, got: %v", err)
+	}
+	instance := launchedInstance(t)
+	userData, err := base64.StdEncoding.DecodeString(cloud.UserData(instance.ID))
+	if err != nil {
+		t.Fatalf("Failed to decode user data: %v", err)
+	}
+	if !strings.Contains(string(userData), agent.ReleaseURL+"/latest") || !strings.Contains(string(userData), "sha256sum -c") {
+		t.Errorf("Expected user data to install the latest lens-agent release, checksummed:\n%s", userData)
+	}
+}
+
+func TestLaunch_SSHCreatesAndSavesKeyPair(t *testing.T) {
+	cloud := setupFakeCloud(t)
+
+	err := runLaunch("test", "", "", "", "4h", "default", "", "", false, "ssh", "public", false, "", "", false, "", false, "", false, false, "")
+	if err != nil {
+		t.Fatalf("runLaunch failed: %v", err)
+	}
+
+	instance := launchedInstance(t)
+	if instance.KeyPair == "" {
+		t.Fatal("Expected key pair to be recorded in state")
+	}
+	if instance.PublicIP == "" {
+		t.Error("Expected public IP to be recorded for a public subnet launch")
+	}
+
+	keys := cloud.KeyPairs(fakecloud.DefaultRegion)
+	if len(keys) != 1 || *keys[0].KeyName != instance.KeyPair {
+		t.Errorf("Expected key pair %s in the cloud, got %+v", instance.KeyPair, keys)
+	}
+
+	keyStorage, err := config.DefaultKeyStorage()
+	if err != nil {
+		t.Fatalf("Failed to open key storage: %v", err)
+	}
+	if !keyStorage.HasPrivateKey(instance.KeyPair) {
+		t.Error("Expected private key to be saved locally")
+	}
+}
+
+func TestLaunch_RunInstancesFailureRollsBack(t *testing.T) {
+	cloud := setupFakeCloud(t)
+	cloud.FailNext("RunInstances", fakecloud.APIError("InsufficientInstanceCapacity", "We currently do not have sufficient capacity"))
+
+	err := runLaunch("test", "", "", "", "4h", "default", "", "", false, "ssh", "public", false, "", "", false, "", false, "", false, false, "")
+	if err == nil {
+		t.Fatal("Expected launch to fail")
+	}
+	if !strings.Contains(err.Error(), "InsufficientInstanceCapacity") {
+		t.Errorf("Expected capacity error, got: %v", err)
+	}
+
+	state, err := config.LoadState()
+	if err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	if len(state.Instances) != 0 {
+		t.Errorf("Expected no instances in state after failed launch, got %d", len(state.Instances))
+	}
+
+	// Everything the launch created is rolled back
+	if keys := cloud.KeyPairs(fakecloud.DefaultRegion); len(keys) != 0 {
+		t.Errorf("Expected key pair to be rolled back, got %d key pairs", len(keys))
+	}
+	if groups := cloud.SecurityGroups(fakecloud.DefaultRegion); len(groups) != 1 {
+		t.Errorf("Expected only the default security group to remain, got %d", len(groups))
+	}
+	if _, ok := cloud.Role(appName + "-session-manager-role"); ok {
+		t.Error("Expected IAM role to be rolled back")
+	}
+	if _, ok := cloud.InstanceProfile(appName + "-session-manager-profile"); ok {
+		t.Error("Expected instance profile to be rolled back")
+	}
+	assertNoLaunchJournal(t)
+}
+
+func TestLaunch_KeepOnFailure(t *testing.T) {
+	cloud := setupFakeCloud(t)
+	// Fail the wait for the instance to start, after it has been created
+	cloud.FailNext("DescribeInstances", fakecloud.APIError("InternalError", "An internal error has occurred"))
+
+	err := runLaunch("test", "", "", "", "4h", "default", "", "", false, "ssh", "public", false, "", "", true, "", false, "", false, false, "")
+	if err == nil {
+		t.Fatal("Expected launch to fail")
+	}
+
+	instance := launchedInstance(t)
+	assertCloudState(t, cloud, instance.ID, types.InstanceStateNameRunning)
+	if len(cloud.KeyPairs(fakecloud.DefaultRegion)) != 1 {
+		t.Error("Expected key pair to be kept")
+	}
+	if _, ok := cloud.Role(appName + "-session-manager-role"); !ok {
+		t.Error("Expected IAM role to be kept")
+	}
+	assertNoLaunchJournal(t)
+}
+
+func assertNoLaunchJournal(t *testing.T) {
+	t.Helper()
+
+	entries, err := os.ReadDir(transaction.GetJournalDir())
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("Failed to read journal directory: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected launch journal to be removed, found %d entries", len(entries))
+	}
+}
+
+func TestLaunch_NameCanBeUsedInsteadOfID(t *testing.T) {
+	cloud := setupFakeCloud(t)
+
+	err := runLaunch("test", "thesis-analysis", "", "", "4h", "default", "", "", false, "session-manager", "public", false, "", "", false, "", false, "", false, false, "")
+	if err != nil {
+		t.Fatalf("runLaunch failed: %v", err)
+	}
+
+	instance := launchedInstance(t)
+	if instance.Name != "thesis-analysis" {
+		t.Errorf("Expected name in state, got %q", instance.Name)
+	}
+	inst, _ := cloud.Instance(instance.ID)
+	if metadata := aws.ParseInstanceMetadata(inst.Tags); metadata.Name != "thesis-analysis" {
+		t.Errorf("Expected Name tag thesis-analysis, got %q", metadata.Name)
+	}
+
+	if err := runStop("thesis", false); err != nil {
+		t.Fatalf("runStop by name prefix failed: %v", err)
+	}
+	assertCloudState(t, cloud, instance.ID, types.InstanceStateNameStopping)
+
+	// Names are unique
+	err = runLaunch("test", "thesis-analysis", "", "", "4h", "default", "", "", false, "session-manager", "public", false, "", "", false, "", false, "", false, false, "")
+	if err == nil || !strings.Contains(err.Error(), "already used") {
+		t.Errorf("Expected duplicate name to be rejected, got %v", err)
+	}
+}
+
+func TestLaunch_DataVolumeSurvivesTerminate(t *testing.T) {
+	cloud := setupFakeCloud(t)
+
+	err := runLaunch("test", "", "", "", "4h", "default", "", "", false, "session-manager", "public", false, "", "", false, "", false, "thesis-data:50", false, false, "")
+	if err != nil {
+		t.Fatalf("runLaunch failed: %v", err)
+	}
+
+	first := launchedInstance(t)
+	if first.DataVolume != "thesis-data" {
+		t.Errorf("Expected data volume in state, got %q", first.DataVolume)
+	}
+	volumes := cloud.Volumes(fakecloud.DefaultRegion)
+	var volume types.Volume
+	for _, v := range volumes {
+		if metadata := aws.ParseInstanceMetadata(v.Tags); metadata.DataVolume == "thesis-data" {
+			volume = v
+		}
+	}
+	if volume.VolumeId == nil || *volume.Size != 50 {
+		t.Fatalf("Expected a 50 GB data volume, got %+v", volumes)
+	}
+	if len(volume.Attachments) != 1 || *volume.Attachments[0].InstanceId != first.ID {
+		t.Errorf("Expected data volume to be attached to %s, got %+v", first.ID, volume.Attachments)
+	}
+	// Its cost outlives the instance, so it is not allocated to it
+	for _, tag := range volume.Tags {
+		if *tag.Key == aws.TagInstance {
+			t.Errorf("Expected the data volume not to carry %s, got %s", aws.TagInstance, *tag.Value)
+		}
+	}
+	userData, err := base64.StdEncoding.DecodeString(cloud.UserData(first.ID))
+	if err != nil {
+		t.Fatalf("Failed to decode user data: %v", err)
+	}
+	if !strings.Contains(string(userData), *volume.VolumeId) || !strings.Contains(string(userData), aws.DataVolumeMountPath) {
+		t.Error("Expected user data to mount the data volume")
+	}
+
+	if err := runTerminate(first.ID, true); err != nil {
+		t.Fatalf("runTerminate failed: %v", err)
+	}
+	if err := cloud.SetInstanceState(first.ID, types.InstanceStateNameTerminated); err != nil {
+		t.Fatalf("SetInstanceState failed: %v", err)
+	}
+
+	// The volume is attached again, without its size
+	err = runLaunch("test", "", "", "", "4h", "default", "", "", false, "session-manager", "public", false, "", "", false, "", false, "thesis-data", false, false, "")
+	if err != nil {
+		t.Fatalf("runLaunch with existing data volume failed: %v", err)
+	}
+	second := launchedInstance(t)
+	inst, _ := cloud.Instance(second.ID)
+	if *inst.Placement.AvailabilityZone != *volume.AvailabilityZone {
+		t.Errorf("Expected instance in %s with its data volume, got %s", *volume.AvailabilityZone, *inst.Placement.AvailabilityZone)
+	}
+	volumes = cloud.Volumes(fakecloud.DefaultRegion)
+	reattached := false
+	for _, v := range volumes {
+		if *v.VolumeId == *volume.VolumeId && len(v.Attachments) == 1 && *v.Attachments[0].InstanceId == second.ID {
+			reattached = true
+		}
+	}
+	if !reattached {
+		t.Errorf("Expected data volume %s to be attached to %s, got %+v", *volume.VolumeId, second.ID, volumes)
+	}
+}
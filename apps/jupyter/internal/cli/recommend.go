@@ -0,0 +1,11 @@
+package cli
+
+import (
+	"github.com/scttfrdmn/lens/pkg/cli"
+	"github.com/spf13/cobra"
+)
+
+// NewRecommendCmd creates the recommend command for rightsizing an instance
+func NewRecommendCmd() *cobra.Command {
+	return cli.NewRecommendCmd("lens-jupyter")
+}
@@ -0,0 +1,11 @@
+package cli
+
+import (
+	"github.com/scttfrdmn/lens/pkg/cli"
+	"github.com/spf13/cobra"
+)
+
+// NewResizeCmd creates the resize command for changing the instance type or disk size
+func NewResizeCmd() *cobra.Command {
+	return cli.NewResizeCmd("lens-jupyter")
+}
@@ -7,24 +7,42 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awslib "github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/cli"
 	"github.com/scttfrdmn/lens/pkg/config"
 	"github.com/spf13/cobra"
 )
 
 // NewStatusCmd creates the status command for checking instance status
 func NewStatusCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "status INSTANCE_ID",
+	var (
+		all     bool
+		profile string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "status [INSTANCE]",
 		Short: "Show instance status and logs",
 		Long:  "Display detailed status information about an EC2 instance including state, uptime, and configuration",
-		Args:  cobra.ExactArgs(1),
+		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runStatus(args[0])
+			if all {
+				return cli.RunIdleStatus(context.Background(), profile)
+			}
+			instanceRef := ""
+			if len(args) > 0 {
+				instanceRef = args[0]
+			}
+			return runStatus(instanceRef, profile)
 		},
 	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "Show when every running instance will be auto-stopped")
+	cmd.Flags().StringVarP(&profile, "profile", "p", "default", "AWS profile to use")
+
+	return cmd
 }
 
-func runStatus(instanceID string) error {
+func runStatus(instanceRef, profile string) error {
 	ctx := context.Background()
 
 	// Load state to get instance details
@@ -33,14 +51,15 @@ func runStatus(instanceID string) error {
 		return fmt.Errorf("failed to load state: %w", err)
 	}
 
-	// Get instance from state
-	instance, exists := state.Instances[instanceID]
-	if !exists {
-		return fmt.Errorf("instance %s not found in local state", instanceID)
+	// Resolve the instance by name, ID or prefix
+	instance, err := cli.ResolveInstance(state, instanceRef)
+	if err != nil {
+		return err
 	}
+	instanceID := instance.ID
 
 	// Create AWS client for the instance's region
-	ec2Client, err := awslib.NewEC2ClientForRegion(ctx, instance.Region)
+	ec2Client, err := awslib.NewEC2ClientForProfileRegion(ctx, profile, instance.Region)
 	if err != nil {
 		return fmt.Errorf("failed to create AWS client: %w", err)
 	}
@@ -54,6 +73,9 @@ func runStatus(instanceID string) error {
 	// Display status
 	fmt.Printf("Instance Status: %s\n", instanceID)
 	fmt.Println("=" + string(make([]byte, 60)))
+	if instance.Name != "" {
+		fmt.Printf("Name:            %s\n", instance.Name)
+	}
 	fmt.Printf("Environment:     %s\n", instance.Environment)
 	fmt.Printf("Instance Type:   %s\n", instance.InstanceType)
 	fmt.Printf("Region:          %s\n", instance.Region)
@@ -71,6 +93,11 @@ func runStatus(instanceID string) error {
 	}
 
 	fmt.Printf("Idle Timeout:    %s\n", instance.IdleTimeout)
+	if awsInstance.State.Name == "running" {
+		cli.PrintAutoStop(ctx, profile, instance)
+	}
+	cli.PrintIdleAlarm(ctx, profile, instance)
+	cli.PrintExpiry(instance)
 	fmt.Printf("Key Pair:        %s\n", instance.KeyPair)
 	fmt.Printf("Security Group:  %s\n", instance.SecurityGroup)
 
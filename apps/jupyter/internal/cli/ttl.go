@@ -0,0 +1,16 @@
+package cli
+
+import (
+	"github.com/scttfrdmn/lens/pkg/cli"
+	"github.com/spf13/cobra"
+)
+
+// NewReapCmd creates the reap command for enforcing instance TTLs
+func NewReapCmd() *cobra.Command {
+	return cli.NewReapCmd("lens-jupyter")
+}
+
+// NewExtendCmd creates the extend command for extending the TTL of an instance
+func NewExtendCmd() *cobra.Command {
+	return cli.NewExtendCmd("lens-jupyter")
+}
@@ -80,6 +80,9 @@ Quick Start:
 	rootCmd.AddCommand(cli.NewGenerateCmd())
 	rootCmd.AddCommand(cli.NewConfigCmd())
 	rootCmd.AddCommand(cli.NewCostsCmd())
+	rootCmd.AddCommand(cli.NewGCCmd())
+	rootCmd.AddCommand(cli.NewSyncCmd())
+	rootCmd.AddCommand(cli.NewRenameCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
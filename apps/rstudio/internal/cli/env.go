@@ -3,6 +3,7 @@ package cli
 import (
 	"fmt"
 
+	"github.com/scttfrdmn/lens/pkg/cli"
 	"github.com/scttfrdmn/lens/pkg/config"
 	"github.com/spf13/cobra"
 )
@@ -16,6 +17,7 @@ func NewEnvCmd() *cobra.Command {
 
 	cmd.AddCommand(NewEnvListCmd())
 	cmd.AddCommand(NewEnvValidateCmd())
+	cmd.AddCommand(cli.NewEnvShowCmd())
 	return cmd
 }
 
@@ -43,14 +45,14 @@ func NewEnvValidateCmd() *cobra.Command {
 }
 
 func runEnvList() error {
-	envs, err := config.ListEnvironments()
+	envs, err := config.ListEnvironmentInfo()
 	if err != nil {
 		return fmt.Errorf("failed to list environments: %w", err)
 	}
 
 	fmt.Println("Available environments:")
 	for _, env := range envs {
-		fmt.Printf("  %s\n", env)
+		fmt.Printf("  %-20s %s\n", env.Name, env.SourceDescription())
 	}
 
 	return nil
@@ -146,8 +146,8 @@ func TestNewEnvCmd(t *testing.T) {
 	}
 
 	// Check subcommands
-	if len(cmd.Commands()) != 2 {
-		t.Errorf("Expected 2 subcommands, got: %d", len(cmd.Commands()))
+	if len(cmd.Commands()) != 3 {
+		t.Errorf("Expected 3 subcommands, got: %d", len(cmd.Commands()))
 	}
 }
 
@@ -3,18 +3,23 @@ package cli
 import (
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
-	awssdkconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	rstudioconfig "github.com/scttfrdmn/lens/apps/rstudio/internal/config"
+	"github.com/scttfrdmn/lens/pkg/agent"
 	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/cli"
 	"github.com/scttfrdmn/lens/pkg/config"
 	"github.com/scttfrdmn/lens/pkg/output"
 	"github.com/scttfrdmn/lens/pkg/readiness"
+	"github.com/scttfrdmn/lens/pkg/transaction"
 	"github.com/spf13/cobra"
 )
 
@@ -23,12 +28,20 @@ const (
 	connectionMethodSessionManager = "session-manager"
 	subnetTypePublic               = "public"
 	subnetTypePrivate              = "private"
+
+	// appName prefixes the AWS resources created by this CLI
+	appName = "lens-rstudio"
 )
 
+// readinessPollInterval is how often the service readiness check runs over SSM.
+// Tests running against pkg/fakecloud shorten it.
+var readinessPollInterval = 10 * time.Second
+
 // NewLaunchCmd creates the launch command for starting new RStudio instances
 func NewLaunchCmd() *cobra.Command {
 	var (
 		environment      string
+		name             string
 		instanceType     string
 		customAMI        string
 		idleTimeout      string
@@ -44,16 +57,27 @@ func NewLaunchCmd() *cobra.Command {
 		spotType         string
 		s3Bucket         string
 		s3SyncPath       string
+		keepOnFailure    bool
+		project          string
+		overrideBudget   bool
+		dataVolume       string
+		sharedDataVolume bool
+		idleAlarm        bool
+		ttl              string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "launch",
 		Short: "Launch a new RStudio instance",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runLaunch(environment, instanceType, customAMI, idleTimeout, profile, region, availabilityZone, dryRun, connectionMethod, subnetType, createNatGateway, useSpot, spotMaxPrice, spotType, s3Bucket, s3SyncPath)
+			if err := cli.ApplyConfigDefaults(cmd, cli.LaunchConfigKeys("rstudio")); err != nil {
+				return err
+			}
+			return runLaunch(environment, name, instanceType, customAMI, idleTimeout, profile, region, availabilityZone, dryRun, connectionMethod, subnetType, createNatGateway, useSpot, spotMaxPrice, spotType, s3Bucket, s3SyncPath, keepOnFailure, project, overrideBudget, dataVolume, sharedDataVolume, idleAlarm, ttl)
 		},
 	}
 
+	cmd.Flags().StringVar(&name, "name", "", "Unique name for the instance, usable instead of its ID in other commands")
 	cmd.Flags().StringVarP(&environment, "env", "e", "data-science", "Environment configuration to use")
 	cmd.Flags().StringVarP(&instanceType, "instance-type", "t", "", "Override instance type")
 	cmd.Flags().StringVar(&customAMI, "ami", "", "Use custom AMI instead of base AMI (see: list-amis)")
@@ -70,6 +94,13 @@ func NewLaunchCmd() *cobra.Command {
 	cmd.Flags().StringVar(&spotType, "spot-type", "one-time", "Spot instance type: one-time or persistent")
 	cmd.Flags().StringVar(&s3Bucket, "s3-bucket", "", "S3 bucket for data sync (e.g., my-bucket or my-bucket/prefix)")
 	cmd.Flags().StringVar(&s3SyncPath, "s3-sync-path", "/home/ubuntu/data", "Local path to sync with S3")
+	cmd.Flags().BoolVar(&keepOnFailure, "keep-on-failure", false, "Keep resources created by a failed launch for debugging instead of rolling them back")
+	cmd.Flags().StringVar(&project, "project", "", "Project to charge the instance to, tagged as lens:project and used by budgets")
+	cmd.Flags().BoolVar(&overrideBudget, "override-budget", false, "Launch even if the instance would take a budget over its cap")
+	cmd.Flags().StringVar(&dataVolume, "data-volume", "", "Persistent data volume mounted at "+aws.DataVolumeMountPath+": NAME:SIZE in GB to create it, NAME to attach it again")
+	cmd.Flags().BoolVar(&sharedDataVolume, "shared-data-volume", false, "Attach the --data-volume of that name even if someone else owns it")
+	cmd.Flags().BoolVar(&idleAlarm, "idle-alarm", false, "Also create a CloudWatch alarm that stops the instance after the idle timeout at low CPU and network, in case the idle agent is not running")
+	cmd.Flags().StringVar(&ttl, "ttl", "", "Maximum lifetime, e.g. 30d, after which reap stops or terminates the instance")
 
 	return cmd
 }
@@ -102,7 +133,7 @@ func parseDuration(s string) (int, error) {
 	}
 }
 
-func runLaunch(environment, instanceType, customAMI, idleTimeout, profile, region, availabilityZone string, dryRun bool, connectionMethod, subnetType string, createNatGateway bool, useSpot bool, spotMaxPrice, spotType, s3Bucket, s3SyncPath string) error {
+func runLaunch(environment, name, instanceType, customAMI, idleTimeout, profile, region, availabilityZone string, dryRun bool, connectionMethod, subnetType string, createNatGateway bool, useSpot bool, spotMaxPrice, spotType, s3Bucket, s3SyncPath string, keepOnFailure bool, project string, overrideBudget bool, dataVolume string, sharedDataVolume bool, idleAlarm bool, ttl string) error {
 	ctx := context.Background()
 
 	// Load and validate environment configuration
@@ -117,11 +148,47 @@ func runLaunch(environment, instanceType, customAMI, idleTimeout, profile, regio
 		return fmt.Errorf("failed to parse idle timeout: %w", err)
 	}
 
+	// Parse TTL
+	ttlDuration, err := cli.ParseTTL(ttl)
+	if err != nil {
+		return err
+	}
+
+	// An idle alarm cannot watch more than a week
+	if idleAlarm {
+		if err := aws.CheckIdleAlarmTimeout(time.Duration(idleTimeoutSeconds) * time.Second); err != nil {
+			return err
+		}
+	}
+
 	// Validate launch options
 	if err := validateLaunchOptions(connectionMethod, subnetType); err != nil {
 		return err
 	}
 
+	// User data installs the lens-agent release this build was made for
+	if err := agent.CheckVersion(); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	// Check the name before creating any resources
+	if name != "" {
+		if err := cli.CheckNewInstanceName(name); err != nil {
+			return err
+		}
+	}
+
+	// Check the data volume before creating any resources
+	var dataVolumeSpec *aws.DataVolumeSpec
+	if dataVolume != "" {
+		spec, err := aws.ParseDataVolumeSpec(dataVolume)
+		if err != nil {
+			return err
+		}
+		spec.Shared = sharedDataVolume
+		dataVolumeSpec = &spec
+	}
+
 	// Display warnings and information
 	displayLaunchWarnings(connectionMethod, subnetType, createNatGateway, useSpot, s3Bucket)
 
@@ -129,7 +196,7 @@ func runLaunch(environment, instanceType, customAMI, idleTimeout, profile, regio
 		return executeDryRun(ctx, env, profile, region, availabilityZone, idleTimeout, connectionMethod, subnetType, createNatGateway, useSpot, spotMaxPrice, spotType, s3Bucket)
 	}
 
-	return executeLaunch(ctx, env, customAMI, profile, region, availabilityZone, idleTimeoutSeconds, connectionMethod, subnetType, createNatGateway, useSpot, spotMaxPrice, spotType, s3Bucket, s3SyncPath)
+	return executeLaunch(ctx, env, name, customAMI, profile, region, availabilityZone, idleTimeoutSeconds, connectionMethod, subnetType, createNatGateway, useSpot, spotMaxPrice, spotType, s3Bucket, s3SyncPath, keepOnFailure, project, overrideBudget, dataVolumeSpec, idleAlarm, ttlDuration)
 }
 
 // loadAndValidateEnvironment loads the environment configuration and applies overrides
@@ -216,59 +283,139 @@ func executeDryRun(ctx context.Context, env *config.Environment, profile, region
 }
 
 // executeLaunch performs the actual instance launch
-func executeLaunch(ctx context.Context, env *config.Environment, customAMI, profile, region, availabilityZone string, idleTimeoutSeconds int, connectionMethod, subnetType string, createNatGateway bool, useSpot bool, spotMaxPrice, spotType, s3Bucket, s3SyncPath string) error {
+func executeLaunch(ctx context.Context, env *config.Environment, name, customAMI, profile, region, availabilityZone string, idleTimeoutSeconds int, connectionMethod, subnetType string, createNatGateway bool, useSpot bool, spotMaxPrice, spotType, s3Bucket, s3SyncPath string, keepOnFailure bool, project string, overrideBudget bool, dataVolumeSpec *aws.DataVolumeSpec, idleAlarm bool, ttl time.Duration) error {
 	out := output.DefaultFormatter()
 	out.Blank()
 	out.Header(fmt.Sprintf("Launching %s environment on %s", env.Name, env.InstanceType))
 	out.Blank()
 
+	// Cancel in-flight AWS calls on Ctrl-C so that the launch is rolled back
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Setup AWS clients and determine region
 	ec2Client, ssmClient, actualRegion, err := setupAWSClient(ctx, profile, region)
 	if err != nil {
 		return err
 	}
 
+	// Refuse launches that would take a budget over its cap
+	if !overrideBudget {
+		err := cli.CheckLaunchBudget(ctx, cli.PlannedLaunch{
+			Profile:      profile,
+			App:          appName,
+			Project:      project,
+			InstanceType: env.InstanceType,
+			Region:       actualRegion,
+			EBSSize:      env.EBSVolumeSize,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Launch in the availability zone of an existing data volume
+	var dataVolume *aws.DataVolume
+	if dataVolumeSpec != nil {
+		dataVolume, availabilityZone, err = cli.DataVolumeForLaunch(ctx, ec2Client, profile, *dataVolumeSpec, availabilityZone)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Roll back resources left behind by earlier launches that were interrupted
+	if err := transaction.RecoverPending(ctx, appName); err != nil {
+		out.Warning(fmt.Sprintf("Failed to clean up an interrupted launch: %v", err))
+	}
+
+	// Journal every resource created from here on so a failure can be undone
+	tx, err := transaction.Begin(appName, profile)
+	if err != nil {
+		return fmt.Errorf("failed to start launch journal: %w", err)
+	}
+	ec2Client.SetResourceRecorder(tx)
+
+	var keyInfo *aws.KeyPairInfo
+	fail := func(err error) error {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("launch interrupted: %w", err)
+		}
+		if keepOnFailure {
+			keepFailedInstance(tx, env, keyInfo, connectionMethod, profile)
+		}
+		return tx.Fail(err, keepOnFailure)
+	}
+
 	// Setup IAM instance profile (always, for SSM access)
-	instanceProfile, err := setupInstanceProfile(ctx, profile)
+	instanceProfile, err := setupInstanceProfile(ctx, profile, tx)
 	if err != nil {
-		return err
+		return fail(err)
 	}
 
 	// Setup SSH key if needed
-	var keyInfo *aws.KeyPairInfo
 	if connectionMethod == connectionMethodSSH {
 		keyInfo, err = setupSSHKey(ctx, ec2Client, actualRegion)
 		if err != nil {
-			return err
+			return fail(err)
 		}
 	}
 
 	// Setup networking (subnet and NAT gateway)
 	subnet, err := setupNetworking(ctx, ec2Client, env.InstanceType, subnetType, availabilityZone, createNatGateway)
 	if err != nil {
-		return err
+		return fail(err)
 	}
 
 	// Setup security group
 	securityGroup, err := setupSecurityGroup(ctx, ec2Client, subnet.VpcID, connectionMethod)
 	if err != nil {
-		return err
+		return fail(err)
+	}
+
+	// Create or reattach the data volume in the instance's availability zone
+	metadata := instanceMetadata(ctx, profile, env, name, idleTimeoutSeconds, s3Bucket, s3SyncPath, project)
+	metadata.ExpiresAt = cli.ExpiresAfter(ttl)
+	dataVolumeID := ""
+	if dataVolumeSpec != nil {
+		dataVolume, err = setupDataVolume(ctx, ec2Client, *dataVolumeSpec, dataVolume, subnet.AvailabilityZone, metadata)
+		if err != nil {
+			return fail(err)
+		}
+		metadata.DataVolume = dataVolume.Name
+		dataVolumeID = dataVolume.ID
 	}
 
 	// Select AMI and generate user data
-	amiID, userData, err := prepareInstanceImage(ctx, ec2Client, env, actualRegion, customAMI, idleTimeoutSeconds, s3Bucket, s3SyncPath)
+	amiID, userData, err := prepareInstanceImage(ctx, ec2Client, env, actualRegion, customAMI, idleTimeoutSeconds, s3Bucket, s3SyncPath, dataVolumeID)
 	if err != nil {
-		return err
+		return fail(err)
 	}
 
 	// Launch and wait for instance
-	instance, err := launchAndWaitForInstance(ctx, ec2Client, ssmClient, env, subnet, securityGroup, amiID, userData, keyInfo, instanceProfile, useSpot, spotMaxPrice, spotType)
+	instance, err := launchAndWaitForInstance(ctx, ec2Client, ssmClient, env, subnet, securityGroup, amiID, userData, keyInfo, instanceProfile, metadata, dataVolumeID, useSpot, spotMaxPrice, spotType)
 	if err != nil {
-		return err
+		return fail(err)
+	}
+
+	// Create the CloudWatch alarm that stops the instance if lens-agent does not
+	if idleAlarm {
+		alarm, err := cli.SetupIdleAlarm(ctx, ec2Client, profile, instance)
+		if err != nil {
+			out.Warning(fmt.Sprintf("Failed to create idle alarm: %v", err))
+		} else {
+			out.SuccessWithDetail("Idle alarm created", alarm.Name)
+		}
+	}
+	if !metadata.ExpiresAt.IsZero() {
+		out.Info(fmt.Sprintf("Expires %s; extend it with '%s extend'", metadata.ExpiresAt.Local().Format("Jan 2 15:04"), appName))
 	}
 
 	// Display connection information
-	return displayInstanceInfo(instance, env, subnet, keyInfo, connectionMethod, subnetType, profile)
+	err = displayInstanceInfo(instance, env, subnet, keyInfo, connectionMethod, subnetType, profile)
+	if commitErr := tx.Commit(); commitErr != nil {
+		out.Warning(fmt.Sprintf("%v", commitErr))
+	}
+	return err
 }
 
 // determineRegion returns the actual region to use
@@ -294,19 +441,16 @@ func setupAWSClient(ctx context.Context, profile, region string) (*aws.EC2Client
 	}
 
 	// Create SSM client for readiness checks
-	cfg, err := awssdkconfig.LoadDefaultConfig(ctx,
-		awssdkconfig.WithSharedConfigProfile(profile),
-	)
+	ssmClient, err := aws.NewSSMClientForProfile(ctx, profile)
 	if err != nil {
 		return nil, nil, "", fmt.Errorf("failed to load AWS config for SSM: %w", err)
 	}
-	ssmClient := aws.NewSSMClient(cfg)
 
 	return ec2Client, ssmClient, actualRegion, nil
 }
 
 // setupInstanceProfile configures IAM instance profile with SSM permissions (always created)
-func setupInstanceProfile(ctx context.Context, profile string) (*aws.InstanceProfileInfo, error) {
+func setupInstanceProfile(ctx context.Context, profile string, recorder aws.ResourceRecorder) (*aws.InstanceProfileInfo, error) {
 	out := output.DefaultFormatter()
 	out.Step("🔐", "Setting up secure access permissions")
 
@@ -314,8 +458,9 @@ func setupInstanceProfile(ctx context.Context, profile string) (*aws.InstancePro
 	if err != nil {
 		return nil, fmt.Errorf("failed to create IAM client: %w", err)
 	}
+	iamClient.SetResourceRecorder(recorder)
 
-	instanceProfile, err := iamClient.GetOrCreateSessionManagerRole(ctx, "lens-rstudio")
+	instanceProfile, err := iamClient.GetOrCreateSessionManagerRole(ctx, appName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to setup Session Manager role: %w", err)
 	}
@@ -428,8 +573,27 @@ func setupSecurityGroup(ctx context.Context, ec2Client *aws.EC2Client, vpcID, co
 	return securityGroup, nil
 }
 
+// setupDataVolume creates the persistent data volume in the availability
+// zone of the instance, unless it already exists
+func setupDataVolume(ctx context.Context, ec2Client *aws.EC2Client, spec aws.DataVolumeSpec, existing *aws.DataVolume, availabilityZone string, metadata aws.InstanceMetadata) (*aws.DataVolume, error) {
+	out := output.DefaultFormatter()
+	out.Step("💾", "Preparing data volume")
+
+	if existing != nil {
+		out.SuccessWithDetail("Reattaching data volume", fmt.Sprintf("%s (%s, %d GB)", existing.Name, existing.ID, existing.SizeGB))
+		return existing, nil
+	}
+
+	volume, err := ec2Client.CreateDataVolume(ctx, spec, availabilityZone, metadata)
+	if err != nil {
+		return nil, err
+	}
+	out.SuccessWithDetail("Data volume created", fmt.Sprintf("%s (%s, %d GB)", volume.Name, volume.ID, volume.SizeGB))
+	return volume, nil
+}
+
 // prepareInstanceImage selects AMI and generates user data
-func prepareInstanceImage(ctx context.Context, ec2Client *aws.EC2Client, env *config.Environment, region, customAMI string, idleTimeoutSeconds int, s3Bucket, s3SyncPath string) (string, string, error) {
+func prepareInstanceImage(ctx context.Context, ec2Client *aws.EC2Client, env *config.Environment, region, customAMI string, idleTimeoutSeconds int, s3Bucket, s3SyncPath, dataVolumeID string) (string, string, error) {
 	out := output.DefaultFormatter()
 	var amiID string
 
@@ -448,7 +612,7 @@ func prepareInstanceImage(ctx context.Context, ec2Client *aws.EC2Client, env *co
 		out.Success("Image selected")
 	}
 
-	userData, err := rstudioconfig.GenerateUserData(env, idleTimeoutSeconds, s3Bucket, s3SyncPath)
+	userData, err := rstudioconfig.GenerateUserData(env, idleTimeoutSeconds, s3Bucket, s3SyncPath, dataVolumeID)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to generate user data: %w", err)
 	}
@@ -456,8 +620,38 @@ func prepareInstanceImage(ctx context.Context, ec2Client *aws.EC2Client, env *co
 	return amiID, userData, nil
 }
 
+// instanceMetadata returns the lens metadata written as tags on the instance,
+// so that 'sync' can rebuild local state from AWS
+func instanceMetadata(ctx context.Context, profile string, env *config.Environment, name string, idleTimeoutSeconds int, s3Bucket, s3SyncPath, project string) aws.InstanceMetadata {
+	metadata := aws.InstanceMetadata{
+		Name:        name,
+		App:         appName,
+		Project:     project,
+		IdleTimeout: (time.Duration(idleTimeoutSeconds) * time.Second).String(),
+		AMIBase:     env.AMIBase,
+		S3Bucket:    s3Bucket,
+	}
+	if s3Bucket != "" {
+		metadata.S3SyncPath = s3SyncPath
+	}
+
+	stsClient, err := aws.NewSTSClient(ctx, profile)
+	if err != nil {
+		fmt.Printf("Warning: Could not determine instance owner: %v\n", err)
+		return metadata
+	}
+	identity, err := stsClient.GetCallerIdentity(ctx)
+	if err != nil {
+		fmt.Printf("Warning: Could not determine instance owner: %v\n", err)
+		return metadata
+	}
+	metadata.Owner = identity.ARN
+
+	return metadata
+}
+
 // launchAndWaitForInstance launches the EC2 instance and waits for it to be running
-func launchAndWaitForInstance(ctx context.Context, ec2Client *aws.EC2Client, ssmClient *aws.SSMClient, env *config.Environment, subnet *aws.SubnetInfo, securityGroup *aws.SecurityGroupInfo, amiID, userData string, keyInfo *aws.KeyPairInfo, instanceProfile *aws.InstanceProfileInfo, useSpot bool, spotMaxPrice, spotType string) (*types.Instance, error) {
+func launchAndWaitForInstance(ctx context.Context, ec2Client *aws.EC2Client, ssmClient *aws.SSMClient, env *config.Environment, subnet *aws.SubnetInfo, securityGroup *aws.SecurityGroupInfo, amiID, userData string, keyInfo *aws.KeyPairInfo, instanceProfile *aws.InstanceProfileInfo, metadata aws.InstanceMetadata, dataVolumeID string, useSpot bool, spotMaxPrice, spotType string) (*types.Instance, error) {
 	out := output.DefaultFormatter()
 	out.Step("🚀", fmt.Sprintf("Starting your %s environment", env.Name))
 
@@ -473,6 +667,7 @@ func launchAndWaitForInstance(ctx context.Context, ec2Client *aws.EC2Client, ssm
 		UseSpot:          useSpot,
 		SpotMaxPrice:     spotMaxPrice,
 		SpotInstanceType: spotType,
+		Metadata:         metadata,
 	}
 
 	// Add SSH key if provided
@@ -493,12 +688,25 @@ func launchAndWaitForInstance(ctx context.Context, ec2Client *aws.EC2Client, ssm
 		return nil, fmt.Errorf("instance failed to start: %w", err)
 	}
 
+	// Attach the data volume; the user data waits for it and mounts it
+	if dataVolumeID != "" {
+		out.Status("Attaching data volume")
+		if err := ec2Client.AttachDataVolume(ctx, dataVolumeID, instanceID); err != nil {
+			return nil, err
+		}
+	}
+
 	// Get updated instance info
 	instance, err = ec2Client.GetInstanceInfo(ctx, instanceID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get instance info: %w", err)
 	}
 
+	// Tag the instance and its volumes so billed costs can be reconciled
+	if err := ec2Client.TagCostAllocation(ctx, *instance); err != nil {
+		out.Warning(fmt.Sprintf("Failed to set the %s cost allocation tag: %v", aws.TagInstance, err))
+	}
+
 	// Poll for RStudio Server readiness with progress streaming
 	out.Blank()
 	out.Status("Installing RStudio Server")
@@ -511,6 +719,9 @@ func launchAndWaitForInstance(ctx context.Context, ec2Client *aws.EC2Client, ssm
 
 	if err := waitForRStudioReady(ctx, ssmClient, instance); err != nil {
 		close(progressDone) // Stop progress streaming
+		if ctx.Err() != nil {
+			return nil, err
+		}
 		out.Blank()
 		out.Warning(fmt.Sprintf("%v", err))
 		out.Info("You can still try connecting - the service may still be starting up")
@@ -582,11 +793,6 @@ func displayInstanceInfo(instance *types.Instance, env *config.Environment, subn
 
 // saveInstanceToState saves the launched instance to local state
 func saveInstanceToState(instance *types.Instance, env *config.Environment, keyInfo *aws.KeyPairInfo, connectionMethod string) error {
-	state, err := config.LoadState()
-	if err != nil {
-		return fmt.Errorf("failed to load state: %w", err)
-	}
-
 	publicIP := ""
 	if instance.PublicIpAddress != nil {
 		publicIP = *instance.PublicIpAddress
@@ -613,26 +819,68 @@ func saveInstanceToState(instance *types.Instance, env *config.Environment, keyI
 		securityGroup = *instance.SecurityGroups[0].GroupId
 	}
 
+	// Metadata tagged at launch
+	metadata := aws.ParseInstanceMetadata(instance.Tags)
+
 	instanceConfig := &config.Instance{
 		ID:            *instance.InstanceId,
+		Name:          metadata.Name,
+		App:           metadata.App,
 		Environment:   env.Name,
 		InstanceType:  env.InstanceType,
 		PublicIP:      publicIP,
 		KeyPair:       keyPairName,
 		LaunchedAt:    *instance.LaunchTime,
-		IdleTimeout:   "", // Not tracked yet
+		IdleTimeout:   metadata.IdleTimeout,
 		TunnelPID:     0,
 		Region:        region,
 		SecurityGroup: securityGroup,
 		AMIBase:       env.AMIBase,
+		EBSSize:       metadata.EBSSize,
+		S3Bucket:      metadata.S3Bucket,
+		S3MountPath:   metadata.S3SyncPath,
+		Owner:         metadata.Owner,
+		Project:       metadata.Project,
+		DataVolume:    metadata.DataVolume,
+	}
+	if !metadata.ExpiresAt.IsZero() {
+		instanceConfig.ExpiresAt = &metadata.ExpiresAt
 	}
 
 	// Record initial state as "running"
 	instanceConfig.RecordStateChange("running")
 
-	state.Instances[*instance.InstanceId] = instanceConfig
+	return config.UpdateState(func(state *config.LocalState) error {
+		state.Instances[*instance.InstanceId] = instanceConfig
+		return nil
+	})
+}
+
+// keepFailedInstance saves the instance of a failed launch, if one was created,
+// to local state so that it can be inspected and terminated later
+func keepFailedInstance(tx *transaction.Transaction, env *config.Environment, keyInfo *aws.KeyPairInfo, connectionMethod, profile string) {
+	ctx := context.Background()
+	for _, resource := range tx.Resources() {
+		if resource.Kind != aws.ResourceInstance {
+			continue
+		}
 
-	return state.Save()
+		ec2Client, err := aws.NewEC2ClientForProfileRegion(ctx, profile, resource.Region)
+		if err != nil {
+			fmt.Printf("Warning: Failed to create AWS client: %v\n", err)
+			return
+		}
+		instance, err := ec2Client.GetInstanceInfo(ctx, resource.ID)
+		if err != nil {
+			fmt.Printf("Warning: Failed to get instance info for %s: %v\n", resource.ID, err)
+			return
+		}
+		if err := saveInstanceToState(instance, env, keyInfo, connectionMethod); err != nil {
+			fmt.Printf("Warning: Failed to save instance to local state: %v\n", err)
+			return
+		}
+		fmt.Printf("Instance %s saved to local state; use 'lens-rstudio terminate %s' when done\n", resource.ID, resource.ID)
+	}
 }
 
 // printDryRunConfiguration displays the dry run configuration
@@ -791,8 +1039,8 @@ func waitForRStudioReady(ctx context.Context, ssmClient *aws.SSMClient, instance
 	config := readiness.SSMServiceConfig{
 		InstanceID: instanceID,
 		Port:       8787,
-		Timeout:    5 * time.Minute,  // 5 minutes should be enough for installation
-		Retry:      10 * time.Second, // Check every 10 seconds
+		Timeout:    5 * time.Minute, // 5 minutes should be enough for installation
+		Retry:      readinessPollInterval,
 	}
 
 	// Track elapsed time for progress updates
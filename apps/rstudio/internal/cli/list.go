@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"sort"
@@ -13,6 +14,7 @@ import (
 
 	awslib "github.com/scttfrdmn/lens/pkg/aws"
 	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/cost"
 	"github.com/spf13/cobra"
 )
 
@@ -25,6 +27,7 @@ var (
 	sortBy            string
 	outputFormat      string
 	noColor           bool
+	allUsers          bool
 )
 
 // NewListCmd creates the list command for viewing active instances
@@ -40,9 +43,10 @@ Filter Options:
   --ide           Filter by IDE type (jupyter, rstudio, vscode)
   --older-than    Show instances older than duration (e.g., 2h, 1d)
   --newer-than    Show instances newer than duration (e.g., 30m, 1h)
+  --all-users     Show the instances of everyone sharing the state backend
 
 Sort Options:
-  --sort-by       Sort by: uptime, type, env, state (default: uptime)
+  --sort-by       Sort by: uptime, type, env, state, owner, cost (default: uptime)
 
 Output Options:
   --format        Output format: table, json, csv (default: table)
@@ -57,43 +61,58 @@ Output Options:
 	cmd.Flags().StringVar(&filterIDE, "ide", "", "Filter by IDE type (jupyter, rstudio, vscode)")
 	cmd.Flags().StringVar(&olderThan, "older-than", "", "Show instances older than duration (e.g., 2h, 1d)")
 	cmd.Flags().StringVar(&newerThan, "newer-than", "", "Show instances newer than duration (e.g., 30m, 1h)")
-	cmd.Flags().StringVar(&sortBy, "sort-by", "uptime", "Sort by: uptime, type, env, state")
+	cmd.Flags().StringVar(&sortBy, "sort-by", "uptime", "Sort by: uptime, type, env, state, owner, cost")
 	cmd.Flags().StringVar(&outputFormat, "format", "table", "Output format: table, json, csv")
 	cmd.Flags().BoolVar(&noColor, "no-color", false, "Disable color-coded output")
+	cmd.Flags().BoolVar(&allUsers, "all-users", false, "Show the instances and costs of everyone sharing the state backend")
 
 	return cmd
 }
 
 type instanceInfo struct {
 	Instance *config.Instance
+	Owner    string
 	State    string
 	Uptime   time.Duration
+	Cost     float64 // Cost to date
 }
 
 func runList() error {
 	ctx := context.Background()
 
-	state, err := config.LoadState()
+	states, err := loadListStates()
 	if err != nil {
-		return fmt.Errorf("failed to load state: %w", err)
-	}
-
-	if len(state.Instances) == 0 {
-		fmt.Println("No instances found")
-		return nil
+		return err
 	}
 
 	// Gather instance information
 	var instances []instanceInfo
-	for _, instance := range state.Instances {
-		instanceState := getInstanceState(ctx, instance)
-		uptime := time.Since(instance.LaunchedAt)
-
-		instances = append(instances, instanceInfo{
-			Instance: instance,
-			State:    instanceState,
-			Uptime:   uptime,
-		})
+	for owner, state := range states {
+		for _, instance := range state.Instances {
+			instanceOwner := owner
+			if instanceOwner == "" {
+				instanceOwner = instance.Owner
+			}
+			calc := cost.CalculateCost(
+				cost.PriceQuery{InstanceType: instance.InstanceType, Region: instance.Region},
+				instance.LaunchedAt,
+				convertToCostStateChanges(instance.StateChanges),
+				instance.EBSSize,
+			)
+
+			instances = append(instances, instanceInfo{
+				Instance: instance,
+				Owner:    instanceOwner,
+				State:    getInstanceState(ctx, instance),
+				Uptime:   time.Since(instance.LaunchedAt),
+				Cost:     calc.TotalCost,
+			})
+		}
+	}
+
+	if len(instances) == 0 {
+		fmt.Println("No instances found")
+		return nil
 	}
 
 	// Apply filters
@@ -118,6 +137,27 @@ func runList() error {
 	}
 }
 
+// loadListStates returns the state to list by owner: the current user's
+// state, or with --all-users the state of everyone sharing the backend
+func loadListStates() (map[string]*config.LocalState, error) {
+	if !allUsers {
+		state, err := config.LoadState()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load state: %w", err)
+		}
+		return map[string]*config.LocalState{"": state}, nil
+	}
+
+	states, err := config.LoadAllStates()
+	if errors.Is(err, config.ErrStateNotShared) {
+		return nil, fmt.Errorf("--all-users: %w", err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load team state: %w", err)
+	}
+	return states, nil
+}
+
 func applyFilters(instances []instanceInfo) []instanceInfo {
 	var filtered []instanceInfo
 
@@ -170,6 +210,10 @@ func sortInstances(instances []instanceInfo) {
 			return instances[i].Instance.Environment < instances[j].Instance.Environment
 		case "state":
 			return instances[i].State < instances[j].State
+		case "owner":
+			return instances[i].Owner < instances[j].Owner
+		case "cost":
+			return instances[i].Cost > instances[j].Cost // Most expensive first
 		case "uptime":
 			fallthrough
 		default:
@@ -179,8 +223,12 @@ func sortInstances(instances []instanceInfo) {
 }
 
 func outputTable(instances []instanceInfo) error {
+	if allUsers {
+		return outputTeamTable(instances)
+	}
+
 	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
-	if _, err := fmt.Fprintln(w, "ID\tENV\tTYPE\tSTATE\tUPTIME\tTUNNEL"); err != nil {
+	if _, err := fmt.Fprintln(w, "ID\tNAME\tENV\tTYPE\tSTATE\tUPTIME\tTUNNEL"); err != nil {
 		return fmt.Errorf("failed to write header: %w", err)
 	}
 
@@ -196,8 +244,9 @@ func outputTable(instances []instanceInfo) error {
 			state = colorizeState(state)
 		}
 
-		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
 			info.Instance.ID,
+			info.Instance.Name,
 			info.Instance.Environment,
 			info.Instance.InstanceType,
 			state,
@@ -211,12 +260,64 @@ func outputTable(instances []instanceInfo) error {
 	return w.Flush()
 }
 
+// outputTeamTable lists the instances of every owner with their cost to
+// date, followed by the total cost per owner and for the team
+func outputTeamTable(instances []instanceInfo) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	if _, err := fmt.Fprintln(w, "OWNER\tID\tNAME\tENV\tTYPE\tSTATE\tUPTIME\tCOST"); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	ownerCosts := make(map[string]float64)
+	var owners []string
+	var teamCost float64
+	for _, info := range instances {
+		state := info.State
+		if !noColor {
+			state = colorizeState(state)
+		}
+
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			info.Owner,
+			info.Instance.ID,
+			info.Instance.Name,
+			info.Instance.Environment,
+			info.Instance.InstanceType,
+			state,
+			formatDuration(info.Instance.LaunchedAt),
+			cost.FormatCostShort(info.Cost),
+		); err != nil {
+			return fmt.Errorf("failed to write instance data: %w", err)
+		}
+
+		if _, ok := ownerCosts[info.Owner]; !ok {
+			owners = append(owners, info.Owner)
+		}
+		ownerCosts[info.Owner] += info.Cost
+		teamCost += info.Cost
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	sort.Strings(owners)
+	fmt.Println()
+	fmt.Println("Cost to date by owner:")
+	for _, owner := range owners {
+		fmt.Printf("  %-20s %s\n", owner, cost.FormatCostShort(ownerCosts[owner]))
+	}
+	fmt.Printf("  %-20s %s\n", "Team total", cost.FormatCostShort(teamCost))
+	return nil
+}
+
 func outputJSON(instances []instanceInfo) error {
 	output := make([]map[string]interface{}, 0, len(instances))
 
 	for _, info := range instances {
 		output = append(output, map[string]interface{}{
 			"id":            info.Instance.ID,
+			"name":          info.Instance.Name,
+			"owner":         info.Owner,
 			"environment":   info.Instance.Environment,
 			"instance_type": info.Instance.InstanceType,
 			"state":         info.State,
@@ -227,6 +328,7 @@ func outputJSON(instances []instanceInfo) error {
 			"public_ip":     info.Instance.PublicIP,
 			"launched_at":   info.Instance.LaunchedAt,
 			"idle_timeout":  info.Instance.IdleTimeout,
+			"cost":          info.Cost,
 		})
 	}
 
@@ -240,7 +342,7 @@ func outputCSV(instances []instanceInfo) error {
 	defer w.Flush()
 
 	// Write header
-	if err := w.Write([]string{"ID", "Environment", "InstanceType", "State", "Uptime", "Region", "PublicIP", "TunnelPID"}); err != nil {
+	if err := w.Write([]string{"ID", "Environment", "InstanceType", "State", "Uptime", "Region", "PublicIP", "TunnelPID", "Owner", "Cost", "Name"}); err != nil {
 		return fmt.Errorf("failed to write CSV header: %w", err)
 	}
 
@@ -255,6 +357,9 @@ func outputCSV(instances []instanceInfo) error {
 			info.Instance.Region,
 			info.Instance.PublicIP,
 			fmt.Sprintf("%d", info.Instance.TunnelPID),
+			info.Owner,
+			fmt.Sprintf("%.2f", info.Cost),
+			info.Instance.Name,
 		}
 		if err := w.Write(record); err != nil {
 			return fmt.Errorf("failed to write CSV record: %w", err)
@@ -0,0 +1,11 @@
+package cli
+
+import (
+	"github.com/scttfrdmn/lens/pkg/cli"
+	"github.com/spf13/cobra"
+)
+
+// NewScheduleCmd creates the schedule command for starting and stopping instances at set times
+func NewScheduleCmd() *cobra.Command {
+	return cli.NewScheduleCmd("lens-rstudio")
+}
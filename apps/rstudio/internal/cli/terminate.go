@@ -3,8 +3,10 @@ package cli
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/cli"
 	"github.com/scttfrdmn/lens/pkg/config"
 	"github.com/spf13/cobra"
 )
@@ -14,12 +16,16 @@ func NewTerminateCmd() *cobra.Command {
 	var force bool
 
 	cmd := &cobra.Command{
-		Use:   "terminate INSTANCE_ID",
+		Use:   "terminate [INSTANCE]",
 		Short: "Terminate an instance",
 		Long:  "Permanently terminate an EC2 instance and clean up local state",
-		Args:  cobra.ExactArgs(1),
+		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runTerminate(args[0], force)
+			instanceRef := ""
+			if len(args) > 0 {
+				instanceRef = args[0]
+			}
+			return runTerminate(instanceRef, force)
 		},
 	}
 
@@ -27,7 +33,7 @@ func NewTerminateCmd() *cobra.Command {
 	return cmd
 }
 
-func runTerminate(instanceID string, force bool) error {
+func runTerminate(instanceRef string, force bool) error {
 	ctx := context.Background()
 
 	// Load state to get instance details
@@ -36,11 +42,12 @@ func runTerminate(instanceID string, force bool) error {
 		return fmt.Errorf("failed to load state: %w", err)
 	}
 
-	// Get instance from state
-	instance, exists := state.Instances[instanceID]
-	if !exists {
-		return fmt.Errorf("instance %s not found in local state", instanceID)
+	// Resolve the instance by name, ID or prefix
+	instance, err := cli.ResolveInstance(state, instanceRef)
+	if err != nil {
+		return err
 	}
+	instanceID := instance.ID
 
 	// Confirm termination unless force flag is set
 	if !force {
@@ -66,6 +73,14 @@ func runTerminate(instanceID string, force bool) error {
 		return fmt.Errorf("failed to terminate instance: %w", err)
 	}
 
+	// Delete the idle alarm and schedules so that they do not outlive the instance
+	if err := cli.DeleteIdleAlarm(ctx, instance); err != nil {
+		fmt.Printf("Warning: Failed to delete idle alarm: %v\n", err)
+	}
+	if err := cli.DeleteSchedules(ctx, instance); err != nil {
+		fmt.Printf("Warning: Failed to delete schedules: %v\n", err)
+	}
+
 	// Kill SSH tunnel if it's running
 	if instance.TunnelPID > 0 {
 		if err := killProcess(instance.TunnelPID); err != nil {
@@ -75,14 +90,20 @@ func runTerminate(instanceID string, force bool) error {
 		}
 	}
 
-	// Remove instance from local state
-	delete(state.Instances, instanceID)
-	if err := state.Save(); err != nil {
+	// Move instance to the terminated history of local state
+	err = config.UpdateState(func(state *config.LocalState) error {
+		state.RecordTermination(instanceID, time.Now())
+		return nil
+	})
+	if err != nil {
 		fmt.Printf("Warning: Failed to update local state: %v\n", err)
 	}
 
 	fmt.Printf("Instance %s terminated successfully\n", instanceID)
 	fmt.Println("Note: The EC2 instance, security groups, and key pairs may take a few moments to fully terminate in AWS")
+	if instance.DataVolume != "" {
+		fmt.Printf("Data volume %s was kept; launch with --data-volume %s to attach it again\n", instance.DataVolume, instance.DataVolume)
+	}
 
 	return nil
 }
@@ -0,0 +1,11 @@
+package cli
+
+import (
+	"github.com/scttfrdmn/lens/pkg/cli"
+	"github.com/spf13/cobra"
+)
+
+// NewVolumesCmd creates the volumes command for managing persistent data volumes
+func NewVolumesCmd() *cobra.Command {
+	return cli.NewVolumesCmd("lens-rstudio")
+}
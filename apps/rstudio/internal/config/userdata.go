@@ -4,20 +4,23 @@ import (
 	"encoding/base64"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/scttfrdmn/lens/pkg/agent"
+	"github.com/scttfrdmn/lens/pkg/aws"
 	pkgconfig "github.com/scttfrdmn/lens/pkg/config"
 )
 
 // GenerateUserData creates a cloud-init user data script for the given environment
-func GenerateUserData(env *pkgconfig.Environment, idleTimeoutSeconds int, s3Bucket, s3SyncPath string) (string, error) {
-	script := generateUserDataScript(env, idleTimeoutSeconds, s3Bucket, s3SyncPath)
+func GenerateUserData(env *pkgconfig.Environment, idleTimeoutSeconds int, s3Bucket, s3SyncPath, dataVolumeID string) (string, error) {
+	script := generateUserDataScript(env, idleTimeoutSeconds, s3Bucket, s3SyncPath, dataVolumeID)
 	// AWS expects user data to be base64 encoded
 	encoded := base64.StdEncoding.EncodeToString([]byte(script))
 	return encoded, nil
 }
 
 // generateUserDataScript creates the actual bash script for RStudio Server
-func generateUserDataScript(env *pkgconfig.Environment, idleTimeoutSeconds int, s3Bucket, s3SyncPath string) string {
+func generateUserDataScript(env *pkgconfig.Environment, idleTimeoutSeconds int, s3Bucket, s3SyncPath, dataVolumeID string) string {
 	var sb strings.Builder
 
 	// Start with bash shebang and error handling
@@ -55,6 +58,11 @@ func generateUserDataScript(env *pkgconfig.Environment, idleTimeoutSeconds int,
 	sb.WriteString("systemctl enable snap.amazon-ssm-agent.amazon-ssm-agent.service\n")
 	sb.WriteString("systemctl start snap.amazon-ssm-agent.amazon-ssm-agent.service\n\n")
 
+	// Mount the persistent data volume, which is attached once the instance runs
+	if dataVolumeID != "" {
+		sb.WriteString(aws.DataVolumeMountScript(dataVolumeID))
+	}
+
 	// GPU detection and driver installation
 	sb.WriteString(generateGPUSetupScript())
 
@@ -161,29 +169,15 @@ func generateUserDataScript(env *pkgconfig.Environment, idleTimeoutSeconds int,
 	sb.WriteString("systemctl restart rstudio-server\n")
 	sb.WriteString("systemctl enable rstudio-server\n\n")
 
-	// Setup idle detection system
-	sb.WriteString("# Setup idle detection and auto-stop system\n")
-	sb.WriteString("echo 'Setting up idle detection...'\n\n")
-
-	// Install jq for JSON parsing
-	sb.WriteString("apt-get install -y jq ec2-instance-connect\n\n")
-
-	// Embed the idle monitor script
-	sb.WriteString(generateIdleMonitorScript())
-
-	// Embed the auto-stop script
-	sb.WriteString(generateAutoStopScript())
-
-	// Create systemd service files
-	sb.WriteString(generateIdleDetectionServices(idleTimeoutSeconds))
+	// Install EC2 Instance Connect for SSH connections
+	sb.WriteString("apt-get install -y ec2-instance-connect\n\n")
 
-	// Enable and start the services
-	sb.WriteString("systemctl daemon-reload\n")
-	sb.WriteString("systemctl enable rstudio-idle-monitor.timer\n")
-	sb.WriteString("systemctl enable rstudio-auto-stop.timer\n")
-	sb.WriteString("systemctl start rstudio-idle-monitor.timer\n")
-	sb.WriteString("systemctl start rstudio-auto-stop.timer\n")
-	sb.WriteString("echo 'Idle detection system installed and enabled'\n\n")
+	// Install the agent that stops the instance when it is idle
+	sb.WriteString(agent.InstallScript(agent.InstallOptions{
+		App:         "lens-rstudio",
+		IdleTimeout: time.Duration(idleTimeoutSeconds) * time.Second,
+		Signals:     []string{"rstudio", "cpu", "gpu", "sessions"},
+	}))
 
 	// Setup S3 data sync if bucket is specified
 	if s3Bucket != "" {
@@ -205,277 +199,8 @@ func generateUserDataScript(env *pkgconfig.Environment, idleTimeoutSeconds int,
 }
 
 // GetRawUserData returns the user data script without base64 encoding (for debugging)
-func GetRawUserData(env *pkgconfig.Environment, idleTimeoutSeconds int, s3Bucket, s3SyncPath string) string {
-	return generateUserDataScript(env, idleTimeoutSeconds, s3Bucket, s3SyncPath)
-}
-
-// generateIdleMonitorScript creates the idle monitor script for RStudio
-func generateIdleMonitorScript() string {
-	return `# Create idle monitor script
-cat > /usr/local/bin/rstudio-idle-monitor.sh << 'IDLE_MONITOR_EOF'
-#!/bin/bash
-# RStudio Idle Monitor
-set -e
-
-# Configuration
-IDLE_STATE_FILE="/var/run/rstudio-idle-status"
-LAST_ACTIVITY_FILE="/var/run/rstudio-last-activity"
-CPU_THRESHOLD="${CPU_THRESHOLD:-10}"
-LOG_FILE="/var/log/rstudio-idle-monitor.log"
-
-log() {
-    echo "[$(date '+%Y-%m-%d %H:%M:%S')] $1" | tee -a "$LOG_FILE"
-}
-
-check_rstudio_sessions() {
-    # Check for active RStudio sessions by looking at rserver process children
-    local rserver_pids=$(pgrep -f "rserver" || echo "")
-    if [ -z "$rserver_pids" ]; then
-        log "WARNING: RStudio Server process not found"
-        return 1
-    fi
-
-    # Check for active rsession processes (user sessions)
-    local rsession_count=$(pgrep -c "rsession" 2>/dev/null || echo "0")
-    if [ "$rsession_count" -gt 0 ]; then
-        log "Active RStudio sessions detected: $rsession_count"
-        return 0
-    fi
-
-    log "No active RStudio sessions"
-    return 1
-}
-
-check_cpu_usage() {
-    local cpu_idle=$(top -bn1 | grep "Cpu(s)" | sed "s/.*, *\([0-9.]*\)%* id.*/\1/" | awk '{print int($1)}')
-    local cpu_usage=$((100 - cpu_idle))
-    if [ "$cpu_usage" -gt "$CPU_THRESHOLD" ]; then
-        log "CPU usage above threshold: ${cpu_usage}% > ${CPU_THRESHOLD}%"
-        return 0
-    fi
-    log "CPU usage below threshold: ${cpu_usage}% <= ${CPU_THRESHOLD}%"
-    return 1
-}
-
-check_running_processes() {
-    # Check for active R processes
-    local r_pids=$(pgrep -f "rsession" || echo "")
-    if [ -z "$r_pids" ]; then
-        log "No R session processes"
-        return 1
-    fi
-
-    for pid in $r_pids; do
-        local cpu_usage=$(ps -p "$pid" -o %cpu= 2>/dev/null | awk '{print int($1)}')
-        if [ -n "$cpu_usage" ] && [ "$cpu_usage" -gt 5 ]; then
-            local cmd=$(ps -p "$pid" -o comm= 2>/dev/null)
-            log "Active R process detected: PID=$pid CMD=$cmd CPU=${cpu_usage}%"
-            return 0
-        fi
-    done
-    log "No active R computation processes"
-    return 1
-}
-
-main() {
-    log "=== Starting idle detection check ==="
-    local is_active=0
-    if check_rstudio_sessions; then
-        is_active=1
-    elif check_cpu_usage; then
-        is_active=1
-    elif check_running_processes; then
-        is_active=1
-    fi
-
-    if [ $is_active -eq 1 ]; then
-        echo "active" > "$IDLE_STATE_FILE"
-        date +%s > "$LAST_ACTIVITY_FILE"
-        log "RESULT: System is ACTIVE"
-    else
-        echo "idle" > "$IDLE_STATE_FILE"
-        if [ ! -f "$LAST_ACTIVITY_FILE" ]; then
-            date +%s > "$LAST_ACTIVITY_FILE"
-        fi
-        local last_activity=$(cat "$LAST_ACTIVITY_FILE")
-        local now=$(date +%s)
-        local idle_duration=$((now - last_activity))
-        log "RESULT: System is IDLE (duration: ${idle_duration}s)"
-    fi
-    log "=== Idle detection check complete ==="
-}
-
-main
-IDLE_MONITOR_EOF
-
-chmod +x /usr/local/bin/rstudio-idle-monitor.sh
-
-`
-}
-
-// generateAutoStopScript creates the auto-stop script
-func generateAutoStopScript() string {
-	return `# Create auto-stop script
-cat > /usr/local/bin/rstudio-auto-stop.sh << 'AUTO_STOP_EOF'
-#!/bin/bash
-# RStudio Auto-Stop Service
-set -e
-
-# Configuration
-IDLE_STATE_FILE="/var/run/rstudio-idle-status"
-LAST_ACTIVITY_FILE="/var/run/rstudio-last-activity"
-IDLE_TIMEOUT="${IDLE_TIMEOUT:-14400}"
-IDLE_ACTION="${IDLE_ACTION:-stop}"
-LOG_FILE="/var/log/rstudio-auto-stop.log"
-ENABLED_FILE="/etc/rstudio-auto-stop.enabled"
-
-log() {
-    echo "[$(date '+%Y-%m-%d %H:%M:%S')] $1" | tee -a "$LOG_FILE"
-}
-
-if [ ! -f "$ENABLED_FILE" ]; then
-    if [ ! -f "/var/run/rstudio-auto-stop-initialized" ]; then
-        echo "enabled" > "$ENABLED_FILE"
-        touch "/var/run/rstudio-auto-stop-initialized"
-        log "Auto-stop initialized and enabled"
-    fi
-fi
-
-if [ -f "$ENABLED_FILE" ] && [ "$(cat $ENABLED_FILE)" = "disabled" ]; then
-    exit 0
-fi
-
-if [ ! -f "$IDLE_STATE_FILE" ] || [ ! -f "$LAST_ACTIVITY_FILE" ]; then
-    exit 0
-fi
-
-CURRENT_STATE=$(cat "$IDLE_STATE_FILE")
-if [ "$CURRENT_STATE" != "idle" ]; then
-    exit 0
-fi
-
-LAST_ACTIVITY=$(cat "$LAST_ACTIVITY_FILE")
-NOW=$(date +%s)
-IDLE_DURATION=$((NOW - LAST_ACTIVITY))
-
-log "System idle for ${IDLE_DURATION}s (threshold: ${IDLE_TIMEOUT}s)"
-
-if [ $IDLE_DURATION -lt $IDLE_TIMEOUT ]; then
-    REMAINING=$((IDLE_TIMEOUT - IDLE_DURATION))
-    log "Time until auto-stop: ${REMAINING}s"
-    exit 0
-fi
-
-log "========================================"
-log "IDLE TIMEOUT EXCEEDED - INITIATING SHUTDOWN"
-log "========================================"
-log "Idle duration: ${IDLE_DURATION}s"
-log "Idle threshold: ${IDLE_TIMEOUT}s"
-log "Action: ${IDLE_ACTION}"
-
-INSTANCE_ID=$(ec2-metadata --instance-id 2>/dev/null | cut -d' ' -f2 || echo "unknown")
-REGION=$(ec2-metadata --availability-zone 2>/dev/null | cut -d' ' -f2 | sed 's/[a-z]$//' || echo "us-east-1")
-
-log "Instance ID: $INSTANCE_ID"
-log "Region: $REGION"
-
-if [ "$IDLE_ACTION" = "hibernate" ]; then
-    log "Attempting to hibernate instance..."
-    if aws ec2 stop-instances --instance-ids "$INSTANCE_ID" --region "$REGION" --hibernate 2>&1 | tee -a "$LOG_FILE"; then
-        log "Hibernate command sent successfully"
-    else
-        log "ERROR: Failed to hibernate, falling back to stop"
-        aws ec2 stop-instances --instance-ids "$INSTANCE_ID" --region "$REGION" 2>&1 | tee -a "$LOG_FILE" || log "ERROR: Stop command also failed"
-    fi
-else
-    log "Stopping instance..."
-    if aws ec2 stop-instances --instance-ids "$INSTANCE_ID" --region "$REGION" 2>&1 | tee -a "$LOG_FILE"; then
-        log "Stop command sent successfully"
-    else
-        log "ERROR: Failed to stop instance"
-        exit 1
-    fi
-fi
-
-log "Auto-stop process complete"
-log "========================================"
-AUTO_STOP_EOF
-
-chmod +x /usr/local/bin/rstudio-auto-stop.sh
-
-`
-}
-
-// generateIdleDetectionServices creates the systemd service and timer files
-func generateIdleDetectionServices(idleTimeoutSeconds int) string {
-	idleTimeoutEnv := fmt.Sprintf("Environment=\"IDLE_TIMEOUT=%d\"", idleTimeoutSeconds)
-
-	return fmt.Sprintf(`# Create idle monitor systemd service
-cat > /etc/systemd/system/rstudio-idle-monitor.service << 'SERVICE_EOF'
-[Unit]
-Description=RStudio Idle Monitor
-After=network.target
-
-[Service]
-Type=oneshot
-ExecStart=/usr/local/bin/rstudio-idle-monitor.sh
-Environment="CPU_THRESHOLD=10"
-StandardOutput=journal
-StandardError=journal
-
-[Install]
-WantedBy=multi-user.target
-SERVICE_EOF
-
-# Create idle monitor timer
-cat > /etc/systemd/system/rstudio-idle-monitor.timer << 'TIMER_EOF'
-[Unit]
-Description=Run RStudio idle monitor every 5 minutes
-Requires=rstudio-idle-monitor.service
-
-[Timer]
-OnBootSec=5min
-OnUnitActiveSec=5min
-AccuracySec=1min
-
-[Install]
-WantedBy=timers.target
-TIMER_EOF
-
-# Create auto-stop systemd service
-cat > /etc/systemd/system/rstudio-auto-stop.service << 'SERVICE_EOF'
-[Unit]
-Description=RStudio Auto-Stop Service
-After=network.target rstudio-idle-monitor.service
-
-[Service]
-Type=oneshot
-ExecStart=/usr/local/bin/rstudio-auto-stop.sh
-%s
-Environment="IDLE_ACTION=stop"
-StandardOutput=journal
-StandardError=journal
-
-[Install]
-WantedBy=multi-user.target
-SERVICE_EOF
-
-# Create auto-stop timer
-cat > /etc/systemd/system/rstudio-auto-stop.timer << 'TIMER_EOF'
-[Unit]
-Description=Check for auto-stop every minute
-Requires=rstudio-auto-stop.service
-
-[Timer]
-OnBootSec=1min
-OnUnitActiveSec=1min
-AccuracySec=30s
-
-[Install]
-WantedBy=timers.target
-TIMER_EOF
-
-`, idleTimeoutEnv)
+func GetRawUserData(env *pkgconfig.Environment, idleTimeoutSeconds int, s3Bucket, s3SyncPath, dataVolumeID string) string {
+	return generateUserDataScript(env, idleTimeoutSeconds, s3Bucket, s3SyncPath, dataVolumeID)
 }
 
 // generateS3SyncScript creates the S3 mounting script using mountpoint-s3
@@ -0,0 +1,11 @@
+package cli
+
+import (
+	"github.com/scttfrdmn/lens/pkg/cli"
+	"github.com/spf13/cobra"
+)
+
+// NewBackupCmd creates the backup command for snapshotting, restoring and pruning volume backups
+func NewBackupCmd() *cobra.Command {
+	return cli.NewBackupCmd("lens-vscode")
+}
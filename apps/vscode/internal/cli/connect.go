@@ -7,6 +7,7 @@ import (
 	"os/exec"
 
 	awslib "github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/cli"
 	"github.com/scttfrdmn/lens/pkg/config"
 	"github.com/spf13/cobra"
 )
@@ -16,16 +17,16 @@ func NewConnectCmd() *cobra.Command {
 	var localPort int
 
 	cmd := &cobra.Command{
-		Use:   "connect [INSTANCE_ID]",
+		Use:   "connect [INSTANCE]",
 		Short: "Connect to an existing VSCode Server instance",
 		Long:  "Setup SSH tunnel or Session Manager port forwarding to access VSCode Server",
 		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			instanceID := ""
+			instanceRef := ""
 			if len(args) > 0 {
-				instanceID = args[0]
+				instanceRef = args[0]
 			}
-			return runConnect(instanceID, localPort)
+			return runConnect(instanceRef, localPort)
 		},
 	}
 
@@ -33,7 +34,7 @@ func NewConnectCmd() *cobra.Command {
 	return cmd
 }
 
-func runConnect(instanceID string, localPort int) error {
+func runConnect(instanceRef string, localPort int) error {
 	ctx := context.Background()
 
 	// Load state to get instance details
@@ -42,20 +43,12 @@ func runConnect(instanceID string, localPort int) error {
 		return fmt.Errorf("failed to load state: %w", err)
 	}
 
-	// If no instance ID provided, auto-select
-	if instanceID == "" {
-		selectedID, err := selectInstance(state)
-		if err != nil {
-			return err
-		}
-		instanceID = selectedID
-	}
-
-	// Get instance from state
-	instance, exists := state.Instances[instanceID]
-	if !exists {
-		return fmt.Errorf("instance %s not found in local state", instanceID)
+	// Resolve the instance by name, ID or prefix, or pick one
+	instance, err := cli.ResolveInstance(state, instanceRef)
+	if err != nil {
+		return err
 	}
+	instanceID := instance.ID
 
 	// Check if tunnel is already running
 	if instance.TunnelPID > 0 {
@@ -101,15 +94,15 @@ func runConnect(instanceID string, localPort int) error {
 
 	if useSSM {
 		// Use Session Manager port forwarding
-		return setupSSMPortForwarding(instanceID, localPort, instance, state)
+		return setupSSMPortForwarding(instanceID, localPort, instance)
 	}
 
 	// Setup SSH tunnel
-	return setupSSHTunnel(instanceID, localPort, publicIP, instance, state)
+	return setupSSHTunnel(instanceID, localPort, publicIP, instance)
 }
 
 // setupSSHTunnel sets up an SSH tunnel to the instance
-func setupSSHTunnel(instanceID string, localPort int, publicIP string, instance *config.Instance, state *config.LocalState) error {
+func setupSSHTunnel(instanceID string, localPort int, publicIP string, instance *config.Instance) error {
 	fmt.Printf("Setting up SSH tunnel to %s...\n", instanceID)
 
 	keyStorage, err := config.DefaultKeyStorage()
@@ -148,7 +141,7 @@ func setupSSHTunnel(instanceID string, localPort int, publicIP string, instance
 
 	// Save tunnel PID
 	instance.TunnelPID = cmd.Process.Pid
-	if err := state.Save(); err != nil {
+	if err := saveTunnelPID(instanceID, instance.TunnelPID); err != nil {
 		fmt.Printf("Warning: Failed to save tunnel PID: %v\n", err)
 	}
 
@@ -161,7 +154,7 @@ func setupSSHTunnel(instanceID string, localPort int, publicIP string, instance
 }
 
 // setupSSMPortForwarding sets up Session Manager port forwarding
-func setupSSMPortForwarding(instanceID string, localPort int, instance *config.Instance, state *config.LocalState) error {
+func setupSSMPortForwarding(instanceID string, localPort int, instance *config.Instance) error {
 	fmt.Printf("Setting up Session Manager port forwarding to %s...\n", instanceID)
 
 	// Check if AWS CLI and Session Manager plugin are installed
@@ -182,7 +175,7 @@ func setupSSMPortForwarding(instanceID string, localPort int, instance *config.I
 
 	// Save tunnel PID
 	instance.TunnelPID = cmd.Process.Pid
-	if err := state.Save(); err != nil {
+	if err := saveTunnelPID(instanceID, instance.TunnelPID); err != nil {
 		fmt.Printf("Warning: Failed to save tunnel PID: %v\n", err)
 	}
 
@@ -194,32 +187,10 @@ func setupSSMPortForwarding(instanceID string, localPort int, instance *config.I
 	return nil
 }
 
-// selectInstance automatically selects an instance when none is specified
-func selectInstance(state *config.LocalState) (string, error) {
-	if len(state.Instances) == 0 {
-		return "", fmt.Errorf("no instances found. Launch an instance first with 'lens-vscode launch'")
-	}
-
-	if len(state.Instances) == 1 {
-		// Only one instance, auto-select it
-		for id := range state.Instances {
-			fmt.Printf("Auto-selecting instance: %s\n", id)
-			return id, nil
-		}
-	}
-
-	// Multiple instances, show list and prompt
-	fmt.Println("Multiple instances found:")
-	fmt.Println()
-	for id, inst := range state.Instances {
-		fmt.Printf("  %s\n", id)
-		fmt.Printf("    Environment: %s\n", inst.Environment)
-		fmt.Printf("    Type: %s\n", inst.InstanceType)
-		if inst.PublicIP != "" {
-			fmt.Printf("    Public IP: %s\n", inst.PublicIP)
-		}
-		fmt.Printf("    Launched: %s\n", inst.LaunchedAt.Format("2006-01-02 15:04:05"))
-		fmt.Println()
-	}
-	return "", fmt.Errorf("please specify which instance to connect to: lens-vscode connect INSTANCE_ID")
+// saveTunnelPID records the PID of the tunnel process for an instance
+func saveTunnelPID(instanceID string, pid int) error {
+	return config.UpdateInstance(instanceID, func(instance *config.Instance) error {
+		instance.TunnelPID = pid
+		return nil
+	})
 }
@@ -1,9 +1,11 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"sort"
 
+	"github.com/scttfrdmn/lens/pkg/cli"
 	"github.com/scttfrdmn/lens/pkg/config"
 	"github.com/scttfrdmn/lens/pkg/cost"
 	"github.com/spf13/cobra"
@@ -12,9 +14,11 @@ import (
 // NewCostsCmd creates the costs command for viewing cost information
 func NewCostsCmd() *cobra.Command {
 	var showDetails bool
+	var profile string
+	var local bool
 
 	cmd := &cobra.Command{
-		Use:   "costs [INSTANCE_ID]",
+		Use:   "costs [INSTANCE]",
 		Short: "Show cost information for instances",
 		Long: `Display cost breakdown for running instances.
 
@@ -22,23 +26,35 @@ Shows both actual running costs and effective costs (total/elapsed time).
 The effective cost demonstrates the true cost savings of cloud infrastructure
 by factoring in stop/start cycles.
 
-Without INSTANCE_ID, shows costs for all instances.
-With INSTANCE_ID, shows detailed breakdown for that instance.`,
+Also includes what lens resources cost beyond instance hours and root
+volumes: NAT Gateways (shared by the instances behind them), Elastic IPs
+(including idle ones), public IPv4 addresses, AMI snapshots, data volumes
+and their snapshots, and data transfer measured by CloudWatch. Use --local to skip these AWS lookups.
+
+Without INSTANCE, shows costs for all instances.
+With INSTANCE (a name, ID or unambiguous prefix), shows detailed breakdown for that instance.
+Use "costs report" to export costs by month for grant or expenditure reports,
+and "costs reconcile" to compare estimates with billed costs from Cost Explorer.`,
 		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if len(args) == 1 {
-				return runCostsDetail(args[0])
+				return runCostsDetail(args[0], profile, local)
 			}
-			return runCostsAll(showDetails)
+			return runCostsAll(showDetails, profile, local)
 		},
 	}
 
 	cmd.Flags().BoolVarP(&showDetails, "details", "d", false, "Show detailed breakdown for each instance")
+	cmd.Flags().StringVarP(&profile, "profile", "p", "default", "AWS profile to use")
+	cmd.Flags().BoolVar(&local, "local", false, "Only use local state, skipping NAT Gateways, Elastic IPs, snapshots and data transfer")
+
+	cmd.AddCommand(cli.NewCostsReportCmd("lens-vscode"))
+	cmd.AddCommand(cli.NewCostsReconcileCmd("lens-vscode"))
 
 	return cmd
 }
 
-func runCostsAll(showDetails bool) error {
+func runCostsAll(showDetails bool, profile string, local bool) error {
 	state, err := config.LoadState()
 	if err != nil {
 		return fmt.Errorf("failed to load state: %w", err)
@@ -50,13 +66,25 @@ func runCostsAll(showDetails bool) error {
 	}
 
 	// Load config to check if cost tracking is enabled
-	cfg, _ := config.LoadUserConfig()
+	cfg, _ := config.LoadConfig()
 	if cfg != nil && !cfg.EnableCostTracking {
 		fmt.Println("Cost tracking is disabled in config")
 		fmt.Println("Enable with: lens-vscode config set enable_cost_tracking true")
 		return nil
 	}
 
+	// Resources billed beyond instance hours and root volumes
+	var resources []cost.ResourceCost
+	tracked := make(map[string]bool)
+	if !local {
+		instances := make([]*config.Instance, 0, len(state.Instances))
+		for _, instance := range state.Instances {
+			instances = append(instances, instance)
+			tracked[instance.ID] = true
+		}
+		resources = cli.LoadResourceCosts(context.Background(), profile, instances)
+	}
+
 	fmt.Println("Instance Costs Summary")
 	fmt.Println("======================")
 	fmt.Println()
@@ -70,11 +98,13 @@ func runCostsAll(showDetails bool) error {
 		costStateChanges := convertToCostStateChanges(instance.StateChanges)
 
 		calc := cost.CalculateCost(
-			instance.InstanceType,
+			cost.PriceQuery{InstanceType: instance.InstanceType, Region: instance.Region},
 			instance.LaunchedAt,
 			costStateChanges,
 			instance.EBSSize,
 		)
+		calc.AddResources(instance.ID, resources)
+		cli.ApplySchedule(calc, instance)
 
 		calculations = append(calculations, &instanceCostInfo{
 			instance: instance,
@@ -95,17 +125,26 @@ func runCostsAll(showDetails bool) error {
 	for _, info := range calculations {
 		fmt.Printf("Instance: %s (%s)\n", info.instance.ID, info.instance.Environment)
 		fmt.Printf("  Type: %s\n", info.instance.InstanceType)
+		if note := info.calc.Price.Note(); note != "" {
+			fmt.Printf("  Price: %s\n", note)
+		}
 		fmt.Printf("  Running: %s / %s (%.0f%% utilization)\n",
 			cost.FormatHours(info.calc.TotalRunningHours),
 			cost.FormatHours(info.calc.TotalElapsedHours),
 			info.calc.GetUtilizationPercentage())
 		fmt.Printf("  Total Cost: %s\n", cost.FormatCostShort(info.calc.TotalCost))
 		fmt.Printf("  Effective Rate: %s/hour\n", cost.FormatCost(info.calc.EffectiveCostPerHour))
+		if len(info.calc.Resources) > 0 {
+			fmt.Printf("  Resources: %s/month (%s)\n",
+				cost.FormatCostShort(info.calc.ResourcesMonthly()),
+				cli.ResourceKinds(info.calc.Resources))
+		}
 
 		if showDetails {
 			fmt.Printf("    Compute: %s  Storage: %s\n",
 				cost.FormatCostShort(info.calc.ComputeCost),
 				cost.FormatCostShort(info.calc.StorageCost))
+			cli.PrintResourceCosts("    ", info.calc.Resources)
 			fmt.Printf("    %s\n", info.calc.OnPremComparison)
 		}
 		fmt.Println()
@@ -123,14 +162,31 @@ func runCostsAll(showDetails bool) error {
 		fmt.Printf("Effective Rate: %s/hour\n", cost.FormatCost(effectiveRate))
 	}
 
+	// Resources no tracked instance uses are charged to the account
+	unattributed := cli.UnattributedResources(resources, tracked)
+	if len(resources) > 0 {
+		fmt.Println()
+		fmt.Println("Account Resources")
+		fmt.Println("-----------------")
+		cli.PrintResourceCosts("", resources)
+		if len(unattributed) > 0 {
+			fmt.Printf("Not used by any instance: %s/month\n",
+				cost.FormatCostShort(cost.SumMonthly(unattributed)))
+		}
+	}
+
 	// Monthly estimate
 	if len(calculations) > 0 {
-		var monthlyEstimate float64
+		monthlyEstimate := cost.SumMonthly(unattributed)
+		basis := "current usage pattern"
 		for _, info := range calculations {
 			monthlyEstimate += info.calc.EstimateMonthly()
+			if info.calc.Scheduled {
+				basis = "schedules and current usage pattern"
+			}
 		}
-		fmt.Printf("\nEstimated Monthly: %s (based on current usage pattern)\n",
-			cost.FormatCostShort(monthlyEstimate))
+		fmt.Printf("\nEstimated Monthly: %s (based on %s)\n",
+			cost.FormatCostShort(monthlyEstimate), basis)
 
 		if cfg != nil && cfg.CostAlertThreshold > 0 {
 			if monthlyEstimate > cfg.CostAlertThreshold {
@@ -142,26 +198,33 @@ func runCostsAll(showDetails bool) error {
 	return nil
 }
 
-func runCostsDetail(instanceID string) error {
+func runCostsDetail(instanceRef, profile string, local bool) error {
 	state, err := config.LoadState()
 	if err != nil {
 		return fmt.Errorf("failed to load state: %w", err)
 	}
 
-	instance, exists := state.Instances[instanceID]
-	if !exists {
-		return fmt.Errorf("instance %s not found in local state", instanceID)
+	// Resolve the instance by name, ID or prefix
+	instance, err := cli.ResolveInstance(state, instanceRef)
+	if err != nil {
+		return err
 	}
+	instanceID := instance.ID
 
 	// Convert state changes
 	costStateChanges := convertToCostStateChanges(instance.StateChanges)
 
 	calc := cost.CalculateCost(
-		instance.InstanceType,
+		cost.PriceQuery{InstanceType: instance.InstanceType, Region: instance.Region},
 		instance.LaunchedAt,
 		costStateChanges,
 		instance.EBSSize,
 	)
+	if !local {
+		resources := cli.LoadResourceCosts(context.Background(), profile, []*config.Instance{instance})
+		calc.AddResources(instanceID, resources)
+	}
+	cli.ApplySchedule(calc, instance)
 
 	fmt.Printf("Cost Breakdown for %s\n", instanceID)
 	fmt.Println("================================")
@@ -189,36 +252,53 @@ func runCostsDetail(instanceID string) error {
 	fmt.Println("Cost Breakdown:")
 	fmt.Printf("  Compute:      %s (%s/hour × %.1f hours)\n",
 		cost.FormatCostShort(calc.ComputeCost),
-		cost.FormatCost(cost.InstancePricing[instance.InstanceType]),
+		cost.FormatCost(calc.HourlyRate),
 		calc.TotalRunningHours)
 	fmt.Printf("  Storage:      %s (%d GB × $%.4f/GB-month)\n",
 		cost.FormatCostShort(calc.StorageCost),
 		instance.EBSSize,
 		cost.EBSPricePerGBMonth)
 	fmt.Printf("  Total:        %s\n", cost.FormatCostShort(calc.TotalCost))
+	if note := calc.Price.Note(); note != "" {
+		fmt.Printf("  Note:         %s price is %s\n", instance.InstanceType, note)
+	}
 	fmt.Println()
 
+	// Resources
+	if len(calc.Resources) > 0 {
+		fmt.Println("Resources (monthly share):")
+		cli.PrintResourceCosts("  ", calc.Resources)
+		fmt.Printf("  Total:        %s/month\n", cost.FormatCostShort(calc.ResourcesMonthly()))
+		fmt.Println()
+	}
+
 	// Key metrics
 	fmt.Println("Key Metrics:")
-	fmt.Printf("  Cost per Running Hour:  %s\n",
-		cost.FormatCost(cost.InstancePricing[instance.InstanceType]))
+	fmt.Printf("  Cost per Running Hour:  %s\n", calc.Price.FormatHourly())
 	fmt.Printf("  Effective Cost per Hour: %s\n", cost.FormatCost(calc.EffectiveCostPerHour))
-	savings := cost.InstancePricing[instance.InstanceType] - calc.EffectiveCostPerHour
-	savingsPercent := (savings / cost.InstancePricing[instance.InstanceType]) * 100
-	fmt.Printf("  Savings vs 24/7:         %s/hour (%.0f%%)\n",
-		cost.FormatCost(savings), savingsPercent)
+	if calc.Price.Known() {
+		savings := calc.HourlyRate - calc.EffectiveCostPerHour
+		savingsPercent := (savings / calc.HourlyRate) * 100
+		fmt.Printf("  Savings vs 24/7:         %s/hour (%.0f%%)\n",
+			cost.FormatCost(savings), savingsPercent)
+	}
 	fmt.Println()
 
 	// Monthly estimates
 	fmt.Println("Projections:")
 	monthlyEstimate := calc.EstimateMonthly()
-	fmt.Printf("  Est. Monthly Cost:       %s (at current usage)\n",
-		cost.FormatCostShort(monthlyEstimate))
+	usage := "at current usage"
+	if calc.Scheduled {
+		usage = fmt.Sprintf("on schedule, %.0fh a week", calc.ScheduledHoursPerWeek)
+	}
+	fmt.Printf("  Est. Monthly Cost:       %s (%s)\n",
+		cost.FormatCostShort(monthlyEstimate), usage)
 
 	// 24/7 comparison
 	hoursPerMonth := 24.0 * 30.0
-	cost247 := (cost.InstancePricing[instance.InstanceType] * hoursPerMonth) +
-		(float64(instance.EBSSize) * cost.EBSPricePerGBMonth)
+	cost247 := (calc.HourlyRate * hoursPerMonth) +
+		(float64(instance.EBSSize) * cost.EBSPricePerGBMonth) +
+		calc.ResourcesMonthly()
 	fmt.Printf("  24/7 Monthly Cost:       %s\n", cost.FormatCostShort(cost247))
 	monthlySavings := cost247 - monthlyEstimate
 	fmt.Printf("  Monthly Savings:         %s (%.0f%%)\n",
@@ -235,9 +315,16 @@ func runCostsDetail(instanceID string) error {
 	if len(instance.StateChanges) > 0 {
 		fmt.Println("State Change History:")
 		for _, change := range instance.StateChanges {
-			fmt.Printf("  %s → %s\n",
+			origin := ""
+			if change.Source != "" {
+				origin = " (" + change.Origin() + ")"
+			}
+			if change.ResizedFrom != "" {
+				origin += " (resized from " + change.ResizedFrom + ")"
+			}
+			fmt.Printf("  %s → %s%s\n",
 				change.Timestamp.Format("2006-01-02 15:04:05"),
-				change.State)
+				change.State, origin)
 		}
 		fmt.Println()
 	}
@@ -254,8 +341,9 @@ func convertToCostStateChanges(stateChanges []config.StateChange) []cost.StateCh
 	result := make([]cost.StateChange, len(stateChanges))
 	for i, sc := range stateChanges {
 		result[i] = cost.StateChange{
-			State:     sc.State,
-			Timestamp: sc.Timestamp,
+			State:       sc.State,
+			Timestamp:   sc.Timestamp,
+			ResizedFrom: sc.ResizedFrom,
 		}
 	}
 	return result
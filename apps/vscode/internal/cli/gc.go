@@ -0,0 +1,11 @@
+package cli
+
+import (
+	"github.com/scttfrdmn/lens/pkg/cli"
+	"github.com/spf13/cobra"
+)
+
+// NewGCCmd creates the gc command for removing orphaned lens resources
+func NewGCCmd() *cobra.Command {
+	return cli.NewGCCmd("lens-vscode")
+}
@@ -0,0 +1,228 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/lens/pkg/agent"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/fakecloud"
+	"github.com/scttfrdmn/lens/pkg/transaction"
+)
+
+const testEnvironmentYAML = `name: "test"
+instance_type: "t4g.medium"
+ami_base: "ubuntu24-arm64"
+ebs_volume_size: 20
+packages:
+  - git
+`
+
+// setupFakeCloud isolates HOME, installs a test environment and routes all
+// AWS calls to an in-memory cloud
+func setupFakeCloud(t *testing.T) *fakecloud.Cloud {
+	t.Helper()
+
+	t.Setenv("HOME", t.TempDir())
+
+	envDir := filepath.Join(config.GetConfigDir(), "environments")
+	if err := os.MkdirAll(envDir, 0755); err != nil {
+		t.Fatalf("Failed to create environments dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(envDir, "test.yaml"), []byte(testEnvironmentYAML), 0644); err != nil {
+		t.Fatalf("Failed to write environment: %v", err)
+	}
+
+	previous := readinessPollInterval
+	readinessPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { readinessPollInterval = previous })
+
+	// Launches install a pinned lens-agent release, as make builds do
+	version := agent.Version
+	agent.Version = "1.0.0"
+	t.Cleanup(func() { agent.Version = version })
+
+	return fakecloud.Install(t)
+}
+
+// launchedInstance returns the single instance recorded in local state
+func launchedInstance(t *testing.T) *config.Instance {
+	t.Helper()
+
+	state, err := config.LoadState()
+	if err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	if len(state.Instances) != 1 {
+		t.Fatalf("Expected 1 instance in state, got %d", len(state.Instances))
+	}
+	for _, instance := range state.Instances {
+		return instance
+	}
+	return nil
+}
+
+func assertCloudState(t *testing.T, cloud *fakecloud.Cloud, instanceID string, want types.InstanceStateName) {
+	t.Helper()
+
+	inst, ok := cloud.Instance(instanceID)
+	if !ok {
+		t.Fatalf("Instance %s not found in fake cloud", instanceID)
+	}
+	if inst.State.Name != want {
+		t.Errorf("Expected instance %s to be %s, got %s", instanceID, want, inst.State.Name)
+	}
+}
+
+func TestLifecycle_LaunchStopStartTerminate(t *testing.T) {
+	cloud := setupFakeCloud(t)
+
+	err := runLaunch("test", "", "", "", "4h", "default", "", "", false, "session-manager", "public", false, false, "", "", "", "", false, "", false, "", false, false, "")
+	if err != nil {
+		t.Fatalf("runLaunch failed: %v", err)
+	}
+
+	instance := launchedInstance(t)
+	assertCloudState(t, cloud, instance.ID, types.InstanceStateNameRunning)
+
+	if instance.Environment != "test" || instance.InstanceType != "t4g.medium" {
+		t.Errorf("Unexpected instance in state: %+v", instance)
+	}
+	if instance.Region != fakecloud.DefaultRegion {
+		t.Errorf("Expected region %s, got %s", fakecloud.DefaultRegion, instance.Region)
+	}
+
+	inst, _ := cloud.Instance(instance.ID)
+	if inst.IamInstanceProfile == nil {
+		t.Error("Expected instance to be launched with an instance profile")
+	}
+	if cloud.UserData(instance.ID) == "" {
+		t.Error("Expected instance to be launched with user data")
+	}
+
+	if err := runStop(instance.ID, false); err != nil {
+		t.Fatalf("runStop failed: %v", err)
+	}
+	assertCloudState(t, cloud, instance.ID, types.InstanceStateNameStopping)
+
+	if err := runStart(instance.ID); err != nil {
+		t.Fatalf("runStart failed: %v", err)
+	}
+	assertCloudState(t, cloud, instance.ID, types.InstanceStateNameRunning)
+
+	if err := runTerminate(instance.ID, true); err != nil {
+		t.Fatalf("runTerminate failed: %v", err)
+	}
+	assertCloudState(t, cloud, instance.ID, types.InstanceStateNameShuttingDown)
+
+	state, err := config.LoadState()
+	if err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	if len(state.Instances) != 0 {
+		t.Errorf("Expected terminated instance to be removed from state, got %d instances", len(state.Instances))
+	}
+}
+
+func TestLaunch_SSHCreatesAndSavesKeyPair(t *testing.T) {
+	cloud := setupFakeCloud(t)
+
+	err := runLaunch("test", "", "", "", "4h", "default", "", "", false, "ssh", "public", false, false, "", "", "", "", false, "", false, "", false, false, "")
+	if err != nil {
+		t.Fatalf("runLaunch failed: %v", err)
+	}
+
+	instance := launchedInstance(t)
+	if instance.KeyPair == "" {
+		t.Fatal("Expected key pair to be recorded in state")
+	}
+	if instance.PublicIP == "" {
+		t.Error("Expected public IP to be recorded for a public subnet launch")
+	}
+
+	keys := cloud.KeyPairs(fakecloud.DefaultRegion)
+	if len(keys) != 1 || *keys[0].KeyName != instance.KeyPair {
+		t.Errorf("Expected key pair %s in the cloud, got %+v", instance.KeyPair, keys)
+	}
+
+	keyStorage, err := config.DefaultKeyStorage()
+	if err != nil {
+		t.Fatalf("Failed to open key storage: %v", err)
+	}
+	if !keyStorage.HasPrivateKey(instance.KeyPair) {
+		t.Error("Expected private key to be saved locally")
+	}
+}
+
+func TestLaunch_RunInstancesFailureRollsBack(t *testing.T) {
+	cloud := setupFakeCloud(t)
+	cloud.FailNext("RunInstances", fakecloud.APIError("InsufficientInstanceCapacity", "We currently do not have sufficient capacity"))
+
+	err := runLaunch("test", "", "", "", "4h", "default", "", "", false, "ssh", "public", false, false, "", "", "", "", false, "", false, "", false, false, "")
+	if err == nil {
+		t.Fatal("Expected launch to fail")
+	}
+	if !strings.Contains(err.Error(), "InsufficientInstanceCapacity") {
+		t.Errorf("Expected capacity error, got: %v", err)
+	}
+
+	state, err := config.LoadState()
+	if err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	if len(state.Instances) != 0 {
+		t.Errorf("Expected no instances in state after failed launch, got %d", len(state.Instances))
+	}
+
+	// Everything the launch created is rolled back
+	if keys := cloud.KeyPairs(fakecloud.DefaultRegion); len(keys) != 0 {
+		t.Errorf("Expected key pair to be rolled back, got %d key pairs", len(keys))
+	}
+	if groups := cloud.SecurityGroups(fakecloud.DefaultRegion); len(groups) != 1 {
+		t.Errorf("Expected only the default security group to remain, got %d", len(groups))
+	}
+	if _, ok := cloud.Role(appName + "-session-manager-role"); ok {
+		t.Error("Expected IAM role to be rolled back")
+	}
+	if _, ok := cloud.InstanceProfile(appName + "-session-manager-profile"); ok {
+		t.Error("Expected instance profile to be rolled back")
+	}
+	assertNoLaunchJournal(t)
+}
+
+func TestLaunch_KeepOnFailure(t *testing.T) {
+	cloud := setupFakeCloud(t)
+	// Fail the wait for the instance to start, after it has been created
+	cloud.FailNext("DescribeInstances", fakecloud.APIError("InternalError", "An internal error has occurred"))
+
+	err := runLaunch("test", "", "", "", "4h", "default", "", "", false, "ssh", "public", false, false, "", "", "", "", true, "", false, "", false, false, "")
+	if err == nil {
+		t.Fatal("Expected launch to fail")
+	}
+
+	instance := launchedInstance(t)
+	assertCloudState(t, cloud, instance.ID, types.InstanceStateNameRunning)
+	if len(cloud.KeyPairs(fakecloud.DefaultRegion)) != 1 {
+		t.Error("Expected key pair to be kept")
+	}
+	if _, ok := cloud.Role(appName + "-session-manager-role"); !ok {
+		t.Error("Expected IAM role to be kept")
+	}
+	assertNoLaunchJournal(t)
+}
+
+func assertNoLaunchJournal(t *testing.T) {
+	t.Helper()
+
+	entries, err := os.ReadDir(transaction.GetJournalDir())
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("Failed to read journal directory: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected launch journal to be removed, found %d entries", len(entries))
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/cli"
 	"github.com/scttfrdmn/lens/pkg/config"
 	"github.com/spf13/cobra"
 )
@@ -12,18 +13,22 @@ import (
 // NewStartCmd creates the start command for starting stopped instances
 func NewStartCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "start INSTANCE_ID",
+		Use:   "start [INSTANCE]",
 		Short: "Start a stopped VSCode Server instance",
-		Args:  cobra.ExactArgs(1),
+		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runStart(args[0])
+			instanceRef := ""
+			if len(args) > 0 {
+				instanceRef = args[0]
+			}
+			return runStart(instanceRef)
 		},
 	}
 
 	return cmd
 }
 
-func runStart(instanceID string) error {
+func runStart(instanceRef string) error {
 	ctx := context.Background()
 
 	// Load state to get instance details
@@ -32,11 +37,12 @@ func runStart(instanceID string) error {
 		return fmt.Errorf("failed to load state: %w", err)
 	}
 
-	// Get instance from state
-	instance, exists := state.Instances[instanceID]
-	if !exists {
-		return fmt.Errorf("instance %s not found in local state", instanceID)
+	// Resolve the instance by name, ID or prefix
+	instance, err := cli.ResolveInstance(state, instanceRef)
+	if err != nil {
+		return err
 	}
+	instanceID := instance.ID
 
 	// Create AWS client for the instance's region
 	ec2Client, err := aws.NewEC2ClientForRegion(ctx, instance.Region)
@@ -63,6 +69,12 @@ func runStart(instanceID string) error {
 		return fmt.Errorf("instance is in state '%s', can only start instances in 'stopped' state", stateName)
 	}
 
+	// Change to a type queued by recommend --apply, however the instance
+	// was stopped
+	if err := cli.ApplyPendingType(ctx, ec2Client, instance); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
 	// Start the instance
 	fmt.Printf("Starting instance %s...\n", instanceID)
 
@@ -84,8 +96,11 @@ func runStart(instanceID string) error {
 
 	// Update state with new public IP
 	if instanceInfo.PublicIpAddress != nil {
-		instance.PublicIP = *instanceInfo.PublicIpAddress
-		if err := state.Save(); err != nil {
+		err := config.UpdateInstance(instanceID, func(instance *config.Instance) error {
+			instance.PublicIP = *instanceInfo.PublicIpAddress
+			return nil
+		})
+		if err != nil {
 			fmt.Printf("Warning: Failed to update state: %v\n", err)
 		}
 	}
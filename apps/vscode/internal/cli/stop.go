@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/cli"
 	"github.com/scttfrdmn/lens/pkg/config"
 	"github.com/spf13/cobra"
 )
@@ -14,11 +15,15 @@ func NewStopCmd() *cobra.Command {
 	var hibernate bool
 
 	cmd := &cobra.Command{
-		Use:   "stop INSTANCE_ID",
+		Use:   "stop [INSTANCE]",
 		Short: "Stop a VSCode Server instance",
-		Args:  cobra.ExactArgs(1),
+		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runStop(args[0], hibernate)
+			instanceRef := ""
+			if len(args) > 0 {
+				instanceRef = args[0]
+			}
+			return runStop(instanceRef, hibernate)
 		},
 	}
 
@@ -26,7 +31,7 @@ func NewStopCmd() *cobra.Command {
 	return cmd
 }
 
-func runStop(instanceID string, hibernate bool) error {
+func runStop(instanceRef string, hibernate bool) error {
 	ctx := context.Background()
 
 	// Load state to get instance details
@@ -35,11 +40,12 @@ func runStop(instanceID string, hibernate bool) error {
 		return fmt.Errorf("failed to load state: %w", err)
 	}
 
-	// Get instance from state
-	instance, exists := state.Instances[instanceID]
-	if !exists {
-		return fmt.Errorf("instance %s not found in local state", instanceID)
+	// Resolve the instance by name, ID or prefix
+	instance, err := cli.ResolveInstance(state, instanceRef)
+	if err != nil {
+		return err
 	}
+	instanceID := instance.ID
 
 	// Create AWS client for the instance's region
 	ec2Client, err := aws.NewEC2ClientForRegion(ctx, instance.Region)
@@ -64,13 +70,23 @@ func runStop(instanceID string, hibernate bool) error {
 			fmt.Printf("Warning: Failed to kill tunnel (PID %d): %v\n", instance.TunnelPID, err)
 		} else {
 			fmt.Printf("Tunnel (PID %d) stopped\n", instance.TunnelPID)
-			instance.TunnelPID = 0
-			if err := state.Save(); err != nil {
+			err := config.UpdateInstance(instanceID, func(instance *config.Instance) error {
+				instance.TunnelPID = 0
+				return nil
+			})
+			if err != nil {
 				fmt.Printf("Warning: Failed to update state: %v\n", err)
 			}
 		}
 	}
 
+	// Change the instance type queued by recommend --apply
+	if hibernate && instance.PendingType != "" {
+		fmt.Printf("Note: The change to %s is applied on the next stop without --hibernate\n", instance.PendingType)
+	} else if err := cli.ApplyPendingType(ctx, ec2Client, instance); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
 	fmt.Printf("Instance %s stopped successfully\n", instanceID)
 	return nil
 }
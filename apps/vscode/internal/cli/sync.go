@@ -0,0 +1,11 @@
+package cli
+
+import (
+	"github.com/scttfrdmn/lens/pkg/cli"
+	"github.com/spf13/cobra"
+)
+
+// NewSyncCmd creates the sync command for rebuilding local state from AWS
+func NewSyncCmd() *cobra.Command {
+	return cli.NewSyncCmd("lens-vscode")
+}
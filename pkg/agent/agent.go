@@ -0,0 +1,323 @@
+// Package agent implements lens-agent, which runs on every lens instance,
+// watches pluggable activity signals and stops the instance once it has been
+// idle for longer than its idle timeout
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Action is what the agent does to an instance that reaches its idle timeout
+type Action string
+
+const (
+	ActionStop      Action = "stop"
+	ActionHibernate Action = "hibernate" // Falls back to stop if hibernation fails
+	ActionNone      Action = "none"      // Report idleness without acting on it
+)
+
+// ParseAction validates an --action value
+func ParseAction(value string) (Action, error) {
+	switch action := Action(value); action {
+	case ActionStop, ActionHibernate, ActionNone:
+		return action, nil
+	}
+	return "", fmt.Errorf("invalid action %q: must be stop, hibernate or none", value)
+}
+
+const (
+	// DefaultStatusFile is where the agent writes its status as JSON
+	DefaultStatusFile = "/var/lib/lens-agent/status.json"
+
+	// DefaultDisableFile turns off auto-stop while it exists
+	DefaultDisableFile = "/etc/lens-agent/disabled"
+
+	// DefaultKeepaliveFile holds the time auto-stop is postponed until
+	DefaultKeepaliveFile = "/etc/lens-agent/keepalive-until"
+
+	// DefaultWarnBefore is how long before stopping logged-in users are warned
+	DefaultWarnBefore = 15 * time.Minute
+
+	// DefaultInterval is how often the agent checks its signals
+	DefaultInterval = time.Minute
+)
+
+// Instance states reported in the status file
+const (
+	StateActive   = "active"
+	StateIdle     = "idle"
+	StateStopping = "stopping"
+)
+
+// Config configures a Monitor
+type Config struct {
+	App           string        // App the instance runs, e.g. lens-jupyter
+	IdleTimeout   time.Duration // Idle time before Action; zero never acts
+	Action        Action
+	Interval      time.Duration
+	StatusFile    string
+	DisableFile   string
+	KeepaliveFile string
+	WarnBefore    time.Duration // Warn logged-in users this long before the action
+}
+
+// Status is the JSON document the agent writes after every check
+type Status struct {
+	Version            string         `json:"version"`
+	App                string         `json:"app,omitempty"`
+	State              string         `json:"state"`
+	StartedAt          time.Time      `json:"started_at"`
+	CheckedAt          time.Time      `json:"checked_at"`
+	LastActivity       time.Time      `json:"last_activity"`
+	IdleSeconds        int64          `json:"idle_seconds"`
+	IdleTimeoutSeconds int64          `json:"idle_timeout_seconds"`
+	Action             Action         `json:"action"`
+	AutoStop           bool           `json:"auto_stop"`                 // False when disabled or the action is none
+	StopAt             *time.Time     `json:"stop_at,omitempty"`         // When the action runs if nothing happens
+	KeepaliveUntil     *time.Time     `json:"keepalive_until,omitempty"` // Auto-stop is postponed until then
+	Warning            bool           `json:"warning,omitempty"`         // StopAt is within the warning window
+	Signals            []SignalStatus `json:"signals"`
+}
+
+// SignalStatus is the result of one signal's latest check
+type SignalStatus struct {
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Monitor checks signals on an interval and stops the instance when none of
+// them has reported activity for the idle timeout
+type Monitor struct {
+	cfg       Config
+	signals   []Signal
+	stopper   Stopper
+	publisher KeepalivePublisher // Nil when the agent cannot reach CloudWatch
+	now       func() time.Time
+	warn      func(ctx context.Context, message string) error
+
+	started       time.Time
+	lastCheck     time.Time
+	lastActivity  time.Time
+	stopping      bool
+	warnedFor     time.Time // Stop time logged-in users were last warned about
+	lastPublished time.Time // When the keepalive metric was last published
+}
+
+// NewMonitor creates a monitor. The instance counts as active when the
+// monitor starts, so a fresh boot gets the full idle timeout. The publisher,
+// if any, reports keepalives to the instance's idle alarm.
+func NewMonitor(cfg Config, signals []Signal, stopper Stopper, publisher KeepalivePublisher) *Monitor {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.StatusFile == "" {
+		cfg.StatusFile = DefaultStatusFile
+	}
+	if cfg.DisableFile == "" {
+		cfg.DisableFile = DefaultDisableFile
+	}
+	if cfg.KeepaliveFile == "" {
+		cfg.KeepaliveFile = DefaultKeepaliveFile
+	}
+	if cfg.Action == "" {
+		cfg.Action = ActionStop
+	}
+	m := &Monitor{cfg: cfg, signals: signals, stopper: stopper, publisher: publisher, now: time.Now, warn: broadcast}
+	m.reset()
+	return m
+}
+
+func (m *Monitor) reset() {
+	m.started = m.now()
+	m.lastCheck = m.started
+	m.lastActivity = m.started
+}
+
+// Check runs every signal once, writes the status file, publishes any
+// keepalive, warns logged-in users when a stop is near and stops the instance
+// once it has been idle for the idle timeout and any keepalive has expired
+func (m *Monitor) Check(ctx context.Context) (*Status, error) {
+	now := m.now()
+	status := &Status{
+		Version:            ReleaseVersion(),
+		App:                m.cfg.App,
+		StartedAt:          m.started,
+		CheckedAt:          now,
+		IdleTimeoutSeconds: int64(m.cfg.IdleTimeout / time.Second),
+		Action:             m.cfg.Action,
+	}
+
+	active := false
+	for _, signal := range m.signals {
+		activity, err := signal.Check(ctx, m.lastCheck)
+		result := SignalStatus{Name: signal.Name(), Active: activity.Active && err == nil, Detail: activity.Detail}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		active = active || result.Active
+		status.Signals = append(status.Signals, result)
+	}
+	m.lastCheck = now
+	if active {
+		m.lastActivity = now
+	}
+
+	status.LastActivity = m.lastActivity
+	status.IdleSeconds = int64(now.Sub(m.lastActivity) / time.Second)
+	status.AutoStop = m.cfg.Action != ActionNone && m.cfg.IdleTimeout > 0 && !m.disabled()
+	status.State = StateIdle
+	if active {
+		status.State = StateActive
+	}
+	if keepalive, err := ReadKeepalive(m.cfg.KeepaliveFile); err != nil {
+		log.Printf("Warning: %v", err)
+	} else if keepalive.After(now) {
+		status.KeepaliveUntil = &keepalive
+		m.publishKeepalive(ctx, now)
+	}
+	if !status.AutoStop {
+		return status, WriteStatus(m.cfg.StatusFile, status)
+	}
+
+	stopAt := m.lastActivity.Add(m.cfg.IdleTimeout)
+	if status.KeepaliveUntil != nil && status.KeepaliveUntil.After(stopAt) {
+		stopAt = *status.KeepaliveUntil
+	}
+	status.StopAt = &stopAt
+	status.Warning = !m.stopping && m.cfg.WarnBefore > 0 && stopAt.Sub(now) <= m.cfg.WarnBefore
+	if status.Warning && !stopAt.Equal(m.warnedFor) && now.Before(stopAt) {
+		m.warnedFor = stopAt
+		message := fmt.Sprintf("lens: this instance has been idle since %s and will %s at %s.\n"+
+			"Run 'sudo lens-agent keepalive --for 1h' to postpone it.",
+			m.lastActivity.Format("15:04 MST"), m.cfg.Action, stopAt.Format("15:04 MST"))
+		if err := m.warn(ctx, message); err != nil {
+			log.Printf("Warning: failed to warn logged-in users: %v", err)
+		}
+	}
+
+	var actionErr error
+	if !m.stopping && !now.Before(stopAt) {
+		status.State = StateStopping
+		if err := WriteStatus(m.cfg.StatusFile, status); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		log.Printf("Idle for %s, running %s", now.Sub(m.lastActivity).Round(time.Second), m.cfg.Action)
+		if err := m.stopper.Stop(ctx, m.cfg.Action == ActionHibernate); err != nil {
+			// Try again on the next check
+			status.State = StateIdle
+			actionErr = fmt.Errorf("failed to %s instance: %w", m.cfg.Action, err)
+		} else {
+			m.stopping = true
+		}
+	}
+	if m.stopping {
+		status.State = StateStopping
+	}
+
+	if err := WriteStatus(m.cfg.StatusFile, status); err != nil {
+		return status, err
+	}
+	return status, actionErr
+}
+
+// publishKeepalive publishes the keepalive metric if it has not been in the
+// last KeepalivePublishInterval, so the idle alarm sees the keepalive too
+func (m *Monitor) publishKeepalive(ctx context.Context, now time.Time) {
+	if m.publisher == nil || now.Sub(m.lastPublished) < KeepalivePublishInterval {
+		return
+	}
+	if err := m.publisher.PublishKeepalive(ctx, now); err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	m.lastPublished = now
+}
+
+// Run checks on every interval until ctx is cancelled. A stopped instance
+// that is started again resumes with a fresh idle timeout.
+func (m *Monitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	state := ""
+	for {
+		// A gap much longer than the interval means the instance was
+		// stopped or hibernated and has been started again
+		if m.now().Sub(m.lastCheck) > 5*m.cfg.Interval {
+			log.Printf("Instance resumed")
+			m.stopping = false
+			m.reset()
+		}
+
+		status, err := m.Check(ctx)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		if status.State != state {
+			log.Printf("Instance is %s (idle %s)", status.State, time.Duration(status.IdleSeconds)*time.Second)
+			state = status.State
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// broadcast writes a message to the terminals of logged-in users
+func broadcast(ctx context.Context, message string) error {
+	_, err := runCommand(ctx, "wall", message)
+	return err
+}
+
+func (m *Monitor) disabled() bool {
+	_, err := os.Stat(m.cfg.DisableFile)
+	return err == nil
+}
+
+// WriteStatus atomically replaces the status file
+func WriteStatus(path string, status *Status) error {
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode status: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create status directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write status file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write status file: %w", err)
+	}
+	return nil
+}
+
+// ReadStatus reads a status file written by the agent
+func ReadStatus(path string) (*Status, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read status file: %w", err)
+	}
+	return ParseStatus(data)
+}
+
+// ParseStatus decodes a status document
+func ParseStatus(data []byte) (*Status, error) {
+	var status Status
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("failed to parse agent status: %w", err)
+	}
+	return &status, nil
+}
@@ -0,0 +1,325 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSignal reports whatever the test sets
+type fakeSignal struct {
+	activity Activity
+	err      error
+}
+
+func (s *fakeSignal) Name() string { return "fake" }
+
+func (s *fakeSignal) Check(ctx context.Context, since time.Time) (Activity, error) {
+	return s.activity, s.err
+}
+
+// fakeStopper records stop requests
+type fakeStopper struct {
+	stops     int
+	hibernate bool
+	err       error
+}
+
+func (s *fakeStopper) Stop(ctx context.Context, hibernate bool) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.stops++
+	s.hibernate = hibernate
+	return nil
+}
+
+type fakePublisher struct {
+	published []time.Time
+}
+
+func (p *fakePublisher) PublishKeepalive(ctx context.Context, at time.Time) error {
+	p.published = append(p.published, at)
+	return nil
+}
+
+func newTestMonitor(t *testing.T, cfg Config, signal Signal, stopper Stopper) (*Monitor, *time.Time) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg.StatusFile = filepath.Join(dir, "status.json")
+	cfg.DisableFile = filepath.Join(dir, "disabled")
+	cfg.KeepaliveFile = filepath.Join(dir, "keepalive-until")
+	now := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	m := NewMonitor(cfg, []Signal{signal}, stopper, nil)
+	m.now = func() time.Time { return now }
+	m.warn = func(ctx context.Context, message string) error { return nil }
+	m.reset()
+	return m, &now
+}
+
+func TestMonitor_StopsAfterIdleTimeout(t *testing.T) {
+	signal := &fakeSignal{activity: Activity{Active: true, Detail: "busy"}}
+	stopper := &fakeStopper{}
+	m, now := newTestMonitor(t, Config{App: "lens-jupyter", IdleTimeout: time.Hour, Action: ActionHibernate}, signal, stopper)
+	ctx := context.Background()
+
+	*now = now.Add(10 * time.Minute)
+	status, err := m.Check(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if status.State != StateActive || !status.LastActivity.Equal(*now) || status.StopAt == nil || !status.StopAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("status = %+v, want active with a stop an hour from now", status)
+	}
+	lastActivity := *now
+
+	// Idle, but not for long enough
+	signal.activity = Activity{Detail: "quiet"}
+	*now = now.Add(59 * time.Minute)
+	status, err = m.Check(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if status.State != StateIdle || status.IdleSeconds != 59*60 || stopper.stops != 0 {
+		t.Errorf("status = %+v after 59 idle minutes, stops = %d", status, stopper.stops)
+	}
+
+	// A failed stop is retried on the next check
+	stopper.err = errors.New("throttled")
+	*now = now.Add(time.Minute)
+	if _, err := m.Check(ctx); err == nil || !strings.Contains(err.Error(), "throttled") {
+		t.Errorf("expected the stop error, got %v", err)
+	}
+	stopper.err = nil
+	*now = now.Add(time.Minute)
+	status, err = m.Check(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if status.State != StateStopping || stopper.stops != 1 || !stopper.hibernate {
+		t.Errorf("status = %+v, stops = %d; want one hibernate", status, stopper.stops)
+	}
+	if _, err := m.Check(ctx); err != nil || stopper.stops != 1 {
+		t.Errorf("expected no second stop, got %d (%v)", stopper.stops, err)
+	}
+
+	written, err := ReadStatus(m.cfg.StatusFile)
+	if err != nil {
+		t.Fatalf("ReadStatus failed: %v", err)
+	}
+	if written.State != StateStopping || written.App != "lens-jupyter" || !written.LastActivity.Equal(lastActivity) ||
+		len(written.Signals) != 1 || written.Signals[0].Detail != "quiet" {
+		t.Errorf("status file = %+v", written)
+	}
+}
+
+func TestMonitor_DisabledAndErrors(t *testing.T) {
+	signal := &fakeSignal{err: errors.New("connection refused"), activity: Activity{Active: true}}
+	stopper := &fakeStopper{}
+	m, now := newTestMonitor(t, Config{IdleTimeout: time.Hour}, signal, stopper)
+	ctx := context.Background()
+
+	if err := os.WriteFile(m.cfg.DisableFile, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(2 * time.Hour)
+	status, err := m.Check(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	// A failing signal never counts as activity
+	if status.State != StateIdle || status.Signals[0].Active || status.Signals[0].Error != "connection refused" {
+		t.Errorf("status = %+v, want idle with the signal error", status)
+	}
+	if status.AutoStop || status.StopAt != nil || stopper.stops != 0 {
+		t.Errorf("auto-stop ran while disabled: %+v", status)
+	}
+
+	if err := os.Remove(m.cfg.DisableFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Check(ctx); err != nil || stopper.stops != 1 {
+		t.Errorf("expected a stop once re-enabled, got %d (%v)", stopper.stops, err)
+	}
+
+	// Action none only reports
+	m, now = newTestMonitor(t, Config{IdleTimeout: time.Hour, Action: ActionNone}, &fakeSignal{}, nil)
+	*now = now.Add(2 * time.Hour)
+	if status, err := m.Check(ctx); err != nil || status.AutoStop || status.State != StateIdle {
+		t.Errorf("Check with action none = %+v, %v", status, err)
+	}
+}
+
+func TestMonitor_KeepaliveAndWarning(t *testing.T) {
+	stopper := &fakeStopper{}
+	m, now := newTestMonitor(t, Config{IdleTimeout: time.Hour, WarnBefore: 15 * time.Minute}, &fakeSignal{}, stopper)
+	var warnings []string
+	m.warn = func(ctx context.Context, message string) error {
+		warnings = append(warnings, message)
+		return nil
+	}
+	ctx := context.Background()
+	start := *now
+
+	*now = start.Add(30 * time.Minute)
+	if status, err := m.Check(ctx); err != nil || status.Warning || len(warnings) != 0 {
+		t.Errorf("status = %+v (%v), warnings = %v; want no warning 30 minutes out", status, err, warnings)
+	}
+
+	// Users are warned once per stop time
+	for _, minutes := range []int{46, 50} {
+		*now = start.Add(time.Duration(minutes) * time.Minute)
+		if status, err := m.Check(ctx); err != nil || !status.Warning {
+			t.Errorf("status = %+v (%v), want a warning", status, err)
+		}
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "will stop at 10:00 UTC") || !strings.Contains(warnings[0], "sudo lens-agent keepalive") {
+		t.Errorf("warnings = %q", warnings)
+	}
+
+	// A keepalive postpones the stop past the idle timeout
+	until := start.Add(3 * time.Hour)
+	if err := WriteKeepalive(m.cfg.KeepaliveFile, until); err != nil {
+		t.Fatalf("WriteKeepalive failed: %v", err)
+	}
+	*now = start.Add(2 * time.Hour)
+	status, err := m.Check(ctx)
+	if err != nil || stopper.stops != 0 || status.Warning {
+		t.Fatalf("status = %+v (%v), stops = %d during a keepalive", status, err, stopper.stops)
+	}
+	if status.KeepaliveUntil == nil || !status.KeepaliveUntil.Equal(until) || !status.StopAt.Equal(until) {
+		t.Errorf("status = %+v, want a stop when the keepalive expires", status)
+	}
+
+	*now = until
+	if _, err := m.Check(ctx); err != nil || stopper.stops != 1 {
+		t.Errorf("expected a stop once the keepalive expired, got %d (%v)", stopper.stops, err)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("expected no warning at the stop itself, got %q", warnings)
+	}
+
+	// Removing a keepalive that does not exist is not an error
+	if err := WriteKeepalive(m.cfg.KeepaliveFile, time.Time{}); err != nil {
+		t.Fatalf("WriteKeepalive failed: %v", err)
+	}
+	if err := WriteKeepalive(m.cfg.KeepaliveFile, time.Time{}); err != nil {
+		t.Errorf("removing a missing keepalive failed: %v", err)
+	}
+	if keepalive, err := ReadKeepalive(m.cfg.KeepaliveFile); err != nil || !keepalive.IsZero() {
+		t.Errorf("ReadKeepalive = %v, %v after removal", keepalive, err)
+	}
+}
+
+func TestInstallScript(t *testing.T) {
+	opts := InstallOptions{
+		App:         "lens-vscode",
+		IdleTimeout: 90 * time.Minute,
+		Signals:     []string{"code-server", "cpu"},
+	}
+	defer func(version string) { Version = version }(Version)
+	Version = "1.2.0"
+	script := InstallScript(opts)
+	for _, want := range []string{
+		"ExecStart=/usr/local/bin/lens-agent run --app lens-vscode --idle-timeout 1h30m0s --action stop --signals code-server,cpu\n",
+		ReleaseURL + "/download/v1.2.0",
+		"sha256sum -c",
+		"systemctl enable --now lens-agent.service",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("install script is missing %q", want)
+		}
+	}
+
+	// A development build warns and installs the latest release, still
+	// verified against its checksums
+	Version = ""
+	if err := CheckVersion(); err == nil {
+		t.Error("expected CheckVersion to warn without a version")
+	}
+	script = InstallScript(opts)
+	for _, want := range []string{
+		ReleaseURL + "/latest",
+		"LENS_AGENT_URL=" + ReleaseURL + "/download/$LENS_AGENT_TAG\n",
+		"sha256sum -c",
+		"systemctl enable --now lens-agent.service",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("install script without a version is missing %q", want)
+		}
+	}
+}
+
+func TestModuleVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		info debug.BuildInfo
+		want string
+	}{
+		{"go install of lens-agent", debug.BuildInfo{Main: debug.Module{Path: modulePath, Version: "v1.2.0"}}, "v1.2.0"},
+		{"launcher depending on a release", debug.BuildInfo{
+			Main: debug.Module{Path: "github.com/scttfrdmn/lens/apps/jupyter", Version: "v1.2.0"},
+			Deps: []*debug.Module{{Path: modulePath, Version: "v1.1.0"}},
+		}, "v1.1.0"},
+		{"checkout", debug.BuildInfo{Main: debug.Module{Path: modulePath, Version: "(devel)"}}, ""},
+		{"untagged commit", debug.BuildInfo{Main: debug.Module{Path: modulePath, Version: "v1.2.1-0.20261017070000-0123456789ab"}}, ""},
+		{"modified checkout", debug.BuildInfo{Main: debug.Module{Path: modulePath, Version: "v1.2.0+dirty"}}, ""},
+		{"replaced with a checkout", debug.BuildInfo{
+			Main: debug.Module{Path: "github.com/scttfrdmn/lens/apps/jupyter", Version: "(devel)"},
+			Deps: []*debug.Module{{Path: modulePath, Version: "v1.1.0", Replace: &debug.Module{Path: "../../pkg"}}},
+		}, ""},
+		{"not built with this module", debug.BuildInfo{Main: debug.Module{Path: "example.com/other", Version: "v1.0.0"}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := moduleVersion(&tt.info); got != tt.want {
+				t.Errorf("moduleVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMonitor_PublishesKeepalive(t *testing.T) {
+	signal := &fakeSignal{}
+	m, now := newTestMonitor(t, Config{App: "lens-jupyter", IdleTimeout: time.Hour}, signal, &fakeStopper{})
+	publisher := &fakePublisher{}
+	m.publisher = publisher
+	ctx := context.Background()
+
+	// Nothing to publish without a keepalive
+	if _, err := m.Check(ctx); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(publisher.published) != 0 {
+		t.Fatalf("expected no keepalive metric without a keepalive, got %v", publisher.published)
+	}
+
+	until := now.Add(3 * time.Hour)
+	if err := WriteKeepalive(m.cfg.KeepaliveFile, until); err != nil {
+		t.Fatalf("WriteKeepalive failed: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		*now = now.Add(time.Minute)
+		if _, err := m.Check(ctx); err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+	}
+	// Once per KeepalivePublishInterval, starting with the first check
+	if len(publisher.published) != 2 || !publisher.published[1].Equal(publisher.published[0].Add(KeepalivePublishInterval)) {
+		t.Fatalf("expected a keepalive metric every %s, got %v", KeepalivePublishInterval, publisher.published)
+	}
+
+	// Nor after it expires
+	*now = until.Add(time.Minute)
+	if _, err := m.Check(ctx); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(publisher.published) != 2 {
+		t.Errorf("expected no keepalive metric after the keepalive, got %v", publisher.published)
+	}
+}
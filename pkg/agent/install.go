@@ -0,0 +1,150 @@
+package agent
+
+import (
+	"fmt"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Version is the lens-agent release that user data installs, set at build
+// time with -ldflags by make and the release builds. Without it the version
+// of this module the build was made from is used (see ReleaseVersion).
+var Version = ""
+
+// ReleaseURL is where lens-agent binaries are published
+const ReleaseURL = "https://github.com/scttfrdmn/lens/releases"
+
+// modulePath is the module this package belongs to, whose versions are the
+// lens release tags
+const modulePath = "github.com/scttfrdmn/lens/pkg"
+
+// pseudoVersion matches the versions Go gives untagged commits, e.g.
+// v0.0.0-20261017070000-0123456789ab
+var pseudoVersion = regexp.MustCompile(`\d{14}-[0-9a-f]{12}$`)
+
+// InstallOptions configures the agent that user data installs
+type InstallOptions struct {
+	App         string
+	IdleTimeout time.Duration
+	Action      Action
+	Signals     []string
+	DCVSession  string // Only used by the dcv signal
+}
+
+// Args returns the lens-agent run command line for the options
+func (o InstallOptions) Args() []string {
+	action := o.Action
+	if action == "" {
+		action = ActionStop
+	}
+	args := []string{"run"}
+	if o.App != "" {
+		args = append(args, "--app", o.App)
+	}
+	args = append(args,
+		"--idle-timeout", o.IdleTimeout.String(),
+		"--action", string(action),
+		"--signals", strings.Join(o.Signals, ","),
+	)
+	if o.DCVSession != "" {
+		args = append(args, "--dcv-session", o.DCVSession)
+	}
+	return args
+}
+
+// ReleaseVersion returns the lens-agent release user data installs: Version,
+// or else the version of this module recorded in the build, as with go
+// install of a tagged release. It is "" for development builds.
+func ReleaseVersion() string {
+	if Version != "" {
+		return Version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	return moduleVersion(info)
+}
+
+// moduleVersion returns the release version of this module in a build, or ""
+// if it was built from a checkout or an untagged commit
+func moduleVersion(info *debug.BuildInfo) string {
+	module := &info.Main
+	if module.Path != modulePath {
+		module = nil
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				module = dep
+				if dep.Replace != nil {
+					module = dep.Replace
+				}
+				break
+			}
+		}
+	}
+	if module == nil || module.Version == "" || module.Version == "(devel)" ||
+		strings.Contains(module.Version, "+") || pseudoVersion.MatchString(module.Version) {
+		return ""
+	}
+	return module.Version
+}
+
+// CheckVersion returns an error if this build does not know which lens-agent
+// release to install. Launches still go ahead, and user data installs the
+// latest release instead, verified against the checksums published with it.
+func CheckVersion() error {
+	if ReleaseVersion() == "" {
+		return fmt.Errorf("this build does not pin a lens-agent release, so instances install the latest; build it with 'make build VERSION=<release>' to pin one")
+	}
+	return nil
+}
+
+// InstallScript returns the user data that downloads the lens-agent release
+// ReleaseVersion, or the latest release for a development build, for the
+// instance's architecture, verifies its checksum and runs it as a systemd
+// service. A failed download leaves the instance without auto-stop rather
+// than failing the rest of the setup.
+func InstallScript(opts InstallOptions) string {
+	var sb strings.Builder
+	sb.WriteString("# Install lens-agent, which stops the instance when it is idle\n")
+	if version := ReleaseVersion(); version != "" {
+		sb.WriteString("log_progress 'Installing lens-agent'\n")
+		sb.WriteString(fmt.Sprintf("LENS_AGENT_URL=%s/download/v%s\n", ReleaseURL, strings.TrimPrefix(version, "v")))
+	} else {
+		// Resolve the latest release once, so the binary and the checksums
+		// come from the same one
+		sb.WriteString("log_progress 'Warning: no lens-agent release was pinned, installing the latest'\n")
+		sb.WriteString(fmt.Sprintf("LENS_AGENT_TAG=$(curl -fsSL --retry 5 -o /dev/null -w '%%{url_effective}' %s/latest | sed 's|.*/tag/||')\n", ReleaseURL))
+		sb.WriteString(fmt.Sprintf("LENS_AGENT_URL=%s/download/$LENS_AGENT_TAG\n", ReleaseURL))
+	}
+	sb.WriteString("LENS_AGENT=lens-agent_linux_$(dpkg --print-architecture)\n")
+	sb.WriteString("if curl -fsSL --retry 5 -o /tmp/$LENS_AGENT $LENS_AGENT_URL/$LENS_AGENT && \\\n")
+	sb.WriteString("   curl -fsSL --retry 5 -o /tmp/lens-agent-checksums.txt $LENS_AGENT_URL/checksums.txt && \\\n")
+	sb.WriteString("   (cd /tmp && grep \" $LENS_AGENT\\$\" lens-agent-checksums.txt | sha256sum -c -); then\n")
+	sb.WriteString("  install -m 0755 /tmp/$LENS_AGENT /usr/local/bin/lens-agent\n")
+	sb.WriteString("  cat > /etc/systemd/system/lens-agent.service << 'LENS_AGENT_EOF'\n")
+	sb.WriteString("[Unit]\n")
+	sb.WriteString("Description=lens idle detection agent\n")
+	sb.WriteString("After=network-online.target\n")
+	sb.WriteString("Wants=network-online.target\n")
+	sb.WriteString("\n")
+	sb.WriteString("[Service]\n")
+	sb.WriteString(fmt.Sprintf("ExecStart=/usr/local/bin/lens-agent %s\n", strings.Join(opts.Args(), " ")))
+	sb.WriteString("Restart=always\n")
+	sb.WriteString("RestartSec=10\n")
+	sb.WriteString("\n")
+	sb.WriteString("[Install]\n")
+	sb.WriteString("WantedBy=multi-user.target\n")
+	sb.WriteString("LENS_AGENT_EOF\n")
+	sb.WriteString("  systemctl daemon-reload\n")
+	sb.WriteString("  systemctl enable --now lens-agent.service\n")
+	sb.WriteString("  echo 'lens-agent installed and running'\n")
+	sb.WriteString("else\n")
+	sb.WriteString("  log_progress 'Warning: failed to install lens-agent, auto-stop is disabled'\n")
+	sb.WriteString("fi\n")
+	sb.WriteString("rm -f /tmp/$LENS_AGENT /tmp/lens-agent-checksums.txt\n\n")
+
+	return sb.String()
+}
@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// WriteKeepalive postpones auto-stop until the given time. A zero time
+// removes the keepalive.
+func WriteKeepalive(path string, until time.Time) error {
+	if until.IsZero() {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove keepalive: %w", err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create keepalive directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(until.UTC().Format(time.RFC3339)+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write keepalive: %w", err)
+	}
+	return nil
+}
+
+// ReadKeepalive returns the time auto-stop is postponed until, or the zero
+// time if there is no keepalive
+func ReadKeepalive(path string) (time.Time, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read keepalive: %w", err)
+	}
+	until, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse keepalive %s: %w", path, err)
+	}
+	return until, nil
+}
@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// The CloudWatch metric the agent publishes while a keepalive holds the
+// instance. The idle alarm created by launch --idle-alarm reads it, so it
+// does not stop the instance during the keepalive.
+const (
+	MetricNamespace = "Lens/Agent"
+	MetricKeepalive = "Keepalive"
+)
+
+// KeepalivePublishInterval is how often the agent publishes the keepalive
+// metric, once per 5-minute period of the idle alarm
+const KeepalivePublishInterval = 5 * time.Minute
+
+// KeepalivePublisher reports a keepalive on the instance the agent runs on
+type KeepalivePublisher interface {
+	PublishKeepalive(ctx context.Context, at time.Time) error
+}
+
+// CloudWatchPublisher publishes the keepalive metric through the CloudWatch
+// API using the instance profile's credentials
+type CloudWatchPublisher struct {
+	client     *cloudwatch.Client
+	instanceID string
+}
+
+// NewCloudWatchPublisher finds the instance ID and region from instance
+// metadata
+func NewCloudWatchPublisher(ctx context.Context) (*CloudWatchPublisher, error) {
+	cfg, instanceID, err := loadInstanceConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &CloudWatchPublisher{client: cloudwatch.NewFromConfig(cfg), instanceID: instanceID}, nil
+}
+
+// PublishKeepalive publishes a keepalive datapoint of 1 for the instance
+func (p *CloudWatchPublisher) PublishKeepalive(ctx context.Context, at time.Time) error {
+	_, err := p.client.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+		Namespace: aws.String(MetricNamespace),
+		MetricData: []types.MetricDatum{{
+			MetricName: aws.String(MetricKeepalive),
+			Dimensions: []types.Dimension{{Name: aws.String("InstanceId"), Value: aws.String(p.instanceID)}},
+			Timestamp:  aws.Time(at),
+			Value:      aws.Float64(1),
+			Unit:       types.StandardUnitCount,
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish keepalive metric: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// process is a running process read from /proc
+type process struct {
+	PID  int
+	Name string   // Command name from /proc/PID/comm, at most 15 characters
+	Args []string // Command line, as rewritten by processes like sshd
+	UID  int
+}
+
+// listProcesses reads the processes under root/proc. Processes that exit
+// while being read are skipped.
+func listProcesses(root string) ([]process, error) {
+	entries, err := os.ReadDir(filepath.Join(root, "proc"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+	var processes []process
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, "proc", entry.Name())
+		comm, err := os.ReadFile(filepath.Join(dir, "comm"))
+		if err != nil {
+			continue
+		}
+		p := process{PID: pid, Name: strings.TrimSpace(string(comm)), UID: -1}
+		if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+			p.Args = strings.FieldsFunc(string(cmdline), func(r rune) bool { return r == 0 })
+		}
+		if status, err := os.ReadFile(filepath.Join(dir, "status")); err == nil {
+			p.UID = statusUID(string(status))
+		}
+		processes = append(processes, p)
+	}
+	return processes, nil
+}
+
+// statusUID returns the real user ID from the contents of /proc/PID/status
+func statusUID(status string) int {
+	for _, line := range strings.Split(status, "\n") {
+		if fields := strings.Fields(line); len(fields) > 1 && fields[0] == "Uid:" {
+			if uid, err := strconv.Atoi(fields[1]); err == nil {
+				return uid
+			}
+		}
+	}
+	return -1
+}
+
+// lookupUID finds a user's ID in root/etc/passwd
+func lookupUID(root, user string) (int, bool) {
+	file, err := os.Open(filepath.Join(root, "etc", "passwd"))
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) > 2 && fields[0] == user {
+			uid, err := strconv.Atoi(fields[2])
+			return uid, err == nil
+		}
+	}
+	return 0, false
+}
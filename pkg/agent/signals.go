@@ -0,0 +1,362 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Activity is what a signal observed in one check
+type Activity struct {
+	Active bool
+	Detail string // Short human-readable summary, e.g. "2 kernels, 1 busy"
+}
+
+// Signal is one source of user activity. Check reports whether there has
+// been activity since the previous check.
+type Signal interface {
+	Name() string
+	Check(ctx context.Context, since time.Time) (Activity, error)
+}
+
+// SignalNames lists the signals NewSignals accepts
+var SignalNames = []string{"jupyter", "rstudio", "code-server", "dcv", "cpu", "gpu", "sessions", "network"}
+
+// SignalConfig holds the settings signals are created with
+type SignalConfig struct {
+	Root                string  // File system root holding proc and etc; "/" on an instance
+	JupyterURL          string  // Base URL of the Jupyter server
+	CodeServerHeartbeat string  // Heartbeat file code-server touches while a client is connected
+	DCVSession          string  // DCV session whose connections count as activity
+	CPUThreshold        float64 // CPU usage percent that counts as activity
+	GPUThreshold        float64 // GPU utilization percent that counts as activity
+	NetworkThreshold    float64 // Network throughput in KB/s that counts as activity
+}
+
+// DefaultSignalConfig returns the settings for a standard lens instance
+func DefaultSignalConfig() SignalConfig {
+	return SignalConfig{
+		Root:                "/",
+		JupyterURL:          "http://localhost:8888",
+		CodeServerHeartbeat: "/home/ubuntu/.local/share/code-server/heartbeat",
+		DCVSession:          "console",
+		CPUThreshold:        10,
+		GPUThreshold:        10,
+		NetworkThreshold:    100,
+	}
+}
+
+// NewSignals creates the named signals
+func NewSignals(names []string, cfg SignalConfig) ([]Signal, error) {
+	var signals []Signal
+	for _, name := range names {
+		switch name {
+		case "jupyter":
+			signals = append(signals, &jupyterSignal{url: strings.TrimSuffix(cfg.JupyterURL, "/"), client: &http.Client{Timeout: 10 * time.Second}})
+		case "rstudio":
+			signals = append(signals, &rstudioSignal{root: cfg.Root})
+		case "code-server":
+			signals = append(signals, &codeServerSignal{heartbeat: cfg.CodeServerHeartbeat})
+		case "dcv":
+			signals = append(signals, &dcvSignal{session: cfg.DCVSession, run: runCommand})
+		case "cpu":
+			signals = append(signals, &cpuSignal{root: cfg.Root, threshold: cfg.CPUThreshold})
+		case "gpu":
+			signals = append(signals, &gpuSignal{threshold: cfg.GPUThreshold, run: runCommand})
+		case "sessions":
+			signals = append(signals, &sessionsSignal{root: cfg.Root})
+		case "network":
+			signals = append(signals, &networkSignal{root: cfg.Root, threshold: cfg.NetworkThreshold})
+		default:
+			return nil, fmt.Errorf("unknown signal %q: must be one of %s", name, strings.Join(SignalNames, ", "))
+		}
+	}
+	return signals, nil
+}
+
+// commandRunner runs a command and returns its standard output
+type commandRunner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).Output()
+}
+
+// jupyterSignal is active while a kernel is busy or a kernel or terminal has
+// been used since the last check
+type jupyterSignal struct {
+	url    string
+	client *http.Client
+}
+
+func (s *jupyterSignal) Name() string { return "jupyter" }
+
+func (s *jupyterSignal) Check(ctx context.Context, since time.Time) (Activity, error) {
+	var kernels []struct {
+		ExecutionState string    `json:"execution_state"`
+		LastActivity   time.Time `json:"last_activity"`
+	}
+	if err := s.get(ctx, "/api/kernels", &kernels); err != nil {
+		return Activity{}, err
+	}
+	var terminals []struct {
+		LastActivity time.Time `json:"last_activity"`
+	}
+	// Terminals can be disabled in Jupyter Server, so they are best effort
+	_ = s.get(ctx, "/api/terminals", &terminals)
+
+	busy, recent := 0, 0
+	for _, kernel := range kernels {
+		if kernel.ExecutionState == "busy" {
+			busy++
+		}
+		if kernel.LastActivity.After(since) {
+			recent++
+		}
+	}
+	for _, terminal := range terminals {
+		if terminal.LastActivity.After(since) {
+			recent++
+		}
+	}
+	return Activity{
+		Active: busy > 0 || recent > 0,
+		Detail: fmt.Sprintf("%d kernels, %d busy, %d terminals", len(kernels), busy, len(terminals)),
+	}, nil
+}
+
+func (s *jupyterSignal) get(ctx context.Context, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to query Jupyter: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to query Jupyter: %s returned %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse Jupyter %s: %w", path, err)
+	}
+	return nil
+}
+
+// rstudioSignal is active while an R session is running
+type rstudioSignal struct {
+	root string
+}
+
+func (s *rstudioSignal) Name() string { return "rstudio" }
+
+func (s *rstudioSignal) Check(ctx context.Context, since time.Time) (Activity, error) {
+	processes, err := listProcesses(s.root)
+	if err != nil {
+		return Activity{}, err
+	}
+	sessions := 0
+	for _, p := range processes {
+		if p.Name == "rsession" {
+			sessions++
+		}
+	}
+	return Activity{Active: sessions > 0, Detail: fmt.Sprintf("%d R sessions", sessions)}, nil
+}
+
+// codeServerSignal is active when code-server has touched its heartbeat file,
+// which it does about once a minute while a browser is connected
+type codeServerSignal struct {
+	heartbeat string
+}
+
+func (s *codeServerSignal) Name() string { return "code-server" }
+
+func (s *codeServerSignal) Check(ctx context.Context, since time.Time) (Activity, error) {
+	info, err := os.Stat(s.heartbeat)
+	if errors.Is(err, os.ErrNotExist) {
+		return Activity{Detail: "no heartbeat yet"}, nil
+	}
+	if err != nil {
+		return Activity{}, fmt.Errorf("failed to read code-server heartbeat: %w", err)
+	}
+	return Activity{
+		Active: info.ModTime().After(since),
+		Detail: "last heartbeat " + info.ModTime().UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// dcvSignal is active while a client is connected to the DCV session
+type dcvSignal struct {
+	session string
+	run     commandRunner
+}
+
+func (s *dcvSignal) Name() string { return "dcv" }
+
+func (s *dcvSignal) Check(ctx context.Context, since time.Time) (Activity, error) {
+	output, err := s.run(ctx, "dcv", "list-connections", s.session)
+	if err != nil {
+		return Activity{}, fmt.Errorf("failed to list DCV connections: %w", err)
+	}
+	connections := 0
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.Contains(strings.ToLower(line), "no connections") {
+			connections++
+		}
+	}
+	return Activity{Active: connections > 0, Detail: fmt.Sprintf("%d connections", connections)}, nil
+}
+
+// cpuSignal is active when CPU usage since the last check is above the
+// threshold
+type cpuSignal struct {
+	root      string
+	threshold float64
+	busy      uint64
+	total     uint64
+}
+
+func (s *cpuSignal) Name() string { return "cpu" }
+
+func (s *cpuSignal) Check(ctx context.Context, since time.Time) (Activity, error) {
+	data, err := os.ReadFile(filepath.Join(s.root, "proc", "stat"))
+	if err != nil {
+		return Activity{}, fmt.Errorf("failed to read CPU usage: %w", err)
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return Activity{}, fmt.Errorf("failed to parse CPU usage: unexpected /proc/stat")
+	}
+	var busy, total uint64
+	for i, field := range fields[1:] {
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return Activity{}, fmt.Errorf("failed to parse CPU usage: %w", err)
+		}
+		total += value
+		// idle and iowait are the fourth and fifth columns
+		if i != 3 && i != 4 {
+			busy += value
+		}
+	}
+
+	first := s.total == 0
+	busyDelta, totalDelta := busy-s.busy, total-s.total
+	s.busy, s.total = busy, total
+	if first || totalDelta == 0 {
+		return Activity{Detail: "sampling"}, nil
+	}
+	usage := 100 * float64(busyDelta) / float64(totalDelta)
+	return Activity{Active: usage >= s.threshold, Detail: fmt.Sprintf("%.0f%% CPU", usage)}, nil
+}
+
+// gpuSignal is active when any NVIDIA GPU is busier than the threshold
+type gpuSignal struct {
+	threshold float64
+	run       commandRunner
+}
+
+func (s *gpuSignal) Name() string { return "gpu" }
+
+func (s *gpuSignal) Check(ctx context.Context, since time.Time) (Activity, error) {
+	output, err := s.run(ctx, "nvidia-smi", "--query-gpu=utilization.gpu", "--format=csv,noheader,nounits")
+	if errors.Is(err, exec.ErrNotFound) {
+		return Activity{Detail: "no NVIDIA GPU"}, nil
+	}
+	if err != nil {
+		return Activity{}, fmt.Errorf("failed to query GPU utilization: %w", err)
+	}
+	highest := 0.0
+	for _, line := range strings.Fields(string(output)) {
+		value, err := strconv.ParseFloat(line, 64)
+		if err != nil {
+			return Activity{}, fmt.Errorf("failed to parse GPU utilization %q", line)
+		}
+		if value > highest {
+			highest = value
+		}
+	}
+	return Activity{Active: highest >= s.threshold, Detail: fmt.Sprintf("%.0f%% GPU", highest)}, nil
+}
+
+// sessionsSignal is active while someone has an interactive SSH or Session
+// Manager shell open. Session Manager port forwarding, which connect uses for
+// tunnels, starts no shell and does not count.
+type sessionsSignal struct {
+	root string
+}
+
+func (s *sessionsSignal) Name() string { return "sessions" }
+
+func (s *sessionsSignal) Check(ctx context.Context, since time.Time) (Activity, error) {
+	processes, err := listProcesses(s.root)
+	if err != nil {
+		return Activity{}, err
+	}
+	ssmUID, hasSSMUser := lookupUID(s.root, "ssm-user")
+	ssh, ssm := 0, 0
+	for _, p := range processes {
+		switch {
+		case strings.HasPrefix(p.Name, "sshd") && strings.Contains(strings.Join(p.Args, " "), "@pts/"):
+			ssh++
+		case hasSSMUser && p.UID == ssmUID:
+			ssm++
+		}
+	}
+	return Activity{Active: ssh+ssm > 0, Detail: fmt.Sprintf("%d SSH, %d Session Manager processes", ssh, ssm)}, nil
+}
+
+// networkSignal is active when network throughput since the last check is
+// above the threshold, such as during a long download
+type networkSignal struct {
+	root      string
+	threshold float64
+	bytes     uint64
+	sampled   time.Time
+}
+
+func (s *networkSignal) Name() string { return "network" }
+
+func (s *networkSignal) Check(ctx context.Context, since time.Time) (Activity, error) {
+	data, err := os.ReadFile(filepath.Join(s.root, "proc", "net", "dev"))
+	if err != nil {
+		return Activity{}, fmt.Errorf("failed to read network usage: %w", err)
+	}
+	var total uint64
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		iface, counters, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || strings.TrimSpace(iface) == "lo" {
+			continue
+		}
+		fields := strings.Fields(counters)
+		if len(fields) < 9 {
+			continue
+		}
+		received, _ := strconv.ParseUint(fields[0], 10, 64)
+		sent, _ := strconv.ParseUint(fields[8], 10, 64)
+		total += received + sent
+	}
+
+	now := time.Now()
+	previous, sampled := s.bytes, s.sampled
+	s.bytes, s.sampled = total, now
+	if sampled.IsZero() || total < previous {
+		return Activity{Detail: "sampling"}, nil
+	}
+	rate := float64(total-previous) / 1024 / now.Sub(sampled).Seconds()
+	return Activity{Active: rate >= s.threshold, Detail: fmt.Sprintf("%.0f KB/s", rate)}, nil
+}
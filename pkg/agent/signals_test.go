@@ -0,0 +1,228 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeProc is a file system root with a fake /proc and /etc/passwd
+type fakeProc struct {
+	t    *testing.T
+	root string
+}
+
+func newFakeProc(t *testing.T) *fakeProc {
+	p := &fakeProc{t: t, root: t.TempDir()}
+	p.write("etc/passwd", "root:x:0:0::/root:/bin/bash\nssm-user:x:1001:1001::/home/ssm-user:/bin/sh\n")
+	return p
+}
+
+func (p *fakeProc) write(name, content string) {
+	p.t.Helper()
+	path := filepath.Join(p.root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		p.t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+func (p *fakeProc) process(pid int, comm string, uid int, args ...string) {
+	dir := fmt.Sprintf("proc/%d/", pid)
+	p.write(dir+"comm", comm+"\n")
+	p.write(dir+"cmdline", strings.Join(args, "\x00")+"\x00")
+	p.write(dir+"status", fmt.Sprintf("Name:\t%s\nUid:\t%d\t%d\t%d\t%d\n", comm, uid, uid, uid, uid))
+}
+
+func check(t *testing.T, signal Signal, since time.Time) Activity {
+	t.Helper()
+	activity, err := signal.Check(context.Background(), since)
+	if err != nil {
+		t.Fatalf("%s check failed: %v", signal.Name(), err)
+	}
+	return activity
+}
+
+func TestJupyterSignal(t *testing.T) {
+	since := time.Now().Add(-time.Minute)
+	kernels := fmt.Sprintf(`[{"execution_state": "idle", "last_activity": %q}]`, since.Add(-time.Hour).Format(time.RFC3339))
+	terminals := `[]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/kernels":
+			fmt.Fprint(w, kernels)
+		case "/api/terminals":
+			fmt.Fprint(w, terminals)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	signals, err := NewSignals([]string{"jupyter"}, SignalConfig{JupyterURL: server.URL + "/"})
+	if err != nil {
+		t.Fatalf("NewSignals failed: %v", err)
+	}
+	signal := signals[0]
+	if activity := check(t, signal, since); activity.Active {
+		t.Errorf("idle kernel reported active: %+v", activity)
+	}
+
+	kernels = `[{"execution_state": "busy", "last_activity": "2026-01-01T00:00:00Z"}]`
+	if activity := check(t, signal, since); !activity.Active || activity.Detail != "1 kernels, 1 busy, 0 terminals" {
+		t.Errorf("busy kernel = %+v", activity)
+	}
+
+	kernels = `[]`
+	terminals = fmt.Sprintf(`[{"name": "1", "last_activity": %q}]`, time.Now().Format(time.RFC3339))
+	if activity := check(t, signal, since); !activity.Active {
+		t.Errorf("recently used terminal reported idle: %+v", activity)
+	}
+
+	server.Close()
+	if _, err := signal.Check(context.Background(), since); err == nil {
+		t.Error("expected an error when Jupyter is down")
+	}
+}
+
+func TestProcessSignals(t *testing.T) {
+	proc := newFakeProc(t)
+	proc.process(1, "systemd", 0, "/sbin/init")
+	proc.process(812, "sshd", 0, "sshd: /usr/sbin/sshd -D [listener] 0 of 10-100 startups")
+	proc.process(2210, "ssm-session-wor", 0, "/usr/bin/ssm-session-worker", "tunnel-session")
+	proc.process(3001, "rserver", 0, "/usr/lib/rstudio-server/bin/rserver")
+	cfg := DefaultSignalConfig()
+	cfg.Root = proc.root
+	signals, err := NewSignals([]string{"rstudio", "sessions"}, cfg)
+	if err != nil {
+		t.Fatalf("NewSignals failed: %v", err)
+	}
+	rstudio, sessions := signals[0], signals[1]
+
+	// A listening sshd and a port forwarding tunnel are not sessions
+	if activity := check(t, sessions, time.Now()); activity.Active {
+		t.Errorf("sessions = %+v with no shells open", activity)
+	}
+	if activity := check(t, rstudio, time.Now()); activity.Active {
+		t.Errorf("rstudio = %+v with no R sessions", activity)
+	}
+
+	proc.process(4100, "sshd", 1000, "sshd: ubuntu@pts/0")
+	proc.process(4200, "sh", 1001, "sh")
+	proc.process(3100, "rsession", 1000, "/usr/lib/rstudio-server/bin/rsession", "-u", "ubuntu")
+	if activity := check(t, sessions, time.Now()); !activity.Active || activity.Detail != "1 SSH, 1 Session Manager processes" {
+		t.Errorf("sessions = %+v", activity)
+	}
+	if activity := check(t, rstudio, time.Now()); !activity.Active || activity.Detail != "1 R sessions" {
+		t.Errorf("rstudio = %+v", activity)
+	}
+}
+
+func TestCPUAndNetworkSignals(t *testing.T) {
+	proc := newFakeProc(t)
+	cfg := DefaultSignalConfig()
+	cfg.Root = proc.root
+	signals, err := NewSignals([]string{"cpu", "network"}, cfg)
+	if err != nil {
+		t.Fatalf("NewSignals failed: %v", err)
+	}
+	cpu, network := signals[0], signals[1]
+
+	// user nice system idle iowait irq softirq steal
+	proc.write("proc/stat", "cpu  1000 0 1000 8000 0 0 0 0\ncpu0 1000 0 1000 8000 0 0 0 0\n")
+	netdev := "Inter-|   Receive\n face |bytes    packets errs drop fifo frame compressed multicast|bytes\n" +
+		"    lo: %d 0 0 0 0 0 0 0 %d 0 0 0 0 0 0 0\n  ens5: %d 0 0 0 0 0 0 0 %d 0 0 0 0 0 0 0\n"
+	proc.write("proc/net/dev", fmt.Sprintf(netdev, 0, 0, 0, 0))
+	if activity := check(t, cpu, time.Now()); activity.Active || activity.Detail != "sampling" {
+		t.Errorf("first cpu sample = %+v", activity)
+	}
+	check(t, network, time.Now())
+
+	// 50 busy and 950 idle ticks is 5%
+	proc.write("proc/stat", "cpu  1040 0 1010 8900 50 0 0 0\n")
+	if activity := check(t, cpu, time.Now()); activity.Active || activity.Detail != "5% CPU" {
+		t.Errorf("cpu at 5%% = %+v", activity)
+	}
+	proc.write("proc/stat", "cpu  1840 0 1010 9000 150 0 0 0\n")
+	if activity := check(t, cpu, time.Now()); !activity.Active || activity.Detail != "80% CPU" {
+		t.Errorf("cpu at 80%% = %+v", activity)
+	}
+
+	// Loopback traffic does not count
+	proc.write("proc/net/dev", fmt.Sprintf(netdev, 1<<40, 1<<40, 0, 0))
+	if activity := check(t, network, time.Now()); activity.Active {
+		t.Errorf("network = %+v with only loopback traffic", activity)
+	}
+	proc.write("proc/net/dev", fmt.Sprintf(netdev, 0, 0, 1<<40, 1024))
+	if activity := check(t, network, time.Now()); !activity.Active {
+		t.Errorf("network = %+v during a large download", activity)
+	}
+}
+
+func TestCommandSignals(t *testing.T) {
+	output, runErr := "", error(nil)
+	run := func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return []byte(output), runErr
+	}
+	gpu := &gpuSignal{threshold: 10, run: run}
+	dcv := &dcvSignal{session: "console", run: run}
+
+	output = "3\n45\n"
+	if activity := check(t, gpu, time.Now()); !activity.Active || activity.Detail != "45% GPU" {
+		t.Errorf("gpu = %+v", activity)
+	}
+	output = "0\n"
+	if activity := check(t, gpu, time.Now()); activity.Active {
+		t.Errorf("idle gpu = %+v", activity)
+	}
+	runErr = &exec.Error{Name: "nvidia-smi", Err: exec.ErrNotFound}
+	if activity := check(t, gpu, time.Now()); activity.Active || activity.Detail != "no NVIDIA GPU" {
+		t.Errorf("gpu without nvidia-smi = %+v", activity)
+	}
+	runErr = nil
+
+	output = "There are no connections\n"
+	if activity := check(t, dcv, time.Now()); activity.Active {
+		t.Errorf("dcv = %+v with no connections", activity)
+	}
+	output = "Connection 1: user ubuntu from 203.0.113.7\n"
+	if activity := check(t, dcv, time.Now()); !activity.Active {
+		t.Errorf("dcv = %+v with a connection", activity)
+	}
+}
+
+func TestCodeServerSignal(t *testing.T) {
+	heartbeat := filepath.Join(t.TempDir(), "heartbeat")
+	signal := &codeServerSignal{heartbeat: heartbeat}
+	since := time.Now().Add(-time.Minute)
+
+	if activity := check(t, signal, since); activity.Active {
+		t.Errorf("code-server = %+v before any heartbeat", activity)
+	}
+	if err := os.WriteFile(heartbeat, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if activity := check(t, signal, since); !activity.Active {
+		t.Errorf("code-server = %+v after a heartbeat", activity)
+	}
+	old := since.Add(-time.Hour)
+	if err := os.Chtimes(heartbeat, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if activity := check(t, signal, since); activity.Active {
+		t.Errorf("code-server = %+v with a stale heartbeat", activity)
+	}
+
+	if _, err := NewSignals([]string{"keyboard"}, DefaultSignalConfig()); err == nil {
+		t.Error("expected an error for an unknown signal")
+	}
+}
@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+// Stopper stops the instance the agent runs on
+type Stopper interface {
+	Stop(ctx context.Context, hibernate bool) error
+}
+
+// EC2Stopper stops the instance through the EC2 API using the instance
+// profile's credentials
+type EC2Stopper struct {
+	client     *ec2.Client
+	instanceID string
+}
+
+// NewEC2Stopper finds the instance ID and region from instance metadata
+func NewEC2Stopper(ctx context.Context) (*EC2Stopper, error) {
+	cfg, instanceID, err := loadInstanceConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &EC2Stopper{client: ec2.NewFromConfig(cfg), instanceID: instanceID}, nil
+}
+
+// loadInstanceConfig loads the AWS config of the instance the agent runs on,
+// in its region, and returns it with the instance ID
+func loadInstanceConfig(ctx context.Context) (aws.Config, string, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return aws.Config{}, "", fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	document, err := imds.NewFromConfig(cfg).GetInstanceIdentityDocument(ctx, &imds.GetInstanceIdentityDocumentInput{})
+	if err != nil {
+		return aws.Config{}, "", fmt.Errorf("failed to read instance metadata: %w", err)
+	}
+	cfg.Region = document.Region
+	return cfg, document.InstanceID, nil
+}
+
+// Stop stops or hibernates the instance. Hibernation falls back to a plain
+// stop if the instance cannot hibernate.
+func (s *EC2Stopper) Stop(ctx context.Context, hibernate bool) error {
+	if hibernate {
+		_, err := s.client.StopInstances(ctx, &ec2.StopInstancesInput{
+			InstanceIds: []string{s.instanceID},
+			Hibernate:   aws.Bool(true),
+		})
+		if err == nil {
+			return nil
+		}
+		log.Printf("Warning: failed to hibernate %s, stopping instead: %v", s.instanceID, err)
+	}
+	_, err := s.client.StopInstances(ctx, &ec2.StopInstancesInput{
+		InstanceIds: []string{s.instanceID},
+	})
+	return err
+}
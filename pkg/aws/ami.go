@@ -69,7 +69,7 @@ func (a *AMISelector) findUbuntuAMI(ctx context.Context, client *EC2Client, vers
 		archType = types.ArchitectureValuesX8664
 	}
 
-	result, err := client.client.DescribeImages(ctx, &ec2.DescribeImagesInput{
+	newestAMI, err := client.latestImage(ctx, &ec2.DescribeImagesInput{
 		Filters: []types.Filter{
 			{
 				Name:   aws.String("name"),
@@ -94,19 +94,10 @@ func (a *AMISelector) findUbuntuAMI(ctx context.Context, client *EC2Client, vers
 		return "", fmt.Errorf("failed to query Ubuntu AMIs: %w", err)
 	}
 
-	if len(result.Images) == 0 {
+	if newestAMI == nil {
 		return "", fmt.Errorf("no Ubuntu %s %s AMIs found in region %s", version, arch, a.region)
 	}
 
-	// Find the most recent AMI by creation date
-	var newestAMI *types.Image
-	for i := range result.Images {
-		img := &result.Images[i]
-		if newestAMI == nil || aws.ToString(img.CreationDate) > aws.ToString(newestAMI.CreationDate) {
-			newestAMI = img
-		}
-	}
-
 	amiID := aws.ToString(newestAMI.ImageId)
 	fmt.Printf("Selected Ubuntu %s %s AMI: %s (%s)\n", version, arch, amiID, aws.ToString(newestAMI.Name))
 	return amiID, nil
@@ -125,7 +116,7 @@ func (a *AMISelector) findAmazonLinuxAMI(ctx context.Context, client *EC2Client,
 		namePattern = "amzn2-ami-hvm-*-x86_64-gp2"
 	}
 
-	result, err := client.client.DescribeImages(ctx, &ec2.DescribeImagesInput{
+	newestAMI, err := client.latestImage(ctx, &ec2.DescribeImagesInput{
 		Filters: []types.Filter{
 			{
 				Name:   aws.String("name"),
@@ -150,20 +141,29 @@ func (a *AMISelector) findAmazonLinuxAMI(ctx context.Context, client *EC2Client,
 		return "", fmt.Errorf("failed to query Amazon Linux AMIs: %w", err)
 	}
 
-	if len(result.Images) == 0 {
+	if newestAMI == nil {
 		return "", fmt.Errorf("no Amazon Linux %s %s AMIs found in region %s", version, arch, a.region)
 	}
 
-	// Find the most recent AMI
-	var newestAMI *types.Image
+	amiID := aws.ToString(newestAMI.ImageId)
+	fmt.Printf("Selected Amazon Linux %s %s AMI: %s (%s)\n", version, arch, amiID, aws.ToString(newestAMI.Name))
+	return amiID, nil
+}
+
+// latestImage returns the most recently created image matching the query, or nil if none match
+func (e *EC2Client) latestImage(ctx context.Context, input *ec2.DescribeImagesInput) (*types.Image, error) {
+	result, err := e.client.DescribeImages(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	var newest *types.Image
 	for i := range result.Images {
 		img := &result.Images[i]
-		if newestAMI == nil || aws.ToString(img.CreationDate) > aws.ToString(newestAMI.CreationDate) {
-			newestAMI = img
+		if newest == nil || aws.ToString(img.CreationDate) > aws.ToString(newest.CreationDate) {
+			newest = img
 		}
 	}
 
-	amiID := aws.ToString(newestAMI.ImageId)
-	fmt.Printf("Selected Amazon Linux %s %s AMI: %s (%s)\n", version, arch, amiID, aws.ToString(newestAMI.Name))
-	return amiID, nil
+	return newest, nil
 }
@@ -0,0 +1,183 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// BackupKind is the kind of volume a backup was taken of, stored in the
+// lens:backup tag
+type BackupKind string
+
+const (
+	BackupData BackupKind = "data" // A persistent data volume
+	BackupRoot BackupKind = "root" // The root volume of an instance
+)
+
+// BackupSource is the volume a backup is taken of
+type BackupSource struct {
+	Kind           BackupKind
+	VolumeID       string
+	Name           string // Name tag: the data volume or instance name
+	SourceInstance string // Instance the volume is attached to, if any
+	DataVolume     string // Data volume name for BackupData
+	App            string
+	Owner          string // ARN of the principal taking the backup
+}
+
+// Backup is an EBS snapshot taken by backup create or volumes snapshot. It
+// outlives the volume and instance it was taken of.
+type Backup struct {
+	ID             string
+	Region         string
+	Kind           BackupKind
+	Name           string
+	SourceInstance string
+	DataVolume     string
+	VolumeID       string
+	SizeGB         int // Size of the volume; restores need at least this much
+	State          string
+	Progress       string
+	App            string
+	Owner          string // Empty for backups taken before they were tagged with one
+	Description    string
+	CreatedAt      time.Time
+}
+
+// Source identifies what a backup is of for retention: the data volume name,
+// or the instance whose root volume was backed up
+func (b Backup) Source() string {
+	if b.Kind == BackupData {
+		return "data volume " + b.DataVolume
+	}
+	return "root volume of " + b.SourceInstance
+}
+
+// Completed reports whether the snapshot has finished
+func (b Backup) Completed() bool {
+	return b.State == string(types.SnapshotStateCompleted)
+}
+
+// CreateBackup starts a snapshot of a volume, tagged as a backup of source,
+// and returns its ID
+func (e *EC2Client) CreateBackup(ctx context.Context, source BackupSource, description string) (string, error) {
+	tags := []types.Tag{
+		{Key: aws.String(TagName), Value: aws.String(source.Name)},
+		{Key: aws.String(TagBackup), Value: aws.String(string(source.Kind))},
+	}
+	optional := []struct{ key, value string }{
+		{TagSourceInstance, source.SourceInstance},
+		{TagDataVolume, source.DataVolume},
+		{TagApp, source.App},
+		{TagOwner, source.Owner},
+	}
+	if source.App != "" {
+		optional = append(optional, struct{ key, value string }{TagCreatedBy, source.App + "-cli"})
+	}
+	for _, tag := range optional {
+		if tag.value != "" {
+			tags = append(tags, types.Tag{Key: aws.String(tag.key), Value: aws.String(tag.value)})
+		}
+	}
+
+	result, err := e.client.CreateSnapshot(ctx, &ec2.CreateSnapshotInput{
+		VolumeId:    aws.String(source.VolumeID),
+		Description: aws.String(description),
+		TagSpecifications: []types.TagSpecification{{
+			ResourceType: types.ResourceTypeSnapshot,
+			Tags:         tags,
+		}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to snapshot volume %s: %w", source.VolumeID, err)
+	}
+	return aws.ToString(result.SnapshotId), nil
+}
+
+// WaitForBackups waits for snapshots to complete
+func (e *EC2Client) WaitForBackups(ctx context.Context, snapshotIDs []string, timeout time.Duration) error {
+	waiter := ec2.NewSnapshotCompletedWaiter(e.client)
+	return waiter.Wait(ctx, &ec2.DescribeSnapshotsInput{SnapshotIds: snapshotIDs}, timeout)
+}
+
+// ListBackups returns the backups in the region taken by owner, or everyone's
+// if owner is empty, oldest first
+func (e *EC2Client) ListBackups(ctx context.Context, owner string) ([]Backup, error) {
+	filters := []types.Filter{{
+		Name:   aws.String("tag-key"),
+		Values: []string{TagBackup},
+	}}
+	if owner != "" {
+		filters = append(filters, types.Filter{
+			Name:   aws.String("tag:" + TagOwner),
+			Values: []string{owner},
+		})
+	}
+
+	var backups []Backup
+	paginator := ec2.NewDescribeSnapshotsPaginator(e.client, &ec2.DescribeSnapshotsInput{
+		OwnerIds: []string{"self"},
+		Filters:  filters,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list backups: %w", err)
+		}
+		for _, snapshot := range page.Snapshots {
+			backups = append(backups, e.backup(snapshot))
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.Before(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// GetBackup returns the backup with the given snapshot ID
+func (e *EC2Client) GetBackup(ctx context.Context, snapshotID string) (*Backup, error) {
+	result, err := e.client.DescribeSnapshots(ctx, &ec2.DescribeSnapshotsInput{
+		SnapshotIds: []string{snapshotID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe snapshot %s: %w", snapshotID, err)
+	}
+	if len(result.Snapshots) == 0 || tagValue(result.Snapshots[0].Tags, TagBackup) == "" {
+		return nil, fmt.Errorf("snapshot %s is not a lens backup", snapshotID)
+	}
+	backup := e.backup(result.Snapshots[0])
+	return &backup, nil
+}
+
+// DeleteBackup deletes a backup's snapshot
+func (e *EC2Client) DeleteBackup(ctx context.Context, snapshotID string) error {
+	_, err := e.client.DeleteSnapshot(ctx, &ec2.DeleteSnapshotInput{
+		SnapshotId: aws.String(snapshotID),
+	})
+	return err
+}
+
+func (e *EC2Client) backup(snapshot types.Snapshot) Backup {
+	return Backup{
+		ID:             aws.ToString(snapshot.SnapshotId),
+		Region:         e.region,
+		Kind:           BackupKind(tagValue(snapshot.Tags, TagBackup)),
+		Name:           tagValue(snapshot.Tags, TagName),
+		SourceInstance: tagValue(snapshot.Tags, TagSourceInstance),
+		DataVolume:     tagValue(snapshot.Tags, TagDataVolume),
+		VolumeID:       aws.ToString(snapshot.VolumeId),
+		SizeGB:         int(aws.ToInt32(snapshot.VolumeSize)),
+		State:          string(snapshot.State),
+		Progress:       aws.ToString(snapshot.Progress),
+		App:            tagValue(snapshot.Tags, TagApp),
+		Owner:          tagValue(snapshot.Tags, TagOwner),
+		Description:    aws.ToString(snapshot.Description),
+		CreatedAt:      aws.ToTime(snapshot.StartTime),
+	}
+}
@@ -0,0 +1,111 @@
+package aws
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
+)
+
+// CloudTrailRetention is how far back CloudTrail event history can be looked up
+const CloudTrailRetention = 90 * 24 * time.Hour
+
+// instanceStateEvents maps the CloudTrail events that change whether an
+// instance runs to the state it is in afterwards. BidEvictedEvent is a Spot
+// interruption.
+var instanceStateEvents = map[string]string{
+	"RunInstances":       "running",
+	"StartInstances":     "running",
+	"StopInstances":      "stopped",
+	"TerminateInstances": "terminated",
+	"BidEvictedEvent":    "stopped",
+}
+
+// CloudTrailClient wraps the CloudTrail event history lookups of one region
+type CloudTrailClient struct {
+	client CloudTrailAPI
+	region string
+}
+
+// NewCloudTrailClientWithAPI creates a CloudTrail client backed by the given API implementation
+func NewCloudTrailClientWithAPI(api CloudTrailAPI, region string) *CloudTrailClient {
+	return &CloudTrailClient{client: api, region: region}
+}
+
+// NewCloudTrailClient creates a CloudTrail client for a region using the
+// specified AWS profile
+func NewCloudTrailClient(ctx context.Context, profile, region string) (*CloudTrailClient, error) {
+	if p := activeProvider(); p != nil {
+		region = providerRegion(p, region)
+		return NewCloudTrailClientWithAPI(p.CloudTrail(region), region), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithSharedConfigProfile(profile),
+		config.WithRegion(region),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &CloudTrailClient{client: cloudtrail.NewFromConfig(cfg), region: cfg.Region}, nil
+}
+
+// InstanceStateEvent is a start, stop or termination of an instance recorded
+// by CloudTrail, whoever made it: lens, the console, a script on the instance
+// or AWS itself
+type InstanceStateEvent struct {
+	EventName string    // e.g. "StopInstances"
+	State     string    // State the instance is in afterwards: "running", "stopped" or "terminated"
+	Time      time.Time // When the request was made
+	Username  string    // Principal that made the request, if any
+}
+
+// InstanceStateEvents returns the state-changing events of an instance since
+// the given time, oldest first. CloudTrail keeps 90 days of event history.
+func (c *CloudTrailClient) InstanceStateEvents(ctx context.Context, instanceID string, since time.Time) ([]InstanceStateEvent, error) {
+	if oldest := time.Now().Add(-CloudTrailRetention); since.Before(oldest) {
+		since = oldest
+	}
+
+	input := &cloudtrail.LookupEventsInput{
+		LookupAttributes: []types.LookupAttribute{
+			{AttributeKey: types.LookupAttributeKeyResourceName, AttributeValue: aws.String(instanceID)},
+		},
+		StartTime: aws.Time(since),
+	}
+
+	var events []InstanceStateEvent
+	for {
+		result, err := c.client.LookupEvents(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, event := range result.Events {
+			state, ok := instanceStateEvents[aws.ToString(event.EventName)]
+			if !ok || event.EventTime == nil {
+				continue
+			}
+			events = append(events, InstanceStateEvent{
+				EventName: aws.ToString(event.EventName),
+				State:     state,
+				Time:      *event.EventTime,
+				Username:  aws.ToString(event.Username),
+			})
+		}
+
+		if result.NextToken == nil {
+			break
+		}
+		input.NextToken = result.NextToken
+	}
+
+	// Event history is returned newest first
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events, nil
+}
@@ -0,0 +1,187 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// CloudWatch namespaces of instance metrics
+const (
+	NamespaceEC2       = "AWS/EC2"    // Published by EC2 for every instance
+	NamespaceAgent     = "CWAgent"    // Published by the CloudWatch agent, when installed
+	NamespaceLensAgent = "Lens/Agent" // Published by lens-agent, see agent.MetricNamespace
+)
+
+// EC2 metrics read by lens
+const (
+	MetricNetworkIn      = "NetworkIn"      // Bytes received by all network interfaces
+	MetricNetworkOut     = "NetworkOut"     // Bytes sent by all network interfaces
+	MetricCPUUtilization = "CPUUtilization" // Percent of the vCPUs in use
+)
+
+// CloudWatch agent metrics read by lens. The agent must publish them with an
+// InstanceId dimension only (append_dimensions with InstanceId and
+// aggregation_dimensions [["InstanceId"]]).
+const (
+	MetricMemoryUsed     = "mem_used_percent"           // Percent of memory in use
+	MetricDiskUsed       = "disk_used_percent"          // Percent of disk space in use
+	MetricGPUUtilization = "nvidia_smi_utilization_gpu" // Percent of GPU time in use
+)
+
+// MetricKeepalive is the lens-agent metric that is 1 while a keepalive
+// postpones auto-stop, and missing otherwise
+const MetricKeepalive = "Keepalive"
+
+// metricRetention is how far back CloudWatch keeps hourly EC2 datapoints
+const metricRetention = 455 * 24 * time.Hour
+
+// maxDatapoints is the most datapoints GetMetricStatistics returns per call
+const maxDatapoints = 1440
+
+// CloudWatchClient wraps the CloudWatch operations used for instance metrics
+type CloudWatchClient struct {
+	client CloudWatchAPI
+	region string
+}
+
+// NewCloudWatchClientWithAPI creates a CloudWatch client backed by the given API implementation
+func NewCloudWatchClientWithAPI(api CloudWatchAPI, region string) *CloudWatchClient {
+	return &CloudWatchClient{client: api, region: region}
+}
+
+// NewCloudWatchClient creates a CloudWatch client for a region using the
+// specified AWS profile
+func NewCloudWatchClient(ctx context.Context, profile, region string) (*CloudWatchClient, error) {
+	if p := activeProvider(); p != nil {
+		region = providerRegion(p, region)
+		return NewCloudWatchClientWithAPI(p.CloudWatch(region), region), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithSharedConfigProfile(profile),
+		config.WithRegion(region),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &CloudWatchClient{client: cloudwatch.NewFromConfig(cfg), region: cfg.Region}, nil
+}
+
+// NewCloudWatchClientForRegion creates a CloudWatch client for a region using
+// the default credential chain
+func NewCloudWatchClientForRegion(ctx context.Context, region string) (*CloudWatchClient, error) {
+	if p := activeProvider(); p != nil {
+		region = providerRegion(p, region)
+		return NewCloudWatchClientWithAPI(p.CloudWatch(region), region), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(region),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &CloudWatchClient{client: cloudwatch.NewFromConfig(cfg), region: cfg.Region}, nil
+}
+
+// InstanceMetricSum returns the sum of an AWS/EC2 metric of an instance
+// between start and end. Datapoints older than CloudWatch retains are not
+// included.
+func (c *CloudWatchClient) InstanceMetricSum(ctx context.Context, instanceID, metric string, start, end time.Time) (float64, error) {
+	start, period := metricPeriod(start, end)
+	if !start.Before(end) {
+		return 0, nil
+	}
+
+	result, err := c.client.GetMetricStatistics(ctx, &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String(NamespaceEC2),
+		MetricName: aws.String(metric),
+		Dimensions: []types.Dimension{
+			{Name: aws.String("InstanceId"), Value: aws.String(instanceID)},
+		},
+		StartTime:  aws.Time(start),
+		EndTime:    aws.Time(end),
+		Period:     aws.Int32(int32(period / time.Second)),
+		Statistics: []types.Statistic{types.StatisticSum},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get %s of %s: %w", metric, instanceID, err)
+	}
+
+	var sum float64
+	for _, point := range result.Datapoints {
+		sum += aws.ToFloat64(point.Sum)
+	}
+	return sum, nil
+}
+
+// MetricStats summarizes a percentage metric of an instance over a time range
+type MetricStats struct {
+	Average    float64 // Average over the whole range
+	Peak       float64 // Highest hourly average
+	Datapoints int     // Periods with data; zero when the metric is not published
+	Period     time.Duration
+}
+
+// Hours returns how many hours of data the stats cover
+func (s MetricStats) Hours() float64 {
+	return float64(s.Datapoints) * s.Period.Hours()
+}
+
+// InstanceMetricStats returns the average and peak of an instance metric in a
+// namespace between start and end. The peak is the highest hourly average, or
+// the highest average of a longer period for ranges of more than two months.
+func (c *CloudWatchClient) InstanceMetricStats(ctx context.Context, namespace, instanceID, metric string, start, end time.Time) (MetricStats, error) {
+	start, period := metricPeriod(start, end)
+	stats := MetricStats{Period: period}
+	if !start.Before(end) {
+		return stats, nil
+	}
+
+	result, err := c.client.GetMetricStatistics(ctx, &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String(namespace),
+		MetricName: aws.String(metric),
+		Dimensions: []types.Dimension{
+			{Name: aws.String("InstanceId"), Value: aws.String(instanceID)},
+		},
+		StartTime:  aws.Time(start),
+		EndTime:    aws.Time(end),
+		Period:     aws.Int32(int32(period / time.Second)),
+		Statistics: []types.Statistic{types.StatisticAverage},
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to get %s of %s: %w", metric, instanceID, err)
+	}
+
+	var sum float64
+	for _, point := range result.Datapoints {
+		average := aws.ToFloat64(point.Average)
+		sum += average
+		stats.Peak = max(stats.Peak, average)
+	}
+	stats.Datapoints = len(result.Datapoints)
+	if stats.Datapoints > 0 {
+		stats.Average = sum / float64(stats.Datapoints)
+	}
+	return stats, nil
+}
+
+// metricPeriod clips start to the datapoints CloudWatch retains and returns
+// it with the period to request: hourly, or longer when the range needs more
+// datapoints than one call can return
+func metricPeriod(start, end time.Time) (time.Time, time.Duration) {
+	if oldest := end.Add(-metricRetention); start.Before(oldest) {
+		start = oldest
+	}
+	period := time.Hour
+	if span := end.Sub(start); span > maxDatapoints*period {
+		period = (span/maxDatapoints/time.Hour + 1) * time.Hour
+	}
+	return start, period
+}
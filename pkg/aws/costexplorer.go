@@ -0,0 +1,120 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+// costExplorerRegion is the region that serves the Cost Explorer API
+const costExplorerRegion = "us-east-1"
+
+// costMetric is the Cost Explorer metric compared with lens estimates:
+// on-demand prices before discounts are applied
+const costMetric = "UnblendedCost"
+
+// costDateFormat is the date format of Cost Explorer time periods
+const costDateFormat = "2006-01-02"
+
+// CostExplorerClient wraps the Cost Explorer operations used to read billed costs
+type CostExplorerClient struct {
+	client CostExplorerAPI
+}
+
+// NewCostExplorerClientWithAPI creates a Cost Explorer client backed by the given API implementation
+func NewCostExplorerClientWithAPI(api CostExplorerAPI) *CostExplorerClient {
+	return &CostExplorerClient{client: api}
+}
+
+// NewCostExplorerClient creates a new Cost Explorer client for the given profile
+func NewCostExplorerClient(ctx context.Context, profile string) (*CostExplorerClient, error) {
+	if p := activeProvider(); p != nil {
+		return NewCostExplorerClientWithAPI(p.CostExplorer()), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithSharedConfigProfile(profile),
+		config.WithRegion(costExplorerRegion),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &CostExplorerClient{client: costexplorer.NewFromConfig(cfg)}, nil
+}
+
+// TagCost is the billed cost of the resources with one value of a tag in a
+// calendar month
+type TagCost struct {
+	Month     time.Time // First day of the month, UTC
+	Value     string    // Tag value; empty for resources without the tag
+	Amount    float64   // Unblended cost in USD
+	Estimated bool      // The month is not final yet
+}
+
+// MonthlyCostByTag returns the billed cost of each value of a tag per
+// calendar month between from and to (exclusive). Cost Explorer only
+// reports tags that are activated as cost allocation tags, from the day they
+// were activated.
+func (c *CostExplorerClient) MonthlyCostByTag(ctx context.Context, tagKey string, from, to time.Time) ([]TagCost, error) {
+	input := &costexplorer.GetCostAndUsageInput{
+		TimePeriod: &types.DateInterval{
+			Start: aws.String(from.UTC().Format(costDateFormat)),
+			End:   aws.String(to.UTC().Format(costDateFormat)),
+		},
+		Granularity: types.GranularityMonthly,
+		Metrics:     []string{costMetric},
+		GroupBy:     []types.GroupDefinition{{Type: types.GroupDefinitionTypeTag, Key: aws.String(tagKey)}},
+	}
+
+	var costs []TagCost
+	for {
+		result, err := c.client.GetCostAndUsage(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, period := range result.ResultsByTime {
+			if period.TimePeriod == nil {
+				continue
+			}
+			month, err := time.Parse(costDateFormat, aws.ToString(period.TimePeriod.Start))
+			if err != nil {
+				return nil, fmt.Errorf("invalid time period %q: %w", aws.ToString(period.TimePeriod.Start), err)
+			}
+			month = time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+			for _, group := range period.Groups {
+				if len(group.Keys) == 0 {
+					continue
+				}
+				// Groups without the metric have nothing billed to report
+				metric, ok := group.Metrics[costMetric]
+				if !ok || metric.Amount == nil {
+					continue
+				}
+				amount, err := strconv.ParseFloat(aws.ToString(metric.Amount), 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid cost amount for %s: %w", group.Keys[0], err)
+				}
+				costs = append(costs, TagCost{
+					Month:     month,
+					Value:     strings.TrimPrefix(group.Keys[0], tagKey+"$"),
+					Amount:    amount,
+					Estimated: period.Estimated,
+				})
+			}
+		}
+
+		if result.NextPageToken == nil {
+			return costs, nil
+		}
+		input.NextPageToken = result.NextPageToken
+	}
+}
@@ -0,0 +1,42 @@
+package aws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+// stubCostExplorer returns a canned GetCostAndUsage result
+type stubCostExplorer struct {
+	output *costexplorer.GetCostAndUsageOutput
+}
+
+func (s stubCostExplorer) GetCostAndUsage(ctx context.Context, params *costexplorer.GetCostAndUsageInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetCostAndUsageOutput, error) {
+	return s.output, nil
+}
+
+func TestMonthlyCostByTag_SkipsGroupsWithoutTheMetric(t *testing.T) {
+	client := NewCostExplorerClientWithAPI(stubCostExplorer{output: &costexplorer.GetCostAndUsageOutput{
+		ResultsByTime: []types.ResultByTime{{
+			TimePeriod: &types.DateInterval{Start: aws.String("2026-09-01"), End: aws.String("2026-10-01")},
+			Groups: []types.Group{
+				{Keys: []string{TagInstance + "$i-billed"}, Metrics: map[string]types.MetricValue{costMetric: {Amount: aws.String("4.5"), Unit: aws.String("USD")}}},
+				{Keys: []string{TagInstance + "$i-other-metric"}, Metrics: map[string]types.MetricValue{"BlendedCost": {Amount: aws.String("1")}}},
+				{Keys: []string{TagInstance + "$i-no-amount"}, Metrics: map[string]types.MetricValue{costMetric: {Unit: aws.String("USD")}}},
+			},
+		}},
+	}})
+
+	costs, err := client.MonthlyCostByTag(context.Background(), TagInstance,
+		time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("MonthlyCostByTag failed: %v", err)
+	}
+	if len(costs) != 1 || costs[0].Value != "i-billed" || costs[0].Amount != 4.5 {
+		t.Errorf("costs = %+v, want only i-billed at 4.5", costs)
+	}
+}
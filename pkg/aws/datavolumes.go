@@ -0,0 +1,379 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// DataVolumeDevice is the device name persistent data volumes are attached
+// as. Nitro instances expose it as an NVMe device whose serial is the
+// volume ID.
+const DataVolumeDevice = "/dev/sdf"
+
+// DataVolumeMountPath is where the user data script mounts the data volume
+const DataVolumeMountPath = "/home/ubuntu/data"
+
+// dataVolumeNamePattern matches valid data volume names
+var dataVolumeNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,62}$`)
+
+// DataVolumeSpec is a persistent data volume requested at launch with
+// --data-volume name:size
+type DataVolumeSpec struct {
+	Name   string
+	SizeGB int  // Size of a new volume; ignored when the volume exists
+	Shared bool // Attach a volume of this name whoever owns it, not only the caller's
+}
+
+// ParseDataVolumeSpec parses "name:size" or, for a volume that already
+// exists, "name"
+func ParseDataVolumeSpec(value string) (DataVolumeSpec, error) {
+	name, size, hasSize := strings.Cut(value, ":")
+	if !dataVolumeNamePattern.MatchString(name) {
+		return DataVolumeSpec{}, fmt.Errorf("invalid data volume name %q: use up to 63 letters, digits, '.', '_' or '-', starting with a letter or digit", name)
+	}
+	spec := DataVolumeSpec{Name: name}
+	if hasSize {
+		sizeGB, err := strconv.Atoi(size)
+		if err != nil || sizeGB < 1 || sizeGB > 16384 {
+			return DataVolumeSpec{}, fmt.Errorf("invalid data volume size %q: use a number of GB between 1 and 16384", size)
+		}
+		spec.SizeGB = sizeGB
+	}
+	return spec, nil
+}
+
+// DataVolume is a named persistent EBS volume that outlives the instances
+// it is attached to
+type DataVolume struct {
+	ID               string
+	Name             string
+	Region           string
+	AvailabilityZone string
+	SizeGB           int
+	State            string // creating, available, in-use, ...
+	InstanceID       string // Attached instance, if any
+	App              string
+	Owner            string
+	Project          string
+	CreatedAt        time.Time
+}
+
+// Attached reports whether the volume is attached to an instance
+func (v DataVolume) Attached() bool {
+	return v.InstanceID != ""
+}
+
+func (e *EC2Client) dataVolume(volume types.Volume) DataVolume {
+	v := DataVolume{
+		ID:               aws.ToString(volume.VolumeId),
+		Name:             tagValue(volume.Tags, TagDataVolume),
+		Region:           e.region,
+		AvailabilityZone: aws.ToString(volume.AvailabilityZone),
+		SizeGB:           int(aws.ToInt32(volume.Size)),
+		State:            string(volume.State),
+		App:              tagValue(volume.Tags, TagApp),
+		Owner:            tagValue(volume.Tags, TagOwner),
+		Project:          tagValue(volume.Tags, TagProject),
+		CreatedAt:        aws.ToTime(volume.CreateTime),
+	}
+	for _, attachment := range volume.Attachments {
+		if attachment.State == types.VolumeAttachmentStateAttaching || attachment.State == types.VolumeAttachmentStateAttached {
+			v.InstanceID = aws.ToString(attachment.InstanceId)
+		}
+	}
+	return v
+}
+
+// ListDataVolumes returns the persistent data volumes in the region
+func (e *EC2Client) ListDataVolumes(ctx context.Context) ([]DataVolume, error) {
+	return e.describeDataVolumes(ctx, types.Filter{
+		Name:   aws.String("tag-key"),
+		Values: []string{TagDataVolume},
+	})
+}
+
+// FindDataVolume returns the data volume with the given name owned by owner,
+// the ARN in its lens:owner tag, or nil if the region has none. An empty
+// owner finds a volume of that name whoever owns it.
+func (e *EC2Client) FindDataVolume(ctx context.Context, name, owner string) (*DataVolume, error) {
+	filters := []types.Filter{{
+		Name:   aws.String("tag:" + TagDataVolume),
+		Values: []string{name},
+	}}
+	if owner != "" {
+		filters = append(filters, types.Filter{
+			Name:   aws.String("tag:" + TagOwner),
+			Values: []string{owner},
+		})
+	}
+	volumes, err := e.describeDataVolumes(ctx, filters...)
+	if err != nil {
+		return nil, err
+	}
+	switch len(volumes) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &volumes[0], nil
+	}
+	return nil, fmt.Errorf("%d volumes in %s are named %q; delete or rename all but one", len(volumes), e.region, name)
+}
+
+// AttachedDataVolume returns the data volume attached to an instance, or nil
+// if it has none
+func (e *EC2Client) AttachedDataVolume(ctx context.Context, instanceID string) (*DataVolume, error) {
+	volumes, err := e.describeDataVolumes(ctx,
+		types.Filter{Name: aws.String("tag-key"), Values: []string{TagDataVolume}},
+		types.Filter{Name: aws.String("attachment.instance-id"), Values: []string{instanceID}},
+	)
+	if err != nil || len(volumes) == 0 {
+		return nil, err
+	}
+	return &volumes[0], nil
+}
+
+func (e *EC2Client) describeDataVolumes(ctx context.Context, filters ...types.Filter) ([]DataVolume, error) {
+	var volumes []DataVolume
+	paginator := ec2.NewDescribeVolumesPaginator(e.client, &ec2.DescribeVolumesInput{
+		Filters: filters,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe data volumes: %w", err)
+		}
+		for _, volume := range page.Volumes {
+			volumes = append(volumes, e.dataVolume(volume))
+		}
+	}
+	return volumes, nil
+}
+
+// CreateDataVolume creates an encrypted gp3 data volume in an availability
+// zone and waits until it is available. The volume is tagged with its name
+// and the app, owner and project of the metadata.
+func (e *EC2Client) CreateDataVolume(ctx context.Context, spec DataVolumeSpec, availabilityZone string, metadata InstanceMetadata) (*DataVolume, error) {
+	if spec.SizeGB == 0 {
+		return nil, fmt.Errorf("data volume %q does not exist in %s: give its size to create it, e.g. %s:100", spec.Name, e.region, spec.Name)
+	}
+	return e.createDataVolume(ctx, spec, "", availabilityZone, metadata)
+}
+
+// RestoreDataVolume creates a data volume from a backup in an availability
+// zone and waits until it is available
+func (e *EC2Client) RestoreDataVolume(ctx context.Context, backup Backup, name, availabilityZone string, metadata InstanceMetadata) (*DataVolume, error) {
+	if metadata.App == "" {
+		metadata.App = backup.App
+	}
+	return e.createDataVolume(ctx, DataVolumeSpec{Name: name, SizeGB: backup.SizeGB}, backup.ID, availabilityZone, metadata)
+}
+
+func (e *EC2Client) createDataVolume(ctx context.Context, spec DataVolumeSpec, snapshotID, availabilityZone string, metadata InstanceMetadata) (*DataVolume, error) {
+	app := metadata.App
+	if app == "" {
+		app = defaultApp
+	}
+	tags := []types.Tag{
+		{Key: aws.String(TagName), Value: aws.String(spec.Name)},
+		{Key: aws.String(TagCreatedBy), Value: aws.String(app + "-cli")},
+		{Key: aws.String(TagApp), Value: aws.String(app)},
+		{Key: aws.String(TagDataVolume), Value: aws.String(spec.Name)},
+	}
+	for _, tag := range []struct{ key, value string }{{TagOwner, metadata.Owner}, {TagProject, metadata.Project}} {
+		if tag.value != "" {
+			tags = append(tags, types.Tag{Key: aws.String(tag.key), Value: aws.String(tag.value)})
+		}
+	}
+
+	input := &ec2.CreateVolumeInput{
+		AvailabilityZone: aws.String(availabilityZone),
+		Size:             aws.Int32(int32(spec.SizeGB)),
+		VolumeType:       types.VolumeTypeGp3,
+		Encrypted:        aws.Bool(true),
+		TagSpecifications: []types.TagSpecification{{
+			ResourceType: types.ResourceTypeVolume,
+			Tags:         tags,
+		}},
+	}
+	if snapshotID != "" {
+		input.SnapshotId = aws.String(snapshotID)
+	}
+	result, err := e.client.CreateVolume(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create data volume: %w", err)
+	}
+	volumeID := aws.ToString(result.VolumeId)
+	e.record(ResourceVolume, volumeID, "")
+
+	waiter := ec2.NewVolumeAvailableWaiter(e.client)
+	output, err := waiter.WaitForOutput(ctx, &ec2.DescribeVolumesInput{VolumeIds: []string{volumeID}}, 5*time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("failed waiting for data volume %s: %w", volumeID, err)
+	}
+	volume := e.dataVolume(output.Volumes[0])
+	return &volume, nil
+}
+
+// AttachDataVolume attaches a data volume to an instance as DataVolumeDevice
+// and waits until it is attached. The volume is kept when the instance is
+// terminated.
+func (e *EC2Client) AttachDataVolume(ctx context.Context, volumeID, instanceID string) error {
+	return e.AttachDataVolumeAs(ctx, volumeID, instanceID, DataVolumeDevice)
+}
+
+// AttachDataVolumeAs attaches a data volume to an instance as device, for
+// instances that already have a data volume attached as DataVolumeDevice
+func (e *EC2Client) AttachDataVolumeAs(ctx context.Context, volumeID, instanceID, device string) error {
+	_, err := e.client.AttachVolume(ctx, &ec2.AttachVolumeInput{
+		VolumeId:   aws.String(volumeID),
+		InstanceId: aws.String(instanceID),
+		Device:     aws.String(device),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to attach data volume %s: %w", volumeID, err)
+	}
+
+	waiter := ec2.NewVolumeInUseWaiter(e.client)
+	if err := waiter.Wait(ctx, &ec2.DescribeVolumesInput{VolumeIds: []string{volumeID}}, 5*time.Minute); err != nil {
+		return fmt.Errorf("failed waiting for data volume %s to attach: %w", volumeID, err)
+	}
+	return nil
+}
+
+// FreeDataVolumeDevice returns the first device name from /dev/sdf to
+// /dev/sdp that no volume of the instance is attached as
+func FreeDataVolumeDevice(instance types.Instance) (string, error) {
+	used := make(map[string]bool)
+	for _, mapping := range instance.BlockDeviceMappings {
+		used[aws.ToString(mapping.DeviceName)] = true
+	}
+	for letter := 'f'; letter <= 'p'; letter++ {
+		device := fmt.Sprintf("/dev/sd%c", letter)
+		if !used[device] && !used[strings.Replace(device, "/dev/sd", "/dev/xvd", 1)] {
+			return device, nil
+		}
+	}
+	return "", fmt.Errorf("instance %s has no free device for another volume", aws.ToString(instance.InstanceId))
+}
+
+// DeleteDataVolume deletes a data volume that is not attached to an instance
+func (e *EC2Client) DeleteDataVolume(ctx context.Context, volumeID string) error {
+	_, err := e.client.DeleteVolume(ctx, &ec2.DeleteVolumeInput{
+		VolumeId: aws.String(volumeID),
+	})
+	return err
+}
+
+// SnapshotDataVolume starts a backup of a data volume, owned by owner, and
+// returns its ID. The snapshot is taken in the background; the volume can be
+// used while it completes.
+func (e *EC2Client) SnapshotDataVolume(ctx context.Context, volume DataVolume, owner, description string) (string, error) {
+	return e.CreateBackup(ctx, BackupSource{
+		Kind:           BackupData,
+		VolumeID:       volume.ID,
+		Name:           volume.Name,
+		SourceInstance: volume.InstanceID,
+		DataVolume:     volume.Name,
+		App:            volume.App,
+		Owner:          owner,
+	}, description)
+}
+
+// DataVolumeMountScript returns the user data that waits for a data volume
+// to be attached, creates an ext4 file system on it when it is new and mounts
+// it at DataVolumeMountPath, also on later boots. The volume is attached
+// after the instance is running, so the script waits up to ten minutes.
+func DataVolumeMountScript(volumeID string) string {
+	return `# Mount the persistent data volume
+log_progress 'Mounting data volume'
+` + mountVolumeScript(volumeID, DataVolumeDevice, DataVolumeMountPath, 120, 5, true) + `
+`
+}
+
+// RestoredVolumeMountScript returns the Session Manager command that mounts
+// a data volume restored from a backup at mountPath on a running instance,
+// also on later boots. It fails when the volume cannot be mounted.
+func RestoredVolumeMountScript(volumeID, device, mountPath string) string {
+	return mountVolumeScript(volumeID, device, mountPath, 60, 2, false) + strings.NewReplacer("{mount}", mountPath).Replace(`findmnt {mount} >/dev/null || exit 1
+df -h {mount}
+`)
+}
+
+// mountVolumeScript returns bash that waits for an attached volume, picks its
+// ext4 or xfs file system, creating one on a blank volume when format is set,
+// and mounts it through /etc/fstab. A restored root volume shares the UUID
+// and label of the instance's own root file system, so it gets new ones
+// before it is mounted; otherwise the instance could boot from it.
+func mountVolumeScript(volumeID, device, mountPath string, attempts, interval int, format bool) string {
+	formatScript := ""
+	if format {
+		formatScript = `  if [ -z "$DATA_PART" ] && ! blkid "$DATA_DEVICE" >/dev/null 2>&1; then
+    mkfs.ext4 -q "$DATA_DEVICE"
+    DATA_PART="$DATA_DEVICE"
+    DATA_NEW=1
+  fi
+`
+	}
+	return strings.NewReplacer(
+		"{volume}", volumeID,
+		"{device}", device,
+		"{xvd}", strings.Replace(device, "/dev/sd", "/dev/xvd", 1),
+		"{mount}", mountPath,
+		"{attempts}", strconv.Itoa(attempts),
+		"{interval}", strconv.Itoa(interval),
+		"{format}\n", formatScript,
+	).Replace(`DATA_VOLUME_ID="{volume}"
+DATA_DEVICE=""
+DATA_PART=""
+DATA_NEW=""
+for i in $(seq 1 {attempts}); do
+  for candidate in "/dev/disk/by-id/nvme-Amazon_Elastic_Block_Store_${DATA_VOLUME_ID//-/}" {xvd} {device}; do
+    if [ -b "$candidate" ]; then
+      DATA_DEVICE=$(readlink -f "$candidate")
+      break 2
+    fi
+  done
+  sleep {interval}
+done
+if [ -z "$DATA_DEVICE" ]; then
+  echo "Volume $DATA_VOLUME_ID was not attached within $(({attempts} * {interval})) seconds" >&2
+else
+  DATA_PART=$(lsblk -lnpo NAME,FSTYPE "$DATA_DEVICE" | awk '$2 == "ext4" || $2 == "xfs" { print $1; exit }')
+  if [ -n "$DATA_PART" ] && [ "$DATA_PART" != "$DATA_DEVICE" ]; then
+    DATA_LABEL=$(blkid -s LABEL -o value "$DATA_PART" || true)
+    if [ "$(blkid -s UUID -o value "$DATA_PART")" = "$(findmnt -no UUID /)" ] || { [ -n "$DATA_LABEL" ] && [ "$DATA_LABEL" = "$(findmnt -no LABEL /)" ]; }; then
+      if command -v sgdisk >/dev/null; then
+        sgdisk -G "$DATA_DEVICE" >/dev/null && partprobe "$DATA_DEVICE" || true
+      fi
+      case $(blkid -s TYPE -o value "$DATA_PART") in
+        xfs) xfs_admin -U generate -L lens-data "$DATA_PART" ;;
+        *) e2fsck -fy "$DATA_PART" >/dev/null || true; tune2fs -U random -L lens-data "$DATA_PART" ;;
+      esac
+    fi
+  fi
+{format}
+  if [ -z "$DATA_PART" ]; then
+    echo "Volume $DATA_VOLUME_ID has no ext4 or xfs file system" >&2
+  else
+    mkdir -p {mount}
+    DATA_UUID=$(blkid -s UUID -o value "$DATA_PART")
+    grep -q "UUID=$DATA_UUID" /etc/fstab || echo "UUID=$DATA_UUID {mount} auto defaults,nofail 0 2" >> /etc/fstab
+    if mount {mount}; then
+      if [ -n "$DATA_NEW" ]; then
+        chown ubuntu:ubuntu {mount}
+      fi
+    else
+      echo "Failed to mount volume $DATA_VOLUME_ID" >&2
+    fi
+  fi
+fi
+`)
+}
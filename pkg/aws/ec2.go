@@ -14,12 +14,25 @@ import (
 
 // EC2Client wraps the AWS EC2 SDK client with convenience methods for managing instances
 type EC2Client struct {
-	client *ec2.Client
-	region string
+	client   EC2API
+	region   string
+	recorder ResourceRecorder
+}
+
+// NewEC2ClientWithAPI creates an EC2 client backed by the given API implementation
+func NewEC2ClientWithAPI(api EC2API, region string) *EC2Client {
+	return &EC2Client{
+		client: api,
+		region: region,
+	}
 }
 
 // NewEC2Client creates a new EC2 client using the specified AWS profile
 func NewEC2Client(ctx context.Context, profile string) (*EC2Client, error) {
+	if p := activeProvider(); p != nil {
+		return NewEC2ClientWithAPI(p.EC2(p.DefaultRegion()), p.DefaultRegion()), nil
+	}
+
 	cfg, err := config.LoadDefaultConfig(ctx,
 		config.WithSharedConfigProfile(profile),
 	)
@@ -35,7 +48,33 @@ func NewEC2Client(ctx context.Context, profile string) (*EC2Client, error) {
 
 // NewEC2ClientForRegion creates a new EC2 client using the default profile for a specific region
 func NewEC2ClientForRegion(ctx context.Context, region string) (*EC2Client, error) {
+	if p := activeProvider(); p != nil {
+		region = providerRegion(p, region)
+		return NewEC2ClientWithAPI(p.EC2(region), region), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(region),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EC2Client{
+		client: ec2.NewFromConfig(cfg),
+		region: cfg.Region,
+	}, nil
+}
+
+// NewEC2ClientForProfileRegion creates a new EC2 client using the specified AWS profile in a specific region
+func NewEC2ClientForProfileRegion(ctx context.Context, profile, region string) (*EC2Client, error) {
+	if p := activeProvider(); p != nil {
+		region = providerRegion(p, region)
+		return NewEC2ClientWithAPI(p.EC2(region), region), nil
+	}
+
 	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithSharedConfigProfile(profile),
 		config.WithRegion(region),
 	)
 	if err != nil {
@@ -149,6 +188,11 @@ func (e *EC2Client) LaunchInstance(ctx context.Context, params LaunchParams) (*t
 		subnetID = defaultSubnet
 	}
 
+	metadata := params.Metadata
+	metadata.Environment = params.Environment
+	metadata.KeyPair = params.KeyPairName
+	metadata.EBSSize = params.EBSVolumeSize
+
 	runInput := &ec2.RunInstancesInput{
 		ImageId:          aws.String(params.AMI),
 		InstanceType:     types.InstanceType(params.InstanceType),
@@ -170,11 +214,7 @@ func (e *EC2Client) LaunchInstance(ctx context.Context, params LaunchParams) (*t
 		TagSpecifications: []types.TagSpecification{
 			{
 				ResourceType: types.ResourceTypeInstance,
-				Tags: []types.Tag{
-					{Key: aws.String("Name"), Value: aws.String("lens-jupyter")},
-					{Key: aws.String("CreatedBy"), Value: aws.String("lens-jupyter-cli")},
-					{Key: aws.String("Environment"), Value: aws.String(params.Environment)},
-				},
+				Tags:         metadata.Tags(),
 			},
 		},
 	}
@@ -257,6 +297,7 @@ func (e *EC2Client) LaunchInstance(ctx context.Context, params LaunchParams) (*t
 	if len(result.Instances) == 0 {
 		return nil, fmt.Errorf("no instances created")
 	}
+	e.record(ResourceInstance, aws.ToString(result.Instances[0].InstanceId), "")
 
 	return &result.Instances[0], nil
 }
@@ -307,6 +348,14 @@ func (e *EC2Client) WaitForInstanceRunning(ctx context.Context, instanceID strin
 	}, 5*time.Minute)
 }
 
+// WaitForInstanceStopped waits for an EC2 instance to reach the stopped state with a 10 minute timeout
+func (e *EC2Client) WaitForInstanceStopped(ctx context.Context, instanceID string) error {
+	waiter := ec2.NewInstanceStoppedWaiter(e.client)
+	return waiter.Wait(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: []string{instanceID},
+	}, 10*time.Minute)
+}
+
 // GetInstanceInfo retrieves detailed information about a specific EC2 instance
 func (e *EC2Client) GetInstanceInfo(ctx context.Context, instanceID string) (*types.Instance, error) {
 	result, err := e.client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
@@ -341,6 +390,15 @@ func (e *EC2Client) StartInstance(ctx context.Context, instanceID string) error
 	return err
 }
 
+// ModifyInstanceType changes the instance type of a stopped EC2 instance
+func (e *EC2Client) ModifyInstanceType(ctx context.Context, instanceID, instanceType string) error {
+	_, err := e.client.ModifyInstanceAttribute(ctx, &ec2.ModifyInstanceAttributeInput{
+		InstanceId:   aws.String(instanceID),
+		InstanceType: &types.AttributeValue{Value: aws.String(instanceType)},
+	})
+	return err
+}
+
 // TerminateInstance permanently terminates an EC2 instance
 func (e *EC2Client) TerminateInstance(ctx context.Context, instanceID string) error {
 	_, err := e.client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{
@@ -362,6 +420,15 @@ func (e *EC2Client) CreateAMI(ctx context.Context, instanceID, name, description
 				Tags: []types.Tag{
 					{Key: aws.String("Name"), Value: aws.String(name)},
 					{Key: aws.String("CreatedBy"), Value: aws.String("lens-jupyter-cli")},
+					{Key: aws.String(TagSourceInstance), Value: aws.String(instanceID)},
+				},
+			},
+			{
+				ResourceType: types.ResourceTypeSnapshot,
+				Tags: []types.Tag{
+					{Key: aws.String("Name"), Value: aws.String(name)},
+					{Key: aws.String("CreatedBy"), Value: aws.String("lens-jupyter-cli")},
+					{Key: aws.String(TagSourceInstance), Value: aws.String(instanceID)},
 				},
 			},
 		},
@@ -474,7 +541,8 @@ type LaunchParams struct {
 	Environment      string
 	SubnetID         string
 	InstanceProfile  string
-	UseSpot          bool   // Launch as Spot instance (70-90% cost savings)
-	SpotMaxPrice     string // Optional max price (defaults to on-demand price)
-	SpotInstanceType string // Either "one-time" or "persistent" (default: one-time)
+	UseSpot          bool             // Launch as Spot instance (70-90% cost savings)
+	SpotMaxPrice     string           // Optional max price (defaults to on-demand price)
+	SpotInstanceType string           // Either "one-time" or "persistent" (default: one-time)
+	Metadata         InstanceMetadata // Written as tags; Environment, KeyPair and EBSSize come from the fields above
 }
@@ -0,0 +1,233 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// Footprint is the lens infrastructure in a region that is billed on its own,
+// independently of instance hours and root volumes
+type Footprint struct {
+	Region      string
+	NATGateways []NATGatewayUsage
+	Addresses   []AddressUsage
+	Snapshots   []SnapshotUsage
+	DataVolumes []DataVolume
+
+	// PublicIPs maps live instances with an auto-assigned public IPv4
+	// address (not an Elastic IP) to that address
+	PublicIPs map[string]string
+}
+
+// NATGatewayUsage is a lens NAT gateway and the live instances whose subnets
+// route through it
+type NATGatewayUsage struct {
+	ID        string
+	Name      string
+	CreatedAt time.Time
+	Addresses []string // Allocation IDs of its Elastic IPs
+	Instances []string
+}
+
+// AddressUsage is a lens Elastic IP and what it is associated with
+type AddressUsage struct {
+	AllocationID string
+	PublicIP     string
+	InstanceID   string // Set when associated with an instance
+	NATGatewayID string // Set when used by a NAT gateway
+}
+
+// Idle reports whether the address is not associated with anything
+func (a AddressUsage) Idle() bool {
+	return a.InstanceID == "" && a.NATGatewayID == ""
+}
+
+// SnapshotUsage is a lens EBS snapshot, backing a custom AMI or taken as a
+// backup
+type SnapshotUsage struct {
+	ID             string
+	Name           string
+	ImageID        string     // AMI the snapshot backs, if it still exists
+	SourceInstance string     // Instance the AMI was created from, if known
+	Backup         BackupKind // Kind of volume the snapshot is a backup of, if it is one
+	SizeGB         int
+	StartedAt      time.Time
+}
+
+// ScanFootprint lists the NAT gateways, Elastic IPs, snapshots and data
+// volumes created by lens in the client's region, and the live instances
+// using them
+func (e *EC2Client) ScanFootprint(ctx context.Context) (*Footprint, error) {
+	instances, err := e.liveInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	footprint := &Footprint{Region: e.region, PublicIPs: make(map[string]string)}
+	instancesBySubnet := make(map[string][]string)
+	for _, inst := range instances {
+		instanceID := aws.ToString(inst.InstanceId)
+		instancesBySubnet[aws.ToString(inst.SubnetId)] = append(instancesBySubnet[aws.ToString(inst.SubnetId)], instanceID)
+		if inst.PublicIpAddress != nil {
+			footprint.PublicIPs[instanceID] = *inst.PublicIpAddress
+		}
+	}
+
+	if err := e.footprintNATGateways(ctx, footprint, instancesBySubnet); err != nil {
+		return nil, err
+	}
+	if err := e.footprintAddresses(ctx, footprint); err != nil {
+		return nil, err
+	}
+	if err := e.footprintSnapshots(ctx, footprint); err != nil {
+		return nil, err
+	}
+	if footprint.DataVolumes, err = e.ListDataVolumes(ctx); err != nil {
+		return nil, err
+	}
+	return footprint, nil
+}
+
+// footprintNATGateways adds the lens NAT gateways that are not being deleted
+func (e *EC2Client) footprintNATGateways(ctx context.Context, footprint *Footprint, instancesBySubnet map[string][]string) error {
+	natResult, err := e.client.DescribeNatGateways(ctx, &ec2.DescribeNatGatewaysInput{
+		Filter: []types.Filter{
+			{
+				Name:   aws.String("state"),
+				Values: []string{"pending", "available"},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list NAT Gateways: %w", err)
+	}
+
+	var lensNATs []types.NatGateway
+	for _, nat := range natResult.NatGateways {
+		if IsLensCreatedBy(tagValue(nat.Tags, TagCreatedBy)) {
+			lensNATs = append(lensNATs, nat)
+		}
+	}
+	if len(lensNATs) == 0 {
+		return nil
+	}
+
+	tableResult, err := e.client.DescribeRouteTables(ctx, &ec2.DescribeRouteTablesInput{})
+	if err != nil {
+		return fmt.Errorf("failed to list route tables: %w", err)
+	}
+	subnetResult, err := e.client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{})
+	if err != nil {
+		return fmt.Errorf("failed to list subnets: %w", err)
+	}
+
+	instancesByNAT := make(map[string][]string)
+	for subnetID, natIDs := range natGatewaysBySubnet(tableResult.RouteTables, subnetResult.Subnets) {
+		for _, natID := range natIDs {
+			instancesByNAT[natID] = append(instancesByNAT[natID], instancesBySubnet[subnetID]...)
+		}
+	}
+
+	for _, nat := range lensNATs {
+		natID := aws.ToString(nat.NatGatewayId)
+		usage := NATGatewayUsage{
+			ID:        natID,
+			Name:      tagValue(nat.Tags, TagName),
+			CreatedAt: aws.ToTime(nat.CreateTime),
+			Instances: instancesByNAT[natID],
+		}
+		for _, address := range nat.NatGatewayAddresses {
+			if address.AllocationId != nil {
+				usage.Addresses = append(usage.Addresses, *address.AllocationId)
+			}
+		}
+		footprint.NATGateways = append(footprint.NATGateways, usage)
+	}
+	return nil
+}
+
+// footprintAddresses adds the lens Elastic IPs
+func (e *EC2Client) footprintAddresses(ctx context.Context, footprint *Footprint) error {
+	result, err := e.client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{})
+	if err != nil {
+		return fmt.Errorf("failed to list Elastic IPs: %w", err)
+	}
+
+	natByAddress := make(map[string]string)
+	for _, nat := range footprint.NATGateways {
+		for _, allocationID := range nat.Addresses {
+			natByAddress[allocationID] = nat.ID
+		}
+	}
+
+	for _, address := range result.Addresses {
+		if !IsLensCreatedBy(tagValue(address.Tags, TagCreatedBy)) {
+			continue
+		}
+		allocationID := aws.ToString(address.AllocationId)
+		usage := AddressUsage{
+			AllocationID: allocationID,
+			PublicIP:     aws.ToString(address.PublicIp),
+			InstanceID:   aws.ToString(address.InstanceId),
+			NATGatewayID: natByAddress[allocationID],
+		}
+		if usage.InstanceID != "" {
+			// Charged as an Elastic IP, not as an auto-assigned address
+			delete(footprint.PublicIPs, usage.InstanceID)
+		}
+		footprint.Addresses = append(footprint.Addresses, usage)
+	}
+	return nil
+}
+
+// footprintSnapshots adds the lens snapshots with the AMIs they back
+func (e *EC2Client) footprintSnapshots(ctx context.Context, footprint *Footprint) error {
+	imageResult, err := e.client.DescribeImages(ctx, &ec2.DescribeImagesInput{
+		Owners: []string{"self"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list AMIs: %w", err)
+	}
+	imageBySnapshot := make(map[string]types.Image)
+	for _, image := range imageResult.Images {
+		for _, mapping := range image.BlockDeviceMappings {
+			if mapping.Ebs != nil && mapping.Ebs.SnapshotId != nil {
+				imageBySnapshot[*mapping.Ebs.SnapshotId] = image
+			}
+		}
+	}
+
+	snapshotResult, err := e.client.DescribeSnapshots(ctx, &ec2.DescribeSnapshotsInput{
+		OwnerIds: []string{"self"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	for _, snapshot := range snapshotResult.Snapshots {
+		if !IsLensCreatedBy(tagValue(snapshot.Tags, TagCreatedBy)) {
+			continue
+		}
+		snapshotID := aws.ToString(snapshot.SnapshotId)
+		usage := SnapshotUsage{
+			ID:             snapshotID,
+			Name:           tagValue(snapshot.Tags, TagName),
+			SourceInstance: tagValue(snapshot.Tags, TagSourceInstance),
+			Backup:         BackupKind(tagValue(snapshot.Tags, TagBackup)),
+			SizeGB:         int(aws.ToInt32(snapshot.VolumeSize)),
+			StartedAt:      aws.ToTime(snapshot.StartTime),
+		}
+		if image, ok := imageBySnapshot[snapshotID]; ok {
+			usage.ImageID = aws.ToString(image.ImageId)
+			if usage.SourceInstance == "" {
+				usage.SourceInstance = tagValue(image.Tags, TagSourceInstance)
+			}
+		}
+		footprint.Snapshots = append(footprint.Snapshots, usage)
+	}
+	return nil
+}
@@ -15,11 +15,21 @@ import (
 
 // IAMClient wraps the AWS IAM client with our methods
 type IAMClient struct {
-	client *iam.Client
+	client   IAMAPI
+	recorder ResourceRecorder
+}
+
+// NewIAMClientWithAPI creates an IAM client backed by the given API implementation
+func NewIAMClientWithAPI(api IAMAPI) *IAMClient {
+	return &IAMClient{client: api}
 }
 
 // NewIAMClient creates a new IAM client using the same profile as EC2Client
 func NewIAMClient(ctx context.Context, profile string) (*IAMClient, error) {
+	if p := activeProvider(); p != nil {
+		return NewIAMClientWithAPI(p.IAM()), nil
+	}
+
 	cfg, err := config.LoadDefaultConfig(ctx,
 		config.WithSharedConfigProfile(profile),
 		config.WithRegion("us-east-1"), // IAM is global but requires a region
@@ -137,7 +147,11 @@ func (i *IAMClient) createRole(ctx context.Context, roleName, appPrefix string)
 			},
 		},
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	i.record(ResourceIAMRole, roleName)
+	return nil
 }
 
 // attachSessionManagerPolicy attaches the Session Manager policy to the role
@@ -275,6 +289,7 @@ func (i *IAMClient) createInstanceProfile(ctx context.Context, profileName, role
 	if err != nil {
 		return nil, fmt.Errorf("failed to create instance profile: %w", err)
 	}
+	i.record(ResourceInstanceProfile, profileName)
 
 	// Add the role to the instance profile
 	_, err = i.client.AddRoleToInstanceProfile(ctx, &iam.AddRoleToInstanceProfileInput{
@@ -361,6 +376,89 @@ func (i *IAMClient) ValidateSessionManagerRole(ctx context.Context, roleName str
 	return nil
 }
 
+// SchedulerRoleName is the role EventBridge Scheduler assumes to start and
+// stop instances on their schedules
+const SchedulerRoleName = "lens-scheduler-role"
+
+// SchedulerTrustPolicy is the trust policy for EventBridge Scheduler
+const SchedulerTrustPolicy = `{
+    "Version": "2012-10-17",
+    "Statement": [
+        {
+            "Effect": "Allow",
+            "Principal": {
+                "Service": "scheduler.amazonaws.com"
+            },
+            "Action": "sts:AssumeRole"
+        }
+    ]
+}`
+
+// schedulerPolicy lets the scheduler role start and stop lens instances only
+const schedulerPolicy = `{
+    "Version": "2012-10-17",
+    "Statement": [
+        {
+            "Effect": "Allow",
+            "Action": ["ec2:StartInstances", "ec2:StopInstances"],
+            "Resource": "*",
+            "Condition": {
+                "StringLike": {
+                    "ec2:ResourceTag/CreatedBy": "lens-*-cli"
+                }
+            }
+        }
+    ]
+}`
+
+// GetOrCreateSchedulerRole creates or gets the role EventBridge Scheduler
+// assumes to start and stop instances, and returns its ARN. The role is
+// shared by every lens app.
+func (i *IAMClient) GetOrCreateSchedulerRole(ctx context.Context, appPrefix string) (string, error) {
+	var roleARN string
+	result, err := i.client.GetRole(ctx, &iam.GetRoleInput{
+		RoleName: aws.String(SchedulerRoleName),
+	})
+	switch {
+	case err == nil:
+		roleARN = aws.ToString(result.Role.Arn)
+	case IsNotFound(err):
+		fmt.Printf("Creating IAM role: %s\n", SchedulerRoleName)
+		created, err := i.client.CreateRole(ctx, &iam.CreateRoleInput{
+			RoleName:                 aws.String(SchedulerRoleName),
+			AssumeRolePolicyDocument: aws.String(SchedulerTrustPolicy),
+			Description:              aws.String("IAM role for EventBridge Scheduler to start and stop lens instances"),
+			Tags: []types.Tag{
+				{
+					Key:   aws.String("CreatedBy"),
+					Value: aws.String(appPrefix + "-cli"),
+				},
+				{
+					Key:   aws.String("Purpose"),
+					Value: aws.String("instance-schedules"),
+				},
+			},
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to create role: %w", err)
+		}
+		i.record(ResourceIAMRole, SchedulerRoleName)
+		roleARN = aws.ToString(created.Role.Arn)
+	default:
+		return "", fmt.Errorf("failed to check role existence: %w", err)
+	}
+
+	// Put the policy every time so that existing roles get any changes to it
+	if _, err := i.client.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		RoleName:       aws.String(SchedulerRoleName),
+		PolicyName:     aws.String("lens-scheduler-policy"),
+		PolicyDocument: aws.String(schedulerPolicy),
+	}); err != nil {
+		return "", fmt.Errorf("failed to attach scheduler policy: %w", err)
+	}
+	return roleARN, nil
+}
+
 // CleanupSessionManagerResources removes Session Manager IAM resources (use with caution)
 func (i *IAMClient) CleanupSessionManagerResources(ctx context.Context) error {
 	roleName := "lens-jupyter-session-manager-role"
@@ -0,0 +1,260 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/smithy-go"
+)
+
+// IdleAlarmPrefix starts the name of every idle alarm, followed by the ID of
+// the instance it stops
+const IdleAlarmPrefix = "lens-idle-stop-"
+
+// IdleAlarmCPUThreshold is the CPU percent every 5-minute peak of an instance
+// must stay below for its idle alarm to stop it
+const IdleAlarmCPUThreshold = 5.0
+
+// IdleAlarmNetworkThreshold is the NetworkIn bytes every 5 minutes of an
+// instance must also stay below for its idle alarm to stop it. Someone using
+// an IDE in the browser keeps it above this even when the CPU is quiet.
+const IdleAlarmNetworkThreshold = 256 * 1024
+
+// idleAlarmPeriod is the period of the datapoints EC2 basic monitoring publishes
+const idleAlarmPeriod = 5 * time.Minute
+
+// maxAlarmEvaluation is the longest range CloudWatch evaluates an alarm of
+// periods shorter than an hour over
+const maxAlarmEvaluation = 24 * time.Hour
+
+// MaxIdleAlarmTimeout is the longest idle timeout an idle alarm can watch,
+// the 7 days CloudWatch evaluates an alarm of hourly periods over
+const MaxIdleAlarmTimeout = 7 * 24 * time.Hour
+
+// IdleAlarm is a CloudWatch alarm that stops an instance whose CPU and
+// network have been idle for its idle timeout. It is a safety net for when
+// lens-agent is not running on the instance.
+type IdleAlarm struct {
+	Name        string
+	InstanceID  string
+	State       string // OK, ALARM or INSUFFICIENT_DATA
+	StateReason string
+	UpdatedAt   time.Time
+	Evaluation  time.Duration // How long the CPU and network must stay idle
+}
+
+// IdleAlarmName returns the name of the idle alarm of an instance
+func IdleAlarmName(instanceID string) string {
+	return IdleAlarmPrefix + instanceID
+}
+
+// CheckIdleAlarmTimeout returns an error if an idle alarm cannot watch an
+// idle timeout
+func CheckIdleAlarmTimeout(idleTimeout time.Duration) error {
+	if idleTimeout <= 0 {
+		return fmt.Errorf("an idle alarm needs an idle timeout")
+	}
+	if idleTimeout > MaxIdleAlarmTimeout {
+		return fmt.Errorf("an idle alarm can watch an idle timeout of at most 7 days (%s), not %s", MaxIdleAlarmTimeout, idleTimeout)
+	}
+	return nil
+}
+
+// CreateIdleAlarm creates or replaces the idle alarm of an instance. The alarm
+// stops the instance with the built-in EC2 action once, in every 5 minutes,
+// the peak CPU has stayed below IdleAlarmCPUThreshold and the bytes received
+// below IdleAlarmNetworkThreshold for the idle timeout, and lens-agent has
+// published no MetricKeepalive. Idle timeouts over the
+// 24 hours CloudWatch evaluates 5-minute periods over are watched in hourly
+// periods, up to MaxIdleAlarmTimeout. A stopped instance publishes no data, so
+// its alarm stays quiet until it is started again.
+func (c *CloudWatchClient) CreateIdleAlarm(ctx context.Context, instanceID string, idleTimeout time.Duration, metadata InstanceMetadata) (*IdleAlarm, error) {
+	if err := CheckIdleAlarmTimeout(idleTimeout); err != nil {
+		return nil, err
+	}
+	period := idleAlarmPeriod
+	if idleTimeout > maxAlarmEvaluation {
+		period = time.Hour
+	}
+	periods := int32((idleTimeout + period - 1) / period)
+	evaluation := time.Duration(periods) * period
+
+	app := metadata.App
+	if app == "" {
+		app = defaultApp
+	}
+	name := IdleAlarmName(instanceID)
+	_, err := c.client.PutMetricAlarm(ctx, &cloudwatch.PutMetricAlarmInput{
+		AlarmName: aws.String(name),
+		AlarmDescription: aws.String(fmt.Sprintf("Stops %s after %s with CPU below %.0f%% and network below %d KB per 5 minutes and no keepalive, in case lens-agent is not running",
+			instanceID, evaluation, IdleAlarmCPUThreshold, IdleAlarmNetworkThreshold/1024)),
+		Metrics:            idleAlarmMetrics(instanceID, period),
+		EvaluationPeriods:  aws.Int32(periods),
+		Threshold:          aws.Float64(1),
+		ComparisonOperator: types.ComparisonOperatorLessThanThreshold,
+		TreatMissingData:   aws.String("missing"),
+		AlarmActions:       []string{fmt.Sprintf("arn:aws:automate:%s:ec2:stop", c.region)},
+		Tags: []types.Tag{
+			{Key: aws.String(TagCreatedBy), Value: aws.String(app + "-cli")},
+			{Key: aws.String(TagApp), Value: aws.String(app)},
+			{Key: aws.String(TagInstance), Value: aws.String(instanceID)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create idle alarm for %s: %w", instanceID, err)
+	}
+	return &IdleAlarm{Name: name, InstanceID: instanceID, State: string(types.StateValueInsufficientData), Evaluation: evaluation}, nil
+}
+
+// idleAlarmMetrics returns the metric math of an idle alarm: the larger of the
+// peak CPU and the bytes received in each period, as a fraction of their
+// thresholds, and the keepalive lens-agent publishes. The instance is idle
+// while it is below 1. The network threshold grows with the period, so an
+// hourly period allows 12 times as many bytes. The keepalive is missing
+// outside a keepalive, which FILL turns into 0.
+func idleAlarmMetrics(instanceID string, period time.Duration) []types.MetricDataQuery {
+	metric := func(namespace, name string) *types.Metric {
+		return &types.Metric{
+			Namespace:  aws.String(namespace),
+			MetricName: aws.String(name),
+			Dimensions: []types.Dimension{{Name: aws.String("InstanceId"), Value: aws.String(instanceID)}},
+		}
+	}
+	seconds := aws.Int32(int32(period / time.Second))
+	networkThreshold := IdleAlarmNetworkThreshold * int64(period/idleAlarmPeriod)
+	return []types.MetricDataQuery{
+		{
+			Id:         aws.String("cpu"),
+			MetricStat: &types.MetricStat{Metric: metric(NamespaceEC2, MetricCPUUtilization), Period: seconds, Stat: aws.String(string(types.StatisticMaximum))},
+			ReturnData: aws.Bool(false),
+		},
+		{
+			Id:         aws.String("network"),
+			MetricStat: &types.MetricStat{Metric: metric(NamespaceEC2, MetricNetworkIn), Period: seconds, Stat: aws.String(string(types.StatisticSum))},
+			ReturnData: aws.Bool(false),
+		},
+		{
+			Id:         aws.String("keepalive"),
+			MetricStat: &types.MetricStat{Metric: metric(NamespaceLensAgent, MetricKeepalive), Period: seconds, Stat: aws.String(string(types.StatisticMaximum))},
+			ReturnData: aws.Bool(false),
+		},
+		{
+			Id:         aws.String("activity"),
+			Expression: aws.String(fmt.Sprintf("MAX([cpu / %g, network / %d, FILL(keepalive, 0)])", IdleAlarmCPUThreshold, networkThreshold)),
+			Label:      aws.String("Activity relative to the idle thresholds"),
+			ReturnData: aws.Bool(true),
+		},
+	}
+}
+
+// GetIdleAlarm returns the idle alarm of an instance, or nil if it has none
+func (c *CloudWatchClient) GetIdleAlarm(ctx context.Context, instanceID string) (*IdleAlarm, error) {
+	result, err := c.client.DescribeAlarms(ctx, &cloudwatch.DescribeAlarmsInput{
+		AlarmNames: []string{IdleAlarmName(instanceID)},
+		AlarmTypes: []types.AlarmType{types.AlarmTypeMetricAlarm},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idle alarm of %s: %w", instanceID, err)
+	}
+	if len(result.MetricAlarms) == 0 {
+		return nil, nil
+	}
+	alarm := idleAlarm(result.MetricAlarms[0])
+	return &alarm, nil
+}
+
+// ListIdleAlarms returns every idle alarm in the client's region
+func (c *CloudWatchClient) ListIdleAlarms(ctx context.Context) ([]IdleAlarm, error) {
+	var alarms []IdleAlarm
+	input := &cloudwatch.DescribeAlarmsInput{
+		AlarmNamePrefix: aws.String(IdleAlarmPrefix),
+		AlarmTypes:      []types.AlarmType{types.AlarmTypeMetricAlarm},
+	}
+	for {
+		result, err := c.client.DescribeAlarms(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list idle alarms: %w", err)
+		}
+		for _, alarm := range result.MetricAlarms {
+			alarms = append(alarms, idleAlarm(alarm))
+		}
+		if result.NextToken == nil {
+			return alarms, nil
+		}
+		input.NextToken = result.NextToken
+	}
+}
+
+// DeleteIdleAlarm deletes an idle alarm by name. Deleting an alarm that does
+// not exist is not an error.
+func (c *CloudWatchClient) DeleteIdleAlarm(ctx context.Context, name string) error {
+	_, err := c.client.DeleteAlarms(ctx, &cloudwatch.DeleteAlarmsInput{
+		AlarmNames: []string{name},
+	})
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "ResourceNotFound" {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete idle alarm %s: %w", name, err)
+	}
+	return nil
+}
+
+// DeleteResource deletes a CloudWatch resource created by lens
+func (c *CloudWatchClient) DeleteResource(ctx context.Context, resource Resource) error {
+	if resource.Kind != ResourceAlarm {
+		return fmt.Errorf("cannot delete %s with the CloudWatch client", resource.Kind)
+	}
+	return c.DeleteIdleAlarm(ctx, resource.ID)
+}
+
+// OrphanedIdleAlarms returns the idle alarms in the client's region whose
+// instance is not among the live instances
+func (c *CloudWatchClient) OrphanedIdleAlarms(ctx context.Context, liveInstances []string) ([]Orphan, error) {
+	alarms, err := c.ListIdleAlarms(ctx)
+	if err != nil {
+		return nil, err
+	}
+	live := make(map[string]bool, len(liveInstances))
+	for _, id := range liveInstances {
+		live[id] = true
+	}
+
+	var orphans []Orphan
+	for _, alarm := range alarms {
+		if live[alarm.InstanceID] {
+			continue
+		}
+		orphans = append(orphans, Orphan{
+			Resource: Resource{Kind: ResourceAlarm, ID: alarm.Name, Region: c.region},
+			Reason:   fmt.Sprintf("instance %s no longer exists", alarm.InstanceID),
+		})
+	}
+	return orphans, nil
+}
+
+// idleAlarm converts a CloudWatch metric alarm to an IdleAlarm
+func idleAlarm(alarm types.MetricAlarm) IdleAlarm {
+	name := aws.ToString(alarm.AlarmName)
+	period := aws.ToInt32(alarm.Period)
+	for _, query := range alarm.Metrics {
+		if query.MetricStat != nil {
+			period = aws.ToInt32(query.MetricStat.Period)
+		}
+	}
+	return IdleAlarm{
+		Name:        name,
+		InstanceID:  strings.TrimPrefix(name, IdleAlarmPrefix),
+		State:       string(alarm.StateValue),
+		StateReason: aws.ToString(alarm.StateReason),
+		UpdatedAt:   aws.ToTime(alarm.StateUpdatedTimestamp),
+		Evaluation:  time.Duration(period*aws.ToInt32(alarm.EvaluationPeriods)) * time.Second,
+	}
+}
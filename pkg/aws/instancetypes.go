@@ -0,0 +1,115 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// maxInstanceTypesPerCall is the most instance types DescribeInstanceTypes
+// accepts by name
+const maxInstanceTypesPerCall = 100
+
+// InstanceTypeSpec describes the hardware of an instance type
+type InstanceTypeSpec struct {
+	InstanceType  string
+	VCPUs         int
+	MemoryMiB     int64
+	GPUs          int
+	Architectures []string // e.g. "arm64", "x86_64"
+	Burstable     bool     // Earns and spends CPU credits, e.g. t4g
+}
+
+// MemoryGiB returns the memory of the instance type in GiB
+func (s InstanceTypeSpec) MemoryGiB() float64 {
+	return float64(s.MemoryMiB) / 1024
+}
+
+// Supports reports whether the instance type runs images of an architecture
+func (s InstanceTypeSpec) Supports(architecture string) bool {
+	for _, supported := range s.Architectures {
+		if supported == architecture {
+			return true
+		}
+	}
+	return false
+}
+
+// DescribeInstanceTypes returns the specs of the given instance types, keyed
+// by instance type
+func (e *EC2Client) DescribeInstanceTypes(ctx context.Context, instanceTypes []string) (map[string]InstanceTypeSpec, error) {
+	specs := make(map[string]InstanceTypeSpec)
+	for start := 0; start < len(instanceTypes); start += maxInstanceTypesPerCall {
+		end := min(start+maxInstanceTypesPerCall, len(instanceTypes))
+		names := make([]types.InstanceType, 0, end-start)
+		for _, name := range instanceTypes[start:end] {
+			names = append(names, types.InstanceType(name))
+		}
+
+		paginator := ec2.NewDescribeInstanceTypesPaginator(e.client, &ec2.DescribeInstanceTypesInput{
+			InstanceTypes: names,
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to describe instance types: %w", err)
+			}
+			for _, info := range page.InstanceTypes {
+				spec := instanceTypeSpec(info)
+				specs[spec.InstanceType] = spec
+			}
+		}
+	}
+	return specs, nil
+}
+
+// InstanceTypesForArchitecture returns the specs of the current-generation
+// instance types offered in the region that run images of an architecture
+func (e *EC2Client) InstanceTypesForArchitecture(ctx context.Context, architecture string) ([]InstanceTypeSpec, error) {
+	paginator := ec2.NewDescribeInstanceTypesPaginator(e.client, &ec2.DescribeInstanceTypesInput{
+		Filters: []types.Filter{
+			{Name: aws.String("processor-info.supported-architecture"), Values: []string{architecture}},
+			{Name: aws.String("current-generation"), Values: []string{"true"}},
+		},
+	})
+
+	var specs []InstanceTypeSpec
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe instance types: %w", err)
+		}
+		for _, info := range page.InstanceTypes {
+			specs = append(specs, instanceTypeSpec(info))
+		}
+	}
+	return specs, nil
+}
+
+// instanceTypeSpec converts the EC2 description of an instance type
+func instanceTypeSpec(info types.InstanceTypeInfo) InstanceTypeSpec {
+	spec := InstanceTypeSpec{
+		InstanceType: string(info.InstanceType),
+		Burstable:    aws.ToBool(info.BurstablePerformanceSupported),
+	}
+	if info.VCpuInfo != nil {
+		spec.VCPUs = int(aws.ToInt32(info.VCpuInfo.DefaultVCpus))
+	}
+	if info.MemoryInfo != nil {
+		spec.MemoryMiB = aws.ToInt64(info.MemoryInfo.SizeInMiB)
+	}
+	if info.GpuInfo != nil {
+		for _, gpu := range info.GpuInfo.Gpus {
+			spec.GPUs += int(aws.ToInt32(gpu.Count))
+		}
+	}
+	if info.ProcessorInfo != nil {
+		for _, architecture := range info.ProcessorInfo.SupportedArchitectures {
+			spec.Architectures = append(spec.Architectures, string(architecture))
+		}
+	}
+	return spec
+}
@@ -116,6 +116,7 @@ func (e *EC2Client) CreateKeyPair(ctx context.Context, keyName string) (*KeyPair
 	if err != nil {
 		return nil, fmt.Errorf("failed to create key pair: %w", err)
 	}
+	e.record(ResourceKeyPair, aws.ToString(result.KeyName), "")
 
 	return &KeyPairInfo{
 		Name:        *result.KeyName,
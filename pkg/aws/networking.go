@@ -139,6 +139,7 @@ func (e *EC2Client) GetOrCreateNATGateway(ctx context.Context, vpcID string) (*N
 	if err != nil {
 		return nil, fmt.Errorf("failed to allocate Elastic IP: %w", err)
 	}
+	e.record(ResourceElasticIP, aws.ToString(eipResult.AllocationId), "")
 
 	// Create the NAT Gateway
 	fmt.Printf("Creating NAT Gateway in subnet %s...\n", publicSubnet.ID)
@@ -166,6 +167,8 @@ func (e *EC2Client) GetOrCreateNATGateway(ctx context.Context, vpcID string) (*N
 		return nil, fmt.Errorf("failed to create NAT Gateway: %w", err)
 	}
 
+	e.record(ResourceNATGateway, aws.ToString(natResult.NatGateway.NatGatewayId), "")
+
 	natGateway := &NATGatewayInfo{
 		ID:       aws.ToString(natResult.NatGateway.NatGatewayId),
 		SubnetID: publicSubnet.ID,
@@ -319,6 +322,7 @@ func (e *EC2Client) UpdatePrivateSubnetRoutes(ctx context.Context, subnetID, nat
 		}
 		return fmt.Errorf("failed to create route to NAT Gateway: %w", err)
 	}
+	e.record(ResourceRoute, "0.0.0.0/0", routeTableID)
 
 	fmt.Println("✓ Route to NAT Gateway created successfully")
 	return nil
@@ -0,0 +1,481 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+)
+
+// Orphan is a resource created by lens that no live instance references
+type Orphan struct {
+	Resource
+	// Name is the resource's Name tag or name, if different from its ID
+	Name string
+	// Reason explains why the resource is considered orphaned
+	Reason string
+	// SizeGB is the storage billed for AMIs and snapshots
+	SizeGB int
+}
+
+// RegionScan is the result of scanning a region for orphaned resources
+type RegionScan struct {
+	Region  string
+	Orphans []Orphan
+	// InstanceProfiles holds the ARNs of instance profiles attached to live
+	// instances in the region
+	InstanceProfiles []string
+	// Instances holds the IDs of the live instances in the region
+	Instances []string
+}
+
+// IsLensCreatedBy reports whether a CreatedBy tag value was set by a lens
+// CLI ("lens-jupyter-cli", "lens-rstudio-cli", ...)
+func IsLensCreatedBy(value string) bool {
+	return strings.HasPrefix(value, "lens-") && strings.HasSuffix(value, "-cli")
+}
+
+// IsLensSecurityGroup reports whether a security group name follows one of
+// the naming conventions used by lens launches
+func IsLensSecurityGroup(name string) bool {
+	return IsAwsJupyterSecurityGroup(name) ||
+		(strings.HasPrefix(name, "lens-") && strings.HasSuffix(name, "-session-manager"))
+}
+
+// ListRegions returns the names of the regions enabled for the account
+func (e *EC2Client) ListRegions(ctx context.Context) ([]string, error) {
+	result, err := e.client.DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list regions: %w", err)
+	}
+
+	regions := make([]string, 0, len(result.Regions))
+	for _, region := range result.Regions {
+		regions = append(regions, aws.ToString(region.RegionName))
+	}
+	sort.Strings(regions)
+	return regions, nil
+}
+
+// ScanOrphans finds resources in the client's region that were created by
+// lens and that no live (not terminated) instance references: security
+// groups, key pairs, NAT gateways with their Elastic IPs and routes, and
+// snapshots left behind by deleted AMIs. Custom AMIs are deliberate images
+// and are only reported when includeAMIs is set.
+func (e *EC2Client) ScanOrphans(ctx context.Context, includeAMIs bool) (*RegionScan, error) {
+	instances, err := e.liveInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	scan := &RegionScan{Region: e.region}
+	usedGroups := make(map[string]bool)
+	usedKeys := make(map[string]bool)
+	usedImages := make(map[string]bool)
+	usedSubnets := make(map[string]bool)
+	for _, inst := range instances {
+		scan.Instances = append(scan.Instances, aws.ToString(inst.InstanceId))
+		for _, group := range inst.SecurityGroups {
+			usedGroups[aws.ToString(group.GroupId)] = true
+		}
+		usedKeys[aws.ToString(inst.KeyName)] = true
+		usedImages[aws.ToString(inst.ImageId)] = true
+		usedSubnets[aws.ToString(inst.SubnetId)] = true
+		if inst.IamInstanceProfile != nil {
+			scan.InstanceProfiles = append(scan.InstanceProfiles, aws.ToString(inst.IamInstanceProfile.Arn))
+		}
+	}
+
+	natOrphans, err := e.orphanedNATGateways(ctx, usedSubnets)
+	if err != nil {
+		return nil, err
+	}
+	scan.Orphans = append(scan.Orphans, natOrphans...)
+
+	steps := []func() ([]Orphan, error){
+		func() ([]Orphan, error) { return e.orphanedAddresses(ctx, natOrphans) },
+		func() ([]Orphan, error) { return e.orphanedSecurityGroups(ctx, usedGroups) },
+		func() ([]Orphan, error) { return e.orphanedKeyPairs(ctx, usedKeys) },
+		func() ([]Orphan, error) { return e.orphanedImages(ctx, usedImages, includeAMIs) },
+	}
+	for _, step := range steps {
+		orphans, err := step()
+		if err != nil {
+			return nil, err
+		}
+		scan.Orphans = append(scan.Orphans, orphans...)
+	}
+
+	return scan, nil
+}
+
+// liveInstances returns the instances in the region that are not terminated
+// or being terminated
+func (e *EC2Client) liveInstances(ctx context.Context) ([]types.Instance, error) {
+	result, err := e.client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("instance-state-name"),
+				Values: []string{"pending", "running", "stopping", "stopped"},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+
+	var instances []types.Instance
+	for _, reservation := range result.Reservations {
+		instances = append(instances, reservation.Instances...)
+	}
+	return instances, nil
+}
+
+// orphanedNATGateways returns lens NAT gateways that no live instance routes
+// through, together with the routes that point at them
+func (e *EC2Client) orphanedNATGateways(ctx context.Context, usedSubnets map[string]bool) ([]Orphan, error) {
+	natResult, err := e.client.DescribeNatGateways(ctx, &ec2.DescribeNatGatewaysInput{
+		Filter: []types.Filter{
+			{
+				Name:   aws.String("state"),
+				Values: []string{"pending", "available"},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list NAT Gateways: %w", err)
+	}
+
+	var lensNATs []types.NatGateway
+	for _, nat := range natResult.NatGateways {
+		if IsLensCreatedBy(tagValue(nat.Tags, TagCreatedBy)) {
+			lensNATs = append(lensNATs, nat)
+		}
+	}
+	if len(lensNATs) == 0 {
+		return nil, nil
+	}
+
+	tableResult, err := e.client.DescribeRouteTables(ctx, &ec2.DescribeRouteTablesInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list route tables: %w", err)
+	}
+	subnetResult, err := e.client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subnets: %w", err)
+	}
+
+	usedNATs := make(map[string]bool)
+	for subnetID, natIDs := range natGatewaysBySubnet(tableResult.RouteTables, subnetResult.Subnets) {
+		if !usedSubnets[subnetID] {
+			continue
+		}
+		for _, natID := range natIDs {
+			usedNATs[natID] = true
+		}
+	}
+
+	var orphans []Orphan
+	for _, nat := range lensNATs {
+		natID := aws.ToString(nat.NatGatewayId)
+		if usedNATs[natID] {
+			continue
+		}
+		orphans = append(orphans, Orphan{
+			Resource: Resource{Kind: ResourceNATGateway, ID: natID, Region: e.region},
+			Name:     tagValue(nat.Tags, "Name"),
+			Reason:   "no live instance routes through it",
+		})
+		for _, table := range tableResult.RouteTables {
+			for _, route := range table.Routes {
+				if aws.ToString(route.NatGatewayId) == natID && route.DestinationCidrBlock != nil {
+					orphans = append(orphans, Orphan{
+						Resource: Resource{
+							Kind:   ResourceRoute,
+							ID:     *route.DestinationCidrBlock,
+							Region: e.region,
+							Parent: aws.ToString(table.RouteTableId),
+						},
+						Reason: fmt.Sprintf("routes through %s", natID),
+					})
+				}
+			}
+		}
+	}
+	return orphans, nil
+}
+
+// natGatewaysBySubnet returns the NAT gateways each subnet routes through.
+// A subnet uses its explicitly associated route table, or else the main
+// route table of its VPC.
+func natGatewaysBySubnet(tables []types.RouteTable, subnets []types.Subnet) map[string][]string {
+	explicit := make(map[string]types.RouteTable)
+	mainTables := make(map[string]types.RouteTable)
+	for _, table := range tables {
+		for _, assoc := range table.Associations {
+			if aws.ToBool(assoc.Main) {
+				mainTables[aws.ToString(table.VpcId)] = table
+			} else if assoc.SubnetId != nil {
+				explicit[*assoc.SubnetId] = table
+			}
+		}
+	}
+
+	bySubnet := make(map[string][]string)
+	for _, subnet := range subnets {
+		subnetID := aws.ToString(subnet.SubnetId)
+		table, ok := explicit[subnetID]
+		if !ok {
+			table = mainTables[aws.ToString(subnet.VpcId)]
+		}
+		for _, route := range table.Routes {
+			if route.NatGatewayId != nil {
+				bySubnet[subnetID] = append(bySubnet[subnetID], *route.NatGatewayId)
+			}
+		}
+	}
+	return bySubnet
+}
+
+// orphanedAddresses returns lens Elastic IPs that are not associated with
+// anything, or that belong to an orphaned NAT gateway
+func (e *EC2Client) orphanedAddresses(ctx context.Context, natOrphans []Orphan) ([]Orphan, error) {
+	result, err := e.client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Elastic IPs: %w", err)
+	}
+
+	orphanedNATs := make(map[string]bool)
+	for _, orphan := range natOrphans {
+		if orphan.Kind == ResourceNATGateway {
+			orphanedNATs[orphan.ID] = true
+		}
+	}
+	natAddresses := make(map[string]string)
+	if len(orphanedNATs) > 0 {
+		natResult, err := e.client.DescribeNatGateways(ctx, &ec2.DescribeNatGatewaysInput{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list NAT Gateways: %w", err)
+		}
+		for _, nat := range natResult.NatGateways {
+			if !orphanedNATs[aws.ToString(nat.NatGatewayId)] {
+				continue
+			}
+			for _, address := range nat.NatGatewayAddresses {
+				natAddresses[aws.ToString(address.AllocationId)] = aws.ToString(nat.NatGatewayId)
+			}
+		}
+	}
+
+	var orphans []Orphan
+	for _, address := range result.Addresses {
+		if !IsLensCreatedBy(tagValue(address.Tags, TagCreatedBy)) {
+			continue
+		}
+		allocationID := aws.ToString(address.AllocationId)
+		reason := ""
+		if natID, ok := natAddresses[allocationID]; ok {
+			reason = fmt.Sprintf("attached to %s", natID)
+		} else if address.AssociationId == nil {
+			reason = "not associated"
+		} else {
+			continue
+		}
+		orphans = append(orphans, Orphan{
+			Resource: Resource{Kind: ResourceElasticIP, ID: allocationID, Region: e.region},
+			Name:     aws.ToString(address.PublicIp),
+			Reason:   reason,
+		})
+	}
+	return orphans, nil
+}
+
+// orphanedSecurityGroups returns lens security groups no live instance uses
+func (e *EC2Client) orphanedSecurityGroups(ctx context.Context, used map[string]bool) ([]Orphan, error) {
+	result, err := e.client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list security groups: %w", err)
+	}
+
+	var orphans []Orphan
+	for _, group := range result.SecurityGroups {
+		name := aws.ToString(group.GroupName)
+		if !IsLensCreatedBy(tagValue(group.Tags, TagCreatedBy)) && !IsLensSecurityGroup(name) {
+			continue
+		}
+		if used[aws.ToString(group.GroupId)] {
+			continue
+		}
+		orphans = append(orphans, Orphan{
+			Resource: Resource{Kind: ResourceSecurityGroup, ID: aws.ToString(group.GroupId), Region: e.region},
+			Name:     name,
+			Reason:   "no live instance uses it",
+		})
+	}
+	return orphans, nil
+}
+
+// orphanedKeyPairs returns lens key pairs no live instance uses
+func (e *EC2Client) orphanedKeyPairs(ctx context.Context, used map[string]bool) ([]Orphan, error) {
+	result, err := e.client.DescribeKeyPairs(ctx, &ec2.DescribeKeyPairsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list key pairs: %w", err)
+	}
+
+	var orphans []Orphan
+	for _, key := range result.KeyPairs {
+		name := aws.ToString(key.KeyName)
+		if !IsLensCreatedBy(tagValue(key.Tags, TagCreatedBy)) && !IsAwsJupyterKey(name) {
+			continue
+		}
+		if used[name] {
+			continue
+		}
+		orphans = append(orphans, Orphan{
+			Resource: Resource{Kind: ResourceKeyPair, ID: name, Region: e.region},
+			Reason:   "no live instance uses it",
+		})
+	}
+	return orphans, nil
+}
+
+// orphanedImages returns lens snapshots whose AMI no longer exists and, with
+// includeAMIs, lens AMIs that no live instance was launched from. Snapshots
+// of a reported AMI are deleted along with it and are not listed separately.
+func (e *EC2Client) orphanedImages(ctx context.Context, used map[string]bool, includeAMIs bool) ([]Orphan, error) {
+	imageResult, err := e.client.DescribeImages(ctx, &ec2.DescribeImagesInput{
+		Owners: []string{"self"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list AMIs: %w", err)
+	}
+
+	var orphans []Orphan
+	imageSnapshots := make(map[string]bool)
+	for _, image := range imageResult.Images {
+		size := 0
+		for _, mapping := range image.BlockDeviceMappings {
+			if mapping.Ebs != nil && mapping.Ebs.SnapshotId != nil {
+				imageSnapshots[*mapping.Ebs.SnapshotId] = true
+				size += int(aws.ToInt32(mapping.Ebs.VolumeSize))
+			}
+		}
+
+		imageID := aws.ToString(image.ImageId)
+		if !includeAMIs || used[imageID] || !IsLensCreatedBy(tagValue(image.Tags, TagCreatedBy)) {
+			continue
+		}
+		orphans = append(orphans, Orphan{
+			Resource: Resource{Kind: ResourceAMI, ID: imageID, Region: e.region},
+			Name:     aws.ToString(image.Name),
+			Reason:   "no live instance was launched from it",
+			SizeGB:   size,
+		})
+	}
+
+	snapshotResult, err := e.client.DescribeSnapshots(ctx, &ec2.DescribeSnapshotsInput{
+		OwnerIds: []string{"self"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	for _, snapshot := range snapshotResult.Snapshots {
+		snapshotID := aws.ToString(snapshot.SnapshotId)
+		if imageSnapshots[snapshotID] || !IsLensCreatedBy(tagValue(snapshot.Tags, TagCreatedBy)) {
+			continue
+		}
+		// Backups are snapshots taken on purpose
+		if tagValue(snapshot.Tags, TagBackup) != "" {
+			continue
+		}
+		orphans = append(orphans, Orphan{
+			Resource: Resource{Kind: ResourceSnapshot, ID: snapshotID, Region: e.region},
+			Name:     tagValue(snapshot.Tags, "Name"),
+			Reason:   "its AMI no longer exists",
+			SizeGB:   int(aws.ToInt32(snapshot.VolumeSize)),
+		})
+	}
+	return orphans, nil
+}
+
+// FindOrphanedRoles returns the lens Session Manager roles and instance
+// profiles ("<app>-session-manager-role" and "-profile") whose instance
+// profile is not attached to any of the given live instance profile ARNs.
+// The instance profile is listed before its role so that deleting in order
+// succeeds.
+func (i *IAMClient) FindOrphanedRoles(ctx context.Context, liveProfileARNs []string) ([]Orphan, error) {
+	live := make(map[string]bool)
+	for _, arn := range liveProfileARNs {
+		live[arn[strings.LastIndex(arn, "/")+1:]] = true
+	}
+
+	var orphans []Orphan
+	paginator := iam.NewListRolesPaginator(i.client, &iam.ListRolesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list IAM roles: %w", err)
+		}
+
+		for _, role := range page.Roles {
+			roleName := aws.ToString(role.RoleName)
+			appPrefix, ok := strings.CutSuffix(roleName, "-session-manager-role")
+			if !ok || !strings.HasPrefix(appPrefix, "lens-") {
+				continue
+			}
+
+			// ListRoles does not return tags, so confirm the role is ours
+			result, err := i.client.GetRole(ctx, &iam.GetRoleInput{RoleName: role.RoleName})
+			if err != nil {
+				if IsNotFound(err) {
+					continue
+				}
+				return nil, fmt.Errorf("failed to get IAM role %s: %w", roleName, err)
+			}
+			createdBy := ""
+			for _, tag := range result.Role.Tags {
+				if aws.ToString(tag.Key) == TagCreatedBy {
+					createdBy = aws.ToString(tag.Value)
+				}
+			}
+			if !IsLensCreatedBy(createdBy) {
+				continue
+			}
+
+			profileName := appPrefix + "-session-manager-profile"
+			if live[profileName] {
+				continue
+			}
+			exists, _, err := i.instanceProfileExists(ctx, profileName)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check instance profile %s: %w", profileName, err)
+			}
+			if exists {
+				orphans = append(orphans, Orphan{
+					Resource: Resource{Kind: ResourceInstanceProfile, ID: profileName},
+					Reason:   "no live instance uses it",
+				})
+			}
+			orphans = append(orphans, Orphan{
+				Resource: Resource{Kind: ResourceIAMRole, ID: roleName},
+				Reason:   "no live instance uses its instance profile",
+			})
+		}
+	}
+	return orphans, nil
+}
+
+// tagValue returns the value of an EC2 tag, or "" if it is not set
+func tagValue(tags []types.Tag, key string) string {
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == key {
+			return aws.ToString(tag.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,132 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/pricing"
+	"github.com/aws/aws-sdk-go-v2/service/pricing/types"
+)
+
+// pricingRegion is a region that serves the Price List API. Prices of every
+// region are available from it.
+const pricingRegion = "us-east-1"
+
+// ErrPriceNotFound is returned by OnDemandHourlyPrice when the Price List API
+// has no matching product
+var ErrPriceNotFound = errors.New("no matching price in the AWS Price List")
+
+// PricingClient wraps the AWS Price List API
+type PricingClient struct {
+	client PricingAPI
+}
+
+// NewPricingClientWithAPI creates a pricing client backed by the given API implementation
+func NewPricingClientWithAPI(api PricingAPI) *PricingClient {
+	return &PricingClient{client: api}
+}
+
+// NewPricingClient creates a new Price List API client for the given profile
+func NewPricingClient(ctx context.Context, profile string) (*PricingClient, error) {
+	if p := activeProvider(); p != nil {
+		return NewPricingClientWithAPI(p.Pricing()), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithSharedConfigProfile(profile),
+		config.WithRegion(pricingRegion),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &PricingClient{client: pricing.NewFromConfig(cfg)}, nil
+}
+
+// LicenseModel returns the Price List license model of an operating system
+func LicenseModel(operatingSystem string) string {
+	if operatingSystem == "Linux" {
+		return "No License required"
+	}
+	return "License Included"
+}
+
+// OnDemandHourlyPrice returns the on-demand hourly USD price of a shared
+// tenancy instance type in a region. operatingSystem is a Price List value
+// such as "Linux" or "Windows".
+func (p *PricingClient) OnDemandHourlyPrice(ctx context.Context, instanceType, region, operatingSystem string) (float64, error) {
+	filter := func(field, value string) types.Filter {
+		return types.Filter{Type: types.FilterTypeTermMatch, Field: aws.String(field), Value: aws.String(value)}
+	}
+
+	result, err := p.client.GetProducts(ctx, &pricing.GetProductsInput{
+		ServiceCode: aws.String("AmazonEC2"),
+		Filters: []types.Filter{
+			filter("instanceType", instanceType),
+			filter("regionCode", region),
+			filter("operatingSystem", operatingSystem),
+			filter("licenseModel", LicenseModel(operatingSystem)),
+			filter("tenancy", "Shared"),
+			filter("preInstalledSw", "NA"),
+			filter("capacitystatus", "Used"),
+		},
+		MaxResults: aws.Int32(10),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get products: %w", err)
+	}
+
+	for _, document := range result.PriceList {
+		price, ok, err := onDemandHourlyUSD(document)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return price, nil
+		}
+	}
+	return 0, ErrPriceNotFound
+}
+
+// priceListProduct is the part of a Price List product document that holds
+// on-demand prices
+type priceListProduct struct {
+	Terms struct {
+		OnDemand map[string]struct {
+			PriceDimensions map[string]struct {
+				Unit         string            `json:"unit"`
+				PricePerUnit map[string]string `json:"pricePerUnit"`
+			} `json:"priceDimensions"`
+		} `json:"OnDemand"`
+	} `json:"terms"`
+}
+
+// onDemandHourlyUSD returns the hourly USD price of a product document
+func onDemandHourlyUSD(document string) (float64, bool, error) {
+	var product priceListProduct
+	if err := json.Unmarshal([]byte(document), &product); err != nil {
+		return 0, false, fmt.Errorf("failed to parse price list product: %w", err)
+	}
+
+	for _, term := range product.Terms.OnDemand {
+		for _, dimension := range term.PriceDimensions {
+			if dimension.Unit != "Hrs" {
+				continue
+			}
+			usd, ok := dimension.PricePerUnit["USD"]
+			if !ok {
+				continue
+			}
+			price, err := strconv.ParseFloat(usd, 64)
+			if err != nil {
+				return 0, false, fmt.Errorf("invalid price %q: %w", usd, err)
+			}
+			return price, true, nil
+		}
+	}
+	return 0, false, nil
+}
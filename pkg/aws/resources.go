@@ -0,0 +1,277 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/smithy-go"
+)
+
+// ResourceKind identifies a type of AWS resource created by lens
+type ResourceKind string
+
+const (
+	ResourceInstance        ResourceKind = "instance"
+	ResourceKeyPair         ResourceKind = "key-pair"
+	ResourceSecurityGroup   ResourceKind = "security-group"
+	ResourceElasticIP       ResourceKind = "elastic-ip"
+	ResourceNATGateway      ResourceKind = "nat-gateway"
+	ResourceRoute           ResourceKind = "route"
+	ResourceIAMRole         ResourceKind = "iam-role"
+	ResourceInstanceProfile ResourceKind = "instance-profile"
+	ResourceAMI             ResourceKind = "ami"
+	ResourceSnapshot        ResourceKind = "snapshot"
+	ResourceVolume          ResourceKind = "volume"
+	ResourceAlarm           ResourceKind = "alarm"
+	ResourceSchedule        ResourceKind = "schedule"
+)
+
+// Resource identifies a single AWS resource created by a client
+type Resource struct {
+	Kind   ResourceKind `json:"kind"`
+	ID     string       `json:"id"`
+	Region string       `json:"region,omitempty"`
+	// Parent is the resource that contains this one, where deleting it needs
+	// both (the route table of a route)
+	Parent string `json:"parent,omitempty"`
+}
+
+// String returns a short human-readable description of the resource
+func (r Resource) String() string {
+	if r.Parent != "" {
+		return fmt.Sprintf("%s %s (%s)", r.Kind, r.ID, r.Parent)
+	}
+	return fmt.Sprintf("%s %s", r.Kind, r.ID)
+}
+
+// IsIAM reports whether the resource is a global IAM resource
+func (r Resource) IsIAM() bool {
+	return r.Kind == ResourceIAMRole || r.Kind == ResourceInstanceProfile
+}
+
+// ResourceRecorder is notified immediately after a client creates a resource,
+// before any further calls that could fail. It is used to journal launches so
+// that partially created resources can be rolled back.
+type ResourceRecorder interface {
+	RecordResource(resource Resource) error
+}
+
+// SetResourceRecorder registers a recorder for resources created by this client.
+// Pass nil to stop recording.
+func (e *EC2Client) SetResourceRecorder(recorder ResourceRecorder) {
+	e.recorder = recorder
+}
+
+// record reports a created resource to the recorder, if any
+func (e *EC2Client) record(kind ResourceKind, id, parent string) {
+	if e.recorder == nil || id == "" {
+		return
+	}
+	resource := Resource{Kind: kind, ID: id, Region: e.region, Parent: parent}
+	if err := e.recorder.RecordResource(resource); err != nil {
+		fmt.Printf("Warning: Failed to record %s: %v\n", resource, err)
+	}
+}
+
+// SetResourceRecorder registers a recorder for resources created by this client.
+// Pass nil to stop recording.
+func (i *IAMClient) SetResourceRecorder(recorder ResourceRecorder) {
+	i.recorder = recorder
+}
+
+// record reports a created resource to the recorder, if any
+func (i *IAMClient) record(kind ResourceKind, id string) {
+	if i.recorder == nil || id == "" {
+		return
+	}
+	resource := Resource{Kind: kind, ID: id}
+	if err := i.recorder.RecordResource(resource); err != nil {
+		fmt.Printf("Warning: Failed to record %s: %v\n", resource, err)
+	}
+}
+
+// DeleteResource deletes an EC2 resource previously reported to a recorder.
+// Instances and NAT gateways are waited on until they are gone, so that the
+// resources they depend on can be deleted next. A resource that no longer
+// exists is not an error.
+func (e *EC2Client) DeleteResource(ctx context.Context, resource Resource) error {
+	var err error
+	switch resource.Kind {
+	case ResourceInstance:
+		if err = e.TerminateInstance(ctx, resource.ID); err == nil {
+			err = e.WaitForInstanceTerminated(ctx, resource.ID)
+		}
+	case ResourceKeyPair:
+		_, err = e.client.DeleteKeyPair(ctx, &ec2.DeleteKeyPairInput{
+			KeyName: aws.String(resource.ID),
+		})
+	case ResourceSecurityGroup:
+		_, err = e.client.DeleteSecurityGroup(ctx, &ec2.DeleteSecurityGroupInput{
+			GroupId: aws.String(resource.ID),
+		})
+	case ResourceRoute:
+		_, err = e.client.DeleteRoute(ctx, &ec2.DeleteRouteInput{
+			RouteTableId:         aws.String(resource.Parent),
+			DestinationCidrBlock: aws.String(resource.ID),
+		})
+	case ResourceNATGateway:
+		_, err = e.client.DeleteNatGateway(ctx, &ec2.DeleteNatGatewayInput{
+			NatGatewayId: aws.String(resource.ID),
+		})
+		if err == nil {
+			err = e.waitForNATGatewayDeleted(ctx, resource.ID)
+		}
+	case ResourceElasticIP:
+		_, err = e.client.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{
+			AllocationId: aws.String(resource.ID),
+		})
+	case ResourceAMI:
+		err = e.DeleteAMI(ctx, resource.ID)
+	case ResourceSnapshot:
+		_, err = e.client.DeleteSnapshot(ctx, &ec2.DeleteSnapshotInput{
+			SnapshotId: aws.String(resource.ID),
+		})
+	case ResourceVolume:
+		err = e.DeleteDataVolume(ctx, resource.ID)
+	default:
+		return fmt.Errorf("cannot delete %s with the EC2 client", resource.Kind)
+	}
+
+	if err != nil && !IsNotFound(err) {
+		return fmt.Errorf("failed to delete %s: %w", resource, err)
+	}
+	return nil
+}
+
+// WaitForInstanceTerminated waits for an EC2 instance to reach the terminated state with a 5 minute timeout
+func (e *EC2Client) WaitForInstanceTerminated(ctx context.Context, instanceID string) error {
+	waiter := ec2.NewInstanceTerminatedWaiter(e.client)
+	return waiter.Wait(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: []string{instanceID},
+	}, 5*time.Minute)
+}
+
+// waitForNATGatewayDeleted waits for a NAT Gateway to finish deleting so its Elastic IP can be released
+func (e *EC2Client) waitForNATGatewayDeleted(ctx context.Context, natGatewayID string) error {
+	deadline := time.Now().Add(5 * time.Minute)
+	for {
+		result, err := e.client.DescribeNatGateways(ctx, &ec2.DescribeNatGatewaysInput{
+			NatGatewayIds: []string{natGatewayID},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to check NAT Gateway status: %w", err)
+		}
+		if len(result.NatGateways) == 0 || result.NatGateways[0].State == types.NatGatewayStateDeleted {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout waiting for NAT Gateway to be deleted")
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(15 * time.Second):
+		}
+	}
+}
+
+// DeleteResource deletes an IAM resource previously reported to a recorder.
+// Roles are removed from instance profiles before the profile is deleted, and
+// policies are detached before a role is deleted. A resource that no longer
+// exists is not an error.
+func (i *IAMClient) DeleteResource(ctx context.Context, resource Resource) error {
+	var err error
+	switch resource.Kind {
+	case ResourceInstanceProfile:
+		err = i.deleteInstanceProfile(ctx, resource.ID)
+	case ResourceIAMRole:
+		err = i.deleteRole(ctx, resource.ID)
+	default:
+		return fmt.Errorf("cannot delete %s with the IAM client", resource.Kind)
+	}
+
+	if err != nil && !IsNotFound(err) {
+		return fmt.Errorf("failed to delete %s: %w", resource, err)
+	}
+	return nil
+}
+
+// deleteInstanceProfile removes all roles from an instance profile and deletes it
+func (i *IAMClient) deleteInstanceProfile(ctx context.Context, profileName string) error {
+	result, err := i.client.GetInstanceProfile(ctx, &iam.GetInstanceProfileInput{
+		InstanceProfileName: aws.String(profileName),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, role := range result.InstanceProfile.Roles {
+		if _, err := i.client.RemoveRoleFromInstanceProfile(ctx, &iam.RemoveRoleFromInstanceProfileInput{
+			InstanceProfileName: aws.String(profileName),
+			RoleName:            role.RoleName,
+		}); err != nil && !IsNotFound(err) {
+			return err
+		}
+	}
+
+	_, err = i.client.DeleteInstanceProfile(ctx, &iam.DeleteInstanceProfileInput{
+		InstanceProfileName: aws.String(profileName),
+	})
+	return err
+}
+
+// deleteRole detaches managed policies, deletes inline policies and deletes the role
+func (i *IAMClient) deleteRole(ctx context.Context, roleName string) error {
+	attached, err := i.client.ListAttachedRolePolicies(ctx, &iam.ListAttachedRolePoliciesInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		return err
+	}
+	for _, policy := range attached.AttachedPolicies {
+		if _, err := i.client.DetachRolePolicy(ctx, &iam.DetachRolePolicyInput{
+			RoleName:  aws.String(roleName),
+			PolicyArn: policy.PolicyArn,
+		}); err != nil && !IsNotFound(err) {
+			return err
+		}
+	}
+
+	inline, err := i.client.ListRolePolicies(ctx, &iam.ListRolePoliciesInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		return err
+	}
+	for _, policyName := range inline.PolicyNames {
+		if _, err := i.client.DeleteRolePolicy(ctx, &iam.DeleteRolePolicyInput{
+			RoleName:   aws.String(roleName),
+			PolicyName: aws.String(policyName),
+		}); err != nil && !IsNotFound(err) {
+			return err
+		}
+	}
+
+	_, err = i.client.DeleteRole(ctx, &iam.DeleteRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	return err
+}
+
+// IsNotFound reports whether err is an AWS error saying the resource does not exist
+func IsNotFound(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	code := apiErr.ErrorCode()
+	return code == "NoSuchEntity" || code == "ResourceNotFoundException" || strings.HasSuffix(code, "NotFound")
+}
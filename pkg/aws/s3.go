@@ -0,0 +1,149 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// ErrPreconditionFailed is returned by PutObject when the object was changed
+// since it was read, or already exists when it was expected not to
+var ErrPreconditionFailed = errors.New("object was modified concurrently")
+
+// ErrObjectNotFound is returned by GetObject when the object does not exist
+var ErrObjectNotFound = errors.New("object not found")
+
+// S3Client wraps the S3 operations used for shared state
+type S3Client struct {
+	client S3API
+	region string
+}
+
+// S3Options configures the S3 client
+type S3Options struct {
+	Profile  string
+	Region   string // Bucket region; the profile's region if empty
+	Endpoint string // Custom endpoint for S3-compatible stores (e.g. MinIO); uses path-style addressing
+}
+
+// NewS3ClientWithAPI creates an S3 client backed by the given API implementation
+func NewS3ClientWithAPI(api S3API, region string) *S3Client {
+	return &S3Client{client: api, region: region}
+}
+
+// NewS3Client creates a new S3 client
+func NewS3Client(ctx context.Context, opts S3Options) (*S3Client, error) {
+	if p := activeProvider(); p != nil {
+		region := providerRegion(p, opts.Region)
+		return NewS3ClientWithAPI(p.S3(region), region), nil
+	}
+
+	loadOpts := []func(*config.LoadOptions) error{
+		config.WithSharedConfigProfile(opts.Profile),
+	}
+	if opts.Region != "" {
+		loadOpts = append(loadOpts, config.WithRegion(opts.Region))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if opts.Endpoint != "" {
+			o.BaseEndpoint = aws.String(opts.Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	return &S3Client{client: client, region: cfg.Region}, nil
+}
+
+// GetObject returns the content and ETag of an object. It returns
+// ErrObjectNotFound when the object does not exist.
+func (s *S3Client) GetObject(ctx context.Context, bucket, key string) ([]byte, string, error) {
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if hasErrorCode(err, "NoSuchKey", "NotFound") {
+			return nil, "", ErrObjectNotFound
+		}
+		return nil, "", fmt.Errorf("failed to get s3://%s/%s: %w", bucket, key, err)
+	}
+	defer result.Body.Close()
+
+	data, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read s3://%s/%s: %w", bucket, key, err)
+	}
+	return data, aws.ToString(result.ETag), nil
+}
+
+// PutObject writes an object only if it is unchanged since it was read.
+// ifMatch is the ETag returned by GetObject; an empty ifMatch writes the
+// object only if it does not exist yet. It returns ErrPreconditionFailed when
+// another writer got there first, and the new ETag otherwise.
+func (s *S3Client) PutObject(ctx context.Context, bucket, key string, data []byte, ifMatch string) (string, error) {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        strings.NewReader(string(data)),
+		ContentType: aws.String("application/json"),
+	}
+	if ifMatch != "" {
+		input.IfMatch = aws.String(ifMatch)
+	} else {
+		input.IfNoneMatch = aws.String("*")
+	}
+
+	result, err := s.client.PutObject(ctx, input)
+	if err != nil {
+		// A conditional write on a deleted object fails with NoSuchKey
+		if hasErrorCode(err, "PreconditionFailed", "ConditionalRequestConflict", "NoSuchKey") {
+			return "", ErrPreconditionFailed
+		}
+		return "", fmt.Errorf("failed to put s3://%s/%s: %w", bucket, key, err)
+	}
+	return aws.ToString(result.ETag), nil
+}
+
+// ListObjects returns the keys of all objects under prefix
+func (s *S3Client) ListObjects(ctx context.Context, bucket, prefix string) ([]string, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list s3://%s/%s: %w", bucket, prefix, err)
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+	}
+	return keys, nil
+}
+
+// hasErrorCode reports whether err is an API error with one of the given codes
+func hasErrorCode(err error, codes ...string) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, code := range codes {
+		if apiErr.ErrorCode() == code {
+			return true
+		}
+	}
+	return false
+}
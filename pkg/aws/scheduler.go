@@ -0,0 +1,357 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler/types"
+	"github.com/aws/smithy-go"
+)
+
+// ScheduleGroup is the EventBridge Scheduler group of every lens schedule
+const ScheduleGroup = "lens"
+
+// Actions of instance schedules
+const (
+	ScheduleActionStart = "start"
+	ScheduleActionStop  = "stop"
+)
+
+// scheduleTargets are the EventBridge Scheduler universal targets that run
+// each action
+var scheduleTargets = map[string]string{
+	ScheduleActionStart: "arn:aws:scheduler:::aws-sdk:ec2:startInstances",
+	ScheduleActionStop:  "arn:aws:scheduler:::aws-sdk:ec2:stopInstances",
+}
+
+// schedulerRoleRetryDelay is how long to wait before retrying a schedule
+// whose newly created role EventBridge Scheduler cannot assume yet
+var schedulerRoleRetryDelay = 5 * time.Second
+
+// ScheduleDefinition is an EventBridge Scheduler schedule with a universal target
+type ScheduleDefinition struct {
+	Name        string
+	GroupName   string
+	Expression  string // e.g. "cron(0 8 ? * MON-FRI *)"
+	Timezone    string // IANA name, e.g. "America/New_York"
+	Description string
+	Enabled     bool
+	TargetArn   string
+	RoleArn     string // Role the schedule assumes to call the target
+	Input       string // JSON parameters of the target call
+}
+
+// InstanceSchedule is a schedule that starts or stops one instance
+type InstanceSchedule struct {
+	Name       string
+	InstanceID string
+	Action     string // ScheduleActionStart or ScheduleActionStop
+	Expression string
+	Timezone   string
+	Enabled    bool
+}
+
+// InstanceScheduleName returns the name of the schedule that runs an action
+// on an instance
+func InstanceScheduleName(instanceID, action string) string {
+	return instanceID + "-" + action
+}
+
+// SchedulerClient wraps the EventBridge Scheduler operations used for
+// instance start/stop schedules
+type SchedulerClient struct {
+	client SchedulerAPI
+	region string
+}
+
+// NewSchedulerClientWithAPI creates a Scheduler client backed by the given API implementation
+func NewSchedulerClientWithAPI(api SchedulerAPI, region string) *SchedulerClient {
+	return &SchedulerClient{client: api, region: region}
+}
+
+// NewSchedulerClient creates a Scheduler client for a region using the
+// specified AWS profile
+func NewSchedulerClient(ctx context.Context, profile, region string) (*SchedulerClient, error) {
+	if p := activeProvider(); p != nil {
+		region = providerRegion(p, region)
+		return NewSchedulerClientWithAPI(p.Scheduler(region), region), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithSharedConfigProfile(profile),
+		config.WithRegion(region),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &SchedulerClient{client: scheduler.NewFromConfig(cfg), region: cfg.Region}, nil
+}
+
+// NewSchedulerClientForRegion creates a Scheduler client for a region using
+// the default credential chain
+func NewSchedulerClientForRegion(ctx context.Context, region string) (*SchedulerClient, error) {
+	if p := activeProvider(); p != nil {
+		region = providerRegion(p, region)
+		return NewSchedulerClientWithAPI(p.Scheduler(region), region), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(region),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &SchedulerClient{client: scheduler.NewFromConfig(cfg), region: cfg.Region}, nil
+}
+
+// GetRegion returns the region of the client
+func (c *SchedulerClient) GetRegion() string {
+	return c.region
+}
+
+// PutInstanceSchedule creates or replaces the schedule that runs an action on
+// an instance at the times of an EventBridge cron expression
+func (c *SchedulerClient) PutInstanceSchedule(ctx context.Context, instanceID, action, expression, timezone, roleARN, app string) (*InstanceSchedule, error) {
+	target, ok := scheduleTargets[action]
+	if !ok {
+		return nil, fmt.Errorf("invalid schedule action %q", action)
+	}
+	if err := c.ensureGroup(ctx, app); err != nil {
+		return nil, err
+	}
+
+	schedule := ScheduleDefinition{
+		Name:        InstanceScheduleName(instanceID, action),
+		GroupName:   ScheduleGroup,
+		Expression:  expression,
+		Timezone:    timezone,
+		Description: fmt.Sprintf("%s %s, created by %s", strings.ToUpper(action[:1])+action[1:], instanceID, app),
+		Enabled:     true,
+		TargetArn:   target,
+		RoleArn:     roleARN,
+		Input:       fmt.Sprintf(`{"InstanceIds":[%q]}`, instanceID),
+	}
+	input := scheduleInput(schedule)
+	for attempt := 0; ; attempt++ {
+		_, err := c.client.CreateSchedule(ctx, input)
+		if isSchedulerError(err, "ConflictException") {
+			// UpdateSchedule takes the same fields and replaces them all
+			update := scheduler.UpdateScheduleInput(*input)
+			_, err = c.client.UpdateSchedule(ctx, &update)
+		}
+		// A new role takes a few seconds to become assumable
+		if isSchedulerError(err, "ValidationException") && strings.Contains(err.Error(), "role") && attempt < 5 {
+			time.Sleep(schedulerRoleRetryDelay)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s schedule for %s: %w", action, instanceID, err)
+		}
+		break
+	}
+	return &InstanceSchedule{
+		Name:       schedule.Name,
+		InstanceID: instanceID,
+		Action:     action,
+		Expression: expression,
+		Timezone:   timezone,
+		Enabled:    true,
+	}, nil
+}
+
+// ensureGroup creates the lens schedule group if it does not exist
+func (c *SchedulerClient) ensureGroup(ctx context.Context, app string) error {
+	_, err := c.client.CreateScheduleGroup(ctx, &scheduler.CreateScheduleGroupInput{
+		Name: aws.String(ScheduleGroup),
+		Tags: []types.Tag{{Key: aws.String(TagCreatedBy), Value: aws.String(app + "-cli")}},
+	})
+	if err != nil && !isSchedulerError(err, "ConflictException") {
+		return fmt.Errorf("failed to create schedule group %s: %w", ScheduleGroup, err)
+	}
+	return nil
+}
+
+// InstanceSchedules returns the schedules of an instance
+func (c *SchedulerClient) InstanceSchedules(ctx context.Context, instanceID string) ([]InstanceSchedule, error) {
+	var schedules []InstanceSchedule
+	for _, action := range []string{ScheduleActionStart, ScheduleActionStop} {
+		schedule, err := c.getSchedule(ctx, InstanceScheduleName(instanceID, action))
+		if IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get schedules of %s: %w", instanceID, err)
+		}
+		schedules = append(schedules, instanceSchedule(*schedule))
+	}
+	return schedules, nil
+}
+
+// ListInstanceSchedules returns every lens schedule in the client's region
+func (c *SchedulerClient) ListInstanceSchedules(ctx context.Context) ([]InstanceSchedule, error) {
+	var schedules []InstanceSchedule
+	paginator := scheduler.NewListSchedulesPaginator(c.client, &scheduler.ListSchedulesInput{
+		GroupName: aws.String(ScheduleGroup),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if IsNotFound(err) {
+			// The group is created with the first schedule
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list schedules: %w", err)
+		}
+		for _, summary := range page.Schedules {
+			name := aws.ToString(summary.Name)
+			schedule, err := c.getSchedule(ctx, name)
+			if IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to get schedule %s: %w", name, err)
+			}
+			schedules = append(schedules, instanceSchedule(*schedule))
+		}
+	}
+	return schedules, nil
+}
+
+// getSchedule returns a schedule in the lens group
+func (c *SchedulerClient) getSchedule(ctx context.Context, name string) (*ScheduleDefinition, error) {
+	out, err := c.client.GetSchedule(ctx, &scheduler.GetScheduleInput{
+		Name:      aws.String(name),
+		GroupName: aws.String(ScheduleGroup),
+	})
+	if err != nil {
+		return nil, err
+	}
+	schedule := &ScheduleDefinition{
+		Name:        aws.ToString(out.Name),
+		GroupName:   aws.ToString(out.GroupName),
+		Expression:  aws.ToString(out.ScheduleExpression),
+		Timezone:    aws.ToString(out.ScheduleExpressionTimezone),
+		Description: aws.ToString(out.Description),
+		Enabled:     out.State == types.ScheduleStateEnabled,
+	}
+	if out.Target != nil {
+		schedule.TargetArn = aws.ToString(out.Target.Arn)
+		schedule.RoleArn = aws.ToString(out.Target.RoleArn)
+		schedule.Input = aws.ToString(out.Target.Input)
+	}
+	return schedule, nil
+}
+
+// deleteSchedule deletes a schedule in the lens group
+func (c *SchedulerClient) deleteSchedule(ctx context.Context, name string) error {
+	_, err := c.client.DeleteSchedule(ctx, &scheduler.DeleteScheduleInput{
+		Name:      aws.String(name),
+		GroupName: aws.String(ScheduleGroup),
+	})
+	return err
+}
+
+// scheduleInput converts a schedule to the input of CreateSchedule. It runs
+// at the exact times of its expression.
+func scheduleInput(schedule ScheduleDefinition) *scheduler.CreateScheduleInput {
+	state := types.ScheduleStateDisabled
+	if schedule.Enabled {
+		state = types.ScheduleStateEnabled
+	}
+	return &scheduler.CreateScheduleInput{
+		Name:                       aws.String(schedule.Name),
+		GroupName:                  aws.String(schedule.GroupName),
+		ScheduleExpression:         aws.String(schedule.Expression),
+		ScheduleExpressionTimezone: aws.String(schedule.Timezone),
+		Description:                aws.String(schedule.Description),
+		State:                      state,
+		FlexibleTimeWindow:         &types.FlexibleTimeWindow{Mode: types.FlexibleTimeWindowModeOff},
+		Target: &types.Target{
+			Arn:     aws.String(schedule.TargetArn),
+			RoleArn: aws.String(schedule.RoleArn),
+			Input:   aws.String(schedule.Input),
+		},
+	}
+}
+
+// DeleteInstanceSchedules deletes the schedules of an instance and returns
+// how many there were
+func (c *SchedulerClient) DeleteInstanceSchedules(ctx context.Context, instanceID string) (int, error) {
+	deleted := 0
+	for _, action := range []string{ScheduleActionStart, ScheduleActionStop} {
+		err := c.deleteSchedule(ctx, InstanceScheduleName(instanceID, action))
+		if IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete %s schedule of %s: %w", action, instanceID, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// DeleteResource deletes a schedule created by lens
+func (c *SchedulerClient) DeleteResource(ctx context.Context, resource Resource) error {
+	if resource.Kind != ResourceSchedule {
+		return fmt.Errorf("cannot delete %s with the Scheduler client", resource.Kind)
+	}
+	err := c.deleteSchedule(ctx, resource.ID)
+	if err != nil && !IsNotFound(err) {
+		return fmt.Errorf("failed to delete schedule %s: %w", resource.ID, err)
+	}
+	return nil
+}
+
+// OrphanedSchedules returns the lens schedules in the client's region whose
+// instance is not among the live instances
+func (c *SchedulerClient) OrphanedSchedules(ctx context.Context, liveInstances []string) ([]Orphan, error) {
+	schedules, err := c.ListInstanceSchedules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	live := make(map[string]bool, len(liveInstances))
+	for _, id := range liveInstances {
+		live[id] = true
+	}
+
+	var orphans []Orphan
+	for _, schedule := range schedules {
+		if live[schedule.InstanceID] {
+			continue
+		}
+		orphans = append(orphans, Orphan{
+			Resource: Resource{Kind: ResourceSchedule, ID: schedule.Name, Region: c.region},
+			Reason:   fmt.Sprintf("instance %s no longer exists", schedule.InstanceID),
+		})
+	}
+	return orphans, nil
+}
+
+// instanceSchedule converts a schedule named by InstanceScheduleName
+func instanceSchedule(schedule ScheduleDefinition) InstanceSchedule {
+	instanceID, action := schedule.Name, ""
+	if i := strings.LastIndex(schedule.Name, "-"); i >= 0 {
+		instanceID, action = schedule.Name[:i], schedule.Name[i+1:]
+	}
+	return InstanceSchedule{
+		Name:       schedule.Name,
+		InstanceID: instanceID,
+		Action:     action,
+		Expression: schedule.Expression,
+		Timezone:   schedule.Timezone,
+		Enabled:    schedule.Enabled,
+	}
+}
+
+// isSchedulerError reports whether err is a Scheduler API error with a code
+func isSchedulerError(err error, code string) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == code
+}
@@ -0,0 +1,50 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler/types"
+)
+
+func TestScheduleInput(t *testing.T) {
+	input := scheduleInput(ScheduleDefinition{
+		Name:       "i-0123456789abcdef0-stop",
+		GroupName:  ScheduleGroup,
+		Expression: "cron(0 18 ? * MON-FRI *)",
+		Timezone:   "America/New_York",
+		Enabled:    true,
+		TargetArn:  scheduleTargets[ScheduleActionStop],
+		RoleArn:    "arn:aws:iam::123456789012:role/" + SchedulerRoleName,
+		Input:      `{"InstanceIds":["i-0123456789abcdef0"]}`,
+	})
+
+	if aws.ToString(input.GroupName) != ScheduleGroup || aws.ToString(input.ScheduleExpressionTimezone) != "America/New_York" {
+		t.Errorf("unexpected group or time zone: %s %s", aws.ToString(input.GroupName), aws.ToString(input.ScheduleExpressionTimezone))
+	}
+	if input.State != types.ScheduleStateEnabled {
+		t.Errorf("expected an enabled schedule, got %s", input.State)
+	}
+	if input.FlexibleTimeWindow == nil || input.FlexibleTimeWindow.Mode != types.FlexibleTimeWindowModeOff {
+		t.Error("expected the schedule to run at the exact times of its expression")
+	}
+	if input.Target == nil || aws.ToString(input.Target.Arn) != "arn:aws:scheduler:::aws-sdk:ec2:stopInstances" || aws.ToString(input.Target.Input) == "" {
+		t.Errorf("unexpected target: %+v", input.Target)
+	}
+
+	if disabled := scheduleInput(ScheduleDefinition{Name: "x"}); disabled.State != types.ScheduleStateDisabled {
+		t.Errorf("expected a disabled schedule, got %s", disabled.State)
+	}
+}
+
+func TestInstanceSchedule(t *testing.T) {
+	schedule := instanceSchedule(ScheduleDefinition{
+		Name:       InstanceScheduleName("i-0123456789abcdef0", ScheduleActionStart),
+		Expression: "cron(0 8 ? * MON-FRI *)",
+		Timezone:   "UTC",
+		Enabled:    true,
+	})
+	if schedule.InstanceID != "i-0123456789abcdef0" || schedule.Action != ScheduleActionStart {
+		t.Errorf("expected the start schedule of i-0123456789abcdef0, got %+v", schedule)
+	}
+}
@@ -174,6 +174,7 @@ func (e *EC2Client) CreateSecurityGroup(ctx context.Context, name, vpcID string)
 	}
 
 	sgID := aws.ToString(createResult.GroupId)
+	e.record(ResourceSecurityGroup, sgID, "")
 
 	_, err = e.client.AuthorizeSecurityGroupIngress(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
 		GroupId:       aws.String(sgID),
@@ -6,24 +6,91 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
 )
 
 // SSMClient wraps AWS Systems Manager operations
 type SSMClient struct {
-	client *ssm.Client
+	client SSMAPI
 	region string
 }
 
+// NewSSMClientWithAPI creates an SSM client backed by the given API implementation
+func NewSSMClientWithAPI(api SSMAPI, region string) *SSMClient {
+	return &SSMClient{
+		client: api,
+		region: region,
+	}
+}
+
 // NewSSMClient creates a new SSM client
 func NewSSMClient(cfg aws.Config) *SSMClient {
+	if p := activeProvider(); p != nil {
+		region := providerRegion(p, cfg.Region)
+		return NewSSMClientWithAPI(p.SSM(region), region)
+	}
+
 	return &SSMClient{
 		client: ssm.NewFromConfig(cfg),
 		region: cfg.Region,
 	}
 }
 
+// NewSSMClientForProfile creates a new SSM client using the specified AWS profile
+func NewSSMClientForProfile(ctx context.Context, profile string) (*SSMClient, error) {
+	if p := activeProvider(); p != nil {
+		return NewSSMClientWithAPI(p.SSM(p.DefaultRegion()), p.DefaultRegion()), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithSharedConfigProfile(profile),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSSMClient(cfg), nil
+}
+
+// NewSSMClientForRegion creates a new SSM client for a region with the
+// default credentials
+func NewSSMClientForRegion(ctx context.Context, region string) (*SSMClient, error) {
+	if p := activeProvider(); p != nil {
+		region = providerRegion(p, region)
+		return NewSSMClientWithAPI(p.SSM(region), region), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(region),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSSMClient(cfg), nil
+}
+
+// NewSSMClientForProfileRegion creates a new SSM client for a region using
+// the specified AWS profile
+func NewSSMClientForProfileRegion(ctx context.Context, profile, region string) (*SSMClient, error) {
+	if p := activeProvider(); p != nil {
+		region = providerRegion(p, region)
+		return NewSSMClientWithAPI(p.SSM(region), region), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithSharedConfigProfile(profile),
+		config.WithRegion(region),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSSMClient(cfg), nil
+}
+
 // CommandResult contains the result of an SSM command execution
 type CommandResult struct {
 	CommandID    string
@@ -0,0 +1,66 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// STSClient wraps the AWS Security Token Service client
+type STSClient struct {
+	client STSAPI
+}
+
+// CallerIdentity describes the AWS principal making requests
+type CallerIdentity struct {
+	Account string
+	ARN     string
+	UserID  string
+}
+
+// Name returns the last component of the principal's ARN: the user name for
+// IAM users and the session name for assumed roles
+func (c CallerIdentity) Name() string {
+	return c.ARN[strings.LastIndex(c.ARN, "/")+1:]
+}
+
+// NewSTSClientWithAPI creates an STS client backed by the given API implementation
+func NewSTSClientWithAPI(api STSAPI) *STSClient {
+	return &STSClient{client: api}
+}
+
+// NewSTSClient creates a new STS client using the specified AWS profile
+func NewSTSClient(ctx context.Context, profile string) (*STSClient, error) {
+	if p := activeProvider(); p != nil {
+		return NewSTSClientWithAPI(p.STS()), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithSharedConfigProfile(profile),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &STSClient{
+		client: sts.NewFromConfig(cfg),
+	}, nil
+}
+
+// GetCallerIdentity returns the identity of the credentials in use
+func (s *STSClient) GetCallerIdentity(ctx context.Context) (*CallerIdentity, error) {
+	result, err := s.client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %w", err)
+	}
+
+	return &CallerIdentity{
+		Account: aws.ToString(result.Account),
+		ARN:     aws.ToString(result.Arn),
+		UserID:  aws.ToString(result.UserId),
+	}, nil
+}
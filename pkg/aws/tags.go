@@ -0,0 +1,235 @@
+package aws
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// Instance tag keys. Name, CreatedBy and Environment predate the lens: prefix
+// and are kept for the IAM auto-stop condition and existing instances.
+const (
+	TagName        = "Name"
+	TagCreatedBy   = "CreatedBy"
+	TagEnvironment = "Environment"
+	TagApp         = "lens:app"
+	TagIdleTimeout = "lens:idle-timeout"
+	TagKeyPair     = "lens:key-pair"
+	TagAMIBase     = "lens:ami-base"
+	TagS3Bucket    = "lens:s3-bucket"
+	TagS3SyncPath  = "lens:s3-sync-path"
+	TagEBSSize     = "lens:ebs-size"
+	TagOwner       = "lens:owner"
+	TagProject     = "lens:project"
+	TagDataVolume  = "lens:data-volume"
+	TagIdleAlarm   = "lens:idle-alarm"
+	TagExpiresAt   = "lens:expires-at"
+)
+
+// TagInstance is set on instances and their volumes to the instance ID. Once
+// activated as a cost allocation tag in the Billing console, Cost Explorer
+// reports the billed cost of each instance under it.
+const TagInstance = "lens:instance"
+
+// TagSourceInstance is set on AMIs and snapshots created by create-ami, and
+// on backups, to the ID of the instance they were created from
+const TagSourceInstance = "lens:source-instance"
+
+// TagBackup marks snapshots taken by backup create and volumes snapshot with
+// the kind of volume they are of, "data" or "root"
+const TagBackup = "lens:backup"
+
+// defaultApp is the app assumed for instances launched before lens:app existed
+const defaultApp = "lens-jupyter"
+
+// InstanceMetadata is the lens metadata stored as tags on each instance, so
+// that local state can be rebuilt from AWS alone
+type InstanceMetadata struct {
+	Name        string // Friendly name chosen by the user; the Name tag is the app if empty
+	App         string // CLI that launched the instance, e.g. "lens-rstudio"
+	Environment string
+	IdleTimeout string // e.g. "4h0m0s"
+	KeyPair     string
+	AMIBase     string
+	S3Bucket    string
+	S3SyncPath  string
+	EBSSize     int
+	Owner       string    // ARN of the principal that launched the instance
+	Project     string    // Project the instance is charged to
+	DataVolume  string    // Name of the persistent data volume attached at launch
+	IdleAlarm   string    // Name of the CloudWatch alarm that stops the instance when idle
+	ExpiresAt   time.Time // When the instance's TTL runs out and reap acts on it; zero without one
+}
+
+// Tags returns the metadata as EC2 tags. Empty fields are left out.
+func (m InstanceMetadata) Tags() []types.Tag {
+	app := m.App
+	if app == "" {
+		app = defaultApp
+	}
+
+	name := m.Name
+	if name == "" {
+		name = app
+	}
+
+	tags := []types.Tag{
+		{Key: aws.String(TagName), Value: aws.String(name)},
+		{Key: aws.String(TagCreatedBy), Value: aws.String(app + "-cli")},
+		{Key: aws.String(TagEnvironment), Value: aws.String(m.Environment)},
+		{Key: aws.String(TagApp), Value: aws.String(app)},
+	}
+
+	optional := []struct{ key, value string }{
+		{TagIdleTimeout, m.IdleTimeout},
+		{TagKeyPair, m.KeyPair},
+		{TagAMIBase, m.AMIBase},
+		{TagS3Bucket, m.S3Bucket},
+		{TagS3SyncPath, m.S3SyncPath},
+		{TagOwner, m.Owner},
+		{TagProject, m.Project},
+		{TagDataVolume, m.DataVolume},
+		{TagIdleAlarm, m.IdleAlarm},
+	}
+	if m.EBSSize > 0 {
+		optional = append(optional, struct{ key, value string }{TagEBSSize, strconv.Itoa(m.EBSSize)})
+	}
+	if !m.ExpiresAt.IsZero() {
+		optional = append(optional, struct{ key, value string }{TagExpiresAt, m.ExpiresAt.UTC().Format(time.RFC3339)})
+	}
+	for _, tag := range optional {
+		if tag.value != "" {
+			tags = append(tags, types.Tag{Key: aws.String(tag.key), Value: aws.String(tag.value)})
+		}
+	}
+
+	return tags
+}
+
+// ParseInstanceMetadata reads lens metadata from instance tags. Instances
+// launched before lens:app was written are attributed to the app in their
+// CreatedBy tag.
+func ParseInstanceMetadata(tags []types.Tag) InstanceMetadata {
+	m := InstanceMetadata{
+		App:         tagValue(tags, TagApp),
+		Environment: tagValue(tags, TagEnvironment),
+		IdleTimeout: tagValue(tags, TagIdleTimeout),
+		KeyPair:     tagValue(tags, TagKeyPair),
+		AMIBase:     tagValue(tags, TagAMIBase),
+		S3Bucket:    tagValue(tags, TagS3Bucket),
+		S3SyncPath:  tagValue(tags, TagS3SyncPath),
+		Owner:       tagValue(tags, TagOwner),
+		Project:     tagValue(tags, TagProject),
+		DataVolume:  tagValue(tags, TagDataVolume),
+		IdleAlarm:   tagValue(tags, TagIdleAlarm),
+	}
+	if m.App == "" {
+		if createdBy := tagValue(tags, TagCreatedBy); IsLensCreatedBy(createdBy) {
+			m.App = strings.TrimSuffix(createdBy, "-cli")
+		}
+	}
+	if size, err := strconv.Atoi(tagValue(tags, TagEBSSize)); err == nil {
+		m.EBSSize = size
+	}
+	if expiresAt, err := time.Parse(time.RFC3339, tagValue(tags, TagExpiresAt)); err == nil {
+		m.ExpiresAt = expiresAt
+	}
+	// Unnamed instances carry the app as their Name tag
+	if name := tagValue(tags, TagName); name != "" && name != m.App && name != defaultApp {
+		m.Name = name
+	}
+	return m
+}
+
+// ListLensInstances returns the instances in the region that were launched by
+// any lens tool and are not terminated or being terminated
+func (e *EC2Client) ListLensInstances(ctx context.Context) ([]types.Instance, error) {
+	instances, err := e.liveInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var lens []types.Instance
+	for _, instance := range instances {
+		if IsLensCreatedBy(tagValue(instance.Tags, TagCreatedBy)) {
+			lens = append(lens, instance)
+		}
+	}
+	return lens, nil
+}
+
+// SetInstanceName sets the Name tag of an instance
+func (e *EC2Client) SetInstanceName(ctx context.Context, instanceID, name string) error {
+	_, err := e.client.CreateTags(ctx, &ec2.CreateTagsInput{
+		Resources: []string{instanceID},
+		Tags:      []types.Tag{{Key: aws.String(TagName), Value: aws.String(name)}},
+	})
+	return err
+}
+
+// SetIdleAlarmTag records the name of an instance's idle alarm in its tags
+func (e *EC2Client) SetIdleAlarmTag(ctx context.Context, instanceID, alarmName string) error {
+	_, err := e.client.CreateTags(ctx, &ec2.CreateTagsInput{
+		Resources: []string{instanceID},
+		Tags:      []types.Tag{{Key: aws.String(TagIdleAlarm), Value: aws.String(alarmName)}},
+	})
+	return err
+}
+
+// SetExpiresAtTag records when an instance's TTL runs out in its tags
+func (e *EC2Client) SetExpiresAtTag(ctx context.Context, instanceID string, expiresAt time.Time) error {
+	_, err := e.client.CreateTags(ctx, &ec2.CreateTagsInput{
+		Resources: []string{instanceID},
+		Tags:      []types.Tag{{Key: aws.String(TagExpiresAt), Value: aws.String(expiresAt.UTC().Format(time.RFC3339))}},
+	})
+	return err
+}
+
+// SetEBSSizeTag records the root volume size of an instance in its tags
+func (e *EC2Client) SetEBSSizeTag(ctx context.Context, instanceID string, sizeGB int) error {
+	_, err := e.client.CreateTags(ctx, &ec2.CreateTagsInput{
+		Resources: []string{instanceID},
+		Tags:      []types.Tag{{Key: aws.String(TagEBSSize), Value: aws.String(strconv.Itoa(sizeGB))}},
+	})
+	return err
+}
+
+// TagCostAllocation sets the lens:instance tag on an instance and its EBS
+// volumes, unless the instance already has it. Data volumes are left out:
+// they outlive the instance and move between instances, so their cost is
+// not any one instance's.
+func (e *EC2Client) TagCostAllocation(ctx context.Context, instance types.Instance) error {
+	instanceID := aws.ToString(instance.InstanceId)
+	if tagValue(instance.Tags, TagInstance) == instanceID {
+		return nil
+	}
+
+	dataVolumes, err := e.describeDataVolumes(ctx,
+		types.Filter{Name: aws.String("tag-key"), Values: []string{TagDataVolume}},
+		types.Filter{Name: aws.String("attachment.instance-id"), Values: []string{instanceID}},
+	)
+	if err != nil {
+		return err
+	}
+	skip := make(map[string]bool, len(dataVolumes))
+	for _, volume := range dataVolumes {
+		skip[volume.ID] = true
+	}
+
+	resources := []string{instanceID}
+	for _, mapping := range instance.BlockDeviceMappings {
+		if mapping.Ebs != nil && mapping.Ebs.VolumeId != nil && !skip[*mapping.Ebs.VolumeId] {
+			resources = append(resources, *mapping.Ebs.VolumeId)
+		}
+	}
+	_, err = e.client.CreateTags(ctx, &ec2.CreateTagsInput{
+		Resources: resources,
+		Tags:      []types.Tag{{Key: aws.String(TagInstance), Value: aws.String(instanceID)}},
+	})
+	return err
+}
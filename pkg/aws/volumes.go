@@ -0,0 +1,76 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// volumeModificationPollInterval is how often WaitForVolumeModification checks progress
+var volumeModificationPollInterval = 5 * time.Second
+
+// RootVolume returns the EBS root volume of an instance
+func (e *EC2Client) RootVolume(ctx context.Context, instance types.Instance) (*types.Volume, error) {
+	rootDevice := aws.ToString(instance.RootDeviceName)
+	for _, mapping := range instance.BlockDeviceMappings {
+		if aws.ToString(mapping.DeviceName) != rootDevice || mapping.Ebs == nil {
+			continue
+		}
+		result, err := e.client.DescribeVolumes(ctx, &ec2.DescribeVolumesInput{
+			VolumeIds: []string{aws.ToString(mapping.Ebs.VolumeId)},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe root volume: %w", err)
+		}
+		if len(result.Volumes) == 0 {
+			break
+		}
+		return &result.Volumes[0], nil
+	}
+	return nil, fmt.Errorf("instance %s has no EBS root volume", aws.ToString(instance.InstanceId))
+}
+
+// GrowVolume increases the size of an EBS volume in GiB. EBS volumes cannot
+// shrink, and a volume can be modified once every six hours.
+func (e *EC2Client) GrowVolume(ctx context.Context, volumeID string, sizeGiB int) error {
+	_, err := e.client.ModifyVolume(ctx, &ec2.ModifyVolumeInput{
+		VolumeId: aws.String(volumeID),
+		Size:     aws.Int32(int32(sizeGiB)),
+	})
+	return err
+}
+
+// WaitForVolumeModification waits until a volume modification has reached
+// the optimizing state, from which the new size can be used, or has completed
+func (e *EC2Client) WaitForVolumeModification(ctx context.Context, volumeID string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		result, err := e.client.DescribeVolumesModifications(ctx, &ec2.DescribeVolumesModificationsInput{
+			VolumeIds: []string{volumeID},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to describe volume modification: %w", err)
+		}
+		for _, modification := range result.VolumesModifications {
+			switch modification.ModificationState {
+			case types.VolumeModificationStateOptimizing, types.VolumeModificationStateCompleted:
+				return nil
+			case types.VolumeModificationStateFailed:
+				return fmt.Errorf("modification of volume %s failed: %s", volumeID, aws.ToString(modification.StatusMessage))
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout waiting for volume %s to be modified", volumeID)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(volumeModificationPollInterval):
+		}
+	}
+}
@@ -0,0 +1,599 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/cost"
+	"github.com/spf13/cobra"
+)
+
+// restoreMountDir is where backup restore --into mounts restored volumes
+const restoreMountDir = "/home/ubuntu/restore"
+
+// BackupOptions selects the region of the backup commands that are not
+// given an instance
+type BackupOptions struct {
+	Profile  string
+	Region   string // Default: the profile's region
+	AllUsers bool   // List and prune everyone's backups, not only the caller's
+}
+
+// RestoreOptions holds the options of backup restore
+type RestoreOptions struct {
+	Into string // Instance to attach and mount the restored volume on
+	Name string // Data volume name; default: derived from the backup
+	Zone string // Availability zone of the volume without Into
+}
+
+// BackupRetention is how many backups prune keeps of each volume: the newest
+// backup of each of the last Daily days, Weekly ISO weeks and Monthly months
+// that have backups
+type BackupRetention struct {
+	Daily   int
+	Weekly  int
+	Monthly int
+}
+
+// NewBackupCmd creates the backup command for snapshotting, restoring and
+// pruning backups of data and root volumes
+func NewBackupCmd(appName string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Back up, restore and prune volume snapshots",
+		Long: `Back up, restore and prune volume snapshots.
+
+backup create snapshots an instance's data volume, or its root volume when it
+has none or with --root. Backups are tagged with what they were taken of and
+are kept when the instance or volume is gone. They cost $` + fmt.Sprintf("%.2f", cost.EBSSnapshotPricePerGBMonth) + ` per GB-month
+of stored data, and only changed blocks are stored after the first backup of a
+volume.
+
+backup restore creates a data volume from a backup. Launch with
+--data-volume NAME to mount it on a new instance, or restore with --into to
+attach and mount it on a running instance under ` + restoreMountDir + `.
+
+backup prune deletes backups the retention rules do not keep.
+
+Backups are tagged with who took them, and list and prune only act on your
+own unless given --all-users. Backups taken before they were tagged with an
+owner are only included with --all-users.`,
+		Example: fmt.Sprintf(`  %[1]s backup create my-analysis
+  %[1]s backup list
+  %[1]s backup restore snap-0123456789abcdef0 --into my-analysis
+  %[1]s backup prune --keep-daily 7 --keep-weekly 4 --dry-run`, appName),
+	}
+
+	cmd.AddCommand(newBackupCreateCmd(appName))
+	cmd.AddCommand(newBackupListCmd())
+	cmd.AddCommand(newBackupRestoreCmd(appName))
+	cmd.AddCommand(newBackupPruneCmd())
+
+	return cmd
+}
+
+// addBackupFlags adds the flags selecting the region of a backup command
+func addBackupFlags(cmd *cobra.Command, opts *BackupOptions) {
+	cmd.Flags().StringVarP(&opts.Profile, "profile", "p", "default", "AWS profile to use")
+	cmd.Flags().StringVarP(&opts.Region, "region", "r", "", "AWS region (default: the profile's region)")
+}
+
+func newBackupCreateCmd(appName string) *cobra.Command {
+	var opts BackupCreateOptions
+	cmd := &cobra.Command{
+		Use:   "create [INSTANCE]",
+		Short: "Snapshot the data volume or root volume of an instance",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			instanceRef := ""
+			if len(args) > 0 {
+				instanceRef = args[0]
+			}
+			_, err := RunBackupCreate(context.Background(), appName, instanceRef, opts)
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Profile, "profile", "p", "default", "AWS profile to use")
+	cmd.Flags().BoolVar(&opts.Root, "root", false, "Back up the root volume even if the instance has a data volume")
+	return cmd
+}
+
+func newBackupListCmd() *cobra.Command {
+	var opts BackupOptions
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List backups with their sizes and monthly cost",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunBackupList(context.Background(), opts)
+		},
+	}
+	addBackupFlags(cmd, &opts)
+	cmd.Flags().BoolVar(&opts.AllUsers, "all-users", false, "List everyone's backups, not only your own")
+	return cmd
+}
+
+func newBackupRestoreCmd(appName string) *cobra.Command {
+	var opts BackupOptions
+	var restore RestoreOptions
+	cmd := &cobra.Command{
+		Use:   "restore SNAPSHOT",
+		Short: "Create a data volume from a backup and mount it",
+		Long: `Create a data volume from a backup.
+
+With --into, the volume is attached to that running instance and mounted under
+` + restoreMountDir + `/NAME over Session Manager. Otherwise launch a new
+instance with --data-volume NAME to mount it at ` + aws.DataVolumeMountPath + `.
+
+A restored root volume holds the whole file system of the instance it was taken
+of; its home directory is under home/ubuntu.`,
+		Example: fmt.Sprintf(`  # Restore into a new instance
+  %[1]s backup restore snap-0123456789abcdef0 --name thesis-data-restored
+  %[1]s launch --data-volume thesis-data-restored
+
+  # Restore into a running instance
+  %[1]s backup restore snap-0123456789abcdef0 --into my-analysis`, appName),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := RunBackupRestore(context.Background(), opts, args[0], restore)
+			return err
+		},
+	}
+	addBackupFlags(cmd, &opts)
+	cmd.Flags().StringVar(&restore.Into, "into", "", "Running instance to attach and mount the restored volume on")
+	cmd.Flags().StringVar(&restore.Name, "name", "", "Name of the restored data volume (default: from the backup and its date)")
+	cmd.Flags().StringVar(&restore.Zone, "zone", "", "Availability zone of the restored volume (default: the zone of a public subnet)")
+	return cmd
+}
+
+func newBackupPruneCmd() *cobra.Command {
+	var opts BackupOptions
+	var retention BackupRetention
+	var dryRun, yes bool
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete backups the retention rules do not keep",
+		Long: `Delete backups the retention rules do not keep.
+
+The rules apply to the backups of each volume separately: the newest backup of
+each of the last --keep-daily days, --keep-weekly ISO weeks and --keep-monthly
+months that have backups is kept. A backup kept by any rule is not deleted, and
+backups still in progress are always kept.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunBackupPrune(context.Background(), opts, retention, dryRun, yes)
+		},
+	}
+	addBackupFlags(cmd, &opts)
+	cmd.Flags().BoolVar(&opts.AllUsers, "all-users", false, "Prune everyone's backups, not only your own")
+	cmd.Flags().IntVar(&retention.Daily, "keep-daily", 7, "Number of daily backups to keep")
+	cmd.Flags().IntVar(&retention.Weekly, "keep-weekly", 4, "Number of weekly backups to keep")
+	cmd.Flags().IntVar(&retention.Monthly, "keep-monthly", 0, "Number of monthly backups to keep")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the backups that would be deleted without deleting them")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip confirmation prompt")
+	return cmd
+}
+
+// backupClient returns an EC2 client for the region of the backup commands
+func backupClient(ctx context.Context, opts BackupOptions) (*aws.EC2Client, error) {
+	return volumesClient(ctx, VolumesOptions{Profile: opts.Profile, Region: opts.Region})
+}
+
+// backupOwner returns the ARN of the principal taking a backup, to tag it
+// with. Backups are still taken if it cannot be determined, without an owner.
+func backupOwner(ctx context.Context, profile string) string {
+	owner, err := callerARN(ctx, profile)
+	if err != nil {
+		fmt.Printf("Warning: Could not determine your identity, the backup is not tagged with an owner: %v\n", err)
+		return ""
+	}
+	return owner
+}
+
+// listBackups returns the backups of the region that list and prune act on:
+// the caller's own, or everyone's with AllUsers
+func listBackups(ctx context.Context, ec2Client *aws.EC2Client, opts BackupOptions) ([]aws.Backup, error) {
+	owner := ""
+	if !opts.AllUsers {
+		var err error
+		if owner, err = callerARN(ctx, opts.Profile); err != nil {
+			return nil, fmt.Errorf("failed to determine your identity to select your backups (use --all-users for everyone's): %w", err)
+		}
+	}
+	return ec2Client.ListBackups(ctx, owner)
+}
+
+// BackupCreateOptions holds the options of backup create
+type BackupCreateOptions struct {
+	Profile string
+	Root    bool // Back up the root volume even if the instance has a data volume
+}
+
+// RunBackupCreate snapshots the data volume of the instance ref refers to,
+// or its root volume when it has none or opts.Root is set, and returns the
+// snapshot ID
+func RunBackupCreate(ctx context.Context, appName, instanceRef string, opts BackupCreateOptions) (string, error) {
+	state, err := config.LoadState()
+	if err != nil {
+		return "", fmt.Errorf("failed to load state: %w", err)
+	}
+	instance, err := ResolveInstance(state, instanceRef)
+	if err != nil {
+		return "", err
+	}
+
+	ec2Client, err := aws.NewEC2ClientForProfileRegion(ctx, opts.Profile, instance.Region)
+	if err != nil {
+		return "", fmt.Errorf("failed to create AWS client: %w", err)
+	}
+
+	app := instance.App
+	if app == "" {
+		app = appName
+	}
+	source := aws.BackupSource{SourceInstance: instance.ID, App: app, Owner: backupOwner(ctx, opts.Profile)}
+	if instance.DataVolume != "" && !opts.Root {
+		volume, err := ec2Client.AttachedDataVolume(ctx, instance.ID)
+		if err != nil {
+			return "", err
+		}
+		if volume == nil {
+			return "", fmt.Errorf("data volume %s is not attached to instance %s; use --root to back up the root volume", instance.DataVolume, instance.DisplayName())
+		}
+		source.Kind = aws.BackupData
+		source.VolumeID = volume.ID
+		source.Name = volume.Name
+		source.DataVolume = volume.Name
+	} else {
+		awsInstance, err := ec2Client.GetInstanceInfo(ctx, instance.ID)
+		if err != nil {
+			return "", fmt.Errorf("failed to get instance info: %w", err)
+		}
+		volume, err := ec2Client.RootVolume(ctx, *awsInstance)
+		if err != nil {
+			return "", err
+		}
+		source.Kind = aws.BackupRoot
+		source.VolumeID = *volume.VolumeId
+		source.Name = instance.ID
+		if instance.Name != "" {
+			source.Name = instance.Name
+		}
+	}
+
+	description := fmt.Sprintf("lens backup of the %s volume of %s taken %s", source.Kind, instance.DisplayName(), time.Now().UTC().Format(time.RFC3339))
+	snapshotID, err := ec2Client.CreateBackup(ctx, source, description)
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("Backup %s of the %s volume of %s started\n", snapshotID, source.Kind, instance.DisplayName())
+	fmt.Println("The backup holds the data written to disk when it started; stop the instance first for a consistent copy")
+	fmt.Printf("Check its progress with 'backup list --region %s'\n", instance.Region)
+	return snapshotID, nil
+}
+
+// backupSourceName returns the data volume or instance name a backup was
+// taken of
+func backupSourceName(backup aws.Backup) string {
+	if backup.Kind == aws.BackupData {
+		return backup.DataVolume
+	}
+	if backup.Name != "" && backup.Name != backup.SourceInstance {
+		return fmt.Sprintf("%s (%s)", backup.Name, backup.SourceInstance)
+	}
+	return backup.SourceInstance
+}
+
+// backupMonthlyCost estimates the monthly cost of a backup from its volume
+// size. Snapshots are billed for changed blocks only, so this is an upper
+// bound.
+func backupMonthlyCost(backup aws.Backup) float64 {
+	return float64(backup.SizeGB) * cost.EBSSnapshotPricePerGBMonth
+}
+
+// RunBackupList prints the backups of the region with their sizes and
+// monthly cost
+func RunBackupList(ctx context.Context, opts BackupOptions) error {
+	ec2Client, err := backupClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+	backups, err := listBackups(ctx, ec2Client, opts)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		fmt.Printf("No backups in %s\n", ec2Client.GetRegion())
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SNAPSHOT ID\tVOLUME\tOF\tSIZE\tCREATED\tSTATE\tMONTHLY")
+	var total float64
+	for _, backup := range backups {
+		status := backup.State
+		if !backup.Completed() && backup.Progress != "" {
+			status = fmt.Sprintf("%s %s", backup.State, backup.Progress)
+		}
+		monthly := backupMonthlyCost(backup)
+		total += monthly
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d GB\t%s\t%s\t%s\n",
+			backup.ID, backup.Kind, backupSourceName(backup), backup.SizeGB,
+			backup.CreatedAt.Local().Format("2006-01-02 15:04"), status, cost.FormatCostShort(monthly))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\nTotal: %s/month (upper bound: only changed blocks are billed)\n", cost.FormatCostShort(total))
+	return nil
+}
+
+// invalidVolumeNameChars matches characters data volume names cannot contain
+var invalidVolumeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// restoredVolumeName derives the name of a data volume restored from a
+// backup from what it was taken of and when
+func restoredVolumeName(backup aws.Backup) string {
+	base := backup.DataVolume
+	if base == "" {
+		base = backup.Name
+	}
+	if base == "" {
+		base = backup.SourceInstance
+	}
+	base = strings.Trim(invalidVolumeNameChars.ReplaceAllString(base, "-"), "-._")
+	suffix := "-" + backup.CreatedAt.UTC().Format("20060102-1504")
+	if len(base)+len(suffix) > 63 {
+		base = base[:63-len(suffix)]
+	}
+	return base + suffix
+}
+
+// RunBackupRestore creates a data volume from a backup and, with
+// restore.Into, attaches and mounts it on a running instance. It returns the
+// restored volume.
+func RunBackupRestore(ctx context.Context, opts BackupOptions, snapshotID string, restore RestoreOptions) (*aws.DataVolume, error) {
+	var instance *config.Instance
+	var awsInstance *ec2types.Instance
+	var ec2Client *aws.EC2Client
+	var err error
+	if restore.Into != "" {
+		state, err := config.LoadState()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load state: %w", err)
+		}
+		if instance, err = state.FindInstance(restore.Into); err != nil {
+			return nil, err
+		}
+		if ec2Client, err = aws.NewEC2ClientForProfileRegion(ctx, opts.Profile, instance.Region); err != nil {
+			return nil, fmt.Errorf("failed to create AWS client: %w", err)
+		}
+		if awsInstance, err = ec2Client.GetInstanceInfo(ctx, instance.ID); err != nil {
+			return nil, fmt.Errorf("failed to get instance info: %w", err)
+		}
+		if awsInstance.State.Name != ec2types.InstanceStateNameRunning {
+			return nil, fmt.Errorf("instance %s is %s; start it to restore into it", instance.DisplayName(), awsInstance.State.Name)
+		}
+		zone := *awsInstance.Placement.AvailabilityZone
+		if restore.Zone != "" && restore.Zone != zone {
+			return nil, fmt.Errorf("instance %s is in %s, not %s", instance.DisplayName(), zone, restore.Zone)
+		}
+		restore.Zone = zone
+	} else if ec2Client, err = backupClient(ctx, opts); err != nil {
+		return nil, err
+	}
+
+	backup, err := ec2Client.GetBackup(ctx, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	if !backup.Completed() {
+		return nil, fmt.Errorf("backup %s is %s %s; restore it once it has completed", backup.ID, backup.State, backup.Progress)
+	}
+
+	name := restore.Name
+	if name == "" {
+		name = restoredVolumeName(*backup)
+	}
+	if _, err := aws.ParseDataVolumeSpec(name); err != nil {
+		return nil, err
+	}
+	// The restored volume is the caller's, so launch --data-volume finds it
+	owner, err := callerARN(ctx, opts.Profile)
+	if err != nil {
+		fmt.Printf("Warning: Could not determine your identity, the data volume is not tagged with an owner: %v\n", err)
+	}
+	existing, err := ec2Client.FindDataVolume(ctx, name, owner)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("data volume %s already exists; choose another name with --name", name)
+	}
+
+	if restore.Zone == "" {
+		subnet, err := ec2Client.GetSubnet(ctx, "public", "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to choose an availability zone, use --zone: %w", err)
+		}
+		restore.Zone = subnet.AvailabilityZone
+	}
+
+	fmt.Printf("Restoring backup %s (%d GB) to data volume %s in %s...\n", backup.ID, backup.SizeGB, name, restore.Zone)
+	volume, err := ec2Client.RestoreDataVolume(ctx, *backup, name, restore.Zone, aws.InstanceMetadata{Owner: owner})
+	if err != nil {
+		return nil, err
+	}
+
+	if instance == nil {
+		fmt.Printf("Data volume %s (%s) restored\n", volume.Name, volume.ID)
+		fmt.Printf("Launch with --data-volume %s to mount it at %s\n", volume.Name, aws.DataVolumeMountPath)
+		return volume, nil
+	}
+
+	if err := mountRestoredVolume(ctx, opts.Profile, ec2Client, instance, *awsInstance, volume, backup.Kind); err != nil {
+		return volume, err
+	}
+	return volume, nil
+}
+
+// mountRestoredVolume attaches a restored data volume to a running instance
+// and mounts it under restoreMountDir over Session Manager
+func mountRestoredVolume(ctx context.Context, profile string, ec2Client *aws.EC2Client, instance *config.Instance, awsInstance ec2types.Instance, volume *aws.DataVolume, kind aws.BackupKind) error {
+	device, err := aws.FreeDataVolumeDevice(awsInstance)
+	if err != nil {
+		return err
+	}
+	if err := ec2Client.AttachDataVolumeAs(ctx, volume.ID, instance.ID, device); err != nil {
+		return err
+	}
+
+	mountPath := restoreMountDir + "/" + volume.Name
+	fmt.Printf("Mounting data volume %s at %s over Session Manager...\n", volume.Name, mountPath)
+	ssmClient, err := aws.NewSSMClientForProfileRegion(ctx, profile, instance.Region)
+	if err != nil {
+		return fmt.Errorf("failed to create SSM client: %w", err)
+	}
+	commandID, err := ssmClient.RunCommand(ctx, instance.ID, aws.RestoredVolumeMountScript(volume.ID, device, mountPath))
+	if err != nil {
+		return fmt.Errorf("data volume %s attached as %s, but failed to mount it: %w", volume.Name, device, err)
+	}
+	result, err := ssmClient.WaitForCommand(ctx, commandID, instance.ID, 3*time.Minute)
+	if err != nil {
+		return fmt.Errorf("data volume %s attached as %s, but failed to mount it: %w", volume.Name, device, err)
+	}
+	if result.Status != ssmtypes.CommandInvocationStatusSuccess {
+		return fmt.Errorf("data volume %s attached as %s, but mounting it failed: %s", volume.Name, device, strings.TrimSpace(result.ErrorOutput))
+	}
+
+	fmt.Printf("Data volume %s (%s) is mounted at %s on %s\n", volume.Name, volume.ID, mountPath, instance.DisplayName())
+	if kind == aws.BackupRoot {
+		fmt.Printf("The restored home directory is %s/home/ubuntu\n", mountPath)
+	}
+	fmt.Printf("It is kept when the instance is terminated; delete it with 'volumes delete %s' when done\n", volume.Name)
+	return nil
+}
+
+// Expired returns the backups the retention does not keep, oldest first. The
+// rules apply to the backups of each volume separately; backups that have not
+// completed are always kept.
+func (r BackupRetention) Expired(backups []aws.Backup) []aws.Backup {
+	bySource := make(map[string][]aws.Backup)
+	for _, backup := range backups {
+		if backup.Completed() {
+			bySource[backup.Source()] = append(bySource[backup.Source()], backup)
+		}
+	}
+
+	var expired []aws.Backup
+	for _, group := range bySource {
+		// Newest first, so the newest backup of each period is kept
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].CreatedAt.After(group[j].CreatedAt)
+		})
+		days := make(map[string]bool)
+		weeks := make(map[string]bool)
+		months := make(map[string]bool)
+		for _, backup := range group {
+			created := backup.CreatedAt.Local()
+			year, week := created.ISOWeek()
+			keep := keepPeriod(days, created.Format("2006-01-02"), r.Daily)
+			keep = keepPeriod(weeks, fmt.Sprintf("%d-W%02d", year, week), r.Weekly) || keep
+			keep = keepPeriod(months, created.Format("2006-01"), r.Monthly) || keep
+			if !keep {
+				expired = append(expired, backup)
+			}
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].CreatedAt.Before(expired[j].CreatedAt)
+	})
+	return expired
+}
+
+// keepPeriod reports whether a backup is the newest of a period that is
+// among the newest limit periods, recording the period as kept
+func keepPeriod(kept map[string]bool, period string, limit int) bool {
+	if kept[period] || len(kept) >= limit {
+		return false
+	}
+	kept[period] = true
+	return true
+}
+
+// RunBackupPrune deletes the backups of the region the retention does not
+// keep, after confirmation unless yes is set
+func RunBackupPrune(ctx context.Context, opts BackupOptions, retention BackupRetention, dryRun, yes bool) error {
+	if retention.Daily < 0 || retention.Weekly < 0 || retention.Monthly < 0 {
+		return fmt.Errorf("retention counts cannot be negative")
+	}
+	if retention.Daily == 0 && retention.Weekly == 0 && retention.Monthly == 0 {
+		return fmt.Errorf("retention keeps no backups: set --keep-daily, --keep-weekly or --keep-monthly")
+	}
+
+	ec2Client, err := backupClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+	backups, err := listBackups(ctx, ec2Client, opts)
+	if err != nil {
+		return err
+	}
+	expired := retention.Expired(backups)
+	if len(expired) == 0 {
+		fmt.Printf("All %d backup(s) in %s are kept by the retention rules\n", len(backups), ec2Client.GetRegion())
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SNAPSHOT ID\tVOLUME\tOF\tCREATED\tMONTHLY")
+	var total float64
+	for _, backup := range expired {
+		monthly := backupMonthlyCost(backup)
+		total += monthly
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", backup.ID, backup.Kind, backupSourceName(backup),
+			backup.CreatedAt.Local().Format("2006-01-02 15:04"), cost.FormatCostShort(monthly))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d of %d backup(s) are not kept by the retention rules (up to %s/month)\n", len(expired), len(backups), cost.FormatCostShort(total))
+
+	if dryRun {
+		fmt.Println("\nDry run: nothing was deleted. Run without --dry-run to delete these backups.")
+		return nil
+	}
+	if !yes {
+		fmt.Printf("\nDelete %d backup(s)? This cannot be undone. (yes/no): ", len(expired))
+		var response string
+		if _, err := fmt.Scanln(&response); err != nil || (response != "yes" && response != "y") {
+			fmt.Println("Prune cancelled")
+			return nil
+		}
+	}
+
+	failCount := 0
+	for _, backup := range expired {
+		if err := ec2Client.DeleteBackup(ctx, backup.ID); err != nil {
+			fmt.Printf("  ✗ Failed to delete %s: %v\n", backup.ID, err)
+			failCount++
+			continue
+		}
+		fmt.Printf("  ✓ Deleted %s\n", backup.ID)
+	}
+	fmt.Printf("\nSummary: %d deleted, %d failed\n", len(expired)-failCount, failCount)
+	if failCount > 0 {
+		return fmt.Errorf("failed to delete %d backup(s)", failCount)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/fakecloud"
+)
+
+func TestBackupRetention_Expired(t *testing.T) {
+	const completed = "completed"
+	daily := func(day int) aws.Backup {
+		return aws.Backup{
+			ID:         "snap-data",
+			Kind:       aws.BackupData,
+			DataVolume: "thesis-data",
+			State:      completed,
+			CreatedAt:  time.Date(2026, 9, day, 12, 0, 0, 0, time.Local),
+		}
+	}
+
+	// One backup a day from Sep 1 to Oct 14, a Wednesday
+	var backups []aws.Backup
+	for day := 1; day <= 44; day++ {
+		backups = append(backups, daily(day))
+	}
+	earlier := daily(44)
+	earlier.ID = "snap-earlier"
+	earlier.CreatedAt = earlier.CreatedAt.Add(-6 * time.Hour)
+	pending := daily(1)
+	pending.State = "pending"
+	other := aws.Backup{Kind: aws.BackupRoot, SourceInstance: "i-other", State: completed, CreatedAt: daily(1).CreatedAt}
+	backups = append(backups, earlier, pending, other)
+
+	expired := BackupRetention{Daily: 7, Weekly: 4}.Expired(backups)
+
+	// Kept: Oct 8-14 daily, plus the newest of the weeks ending Oct 4 and Sep 27
+	if len(expired) != 36 {
+		t.Errorf("expected 36 expired backups, got %d", len(expired))
+	}
+	for _, backup := range expired {
+		created := backup.CreatedAt
+		switch {
+		case backup.ID == "snap-earlier":
+		case backup.State != completed:
+			t.Error("a pending backup expired")
+		case backup.SourceInstance == "i-other":
+			t.Error("the only backup of another volume expired")
+		case created.Equal(daily(34).CreatedAt), created.Equal(daily(27).CreatedAt), created.After(daily(37).CreatedAt):
+			t.Errorf("backup of %s expired", created.Format("Jan 2 15:04"))
+		}
+	}
+	for i := 1; i < len(expired); i++ {
+		if expired[i].CreatedAt.Before(expired[i-1].CreatedAt) {
+			t.Fatal("expired backups are not sorted oldest first")
+		}
+	}
+}
+
+func TestBackup_CreateRestorePrune(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	id := trackManaged(t, cloud)
+	ctx := context.Background()
+	opts := BackupOptions{Profile: "default"}
+
+	first, err := RunBackupCreate(ctx, "lens-jupyter", id, BackupCreateOptions{Profile: "default"})
+	if err != nil {
+		t.Fatalf("RunBackupCreate failed: %v", err)
+	}
+	second, err := RunBackupCreate(ctx, "lens-jupyter", id, BackupCreateOptions{Profile: "default"})
+	if err != nil {
+		t.Fatalf("RunBackupCreate failed: %v", err)
+	}
+
+	// Another user of the account backs up the same instance
+	const colleague = "arn:aws:iam::" + fakecloud.AccountID + ":user/colleague"
+	cloud.SetCaller(colleague)
+	theirs, err := RunBackupCreate(ctx, "lens-jupyter", id, BackupCreateOptions{Profile: "default"})
+	if err != nil {
+		t.Fatalf("RunBackupCreate failed: %v", err)
+	}
+	cloud.SetCaller(fakecloud.CallerARN)
+
+	ec2Client, err := aws.NewEC2Client(ctx, "default")
+	if err != nil {
+		t.Fatalf("NewEC2Client failed: %v", err)
+	}
+	backup, err := ec2Client.GetBackup(ctx, first)
+	if err != nil {
+		t.Fatalf("GetBackup failed: %v", err)
+	}
+	if backup.Kind != aws.BackupRoot || backup.SourceInstance != id || backup.SizeGB != 30 || !backup.Completed() || backup.Owner != fakecloud.CallerARN {
+		t.Errorf("backup = %+v, want a completed 30 GB root backup of %s by %s", backup, id, fakecloud.CallerARN)
+	}
+	scan, err := ec2Client.ScanOrphans(ctx, true)
+	if err != nil {
+		t.Fatalf("ScanOrphans failed: %v", err)
+	}
+	for _, orphan := range scan.Orphans {
+		if orphan.Resource.ID == first {
+			t.Errorf("gc reports backup %s as orphaned", first)
+		}
+	}
+	if err := RunBackupList(ctx, opts); err != nil {
+		t.Errorf("RunBackupList failed: %v", err)
+	}
+
+	// Restore into a new data volume for the next launch
+	volume, err := RunBackupRestore(ctx, opts, first, RestoreOptions{Name: "restored-root"})
+	if err != nil {
+		t.Fatalf("RunBackupRestore failed: %v", err)
+	}
+	if volume.Name != "restored-root" || volume.SizeGB != 30 || volume.Attached() {
+		t.Errorf("restored volume = %+v, want a detached 30 GB data volume", volume)
+	}
+	if _, err := RunBackupRestore(ctx, opts, first, RestoreOptions{Name: "restored-root"}); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("expected an error restoring to an existing volume, got %v", err)
+	}
+
+	// Restore into the running instance
+	volume, err = RunBackupRestore(ctx, opts, second, RestoreOptions{Into: id})
+	if err != nil {
+		t.Fatalf("RunBackupRestore --into failed: %v", err)
+	}
+	if volume.AvailabilityZone == "" || !strings.HasPrefix(volume.Name, id+"-") {
+		t.Errorf("restored volume = %+v", volume)
+	}
+	attached := false
+	for _, v := range cloud.Volumes(fakecloud.DefaultRegion) {
+		if *v.VolumeId == volume.ID && len(v.Attachments) == 1 && *v.Attachments[0].InstanceId == id {
+			attached = true
+		}
+	}
+	if !attached {
+		t.Errorf("restored volume %s is not attached to %s", volume.ID, id)
+	}
+	invocations := cloud.Invocations()
+	if len(invocations) != 1 || !strings.Contains(invocations[0].Script(), volume.ID) || !strings.Contains(invocations[0].Script(), restoreMountDir+"/"+volume.Name) {
+		t.Errorf("expected one command mounting %s, got %+v", volume.ID, invocations)
+	}
+
+	// Both backups are from today, so one daily backup keeps only the newest.
+	// The colleague's backup is not ours to prune.
+	if err := RunBackupPrune(ctx, opts, BackupRetention{Daily: 1}, false, true); err != nil {
+		t.Fatalf("RunBackupPrune failed: %v", err)
+	}
+	var kept []string
+	for _, snapshot := range cloud.Snapshots(fakecloud.DefaultRegion) {
+		kept = append(kept, *snapshot.SnapshotId)
+	}
+	if len(kept) != 2 || !strings.Contains(strings.Join(kept, ","), second) || !strings.Contains(strings.Join(kept, ","), theirs) {
+		t.Errorf("expected backups %s and %s to be kept, got %v", second, theirs, kept)
+	}
+
+	// With --all-users the backups of the instance are pruned together
+	allUsers := BackupOptions{Profile: "default", AllUsers: true}
+	if err := RunBackupPrune(ctx, allUsers, BackupRetention{Daily: 1}, false, true); err != nil {
+		t.Fatalf("RunBackupPrune --all-users failed: %v", err)
+	}
+	if snapshots := cloud.Snapshots(fakecloud.DefaultRegion); len(snapshots) != 1 {
+		t.Errorf("expected one backup to be kept of everyone's, got %d", len(snapshots))
+	}
+	if err := RunBackupPrune(ctx, opts, BackupRetention{}, false, true); err == nil {
+		t.Error("expected an error for a retention that keeps nothing")
+	}
+}
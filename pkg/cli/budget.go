@@ -0,0 +1,599 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/cost"
+	"github.com/scttfrdmn/lens/pkg/hooks"
+	"github.com/spf13/cobra"
+)
+
+// ErrBudgetExceeded is returned when a launch would take a budget over its cap
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// InstanceSpend is an instance with its spend this month
+type InstanceSpend struct {
+	Instance *config.Instance
+	Calc     *cost.CostCalculation
+	Spend    float64
+}
+
+// Running reports whether the instance is running according to its state
+// history, refreshed from EC2 by budget check and launch
+func (s InstanceSpend) Running() bool {
+	return s.Calc.CurrentState == "running"
+}
+
+// Terminated reports whether the instance is terminated according to local
+// state, so it no longer has volumes to pay for
+func (s InstanceSpend) Terminated() bool {
+	return s.Calc.CurrentState == "terminated"
+}
+
+// BudgetUsage is a budget with this month's spend of the instances it matches
+type BudgetUsage struct {
+	Budget    config.Budget
+	Spend     float64
+	Instances []InstanceSpend
+}
+
+// MonthlyExceeded reports whether the matched instances together reached the
+// monthly cap
+func (u BudgetUsage) MonthlyExceeded() bool {
+	return u.Budget.Monthly > 0 && u.Spend >= u.Budget.Monthly
+}
+
+// OverInstanceCap returns the matched instances that reached the
+// per-instance cap on their own
+func (u BudgetUsage) OverInstanceCap() []InstanceSpend {
+	var over []InstanceSpend
+	if u.Budget.PerInstance <= 0 {
+		return nil
+	}
+	for _, spend := range u.Instances {
+		if spend.Spend >= u.Budget.PerInstance {
+			over = append(over, spend)
+		}
+	}
+	return over
+}
+
+// EvaluateBudgets returns the spend of each budget in the calendar month of now
+func EvaluateBudgets(budgets []config.Budget, instances []*config.Instance, now time.Time) []BudgetUsage {
+	from := cost.MonthStart(now)
+	spends := make([]InstanceSpend, len(instances))
+	for i, instance := range instances {
+		calc := cost.CalculateCost(
+			cost.PriceQuery{InstanceType: instance.InstanceType, Region: instance.Region},
+			instance.LaunchedAt,
+			convertToCostStateChanges(instance.StateChanges),
+			instance.EBSSize,
+		)
+		spends[i] = InstanceSpend{Instance: instance, Calc: calc, Spend: calc.SpendBetween(from, now)}
+	}
+
+	usages := make([]BudgetUsage, len(budgets))
+	for i, budget := range budgets {
+		usages[i].Budget = budget
+		for _, spend := range spends {
+			if budget.Matches(spend.Instance) {
+				usages[i].Spend += spend.Spend
+				usages[i].Instances = append(usages[i].Instances, spend)
+			}
+		}
+	}
+	return usages
+}
+
+// loadBudgetInstances returns the instances budgets apply to, including
+// terminated ones: everyone's when the state backend is shared, otherwise the
+// local state's
+func loadBudgetInstances() ([]*config.Instance, error) {
+	states, err := config.LoadAllStates()
+	if errors.Is(err, config.ErrStateNotShared) {
+		var state *config.LocalState
+		state, err = config.LoadState()
+		states = map[string]*config.LocalState{"": state}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+
+	var instances []*config.Instance
+	for _, state := range states {
+		for _, instance := range state.Instances {
+			instances = append(instances, instance)
+		}
+		instances = append(instances, state.Terminated...)
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].ID < instances[j].ID
+	})
+	return instances, nil
+}
+
+// refreshBudgetInstances brings the state history of live instances up to
+// date with EC2, so that stops lens did not record, such as idle stops by
+// lens-agent or the idle alarm, end their compute spend. The instances are
+// only updated in memory; regions that cannot be listed keep their recorded
+// state.
+func refreshBudgetInstances(ctx context.Context, profile string, instances []*config.Instance) {
+	byRegion := make(map[string][]*config.Instance)
+	for _, instance := range instances {
+		if n := len(instance.StateChanges); instance.Region == "" || (n > 0 && instance.StateChanges[n-1].State == "terminated") {
+			continue
+		}
+		byRegion[instance.Region] = append(byRegion[instance.Region], instance)
+	}
+
+	now := time.Now()
+	for region, regionInstances := range byRegion {
+		if err := refreshRegionStates(ctx, profile, region, regionInstances, now); err != nil {
+			fmt.Printf("Warning: Could not refresh instance states in %s, using local state: %v\n", region, err)
+		}
+	}
+}
+
+// refreshRegionStates refreshes the state history of instances of one region
+// from the lens instances EC2 lists there
+func refreshRegionStates(ctx context.Context, profile, region string, instances []*config.Instance, now time.Time) error {
+	ec2Client, err := aws.NewEC2ClientForProfileRegion(ctx, profile, region)
+	if err != nil {
+		return err
+	}
+	live, err := ec2Client.ListLensInstances(ctx)
+	if err != nil {
+		return err
+	}
+	described := make(map[string]types.Instance, len(live))
+	for _, ec2Instance := range live {
+		described[*ec2Instance.InstanceId] = ec2Instance
+	}
+	for _, instance := range instances {
+		if ec2Instance, ok := described[instance.ID]; ok {
+			instance.RefreshState(ec2Instance, now)
+		}
+	}
+	return nil
+}
+
+// PlannedLaunch describes an instance about to be launched
+type PlannedLaunch struct {
+	Profile      string // Used to determine the owner for user budgets
+	App          string // e.g. "lens-jupyter"
+	Project      string
+	InstanceType string
+	Region       string
+	EBSSize      int
+}
+
+// CheckLaunchBudget returns an error wrapping ErrBudgetExceeded when the
+// planned instance would take a budget over its cap this month. Instances
+// are assumed to run until the end of the month, so the projection is an
+// upper bound. Terminated instances count with their spend so far only.
+func CheckLaunchBudget(ctx context.Context, launch PlannedLaunch) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if len(cfg.Budgets) == 0 {
+		return nil
+	}
+
+	planned := &config.Instance{App: launch.App, Project: launch.Project}
+	if hasUserBudget(cfg.Budgets) {
+		owner, err := callerARN(ctx, launch.Profile)
+		if err != nil {
+			fmt.Printf("Warning: Could not determine instance owner, user budgets are not checked: %v\n", err)
+		}
+		planned.Owner = owner
+	}
+
+	instances, err := loadBudgetInstances()
+	if err != nil {
+		return err
+	}
+	refreshBudgetInstances(ctx, launch.Profile, instances)
+
+	now := time.Now()
+	remaining := cost.MonthStart(now).AddDate(0, 1, 0).Sub(now).Hours()
+	price := cost.LookupPrice(ctx, cost.PriceQuery{InstanceType: launch.InstanceType, Region: launch.Region})
+	plannedSpend := price.Hourly*remaining + float64(launch.EBSSize)*cost.EBSPricePerGBMonth*remaining/cost.HoursPerMonth
+
+	for _, usage := range EvaluateBudgets(cfg.Budgets, instances, now) {
+		budget := usage.Budget
+		if !budget.Matches(planned) {
+			continue
+		}
+		if budget.PerInstance > 0 && plannedSpend > budget.PerInstance {
+			return fmt.Errorf("%w: %s would cost up to %s by the end of the month, over the per-instance cap of %s of budget %s (%s); use --override-budget to launch anyway",
+				ErrBudgetExceeded, launch.InstanceType, cost.FormatCostShort(plannedSpend),
+				cost.FormatCostShort(budget.PerInstance), budget.Name, budget.Scope())
+		}
+		if budget.Monthly > 0 {
+			projected := usage.Spend + plannedSpend
+			for _, spend := range usage.Instances {
+				if spend.Terminated() {
+					continue
+				}
+				projected += float64(spend.Instance.EBSSize) * cost.EBSPricePerGBMonth * remaining / cost.HoursPerMonth
+				if spend.Running() {
+					projected += spend.Calc.HourlyRate * remaining
+				}
+			}
+			if projected > budget.Monthly {
+				return fmt.Errorf("%w: projected spend of %s this month is over the monthly cap of %s of budget %s (%s, %s spent so far); use --override-budget to launch anyway",
+					ErrBudgetExceeded, cost.FormatCostShort(projected), cost.FormatCostShort(budget.Monthly),
+					budget.Name, budget.Scope(), cost.FormatCostShort(usage.Spend))
+			}
+		}
+	}
+	return nil
+}
+
+// hasUserBudget reports whether any budget selects instances by owner
+func hasUserBudget(budgets []config.Budget) bool {
+	for _, budget := range budgets {
+		if budget.User != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// callerARN returns the ARN of the principal of the profile
+func callerARN(ctx context.Context, profile string) (string, error) {
+	stsClient, err := aws.NewSTSClient(ctx, profile)
+	if err != nil {
+		return "", err
+	}
+	identity, err := stsClient.GetCallerIdentity(ctx)
+	if err != nil {
+		return "", err
+	}
+	return identity.ARN, nil
+}
+
+// NewBudgetCmd creates the budget command for managing and enforcing spend
+// caps. appName is the name of the CLI, used in examples (e.g. "lens-jupyter").
+func NewBudgetCmd(appName string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "budget",
+		Short: "Manage and enforce budgets",
+		Long: `Manage budgets that cap the monthly spend of instances.
+
+A budget applies to the instances matching all of its selectors: the owner's
+IAM user or role session name (--user), the app (--app) and the project tag
+(--project). A budget without selectors applies to every instance.
+
+launch refuses to start an instance when its projected spend until the end of
+the month would take a budget over its cap, unless --override-budget is given.
+"budget check" stops running instances of budgets that are over their cap and
+runs the on_budget_exceeded hook; run it from cron to enforce budgets.
+
+With a shared state backend, budgets count the instances of everyone sharing it.`,
+		Example: fmt.Sprintf(`  # Cap the team's total spend at $500 a month
+  %[1]s budget set team --monthly 500
+
+  # Cap each instance of a project at $50 a month
+  %[1]s budget set genomics --project genomics --per-instance 50
+
+  # Show spend this month against each budget
+  %[1]s budget list
+
+  # Stop instances over budget every 15 minutes (crontab)
+  */15 * * * * %[1]s budget check`, appName),
+	}
+
+	cmd.AddCommand(newBudgetListCmd())
+	cmd.AddCommand(newBudgetSetCmd())
+	cmd.AddCommand(newBudgetDeleteCmd())
+	cmd.AddCommand(newBudgetCheckCmd())
+
+	return cmd
+}
+
+func newBudgetListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "Show budgets and this month's spend",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunBudgetList()
+		},
+	}
+}
+
+// RunBudgetList prints each budget with this month's spend
+func RunBudgetList() error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if len(cfg.Budgets) == 0 {
+		fmt.Println("No budgets configured")
+		return nil
+	}
+	instances, err := loadBudgetInstances()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSCOPE\tSPENT\tMONTHLY\tPER INSTANCE\tINSTANCES\tSTATUS")
+	for _, usage := range EvaluateBudgets(cfg.Budgets, instances, time.Now()) {
+		status := "ok"
+		if usage.MonthlyExceeded() {
+			status = "over monthly cap"
+		} else if over := usage.OverInstanceCap(); len(over) > 0 {
+			status = fmt.Sprintf("%d over instance cap", len(over))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			usage.Budget.Name, usage.Budget.Scope(), cost.FormatCostShort(usage.Spend),
+			formatCap(usage.Budget.Monthly), formatCap(usage.Budget.PerInstance),
+			len(usage.Instances), status)
+	}
+	return w.Flush()
+}
+
+// formatCap formats a budget cap, "-" when it is not set
+func formatCap(limit float64) string {
+	if limit == 0 {
+		return "-"
+	}
+	return cost.FormatCostShort(limit)
+}
+
+func newBudgetSetCmd() *cobra.Command {
+	var budget config.Budget
+
+	cmd := &cobra.Command{
+		Use:   "set NAME",
+		Short: "Create or update a budget",
+		Long: `Create a budget, or update the given fields of an existing one.
+Budgets are stored in ~/.lens/config.yaml.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			budget.Name = args[0]
+			changed := func(name string) bool { return cmd.Flags().Changed(name) }
+			return RunBudgetSet(budget, changed)
+		},
+	}
+
+	cmd.Flags().StringVar(&budget.User, "user", "", "Only instances owned by this IAM user or role session")
+	cmd.Flags().StringVar(&budget.App, "app", "", "Only instances of this app (jupyter, rstudio or vscode)")
+	cmd.Flags().StringVar(&budget.Project, "project", "", "Only instances of this project")
+	cmd.Flags().Float64Var(&budget.Monthly, "monthly", 0, "Cap on the spend of all matched instances this month ($, 0 for none)")
+	cmd.Flags().Float64Var(&budget.PerInstance, "per-instance", 0, "Cap on the spend of each matched instance this month ($, 0 for none)")
+
+	return cmd
+}
+
+// RunBudgetSet saves a budget in the user config. For an existing budget only
+// the fields whose flag changed are updated.
+func RunBudgetSet(budget config.Budget, changed func(flag string) bool) error {
+	cfg, err := config.LoadUserConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	i := cfg.FindBudget(budget.Name)
+	if i < 0 {
+		cfg.Budgets = append(cfg.Budgets, budget)
+	} else {
+		existing := &cfg.Budgets[i]
+		if changed("user") {
+			existing.User = budget.User
+		}
+		if changed("app") {
+			existing.App = budget.App
+		}
+		if changed("project") {
+			existing.Project = budget.Project
+		}
+		if changed("monthly") {
+			existing.Monthly = budget.Monthly
+		}
+		if changed("per-instance") {
+			existing.PerInstance = budget.PerInstance
+		}
+		budget = *existing
+	}
+	if err := budget.Validate(); err != nil {
+		return err
+	}
+
+	if err := config.SaveUserConfig(cfg); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	fmt.Printf("Budget %s (%s): monthly %s, per instance %s\n",
+		budget.Name, budget.Scope(), formatCap(budget.Monthly), formatCap(budget.PerInstance))
+	return nil
+}
+
+func newBudgetDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete NAME",
+		Short: "Delete a budget",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunBudgetDelete(args[0])
+		},
+	}
+}
+
+// RunBudgetDelete removes a budget from the user config
+func RunBudgetDelete(name string) error {
+	cfg, err := config.LoadUserConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	i := cfg.FindBudget(name)
+	if i < 0 {
+		return fmt.Errorf("budget %s not found in %s", name, config.GetUserConfigPath())
+	}
+	cfg.Budgets = append(cfg.Budgets[:i], cfg.Budgets[i+1:]...)
+	if err := config.SaveUserConfig(cfg); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	fmt.Printf("Budget %s deleted\n", name)
+	return nil
+}
+
+// BudgetCheckOptions controls a budget check
+type BudgetCheckOptions struct {
+	Profile string
+	DryRun  bool
+}
+
+func newBudgetCheckCmd() *cobra.Command {
+	var opts BudgetCheckOptions
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Stop instances of budgets over their cap",
+		Long: `Stop running instances of budgets that reached their cap this month.
+
+When the matched instances together reach a budget's monthly cap, all of them
+are stopped. When an instance reaches the per-instance cap on its own, only
+that instance is stopped. The on_budget_exceeded hook runs for every instance
+stopped, with AWS_IDE_BUDGET, AWS_IDE_BUDGET_LIMIT and AWS_IDE_BUDGET_SPEND set.
+
+Instance states are refreshed from EC2 first, so stops lens did not record,
+such as idle stops, are counted.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunBudgetCheck(opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Profile, "profile", "p", "default", "AWS profile to use")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Show what would be stopped without stopping anything")
+
+	return cmd
+}
+
+// budgetStop is a running instance to stop and the cap it reached
+type budgetStop struct {
+	spend  InstanceSpend
+	budget config.Budget
+	limit  float64
+	total  float64 // Spend counted against the limit
+}
+
+// RunBudgetCheck stops the running instances of budgets over their cap
+func RunBudgetCheck(opts BudgetCheckOptions) error {
+	ctx := context.Background()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if len(cfg.Budgets) == 0 {
+		fmt.Println("No budgets configured")
+		return nil
+	}
+	instances, err := loadBudgetInstances()
+	if err != nil {
+		return err
+	}
+	refreshBudgetInstances(ctx, opts.Profile, instances)
+
+	var stops []budgetStop
+	planned := make(map[string]bool)
+	plan := func(stop budgetStop) {
+		if stop.spend.Running() && !planned[stop.spend.Instance.ID] {
+			planned[stop.spend.Instance.ID] = true
+			stops = append(stops, stop)
+		}
+	}
+	for _, usage := range EvaluateBudgets(cfg.Budgets, instances, time.Now()) {
+		budget := usage.Budget
+		if usage.MonthlyExceeded() {
+			fmt.Printf("Budget %s (%s) is over its monthly cap: %s of %s\n",
+				budget.Name, budget.Scope(), cost.FormatCostShort(usage.Spend), cost.FormatCostShort(budget.Monthly))
+			for _, spend := range usage.Instances {
+				plan(budgetStop{spend: spend, budget: budget, limit: budget.Monthly, total: usage.Spend})
+			}
+			continue
+		}
+		for _, spend := range usage.OverInstanceCap() {
+			fmt.Printf("Instance %s is over the per-instance cap of budget %s: %s of %s\n",
+				spend.Instance.ID, budget.Name, cost.FormatCostShort(spend.Spend), cost.FormatCostShort(budget.PerInstance))
+			plan(budgetStop{spend: spend, budget: budget, limit: budget.PerInstance, total: spend.Spend})
+		}
+	}
+
+	if len(stops) == 0 {
+		fmt.Println("No running instances over budget")
+		return nil
+	}
+	if opts.DryRun {
+		for _, stop := range stops {
+			fmt.Printf("Would stop %s (budget %s)\n", stop.spend.Instance.ID, stop.budget.Name)
+		}
+		return nil
+	}
+
+	// Instances of other users are stopped in AWS; their state catches up on their next sync
+	own, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	var failed int
+	for _, stop := range stops {
+		instance := stop.spend.Instance
+		if err := stopForBudget(ctx, opts.Profile, instance, own.Instances[instance.ID] != nil); err != nil {
+			fmt.Printf("Warning: Failed to stop %s: %v\n", instance.ID, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Stopped %s (budget %s)\n", instance.ID, stop.budget.Name)
+		_ = hooks.ExecuteHook(hooks.EventData{
+			EventType:    hooks.EventBudgetExceeded,
+			InstanceID:   instance.ID,
+			InstanceType: instance.InstanceType,
+			Environment:  instance.Environment,
+			Region:       instance.Region,
+			Timestamp:    time.Now(),
+			AppName:      strings.TrimPrefix(instance.App, "lens-"),
+			Budget:       stop.budget.Name,
+			BudgetLimit:  stop.limit,
+			BudgetSpend:  stop.total,
+		})
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to stop %d instance(s) over budget", failed)
+	}
+	return nil
+}
+
+// stopForBudget stops an instance and records the stop in local state when
+// the instance is the current user's
+func stopForBudget(ctx context.Context, profile string, instance *config.Instance, own bool) error {
+	ec2Client, err := aws.NewEC2ClientForProfileRegion(ctx, profile, instance.Region)
+	if err != nil {
+		return fmt.Errorf("failed to create AWS client: %w", err)
+	}
+	if err := ec2Client.StopInstance(ctx, instance.ID, false); err != nil {
+		return err
+	}
+	if !own {
+		return nil
+	}
+	return config.UpdateInstance(instance.ID, func(instance *config.Instance) error {
+		instance.RecordStateChange("stopped")
+		return nil
+	})
+}
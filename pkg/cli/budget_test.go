@@ -0,0 +1,321 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/cost"
+	"github.com/scttfrdmn/lens/pkg/fakecloud"
+)
+
+func TestEvaluateBudgets_SumsMonthToDateSpendOfMatchedInstances(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	alice := &config.Instance{
+		ID:           "i-alice",
+		App:          "lens-jupyter",
+		Project:      "genomics",
+		Owner:        "arn:aws:iam::123456789012:user/alice",
+		InstanceType: "t4g.medium",
+		Region:       "us-east-1",
+		LaunchedAt:   time.Date(2026, 2, 20, 0, 0, 0, 0, time.UTC), // Only March counts
+	}
+	bob := &config.Instance{
+		ID:           "i-bob",
+		App:          "lens-rstudio",
+		Owner:        "arn:aws:iam::123456789012:user/bob",
+		InstanceType: "t4g.medium",
+		Region:       "us-east-1",
+		LaunchedAt:   time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC),
+		StateChanges: []config.StateChange{
+			{State: "running", Timestamp: time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)},
+			{State: "stopped", Timestamp: time.Date(2026, 3, 14, 18, 0, 0, 0, time.UTC)},
+		},
+	}
+	budgets := []config.Budget{
+		{Name: "team", Monthly: 100},
+		{Name: "alice", User: "alice", PerInstance: 1},
+		{Name: "genomics", App: "jupyter", Project: "genomics", Monthly: 5},
+		{Name: "vscode", App: "vscode", Monthly: 5},
+	}
+
+	hourly := cost.LookupPrice(context.Background(), cost.PriceQuery{InstanceType: "t4g.medium", Region: "us-east-1"}).Hourly
+	aliceSpend := (14*24 + 12) * hourly
+	bobSpend := 6 * hourly
+
+	usages := EvaluateBudgets(budgets, []*config.Instance{alice, bob}, now)
+
+	tests := []struct {
+		name          string
+		wantSpend     float64
+		wantInstances int
+		wantMonthly   bool
+		wantOverCap   int
+	}{
+		{"team", aliceSpend + bobSpend, 2, false, 0},
+		{"alice", aliceSpend, 1, false, 1},
+		{"genomics", aliceSpend, 1, true, 0},
+		{"vscode", 0, 0, false, 0},
+	}
+	for i, tt := range tests {
+		usage := usages[i]
+		if usage.Budget.Name != tt.name {
+			t.Fatalf("Expected budget %s at %d, got %s", tt.name, i, usage.Budget.Name)
+		}
+		if math.Abs(usage.Spend-tt.wantSpend) > 0.001 {
+			t.Errorf("%s: expected spend %.3f, got %.3f", tt.name, tt.wantSpend, usage.Spend)
+		}
+		if len(usage.Instances) != tt.wantInstances {
+			t.Errorf("%s: expected %d instances, got %d", tt.name, tt.wantInstances, len(usage.Instances))
+		}
+		if usage.MonthlyExceeded() != tt.wantMonthly {
+			t.Errorf("%s: expected MonthlyExceeded %v", tt.name, tt.wantMonthly)
+		}
+		if got := len(usage.OverInstanceCap()); got != tt.wantOverCap {
+			t.Errorf("%s: expected %d instances over cap, got %d", tt.name, tt.wantOverCap, got)
+		}
+	}
+}
+
+// trackLaunched launches an instance in the fake cloud and tracks it in local
+// state as launched at launchedAt
+func trackLaunched(t *testing.T, region string, launchedAt time.Time, metadata aws.InstanceMetadata) string {
+	t.Helper()
+	id := launchTagged(t, region, metadata)
+	err := config.UpdateState(func(state *config.LocalState) error {
+		state.Instances[id] = &config.Instance{
+			ID:           id,
+			App:          metadata.App,
+			Project:      metadata.Project,
+			Owner:        metadata.Owner,
+			InstanceType: "t4g.medium",
+			Region:       region,
+			LaunchedAt:   launchedAt,
+			EBSSize:      30,
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateState failed: %v", err)
+	}
+	return id
+}
+
+func TestRunBudgetCheck_StopsInstancesOverCapAndRunsHook(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cloud := fakecloud.Install(t)
+	cloud.SetRegions("us-east-1", "us-west-2")
+
+	launchedAt := cost.MonthStart(time.Now())
+	genomics := trackLaunched(t, "us-east-1", launchedAt, aws.InstanceMetadata{App: "lens-jupyter", Project: "genomics"})
+	other := trackLaunched(t, "us-west-2", launchedAt, aws.InstanceMetadata{App: "lens-jupyter", Project: "other"})
+
+	hookLog := filepath.Join(home, "hook.log")
+	cfg := &config.UserConfig{
+		Budgets: []config.Budget{{Name: "genomics", Project: "genomics", Monthly: 0.0001}},
+		Hooks:   &config.HooksConfig{OnBudgetExceeded: `echo "$AWS_IDE_BUDGET $AWS_IDE_INSTANCE_ID" >> ` + hookLog},
+	}
+	if err := config.SaveUserConfig(cfg); err != nil {
+		t.Fatalf("SaveUserConfig failed: %v", err)
+	}
+
+	if err := RunBudgetCheck(BudgetCheckOptions{Profile: "default", DryRun: true}); err != nil {
+		t.Fatalf("RunBudgetCheck --dry-run failed: %v", err)
+	}
+	if instance, _ := cloud.Instance(genomics); stoppedOrStopping(instance) {
+		t.Fatalf("Expected --dry-run to leave %s running, got %s", genomics, instance.State.Name)
+	}
+
+	if err := RunBudgetCheck(BudgetCheckOptions{Profile: "default"}); err != nil {
+		t.Fatalf("RunBudgetCheck failed: %v", err)
+	}
+
+	for id, wantStopped := range map[string]bool{genomics: true, other: false} {
+		instance, ok := cloud.Instance(id)
+		if !ok {
+			t.Fatalf("instance %s not found", id)
+		}
+		if stoppedOrStopping(instance) != wantStopped {
+			t.Errorf("Expected %s to be stopped: %v, got %s", id, wantStopped, instance.State.Name)
+		}
+	}
+
+	state, err := config.LoadState()
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	changes := state.Instances[genomics].StateChanges
+	if len(changes) == 0 || changes[len(changes)-1].State != "stopped" {
+		t.Errorf("Expected the stop to be recorded, got %v", changes)
+	}
+
+	log, err := os.ReadFile(hookLog)
+	if err != nil {
+		t.Fatalf("Expected the on_budget_exceeded hook to run: %v", err)
+	}
+	if got := strings.TrimSpace(string(log)); got != "genomics "+genomics {
+		t.Errorf("Expected hook output %q, got %q", "genomics "+genomics, got)
+	}
+}
+
+func TestRunBudgetCheck_CountsStopsLensDidNotRecord(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+
+	// Launched at the start of the month, then stopped by lens-agent after a
+	// tenth of the month so far without lens recording it
+	launchedAt := cost.MonthStart(time.Now())
+	stoppedAt := launchedAt.Add(time.Since(launchedAt) / 10)
+	cloud.SetClock(func() time.Time { return launchedAt })
+	idle := trackLaunched(t, "us-east-1", launchedAt, aws.InstanceMetadata{App: "lens-jupyter", Project: "genomics"})
+	cloud.SetClock(func() time.Time { return stoppedAt })
+	ec2Client, err := aws.NewEC2ClientForProfileRegion(context.Background(), "default", "us-east-1")
+	if err != nil {
+		t.Fatalf("NewEC2ClientForProfileRegion failed: %v", err)
+	}
+	if err := ec2Client.StopInstance(context.Background(), idle, false); err != nil {
+		t.Fatalf("StopInstance failed: %v", err)
+	}
+	cloud.SetClock(time.Now)
+	running := trackLaunched(t, "us-west-2", time.Now(), aws.InstanceMetadata{App: "lens-jupyter", Project: "genomics"})
+
+	// Under the cap with the stop, over it if the instance ran all month
+	state, err := config.LoadState()
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	recorded := EvaluateBudgets([]config.Budget{{Name: "genomics", Project: "genomics"}}, []*config.Instance{state.Instances[idle]}, time.Now())
+	if err := config.SaveUserConfig(&config.UserConfig{
+		Budgets: []config.Budget{{Name: "genomics", Project: "genomics", Monthly: recorded[0].Spend * 0.6}},
+	}); err != nil {
+		t.Fatalf("SaveUserConfig failed: %v", err)
+	}
+
+	if err := RunBudgetCheck(BudgetCheckOptions{Profile: "default"}); err != nil {
+		t.Fatalf("RunBudgetCheck failed: %v", err)
+	}
+	if instance, _ := cloud.Instance(running); stoppedOrStopping(instance) {
+		t.Errorf("Expected %s to keep running once the unrecorded stop of %s is counted", running, idle)
+	}
+}
+
+// stoppedOrStopping reports whether a fake instance was stopped
+func stoppedOrStopping(instance types.Instance) bool {
+	return instance.State.Name == types.InstanceStateNameStopping || instance.State.Name == types.InstanceStateNameStopped
+}
+
+func TestCheckLaunchBudget_RefusesLaunchOverCap(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	fakecloud.Install(t)
+
+	cfg := &config.UserConfig{
+		Budgets: []config.Budget{
+			{Name: "researcher", User: "researcher", PerInstance: 5},
+			{Name: "rstudio", App: "rstudio", Monthly: 1000},
+		},
+	}
+	if err := config.SaveUserConfig(cfg); err != nil {
+		t.Fatalf("SaveUserConfig failed: %v", err)
+	}
+
+	launch := PlannedLaunch{Profile: "default", App: "lens-jupyter", InstanceType: "m7g.xlarge", Region: "us-east-1", EBSSize: 30}
+	err := CheckLaunchBudget(context.Background(), launch)
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Expected ErrBudgetExceeded for the caller's per-instance cap, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "--override-budget") {
+		t.Errorf("Expected the error to mention --override-budget, got %v", err)
+	}
+
+	launch.InstanceType = "t4g.nano"
+	if err := CheckLaunchBudget(context.Background(), launch); err != nil {
+		t.Errorf("Expected a small instance to fit the budget, got %v", err)
+	}
+
+	launch.App = "lens-rstudio"
+	launch.InstanceType = "t4g.nano"
+	if err := CheckLaunchBudget(context.Background(), launch); err != nil {
+		t.Errorf("Expected rstudio launch within budget, got %v", err)
+	}
+}
+
+func TestCheckLaunchBudget_IgnoresStorageOfTerminatedInstances(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	fakecloud.Install(t)
+
+	if err := config.SaveUserConfig(&config.UserConfig{
+		Budgets: []config.Budget{{Name: "team", Monthly: 10}},
+	}); err != nil {
+		t.Fatalf("SaveUserConfig failed: %v", err)
+	}
+
+	// Large volumes, terminated last month: nothing is left to pay for
+	launchedAt := cost.MonthStart(time.Now()).AddDate(0, -1, 0)
+	err := config.UpdateState(func(state *config.LocalState) error {
+		for i := 0; i < 10; i++ {
+			state.Terminated = append(state.Terminated, &config.Instance{
+				ID:           fmt.Sprintf("i-terminated%d", i),
+				App:          "lens-jupyter",
+				InstanceType: "t4g.medium",
+				Region:       "us-east-1",
+				LaunchedAt:   launchedAt,
+				EBSSize:      16000,
+				StateChanges: []config.StateChange{
+					{State: "running", Timestamp: launchedAt},
+					{State: "terminated", Timestamp: launchedAt.Add(time.Hour)},
+				},
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateState failed: %v", err)
+	}
+
+	launch := PlannedLaunch{Profile: "default", App: "lens-jupyter", InstanceType: "t4g.nano", Region: "us-east-1", EBSSize: 8}
+	if err := CheckLaunchBudget(context.Background(), launch); err != nil {
+		t.Errorf("Expected terminated instances not to count towards the projection, got %v", err)
+	}
+}
+
+func TestRunBudgetSet_UpdatesOnlyChangedFields(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	all := func(string) bool { return true }
+	if err := RunBudgetSet(config.Budget{Name: "lab", Project: "genomics", Monthly: 200}, all); err != nil {
+		t.Fatalf("RunBudgetSet failed: %v", err)
+	}
+	onlyPerInstance := func(flag string) bool { return flag == "per-instance" }
+	if err := RunBudgetSet(config.Budget{Name: "lab", PerInstance: 40}, onlyPerInstance); err != nil {
+		t.Fatalf("RunBudgetSet failed: %v", err)
+	}
+
+	cfg, err := config.LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig failed: %v", err)
+	}
+	want := config.Budget{Name: "lab", Project: "genomics", Monthly: 200, PerInstance: 40}
+	if len(cfg.Budgets) != 1 || cfg.Budgets[0] != want {
+		t.Errorf("Expected %+v, got %+v", want, cfg.Budgets)
+	}
+
+	if err := RunBudgetSet(config.Budget{Name: "empty"}, all); err == nil {
+		t.Error("Expected a budget without caps to be rejected")
+	}
+	if err := RunBudgetDelete("lab"); err != nil {
+		t.Fatalf("RunBudgetDelete failed: %v", err)
+	}
+	if err := RunBudgetDelete("lab"); err == nil {
+		t.Error("Expected deleting a missing budget to fail")
+	}
+}
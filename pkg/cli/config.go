@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// LaunchConfigKeys maps launch flags to the config keys that provide their
+// defaults, most specific first. app is the short app name (e.g. "jupyter").
+// The global default_instance_type is not used: every environment names its
+// own type, and one type for all apps would put GPU and x86 environments on it.
+func LaunchConfigKeys(app string) map[string][]string {
+	return map[string][]string{
+		"env":           {app + ".default_environment"},
+		"instance-type": {app + ".default_instance_type"},
+		"idle-timeout":  {"idle_timeout"},
+		"profile":       {"default_profile"},
+		"region":        {"default_region"},
+		"subnet-type":   {"default_subnet_type"},
+		"project":       {"default_project"},
+		"idle-alarm":    {"idle_alarm"},
+		"ttl":           {"default_ttl"},
+	}
+}
+
+// ApplyConfigDefaults sets flags that were not given on the command line from
+// the effective configuration. Built-in defaults are left to the flag's own
+// default, so e.g. an environment's instance type still applies unless a
+// config file, .lens.yaml or LENS_* variable sets the app's one.
+func ApplyConfigDefaults(cmd *cobra.Command, keys map[string][]string) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	for flagName, configKeys := range keys {
+		flag := cmd.Flags().Lookup(flagName)
+		if flag == nil || flag.Changed {
+			continue
+		}
+		for _, key := range configKeys {
+			value, source, ok := cfg.Get(key)
+			if !ok || value == "" || source.Layer == config.LayerDefault {
+				continue
+			}
+			if err := flag.Value.Set(value); err != nil {
+				return fmt.Errorf("invalid %s from %s for --%s: %w", key, source, flagName, err)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// NewConfigExplainCmd creates the config explain subcommand
+func NewConfigExplainCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "explain",
+		Short: "Show effective configuration and where each value came from",
+		Long: `Show every configuration key, its effective value and the layer that set it.
+
+Layers, from lowest to highest precedence:
+  default  Built-in defaults
+  system   ` + config.SystemConfigPath() + `
+  user     ~/.lens/config.yaml
+  project  .lens.yaml in the current directory or its nearest parent
+  env      LENS_* environment variables (e.g. LENS_IDLE_TIMEOUT, LENS_JUPYTER_DEFAULT_ENVIRONMENT)
+
+Command-line flags override all layers.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunConfigExplain()
+		},
+	}
+}
+
+// RunConfigExplain prints the effective configuration with value sources
+func RunConfigExplain() error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	files := cfg.Files()
+	if len(files) == 0 {
+		fmt.Println("Config files: none found, using built-in defaults")
+	} else {
+		fmt.Println("Config files:")
+		for _, file := range files {
+			fmt.Printf("  %-8s %s\n", file.Layer, file.Origin)
+		}
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, key := range config.ConfigKeys() {
+		value, source, ok := cfg.Get(key.Name)
+		if !ok {
+			value = "(not set)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", key.Name, value, source)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println("Command-line flags override these values.")
+	return nil
+}
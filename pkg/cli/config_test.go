@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+func TestApplyConfigDefaults(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := config.SaveUserConfig(&config.UserConfig{
+		DefaultInstanceType: "c7i.large",
+		IdleAlarm:           true,
+		DefaultTTL:          "30d",
+		Jupyter:             &config.AppConfig{DefaultEnvironment: "ml-pytorch", DefaultInstanceType: "m7g.large"},
+	}); err != nil {
+		t.Fatalf("Failed to save user config: %v", err)
+	}
+	t.Setenv("LENS_IDLE_TIMEOUT", "1h")
+	t.Setenv("LENS_DEFAULT_PROFILE", "research")
+
+	var env, instanceType, idleTimeout, profile, subnetType, ttl string
+	var idleAlarm bool
+	cmd := &cobra.Command{Use: "launch"}
+	cmd.Flags().StringVar(&env, "env", "data-science", "")
+	cmd.Flags().StringVar(&instanceType, "instance-type", "", "")
+	cmd.Flags().StringVar(&idleTimeout, "idle-timeout", "4h", "")
+	cmd.Flags().StringVar(&profile, "profile", "default", "")
+	cmd.Flags().StringVar(&subnetType, "subnet-type", "public", "")
+	cmd.Flags().BoolVar(&idleAlarm, "idle-alarm", false, "")
+	cmd.Flags().StringVar(&ttl, "ttl", "", "")
+	if err := cmd.Flags().Parse([]string{"--profile", "admin"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	if err := ApplyConfigDefaults(cmd, LaunchConfigKeys("jupyter")); err != nil {
+		t.Fatalf("ApplyConfigDefaults failed: %v", err)
+	}
+
+	if env != "ml-pytorch" {
+		t.Errorf("Expected --env from user config, got %q", env)
+	}
+	if instanceType != "m7g.large" {
+		t.Errorf("Expected --instance-type from jupyter.default_instance_type, got %q", instanceType)
+	}
+	if idleTimeout != "1h" {
+		t.Errorf("Expected --idle-timeout from LENS_IDLE_TIMEOUT, got %q", idleTimeout)
+	}
+	if !idleAlarm {
+		t.Error("Expected --idle-alarm from user config")
+	}
+	if ttl != "30d" {
+		t.Errorf("Expected --ttl from default_ttl, got %q", ttl)
+	}
+	if profile != "admin" {
+		t.Errorf("Expected an explicit --profile to win, got %q", profile)
+	}
+	if subnetType != "public" {
+		t.Errorf("Expected --subnet-type to keep its default, got %q", subnetType)
+	}
+}
+
+func TestApplyConfigDefaults_GlobalInstanceTypeLeavesEnvironmentType(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	// budget set and config set save the whole user config
+	if err := config.SaveUserConfig(&config.UserConfig{DefaultInstanceType: "t4g.medium"}); err != nil {
+		t.Fatalf("Failed to save user config: %v", err)
+	}
+	t.Setenv("LENS_DEFAULT_INSTANCE_TYPE", "c7i.large")
+
+	var instanceType string
+	cmd := &cobra.Command{Use: "launch"}
+	cmd.Flags().StringVar(&instanceType, "instance-type", "", "")
+	if err := ApplyConfigDefaults(cmd, LaunchConfigKeys("jupyter")); err != nil {
+		t.Fatalf("ApplyConfigDefaults failed: %v", err)
+	}
+	if instanceType != "" {
+		t.Errorf("Expected the environment's instance type to be kept, got --instance-type %q", instanceType)
+	}
+}
@@ -16,7 +16,7 @@ func NewCreateAMICmd() *cobra.Command {
 	var noReboot bool
 
 	cmd := &cobra.Command{
-		Use:   "create-ami INSTANCE_ID",
+		Use:   "create-ami [INSTANCE]",
 		Short: "Create an AMI from an instance",
 		Long: `Create an Amazon Machine Image (AMI) from an existing instance.
 
@@ -25,9 +25,13 @@ to quickly launch new instances with the same configuration and installed packag
 
 By default, the instance will be rebooted to ensure filesystem consistency.
 Use --no-reboot to create the AMI without rebooting (not recommended for production).`,
-		Args: cobra.ExactArgs(1),
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runCreateAMI(args[0], name, noReboot)
+			instanceRef := ""
+			if len(args) > 0 {
+				instanceRef = args[0]
+			}
+			return runCreateAMI(instanceRef, name, noReboot)
 		},
 	}
 
@@ -37,7 +41,7 @@ Use --no-reboot to create the AMI without rebooting (not recommended for product
 	return cmd
 }
 
-func runCreateAMI(instanceID string, name string, noReboot bool) error {
+func runCreateAMI(instanceRef string, name string, noReboot bool) error {
 	ctx := context.Background()
 
 	// Load state to get instance details
@@ -46,11 +50,12 @@ func runCreateAMI(instanceID string, name string, noReboot bool) error {
 		return fmt.Errorf("failed to load state: %w", err)
 	}
 
-	// Get instance from state
-	instance, exists := state.Instances[instanceID]
-	if !exists {
-		return fmt.Errorf("instance %s not found in local state", instanceID)
+	// Resolve the instance by name, ID or prefix
+	instance, err := ResolveInstance(state, instanceRef)
+	if err != nil {
+		return err
 	}
+	instanceID := instance.ID
 
 	// Create AWS client for the instance's region
 	ec2Client, err := aws.NewEC2ClientForRegion(ctx, instance.Region)
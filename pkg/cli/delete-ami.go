@@ -156,11 +156,6 @@ func runDeleteAllAMIs(region string) error {
 }
 
 func cleanupStateFile(deletedAMIs []aws.AMIInfo) {
-	state, err := config.LoadState()
-	if err != nil {
-		return
-	}
-
 	// Build set of deleted AMI IDs
 	deletedSet := make(map[string]bool)
 	for _, ami := range deletedAMIs {
@@ -168,17 +163,16 @@ func cleanupStateFile(deletedAMIs []aws.AMIInfo) {
 	}
 
 	// Remove instances that were using deleted AMIs
-	modified := false
-	for id, instance := range state.Instances {
-		// Note: state doesn't track AMI ID currently, only AMIBase
-		// This is a placeholder for future enhancement
-		_ = id
-		_ = instance
-	}
-
-	if modified {
-		if err := state.Save(); err != nil {
-			fmt.Printf("Warning: failed to save state file: %v\n", err)
+	err := config.UpdateState(func(state *config.LocalState) error {
+		for id, instance := range state.Instances {
+			// Note: state doesn't track AMI ID currently, only AMIBase
+			// This is a placeholder for future enhancement
+			_ = id
+			_ = instance
 		}
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Warning: failed to save state file: %v\n", err)
 	}
 }
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// NewEnvShowCmd creates the env show subcommand for printing an environment
+func NewEnvShowCmd() *cobra.Command {
+	var resolved bool
+
+	cmd := &cobra.Command{
+		Use:   "show ENV_NAME",
+		Short: "Show an environment configuration",
+		Long: `Show an environment configuration as YAML.
+
+By default the environment is printed as written. With --resolved, the
+environments it extends are merged in: lists are appended without
+duplicates, environment variables are merged and scalars set by the child
+override its parents.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunEnvShow(args[0], resolved)
+		},
+	}
+
+	cmd.Flags().BoolVar(&resolved, "resolved", false, "Show the environment with inheritance resolved")
+
+	return cmd
+}
+
+// RunEnvShow prints the environment name as YAML
+func RunEnvShow(name string, resolved bool) error {
+	load := config.LoadEnvironmentDefinition
+	if resolved {
+		load = config.LoadEnvironment
+	}
+
+	env, err := load(name)
+	if err != nil {
+		return fmt.Errorf("failed to load environment: %w", err)
+	}
+
+	data, err := yaml.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("failed to marshal environment: %w", err)
+	}
+	fmt.Print(string(data))
+	return nil
+}
@@ -0,0 +1,292 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/cost"
+	"github.com/scttfrdmn/lens/pkg/transaction"
+	"github.com/spf13/cobra"
+)
+
+// GCOptions controls a gc run
+type GCOptions struct {
+	Profile     string
+	Region      string // Only scan this region; all enabled regions if empty
+	DryRun      bool
+	Yes         bool // Skip the confirmation prompt
+	IncludeAMIs bool
+}
+
+// gcDeleteOrder is the order in which orphans are deleted, so that each
+// resource is gone before the resources it depends on
+var gcDeleteOrder = map[aws.ResourceKind]int{
+	aws.ResourceRoute:           0,
+	aws.ResourceNATGateway:      1,
+	aws.ResourceElasticIP:       2,
+	aws.ResourceSecurityGroup:   3,
+	aws.ResourceKeyPair:         4,
+	aws.ResourceAMI:             5,
+	aws.ResourceSnapshot:        6,
+	aws.ResourceInstanceProfile: 7,
+	aws.ResourceIAMRole:         8,
+	aws.ResourceAlarm:           9,
+	aws.ResourceSchedule:        10,
+}
+
+// NewGCCmd creates the gc command for removing resources left behind by lens.
+// appName is the name of the CLI, used in examples (e.g. "lens-jupyter").
+func NewGCCmd(appName string) *cobra.Command {
+	var opts GCOptions
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Find and remove orphaned lens resources",
+		Long: `Find AWS resources created by any lens tool that no live instance references
+any more, and delete them.
+
+Interrupted launches, manual terminations and failed cleanups can leave behind
+security groups, key pairs, NAT Gateways with their Elastic IPs, Session Manager
+IAM roles, AMI snapshots, idle alarms and start/stop schedules. NAT Gateways
+cost around $32/month each even when nothing uses them.
+
+Resources are recognised by their CreatedBy tag or lens naming convention, and
+are only reported when no pending, running, stopping or stopped instance uses
+them. Every enabled region is scanned unless --region is given; IAM roles are
+only checked when all regions are scanned.
+
+Custom AMIs are kept unless --include-amis is given. Snapshots whose AMI has
+already been deregistered are always reported.`,
+		Example: fmt.Sprintf(`  # Show what would be removed and what it costs
+  %[1]s gc --dry-run
+
+  # Remove orphaned resources after confirmation
+  %[1]s gc
+
+  # Only clean up one region, without prompting
+  %[1]s gc --region us-west-2 --yes
+
+  # Also remove custom AMIs no instance was launched from
+  %[1]s gc --include-amis`, appName),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunGC(opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Profile, "profile", "p", "default", "AWS profile to use")
+	cmd.Flags().StringVarP(&opts.Region, "region", "r", "", "Only scan this region (default: all enabled regions)")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "List orphaned resources without deleting them")
+	cmd.Flags().BoolVarP(&opts.Yes, "yes", "y", false, "Skip confirmation prompt")
+	cmd.Flags().BoolVar(&opts.IncludeAMIs, "include-amis", false, "Also remove lens AMIs that no live instance was launched from")
+
+	return cmd
+}
+
+// RunGC finds orphaned lens resources, prints them with their monthly cost
+// and deletes them unless opts.DryRun is set
+func RunGC(opts GCOptions) error {
+	ctx := context.Background()
+
+	orphans, err := FindOrphans(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	if len(orphans) == 0 {
+		fmt.Println("No orphaned lens resources found")
+		return nil
+	}
+
+	printOrphans(orphans)
+
+	if opts.DryRun {
+		fmt.Println("\nDry run: nothing was deleted. Run without --dry-run to remove these resources.")
+		return nil
+	}
+
+	if !opts.Yes {
+		fmt.Printf("\nDelete %d resource(s)? This cannot be undone. (yes/no): ", len(orphans))
+		var response string
+		if _, err := fmt.Scanln(&response); err != nil || (response != "yes" && response != "y") {
+			fmt.Println("Cleanup cancelled")
+			return nil
+		}
+	}
+
+	return deleteOrphans(ctx, opts.Profile, orphans)
+}
+
+// FindOrphans scans the selected regions and, when every region was scanned,
+// IAM for orphaned lens resources. Resources recorded in a launch journal are
+// skipped: they belong to a launch in progress or are rolled back by the next
+// launch.
+func FindOrphans(ctx context.Context, opts GCOptions) ([]aws.Orphan, error) {
+	regions := []string{opts.Region}
+	if opts.Region == "" {
+		ec2Client, err := aws.NewEC2Client(ctx, opts.Profile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create AWS client: %w", err)
+		}
+		regions, err = ec2Client.ListRegions(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var orphans []aws.Orphan
+	var liveProfiles []string
+	complete := opts.Region == ""
+	for _, region := range regions {
+		fmt.Printf("Scanning %s...\n", region)
+		ec2Client, err := aws.NewEC2ClientForProfileRegion(ctx, opts.Profile, region)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create AWS client for %s: %w", region, err)
+		}
+
+		scan, err := ec2Client.ScanOrphans(ctx, opts.IncludeAMIs)
+		if err != nil {
+			if opts.Region != "" {
+				return nil, err
+			}
+			fmt.Printf("Warning: Skipping region %s: %v\n", region, err)
+			complete = false
+			continue
+		}
+		orphans = append(orphans, scan.Orphans...)
+		liveProfiles = append(liveProfiles, scan.InstanceProfiles...)
+
+		cwClient, err := aws.NewCloudWatchClient(ctx, opts.Profile, region)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CloudWatch client for %s: %w", region, err)
+		}
+		if alarms, err := cwClient.OrphanedIdleAlarms(ctx, scan.Instances); err != nil {
+			fmt.Printf("Warning: Not checking idle alarms in %s: %v\n", region, err)
+		} else {
+			orphans = append(orphans, alarms...)
+		}
+
+		schedulerClient, err := aws.NewSchedulerClient(ctx, opts.Profile, region)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Scheduler client for %s: %w", region, err)
+		}
+		if schedules, err := schedulerClient.OrphanedSchedules(ctx, scan.Instances); err != nil {
+			fmt.Printf("Warning: Not checking schedules in %s: %v\n", region, err)
+		} else {
+			orphans = append(orphans, schedules...)
+		}
+	}
+
+	if complete {
+		iamClient, err := aws.NewIAMClient(ctx, opts.Profile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create IAM client: %w", err)
+		}
+		roles, err := iamClient.FindOrphanedRoles(ctx, liveProfiles)
+		if err != nil {
+			return nil, err
+		}
+		orphans = append(orphans, roles...)
+	} else if opts.Region == "" {
+		fmt.Println("Warning: Not checking IAM roles because some regions could not be scanned")
+	}
+
+	journaled, err := transaction.Journaled()
+	if err != nil {
+		return nil, err
+	}
+	if len(journaled) == 0 {
+		return orphans, nil
+	}
+
+	inJournal := make(map[aws.Resource]bool)
+	for _, resource := range journaled {
+		inJournal[resource] = true
+	}
+	filtered := orphans[:0]
+	for _, orphan := range orphans {
+		if inJournal[orphan.Resource] {
+			continue
+		}
+		filtered = append(filtered, orphan)
+	}
+	return filtered, nil
+}
+
+// OrphanMonthlyCost estimates what an orphaned resource costs per month
+func OrphanMonthlyCost(orphan aws.Orphan) float64 {
+	switch orphan.Kind {
+	case aws.ResourceNATGateway:
+		return cost.NATGatewayPricePerHour * cost.HoursPerMonth
+	case aws.ResourceElasticIP:
+		return cost.PublicIPv4PricePerHour * cost.HoursPerMonth
+	case aws.ResourceAMI, aws.ResourceSnapshot:
+		return float64(orphan.SizeGB) * cost.EBSSnapshotPricePerGBMonth
+	}
+	return 0
+}
+
+// printOrphans prints orphans as a table with their monthly cost
+func printOrphans(orphans []aws.Orphan) {
+	fmt.Printf("\nFound %d orphaned resource(s):\n\n", len(orphans))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REGION\tTYPE\tID\tNAME\tREASON\tCOST/MONTH")
+
+	total := 0.0
+	for _, orphan := range orphans {
+		region := orphan.Region
+		if orphan.IsIAM() {
+			region = "global"
+		}
+		id := orphan.ID
+		if orphan.Parent != "" {
+			id = fmt.Sprintf("%s (%s)", orphan.ID, orphan.Parent)
+		}
+		name := orphan.Name
+		if name == "" {
+			name = "-"
+		}
+		monthly := OrphanMonthlyCost(orphan)
+		total += monthly
+		costText := "-"
+		if monthly > 0 {
+			costText = cost.FormatCostShort(monthly)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", region, orphan.Kind, id, name, orphan.Reason, costText)
+	}
+	_ = w.Flush()
+
+	fmt.Printf("\nEstimated savings: %s/month\n", cost.FormatCostShort(total))
+}
+
+// deleteOrphans deletes orphans in dependency order and reports failures
+func deleteOrphans(ctx context.Context, profile string, orphans []aws.Orphan) error {
+	ordered := append([]aws.Orphan(nil), orphans...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return gcDeleteOrder[ordered[i].Kind] < gcDeleteOrder[ordered[j].Kind]
+	})
+
+	cleaner := transaction.NewAWSCleaner(profile)
+	fmt.Printf("\nDeleting %d resource(s)...\n", len(ordered))
+
+	failCount := 0
+	for _, orphan := range ordered {
+		fmt.Printf("Deleting %s...\n", orphan.Resource)
+		if err := cleaner.DeleteResource(ctx, orphan.Resource); err != nil {
+			fmt.Printf("  ✗ Failed: %v\n", err)
+			failCount++
+			continue
+		}
+		fmt.Printf("  ✓ Deleted\n")
+	}
+
+	fmt.Printf("\nSummary: %d deleted, %d failed\n", len(ordered)-failCount, failCount)
+	if failCount > 0 {
+		return fmt.Errorf("failed to delete %d resource(s)", failCount)
+	}
+	return nil
+}
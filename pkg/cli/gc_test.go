@@ -0,0 +1,347 @@
+package cli
+
+import (
+	"context"
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/fakecloud"
+	"github.com/scttfrdmn/lens/pkg/transaction"
+)
+
+// gcFixture holds the IDs of the resources created by seedGCCloud
+type gcFixture struct {
+	liveInstance string
+	liveGroup    string
+	strayGroup   string
+	userGroup    string
+	strayAddress string
+	natGateway   string
+	natAddress   string
+	routeTable   string
+	keptAMI      string
+	strayKey     string
+}
+
+var lensTags = []types.Tag{
+	{Key: awssdk.String("CreatedBy"), Value: awssdk.String("lens-jupyter-cli")},
+}
+
+// seedGCCloud creates a live lens instance in us-east-1 together with resources
+// it uses, and orphaned lens resources in us-east-1 and us-west-2
+func seedGCCloud(t *testing.T, cloud *fakecloud.Cloud) gcFixture {
+	t.Helper()
+	ctx := context.Background()
+	var f gcFixture
+
+	cloud.SetRegions("us-east-1", "us-west-2")
+
+	iamClient, err := aws.NewIAMClient(ctx, "default")
+	if err != nil {
+		t.Fatalf("NewIAMClient failed: %v", err)
+	}
+	profile, err := iamClient.GetOrCreateSessionManagerRole(ctx, "lens-jupyter")
+	if err != nil {
+		t.Fatalf("GetOrCreateSessionManagerRole failed: %v", err)
+	}
+	if _, err := iamClient.GetOrCreateSessionManagerRole(ctx, "lens-vscode"); err != nil {
+		t.Fatalf("GetOrCreateSessionManagerRole failed: %v", err)
+	}
+
+	ec2Client, err := aws.NewEC2Client(ctx, "default")
+	if err != nil {
+		t.Fatalf("NewEC2Client failed: %v", err)
+	}
+	subnet, err := ec2Client.GetSubnet(ctx, "public", "")
+	if err != nil {
+		t.Fatalf("GetSubnet failed: %v", err)
+	}
+	group, err := ec2Client.GetOrCreateSecurityGroup(ctx, aws.DefaultSecurityGroupStrategy(subnet.VpcID))
+	if err != nil {
+		t.Fatalf("GetOrCreateSecurityGroup failed: %v", err)
+	}
+	f.liveGroup = group.ID
+	key, err := ec2Client.CreateKeyPair(ctx, "lens-jupyter-us-east-1")
+	if err != nil {
+		t.Fatalf("CreateKeyPair failed: %v", err)
+	}
+	ami, err := aws.NewAMISelector(ec2Client.GetRegion()).GetAMI(ctx, ec2Client, "ubuntu24-arm64")
+	if err != nil {
+		t.Fatalf("GetAMI failed: %v", err)
+	}
+	inst, err := ec2Client.LaunchInstance(ctx, aws.LaunchParams{
+		AMI:             ami,
+		InstanceType:    "t4g.medium",
+		SecurityGroupID: group.ID,
+		SubnetID:        subnet.ID,
+		KeyPairName:     key.Name,
+		EBSVolumeSize:   20,
+		Environment:     "minimal",
+		InstanceProfile: profile.Name,
+	})
+	if err != nil {
+		t.Fatalf("LaunchInstance failed: %v", err)
+	}
+	f.liveInstance = *inst.InstanceId
+
+	stray, err := ec2Client.CreateSecurityGroup(ctx, "lens-vscode-session-manager", subnet.VpcID)
+	if err != nil {
+		t.Fatalf("CreateSecurityGroup failed: %v", err)
+	}
+	f.strayGroup = stray.ID
+	f.strayKey = "lens-jupyter-old"
+	if _, err := ec2Client.CreateKeyPair(ctx, f.strayKey); err != nil {
+		t.Fatalf("CreateKeyPair failed: %v", err)
+	}
+
+	east := cloud.EC2("us-east-1")
+	userGroup, err := east.CreateSecurityGroup(ctx, &ec2.CreateSecurityGroupInput{
+		GroupName:   awssdk.String("my-analysis"),
+		Description: awssdk.String("Created by the user"),
+	})
+	if err != nil {
+		t.Fatalf("CreateSecurityGroup failed: %v", err)
+	}
+	f.userGroup = *userGroup.GroupId
+	address, err := east.AllocateAddress(ctx, &ec2.AllocateAddressInput{
+		TagSpecifications: []types.TagSpecification{{ResourceType: types.ResourceTypeElasticIp, Tags: lensTags}},
+	})
+	if err != nil {
+		t.Fatalf("AllocateAddress failed: %v", err)
+	}
+	f.strayAddress = *address.AllocationId
+
+	// One AMI is deleted without its snapshot, the other is kept
+	for _, name := range []string{"lens-deleted", "lens-kept"} {
+		amiID, err := ec2Client.CreateAMI(ctx, f.liveInstance, name, "test image", true)
+		if err != nil {
+			t.Fatalf("CreateAMI failed: %v", err)
+		}
+		if name == "lens-kept" {
+			f.keptAMI = amiID
+			continue
+		}
+		if _, err := east.DeregisterImage(ctx, &ec2.DeregisterImageInput{ImageId: awssdk.String(amiID)}); err != nil {
+			t.Fatalf("DeregisterImage failed: %v", err)
+		}
+	}
+
+	// A NAT Gateway left behind in us-west-2, with its Elastic IP and route
+	westClient, err := aws.NewEC2ClientForRegion(ctx, "us-west-2")
+	if err != nil {
+		t.Fatalf("NewEC2ClientForRegion failed: %v", err)
+	}
+	private, err := westClient.GetSubnet(ctx, "private", "")
+	if err != nil {
+		t.Fatalf("GetSubnet failed: %v", err)
+	}
+	public, err := westClient.GetSubnet(ctx, "public", "")
+	if err != nil {
+		t.Fatalf("GetSubnet failed: %v", err)
+	}
+	west := cloud.EC2("us-west-2")
+	natAddress, err := west.AllocateAddress(ctx, &ec2.AllocateAddressInput{
+		TagSpecifications: []types.TagSpecification{{ResourceType: types.ResourceTypeElasticIp, Tags: lensTags}},
+	})
+	if err != nil {
+		t.Fatalf("AllocateAddress failed: %v", err)
+	}
+	f.natAddress = *natAddress.AllocationId
+	nat, err := west.CreateNatGateway(ctx, &ec2.CreateNatGatewayInput{
+		SubnetId:          awssdk.String(public.ID),
+		AllocationId:      natAddress.AllocationId,
+		TagSpecifications: []types.TagSpecification{{ResourceType: types.ResourceTypeNatgateway, Tags: lensTags}},
+	})
+	if err != nil {
+		t.Fatalf("CreateNatGateway failed: %v", err)
+	}
+	f.natGateway = *nat.NatGateway.NatGatewayId
+	if err := westClient.UpdatePrivateSubnetRoutes(ctx, private.ID, f.natGateway); err != nil {
+		t.Fatalf("UpdatePrivateSubnetRoutes failed: %v", err)
+	}
+	tables, err := west.DescribeRouteTables(ctx, &ec2.DescribeRouteTablesInput{})
+	if err != nil || len(tables.RouteTables) != 1 {
+		t.Fatalf("Expected one route table in us-west-2, got %v, %v", tables, err)
+	}
+	f.routeTable = *tables.RouteTables[0].RouteTableId
+
+	return f
+}
+
+// orphanIDs indexes orphans by kind and ID
+func orphanIDs(orphans []aws.Orphan) map[aws.ResourceKind][]string {
+	ids := make(map[aws.ResourceKind][]string)
+	for _, orphan := range orphans {
+		ids[orphan.Kind] = append(ids[orphan.Kind], orphan.ID)
+	}
+	return ids
+}
+
+func TestFindOrphans_ReportsOnlyUnreferencedLensResources(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	f := seedGCCloud(t, cloud)
+
+	orphans, err := FindOrphans(context.Background(), GCOptions{Profile: "default"})
+	if err != nil {
+		t.Fatalf("FindOrphans failed: %v", err)
+	}
+
+	ids := orphanIDs(orphans)
+	tests := []struct {
+		kind aws.ResourceKind
+		want []string
+	}{
+		{aws.ResourceSecurityGroup, []string{f.strayGroup}},
+		{aws.ResourceKeyPair, []string{f.strayKey}},
+		{aws.ResourceElasticIP, []string{f.strayAddress, f.natAddress}},
+		{aws.ResourceNATGateway, []string{f.natGateway}},
+		{aws.ResourceRoute, []string{"0.0.0.0/0"}},
+		{aws.ResourceInstanceProfile, []string{"lens-vscode-session-manager-profile"}},
+		{aws.ResourceIAMRole, []string{"lens-vscode-session-manager-role"}},
+		{aws.ResourceAMI, nil},
+	}
+	for _, tt := range tests {
+		got := ids[tt.kind]
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.kind, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: expected %v, got %v", tt.kind, tt.want, got)
+			}
+		}
+	}
+	if len(ids[aws.ResourceSnapshot]) != 1 {
+		t.Errorf("Expected the snapshot of the deleted AMI, got %v", ids[aws.ResourceSnapshot])
+	}
+
+	total := 0.0
+	for _, orphan := range orphans {
+		total += OrphanMonthlyCost(orphan)
+	}
+	// NAT Gateway $32.40 + two Elastic IPs $3.60 each + 8 GB snapshot $0.40
+	if want := 40.0; total < want-0.001 || total > want+0.001 {
+		t.Errorf("Expected monthly cost %.2f, got %.2f", want, total)
+	}
+
+	withAMIs, err := FindOrphans(context.Background(), GCOptions{Profile: "default", IncludeAMIs: true})
+	if err != nil {
+		t.Fatalf("FindOrphans failed: %v", err)
+	}
+	if got := orphanIDs(withAMIs)[aws.ResourceAMI]; len(got) != 1 || got[0] != f.keptAMI {
+		t.Errorf("Expected AMI %s with --include-amis, got %v", f.keptAMI, got)
+	}
+}
+
+func TestFindOrphans_SingleRegionSkipsIAMAndJournaled(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	f := seedGCCloud(t, cloud)
+
+	// A launch in progress owns the stray security group
+	tx, err := transaction.Begin("lens-test", "default")
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	defer func() { _ = tx.Commit() }()
+	if err := tx.RecordResource(aws.Resource{Kind: aws.ResourceSecurityGroup, ID: f.strayGroup, Region: "us-east-1"}); err != nil {
+		t.Fatalf("RecordResource failed: %v", err)
+	}
+
+	orphans, err := FindOrphans(context.Background(), GCOptions{Profile: "default", Region: "us-east-1"})
+	if err != nil {
+		t.Fatalf("FindOrphans failed: %v", err)
+	}
+	for _, orphan := range orphans {
+		if orphan.Region != "us-east-1" {
+			t.Errorf("Expected only us-east-1 resources, got %s", orphan.Resource)
+		}
+		if orphan.ID == f.strayGroup {
+			t.Error("Expected journaled security group to be skipped")
+		}
+	}
+}
+
+func TestRunGC_DryRunDeletesNothing(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	seedGCCloud(t, cloud)
+
+	before := len(cloud.SecurityGroups("us-east-1"))
+	if err := RunGC(GCOptions{Profile: "default", DryRun: true}); err != nil {
+		t.Fatalf("RunGC failed: %v", err)
+	}
+	if after := len(cloud.SecurityGroups("us-east-1")); after != before {
+		t.Errorf("Expected %d security groups after dry run, got %d", before, after)
+	}
+	if _, ok := cloud.Role("lens-vscode-session-manager-role"); !ok {
+		t.Error("Expected role to be kept by dry run")
+	}
+}
+
+func TestRunGC_DeletesOrphans(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	f := seedGCCloud(t, cloud)
+
+	if err := RunGC(GCOptions{Profile: "default", Yes: true}); err != nil {
+		t.Fatalf("RunGC failed: %v", err)
+	}
+
+	groups := make(map[string]bool)
+	for _, group := range cloud.SecurityGroups("us-east-1") {
+		groups[*group.GroupId] = true
+	}
+	if groups[f.strayGroup] {
+		t.Error("Expected stray security group to be deleted")
+	}
+	if !groups[f.liveGroup] || !groups[f.userGroup] {
+		t.Error("Expected in-use and user security groups to be kept")
+	}
+
+	keys := cloud.KeyPairs("us-east-1")
+	if len(keys) != 1 || *keys[0].KeyName != "lens-jupyter-us-east-1" {
+		t.Errorf("Expected only the in-use key pair to remain, got %d", len(keys))
+	}
+	if addresses := cloud.Addresses("us-east-1"); len(addresses) != 0 {
+		t.Errorf("Expected stray Elastic IP to be released, got %d", len(addresses))
+	}
+	if addresses := cloud.Addresses("us-west-2"); len(addresses) != 0 {
+		t.Errorf("Expected NAT Gateway Elastic IP to be released, got %d", len(addresses))
+	}
+	if nats := cloud.NatGateways("us-west-2"); len(nats) != 1 || nats[0].State != types.NatGatewayStateDeleted {
+		t.Errorf("Expected NAT Gateway to be deleted, got %+v", nats)
+	}
+	tables, _ := cloud.EC2("us-west-2").DescribeRouteTables(context.Background(), &ec2.DescribeRouteTablesInput{
+		RouteTableIds: []string{f.routeTable},
+	})
+	for _, route := range tables.RouteTables[0].Routes {
+		if route.NatGatewayId != nil {
+			t.Error("Expected route to the NAT Gateway to be removed")
+		}
+	}
+	if len(cloud.Snapshots("us-east-1")) != 1 {
+		t.Errorf("Expected only the snapshot of the kept AMI to remain, got %d", len(cloud.Snapshots("us-east-1")))
+	}
+	if images := cloud.Images("us-east-1"); len(images) != 1 || *images[0].ImageId != f.keptAMI {
+		t.Error("Expected custom AMI to be kept without --include-amis")
+	}
+
+	if _, ok := cloud.Role("lens-vscode-session-manager-role"); ok {
+		t.Error("Expected unused role to be deleted")
+	}
+	if _, ok := cloud.InstanceProfile("lens-vscode-session-manager-profile"); ok {
+		t.Error("Expected unused instance profile to be deleted")
+	}
+	if _, ok := cloud.Role("lens-jupyter-session-manager-role"); !ok {
+		t.Error("Expected role of the live instance to be kept")
+	}
+	if inst, _ := cloud.Instance(f.liveInstance); inst.State.Name != types.InstanceStateNameRunning {
+		t.Errorf("Expected live instance to keep running, got %s", inst.State.Name)
+	}
+}
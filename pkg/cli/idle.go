@@ -0,0 +1,355 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/scttfrdmn/lens/pkg/agent"
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/hooks"
+	"github.com/spf13/cobra"
+)
+
+// ReadAgentStatus reads the status lens-agent last wrote on a running
+// instance over Session Manager
+func ReadAgentStatus(ctx context.Context, ssmClient *aws.SSMClient, instanceID string) (*agent.Status, error) {
+	commandID, err := ssmClient.RunCommand(ctx, instanceID, "cat "+agent.DefaultStatusFile)
+	if err != nil {
+		return nil, err
+	}
+	result, err := ssmClient.WaitForCommand(ctx, commandID, instanceID, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != ssmtypes.CommandInvocationStatusSuccess {
+		return nil, fmt.Errorf("lens-agent status is unavailable: %s", strings.TrimSpace(result.ErrorOutput))
+	}
+	return agent.ParseStatus([]byte(result.Output))
+}
+
+// PrintAutoStop prints when lens-agent will stop a running instance
+func PrintAutoStop(ctx context.Context, profile string, instance *config.Instance) {
+	ssmClient, err := aws.NewSSMClientForProfileRegion(ctx, profile, instance.Region)
+	if err != nil {
+		fmt.Printf("Auto-Stop:       unknown (%v)\n", err)
+		return
+	}
+	status, err := ReadAgentStatus(ctx, ssmClient, instance.ID)
+	if err != nil {
+		fmt.Printf("Auto-Stop:       unknown (%v)\n", err)
+		return
+	}
+
+	now := time.Now()
+	fmt.Printf("Last Activity:   %s (%s ago)\n", status.LastActivity.Local().Format(time.RFC3339), roundDuration(now.Sub(status.LastActivity)))
+	fmt.Printf("Auto-Stop:       %s\n", describeAutoStop(status, now))
+	if status.KeepaliveUntil != nil {
+		fmt.Printf("Keepalive:       until %s\n", status.KeepaliveUntil.Local().Format(time.RFC3339))
+	}
+}
+
+// idleStatus is what lens-agent reports on a tracked instance, or why that
+// is unavailable
+type idleStatus struct {
+	instance *config.Instance
+	state    string        // EC2 state, or "unknown"
+	status   *agent.Status // Only for running instances
+	err      error
+}
+
+// readIdleStatuses reads the lens-agent status of every running tracked
+// instance, oldest first
+func readIdleStatuses(ctx context.Context, profile string) ([]idleStatus, error) {
+	state, err := config.LoadState()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	instances := make([]*config.Instance, 0, len(state.Instances))
+	for _, instance := range state.Instances {
+		instances = append(instances, instance)
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].LaunchedAt.Before(instances[j].LaunchedAt)
+	})
+
+	ec2Clients := map[string]*aws.EC2Client{}
+	ssmClients := map[string]*aws.SSMClient{}
+	statuses := make([]idleStatus, 0, len(instances))
+	for _, instance := range instances {
+		ec2Client, ok := ec2Clients[instance.Region]
+		if !ok {
+			if ec2Client, err = aws.NewEC2ClientForProfileRegion(ctx, profile, instance.Region); err != nil {
+				return nil, fmt.Errorf("failed to create AWS client: %w", err)
+			}
+			ec2Clients[instance.Region] = ec2Client
+		}
+		awsInstance, err := ec2Client.GetInstanceInfo(ctx, instance.ID)
+		if err != nil {
+			statuses = append(statuses, idleStatus{instance: instance, state: "unknown", err: err})
+			continue
+		}
+		current := idleStatus{instance: instance, state: string(awsInstance.State.Name)}
+		if awsInstance.State.Name == ec2types.InstanceStateNameRunning {
+			ssmClient, ok := ssmClients[instance.Region]
+			if !ok {
+				if ssmClient, err = aws.NewSSMClientForProfileRegion(ctx, profile, instance.Region); err != nil {
+					return nil, fmt.Errorf("failed to create SSM client: %w", err)
+				}
+				ssmClients[instance.Region] = ssmClient
+			}
+			current.status, current.err = ReadAgentStatus(ctx, ssmClient, instance.ID)
+		}
+		statuses = append(statuses, current)
+	}
+	return statuses, nil
+}
+
+// RunIdleStatus shows when each running instance will be stopped for being
+// idle. It changes nothing; idle-check fires the idle_warning hook.
+func RunIdleStatus(ctx context.Context, profile string) error {
+	statuses, err := readIdleStatuses(ctx, profile)
+	if err != nil {
+		return err
+	}
+	if len(statuses) == 0 {
+		fmt.Println("No instances found")
+		return nil
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INSTANCE\tSTATE\tLAST ACTIVITY\tAUTO-STOP")
+	for _, current := range statuses {
+		switch {
+		case current.status != nil:
+			fmt.Fprintf(w, "%s\t%s\t%s ago\t%s\n", current.instance.DisplayName(), current.status.State,
+				roundDuration(now.Sub(current.status.LastActivity)), describeAutoStop(current.status, now))
+		case current.err != nil:
+			fmt.Fprintf(w, "%s\t%s\t-\tunknown (%v)\n", current.instance.DisplayName(), current.state, current.err)
+		default:
+			fmt.Fprintf(w, "%s\t%s\t-\t-\n", current.instance.DisplayName(), current.state)
+		}
+	}
+	return w.Flush()
+}
+
+// IdleCheckOptions holds the options of the idle-check command
+type IdleCheckOptions struct {
+	Profile string
+}
+
+// NewIdleCheckCmd creates the idle-check command for warning about idle
+// auto-stops from cron
+func NewIdleCheckCmd(appName string) *cobra.Command {
+	var opts IdleCheckOptions
+
+	cmd := &cobra.Command{
+		Use:   "idle-check",
+		Short: "Run the idle_warning hook for instances about to be auto-stopped",
+		Long: `Check when lens-agent will stop each running instance for being idle, and run
+the idle_warning hook, with AWS_IDE_STOP_AT set, for those that will be stopped
+within idle_warning (default 15m). The hook runs once per deadline.
+
+Run idle-check from cron; nothing else fires idle_warning.`,
+		Example: fmt.Sprintf(`  # Check every 5 minutes
+  */5 * * * * %[1]s idle-check`, appName),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunIdleCheck(context.Background(), opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Profile, "profile", "p", "default", "AWS profile to use")
+
+	return cmd
+}
+
+// RunIdleCheck fires the idle_warning hook for every running instance about
+// to be stopped for being idle
+func RunIdleCheck(ctx context.Context, opts IdleCheckOptions) error {
+	statuses, err := readIdleStatuses(ctx, opts.Profile)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, current := range statuses {
+		if current.status == nil {
+			continue
+		}
+		if fired, err := fireIdleWarning(current.instance, current.status, now); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		} else if fired {
+			fmt.Printf("idle_warning sent for %s: %s\n", current.instance.DisplayName(), describeAutoStop(current.status, now))
+		}
+	}
+	return nil
+}
+
+// describeAutoStop says when the agent will act on an instance
+func describeAutoStop(status *agent.Status, now time.Time) string {
+	switch {
+	case status.State == agent.StateStopping:
+		return string(status.Action) + " in progress"
+	case !status.AutoStop || status.StopAt == nil:
+		return "off"
+	}
+	remaining := status.StopAt.Sub(now)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return fmt.Sprintf("%s in %s (%s)", status.Action, roundDuration(remaining), status.StopAt.Local().Format("Jan 2 15:04"))
+}
+
+// roundDuration formats a duration to the minute
+func roundDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// fireIdleWarning runs the idle_warning hook once for each stop time that
+// falls within the idle_warning window
+func fireIdleWarning(instance *config.Instance, status *agent.Status, now time.Time) (bool, error) {
+	if !status.AutoStop || status.StopAt == nil || status.State == agent.StateStopping {
+		return false, nil
+	}
+	if instance.IdleWarning != nil && instance.IdleWarning.Equal(*status.StopAt) {
+		return false, nil
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return false, fmt.Errorf("failed to load config: %w", err)
+	}
+	window, err := time.ParseDuration(cfg.IdleWarning)
+	if err != nil {
+		return false, fmt.Errorf("invalid idle_warning: %w", err)
+	}
+	remaining := status.StopAt.Sub(now)
+	if remaining > window || remaining < 0 {
+		return false, nil
+	}
+
+	_ = hooks.ExecuteHook(hooks.EventData{
+		EventType:    hooks.EventIdleWarning,
+		InstanceID:   instance.ID,
+		InstanceType: instance.InstanceType,
+		Environment:  instance.Environment,
+		Region:       instance.Region,
+		Timestamp:    now,
+		AppName:      strings.TrimPrefix(instance.App, "lens-"),
+		StopAt:       *status.StopAt,
+	})
+	stopAt := *status.StopAt
+	if err := config.UpdateInstance(instance.ID, func(instance *config.Instance) error {
+		instance.IdleWarning = &stopAt
+		return nil
+	}); err != nil {
+		return true, fmt.Errorf("failed to update state: %w", err)
+	}
+	return true, nil
+}
+
+// KeepaliveOptions holds the options of the keepalive command
+type KeepaliveOptions struct {
+	Profile string
+	For     time.Duration
+}
+
+// NewKeepaliveCmd creates the keepalive command for postponing the idle
+// auto-stop of an instance
+func NewKeepaliveCmd(appName string) *cobra.Command {
+	var opts KeepaliveOptions
+
+	cmd := &cobra.Command{
+		Use:   "keepalive [INSTANCE]",
+		Short: "Postpone the idle auto-stop of an instance",
+		Long: `Postpone the idle auto-stop of a running instance, for unattended work that
+does not look busy to lens-agent, such as a job waiting on a remote service.
+
+The instance is not stopped before the keepalive expires, however idle it is.
+After that the idle timeout applies again. --for 0 removes a keepalive.
+lens-agent reports the keepalive to CloudWatch, so the instance's idle alarm,
+if it has one, does not stop it either.`,
+		Example: fmt.Sprintf(`  %[1]s keepalive my-analysis --for 3h
+  %[1]s keepalive my-analysis --for 0`, appName),
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			instanceRef := ""
+			if len(args) > 0 {
+				instanceRef = args[0]
+			}
+			_, err := RunKeepalive(context.Background(), instanceRef, opts)
+			return err
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Profile, "profile", "p", "default", "AWS profile to use")
+	cmd.Flags().DurationVar(&opts.For, "for", time.Hour, "How long to postpone auto-stop, e.g. 3h")
+
+	return cmd
+}
+
+// RunKeepalive writes a keepalive on the instance ref refers to through
+// Session Manager and returns when it expires
+func RunKeepalive(ctx context.Context, instanceRef string, opts KeepaliveOptions) (time.Time, error) {
+	if opts.For < 0 {
+		return time.Time{}, fmt.Errorf("--for must not be negative")
+	}
+
+	state, err := config.LoadState()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to load state: %w", err)
+	}
+	instance, err := ResolveInstance(state, instanceRef)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	ec2Client, err := aws.NewEC2ClientForProfileRegion(ctx, opts.Profile, instance.Region)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to create AWS client: %w", err)
+	}
+	awsInstance, err := ec2Client.GetInstanceInfo(ctx, instance.ID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get instance info: %w", err)
+	}
+	if awsInstance.State.Name != ec2types.InstanceStateNameRunning {
+		return time.Time{}, fmt.Errorf("instance %s is %s; only running instances are auto-stopped", instance.ID, awsInstance.State.Name)
+	}
+
+	ssmClient, err := aws.NewSSMClientForProfileRegion(ctx, opts.Profile, instance.Region)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to create SSM client: %w", err)
+	}
+	commandID, err := ssmClient.RunCommand(ctx, instance.ID, fmt.Sprintf("lens-agent keepalive --for %s", opts.For))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to set keepalive: %w", err)
+	}
+	result, err := ssmClient.WaitForCommand(ctx, commandID, instance.ID, time.Minute)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to set keepalive: %w", err)
+	}
+	if result.Status != ssmtypes.CommandInvocationStatusSuccess {
+		return time.Time{}, fmt.Errorf("failed to set keepalive (is lens-agent installed?): %s", strings.TrimSpace(result.ErrorOutput))
+	}
+
+	if opts.For == 0 {
+		fmt.Printf("✓ Keepalive of %s removed; the idle timeout applies again\n", instance.ID)
+		return time.Time{}, nil
+	}
+	until := time.Now().Add(opts.For)
+	fmt.Printf("✓ %s will not be auto-stopped before %s\n", instance.ID, until.Local().Format("Jan 2 15:04"))
+	return until, nil
+}
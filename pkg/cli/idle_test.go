@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/lens/pkg/agent"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/fakecloud"
+)
+
+func TestRunKeepalive(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	id := trackManaged(t, cloud)
+	ctx := context.Background()
+
+	until, err := RunKeepalive(ctx, id, KeepaliveOptions{Profile: "default", For: 3 * time.Hour})
+	if err != nil {
+		t.Fatalf("RunKeepalive failed: %v", err)
+	}
+	if remaining := time.Until(until); remaining < 179*time.Minute || remaining > 3*time.Hour {
+		t.Errorf("expected a keepalive 3h from now, got %s", until)
+	}
+	invocations := cloud.Invocations()
+	if len(invocations) != 1 || invocations[0].InstanceID != id || invocations[0].Script() != "lens-agent keepalive --for 3h0m0s" {
+		t.Fatalf("unexpected commands: %+v", invocations)
+	}
+
+	cloud.HandleCommands(func(inv fakecloud.Invocation) fakecloud.CommandResult {
+		return fakecloud.CommandResult{Stderr: "lens-agent: command not found", ExitCode: 127}
+	})
+	if _, err := RunKeepalive(ctx, id, KeepaliveOptions{Profile: "default", For: time.Hour}); err == nil || !strings.Contains(err.Error(), "command not found") {
+		t.Errorf("expected the command error, got %v", err)
+	}
+
+	if err := cloud.SetInstanceState(id, types.InstanceStateNameStopped); err != nil {
+		t.Fatalf("SetInstanceState failed: %v", err)
+	}
+	if _, err := RunKeepalive(ctx, id, KeepaliveOptions{Profile: "default", For: time.Hour}); err == nil || !strings.Contains(err.Error(), "stopped") {
+		t.Errorf("expected an error for a stopped instance, got %v", err)
+	}
+}
+
+func TestRunIdleCheck_FiresIdleWarningOnce(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cloud := fakecloud.Install(t)
+	id := trackManaged(t, cloud)
+
+	hookLog := filepath.Join(home, "hook.log")
+	cfg := &config.UserConfig{
+		IdleWarning: "15m",
+		Hooks:       &config.HooksConfig{OnIdleWarning: `echo "$AWS_IDE_INSTANCE_ID $AWS_IDE_STOP_AT" >> ` + hookLog},
+	}
+	if err := config.SaveUserConfig(cfg); err != nil {
+		t.Fatalf("SaveUserConfig failed: %v", err)
+	}
+
+	stopAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	cloud.HandleCommands(func(inv fakecloud.Invocation) fakecloud.CommandResult {
+		if inv.Script() != "cat "+agent.DefaultStatusFile {
+			return fakecloud.CommandResult{ExitCode: 1}
+		}
+		data, _ := json.Marshal(agent.Status{
+			State:        agent.StateIdle,
+			LastActivity: stopAt.Add(-time.Hour),
+			Action:       agent.ActionStop,
+			AutoStop:     true,
+			StopAt:       &stopAt,
+		})
+		return fakecloud.CommandResult{Stdout: string(data)}
+	})
+	ctx := context.Background()
+
+	// An hour out is outside the warning window
+	if err := RunIdleCheck(ctx, IdleCheckOptions{Profile: "default"}); err != nil {
+		t.Fatalf("RunIdleCheck failed: %v", err)
+	}
+	if _, err := os.Stat(hookLog); err == nil {
+		t.Fatal("idle_warning fired an hour before the stop")
+	}
+
+	// status only reads
+	stopAt = time.Now().Add(10 * time.Minute).UTC().Truncate(time.Second)
+	if err := RunIdleStatus(ctx, "default"); err != nil {
+		t.Fatalf("RunIdleStatus failed: %v", err)
+	}
+	if _, err := os.Stat(hookLog); err == nil {
+		t.Fatal("status --all fired idle_warning")
+	}
+	if state, _ := config.LoadState(); state.Instances[id].IdleWarning != nil {
+		t.Fatal("status --all wrote to state")
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := RunIdleCheck(ctx, IdleCheckOptions{Profile: "default"}); err != nil {
+			t.Fatalf("RunIdleCheck failed: %v", err)
+		}
+	}
+	log, err := os.ReadFile(hookLog)
+	if err != nil {
+		t.Fatalf("expected the on_idle_warning hook to run: %v", err)
+	}
+	if got := strings.TrimSpace(string(log)); got != id+" "+stopAt.Format(time.RFC3339) {
+		t.Errorf("expected the hook to run once for %s, got %q", stopAt.Format(time.RFC3339), got)
+	}
+
+	state, err := config.LoadState()
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if warned := state.Instances[id].IdleWarning; warned == nil || !warned.Equal(stopAt) {
+		t.Errorf("expected the warning to be recorded, got %v", warned)
+	}
+}
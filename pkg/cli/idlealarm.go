@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+)
+
+// SetupIdleAlarm creates the CloudWatch alarm that stops a newly launched
+// instance after its idle timeout, in case lens-agent is not running, and
+// records it in the instance's tags
+func SetupIdleAlarm(ctx context.Context, ec2Client *aws.EC2Client, profile string, instance *ec2types.Instance) (*aws.IdleAlarm, error) {
+	instanceID := awssdk.ToString(instance.InstanceId)
+	metadata := aws.ParseInstanceMetadata(instance.Tags)
+	idleTimeout, err := time.ParseDuration(metadata.IdleTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid idle timeout %q: %w", metadata.IdleTimeout, err)
+	}
+
+	cwClient, err := aws.NewCloudWatchClient(ctx, profile, ec2Client.GetRegion())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CloudWatch client: %w", err)
+	}
+	alarm, err := cwClient.CreateIdleAlarm(ctx, instanceID, idleTimeout, metadata)
+	if err != nil {
+		return nil, err
+	}
+	if err := ec2Client.SetIdleAlarmTag(ctx, instanceID, alarm.Name); err != nil {
+		return nil, fmt.Errorf("failed to tag instance with its idle alarm: %w", err)
+	}
+	instance.Tags = append(instance.Tags, ec2types.Tag{Key: awssdk.String(aws.TagIdleAlarm), Value: awssdk.String(alarm.Name)})
+	return alarm, nil
+}
+
+// PrintIdleAlarm prints the state of an instance's idle alarm, if it has one
+func PrintIdleAlarm(ctx context.Context, profile string, instance *config.Instance) {
+	if instance.IdleAlarm == "" {
+		return
+	}
+	cwClient, err := aws.NewCloudWatchClient(ctx, profile, instance.Region)
+	if err != nil {
+		fmt.Printf("Idle Alarm:      unknown (%v)\n", err)
+		return
+	}
+	alarm, err := cwClient.GetIdleAlarm(ctx, instance.ID)
+	if err != nil {
+		fmt.Printf("Idle Alarm:      unknown (%v)\n", err)
+		return
+	}
+	fmt.Printf("Idle Alarm:      %s\n", describeIdleAlarm(alarm))
+}
+
+// describeIdleAlarm summarizes the state of an idle alarm
+func describeIdleAlarm(alarm *aws.IdleAlarm) string {
+	if alarm == nil {
+		return "missing (deleted outside lens?)"
+	}
+	switch alarm.State {
+	case "OK":
+		return fmt.Sprintf("OK (stops after %s below %.0f%% CPU and %d KB network per 5 minutes)",
+			alarm.Evaluation, aws.IdleAlarmCPUThreshold, aws.IdleAlarmNetworkThreshold/1024)
+	case "ALARM":
+		return fmt.Sprintf("ALARM since %s, instance stopped", alarm.UpdatedAt.Local().Format("Jan 2 15:04"))
+	}
+	return "waiting for CPU and network data"
+}
+
+// DeleteIdleAlarm deletes the idle alarm of an instance being terminated
+func DeleteIdleAlarm(ctx context.Context, instance *config.Instance) error {
+	if instance.IdleAlarm == "" {
+		return nil
+	}
+	cwClient, err := aws.NewCloudWatchClientForRegion(ctx, instance.Region)
+	if err != nil {
+		return fmt.Errorf("failed to create CloudWatch client: %w", err)
+	}
+	return cwClient.DeleteIdleAlarm(ctx, instance.IdleAlarm)
+}
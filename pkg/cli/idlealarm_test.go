@@ -0,0 +1,245 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/fakecloud"
+)
+
+func TestSetupIdleAlarm_StopsIdleInstanceAndIsCollectedAfterTerminate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	ctx := context.Background()
+	id := launchTagged(t, fakecloud.DefaultRegion, aws.InstanceMetadata{App: "lens-jupyter", IdleTimeout: "4h0m0s"})
+
+	ec2Client, err := aws.NewEC2ClientForRegion(ctx, fakecloud.DefaultRegion)
+	if err != nil {
+		t.Fatalf("NewEC2ClientForRegion failed: %v", err)
+	}
+	instance, err := ec2Client.GetInstanceInfo(ctx, id)
+	if err != nil {
+		t.Fatalf("GetInstanceInfo failed: %v", err)
+	}
+	alarm, err := SetupIdleAlarm(ctx, ec2Client, "default", instance)
+	if err != nil {
+		t.Fatalf("SetupIdleAlarm failed: %v", err)
+	}
+	if alarm.Name != aws.IdleAlarmName(id) || alarm.Evaluation != 4*time.Hour {
+		t.Errorf("unexpected alarm: %+v", alarm)
+	}
+
+	created, ok := cloud.Alarm(fakecloud.DefaultRegion, alarm.Name)
+	if !ok {
+		t.Fatalf("alarm %s was not created", alarm.Name)
+	}
+	if got := awssdk.ToInt32(created.EvaluationPeriods); got != 48 {
+		t.Errorf("expected 48 five-minute periods, got %d", got)
+	}
+	if len(created.AlarmActions) != 1 || created.AlarmActions[0] != "arn:aws:automate:"+fakecloud.DefaultRegion+":ec2:stop" {
+		t.Errorf("expected the EC2 stop action, got %v", created.AlarmActions)
+	}
+	tagged, _ := cloud.Instance(id)
+	if got := aws.ParseInstanceMetadata(tagged.Tags).IdleAlarm; got != alarm.Name {
+		t.Errorf("expected the instance to be tagged with its alarm, got %q", got)
+	}
+
+	current, err := aws.NewCloudWatchClientForRegion(ctx, fakecloud.DefaultRegion)
+	if err != nil {
+		t.Fatalf("NewCloudWatchClientForRegion failed: %v", err)
+	}
+	if got, err := current.GetIdleAlarm(ctx, id); err != nil || describeIdleAlarm(got) != "waiting for CPU and network data" {
+		t.Errorf("expected a new alarm to wait for data, got %v (%v)", got, err)
+	}
+
+	if err := cloud.SetAlarmState(fakecloud.DefaultRegion, alarm.Name, cwtypes.StateValueAlarm); err != nil {
+		t.Fatalf("SetAlarmState failed: %v", err)
+	}
+	if stopped, _ := cloud.Instance(id); stopped.State.Name != types.InstanceStateNameStopped {
+		t.Errorf("expected the alarm to stop the instance, got %s", stopped.State.Name)
+	}
+	if got, _ := current.GetIdleAlarm(ctx, id); !strings.HasPrefix(describeIdleAlarm(got), "ALARM since") {
+		t.Errorf("unexpected description %q", describeIdleAlarm(got))
+	}
+
+	// An alarm left behind by an instance terminated outside lens is an orphan
+	if err := cloud.SetInstanceState(id, types.InstanceStateNameTerminated); err != nil {
+		t.Fatalf("SetInstanceState failed: %v", err)
+	}
+	orphans, err := FindOrphans(ctx, GCOptions{Profile: "default"})
+	if err != nil {
+		t.Fatalf("FindOrphans failed: %v", err)
+	}
+	if ids := orphanIDs(orphans)[aws.ResourceAlarm]; len(ids) != 1 || ids[0] != alarm.Name {
+		t.Errorf("expected the idle alarm to be an orphan, got %v", ids)
+	}
+	if err := RunGC(GCOptions{Profile: "default", Yes: true}); err != nil {
+		t.Fatalf("RunGC failed: %v", err)
+	}
+	if _, ok := cloud.Alarm(fakecloud.DefaultRegion, alarm.Name); ok {
+		t.Error("expected gc to delete the idle alarm")
+	}
+}
+
+func TestIdleAlarm_NetworkTrafficKeepsInstanceRunning(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	ctx := context.Background()
+	id := launchTagged(t, fakecloud.DefaultRegion, aws.InstanceMetadata{App: "lens-jupyter", IdleTimeout: "1h0m0s"})
+
+	ec2Client, err := aws.NewEC2ClientForRegion(ctx, fakecloud.DefaultRegion)
+	if err != nil {
+		t.Fatalf("NewEC2ClientForRegion failed: %v", err)
+	}
+	instance, err := ec2Client.GetInstanceInfo(ctx, id)
+	if err != nil {
+		t.Fatalf("GetInstanceInfo failed: %v", err)
+	}
+	alarm, err := SetupIdleAlarm(ctx, ec2Client, "default", instance)
+	if err != nil {
+		t.Fatalf("SetupIdleAlarm failed: %v", err)
+	}
+
+	// Someone typing in the IDE: the CPU is quiet but every 5 minutes brings
+	// more than the network threshold
+	now := time.Now()
+	cloud.SetClock(func() time.Time { return now })
+	for at := now.Add(-time.Hour); at.Before(now); at = at.Add(5 * time.Minute) {
+		cloud.AddInstanceMetric(fakecloud.DefaultRegion, id, aws.MetricCPUUtilization, at, 1)
+		cloud.AddInstanceMetric(fakecloud.DefaultRegion, id, aws.MetricNetworkIn, at, 2*aws.IdleAlarmNetworkThreshold)
+	}
+	if state, err := cloud.EvaluateAlarm(fakecloud.DefaultRegion, alarm.Name); err != nil || state != cwtypes.StateValueOk {
+		t.Fatalf("expected network traffic to keep the alarm OK, got %s (%v)", state, err)
+	}
+	if running, _ := cloud.Instance(id); running.State.Name != types.InstanceStateNameRunning {
+		t.Errorf("expected the instance to keep running, got %s", running.State.Name)
+	}
+
+	// An hour later nobody has touched it
+	now = now.Add(time.Hour)
+	for at := now.Add(-time.Hour); at.Before(now); at = at.Add(5 * time.Minute) {
+		cloud.AddInstanceMetric(fakecloud.DefaultRegion, id, aws.MetricCPUUtilization, at, 1)
+		cloud.AddInstanceMetric(fakecloud.DefaultRegion, id, aws.MetricNetworkIn, at, 1024)
+	}
+	if state, err := cloud.EvaluateAlarm(fakecloud.DefaultRegion, alarm.Name); err != nil || state != cwtypes.StateValueAlarm {
+		t.Fatalf("expected an idle CPU and network to raise the alarm, got %s (%v)", state, err)
+	}
+	if stopped, _ := cloud.Instance(id); stopped.State.Name != types.InstanceStateNameStopped {
+		t.Errorf("expected the alarm to stop the instance, got %s", stopped.State.Name)
+	}
+}
+
+func TestCreateIdleAlarm_WatchesLongTimeoutsInHours(t *testing.T) {
+	cloud := fakecloud.Install(t)
+	ctx := context.Background()
+	cwClient, err := aws.NewCloudWatchClientForRegion(ctx, fakecloud.DefaultRegion)
+	if err != nil {
+		t.Fatalf("NewCloudWatchClientForRegion failed: %v", err)
+	}
+
+	alarm, err := cwClient.CreateIdleAlarm(ctx, "i-0123456789abcdef0", 30*time.Hour, aws.InstanceMetadata{})
+	if err != nil {
+		t.Fatalf("CreateIdleAlarm failed: %v", err)
+	}
+	if alarm.Evaluation != 30*time.Hour {
+		t.Errorf("expected the full 30h to be watched, got %s", alarm.Evaluation)
+	}
+	created, _ := cloud.Alarm(fakecloud.DefaultRegion, alarm.Name)
+	if got := awssdk.ToInt32(created.EvaluationPeriods); got != 30 {
+		t.Errorf("expected 30 hourly periods, got %d", got)
+	}
+	if got, _ := cwClient.GetIdleAlarm(ctx, "i-0123456789abcdef0"); got == nil || got.Evaluation != 30*time.Hour {
+		t.Errorf("expected the alarm to read back 30h, got %+v", got)
+	}
+
+	if _, err := cwClient.CreateIdleAlarm(ctx, "i-0123456789abcdef1", 8*24*time.Hour, aws.InstanceMetadata{}); err == nil {
+		t.Error("expected an error for an idle timeout over 7 days")
+	}
+	if _, ok := cloud.Alarm(fakecloud.DefaultRegion, aws.IdleAlarmName("i-0123456789abcdef1")); ok {
+		t.Error("expected no alarm for an idle timeout over 7 days")
+	}
+	if _, err := cwClient.CreateIdleAlarm(ctx, "i-0123456789abcdef0", 0, aws.InstanceMetadata{}); err == nil {
+		t.Error("expected an error without an idle timeout")
+	}
+}
+
+func TestDeleteIdleAlarm(t *testing.T) {
+	cloud := fakecloud.Install(t)
+	ctx := context.Background()
+	cwClient, err := aws.NewCloudWatchClientForRegion(ctx, fakecloud.DefaultRegion)
+	if err != nil {
+		t.Fatalf("NewCloudWatchClientForRegion failed: %v", err)
+	}
+	alarm, err := cwClient.CreateIdleAlarm(ctx, "i-0123456789abcdef0", time.Hour, aws.InstanceMetadata{})
+	if err != nil {
+		t.Fatalf("CreateIdleAlarm failed: %v", err)
+	}
+
+	instance := &config.Instance{ID: "i-0123456789abcdef0", Region: fakecloud.DefaultRegion, IdleAlarm: alarm.Name}
+	if err := DeleteIdleAlarm(ctx, instance); err != nil {
+		t.Fatalf("DeleteIdleAlarm failed: %v", err)
+	}
+	if _, ok := cloud.Alarm(fakecloud.DefaultRegion, alarm.Name); ok {
+		t.Error("expected the alarm to be deleted")
+	}
+	// Deleting it again, as a retried terminate would, is not an error
+	if err := DeleteIdleAlarm(ctx, instance); err != nil {
+		t.Errorf("expected deleting a missing alarm to succeed, got %v", err)
+	}
+}
+
+func TestIdleAlarm_KeepaliveKeepsInstanceRunning(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	ctx := context.Background()
+	id := launchTagged(t, fakecloud.DefaultRegion, aws.InstanceMetadata{App: "lens-jupyter", IdleTimeout: "1h0m0s"})
+
+	ec2Client, err := aws.NewEC2ClientForRegion(ctx, fakecloud.DefaultRegion)
+	if err != nil {
+		t.Fatalf("NewEC2ClientForRegion failed: %v", err)
+	}
+	instance, err := ec2Client.GetInstanceInfo(ctx, id)
+	if err != nil {
+		t.Fatalf("GetInstanceInfo failed: %v", err)
+	}
+	alarm, err := SetupIdleAlarm(ctx, ec2Client, "default", instance)
+	if err != nil {
+		t.Fatalf("SetupIdleAlarm failed: %v", err)
+	}
+
+	// A job waiting on a remote service under a keepalive: the CPU and
+	// network are idle, but lens-agent publishes the keepalive every period
+	now := time.Now()
+	cloud.SetClock(func() time.Time { return now })
+	for at := now.Add(-time.Hour); at.Before(now); at = at.Add(5 * time.Minute) {
+		cloud.AddInstanceMetric(fakecloud.DefaultRegion, id, aws.MetricCPUUtilization, at, 1)
+		cloud.AddInstanceMetric(fakecloud.DefaultRegion, id, aws.MetricNetworkIn, at, 1024)
+		cloud.AddKeepaliveMetric(fakecloud.DefaultRegion, id, at)
+	}
+	if state, err := cloud.EvaluateAlarm(fakecloud.DefaultRegion, alarm.Name); err != nil || state != cwtypes.StateValueOk {
+		t.Fatalf("expected the keepalive to keep the alarm OK, got %s (%v)", state, err)
+	}
+	if running, _ := cloud.Instance(id); running.State.Name != types.InstanceStateNameRunning {
+		t.Errorf("expected the instance to keep running, got %s", running.State.Name)
+	}
+
+	// The keepalive expired an hour ago and the alarm is still armed
+	now = now.Add(time.Hour)
+	for at := now.Add(-time.Hour); at.Before(now); at = at.Add(5 * time.Minute) {
+		cloud.AddInstanceMetric(fakecloud.DefaultRegion, id, aws.MetricCPUUtilization, at, 1)
+		cloud.AddInstanceMetric(fakecloud.DefaultRegion, id, aws.MetricNetworkIn, at, 1024)
+	}
+	if state, err := cloud.EvaluateAlarm(fakecloud.DefaultRegion, alarm.Name); err != nil || state != cwtypes.StateValueAlarm {
+		t.Fatalf("expected the alarm to fire once the keepalive expired, got %s (%v)", state, err)
+	}
+	if stopped, _ := cloud.Instance(id); stopped.State.Name != types.InstanceStateNameStopped {
+		t.Errorf("expected the alarm to stop the instance, got %s", stopped.State.Name)
+	}
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"golang.org/x/term"
+)
+
+// ResolveInstance returns the tracked instance a command argument refers to:
+// an instance ID, a name, or a prefix of either that matches one instance.
+// Without an argument the only tracked instance is used, or the user picks
+// one interactively.
+func ResolveInstance(state *config.LocalState, ref string) (*config.Instance, error) {
+	if ref != "" {
+		return state.FindInstance(ref)
+	}
+	return SelectInstance(state)
+}
+
+// SelectInstance selects the only tracked instance, or prompts the user to
+// pick one when there are several. Without a terminal to prompt on it lists
+// the instances and returns an error.
+func SelectInstance(state *config.LocalState) (*config.Instance, error) {
+	instances := make([]*config.Instance, 0, len(state.Instances))
+	for _, instance := range state.Instances {
+		instances = append(instances, instance)
+	}
+	// Most recently launched first
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].LaunchedAt.After(instances[j].LaunchedAt)
+	})
+
+	switch len(instances) {
+	case 0:
+		return nil, fmt.Errorf("no instances found. Launch an instance first with the launch command")
+	case 1:
+		fmt.Printf("Auto-selecting instance: %s\n", instances[0].DisplayName())
+		return instances[0], nil
+	}
+
+	options := make([]string, len(instances))
+	for i, instance := range instances {
+		options[i] = instanceLabel(instance)
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, fmt.Errorf("multiple instances found, specify one by name or ID:\n  %s", strings.Join(options, "\n  "))
+	}
+
+	var choice int
+	prompt := &survey.Select{
+		Message: "Select an instance:",
+		Options: options,
+	}
+	if err := survey.AskOne(prompt, &choice); err != nil {
+		return nil, err
+	}
+	return instances[choice], nil
+}
+
+// instanceLabel describes an instance on one line for selection
+func instanceLabel(instance *config.Instance) string {
+	name := instance.Name
+	if name == "" {
+		name = "-"
+	}
+	return fmt.Sprintf("%-20s %-20s %-16s %-12s launched %s",
+		name, instance.ID, instance.Environment, instance.InstanceType,
+		instance.LaunchedAt.Format("2006-01-02 15:04"))
+}
+
+// CheckNewInstanceName validates a name for a new instance and checks that no
+// tracked instance uses it yet
+func CheckNewInstanceName(name string) error {
+	if err := config.ValidateInstanceName(name); err != nil {
+		return err
+	}
+	state, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	return state.CheckNameAvailable(name, "")
+}
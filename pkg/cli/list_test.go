@@ -1,8 +1,12 @@
 package cli
 
 import (
+	"errors"
 	"testing"
 	"time"
+
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/fakecloud"
 )
 
 func TestFormatDuration(t *testing.T) {
@@ -83,3 +87,37 @@ func TestFormatDuration_NegativeDuration(t *testing.T) {
 		t.Errorf("formatDuration should return a value even for future times")
 	}
 }
+
+func TestLoadListStates_AllUsers(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	previous := allUsers
+	allUsers = true
+	t.Cleanup(func() { allUsers = previous })
+
+	if _, err := loadListStates(); !errors.Is(err, config.ErrStateNotShared) {
+		t.Fatalf("Expected --all-users to require a shared backend, got %v", err)
+	}
+
+	cloud := fakecloud.Install(t)
+	cloud.CreateBucket("team-state")
+	if err := config.SaveUserConfig(&config.UserConfig{StateBackend: "s3://team-state"}); err != nil {
+		t.Fatalf("Failed to save user config: %v", err)
+	}
+	for _, caller := range []string{"arn:aws:iam::123456789012:user/alice", "arn:aws:iam::123456789012:user/bob"} {
+		cloud.SetCaller(caller)
+		if err := config.UpdateState(func(state *config.LocalState) error {
+			state.Instances["i-"+caller[len(caller)-3:]] = &config.Instance{InstanceType: "t4g.medium"}
+			return nil
+		}); err != nil {
+			t.Fatalf("UpdateState failed: %v", err)
+		}
+	}
+
+	states, err := loadListStates()
+	if err != nil {
+		t.Fatalf("loadListStates failed: %v", err)
+	}
+	if len(states) != 2 || len(states["alice"].Instances) != 1 || len(states["bob"].Instances) != 1 {
+		t.Errorf("Expected one instance each for alice and bob, got %+v", states)
+	}
+}
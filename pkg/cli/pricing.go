@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/cost"
+)
+
+// PriceCacheFile is the name of the price cache in the config directory
+const PriceCacheFile = "pricing-cache.json"
+
+// ConfigurePricing selects the pricing provider from the pricing_source and
+// pricing_cache_ttl settings. Live pricing asks the AWS Price List API,
+// caches answers in ~/.lens/pricing-cache.json and falls back to the bundled
+// snapshot when a price is not available.
+func ConfigurePricing() error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	switch cfg.PricingSource {
+	case config.PricingSourceOffline:
+		cost.UsePricing(cost.NewSnapshotProvider())
+		return nil
+	case config.PricingSourceLive:
+	default:
+		return fmt.Errorf("invalid pricing_source: %s (must be '%s' or '%s')",
+			cfg.PricingSource, config.PricingSourceLive, config.PricingSourceOffline)
+	}
+
+	ttl, err := time.ParseDuration(cfg.PricingCacheTTL)
+	if err != nil {
+		return fmt.Errorf("invalid pricing_cache_ttl: %w", err)
+	}
+
+	cost.UsePricing(cost.ChainProvider{
+		cost.NewCachedProvider(
+			filepath.Join(config.GetConfigDir(), PriceCacheFile),
+			ttl,
+			cost.NewPriceListProvider(cfg.DefaultProfile),
+		),
+		cost.NewSnapshotProvider(),
+	})
+	return nil
+}
@@ -0,0 +1,346 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/cost"
+	"github.com/spf13/cobra"
+)
+
+// fullDiskPercent is the peak disk use above which recommend suggests a
+// larger volume
+const fullDiskPercent = 85
+
+// rightsizeFamily matches the families recommend chooses from: burstable,
+// general purpose, compute and memory optimized (e.g. t4g, m7g, c6i, r6a)
+// and GPU families (e.g. g5, g5g), leaving out variants with local disks or
+// extra networking
+var rightsizeFamily = regexp.MustCompile(`^([tmcr]\d+[agi]?|g\d+g?)\.`)
+
+// RecommendOptions holds the options of the recommend command
+type RecommendOptions struct {
+	Profile string
+	Apply   bool // Change the instance type on the next stop
+}
+
+// NewRecommendCmd creates the recommend command for rightsizing an instance
+// from its CloudWatch utilization
+func NewRecommendCmd(appName string) *cobra.Command {
+	var opts RecommendOptions
+
+	cmd := &cobra.Command{
+		Use:   "recommend [INSTANCE]",
+		Short: "Recommend a better-fitting instance type from CloudWatch utilization",
+		Long: `Recommend a cheaper or better-fitting instance type from the CPU, memory,
+disk and GPU utilization CloudWatch recorded since the instance was launched.
+
+The recommended type is the cheapest current-generation type of the same
+architecture with room for the peak hourly CPU and memory use at 80%.
+Burstable types are only recommended when the average CPU use stays within
+their baseline.
+
+CPU utilization is always available. Memory, disk and GPU utilization need
+the CloudWatch agent on the instance, publishing mem_used_percent,
+disk_used_percent and nvidia_smi_utilization_gpu aggregated by InstanceId.
+Without memory data the current amount of memory is kept.
+
+With --apply the type is changed the next time the instance is stopped or
+started with lens, or right away if it is stopped. An instance stopped some
+other way, e.g. by the idle agent or a schedule, changes type on its next
+start.`,
+		Example: fmt.Sprintf(`  %[1]s recommend my-analysis
+  %[1]s recommend my-analysis --apply`, appName),
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			instanceRef := ""
+			if len(args) > 0 {
+				instanceRef = args[0]
+			}
+			_, err := RunRecommend(context.Background(), instanceRef, opts)
+			return err
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Profile, "profile", "p", "default", "AWS profile to use")
+	cmd.Flags().BoolVar(&opts.Apply, "apply", false, "Change to the recommended type on the next stop or start")
+
+	return cmd
+}
+
+// RunRecommend prints a rightsizing recommendation for the instance ref
+// refers to and queues the change with opts.Apply
+func RunRecommend(ctx context.Context, instanceRef string, opts RecommendOptions) (*cost.Recommendation, error) {
+	state, err := config.LoadState()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	instance, err := ResolveInstance(state, instanceRef)
+	if err != nil {
+		return nil, err
+	}
+
+	ec2Client, err := aws.NewEC2ClientForProfileRegion(ctx, opts.Profile, instance.Region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS client: %w", err)
+	}
+	awsInstance, err := ec2Client.GetInstanceInfo(ctx, instance.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance info: %w", err)
+	}
+	currentType := string(awsInstance.InstanceType)
+	architecture := string(awsInstance.Architecture)
+
+	currentSpecs, err := ec2Client.DescribeInstanceTypes(ctx, []string{currentType})
+	if err != nil {
+		return nil, err
+	}
+	currentSpec, ok := currentSpecs[currentType]
+	if !ok {
+		return nil, fmt.Errorf("instance type %s not found", currentType)
+	}
+
+	utilization, err := instanceUtilization(ctx, opts.Profile, instance, currentSpec.GPUs > 0, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if hours := utilization.CPU.Hours(); hours < cost.MinUtilizationHours {
+		return nil, fmt.Errorf("CloudWatch has %.0f hours of CPU data for %s; recommendations need at least %d",
+			hours, instance.ID, cost.MinUtilizationHours)
+	}
+
+	specs, err := ec2Client.InstanceTypesForArchitecture(ctx, architecture)
+	if err != nil {
+		return nil, err
+	}
+	needed := cost.RequiredSizing(currentSpec, utilization)
+	candidates := rightsizeCandidates(ctx, specs, needed, instance.Region)
+	current := cost.RightsizeCandidate{
+		InstanceTypeSpec: currentSpec,
+		HourlyRate:       cost.LookupPrice(ctx, cost.PriceQuery{InstanceType: currentType, Region: instance.Region}).Hourly,
+	}
+
+	recommendation, err := cost.Rightsize(current, candidates, utilization)
+	if err != nil {
+		return nil, err
+	}
+	printRecommendation(instance, recommendation, utilization)
+
+	if !opts.Apply {
+		if recommendation.Changed() {
+			fmt.Println("\nRun again with --apply to change the instance type on the next stop.")
+		}
+		return &recommendation, nil
+	}
+	if !recommendation.Changed() {
+		fmt.Println("\nNothing to apply.")
+		return &recommendation, nil
+	}
+
+	if awsInstance.State != nil && awsInstance.State.Name == types.InstanceStateNameStopped {
+		fmt.Println()
+		return &recommendation, changeInstanceType(ctx, ec2Client, instance.ID, recommendation.Recommended.InstanceType)
+	}
+	err = config.UpdateInstance(instance.ID, func(instance *config.Instance) error {
+		instance.PendingType = recommendation.Recommended.InstanceType
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update state: %w", err)
+	}
+	fmt.Printf("\nThe instance type will change to %s the next time the instance is stopped or started.\n", recommendation.Recommended.InstanceType)
+	return &recommendation, nil
+}
+
+// instanceUtilization returns the CPU utilization of an instance since
+// launch, and its memory, disk and GPU utilization when the CloudWatch agent
+// publishes them
+func instanceUtilization(ctx context.Context, profile string, instance *config.Instance, gpus bool, now time.Time) (cost.Utilization, error) {
+	var utilization cost.Utilization
+	client, err := aws.NewCloudWatchClient(ctx, profile, instance.Region)
+	if err != nil {
+		return utilization, fmt.Errorf("failed to create CloudWatch client: %w", err)
+	}
+
+	stats := func(namespace, metric string) (aws.MetricStats, error) {
+		return client.InstanceMetricStats(ctx, namespace, instance.ID, metric, instance.LaunchedAt, now)
+	}
+	if utilization.CPU, err = stats(aws.NamespaceEC2, aws.MetricCPUUtilization); err != nil {
+		return utilization, err
+	}
+	if utilization.Memory, err = stats(aws.NamespaceAgent, aws.MetricMemoryUsed); err != nil {
+		return utilization, err
+	}
+	if utilization.Disk, err = stats(aws.NamespaceAgent, aws.MetricDiskUsed); err != nil {
+		return utilization, err
+	}
+	if gpus {
+		if utilization.GPU, err = stats(aws.NamespaceAgent, aws.MetricGPUUtilization); err != nil {
+			return utilization, err
+		}
+	}
+	return utilization, nil
+}
+
+// rightsizeCandidates returns the smallest type of each family that fits,
+// priced for the region. Types without a known price are left out.
+func rightsizeCandidates(ctx context.Context, specs []aws.InstanceTypeSpec, needed cost.Sizing, region string) []cost.RightsizeCandidate {
+	sort.Slice(specs, func(i, j int) bool {
+		if specs[i].VCPUs != specs[j].VCPUs {
+			return specs[i].VCPUs < specs[j].VCPUs
+		}
+		if specs[i].MemoryMiB != specs[j].MemoryMiB {
+			return specs[i].MemoryMiB < specs[j].MemoryMiB
+		}
+		return specs[i].InstanceType < specs[j].InstanceType
+	})
+
+	families := make(map[string]bool)
+	var candidates []cost.RightsizeCandidate
+	for _, spec := range specs {
+		if !rightsizeFamily.MatchString(spec.InstanceType) || !needed.Fits(spec) {
+			continue
+		}
+		family, _, _ := strings.Cut(spec.InstanceType, ".")
+		if families[family] {
+			continue
+		}
+		families[family] = true
+
+		price := cost.LookupPrice(ctx, cost.PriceQuery{InstanceType: spec.InstanceType, Region: region})
+		if price.Known() {
+			candidates = append(candidates, cost.RightsizeCandidate{InstanceTypeSpec: spec, HourlyRate: price.Hourly})
+		}
+	}
+	return candidates
+}
+
+// printRecommendation prints the utilization of an instance and the
+// recommended type with its projected monthly cost
+func printRecommendation(instance *config.Instance, r cost.Recommendation, u cost.Utilization) {
+	label := instance.ID
+	if instance.Name != "" {
+		label = fmt.Sprintf("%s (%s)", instance.Name, instance.ID)
+	}
+	fmt.Printf("Utilization of %s since %s (%s of data)\n\n", label,
+		instance.LaunchedAt.Format("2006-01-02"), cost.FormatHours(u.CPU.Hours()))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "METRIC\tAVERAGE\tPEAK HOURLY")
+	rows := []struct {
+		name  string
+		stats aws.MetricStats
+		shown bool
+	}{
+		{"CPU", u.CPU, true},
+		{"Memory", u.Memory, true},
+		{"Disk", u.Disk, true},
+		{"GPU", u.GPU, r.Current.GPUs > 0},
+	}
+	for _, row := range rows {
+		if !row.shown {
+			continue
+		}
+		if row.stats.Datapoints == 0 {
+			fmt.Fprintf(w, "%s\tnot measured\t\n", row.name)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%.0f%%\t%.0f%%\n", row.name, row.stats.Average, row.stats.Peak)
+	}
+	w.Flush()
+
+	fmt.Println()
+	switch r.Verdict {
+	case cost.RightsizeFits:
+		fmt.Printf("%s fits this workload; no cheaper type of the same architecture does.\n", describeInstanceType(r.Current))
+	case cost.RightsizeDownsize:
+		fmt.Printf("Recommended: %s, down from %s\n", describeInstanceType(r.Recommended), describeInstanceType(r.Current))
+	case cost.RightsizeUpsize:
+		fmt.Printf("Recommended: %s, up from %s, which is too small\n", describeInstanceType(r.Recommended), describeInstanceType(r.Current))
+	}
+
+	if r.Changed() {
+		changes := convertToCostStateChanges(instance.StateChanges)
+		current := cost.CalculateCost(cost.PriceQuery{InstanceType: r.Current.InstanceType, Region: instance.Region},
+			instance.LaunchedAt, changes, instance.EBSSize)
+		recommended := cost.CalculateCost(cost.PriceQuery{InstanceType: r.Recommended.InstanceType, Region: instance.Region},
+			instance.LaunchedAt, changes, instance.EBSSize)
+		ApplySchedule(current, instance)
+		ApplySchedule(recommended, instance)
+		currentMonthly, recommendedMonthly := current.EstimateMonthly(), recommended.EstimateMonthly()
+
+		basis := "the current usage pattern"
+		if current.Scheduled {
+			basis = "its schedule"
+		}
+		fmt.Printf("\nProjected monthly cost at %s:\n", basis)
+		fmt.Printf("  %-14s %s\n", r.Current.InstanceType, cost.FormatCostShort(currentMonthly))
+		fmt.Printf("  %-14s %s\n", r.Recommended.InstanceType, cost.FormatCostShort(recommendedMonthly))
+		if savings := currentMonthly - recommendedMonthly; savings >= 0 {
+			fmt.Printf("  Savings:       %s/month\n", cost.FormatCostShort(savings))
+		} else {
+			fmt.Printf("  Extra cost:    %s/month\n", cost.FormatCostShort(-savings))
+		}
+	}
+
+	if !r.Needed.MemoryMeasured {
+		fmt.Println("\nNote: Memory is not measured, so the current amount is kept. Install the CloudWatch")
+		fmt.Println("agent publishing mem_used_percent by InstanceId to allow types with less memory.")
+	}
+	if u.Disk.Datapoints > 0 && u.Disk.Peak > fullDiskPercent {
+		fmt.Printf("\nNote: The disk peaked at %.0f%% full; consider a larger volume.\n", u.Disk.Peak)
+	}
+}
+
+// describeInstanceType formats an instance type with its size, e.g.
+// "m7g.large (2 vCPUs, 8 GiB)"
+func describeInstanceType(c cost.RightsizeCandidate) string {
+	details := []string{fmt.Sprintf("%d vCPUs", c.VCPUs), fmt.Sprintf("%g GiB", c.MemoryGiB())}
+	if c.GPUs > 0 {
+		details = append(details, fmt.Sprintf("%d GPUs", c.GPUs))
+	}
+	if c.Burstable {
+		details = append(details, "burstable")
+	}
+	return fmt.Sprintf("%s (%s)", c.InstanceType, strings.Join(details, ", "))
+}
+
+// ApplyPendingType changes the type of a stopping or stopped instance to the
+// type queued by recommend --apply, once the instance has stopped
+func ApplyPendingType(ctx context.Context, ec2Client *aws.EC2Client, instance *config.Instance) error {
+	if instance.PendingType == "" {
+		return nil
+	}
+	fmt.Printf("Changing the instance type to %s once the instance has stopped...\n", instance.PendingType)
+	if err := ec2Client.WaitForInstanceStopped(ctx, instance.ID); err != nil {
+		return fmt.Errorf("failed waiting for instance to stop: %w", err)
+	}
+	return changeInstanceType(ctx, ec2Client, instance.ID, instance.PendingType)
+}
+
+// changeInstanceType changes the type of a stopped instance and records the
+// change in its history, so earlier running time keeps the old type's price
+func changeInstanceType(ctx context.Context, ec2Client *aws.EC2Client, instanceID, instanceType string) error {
+	if err := ec2Client.ModifyInstanceType(ctx, instanceID, instanceType); err != nil {
+		return fmt.Errorf("failed to change instance type: %w", err)
+	}
+	err := config.UpdateInstance(instanceID, func(instance *config.Instance) error {
+		instance.RecordTypeChange(instanceType)
+		instance.PendingType = ""
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Warning: Failed to update state: %v\n", err)
+	}
+	fmt.Printf("Instance type changed to %s\n", instanceType)
+	return nil
+}
@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/cost"
+	"github.com/scttfrdmn/lens/pkg/fakecloud"
+)
+
+// trackWithMetrics tracks a t4g.medium instance launched two days ago and
+// records hourly CPU and, when memory is positive, memory utilization
+func trackWithMetrics(t *testing.T, cloud *fakecloud.Cloud, cpuAverage, cpuPeak, memory float64) string {
+	t.Helper()
+	id := launchTagged(t, fakecloud.DefaultRegion, aws.InstanceMetadata{App: "lens-jupyter"})
+	launchedAt := time.Now().Add(-48 * time.Hour)
+	err := config.UpdateState(func(state *config.LocalState) error {
+		state.Instances[id] = &config.Instance{
+			ID:           id,
+			InstanceType: "t4g.medium",
+			Region:       fakecloud.DefaultRegion,
+			LaunchedAt:   launchedAt,
+			StateChanges: []config.StateChange{{State: "running", Timestamp: launchedAt}},
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateState failed: %v", err)
+	}
+
+	for hour := 0; hour < 47; hour++ {
+		at := launchedAt.Add(time.Duration(hour)*time.Hour + time.Minute)
+		cpu := cpuAverage
+		if hour == 20 {
+			cpu = cpuPeak
+		}
+		cloud.AddInstanceMetric(fakecloud.DefaultRegion, id, aws.MetricCPUUtilization, at, cpu)
+		if memory > 0 {
+			cloud.AddAgentMetric(fakecloud.DefaultRegion, id, aws.MetricMemoryUsed, at, memory)
+		}
+	}
+	return id
+}
+
+func TestRunRecommend_DownsizesOnNextStop(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	ctx := context.Background()
+
+	id := trackWithMetrics(t, cloud, 2, 10, 20)
+	recommendation, err := RunRecommend(ctx, id, RecommendOptions{Profile: "default", Apply: true})
+	if err != nil {
+		t.Fatalf("RunRecommend failed: %v", err)
+	}
+	if recommendation.Verdict != cost.RightsizeDownsize || recommendation.Recommended.InstanceType != "t4g.micro" {
+		t.Fatalf("recommendation = %s %s, want downsize to t4g.micro", recommendation.Verdict, recommendation.Recommended.InstanceType)
+	}
+
+	state, err := config.LoadState()
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if got := state.Instances[id].PendingType; got != "t4g.micro" {
+		t.Fatalf("pending type = %q, want t4g.micro", got)
+	}
+	if inst, _ := cloud.Instance(id); inst.InstanceType != "t4g.medium" {
+		t.Errorf("running instance changed to %s before it was stopped", inst.InstanceType)
+	}
+
+	if err := runStop(id, false); err != nil {
+		t.Fatalf("runStop failed: %v", err)
+	}
+	if inst, _ := cloud.Instance(id); inst.InstanceType != "t4g.micro" {
+		t.Errorf("instance type after stop = %s, want t4g.micro", inst.InstanceType)
+	}
+	state, err = config.LoadState()
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if instance := state.Instances[id]; instance.InstanceType != "t4g.micro" || instance.PendingType != "" {
+		t.Errorf("state after stop = %s pending %q, want t4g.micro and nothing pending", instance.InstanceType, instance.PendingType)
+	}
+}
+
+func TestRunRecommend_AppliesOnStartAfterAnotherStop(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	ctx := context.Background()
+
+	id := trackWithMetrics(t, cloud, 2, 10, 20)
+	if _, err := RunRecommend(ctx, id, RecommendOptions{Profile: "default", Apply: true}); err != nil {
+		t.Fatalf("RunRecommend failed: %v", err)
+	}
+
+	// Stopped by the idle agent, not by lens stop
+	if err := cloud.SetInstanceState(id, types.InstanceStateNameStopped); err != nil {
+		t.Fatalf("SetInstanceState failed: %v", err)
+	}
+	if err := runStart(id); err != nil {
+		t.Fatalf("runStart failed: %v", err)
+	}
+	inst, _ := cloud.Instance(id)
+	if inst.InstanceType != "t4g.micro" || inst.State.Name != types.InstanceStateNameRunning {
+		t.Errorf("instance after start = %s %s, want a running t4g.micro", inst.InstanceType, inst.State.Name)
+	}
+	if state, _ := config.LoadState(); state.Instances[id].PendingType != "" {
+		t.Errorf("pending type = %q after start, want nothing pending", state.Instances[id].PendingType)
+	}
+}
+
+func TestRunRecommend_UpsizesSaturatedInstance(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+
+	// Busy on average, without memory data from the CloudWatch agent
+	id := trackWithMetrics(t, cloud, 50, 90, 0)
+	recommendation, err := RunRecommend(context.Background(), id, RecommendOptions{Profile: "default"})
+	if err != nil {
+		t.Fatalf("RunRecommend failed: %v", err)
+	}
+	if recommendation.Verdict != cost.RightsizeUpsize || recommendation.Recommended.InstanceType != "c7g.xlarge" {
+		t.Errorf("recommendation = %s %s, want upsize to c7g.xlarge", recommendation.Verdict, recommendation.Recommended.InstanceType)
+	}
+	if recommendation.Needed.MemoryMeasured || recommendation.Needed.MemoryGiB != 4 {
+		t.Errorf("needed memory = %+v, want the current 4 GiB kept", recommendation.Needed)
+	}
+
+	state, err := config.LoadState()
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if got := state.Instances[id].PendingType; got != "" {
+		t.Errorf("pending type = %q without --apply", got)
+	}
+}
+
+func TestRunRecommend_NeedsADayOfData(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+
+	id := launchTagged(t, fakecloud.DefaultRegion, aws.InstanceMetadata{App: "lens-jupyter"})
+	launchedAt := time.Now().Add(-2 * time.Hour)
+	err := config.UpdateState(func(state *config.LocalState) error {
+		state.Instances[id] = &config.Instance{ID: id, Region: fakecloud.DefaultRegion, LaunchedAt: launchedAt}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateState failed: %v", err)
+	}
+	cloud.AddInstanceMetric(fakecloud.DefaultRegion, id, aws.MetricCPUUtilization, launchedAt.Add(time.Minute), 5)
+
+	_, err = RunRecommend(context.Background(), id, RecommendOptions{Profile: "default"})
+	if err == nil || !strings.Contains(err.Error(), "at least 24") {
+		t.Errorf("expected an error about too little data, got %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/cost"
+	"github.com/spf13/cobra"
+)
+
+// CostsReconcileOptions holds the options of the costs reconcile command
+type CostsReconcileOptions struct {
+	Profile  string
+	From     string // First day, e.g. "2026-01-01"; defaults to the start of last month
+	To       string // Last day, inclusive; defaults to today
+	AllUsers bool   // Include everyone's instances from the shared state backend
+}
+
+// NewCostsReconcileCmd creates the costs reconcile subcommand
+func NewCostsReconcileCmd(appName string) *cobra.Command {
+	var opts CostsReconcileOptions
+
+	cmd := &cobra.Command{
+		Use:   "reconcile",
+		Short: "Compare estimated costs with billed costs from Cost Explorer",
+		Long: `Compare the estimated cost of each instance with what AWS billed for it,
+per calendar month.
+
+Estimates come from the start/stop history recorded by lens, which misses
+stops made on the instance itself (such as idle auto-stops), so they drift
+from the bill over time. Billed costs come from Cost Explorer, grouped by the
+lens:instance tag that lens sets on instances and their volumes.
+
+The lens:instance tag must be activated as a cost allocation tag in the
+Billing console (Cost allocation tags) before Cost Explorer reports it, and
+only costs from the day it was activated are available. Cost Explorer data
+lags by up to a day, and the current month is not final.`,
+		Example: fmt.Sprintf(`  %[1]s costs reconcile
+  %[1]s costs reconcile --from 2026-01-01 --to 2026-06-30 --all-users`, appName),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunCostsReconcile(context.Background(), opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Profile, "profile", "p", "default", "AWS profile to use")
+	cmd.Flags().StringVar(&opts.From, "from", "", "First day, YYYY-MM-DD (default: start of last month)")
+	cmd.Flags().StringVar(&opts.To, "to", "", "Last day, YYYY-MM-DD (default: today)")
+	cmd.Flags().BoolVar(&opts.AllUsers, "all-users", false, "Include everyone's instances (requires a shared state backend)")
+
+	return cmd
+}
+
+// RunCostsReconcile prints the estimated and billed cost of each instance per month
+func RunCostsReconcile(ctx context.Context, opts CostsReconcileOptions) error {
+	now := time.Now()
+	if opts.From == "" {
+		opts.From = cost.MonthStart(now).AddDate(0, -1, 0).Format("2006-01-02")
+	}
+	from, to, err := reportPeriod(opts.From, opts.To, now)
+	if err != nil {
+		return err
+	}
+
+	instances, err := loadReportInstances(opts.AllUsers)
+	if err != nil {
+		return err
+	}
+	reportInstances := make([]cost.ReportInstance, len(instances))
+	names := make(map[string]string)
+	for i, instance := range instances {
+		reportInstances[i] = cost.ReportInstance{
+			Calc: cost.CalculateCost(
+				cost.PriceQuery{InstanceType: instance.InstanceType, Region: instance.Region},
+				instance.LaunchedAt,
+				convertToCostStateChanges(instance.StateChanges),
+				instance.EBSSize,
+			),
+			Groups: reportGroups(instance),
+		}
+		names[instance.ID] = instance.Name
+	}
+	estimates, err := cost.BuildReport(reportInstances, from, to, []string{cost.GroupInstance})
+	if err != nil {
+		return err
+	}
+
+	billed, err := loadBilledCosts(ctx, opts.Profile, from, to)
+	if err != nil {
+		return err
+	}
+	if len(billed) == 0 {
+		fmt.Printf("Warning: Cost Explorer reported no costs tagged %s for %s.\n", aws.TagInstance, estimates.Period())
+		fmt.Printf("Activate %s as a cost allocation tag in the Billing console; costs are reported from the day it is activated.\n\n", aws.TagInstance)
+	}
+
+	reconciliations, err := cost.Reconcile(estimates, billed)
+	if err != nil {
+		return err
+	}
+	if len(reconciliations) == 0 {
+		fmt.Println("No instance costs in this period")
+		return nil
+	}
+
+	fmt.Printf("Estimated vs billed costs, %s\n\n", estimates.Period())
+	return printReconciliations(reconciliations, names)
+}
+
+// loadBilledCosts returns the costs Cost Explorer billed per lens instance
+// and month. Costs of resources without the lens:instance tag are left out.
+func loadBilledCosts(ctx context.Context, profile string, from, to time.Time) ([]cost.BilledCost, error) {
+	client, err := aws.NewCostExplorerClient(ctx, profile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cost Explorer client: %w", err)
+	}
+	tagCosts, err := client.MonthlyCostByTag(ctx, aws.TagInstance, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get costs from Cost Explorer: %w", err)
+	}
+
+	var billed []cost.BilledCost
+	for _, tagCost := range tagCosts {
+		if tagCost.Value == "" {
+			continue
+		}
+		billed = append(billed, cost.BilledCost{
+			Month:      tagCost.Month.Format("2006-01"),
+			InstanceID: tagCost.Value,
+			Amount:     tagCost.Amount,
+			Estimated:  tagCost.Estimated,
+		})
+	}
+	return billed, nil
+}
+
+// printReconciliations prints a table of estimated and billed costs with a
+// total per month
+func printReconciliations(reconciliations []cost.Reconciliation, names map[string]string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	if _, err := fmt.Fprintln(w, "MONTH\tINSTANCE\tNAME\tESTIMATED\tBILLED\tDIFFERENCE"); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	var monthTotal cost.Reconciliation
+	flushMonth := func() {
+		if monthTotal.Month != "" {
+			fmt.Fprintf(w, "%s\t%s\t\t%s\t%s\t%s\n", monthTotal.Month, "Total",
+				cost.FormatCostShort(monthTotal.Estimated), formatBilled(monthTotal), formatDifference(monthTotal))
+		}
+	}
+	for _, r := range reconciliations {
+		if r.Month != monthTotal.Month {
+			flushMonth()
+			monthTotal = cost.Reconciliation{Month: r.Month, Tracked: true, HasBill: true}
+		}
+		monthTotal.Estimated += r.Estimated
+		monthTotal.Billed += r.Billed
+		monthTotal.BillEstimated = monthTotal.BillEstimated || r.BillEstimated
+
+		estimated := cost.FormatCostShort(r.Estimated)
+		if !r.Tracked {
+			estimated = "untracked"
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.Month, r.InstanceID, names[r.InstanceID], estimated, formatBilled(r), formatDifference(r)); err != nil {
+			return fmt.Errorf("failed to write reconciliation: %w", err)
+		}
+	}
+	flushMonth()
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, r := range reconciliations {
+		if r.BillEstimated {
+			fmt.Println("\n~ Billed cost of a month that is not final yet")
+			break
+		}
+	}
+	return nil
+}
+
+// formatBilled formats a billed cost, marking costs that are not final
+func formatBilled(r cost.Reconciliation) string {
+	if !r.HasBill {
+		return "-"
+	}
+	if r.BillEstimated {
+		return "~" + cost.FormatCostShort(r.Billed)
+	}
+	return cost.FormatCostShort(r.Billed)
+}
+
+// formatDifference formats the billed minus estimated cost with its
+// percentage of the estimate, e.g. "+$1.20 (+15%)"
+func formatDifference(r cost.Reconciliation) string {
+	if !r.Tracked || !r.HasBill {
+		return ""
+	}
+	difference := r.Difference()
+	sign := "+"
+	if difference < 0 {
+		sign = "-"
+	}
+	formatted := sign + cost.FormatCostShort(math.Abs(difference))
+	if percent := r.DifferencePercent(); !math.IsNaN(percent) {
+		formatted += fmt.Sprintf(" (%+.0f%%)", percent)
+	}
+	return formatted
+}
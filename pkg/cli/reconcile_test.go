@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/cost"
+	"github.com/scttfrdmn/lens/pkg/fakecloud"
+)
+
+func TestLoadBilledCosts_ReconcilesWithEstimates(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 0, 0, 0, 0, time.UTC) }
+	cloud.AddBilledCost(day(1, 10), map[string]string{aws.TagInstance: "i-tracked"}, 3)
+	cloud.AddBilledCost(day(1, 20), map[string]string{aws.TagInstance: "i-tracked"}, 1.5)
+	cloud.AddBilledCost(day(2, 1), map[string]string{aws.TagInstance: "i-other"}, 2)
+	cloud.AddBilledCost(day(2, 2), map[string]string{}, 100)                           // Untagged
+	cloud.AddBilledCost(day(3, 1), map[string]string{aws.TagInstance: "i-tracked"}, 9) // Outside the period
+
+	billed, err := loadBilledCosts(context.Background(), "default", day(1, 1), day(3, 1))
+	if err != nil {
+		t.Fatalf("loadBilledCosts failed: %v", err)
+	}
+	want := []cost.BilledCost{
+		{Month: "2026-01", InstanceID: "i-tracked", Amount: 4.5},
+		{Month: "2026-02", InstanceID: "i-other", Amount: 2},
+	}
+	if len(billed) != len(want) {
+		t.Fatalf("billed = %+v, want %+v", billed, want)
+	}
+	for i := range want {
+		if billed[i] != want[i] {
+			t.Errorf("billed[%d] = %+v, want %+v", i, billed[i], want[i])
+		}
+	}
+
+	// The instance ran 100 hours in January according to its recorded history
+	calc := cost.CalculateCost(cost.PriceQuery{InstanceType: "t4g.medium", Region: "us-east-1"}, day(1, 5), []cost.StateChange{
+		{State: "running", Timestamp: day(1, 5)},
+		{State: "stopped", Timestamp: day(1, 5).Add(100 * time.Hour)},
+	}, 0)
+	estimates, err := cost.BuildReport([]cost.ReportInstance{
+		{Calc: calc, Groups: map[string]string{cost.GroupInstance: "i-tracked"}},
+	}, day(1, 1), day(3, 1), []string{cost.GroupInstance})
+	if err != nil {
+		t.Fatalf("BuildReport failed: %v", err)
+	}
+
+	reconciliations, err := cost.Reconcile(estimates, billed)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if len(reconciliations) != 2 {
+		t.Fatalf("reconciliations = %+v, want 2", reconciliations)
+	}
+	tracked, other := reconciliations[0], reconciliations[1]
+	if !tracked.Tracked || !tracked.HasBill || math.Abs(tracked.Difference()-(4.5-100*calc.HourlyRate)) > 1e-9 {
+		t.Errorf("tracked = %+v, want billed 4.5 against an estimate of %.2f", tracked, 100*calc.HourlyRate)
+	}
+	if other.Tracked || other.InstanceID != "i-other" || other.Billed != 2 {
+		t.Errorf("other = %+v, want an untracked billed instance", other)
+	}
+}
+
+func TestLoadBilledCosts_ReturnsCostExplorerErrors(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	cloud.FailNext("GetCostAndUsage", fakecloud.APIError("AccessDeniedException", "not authorized"))
+
+	if _, err := loadBilledCosts(context.Background(), "default", time.Now().AddDate(0, -1, 0), time.Now()); err == nil {
+		t.Error("expected an error when Cost Explorer fails")
+	}
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// NewRenameCmd creates the rename command for changing an instance's name.
+// appName is the name of the CLI, used in examples (e.g. "lens-jupyter").
+func NewRenameCmd(appName string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rename INSTANCE NEW_NAME",
+		Short: "Change the name of an instance",
+		Long: `Change the friendly name of an instance.
+
+The name is stored in local state and as the instance's EC2 Name tag. Names
+must be unique among your tracked instances and can be used instead of the
+instance ID in every command.`,
+		Example: fmt.Sprintf(`  # Name an instance by ID
+  %[1]s rename i-0abc123 thesis-analysis
+
+  # Rename it later using its current name
+  %[1]s rename thesis-analysis thesis-final`, appName),
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunRename(context.Background(), args[0], args[1])
+		},
+	}
+}
+
+// RunRename renames the instance ref refers to
+func RunRename(ctx context.Context, ref, newName string) error {
+	if err := config.ValidateInstanceName(newName); err != nil {
+		return err
+	}
+
+	state, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	instance, err := state.FindInstance(ref)
+	if err != nil {
+		return err
+	}
+	if err := state.CheckNameAvailable(newName, instance.ID); err != nil {
+		return err
+	}
+
+	ec2Client, err := aws.NewEC2ClientForRegion(ctx, instance.Region)
+	if err != nil {
+		return fmt.Errorf("failed to create AWS client: %w", err)
+	}
+	if err := ec2Client.SetInstanceName(ctx, instance.ID, newName); err != nil {
+		return fmt.Errorf("failed to tag instance: %w", err)
+	}
+
+	err = config.UpdateState(func(state *config.LocalState) error {
+		if err := state.CheckNameAvailable(newName, instance.ID); err != nil {
+			return err
+		}
+		current, ok := state.Instances[instance.ID]
+		if !ok {
+			return fmt.Errorf("instance %s not found in local state", instance.ID)
+		}
+		current.Name = newName
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update state: %w", err)
+	}
+
+	fmt.Printf("✓ Renamed %s to %s\n", instance.DisplayName(), newName)
+	return nil
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/fakecloud"
+)
+
+func TestRunRename_UpdatesStateAndNameTag(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+
+	id := launchTagged(t, fakecloud.DefaultRegion, aws.InstanceMetadata{App: "lens-jupyter", Name: "draft"})
+	err := config.UpdateState(func(state *config.LocalState) error {
+		state.Instances[id] = &config.Instance{ID: id, Name: "draft", Region: fakecloud.DefaultRegion}
+		state.Instances["i-other"] = &config.Instance{ID: "i-other", Name: "taken", Region: fakecloud.DefaultRegion}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateState failed: %v", err)
+	}
+
+	if err := RunRename(context.Background(), "draft", "thesis-analysis"); err != nil {
+		t.Fatalf("RunRename failed: %v", err)
+	}
+
+	state, err := config.LoadState()
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if got := state.Instances[id].Name; got != "thesis-analysis" {
+		t.Errorf("Expected name thesis-analysis in state, got %q", got)
+	}
+	inst, _ := cloud.Instance(id)
+	if got := aws.ParseInstanceMetadata(inst.Tags).Name; got != "thesis-analysis" {
+		t.Errorf("Expected Name tag thesis-analysis, got %q", got)
+	}
+
+	err = RunRename(context.Background(), "thesis-analysis", "taken")
+	if err == nil || !strings.Contains(err.Error(), "already used") {
+		t.Errorf("Expected duplicate name to be rejected, got %v", err)
+	}
+	err = RunRename(context.Background(), id, "i-looks-like-an-id")
+	if err == nil {
+		t.Error("Expected invalid name to be rejected")
+	}
+}
+
+func TestLaunchInstance_NameTagDefaultsToApp(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+
+	id := launchTagged(t, fakecloud.DefaultRegion, aws.InstanceMetadata{App: "lens-rstudio"})
+
+	inst, _ := cloud.Instance(id)
+	for _, tag := range inst.Tags {
+		if *tag.Key == aws.TagName && *tag.Value != "lens-rstudio" {
+			t.Errorf("Expected Name tag lens-rstudio for an unnamed instance, got %q", *tag.Value)
+		}
+	}
+	if name := aws.ParseInstanceMetadata(inst.Tags).Name; name != "" {
+		t.Errorf("Expected no friendly name for an unnamed instance, got %q", name)
+	}
+}
+
+func TestResolveInstance_SelectsOnlyInstance(t *testing.T) {
+	state := &config.LocalState{Instances: map[string]*config.Instance{
+		"i-1": {ID: "i-1", Name: "only"},
+	}}
+
+	instance, err := ResolveInstance(state, "")
+	if err != nil {
+		t.Fatalf("ResolveInstance failed: %v", err)
+	}
+	if instance.ID != "i-1" {
+		t.Errorf("Expected i-1, got %s", instance.ID)
+	}
+
+	state.Instances["i-2"] = &config.Instance{ID: "i-2", Name: "second"}
+	if _, err := ResolveInstance(state, ""); err == nil || !strings.Contains(err.Error(), "second") {
+		t.Errorf("Expected an error listing the instances without a terminal, got %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/cost"
+	"github.com/spf13/cobra"
+)
+
+// CostsReportOptions holds the options of the costs report command
+type CostsReportOptions struct {
+	From     string // First day, e.g. "2026-01-01"; defaults to the start of this month
+	To       string // Last day, inclusive; defaults to today
+	GroupBy  string // Comma-separated dimensions, e.g. "project,env"
+	Format   string // csv, json, markdown or html
+	Output   string // File to write; stdout when empty
+	AllUsers bool   // Include everyone's instances from the shared state backend
+}
+
+// NewCostsReportCmd creates the costs report subcommand
+func NewCostsReportCmd(appName string) *cobra.Command {
+	var opts CostsReportOptions
+
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Export costs by month, grouped by project, environment or app",
+		Long: `Export the cost of instances between two dates, bucketed by calendar month
+and grouped by the given dimensions, with totals per group.
+
+Costs are computed from the recorded start/stop history of each instance,
+including instances that have since been terminated, and cover compute and
+root volumes.
+
+Dimensions: project, env, app, user, type, region, instance.`,
+		Example: fmt.Sprintf(`  %[1]s costs report
+  %[1]s costs report --from 2026-01-01 --to 2026-06-30 --group-by project,env,app --format csv -o costs.csv
+  %[1]s costs report --group-by user --format html -o costs.html --all-users`, appName),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunCostsReport(opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.From, "from", "", "First day of the report, YYYY-MM-DD (default: start of this month)")
+	cmd.Flags().StringVar(&opts.To, "to", "", "Last day of the report, YYYY-MM-DD (default: today)")
+	cmd.Flags().StringVar(&opts.GroupBy, "group-by", cost.GroupProject, "Comma-separated dimensions to group by")
+	cmd.Flags().StringVar(&opts.Format, "format", cost.ReportMarkdown, "Output format: csv, json, markdown or html")
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", "", "Write the report to a file instead of stdout")
+	cmd.Flags().BoolVar(&opts.AllUsers, "all-users", false, "Include everyone's instances (requires a shared state backend)")
+
+	return cmd
+}
+
+// RunCostsReport builds and writes a cost report
+func RunCostsReport(opts CostsReportOptions) error {
+	from, to, err := reportPeriod(opts.From, opts.To, time.Now())
+	if err != nil {
+		return err
+	}
+	groupBy, err := cost.ParseGroupBy(opts.GroupBy)
+	if err != nil {
+		return err
+	}
+
+	instances, err := loadReportInstances(opts.AllUsers)
+	if err != nil {
+		return err
+	}
+
+	reportInstances := make([]cost.ReportInstance, len(instances))
+	for i, instance := range instances {
+		reportInstances[i] = cost.ReportInstance{
+			Calc: cost.CalculateCost(
+				cost.PriceQuery{InstanceType: instance.InstanceType, Region: instance.Region},
+				instance.LaunchedAt,
+				convertToCostStateChanges(instance.StateChanges),
+				instance.EBSSize,
+			),
+			Groups: reportGroups(instance),
+		}
+	}
+
+	report, err := cost.BuildReport(reportInstances, from, to, groupBy)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if opts.Output != "" {
+		file, err := os.Create(opts.Output)
+		if err != nil {
+			return fmt.Errorf("failed to create report: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := report.Write(out, opts.Format); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if opts.Output != "" {
+		fmt.Printf("Wrote cost report for %d instances to %s\n", report.Total.Instances, opts.Output)
+	}
+	return nil
+}
+
+// reportPeriod parses the first and inclusive last day of a report into a
+// half-open time range
+func reportPeriod(fromValue, toValue string, now time.Time) (time.Time, time.Time, error) {
+	from := cost.MonthStart(now)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1)
+
+	if fromValue != "" {
+		day, err := time.ParseInLocation("2006-01-02", fromValue, now.Location())
+		if err != nil {
+			return from, to, fmt.Errorf("invalid --from date %q (use YYYY-MM-DD)", fromValue)
+		}
+		from = day
+	}
+	if toValue != "" {
+		day, err := time.ParseInLocation("2006-01-02", toValue, now.Location())
+		if err != nil {
+			return from, to, fmt.Errorf("invalid --to date %q (use YYYY-MM-DD)", toValue)
+		}
+		to = day.AddDate(0, 0, 1)
+	}
+	return from, to, nil
+}
+
+// loadReportInstances returns the instances of the local state, or everyone's
+// when allUsers is set, including terminated ones
+func loadReportInstances(allUsers bool) ([]*config.Instance, error) {
+	if allUsers {
+		return loadBudgetInstances()
+	}
+
+	state, err := config.LoadState()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	instances := make([]*config.Instance, 0, len(state.Instances)+len(state.Terminated))
+	for _, instance := range state.Instances {
+		instances = append(instances, instance)
+	}
+	return append(instances, state.Terminated...), nil
+}
+
+// reportGroups returns the value of each report dimension for an instance
+func reportGroups(instance *config.Instance) map[string]string {
+	return map[string]string{
+		cost.GroupProject:  instance.Project,
+		cost.GroupEnv:      instance.Environment,
+		cost.GroupApp:      instance.App,
+		cost.GroupUser:     aws.CallerIdentity{ARN: instance.Owner}.Name(),
+		cost.GroupType:     instance.InstanceType,
+		cost.GroupRegion:   instance.Region,
+		cost.GroupInstance: instance.ID,
+	}
+}
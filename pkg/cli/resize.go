@@ -0,0 +1,244 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// growFilesystemScript grows the root partition and file system to the size
+// of the root volume. growpart reports NOCHANGE with exit code 1 when the
+// partition already fills the volume.
+const growFilesystemScript = `set -e
+root=$(findmnt -n -o SOURCE /)
+disk=/dev/$(lsblk -no PKNAME "$root")
+if [ -e "/sys/class/block/$(basename "$root")/partition" ]; then
+  part=$(cat "/sys/class/block/$(basename "$root")/partition")
+  out=$(growpart "$disk" "$part" 2>&1) || echo "$out" | grep -q NOCHANGE || { echo "$out" >&2; exit 1; }
+fi
+case $(findmnt -n -o FSTYPE /) in
+  xfs) xfs_growfs -d / ;;
+  *) resize2fs "$root" ;;
+esac
+df -h /`
+
+// ResizeOptions holds the options of the resize command
+type ResizeOptions struct {
+	Profile string
+	Type    string // New instance type
+	Disk    int    // New root volume size in GB
+}
+
+// NewResizeCmd creates the resize command for changing the instance type and
+// root volume size of an instance
+func NewResizeCmd(appName string) *cobra.Command {
+	var opts ResizeOptions
+
+	cmd := &cobra.Command{
+		Use:   "resize [INSTANCE]",
+		Short: "Change the instance type or grow the root volume of an instance",
+		Long: `Change the instance type or grow the root volume of an instance, keeping its
+home directory and data.
+
+--type stops a running instance, changes its type and starts it again. The
+new type must support the architecture of the instance's AMI.
+
+--disk grows the root volume while the instance runs and extends the
+partition and file system over Session Manager. EBS volumes cannot shrink,
+and a volume can only be resized once every six hours. The file system of a
+stopped instance is extended when it next boots.`,
+		Example: fmt.Sprintf(`  %[1]s resize my-analysis --type r7g.xlarge
+  %[1]s resize my-analysis --disk 200
+  %[1]s resize my-analysis --type m7g.2xlarge --disk 100`, appName),
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			instanceRef := ""
+			if len(args) > 0 {
+				instanceRef = args[0]
+			}
+			return RunResize(context.Background(), instanceRef, opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Profile, "profile", "p", "default", "AWS profile to use")
+	cmd.Flags().StringVar(&opts.Type, "type", "", "New instance type, e.g. r7g.xlarge")
+	cmd.Flags().IntVar(&opts.Disk, "disk", 0, "New root volume size in GB")
+
+	return cmd
+}
+
+// RunResize changes the type and root volume size of the instance ref refers to
+func RunResize(ctx context.Context, instanceRef string, opts ResizeOptions) error {
+	if opts.Type == "" && opts.Disk == 0 {
+		return fmt.Errorf("nothing to resize: use --type and/or --disk")
+	}
+
+	state, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	instance, err := ResolveInstance(state, instanceRef)
+	if err != nil {
+		return err
+	}
+
+	ec2Client, err := aws.NewEC2ClientForProfileRegion(ctx, opts.Profile, instance.Region)
+	if err != nil {
+		return fmt.Errorf("failed to create AWS client: %w", err)
+	}
+	awsInstance, err := ec2Client.GetInstanceInfo(ctx, instance.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get instance info: %w", err)
+	}
+
+	if opts.Type != "" {
+		if err := resizeType(ctx, ec2Client, instance, awsInstance, opts.Type); err != nil {
+			return err
+		}
+		if awsInstance, err = ec2Client.GetInstanceInfo(ctx, instance.ID); err != nil {
+			return fmt.Errorf("failed to get instance info: %w", err)
+		}
+	}
+	if opts.Disk != 0 {
+		return resizeDisk(ctx, opts.Profile, ec2Client, instance, awsInstance, opts.Disk)
+	}
+	return nil
+}
+
+// resizeType changes the type of an instance, stopping it first and starting
+// it again if it was running
+func resizeType(ctx context.Context, ec2Client *aws.EC2Client, instance *config.Instance, awsInstance *ec2types.Instance, instanceType string) error {
+	if string(awsInstance.InstanceType) == instanceType {
+		return fmt.Errorf("instance %s is already %s", instance.ID, instanceType)
+	}
+	specs, err := ec2Client.DescribeInstanceTypes(ctx, []string{instanceType})
+	if err != nil {
+		return err
+	}
+	spec, ok := specs[instanceType]
+	if !ok {
+		return fmt.Errorf("instance type %s is not offered in %s", instanceType, instance.Region)
+	}
+	architecture := string(awsInstance.Architecture)
+	if !spec.Supports(architecture) {
+		return fmt.Errorf("%s does not support the %s architecture of the instance's AMI (it supports %s)",
+			instanceType, architecture, strings.Join(spec.Architectures, ", "))
+	}
+
+	wasRunning := false
+	switch awsInstance.State.Name {
+	case ec2types.InstanceStateNameStopped:
+	case ec2types.InstanceStateNamePending, ec2types.InstanceStateNameRunning:
+		wasRunning = true
+		fmt.Printf("Stopping instance %s to change its type...\n", instance.ID)
+		if err := ec2Client.StopInstance(ctx, instance.ID, false); err != nil {
+			return fmt.Errorf("failed to stop instance: %w", err)
+		}
+		if err := config.UpdateInstance(instance.ID, func(instance *config.Instance) error {
+			instance.RecordStateChange("stopped")
+			return nil
+		}); err != nil {
+			fmt.Printf("Warning: Failed to update state: %v\n", err)
+		}
+		if err := ec2Client.WaitForInstanceStopped(ctx, instance.ID); err != nil {
+			return fmt.Errorf("failed waiting for instance to stop: %w", err)
+		}
+	default:
+		return fmt.Errorf("instance is in state '%s', can only resize running or stopped instances", awsInstance.State.Name)
+	}
+
+	if err := changeInstanceType(ctx, ec2Client, instance.ID, instanceType); err != nil {
+		return err
+	}
+	if !wasRunning {
+		return nil
+	}
+
+	fmt.Printf("Starting instance %s...\n", instance.ID)
+	if err := ec2Client.StartInstance(ctx, instance.ID); err != nil {
+		return fmt.Errorf("failed to start instance: %w", err)
+	}
+	if err := ec2Client.WaitForInstanceRunning(ctx, instance.ID); err != nil {
+		return fmt.Errorf("failed waiting for instance to start: %w", err)
+	}
+	started, err := ec2Client.GetInstanceInfo(ctx, instance.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get updated instance info: %w", err)
+	}
+	err = config.UpdateInstance(instance.ID, func(instance *config.Instance) error {
+		if started.PublicIpAddress != nil {
+			instance.PublicIP = *started.PublicIpAddress
+		}
+		instance.RecordStateChange("running")
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Warning: Failed to update state: %v\n", err)
+	}
+	fmt.Printf("Instance %s is running as %s\n", instance.ID, instanceType)
+	return nil
+}
+
+// resizeDisk grows the root volume of an instance and, when it is running,
+// its partition and file system
+func resizeDisk(ctx context.Context, profile string, ec2Client *aws.EC2Client, instance *config.Instance, awsInstance *ec2types.Instance, sizeGB int) error {
+	volume, err := ec2Client.RootVolume(ctx, *awsInstance)
+	if err != nil {
+		return err
+	}
+	volumeID := *volume.VolumeId
+	currentSize := int(*volume.Size)
+	if sizeGB <= currentSize {
+		return fmt.Errorf("the root volume is %d GB; it can only grow, not shrink to %d GB", currentSize, sizeGB)
+	}
+
+	fmt.Printf("Growing root volume %s from %d GB to %d GB...\n", volumeID, currentSize, sizeGB)
+	if err := ec2Client.GrowVolume(ctx, volumeID, sizeGB); err != nil {
+		return fmt.Errorf("failed to grow volume: %w", err)
+	}
+	if err := ec2Client.SetEBSSizeTag(ctx, instance.ID, sizeGB); err != nil {
+		fmt.Printf("Warning: Failed to tag instance with its new disk size: %v\n", err)
+	}
+	if err := config.UpdateInstance(instance.ID, func(instance *config.Instance) error {
+		instance.EBSSize = sizeGB
+		return nil
+	}); err != nil {
+		fmt.Printf("Warning: Failed to update state: %v\n", err)
+	}
+	if err := ec2Client.WaitForVolumeModification(ctx, volumeID, 10*time.Minute); err != nil {
+		return err
+	}
+
+	if awsInstance.State.Name != ec2types.InstanceStateNameRunning {
+		fmt.Printf("Root volume is now %d GB; the file system grows when the instance next boots\n", sizeGB)
+		return nil
+	}
+
+	fmt.Println("Growing the partition and file system over Session Manager...")
+	ssmClient, err := aws.NewSSMClientForProfileRegion(ctx, profile, instance.Region)
+	if err != nil {
+		return fmt.Errorf("failed to create SSM client: %w", err)
+	}
+	commandID, err := ssmClient.RunCommand(ctx, instance.ID, growFilesystemScript)
+	if err != nil {
+		return fmt.Errorf("volume grown, but failed to grow the file system (it grows on the next boot): %w", err)
+	}
+	result, err := ssmClient.WaitForCommand(ctx, commandID, instance.ID, 2*time.Minute)
+	if err != nil {
+		return fmt.Errorf("volume grown, but failed to grow the file system (it grows on the next boot): %w", err)
+	}
+	if result.Status != ssmtypes.CommandInvocationStatusSuccess {
+		return fmt.Errorf("volume grown, but growing the file system failed (it grows on the next boot): %s",
+			strings.TrimSpace(result.ErrorOutput))
+	}
+	fmt.Print(result.Output)
+	fmt.Printf("\nRoot volume and file system are now %d GB\n", sizeGB)
+	return nil
+}
@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/fakecloud"
+)
+
+// trackManaged launches a running instance registered with Session Manager
+// and tracks it in local state
+func trackManaged(t *testing.T, cloud *fakecloud.Cloud) string {
+	t.Helper()
+	ctx := context.Background()
+
+	iamClient, err := aws.NewIAMClient(ctx, "default")
+	if err != nil {
+		t.Fatalf("NewIAMClient failed: %v", err)
+	}
+	profile, err := iamClient.GetOrCreateSessionManagerRole(ctx, "lens-jupyter")
+	if err != nil {
+		t.Fatalf("GetOrCreateSessionManagerRole failed: %v", err)
+	}
+	id := launchTagged(t, fakecloud.DefaultRegion, aws.InstanceMetadata{App: "lens-jupyter"}, func(params *aws.LaunchParams) {
+		params.InstanceProfile = profile.Name
+	})
+	if err := cloud.SetInstanceState(id, types.InstanceStateNameRunning); err != nil {
+		t.Fatalf("SetInstanceState failed: %v", err)
+	}
+
+	launchedAt := time.Now().Add(-time.Hour)
+	err = config.UpdateState(func(state *config.LocalState) error {
+		state.Instances[id] = &config.Instance{
+			ID:           id,
+			InstanceType: "t4g.medium",
+			Region:       fakecloud.DefaultRegion,
+			EBSSize:      30,
+			LaunchedAt:   launchedAt,
+			StateChanges: []config.StateChange{{State: "running", Timestamp: launchedAt}},
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateState failed: %v", err)
+	}
+	return id
+}
+
+func TestRunResize_ChangesTypeAndGrowsDisk(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	id := trackManaged(t, cloud)
+
+	err := RunResize(context.Background(), id, ResizeOptions{Profile: "default", Type: "r7g.xlarge", Disk: 100})
+	if err != nil {
+		t.Fatalf("RunResize failed: %v", err)
+	}
+
+	inst, _ := cloud.Instance(id)
+	if inst.InstanceType != "r7g.xlarge" || inst.State.Name != types.InstanceStateNameRunning {
+		t.Errorf("instance is %s and %s, want r7g.xlarge and running", inst.InstanceType, inst.State.Name)
+	}
+	volumes := cloud.Volumes(fakecloud.DefaultRegion)
+	if len(volumes) != 1 || *volumes[0].Size != 100 {
+		t.Errorf("root volume = %+v, want 100 GB", volumes)
+	}
+
+	invocations := cloud.Invocations()
+	if len(invocations) != 1 || !strings.Contains(invocations[0].Script(), "growpart") || !strings.Contains(invocations[0].Script(), "resize2fs") {
+		t.Errorf("expected one growpart/resize2fs command, got %+v", invocations)
+	}
+
+	state, err := config.LoadState()
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	instance := state.Instances[id]
+	if instance.InstanceType != "r7g.xlarge" || instance.EBSSize != 100 {
+		t.Errorf("state = %s with %d GB, want r7g.xlarge with 100 GB", instance.InstanceType, instance.EBSSize)
+	}
+	var states []string
+	for _, change := range instance.StateChanges {
+		states = append(states, change.State)
+	}
+	if got := strings.Join(states, ","); got != "running,stopped,running" {
+		t.Errorf("state changes = %s, want running,stopped,running", got)
+	} else if from := instance.StateChanges[1].ResizedFrom; from != "t4g.medium" {
+		t.Errorf("stop resized from %q, want t4g.medium", from)
+	}
+}
+
+func TestRunResize_DiskSizeSurvivesSync(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	id := trackManaged(t, cloud)
+	ctx := context.Background()
+
+	// launch tags the instance with its disk size, and sync copies the tag to state
+	ec2Client, err := aws.NewEC2ClientForRegion(ctx, fakecloud.DefaultRegion)
+	if err != nil {
+		t.Fatalf("NewEC2ClientForRegion failed: %v", err)
+	}
+	if err := ec2Client.SetEBSSizeTag(ctx, id, 30); err != nil {
+		t.Fatalf("SetEBSSizeTag failed: %v", err)
+	}
+
+	if err := RunResize(ctx, id, ResizeOptions{Profile: "default", Disk: 100}); err != nil {
+		t.Fatalf("RunResize failed: %v", err)
+	}
+	if _, err := RunSync(ctx, SyncOptions{Profile: "default"}); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	state, _ := config.LoadState()
+	if got := state.Instances[id].EBSSize; got != 100 {
+		t.Errorf("expected sync to keep the 100 GB disk size, got %d GB", got)
+	}
+}
+
+func TestRunResize_Rejects(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	id := trackManaged(t, cloud)
+	ctx := context.Background()
+
+	// x86_64 type for an arm64 AMI
+	err := RunResize(ctx, id, ResizeOptions{Profile: "default", Type: "m6i.large"})
+	if err == nil || !strings.Contains(err.Error(), "arm64") {
+		t.Errorf("expected an architecture error, got %v", err)
+	}
+	if inst, _ := cloud.Instance(id); inst.InstanceType != "t4g.medium" || inst.State.Name != types.InstanceStateNameRunning {
+		t.Errorf("rejected resize touched the instance: %s %s", inst.InstanceType, inst.State.Name)
+	}
+
+	// Volumes cannot shrink
+	err = RunResize(ctx, id, ResizeOptions{Profile: "default", Disk: 20})
+	if err == nil || !strings.Contains(err.Error(), "only grow") {
+		t.Errorf("expected an error shrinking the disk, got %v", err)
+	}
+	if err := RunResize(ctx, id, ResizeOptions{Profile: "default"}); err == nil {
+		t.Error("expected an error without --type or --disk")
+	}
+}
@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/cost"
+)
+
+// bytesPerGB is the gigabyte used by AWS billing
+const bytesPerGB = 1 << 30
+
+// LoadResourceCosts returns the NAT Gateways, Elastic IPs, public IPv4
+// addresses and snapshots of the regions the instances run in, and the data
+// transfer of each instance measured by CloudWatch. Regions and metrics that
+// cannot be read are skipped with a warning.
+func LoadResourceCosts(ctx context.Context, profile string, instances []*config.Instance) []cost.ResourceCost {
+	var resources []cost.ResourceCost
+
+	var regions []string
+	seen := make(map[string]bool)
+	for _, instance := range instances {
+		if !seen[instance.Region] {
+			seen[instance.Region] = true
+			regions = append(regions, instance.Region)
+		}
+	}
+	sort.Strings(regions)
+
+	for _, region := range regions {
+		ec2Client, err := aws.NewEC2ClientForProfileRegion(ctx, profile, region)
+		if err != nil {
+			fmt.Printf("Warning: Skipping resources in %s: %v\n", region, err)
+			continue
+		}
+		footprint, err := ec2Client.ScanFootprint(ctx)
+		if err != nil {
+			fmt.Printf("Warning: Skipping resources in %s: %v\n", region, err)
+			continue
+		}
+		resources = append(resources, cost.FootprintCosts(footprint)...)
+	}
+
+	viaNAT := make(map[string]bool)
+	for _, resource := range resources {
+		if resource.Kind == cost.ResourceNATGateway {
+			for _, instanceID := range resource.Instances {
+				viaNAT[instanceID] = true
+			}
+		}
+	}
+
+	for _, instance := range instances {
+		transfer, err := instanceTransferCost(ctx, profile, instance, viaNAT[instance.ID])
+		if err != nil {
+			fmt.Printf("Warning: Skipping data transfer estimates: %v\n", err)
+			break
+		}
+		if transfer != nil {
+			resources = append(resources, *transfer)
+		}
+	}
+
+	return resources
+}
+
+// instanceTransferCost projects the monthly data transfer charge of an
+// instance from the traffic CloudWatch measured since launch. It returns nil
+// when the instance has not sent or received anything.
+func instanceTransferCost(ctx context.Context, profile string, instance *config.Instance, viaNAT bool) (*cost.ResourceCost, error) {
+	client, err := aws.NewCloudWatchClient(ctx, profile, instance.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	in, err := client.InstanceMetricSum(ctx, instance.ID, aws.MetricNetworkIn, instance.LaunchedAt, now)
+	if err != nil {
+		return nil, err
+	}
+	out, err := client.InstanceMetricSum(ctx, instance.ID, aws.MetricNetworkOut, instance.LaunchedAt, now)
+	if err != nil {
+		return nil, err
+	}
+	if in == 0 && out == 0 {
+		return nil, nil
+	}
+
+	inGB, outGB := in/bytesPerGB, out/bytesPerGB
+	elapsedHours := now.Sub(instance.LaunchedAt).Hours()
+	if elapsedHours < 1 {
+		elapsedHours = 1
+	}
+
+	description := fmt.Sprintf("%.1f GB in, %.1f GB out since launch", inGB, outGB)
+	if viaNAT {
+		description += " via NAT Gateway"
+	}
+	return &cost.ResourceCost{
+		Kind:        cost.ResourceTransfer,
+		Region:      instance.Region,
+		Description: description,
+		Monthly:     cost.TransferCost(inGB, outGB, viaNAT) * cost.HoursPerMonth / elapsedHours,
+		Instances:   []string{instance.ID},
+		Estimated:   true,
+	}, nil
+}
+
+// UnattributedResources returns the resources that none of the tracked
+// instances use, such as idle Elastic IPs and unused NAT Gateways
+func UnattributedResources(resources []cost.ResourceCost, tracked map[string]bool) []cost.ResourceCost {
+	var unattributed []cost.ResourceCost
+	for _, resource := range resources {
+		used := false
+		for _, instanceID := range resource.Instances {
+			used = used || tracked[instanceID]
+		}
+		if !used {
+			unattributed = append(unattributed, resource)
+		}
+	}
+	return unattributed
+}
+
+// PrintResourceCosts prints resources as an indented table with their
+// monthly cost. Estimated costs are marked with "~".
+func PrintResourceCosts(indent string, resources []cost.ResourceCost) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, resource := range resources {
+		id := resource.ID
+		if id == "" {
+			id = "-"
+		}
+		monthly := cost.FormatCostShort(resource.Monthly) + "/month"
+		if resource.Estimated {
+			monthly = "~" + monthly
+		}
+		fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\t%s\n", indent, resource.Kind, id, resource.Region, monthly, resource.Description)
+	}
+	_ = w.Flush()
+}
+
+// ResourceKinds summarises the kinds of resources, e.g. "NAT Gateway, Data transfer"
+func ResourceKinds(resources []cost.ResourceCost) string {
+	var kinds []string
+	seen := make(map[string]bool)
+	for _, resource := range resources {
+		if !seen[resource.Kind] {
+			seen[resource.Kind] = true
+			kinds = append(kinds, resource.Kind)
+		}
+	}
+	return strings.Join(kinds, ", ")
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/cost"
+	"github.com/scttfrdmn/lens/pkg/fakecloud"
+)
+
+func TestLoadResourceCosts_AttributesFootprintAndTransfer(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	f := seedGCCloud(t, cloud)
+
+	launchedAt := time.Now().Add(-48 * time.Hour)
+	cloud.AddInstanceMetric("us-east-1", f.liveInstance, aws.MetricNetworkOut, launchedAt.Add(time.Hour), 10*bytesPerGB)
+	cloud.AddInstanceMetric("us-east-1", f.liveInstance, aws.MetricNetworkIn, launchedAt.Add(2*time.Hour), 2*bytesPerGB)
+
+	instances := []*config.Instance{
+		{ID: f.liveInstance, Region: "us-east-1", LaunchedAt: launchedAt},
+		{ID: "i-gone", Region: "us-west-2", LaunchedAt: launchedAt},
+	}
+	resources := LoadResourceCosts(context.Background(), "default", instances)
+
+	byKind := make(map[string][]cost.ResourceCost)
+	for _, resource := range resources {
+		byKind[resource.Kind] = append(byKind[resource.Kind], resource)
+	}
+
+	if nats := byKind[cost.ResourceNATGateway]; len(nats) != 1 || nats[0].ID != f.natGateway || len(nats[0].Instances) != 0 {
+		t.Errorf("Expected unused NAT Gateway %s, got %+v", f.natGateway, nats)
+	} else if want := (cost.NATGatewayPricePerHour + cost.PublicIPv4PricePerHour) * cost.HoursPerMonth; math.Abs(nats[0].Monthly-want) > 0.001 {
+		t.Errorf("Expected NAT Gateway to include its Elastic IP (%.2f), got %.2f", want, nats[0].Monthly)
+	}
+	if eips := byKind[cost.ResourceElasticIP]; len(eips) != 1 || eips[0].ID != f.strayAddress {
+		t.Errorf("Expected only the idle Elastic IP %s, got %+v", f.strayAddress, eips)
+	}
+	if ips := byKind[cost.ResourcePublicIPv4]; len(ips) != 1 || ips[0].Instances[0] != f.liveInstance || !ips[0].WhileRunning {
+		t.Errorf("Expected the public IPv4 address of %s, got %+v", f.liveInstance, ips)
+	}
+	snapshots := byKind[cost.ResourceSnapshot]
+	if len(snapshots) != 2 {
+		t.Fatalf("Expected the snapshots of both AMIs, got %+v", snapshots)
+	}
+	for _, snapshot := range snapshots {
+		if len(snapshot.Instances) != 1 || snapshot.Instances[0] != f.liveInstance {
+			t.Errorf("Expected snapshot %s to be attributed to %s, got %v", snapshot.ID, f.liveInstance, snapshot.Instances)
+		}
+	}
+
+	transfers := byKind[cost.ResourceTransfer]
+	if len(transfers) != 1 || transfers[0].Instances[0] != f.liveInstance {
+		t.Fatalf("Expected data transfer of %s only, got %+v", f.liveInstance, transfers)
+	}
+	want := 10 * cost.DataTransferOutPricePerGB * cost.HoursPerMonth / 48
+	if math.Abs(transfers[0].Monthly-want) > 0.01 {
+		t.Errorf("Expected transfer of %.2f/month, got %.2f", want, transfers[0].Monthly)
+	}
+
+	unattributed := UnattributedResources(resources, map[string]bool{f.liveInstance: true})
+	if len(unattributed) != 2 {
+		t.Errorf("Expected the NAT Gateway and idle Elastic IP to be unattributed, got %+v", unattributed)
+	}
+
+	calc := cost.CalculateCost(cost.PriceQuery{InstanceType: "t4g.medium", Region: "us-east-1"}, launchedAt, nil, 20)
+	before := calc.EstimateMonthly()
+	calc.AddResources(f.liveInstance, resources)
+	if len(calc.Resources) != 4 {
+		t.Errorf("Expected 4 resources for %s, got %+v", f.liveInstance, calc.Resources)
+	}
+	if got := calc.EstimateMonthly() - before; math.Abs(got-calc.ResourcesMonthly()) > 0.001 || got <= 0 {
+		t.Errorf("Expected EstimateMonthly to include resources of %.2f, got %.2f more", calc.ResourcesMonthly(), got)
+	}
+}
+
+func TestLoadResourceCosts_SkipsTransferWhenCloudWatchFails(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	f := seedGCCloud(t, cloud)
+	cloud.AddInstanceMetric("us-east-1", f.liveInstance, aws.MetricNetworkOut, time.Now().Add(-time.Hour), bytesPerGB)
+	cloud.FailNext("GetMetricStatistics", fakecloud.APIError("AccessDenied", "not authorized"))
+
+	instances := []*config.Instance{{ID: f.liveInstance, Region: "us-east-1", LaunchedAt: time.Now().Add(-2 * time.Hour)}}
+	resources := LoadResourceCosts(context.Background(), "default", instances)
+
+	for _, resource := range resources {
+		if resource.Kind == cost.ResourceTransfer {
+			t.Errorf("Expected no data transfer after CloudWatch failed, got %+v", resource)
+		}
+	}
+	if len(resources) == 0 {
+		t.Error("Expected the footprint to be reported without CloudWatch")
+	}
+}
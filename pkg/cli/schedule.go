@@ -0,0 +1,352 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/cost"
+	"github.com/scttfrdmn/lens/pkg/schedule"
+	"github.com/spf13/cobra"
+)
+
+// ScheduleOptions holds the options of the schedule commands
+type ScheduleOptions struct {
+	Profile  string
+	Region   string // schedule list only; default: the regions of tracked instances
+	Start    string // Cron expression, e.g. "0 8 * * 1-5"
+	Stop     string
+	Timezone string
+}
+
+// NewScheduleCmd creates the schedule command for starting and stopping
+// instances at set times
+func NewScheduleCmd(appName string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schedule",
+		Short: "Start and stop instances at set times",
+		Long: `Start and stop instances at set times, e.g. office hours on weekdays.
+
+Schedules are EventBridge Scheduler schedules that call EC2 StartInstances and
+StopInstances, so they run whether or not this computer is on. Times are
+standard five-field cron expressions (minute hour day-of-month month
+day-of-week) in the --tz time zone. An instance may have only a start or only
+a stop time. Idle auto-stop still applies while a schedule keeps an instance
+running.
+
+Scheduled starts and stops are added to the instance's history by sync, and
+cost estimates of a scheduled instance assume it runs on its schedule.`,
+		Example: fmt.Sprintf(`  %[1]s schedule set my-analysis --start "0 8 * * 1-5" --stop "0 18 * * 1-5" --tz America/New_York
+  %[1]s schedule set my-analysis --stop "0 20 * * *"
+  %[1]s schedule list
+  %[1]s schedule delete my-analysis`, appName),
+	}
+
+	cmd.AddCommand(newScheduleSetCmd(appName))
+	cmd.AddCommand(newScheduleListCmd())
+	cmd.AddCommand(newScheduleDeleteCmd())
+
+	return cmd
+}
+
+func newScheduleSetCmd(appName string) *cobra.Command {
+	var opts ScheduleOptions
+	cmd := &cobra.Command{
+		Use:   "set [INSTANCE]",
+		Short: "Set the start/stop schedule of an instance",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			instanceRef := ""
+			if len(args) > 0 {
+				instanceRef = args[0]
+			}
+			_, err := RunScheduleSet(context.Background(), appName, instanceRef, opts)
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Profile, "profile", "p", "default", "AWS profile to use")
+	cmd.Flags().StringVar(&opts.Start, "start", "", `When to start the instance, e.g. "0 8 * * 1-5"`)
+	cmd.Flags().StringVar(&opts.Stop, "stop", "", `When to stop the instance, e.g. "0 18 * * 1-5"`)
+	cmd.Flags().StringVar(&opts.Timezone, "tz", "UTC", "Time zone of the times, e.g. America/New_York")
+	return cmd
+}
+
+func newScheduleListCmd() *cobra.Command {
+	var opts ScheduleOptions
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List start/stop schedules and their next run",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunScheduleList(context.Background(), opts)
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Profile, "profile", "p", "default", "AWS profile to use")
+	cmd.Flags().StringVarP(&opts.Region, "region", "r", "", "AWS region (default: the regions of tracked instances)")
+	return cmd
+}
+
+func newScheduleDeleteCmd() *cobra.Command {
+	var opts ScheduleOptions
+	cmd := &cobra.Command{
+		Use:   "delete [INSTANCE]",
+		Short: "Delete the start/stop schedule of an instance",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			instanceRef := ""
+			if len(args) > 0 {
+				instanceRef = args[0]
+			}
+			return RunScheduleDelete(context.Background(), instanceRef, opts)
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Profile, "profile", "p", "default", "AWS profile to use")
+	return cmd
+}
+
+// RunScheduleSet creates or replaces the schedules that start and stop the
+// instance ref refers to, and records the schedule in local state
+func RunScheduleSet(ctx context.Context, appName, instanceRef string, opts ScheduleOptions) (*config.Schedule, error) {
+	parsed, err := schedule.New(opts.Start, opts.Stop, opts.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := config.LoadState()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	instance, err := ResolveInstance(state, instanceRef)
+	if err != nil {
+		return nil, err
+	}
+
+	iamClient, err := aws.NewIAMClient(ctx, opts.Profile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create IAM client: %w", err)
+	}
+	roleARN, err := iamClient.GetOrCreateSchedulerRole(ctx, appName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up the scheduler role: %w", err)
+	}
+	schedulerClient, err := aws.NewSchedulerClient(ctx, opts.Profile, instance.Region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Scheduler client: %w", err)
+	}
+
+	timezone := parsed.Location.String()
+	for _, action := range []struct {
+		name string
+		cron *schedule.Cron
+	}{{aws.ScheduleActionStart, parsed.Start}, {aws.ScheduleActionStop, parsed.Stop}} {
+		if action.cron == nil {
+			// Replacing a schedule removes a start or stop it no longer has
+			resource := aws.Resource{Kind: aws.ResourceSchedule, ID: aws.InstanceScheduleName(instance.ID, action.name)}
+			if err := schedulerClient.DeleteResource(ctx, resource); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		expression, err := action.cron.EventBridge()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := schedulerClient.PutInstanceSchedule(ctx, instance.ID, action.name, expression, timezone, roleARN, appName); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	set := &config.Schedule{Start: opts.Start, Stop: opts.Stop, Timezone: timezone, Since: now}
+	if err := config.UpdateInstance(instance.ID, func(instance *config.Instance) error {
+		// Keep what the previous schedule did before replacing it
+		instance.MergeStateChanges(instance.ScheduledStateChanges(now))
+		instance.Schedule = set
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to update state: %w", err)
+	}
+
+	fmt.Printf("✓ Schedule of %s set (%s)\n", instance.DisplayName(), timezone)
+	if opts.Start != "" {
+		fmt.Printf("  Start: %s\n", opts.Start)
+	}
+	if opts.Stop != "" {
+		fmt.Printf("  Stop:  %s\n", opts.Stop)
+	}
+	if next, ok := parsed.Next(now); ok {
+		fmt.Printf("  Next:  %s\n", describeTransition(next))
+	}
+	if hours, ok := parsed.HoursPerWeek(); ok {
+		calc := cost.CalculateCost(cost.PriceQuery{InstanceType: instance.InstanceType, Region: instance.Region},
+			instance.LaunchedAt, nil, instance.EBSSize)
+		calc.Scheduled, calc.ScheduledHoursPerWeek = true, hours
+		fmt.Printf("  Runs %.0fh a week, about %s/month\n", hours, cost.FormatCostShort(calc.EstimateMonthly()))
+	}
+	return set, nil
+}
+
+// RunScheduleList lists the lens schedules in a region, or in every region
+// with tracked instances
+func RunScheduleList(ctx context.Context, opts ScheduleOptions) error {
+	state, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	regions := []string{opts.Region}
+	if opts.Region == "" {
+		seen := make(map[string]bool)
+		regions = nil
+		for _, instance := range state.Instances {
+			if !seen[instance.Region] {
+				seen[instance.Region] = true
+				regions = append(regions, instance.Region)
+			}
+		}
+		sort.Strings(regions)
+		if len(regions) == 0 {
+			// The profile's region
+			regions = []string{""}
+		}
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INSTANCE\tACTION\tSCHEDULE\tTIME ZONE\tSTATE\tNEXT")
+	found := 0
+	for _, region := range regions {
+		schedulerClient, err := aws.NewSchedulerClient(ctx, opts.Profile, region)
+		if err != nil {
+			return fmt.Errorf("failed to create Scheduler client: %w", err)
+		}
+		schedules, err := schedulerClient.ListInstanceSchedules(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range schedules {
+			found++
+			name, expression, next := s.InstanceID, s.Expression, "-"
+			if instance, ok := state.Instances[s.InstanceID]; ok {
+				name = instance.DisplayName()
+				if instance.Schedule != nil {
+					expression, next = describeScheduleAction(instance.Schedule, s.Action, now)
+				}
+			}
+			enabled := "enabled"
+			if !s.Enabled {
+				enabled, next = "disabled", "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", name, s.Action, expression, s.Timezone, enabled, next)
+		}
+	}
+	if found == 0 {
+		fmt.Println("No schedules found")
+		return nil
+	}
+	return w.Flush()
+}
+
+// describeScheduleAction returns the cron expression of an action of a
+// schedule and when it next runs
+func describeScheduleAction(s *config.Schedule, action string, now time.Time) (string, string) {
+	expression := s.Start
+	if action == aws.ScheduleActionStop {
+		expression = s.Stop
+	}
+	parsed, err := s.Parse()
+	if err != nil || expression == "" {
+		return expression, "-"
+	}
+	cron := parsed.Start
+	if action == aws.ScheduleActionStop {
+		cron = parsed.Stop
+	}
+	next := cron.Next(now.In(parsed.Location))
+	if next.IsZero() {
+		return expression, "-"
+	}
+	return expression, next.Local().Format("Mon Jan 2 15:04")
+}
+
+// describeTransition describes a scheduled start or stop
+func describeTransition(transition schedule.Transition) string {
+	verb := "start"
+	if transition.State == schedule.StateStopped {
+		verb = "stop"
+	}
+	return fmt.Sprintf("%s at %s", verb, transition.At.Local().Format("Mon Jan 2 15:04"))
+}
+
+// RunScheduleDelete deletes the schedules of the instance ref refers to
+func RunScheduleDelete(ctx context.Context, instanceRef string, opts ScheduleOptions) error {
+	state, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	instance, err := ResolveInstance(state, instanceRef)
+	if err != nil {
+		return err
+	}
+
+	schedulerClient, err := aws.NewSchedulerClient(ctx, opts.Profile, instance.Region)
+	if err != nil {
+		return fmt.Errorf("failed to create Scheduler client: %w", err)
+	}
+	deleted, err := schedulerClient.DeleteInstanceSchedules(ctx, instance.ID)
+	if err != nil {
+		return err
+	}
+	if err := clearSchedule(instance.ID); err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		fmt.Printf("%s has no schedule\n", instance.DisplayName())
+		return nil
+	}
+	fmt.Printf("✓ Schedule of %s deleted\n", instance.DisplayName())
+	return nil
+}
+
+// DeleteSchedules deletes the schedules of an instance being terminated
+func DeleteSchedules(ctx context.Context, instance *config.Instance) error {
+	if instance.Schedule == nil {
+		return nil
+	}
+	schedulerClient, err := aws.NewSchedulerClientForRegion(ctx, instance.Region)
+	if err != nil {
+		return fmt.Errorf("failed to create Scheduler client: %w", err)
+	}
+	if _, err := schedulerClient.DeleteInstanceSchedules(ctx, instance.ID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// clearSchedule removes the schedule of an instance from local state, after
+// recording the starts and stops it made
+func clearSchedule(instanceID string) error {
+	now := time.Now()
+	if err := config.UpdateInstance(instanceID, func(instance *config.Instance) error {
+		instance.MergeStateChanges(instance.ScheduledStateChanges(now))
+		instance.Schedule = nil
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to update state: %w", err)
+	}
+	return nil
+}
+
+// ApplySchedule makes the monthly estimate of a cost calculation assume the
+// instance runs on its schedule, if it has one with both a start and a stop
+func ApplySchedule(calc *cost.CostCalculation, instance *config.Instance) {
+	if hours, ok := instance.ScheduledHoursPerWeek(); ok {
+		calc.Scheduled, calc.ScheduledHoursPerWeek = true, hours
+	}
+}
@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/cost"
+	"github.com/scttfrdmn/lens/pkg/fakecloud"
+)
+
+func TestSchedule_SetFireReplaceDelete(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	ctx := context.Background()
+	id := trackManaged(t, cloud)
+
+	set, err := RunScheduleSet(ctx, "lens-jupyter", id, ScheduleOptions{
+		Profile:  "default",
+		Start:    "0 8 * * 1-5",
+		Stop:     "0 18 * * 1-5",
+		Timezone: "America/New_York",
+	})
+	if err != nil {
+		t.Fatalf("RunScheduleSet failed: %v", err)
+	}
+	if set.Timezone != "America/New_York" {
+		t.Errorf("unexpected schedule: %+v", set)
+	}
+
+	start, ok := cloud.Schedule(fakecloud.DefaultRegion, aws.ScheduleGroup, aws.InstanceScheduleName(id, aws.ScheduleActionStart))
+	if !ok {
+		t.Fatal("start schedule was not created")
+	}
+	if start.Expression != "cron(0 8 ? * MON-FRI *)" || start.Timezone != "America/New_York" {
+		t.Errorf("unexpected start schedule: %+v", start)
+	}
+	if start.TargetArn != "arn:aws:scheduler:::aws-sdk:ec2:startInstances" || !strings.Contains(start.Input, id) {
+		t.Errorf("expected the schedule to start %s, got %s %s", id, start.TargetArn, start.Input)
+	}
+	if _, ok := cloud.Role(aws.SchedulerRoleName); !ok {
+		t.Errorf("expected role %s to be created", aws.SchedulerRoleName)
+	}
+	state, _ := config.LoadState()
+	if got := state.Instances[id].Schedule; got == nil || got.Start != "0 8 * * 1-5" || got.Stop != "0 18 * * 1-5" {
+		t.Errorf("expected the schedule in state, got %+v", got)
+	}
+
+	// EventBridge Scheduler stops and starts the instance
+	stopName := aws.InstanceScheduleName(id, aws.ScheduleActionStop)
+	if err := cloud.FireSchedule(fakecloud.DefaultRegion, aws.ScheduleGroup, stopName); err != nil {
+		t.Fatalf("FireSchedule failed: %v", err)
+	}
+	if instance, _ := cloud.Instance(id); instance.State.Name != types.InstanceStateNameStopped {
+		t.Errorf("expected the stop schedule to stop the instance, got %s", instance.State.Name)
+	}
+	if err := cloud.FireSchedule(fakecloud.DefaultRegion, aws.ScheduleGroup, start.Name); err != nil {
+		t.Fatalf("FireSchedule failed: %v", err)
+	}
+	if instance, _ := cloud.Instance(id); instance.State.Name != types.InstanceStateNameRunning {
+		t.Errorf("expected the start schedule to start the instance, got %s", instance.State.Name)
+	}
+
+	// Replacing the schedule with a stop only removes the start
+	if _, err := RunScheduleSet(ctx, "lens-jupyter", id, ScheduleOptions{Profile: "default", Stop: "0 20 * * *", Timezone: "UTC"}); err != nil {
+		t.Fatalf("RunScheduleSet failed: %v", err)
+	}
+	if _, ok := cloud.Schedule(fakecloud.DefaultRegion, aws.ScheduleGroup, start.Name); ok {
+		t.Error("expected the start schedule to be deleted")
+	}
+	if stop, _ := cloud.Schedule(fakecloud.DefaultRegion, aws.ScheduleGroup, stopName); stop.Expression != "cron(0 20 * * ? *)" {
+		t.Errorf("expected the stop schedule to be replaced, got %q", stop.Expression)
+	}
+
+	if err := RunScheduleList(ctx, ScheduleOptions{Profile: "default"}); err != nil {
+		t.Errorf("RunScheduleList failed: %v", err)
+	}
+
+	if err := RunScheduleDelete(ctx, id, ScheduleOptions{Profile: "default"}); err != nil {
+		t.Fatalf("RunScheduleDelete failed: %v", err)
+	}
+	if _, ok := cloud.Schedule(fakecloud.DefaultRegion, aws.ScheduleGroup, stopName); ok {
+		t.Error("expected the stop schedule to be deleted")
+	}
+	state, _ = config.LoadState()
+	if state.Instances[id].Schedule != nil {
+		t.Errorf("expected the schedule to be removed from state, got %+v", state.Instances[id].Schedule)
+	}
+}
+
+func TestRunScheduleSet_Invalid(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	id := trackManaged(t, cloud)
+	ctx := context.Background()
+
+	for name, opts := range map[string]ScheduleOptions{
+		"no times":     {Timezone: "UTC"},
+		"bad cron":     {Start: "0 25 * * *", Timezone: "UTC"},
+		"both days":    {Stop: "0 18 1 * 1-5", Timezone: "UTC"},
+		"bad timezone": {Stop: "0 18 * * *", Timezone: "Mars/Olympus_Mons"},
+	} {
+		opts.Profile = "default"
+		if _, err := RunScheduleSet(ctx, "lens-jupyter", id, opts); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, ok := cloud.Role(aws.SchedulerRoleName); ok {
+		t.Error("expected no role to be created for an invalid schedule")
+	}
+}
+
+func TestSchedule_CollectedAfterTerminate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	ctx := context.Background()
+	id := trackManaged(t, cloud)
+
+	if _, err := RunScheduleSet(ctx, "lens-jupyter", id, ScheduleOptions{Profile: "default", Stop: "0 18 * * *", Timezone: "UTC"}); err != nil {
+		t.Fatalf("RunScheduleSet failed: %v", err)
+	}
+
+	// A schedule left behind by an instance terminated outside lens is an orphan
+	if err := cloud.SetInstanceState(id, types.InstanceStateNameTerminated); err != nil {
+		t.Fatalf("SetInstanceState failed: %v", err)
+	}
+	orphans, err := FindOrphans(ctx, GCOptions{Profile: "default"})
+	if err != nil {
+		t.Fatalf("FindOrphans failed: %v", err)
+	}
+	stopName := aws.InstanceScheduleName(id, aws.ScheduleActionStop)
+	if ids := orphanIDs(orphans)[aws.ResourceSchedule]; len(ids) != 1 || ids[0] != stopName {
+		t.Errorf("expected the stop schedule to be an orphan, got %v", ids)
+	}
+	if err := RunGC(GCOptions{Profile: "default", Yes: true}); err != nil {
+		t.Fatalf("RunGC failed: %v", err)
+	}
+	if _, ok := cloud.Schedule(fakecloud.DefaultRegion, aws.ScheduleGroup, stopName); ok {
+		t.Error("expected gc to delete the schedule")
+	}
+}
+
+func TestApplySchedule(t *testing.T) {
+	instance := &config.Instance{
+		Schedule: &config.Schedule{Start: "0 8 * * 1-5", Stop: "0 18 * * 1-5", Timezone: "UTC"},
+	}
+	calc := cost.CalculateCost(cost.PriceQuery{InstanceType: "t4g.medium", Region: "us-east-1"},
+		time.Now().Add(-time.Hour), nil, 0)
+	ApplySchedule(calc, instance)
+	if !calc.Scheduled || calc.ScheduledHoursPerWeek != 50 {
+		t.Fatalf("expected 50 scheduled hours a week, got %+v", calc)
+	}
+	want := calc.HourlyRate * 50 * 30 / 7
+	if got := calc.EstimateMonthly(); math.Abs(got-want) > 0.01 {
+		t.Errorf("expected about %.2f a month, got %.2f", want, got)
+	}
+
+	// A stop-only schedule does not bound the running time
+	unbounded := cost.CalculateCost(cost.PriceQuery{InstanceType: "t4g.medium", Region: "us-east-1"},
+		time.Now().Add(-time.Hour), nil, 0)
+	ApplySchedule(unbounded, &config.Instance{Schedule: &config.Schedule{Stop: "0 18 * * *", Timezone: "UTC"}})
+	if unbounded.Scheduled {
+		t.Error("expected a stop-only schedule not to change the estimate")
+	}
+}
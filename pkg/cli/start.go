@@ -12,18 +12,22 @@ import (
 // NewStartCmd creates the start command for starting stopped instances
 func NewStartCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "start INSTANCE_ID",
+		Use:   "start [INSTANCE]",
 		Short: "Start a stopped instance",
-		Args:  cobra.ExactArgs(1),
+		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runStart(args[0])
+			instanceRef := ""
+			if len(args) > 0 {
+				instanceRef = args[0]
+			}
+			return runStart(instanceRef)
 		},
 	}
 
 	return cmd
 }
 
-func runStart(instanceID string) error {
+func runStart(instanceRef string) error {
 	ctx := context.Background()
 
 	// Load state to get instance details
@@ -32,11 +36,12 @@ func runStart(instanceID string) error {
 		return fmt.Errorf("failed to load state: %w", err)
 	}
 
-	// Get instance from state
-	instance, exists := state.Instances[instanceID]
-	if !exists {
-		return fmt.Errorf("instance %s not found in local state", instanceID)
+	// Resolve the instance by name, ID or prefix
+	instance, err := ResolveInstance(state, instanceRef)
+	if err != nil {
+		return err
 	}
+	instanceID := instance.ID
 
 	// Create AWS client for the instance's region
 	ec2Client, err := aws.NewEC2ClientForRegion(ctx, instance.Region)
@@ -63,6 +68,12 @@ func runStart(instanceID string) error {
 		return fmt.Errorf("instance is in state '%s', can only start instances in 'stopped' state", stateName)
 	}
 
+	// Change to a type queued by recommend --apply, however the instance
+	// was stopped
+	if err := ApplyPendingType(ctx, ec2Client, instance); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
 	// Start the instance
 	fmt.Printf("Starting instance %s...\n", instanceID)
 
@@ -83,14 +94,14 @@ func runStart(instanceID string) error {
 	}
 
 	// Update state with new public IP and record state change
-	if instanceInfo.PublicIpAddress != nil {
-		instance.PublicIP = *instanceInfo.PublicIpAddress
-	}
-
-	// Record state change to "running"
-	instance.RecordStateChange("running")
-
-	if err := state.Save(); err != nil {
+	err = config.UpdateInstance(instanceID, func(instance *config.Instance) error {
+		if instanceInfo.PublicIpAddress != nil {
+			instance.PublicIP = *instanceInfo.PublicIpAddress
+		}
+		instance.RecordStateChange("running")
+		return nil
+	})
+	if err != nil {
 		fmt.Printf("Warning: Failed to update state: %v\n", err)
 	}
 
@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// SyncOptions controls a sync run
+type SyncOptions struct {
+	Profile string
+	Region  string // Only sync this region; all enabled regions if empty
+
+	// CloudTrail backfills the state history of tracked instances from the
+	// start, stop and terminate events in CloudTrail
+	CloudTrail bool
+}
+
+// NewSyncCmd creates the sync command for reconciling local state with AWS.
+// appName is the name of the CLI, used in examples (e.g. "lens-jupyter").
+func NewSyncCmd(appName string) *cobra.Command {
+	var opts SyncOptions
+
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Rebuild local state from the instances in AWS",
+		Long: `Reconcile the local state file with the lens instances that exist in AWS.
+
+Instances launched by any lens tool carry their metadata as tags (app,
+environment, idle timeout, key pair, AMI base, S3 bucket and owner), so local
+state can be rebuilt on a new machine or after state.json was lost.
+
+Tracked instances are refreshed with their current type, IP address and
+running state, instances that no longer exist are removed, and lens instances
+that are not tracked yet are imported. Instances without the lens:instance
+cost allocation tag get it.
+
+Starts and stops that lens did not make itself (idle auto-stops on the
+instance, console actions, Spot interruptions) are missing from the state
+history used for costs. sync infers them from the launch time and state
+transition reason EC2 reports, and with --cloudtrail also backfills them from
+the StartInstances, StopInstances and TerminateInstances events of the last
+90 days in CloudTrail. Inferred changes are marked as such in the history. Every enabled region is scanned unless
+--region is given; regions that cannot be scanned are left untouched.`,
+		Example: fmt.Sprintf(`  # Sync all regions
+  %[1]s sync
+
+  # Only sync one region
+  %[1]s sync --region us-west-2
+
+  # Also backfill start/stop history from CloudTrail
+  %[1]s sync --cloudtrail`, appName),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := RunSync(context.Background(), opts)
+			return err
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Profile, "profile", "p", "default", "AWS profile to use")
+	cmd.Flags().StringVarP(&opts.Region, "region", "r", "", "Only sync this region (default: all enabled regions)")
+	cmd.Flags().BoolVar(&opts.CloudTrail, "cloudtrail", false, "Backfill start/stop history from CloudTrail events")
+
+	return cmd
+}
+
+// RunSync reconciles local state with the lens instances in each region and
+// saves it
+func RunSync(ctx context.Context, opts SyncOptions) (config.SyncResult, error) {
+	var total config.SyncResult
+
+	regions := []string{opts.Region}
+	if opts.Region == "" {
+		ec2Client, err := aws.NewEC2Client(ctx, opts.Profile)
+		if err != nil {
+			return total, fmt.Errorf("failed to create AWS client: %w", err)
+		}
+		regions, err = ec2Client.ListRegions(ctx)
+		if err != nil {
+			return total, err
+		}
+	}
+
+	// Scan every region before taking the state lock, so that other commands
+	// are not blocked while AWS is queried
+	scanned := make(map[string][]types.Instance)
+	events := make(map[string][]config.StateChange)
+	for _, region := range regions {
+		fmt.Printf("Scanning %s...\n", region)
+		ec2Client, err := aws.NewEC2ClientForProfileRegion(ctx, opts.Profile, region)
+		if err != nil {
+			return total, fmt.Errorf("failed to create AWS client for %s: %w", region, err)
+		}
+
+		instances, err := ec2Client.ListLensInstances(ctx)
+		if err != nil {
+			if opts.Region != "" {
+				return total, err
+			}
+			fmt.Printf("Warning: Skipping region %s: %v\n", region, err)
+			continue
+		}
+		scanned[region] = instances
+
+		// Instances launched before lens:instance was set are tagged so their
+		// billed costs can be reconciled
+		for _, instance := range instances {
+			if err := ec2Client.TagCostAllocation(ctx, instance); err != nil {
+				fmt.Printf("Warning: Failed to set the %s tag on %s: %v\n", aws.TagInstance, *instance.InstanceId, err)
+			}
+		}
+
+		if opts.CloudTrail {
+			if err := lookupStateEvents(ctx, opts.Profile, region, instances, events); err != nil {
+				fmt.Printf("Warning: Skipping CloudTrail history in %s: %v\n", region, err)
+			}
+		}
+	}
+
+	now := time.Now()
+	err := config.UpdateState(func(state *config.LocalState) error {
+		total = config.SyncResult{}
+		for _, region := range regions {
+			instances, ok := scanned[region]
+			if !ok {
+				continue
+			}
+			result := state.Reconcile(region, instances, now)
+			for _, id := range result.Imported {
+				fmt.Printf("  + %s (%s)\n", id, state.Instances[id].Environment)
+			}
+			for _, id := range result.Removed {
+				fmt.Printf("  - %s (no longer exists)\n", id)
+			}
+			total.Imported = append(total.Imported, result.Imported...)
+			total.Updated = append(total.Updated, result.Updated...)
+			total.Removed = append(total.Removed, result.Removed...)
+			total.Backfilled = append(total.Backfilled, result.Backfilled...)
+		}
+
+		backfilled := make(map[string]bool)
+		for _, id := range total.Backfilled {
+			backfilled[id] = true
+		}
+		for _, id := range state.Backfill(events) {
+			if !backfilled[id] {
+				total.Backfilled = append(total.Backfilled, id)
+			}
+		}
+		for _, id := range total.Backfilled {
+			fmt.Printf("  ~ %s (state history backfilled)\n", id)
+		}
+		return nil
+	})
+	if err != nil {
+		return total, fmt.Errorf("failed to save state: %w", err)
+	}
+
+	fmt.Printf("\nSummary: %d imported, %d updated, %d removed, %d backfilled\n",
+		len(total.Imported), len(total.Updated), len(total.Removed), len(total.Backfilled))
+	return total, nil
+}
+
+// lookupStateEvents adds the state changes CloudTrail recorded for each
+// instance of a region to events
+func lookupStateEvents(ctx context.Context, profile, region string, instances []types.Instance, events map[string][]config.StateChange) error {
+	client, err := aws.NewCloudTrailClient(ctx, profile, region)
+	if err != nil {
+		return err
+	}
+	since := time.Now().Add(-aws.CloudTrailRetention)
+	for _, instance := range instances {
+		id := *instance.InstanceId
+		instanceEvents, err := client.InstanceStateEvents(ctx, id, since)
+		if err != nil {
+			return err
+		}
+		events[id] = config.StateChangesFromEvents(instanceEvents)
+	}
+	return nil
+}
@@ -0,0 +1,310 @@
+package cli
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/fakecloud"
+)
+
+// launchTagged launches an instance in region carrying the given lens
+// metadata. Options adjust the launch parameters.
+func launchTagged(t *testing.T, region string, metadata aws.InstanceMetadata, options ...func(*aws.LaunchParams)) string {
+	t.Helper()
+	ctx := context.Background()
+
+	ec2Client, err := aws.NewEC2ClientForProfileRegion(ctx, "default", region)
+	if err != nil {
+		t.Fatalf("NewEC2ClientForProfileRegion failed: %v", err)
+	}
+	subnet, err := ec2Client.GetSubnet(ctx, "public", "")
+	if err != nil {
+		t.Fatalf("GetSubnet failed: %v", err)
+	}
+	group, err := ec2Client.GetOrCreateSecurityGroup(ctx, aws.DefaultSecurityGroupStrategy(subnet.VpcID))
+	if err != nil {
+		t.Fatalf("GetOrCreateSecurityGroup failed: %v", err)
+	}
+	key, err := ec2Client.CreateKeyPair(ctx, "lens-rstudio-"+region)
+	if err != nil {
+		t.Fatalf("CreateKeyPair failed: %v", err)
+	}
+	ami, err := aws.NewAMISelector(region).GetAMI(ctx, ec2Client, "ubuntu24-arm64")
+	if err != nil {
+		t.Fatalf("GetAMI failed: %v", err)
+	}
+	params := aws.LaunchParams{
+		AMI:             ami,
+		InstanceType:    "t4g.medium",
+		SubnetID:        subnet.ID,
+		SecurityGroupID: group.ID,
+		KeyPairName:     key.Name,
+		EBSVolumeSize:   30,
+		Environment:     "data-science",
+		Metadata:        metadata,
+	}
+	for _, option := range options {
+		option(&params)
+	}
+	inst, err := ec2Client.LaunchInstance(ctx, params)
+	if err != nil {
+		t.Fatalf("LaunchInstance failed: %v", err)
+	}
+	return *inst.InstanceId
+}
+
+func TestLaunchInstance_WritesMetadataTags(t *testing.T) {
+	cloud := fakecloud.Install(t)
+	id := launchTagged(t, fakecloud.DefaultRegion, aws.InstanceMetadata{
+		App:         "lens-rstudio",
+		IdleTimeout: "2h0m0s",
+		AMIBase:     "ubuntu24-arm64",
+		S3Bucket:    "my-data",
+		Owner:       fakecloud.CallerARN,
+		Project:     "genomics",
+		ExpiresAt:   time.Date(2026, 11, 16, 12, 0, 0, 0, time.UTC),
+	})
+
+	instance, ok := cloud.Instance(id)
+	if !ok {
+		t.Fatalf("instance %s not found", id)
+	}
+	got := aws.ParseInstanceMetadata(instance.Tags)
+	want := aws.InstanceMetadata{
+		App:         "lens-rstudio",
+		Environment: "data-science",
+		IdleTimeout: "2h0m0s",
+		KeyPair:     "lens-rstudio-" + fakecloud.DefaultRegion,
+		AMIBase:     "ubuntu24-arm64",
+		S3Bucket:    "my-data",
+		EBSSize:     30,
+		Owner:       fakecloud.CallerARN,
+		Project:     "genomics",
+		ExpiresAt:   time.Date(2026, 11, 16, 12, 0, 0, 0, time.UTC),
+	}
+	if got != want {
+		t.Errorf("metadata = %+v, want %+v", got, want)
+	}
+
+	for _, tag := range instance.Tags {
+		if *tag.Key == aws.TagCreatedBy && *tag.Value != "lens-rstudio-cli" {
+			t.Errorf("CreatedBy = %q, want lens-rstudio-cli", *tag.Value)
+		}
+	}
+}
+
+func TestRunSync_ImportsRefreshesAndRemoves(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	cloud.SetRegions("us-east-1", "us-west-2")
+
+	tracked := launchTagged(t, "us-east-1", aws.InstanceMetadata{App: "lens-jupyter", IdleTimeout: "4h0m0s"})
+	untracked := launchTagged(t, "us-west-2", aws.InstanceMetadata{
+		App:      "lens-rstudio",
+		AMIBase:  "ubuntu24-arm64",
+		S3Bucket: "my-data",
+		Owner:    fakecloud.CallerARN,
+	})
+	if err := cloud.SetInstanceState(untracked, types.InstanceStateNameStopped); err != nil {
+		t.Fatalf("SetInstanceState failed: %v", err)
+	}
+
+	if err := config.EnsureConfigDir(); err != nil {
+		t.Fatalf("EnsureConfigDir failed: %v", err)
+	}
+	launchedAt := time.Now().Add(-time.Hour)
+	state := &config.LocalState{
+		Instances: map[string]*config.Instance{
+			tracked: {
+				ID:           tracked,
+				Environment:  "minimal",
+				InstanceType: "t4g.small",
+				Region:       "us-east-1",
+				LaunchedAt:   launchedAt,
+				StateChanges: []config.StateChange{{State: "running", Timestamp: launchedAt}},
+			},
+			"i-gone": {ID: "i-gone", Region: "us-east-1"},
+		},
+		KeyPairs: map[string]string{},
+	}
+	if err := state.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	result, err := RunSync(context.Background(), SyncOptions{Profile: "default"})
+	if err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if len(result.Imported) != 1 || len(result.Updated) != 1 || len(result.Removed) != 1 {
+		t.Fatalf("result = %+v, want 1 imported, 1 updated, 1 removed", result)
+	}
+
+	state, err = config.LoadState()
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if _, ok := state.Instances["i-gone"]; ok {
+		t.Error("instance that no longer exists was not removed")
+	}
+	if len(state.Terminated) != 1 || state.Terminated[0].ID != "i-gone" {
+		t.Errorf("instance that no longer exists was not kept as terminated: %+v", state.Terminated)
+	}
+
+	// Both instances and their volumes get the cost allocation tag
+	for _, region := range []string{"us-east-1", "us-west-2"} {
+		for _, volume := range cloud.Volumes(region) {
+			instanceID := *volume.Attachments[0].InstanceId
+			if got := tagValue(volume.Tags, aws.TagInstance); got != instanceID {
+				t.Errorf("volume %s has %s=%q, want %s", *volume.VolumeId, aws.TagInstance, got, instanceID)
+			}
+			instance, _ := cloud.Instance(instanceID)
+			if got := tagValue(instance.Tags, aws.TagInstance); got != instanceID {
+				t.Errorf("instance %s has %s=%q", instanceID, aws.TagInstance, got)
+			}
+		}
+	}
+
+	refreshed := state.Instances[tracked]
+	if refreshed.InstanceType != "t4g.medium" || refreshed.Environment != "data-science" || refreshed.App != "lens-jupyter" {
+		t.Errorf("tracked instance not refreshed from AWS: %+v", refreshed)
+	}
+	if len(refreshed.StateChanges) != 1 || !refreshed.LaunchedAt.Equal(launchedAt) {
+		t.Errorf("tracked instance history changed: %+v", refreshed.StateChanges)
+	}
+
+	imported := state.Instances[untracked]
+	if imported == nil {
+		t.Fatalf("instance %s was not imported", untracked)
+	}
+	if imported.Region != "us-west-2" || imported.App != "lens-rstudio" || imported.S3Bucket != "my-data" ||
+		imported.Owner != fakecloud.CallerARN || imported.EBSSize != 30 || imported.KeyPair != "lens-rstudio-us-west-2" {
+		t.Errorf("imported instance = %+v", imported)
+	}
+	if n := len(imported.StateChanges); n != 2 || imported.StateChanges[n-1].State != "stopped" {
+		t.Errorf("imported state changes = %+v, want running then stopped", imported.StateChanges)
+	}
+}
+
+func TestRunSync_KeepsEntriesInFailedRegion(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	cloud.SetRegions("us-east-1")
+
+	if err := config.EnsureConfigDir(); err != nil {
+		t.Fatalf("EnsureConfigDir failed: %v", err)
+	}
+	state := &config.LocalState{
+		Instances: map[string]*config.Instance{"i-kept": {ID: "i-kept", Region: "us-east-1"}},
+		KeyPairs:  map[string]string{},
+	}
+	if err := state.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	cloud.FailNext("DescribeInstances", fakecloud.APIError("UnauthorizedOperation", "not authorized"))
+	if _, err := RunSync(context.Background(), SyncOptions{Profile: "default"}); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+
+	state, err := config.LoadState()
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if _, ok := state.Instances["i-kept"]; !ok {
+		t.Error("entry in a region that could not be scanned was removed")
+	}
+}
+
+func TestRunSync_BackfillsHistoryFromCloudTrail(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	cloud.SetRegions("us-east-1")
+	launchedAt := time.Now().Add(-6 * time.Hour).UTC().Truncate(time.Second)
+	now := launchedAt
+	cloud.SetClock(func() time.Time { return now })
+
+	id := launchTagged(t, "us-east-1", aws.InstanceMetadata{App: "lens-jupyter"})
+	if err := cloud.SetInstanceState(id, types.InstanceStateNameRunning); err != nil {
+		t.Fatalf("SetInstanceState failed: %v", err)
+	}
+
+	if err := config.EnsureConfigDir(); err != nil {
+		t.Fatalf("EnsureConfigDir failed: %v", err)
+	}
+	state := &config.LocalState{
+		Instances: map[string]*config.Instance{
+			id: {
+				ID:           id,
+				Region:       "us-east-1",
+				LaunchedAt:   launchedAt,
+				StateChanges: []config.StateChange{{State: "running", Timestamp: launchedAt}},
+			},
+		},
+		KeyPairs: map[string]string{},
+	}
+	if err := state.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Stopped and started again outside lens, e.g. by the idle agent and the console
+	ctx := context.Background()
+	ec2Client, err := aws.NewEC2ClientForProfileRegion(ctx, "default", "us-east-1")
+	if err != nil {
+		t.Fatalf("NewEC2ClientForProfileRegion failed: %v", err)
+	}
+	stoppedAt := launchedAt.Add(2 * time.Hour)
+	now = stoppedAt
+	if err := ec2Client.StopInstance(ctx, id, false); err != nil {
+		t.Fatalf("StopInstance failed: %v", err)
+	}
+	if err := cloud.SetInstanceState(id, types.InstanceStateNameStopped); err != nil {
+		t.Fatalf("SetInstanceState failed: %v", err)
+	}
+	startedAt := launchedAt.Add(5 * time.Hour)
+	now = startedAt
+	if err := ec2Client.StartInstance(ctx, id); err != nil {
+		t.Fatalf("StartInstance failed: %v", err)
+	}
+	now = launchedAt.Add(6 * time.Hour)
+
+	result, err := RunSync(ctx, SyncOptions{Profile: "default", CloudTrail: true})
+	if err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if len(result.Backfilled) != 1 || result.Backfilled[0] != id {
+		t.Fatalf("result = %+v, want %s backfilled", result, id)
+	}
+
+	state, err = config.LoadState()
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	want := []config.StateChange{
+		{State: "running", Timestamp: launchedAt},
+		{State: "stopped", Timestamp: stoppedAt, Source: config.StateSourceCloudTrail},
+		{State: "running", Timestamp: startedAt, Source: config.StateSourceCloudTrail},
+	}
+	got := state.Instances[id].StateChanges
+	if len(got) != len(want) {
+		t.Fatalf("state changes = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].State != want[i].State || !got[i].Timestamp.Equal(want[i].Timestamp) || got[i].Source != want[i].Source || got[i].Inferred {
+			t.Errorf("change %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// tagValue returns the value of a tag, or "" if it is not set
+func tagValue(tags []types.Tag, key string) string {
+	for _, tag := range tags {
+		if *tag.Key == key {
+			return *tag.Value
+		}
+	}
+	return ""
+}
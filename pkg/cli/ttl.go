@@ -0,0 +1,471 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/hooks"
+	"github.com/spf13/cobra"
+)
+
+// ttlSnapshotTimeout is how long reap waits for the snapshots of an expired
+// instance to complete before terminating it
+var ttlSnapshotTimeout = time.Hour
+
+// Actions reap takes on an instance whose TTL ran out, as reported to the
+// ttl_expired hook
+const (
+	ttlActionStopped     = "stopped"
+	ttlActionUnscheduled = "stopped and unscheduled"
+	ttlActionTerminated  = "terminated"
+	ttlActionSnapshotted = "snapshotted and terminated"
+)
+
+// ParseTTL parses a maximum lifetime such as "30d", "12h" or "90m". An empty
+// TTL is none and parses as 0.
+func ParseTTL(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	ttl, err := parseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid TTL %q (use e.g. 30d, 12h or 90m)", s)
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("invalid TTL %q: must be positive", s)
+	}
+	return ttl, nil
+}
+
+// ExpiresAfter returns when a TTL starting now runs out, to the second, or
+// the zero time without a TTL
+func ExpiresAfter(ttl time.Duration) time.Time {
+	if ttl == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl).UTC().Truncate(time.Second)
+}
+
+// PrintExpiry prints when the instance's TTL runs out, if it has one
+func PrintExpiry(instance *config.Instance) {
+	if instance.ExpiresAt == nil {
+		return
+	}
+	fmt.Printf("Expires:         %s\n", describeExpiry(*instance.ExpiresAt, time.Now()))
+}
+
+// describeExpiry says when a TTL runs out
+func describeExpiry(expiresAt, now time.Time) string {
+	at := expiresAt.Local().Format("Jan 2 15:04")
+	if !expiresAt.After(now) {
+		return fmt.Sprintf("%s (expired %s ago)", at, roundDays(now.Sub(expiresAt)))
+	}
+	return fmt.Sprintf("%s (in %s)", at, roundDays(expiresAt.Sub(now)))
+}
+
+// roundDays formats a duration of a day or more in days and hours, and a
+// shorter one to the minute
+func roundDays(d time.Duration) string {
+	if d.Round(time.Minute) < 24*time.Hour {
+		return roundDuration(d)
+	}
+	d = d.Round(time.Hour)
+	return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+}
+
+// ExtendOptions holds the options of the extend command
+type ExtendOptions struct {
+	Profile string
+	TTL     string
+}
+
+// NewExtendCmd creates the extend command for postponing the end of an
+// instance's TTL
+func NewExtendCmd(appName string) *cobra.Command {
+	var opts ExtendOptions
+
+	cmd := &cobra.Command{
+		Use:   "extend [INSTANCE]",
+		Short: "Extend the TTL of an instance",
+		Long: `Extend the maximum lifetime of an instance set with launch --ttl, so that reap
+acts on it later.
+
+The TTL is added to the current expiry, or to now if the instance has already
+expired or has no TTL. The ttl_warning hook fires again before the new expiry.`,
+		Example: fmt.Sprintf(`  %[1]s extend my-analysis --ttl 7d`, appName),
+		Args:    cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			instanceRef := ""
+			if len(args) > 0 {
+				instanceRef = args[0]
+			}
+			_, err := RunExtend(context.Background(), instanceRef, opts)
+			return err
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Profile, "profile", "p", "default", "AWS profile to use")
+	cmd.Flags().StringVar(&opts.TTL, "ttl", "", "How much longer the instance may live, e.g. 7d")
+	_ = cmd.MarkFlagRequired("ttl")
+
+	return cmd
+}
+
+// RunExtend extends the TTL of the instance ref refers to and returns when
+// it now runs out
+func RunExtend(ctx context.Context, instanceRef string, opts ExtendOptions) (time.Time, error) {
+	ttl, err := ParseTTL(opts.TTL)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if ttl == 0 {
+		return time.Time{}, fmt.Errorf("--ttl is required")
+	}
+
+	state, err := config.LoadState()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to load state: %w", err)
+	}
+	instance, err := ResolveInstance(state, instanceRef)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	ec2Client, err := aws.NewEC2ClientForProfileRegion(ctx, opts.Profile, instance.Region)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to create AWS client: %w", err)
+	}
+	awsInstance, err := ec2Client.GetInstanceInfo(ctx, instance.ID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get instance info: %w", err)
+	}
+	if isGone(awsInstance.State) {
+		return time.Time{}, fmt.Errorf("instance %s is %s", instance.ID, awsInstance.State.Name)
+	}
+
+	now := time.Now()
+	from := now
+	if current := instanceExpiry(instance, *awsInstance); current.After(now) {
+		from = current
+	}
+	expiresAt := from.Add(ttl).UTC().Truncate(time.Second)
+	if err := ec2Client.SetExpiresAtTag(ctx, instance.ID, expiresAt); err != nil {
+		return time.Time{}, fmt.Errorf("failed to tag instance: %w", err)
+	}
+	if err := config.UpdateInstance(instance.ID, func(instance *config.Instance) error {
+		instance.ExpiresAt = &expiresAt
+		instance.TTLWarning = nil
+		return nil
+	}); err != nil {
+		return time.Time{}, fmt.Errorf("failed to update state: %w", err)
+	}
+
+	fmt.Printf("✓ %s now expires %s\n", instance.DisplayName(), describeExpiry(expiresAt, now))
+	return expiresAt, nil
+}
+
+// ReapOptions holds the options of the reap command
+type ReapOptions struct {
+	Profile string
+	DryRun  bool
+}
+
+// NewReapCmd creates the reap command for enforcing instance TTLs
+func NewReapCmd(appName string) *cobra.Command {
+	var opts ReapOptions
+
+	cmd := &cobra.Command{
+		Use:   "reap",
+		Short: "Act on instances whose TTL ran out",
+		Long: `Act on instances launched with --ttl (or default_ttl) whose maximum lifetime
+ran out, and warn about those that are about to.
+
+The ttl_warning hook runs once per expiry for instances that expire within
+ttl_warning (default 48h), with AWS_IDE_EXPIRES_AT set. Once an instance has
+expired:
+
+  auto_terminate: false  it is stopped (the default), and its schedule, which
+                         would start it again, is deleted
+  auto_terminate: true   it is terminated, or with ttl_policy: snapshot its
+                         volumes are backed up first, as with 'backup create'
+
+The ttl_expired hook runs for every instance acted on, with AWS_IDE_ACTION set.
+Use 'extend' to give an instance more time. Run reap from cron to enforce TTLs.`,
+		Example: fmt.Sprintf(`  %[1]s reap --dry-run
+
+  # Enforce TTLs every hour
+  0 * * * * %[1]s reap`, appName),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunReap(context.Background(), opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Profile, "profile", "p", "default", "AWS profile to use")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Show what would be done without doing it or running hooks")
+
+	return cmd
+}
+
+// RunReap warns about instances whose TTL is about to run out and stops,
+// terminates or snapshots then terminates those whose TTL ran out
+func RunReap(ctx context.Context, opts ReapOptions) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	window, err := parseDuration(cfg.TTLWarning)
+	if err != nil {
+		return fmt.Errorf("invalid ttl_warning: %w", err)
+	}
+	if cfg.TTLPolicy != config.TTLPolicyTerminate && cfg.TTLPolicy != config.TTLPolicySnapshot {
+		return fmt.Errorf("invalid ttl_policy %q (must be %q or %q)", cfg.TTLPolicy, config.TTLPolicyTerminate, config.TTLPolicySnapshot)
+	}
+
+	state, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	var instances []*config.Instance
+	for _, instance := range state.Instances {
+		if instance.ExpiresAt != nil {
+			instances = append(instances, instance)
+		}
+	}
+	if len(instances) == 0 {
+		fmt.Println("No instances with a TTL")
+		return nil
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].ExpiresAt.Before(*instances[j].ExpiresAt)
+	})
+
+	now := time.Now()
+	ec2Clients := map[string]*aws.EC2Client{}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INSTANCE\tSTATE\tEXPIRES\tACTION")
+	failed := 0
+	for _, instance := range instances {
+		ec2Client, ok := ec2Clients[instance.Region]
+		if !ok {
+			if ec2Client, err = aws.NewEC2ClientForProfileRegion(ctx, opts.Profile, instance.Region); err != nil {
+				return fmt.Errorf("failed to create AWS client: %w", err)
+			}
+			ec2Clients[instance.Region] = ec2Client
+		}
+		awsInstance, err := ec2Client.GetInstanceInfo(ctx, instance.ID)
+		if err != nil {
+			fmt.Fprintf(w, "%s\tunknown\t%s\t%v\n", instance.DisplayName(), describeExpiry(*instance.ExpiresAt, now), err)
+			continue
+		}
+		if isGone(awsInstance.State) {
+			continue
+		}
+
+		expiresAt := instanceExpiry(instance, *awsInstance)
+		action := "-"
+		switch {
+		case expiresAt.After(now.Add(window)):
+		case expiresAt.After(now):
+			if opts.DryRun {
+				break
+			}
+			if fired, err := fireTTLWarning(instance, expiresAt, now); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			} else if fired {
+				action = "ttl_warning sent"
+			}
+		default:
+			planned := ttlAction(cfg.AutoTerminate, cfg.TTLPolicy, awsInstance.State.Name)
+			switch {
+			case planned == "":
+				action = "none (stopped; set auto_terminate to terminate)"
+			case opts.DryRun:
+				action = "would be " + planned
+			default:
+				taken, err := reapInstance(ctx, ec2Client, instance, *awsInstance, planned)
+				if err != nil {
+					failed++
+					action = fmt.Sprintf("failed: %v", err)
+					break
+				}
+				action = taken
+				_ = hooks.ExecuteHook(hooks.EventData{
+					EventType:    hooks.EventTTLExpired,
+					InstanceID:   instance.ID,
+					InstanceType: instance.InstanceType,
+					Environment:  instance.Environment,
+					Region:       instance.Region,
+					Timestamp:    time.Now(),
+					AppName:      strings.TrimPrefix(instance.App, "lens-"),
+					ExpiresAt:    expiresAt,
+					Action:       taken,
+				})
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", instance.DisplayName(), awsInstance.State.Name, describeExpiry(expiresAt, now), action)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to reap %d instance(s)", failed)
+	}
+	return nil
+}
+
+// instanceExpiry returns when an instance's TTL runs out. The tag wins over
+// local state, as extend may have been run elsewhere.
+func instanceExpiry(instance *config.Instance, awsInstance ec2types.Instance) time.Time {
+	if expiresAt := aws.ParseInstanceMetadata(awsInstance.Tags).ExpiresAt; !expiresAt.IsZero() {
+		return expiresAt
+	}
+	if instance.ExpiresAt != nil {
+		return *instance.ExpiresAt
+	}
+	return time.Time{}
+}
+
+// isGone reports whether an instance is terminated or being terminated
+func isGone(state *ec2types.InstanceState) bool {
+	return state != nil && (state.Name == ec2types.InstanceStateNameTerminated || state.Name == ec2types.InstanceStateNameShuttingDown)
+}
+
+// ttlAction returns what reap does with an expired instance in a state, or
+// "" if there is nothing to do
+func ttlAction(autoTerminate bool, policy string, state ec2types.InstanceStateName) string {
+	switch {
+	case autoTerminate && policy == config.TTLPolicySnapshot:
+		return ttlActionSnapshotted
+	case autoTerminate:
+		return ttlActionTerminated
+	case state == ec2types.InstanceStateNameRunning || state == ec2types.InstanceStateNamePending:
+		return ttlActionStopped
+	}
+	return ""
+}
+
+// fireTTLWarning runs the ttl_warning hook once for each expiry
+func fireTTLWarning(instance *config.Instance, expiresAt, now time.Time) (bool, error) {
+	if instance.TTLWarning != nil && instance.TTLWarning.Equal(expiresAt) {
+		return false, nil
+	}
+	_ = hooks.ExecuteHook(hooks.EventData{
+		EventType:    hooks.EventTTLWarning,
+		InstanceID:   instance.ID,
+		InstanceType: instance.InstanceType,
+		Environment:  instance.Environment,
+		Region:       instance.Region,
+		Timestamp:    now,
+		AppName:      strings.TrimPrefix(instance.App, "lens-"),
+		ExpiresAt:    expiresAt,
+	})
+	if err := config.UpdateInstance(instance.ID, func(instance *config.Instance) error {
+		instance.TTLWarning = &expiresAt
+		return nil
+	}); err != nil {
+		return true, fmt.Errorf("failed to update state: %w", err)
+	}
+	return true, nil
+}
+
+// reapInstance takes an action of ttlAction on an expired instance and
+// returns the action taken. A stopped instance also loses its schedule, which
+// would start it again.
+func reapInstance(ctx context.Context, ec2Client *aws.EC2Client, instance *config.Instance, awsInstance ec2types.Instance, action string) (string, error) {
+	if action == ttlActionStopped {
+		if err := ec2Client.StopInstance(ctx, instance.ID, false); err != nil {
+			return "", err
+		}
+		if instance.Schedule != nil {
+			if err := DeleteSchedules(ctx, instance); err != nil {
+				return "", fmt.Errorf("stopped, but failed to delete its schedule: %w", err)
+			}
+			if err := clearSchedule(instance.ID); err != nil {
+				return "", err
+			}
+			action = ttlActionUnscheduled
+		}
+		return action, config.UpdateInstance(instance.ID, func(instance *config.Instance) error {
+			instance.RecordStateChange("stopped")
+			return nil
+		})
+	}
+
+	if action == ttlActionSnapshotted {
+		if err := snapshotExpired(ctx, ec2Client, instance, awsInstance); err != nil {
+			return "", err
+		}
+	}
+	if err := ec2Client.TerminateInstance(ctx, instance.ID); err != nil {
+		return "", fmt.Errorf("failed to terminate instance: %w", err)
+	}
+	if err := DeleteIdleAlarm(ctx, instance); err != nil {
+		fmt.Printf("Warning: Failed to delete idle alarm of %s: %v\n", instance.ID, err)
+	}
+	if err := DeleteSchedules(ctx, instance); err != nil {
+		fmt.Printf("Warning: Failed to delete schedules of %s: %v\n", instance.ID, err)
+	}
+	return action, config.UpdateState(func(state *config.LocalState) error {
+		state.RecordTermination(instance.ID, time.Now())
+		return nil
+	})
+}
+
+// snapshotExpired stops an expired instance for a consistent copy, backs up
+// its root and data volumes and waits for the backups to complete, so that
+// terminating it does not lose anything
+func snapshotExpired(ctx context.Context, ec2Client *aws.EC2Client, instance *config.Instance, awsInstance ec2types.Instance) error {
+	if awsInstance.State.Name != ec2types.InstanceStateNameStopped {
+		if err := ec2Client.StopInstance(ctx, instance.ID, false); err != nil {
+			return fmt.Errorf("failed to stop instance: %w", err)
+		}
+		if err := ec2Client.WaitForInstanceStopped(ctx, instance.ID); err != nil {
+			return fmt.Errorf("failed to stop instance: %w", err)
+		}
+	}
+
+	name := instance.ID
+	if instance.Name != "" {
+		name = instance.Name
+	}
+	root, err := ec2Client.RootVolume(ctx, awsInstance)
+	if err != nil {
+		return err
+	}
+	sources := []aws.BackupSource{{Kind: aws.BackupRoot, VolumeID: *root.VolumeId, Name: name, SourceInstance: instance.ID, App: instance.App, Owner: instance.Owner}}
+	if instance.DataVolume != "" {
+		volume, err := ec2Client.AttachedDataVolume(ctx, instance.ID)
+		if err != nil {
+			return err
+		}
+		if volume != nil {
+			sources = append(sources, aws.BackupSource{Kind: aws.BackupData, VolumeID: volume.ID, Name: volume.Name,
+				SourceInstance: instance.ID, DataVolume: volume.Name, App: instance.App, Owner: instance.Owner})
+		}
+	}
+
+	var snapshotIDs []string
+	for _, source := range sources {
+		description := fmt.Sprintf("lens backup of the %s volume of %s taken when its TTL ran out %s",
+			source.Kind, instance.DisplayName(), time.Now().UTC().Format(time.RFC3339))
+		snapshotID, err := ec2Client.CreateBackup(ctx, source, description)
+		if err != nil {
+			return err
+		}
+		snapshotIDs = append(snapshotIDs, snapshotID)
+	}
+	if err := ec2Client.WaitForBackups(ctx, snapshotIDs, ttlSnapshotTimeout); err != nil {
+		return fmt.Errorf("backups %s did not complete, not terminating: %w", strings.Join(snapshotIDs, ", "), err)
+	}
+	fmt.Printf("Backed up %s: %s\n", instance.DisplayName(), strings.Join(snapshotIDs, ", "))
+	return nil
+}
@@ -0,0 +1,236 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/fakecloud"
+)
+
+func TestParseTTL(t *testing.T) {
+	for input, want := range map[string]time.Duration{
+		"":    0,
+		"30d": 30 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+		"90m": 90 * time.Minute,
+	} {
+		if got, err := ParseTTL(input); err != nil || got != want {
+			t.Errorf("ParseTTL(%q) = %s, %v; want %s", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"soon", "-1d", "0h"} {
+		if _, err := ParseTTL(input); err == nil {
+			t.Errorf("ParseTTL(%q): expected an error", input)
+		}
+	}
+}
+
+// setExpiry sets when a tracked instance's TTL runs out in local state
+func setExpiry(t *testing.T, id string, expiresAt time.Time) {
+	t.Helper()
+	if err := config.UpdateInstance(id, func(instance *config.Instance) error {
+		instance.ExpiresAt = &expiresAt
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateInstance failed: %v", err)
+	}
+}
+
+func TestRunReap_WarnsOnceThenStops(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cloud := fakecloud.Install(t)
+	id := trackManaged(t, cloud)
+	ctx := context.Background()
+
+	hookLog := filepath.Join(home, "hook.log")
+	if err := config.SaveUserConfig(&config.UserConfig{Hooks: &config.HooksConfig{
+		OnTTLWarning: `echo "warning $AWS_IDE_INSTANCE_ID $AWS_IDE_EXPIRES_AT" >> ` + hookLog,
+		OnTTLExpired: `echo "expired $AWS_IDE_INSTANCE_ID $AWS_IDE_ACTION" >> ` + hookLog,
+	}}); err != nil {
+		t.Fatalf("SaveUserConfig failed: %v", err)
+	}
+
+	// Three days out is outside the default 48h warning window
+	setExpiry(t, id, time.Now().Add(72*time.Hour))
+	if err := RunReap(ctx, ReapOptions{Profile: "default"}); err != nil {
+		t.Fatalf("RunReap failed: %v", err)
+	}
+	if _, err := os.Stat(hookLog); err == nil {
+		t.Fatal("ttl_warning fired three days before the expiry")
+	}
+
+	expiresAt := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	setExpiry(t, id, expiresAt)
+	for i := 0; i < 2; i++ {
+		if err := RunReap(ctx, ReapOptions{Profile: "default"}); err != nil {
+			t.Fatalf("RunReap failed: %v", err)
+		}
+	}
+	if instance, _ := cloud.Instance(id); instance.State.Name != types.InstanceStateNameRunning {
+		t.Fatalf("expected an instance that has not expired to keep running, got %s", instance.State.Name)
+	}
+
+	// Without auto_terminate an expired instance is only stopped
+	setExpiry(t, id, time.Now().Add(-time.Hour))
+	if err := RunReap(ctx, ReapOptions{Profile: "default", DryRun: true}); err != nil {
+		t.Fatalf("RunReap failed: %v", err)
+	}
+	if instance, _ := cloud.Instance(id); stoppedOrStopping(instance) {
+		t.Fatal("expected a dry run not to stop the instance")
+	}
+	if err := RunReap(ctx, ReapOptions{Profile: "default"}); err != nil {
+		t.Fatalf("RunReap failed: %v", err)
+	}
+	if instance, _ := cloud.Instance(id); !stoppedOrStopping(instance) {
+		t.Errorf("expected the expired instance to be stopped, got %s", instance.State.Name)
+	}
+
+	log, err := os.ReadFile(hookLog)
+	if err != nil {
+		t.Fatalf("expected the TTL hooks to run: %v", err)
+	}
+	want := "warning " + id + " " + expiresAt.Format(time.RFC3339) + "\nexpired " + id + " stopped"
+	if got := strings.TrimSpace(string(log)); got != want {
+		t.Errorf("expected hooks %q, got %q", want, got)
+	}
+}
+
+func TestRunReap_StopDeletesSchedule(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cloud := fakecloud.Install(t)
+	id := trackManaged(t, cloud)
+	ctx := context.Background()
+
+	hookLog := filepath.Join(home, "hook.log")
+	if err := config.SaveUserConfig(&config.UserConfig{Hooks: &config.HooksConfig{
+		OnTTLExpired: `echo "$AWS_IDE_ACTION" >> ` + hookLog,
+	}}); err != nil {
+		t.Fatalf("SaveUserConfig failed: %v", err)
+	}
+	if _, err := RunScheduleSet(ctx, "lens-jupyter", id, ScheduleOptions{Profile: "default", Start: "0 8 * * 1-5", Stop: "0 18 * * 1-5", Timezone: "UTC"}); err != nil {
+		t.Fatalf("RunScheduleSet failed: %v", err)
+	}
+	setExpiry(t, id, time.Now().Add(-time.Hour))
+
+	if err := RunReap(ctx, ReapOptions{Profile: "default"}); err != nil {
+		t.Fatalf("RunReap failed: %v", err)
+	}
+	if instance, _ := cloud.Instance(id); !stoppedOrStopping(instance) {
+		t.Fatalf("expected the expired instance to be stopped, got %s", instance.State.Name)
+	}
+	for _, action := range []string{aws.ScheduleActionStart, aws.ScheduleActionStop} {
+		if _, ok := cloud.Schedule(fakecloud.DefaultRegion, aws.ScheduleGroup, aws.InstanceScheduleName(id, action)); ok {
+			t.Errorf("expected the %s schedule to be deleted so it cannot start the expired instance", action)
+		}
+	}
+	state, _ := config.LoadState()
+	if state.Instances[id].Schedule != nil {
+		t.Error("expected the schedule to be removed from state")
+	}
+	if log, _ := os.ReadFile(hookLog); strings.TrimSpace(string(log)) != ttlActionUnscheduled {
+		t.Errorf("expected ttl_expired with action %q, got %q", ttlActionUnscheduled, log)
+	}
+}
+
+func TestRunReap_SnapshotsThenTerminates(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	id := trackManaged(t, cloud)
+	ctx := context.Background()
+
+	if err := config.SaveUserConfig(&config.UserConfig{AutoTerminate: true, TTLPolicy: config.TTLPolicySnapshot}); err != nil {
+		t.Fatalf("SaveUserConfig failed: %v", err)
+	}
+	setExpiry(t, id, time.Now().Add(-time.Minute))
+
+	if err := RunReap(ctx, ReapOptions{Profile: "default"}); err != nil {
+		t.Fatalf("RunReap failed: %v", err)
+	}
+	if instance, _ := cloud.Instance(id); instance.State.Name != types.InstanceStateNameShuttingDown && instance.State.Name != types.InstanceStateNameTerminated {
+		t.Errorf("expected the expired instance to be terminated, got %s", instance.State.Name)
+	}
+
+	ec2Client, err := aws.NewEC2ClientForRegion(ctx, fakecloud.DefaultRegion)
+	if err != nil {
+		t.Fatalf("NewEC2ClientForRegion failed: %v", err)
+	}
+	backups, err := ec2Client.ListBackups(ctx, "")
+	if err != nil {
+		t.Fatalf("ListBackups failed: %v", err)
+	}
+	if len(backups) != 1 || backups[0].Kind != aws.BackupRoot || backups[0].SourceInstance != id || !backups[0].Completed() {
+		t.Errorf("expected a completed backup of the root volume, got %+v", backups)
+	}
+
+	state, _ := config.LoadState()
+	if _, ok := state.Instances[id]; ok {
+		t.Error("expected the instance to be removed from state")
+	}
+}
+
+func TestRunExtend(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	id := trackManaged(t, cloud)
+	ctx := context.Background()
+
+	expiresAt := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	setExpiry(t, id, expiresAt)
+	if err := config.UpdateInstance(id, func(instance *config.Instance) error {
+		instance.TTLWarning = &expiresAt
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateInstance failed: %v", err)
+	}
+
+	extended, err := RunExtend(ctx, id, ExtendOptions{Profile: "default", TTL: "7d"})
+	if err != nil {
+		t.Fatalf("RunExtend failed: %v", err)
+	}
+	if want := expiresAt.Add(7 * 24 * time.Hour); !extended.Equal(want) {
+		t.Errorf("expected the TTL to be extended from the current expiry to %s, got %s", want, extended)
+	}
+	instance, _ := cloud.Instance(id)
+	if tagged := aws.ParseInstanceMetadata(instance.Tags).ExpiresAt; !tagged.Equal(extended) {
+		t.Errorf("expected the instance to be tagged with the new expiry, got %s", tagged)
+	}
+	state, _ := config.LoadState()
+	if got := state.Instances[id]; got.ExpiresAt == nil || !got.ExpiresAt.Equal(extended) || got.TTLWarning != nil {
+		t.Errorf("expected the new expiry in state and the warning reset, got %v %v", got.ExpiresAt, got.TTLWarning)
+	}
+
+	// An expired instance gets the TTL from now
+	setExpiry(t, id, time.Now().Add(-48*time.Hour))
+	if err := ec2TagExpiry(ctx, id, time.Now().Add(-48*time.Hour)); err != nil {
+		t.Fatalf("SetExpiresAtTag failed: %v", err)
+	}
+	extended, err = RunExtend(ctx, id, ExtendOptions{Profile: "default", TTL: "1d"})
+	if err != nil {
+		t.Fatalf("RunExtend failed: %v", err)
+	}
+	if remaining := time.Until(extended); remaining < 23*time.Hour || remaining > 25*time.Hour {
+		t.Errorf("expected the TTL to run out a day from now, got %s", extended)
+	}
+
+	if _, err := RunExtend(ctx, id, ExtendOptions{Profile: "default"}); err == nil {
+		t.Error("expected an error without --ttl")
+	}
+}
+
+// ec2TagExpiry sets the expiry tag of an instance in the default region
+func ec2TagExpiry(ctx context.Context, id string, expiresAt time.Time) error {
+	ec2Client, err := aws.NewEC2ClientForRegion(ctx, fakecloud.DefaultRegion)
+	if err != nil {
+		return err
+	}
+	return ec2Client.SetExpiresAtTag(ctx, id, expiresAt)
+}
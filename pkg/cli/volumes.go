@@ -0,0 +1,298 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/cost"
+	"github.com/spf13/cobra"
+)
+
+// DataVolumeForLaunch looks up the data volume named in spec before a launch
+// and returns it with the availability zone the instance must be launched in
+// to attach it. Only the caller's volumes are considered unless spec.Shared
+// is set. A volume that does not exist yet is returned as nil with the
+// requested zone; the launch creates it once the zone is chosen.
+func DataVolumeForLaunch(ctx context.Context, ec2Client *aws.EC2Client, profile string, spec aws.DataVolumeSpec, availabilityZone string) (*aws.DataVolume, string, error) {
+	owner := ""
+	if !spec.Shared {
+		var err error
+		if owner, err = callerARN(ctx, profile); err != nil {
+			return nil, "", fmt.Errorf("failed to determine your identity to find your data volume (use --shared-data-volume for anyone's): %w", err)
+		}
+	}
+	volume, err := ec2Client.FindDataVolume(ctx, spec.Name, owner)
+	if err != nil {
+		return nil, "", err
+	}
+	if volume == nil && owner != "" {
+		// Rather than create a second volume of the same name, say whose it is
+		other, err := ec2Client.FindDataVolume(ctx, spec.Name, "")
+		if err != nil {
+			return nil, "", err
+		}
+		if other != nil {
+			whose := "has no owner"
+			if other.Owner != "" {
+				whose = "belongs to " + other.Owner
+			}
+			return nil, "", fmt.Errorf("data volume %q in %s %s; use --shared-data-volume to attach it anyway", spec.Name, ec2Client.GetRegion(), whose)
+		}
+	}
+	if volume == nil {
+		if spec.SizeGB == 0 {
+			return nil, "", fmt.Errorf("data volume %q does not exist in %s: give its size to create it, e.g. --data-volume %s:100",
+				spec.Name, ec2Client.GetRegion(), spec.Name)
+		}
+		return nil, availabilityZone, nil
+	}
+
+	if volume.Attached() {
+		return nil, "", fmt.Errorf("data volume %q is attached to instance %s; terminate it first", spec.Name, volume.InstanceID)
+	}
+	if availabilityZone != "" && availabilityZone != volume.AvailabilityZone {
+		return nil, "", fmt.Errorf("data volume %q is in %s and cannot be attached to an instance in %s", spec.Name, volume.AvailabilityZone, availabilityZone)
+	}
+	if spec.SizeGB != 0 && spec.SizeGB != volume.SizeGB {
+		fmt.Printf("Note: Data volume %s already exists with %d GB; the requested %d GB is ignored\n", spec.Name, volume.SizeGB, spec.SizeGB)
+	}
+	return volume, volume.AvailabilityZone, nil
+}
+
+// VolumesOptions selects the region of the volumes commands
+type VolumesOptions struct {
+	Profile string
+	Region  string // Default: the profile's region
+	Shared  bool   // Find volumes by name whoever owns them, not only the caller's
+}
+
+// NewVolumesCmd creates the volumes command for managing persistent data volumes
+func NewVolumesCmd(appName string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "volumes",
+		Short: "Manage persistent data volumes",
+		Long: `Manage persistent data volumes.
+
+launch --data-volume NAME:SIZE creates a named EBS volume the first time and
+mounts it at ` + aws.DataVolumeMountPath + `. The volume is kept when the
+instance is terminated, and launching again with --data-volume NAME attaches it
+to the new instance, which is placed in the volume's availability zone.
+
+Volumes cost $` + fmt.Sprintf("%.2f", cost.EBSPricePerGBMonth) + ` per GB-month whether or not they are attached.`,
+		Example: fmt.Sprintf(`  # Create a 100 GB volume on first launch, reattach it on later launches
+  %[1]s launch --data-volume thesis-data:100
+  %[1]s launch --data-volume thesis-data
+
+  %[1]s volumes list
+  %[1]s volumes snapshot thesis-data
+  %[1]s volumes delete thesis-data`, appName),
+	}
+
+	cmd.AddCommand(newVolumesListCmd())
+	cmd.AddCommand(newVolumesDeleteCmd())
+	cmd.AddCommand(newVolumesSnapshotCmd())
+
+	return cmd
+}
+
+// addVolumesFlags adds the flags selecting the region of a volumes command
+func addVolumesFlags(cmd *cobra.Command, opts *VolumesOptions) {
+	cmd.Flags().StringVarP(&opts.Profile, "profile", "p", "default", "AWS profile to use")
+	cmd.Flags().StringVarP(&opts.Region, "region", "r", "", "AWS region (default: the profile's region)")
+}
+
+func newVolumesListCmd() *cobra.Command {
+	var opts VolumesOptions
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List data volumes and the instances they are attached to",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunVolumesList(context.Background(), opts)
+		},
+	}
+	addVolumesFlags(cmd, &opts)
+	return cmd
+}
+
+func newVolumesDeleteCmd() *cobra.Command {
+	var opts VolumesOptions
+	var force bool
+	cmd := &cobra.Command{
+		Use:   "delete NAME",
+		Short: "Delete a detached data volume and all data on it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunVolumesDelete(context.Background(), opts, args[0], force)
+		},
+	}
+	addVolumesFlags(cmd, &opts)
+	cmd.Flags().BoolVar(&opts.Shared, "shared", false, "Find the volume by name whoever owns it, not only yours")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Delete without confirmation")
+	return cmd
+}
+
+func newVolumesSnapshotCmd() *cobra.Command {
+	var opts VolumesOptions
+	cmd := &cobra.Command{
+		Use:   "snapshot NAME",
+		Short: "Snapshot a data volume",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := RunVolumesSnapshot(context.Background(), opts, args[0])
+			return err
+		},
+	}
+	addVolumesFlags(cmd, &opts)
+	cmd.Flags().BoolVar(&opts.Shared, "shared", false, "Find the volume by name whoever owns it, not only yours")
+	return cmd
+}
+
+// volumesClient returns an EC2 client for the region of the volumes commands
+func volumesClient(ctx context.Context, opts VolumesOptions) (*aws.EC2Client, error) {
+	var ec2Client *aws.EC2Client
+	var err error
+	if opts.Region != "" {
+		ec2Client, err = aws.NewEC2ClientForProfileRegion(ctx, opts.Profile, opts.Region)
+	} else {
+		ec2Client, err = aws.NewEC2Client(ctx, opts.Profile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS client: %w", err)
+	}
+	return ec2Client, nil
+}
+
+// findDataVolume returns the data volume with the ID ref, or the caller's
+// named ref, or anyone's with opts.Shared
+func findDataVolume(ctx context.Context, ec2Client *aws.EC2Client, opts VolumesOptions, ref string) (*aws.DataVolume, error) {
+	if strings.HasPrefix(ref, "vol-") {
+		volumes, err := ec2Client.ListDataVolumes(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for i := range volumes {
+			if volumes[i].ID == ref {
+				return &volumes[i], nil
+			}
+		}
+	} else {
+		owner := ""
+		if !opts.Shared {
+			var err error
+			if owner, err = callerARN(ctx, opts.Profile); err != nil {
+				return nil, fmt.Errorf("failed to determine your identity to find your data volume (use --shared for anyone's): %w", err)
+			}
+		}
+		volume, err := ec2Client.FindDataVolume(ctx, ref, owner)
+		if err != nil || volume != nil {
+			return volume, err
+		}
+	}
+	return nil, fmt.Errorf("data volume %s not found in %s", ref, ec2Client.GetRegion())
+}
+
+// RunVolumesList prints the data volumes of the region with their monthly cost
+func RunVolumesList(ctx context.Context, opts VolumesOptions) error {
+	ec2Client, err := volumesClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+	volumes, err := ec2Client.ListDataVolumes(ctx)
+	if err != nil {
+		return err
+	}
+	if len(volumes) == 0 {
+		fmt.Printf("No data volumes in %s\n", ec2Client.GetRegion())
+		return nil
+	}
+
+	state, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVOLUME ID\tSIZE\tZONE\tATTACHED TO\tCREATED\tMONTHLY")
+	var total float64
+	for _, volume := range volumes {
+		attached := "-"
+		if volume.Attached() {
+			attached = volume.InstanceID
+			if instance, ok := state.Instances[volume.InstanceID]; ok {
+				attached = instance.DisplayName()
+			}
+		}
+		monthly := float64(volume.SizeGB) * cost.EBSPricePerGBMonth
+		total += monthly
+		fmt.Fprintf(w, "%s\t%s\t%d GB\t%s\t%s\t%s\t%s\n",
+			volume.Name, volume.ID, volume.SizeGB, volume.AvailabilityZone, attached,
+			volume.CreatedAt.Local().Format("2006-01-02"), cost.FormatCostShort(monthly))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\nTotal: %s/month\n", cost.FormatCostShort(total))
+	return nil
+}
+
+// RunVolumesDelete deletes a detached data volume after confirmation
+func RunVolumesDelete(ctx context.Context, opts VolumesOptions, ref string, force bool) error {
+	ec2Client, err := volumesClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+	volume, err := findDataVolume(ctx, ec2Client, opts, ref)
+	if err != nil {
+		return err
+	}
+	if volume.Attached() {
+		return fmt.Errorf("data volume %s is attached to instance %s; terminate the instance first", volume.Name, volume.InstanceID)
+	}
+
+	if !force {
+		fmt.Printf("WARNING: This will permanently delete data volume %s (%s, %d GB) and all data on it\n", volume.Name, volume.ID, volume.SizeGB)
+		fmt.Printf("Take a snapshot first with 'volumes snapshot %s' to keep a copy. Continue? (yes/no): ", volume.Name)
+		var response string
+		if _, err := fmt.Scanln(&response); err != nil || (response != "yes" && response != "y") {
+			fmt.Println("Deletion cancelled")
+			return nil
+		}
+	}
+
+	if err := ec2Client.DeleteDataVolume(ctx, volume.ID); err != nil {
+		return fmt.Errorf("failed to delete data volume: %w", err)
+	}
+	fmt.Printf("Data volume %s (%s) deleted\n", volume.Name, volume.ID)
+	return nil
+}
+
+// RunVolumesSnapshot starts a snapshot of a data volume and returns its ID
+func RunVolumesSnapshot(ctx context.Context, opts VolumesOptions, ref string) (string, error) {
+	ec2Client, err := volumesClient(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+	volume, err := findDataVolume(ctx, ec2Client, opts, ref)
+	if err != nil {
+		return "", err
+	}
+
+	description := fmt.Sprintf("Snapshot of data volume %s taken %s", volume.Name, time.Now().UTC().Format(time.RFC3339))
+	snapshotID, err := ec2Client.SnapshotDataVolume(ctx, *volume, backupOwner(ctx, opts.Profile), description)
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("Snapshot %s of data volume %s started\n", snapshotID, volume.Name)
+	if volume.Attached() {
+		fmt.Println("The snapshot holds the data written to disk when it started; stop the instance first for a consistent copy")
+	}
+	fmt.Printf("Snapshots cost $%.2f per GB-month of changed data\n", cost.EBSSnapshotPricePerGBMonth)
+	return snapshotID, nil
+}
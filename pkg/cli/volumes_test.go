@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/fakecloud"
+)
+
+func TestDataVolumes_OutliveInstances(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	ctx := context.Background()
+	opts := VolumesOptions{Profile: "default"}
+
+	ec2Client, err := aws.NewEC2Client(ctx, "default")
+	if err != nil {
+		t.Fatalf("NewEC2Client failed: %v", err)
+	}
+	spec, err := aws.ParseDataVolumeSpec("thesis-data:100")
+	if err != nil {
+		t.Fatalf("ParseDataVolumeSpec failed: %v", err)
+	}
+
+	// A new volume needs a size and is created in the chosen zone
+	if _, _, err := DataVolumeForLaunch(ctx, ec2Client, "default", aws.DataVolumeSpec{Name: "thesis-data"}, ""); err == nil {
+		t.Error("expected an error for a new volume without a size")
+	}
+	existing, zone, err := DataVolumeForLaunch(ctx, ec2Client, "default", spec, "")
+	if err != nil || existing != nil || zone != "" {
+		t.Fatalf("DataVolumeForLaunch = %v, %q, %v; want nothing for a new volume", existing, zone, err)
+	}
+
+	id := launchTagged(t, fakecloud.DefaultRegion, aws.InstanceMetadata{App: "lens-jupyter"})
+	if err := cloud.SetInstanceState(id, types.InstanceStateNameRunning); err != nil {
+		t.Fatalf("SetInstanceState failed: %v", err)
+	}
+	inst, _ := cloud.Instance(id)
+	instanceZone := *inst.Placement.AvailabilityZone
+
+	owner, err := callerARN(ctx, "default")
+	if err != nil {
+		t.Fatalf("callerARN failed: %v", err)
+	}
+	volume, err := ec2Client.CreateDataVolume(ctx, spec, instanceZone, aws.InstanceMetadata{App: "lens-jupyter", Project: "thesis", Owner: owner})
+	if err != nil {
+		t.Fatalf("CreateDataVolume failed: %v", err)
+	}
+	if err := ec2Client.AttachDataVolume(ctx, volume.ID, id); err != nil {
+		t.Fatalf("AttachDataVolume failed: %v", err)
+	}
+
+	// An attached volume cannot be launched with or deleted
+	if _, _, err := DataVolumeForLaunch(ctx, ec2Client, "default", spec, ""); err == nil || !strings.Contains(err.Error(), id) {
+		t.Errorf("expected an error naming the attached instance, got %v", err)
+	}
+	if err := RunVolumesDelete(ctx, opts, "thesis-data", true); err == nil {
+		t.Error("expected an error deleting an attached volume")
+	}
+	if err := RunVolumesList(ctx, opts); err != nil {
+		t.Errorf("RunVolumesList failed: %v", err)
+	}
+
+	// Snapshots are backups that gc leaves alone
+	snapshotID, err := RunVolumesSnapshot(ctx, opts, "thesis-data")
+	if err != nil {
+		t.Fatalf("RunVolumesSnapshot failed: %v", err)
+	}
+	scan, err := ec2Client.ScanOrphans(ctx, true)
+	if err != nil {
+		t.Fatalf("ScanOrphans failed: %v", err)
+	}
+	for _, orphan := range scan.Orphans {
+		if orphan.Resource.ID == snapshotID {
+			t.Errorf("gc reports data volume snapshot %s as orphaned", snapshotID)
+		}
+	}
+
+	// Terminating the instance keeps the volume and frees it for the next launch
+	if err := cloud.SetInstanceState(id, types.InstanceStateNameTerminated); err != nil {
+		t.Fatalf("SetInstanceState failed: %v", err)
+	}
+	existing, zone, err = DataVolumeForLaunch(ctx, ec2Client, "default", aws.DataVolumeSpec{Name: "thesis-data"}, "")
+	if err != nil {
+		t.Fatalf("DataVolumeForLaunch failed: %v", err)
+	}
+	if existing == nil || existing.ID != volume.ID || zone != instanceZone || existing.Project != "thesis" {
+		t.Errorf("DataVolumeForLaunch = %+v in %q, want %s in %s", existing, zone, volume.ID, instanceZone)
+	}
+	otherZone := fakecloud.DefaultRegion + "a"
+	if otherZone == instanceZone {
+		otherZone = fakecloud.DefaultRegion + "b"
+	}
+	if _, _, err := DataVolumeForLaunch(ctx, ec2Client, "default", spec, otherZone); err == nil {
+		t.Errorf("expected an error launching in %s with a volume in %s", otherZone, instanceZone)
+	}
+
+	if err := RunVolumesDelete(ctx, opts, volume.ID, true); err != nil {
+		t.Fatalf("RunVolumesDelete failed: %v", err)
+	}
+	for _, v := range cloud.Volumes(fakecloud.DefaultRegion) {
+		if *v.VolumeId == volume.ID {
+			t.Errorf("volume %s still exists after delete", volume.ID)
+		}
+	}
+}
+
+func TestDataVolumes_ScopedToTheirOwner(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	ctx := context.Background()
+
+	ec2Client, err := aws.NewEC2Client(ctx, "default")
+	if err != nil {
+		t.Fatalf("NewEC2Client failed: %v", err)
+	}
+	spec, err := aws.ParseDataVolumeSpec("thesis-data:100")
+	if err != nil {
+		t.Fatalf("ParseDataVolumeSpec failed: %v", err)
+	}
+	zone := fakecloud.DefaultRegion + "a"
+	colleague := "arn:aws:iam::" + fakecloud.AccountID + ":user/colleague"
+	theirs, err := ec2Client.CreateDataVolume(ctx, spec, zone, aws.InstanceMetadata{App: "lens-jupyter", Owner: colleague})
+	if err != nil {
+		t.Fatalf("CreateDataVolume failed: %v", err)
+	}
+
+	// A volume of the same name that someone else owns is neither attached
+	// nor silently duplicated
+	if _, _, err := DataVolumeForLaunch(ctx, ec2Client, "default", spec, ""); err == nil || !strings.Contains(err.Error(), colleague) {
+		t.Errorf("expected an error naming the owner of the volume, got %v", err)
+	}
+	if err := RunVolumesDelete(ctx, VolumesOptions{Profile: "default"}, "thesis-data", true); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected someone else's volume not to be found by name, got %v", err)
+	}
+
+	// Sharing it is an explicit choice
+	spec.Shared = true
+	existing, _, err := DataVolumeForLaunch(ctx, ec2Client, "default", spec, "")
+	if err != nil || existing == nil || existing.ID != theirs.ID {
+		t.Fatalf("DataVolumeForLaunch = %+v, %v; want %s when shared", existing, err, theirs.ID)
+	}
+
+	// Once the caller has a volume of that name, it is the one they get
+	owner, err := callerARN(ctx, "default")
+	if err != nil {
+		t.Fatalf("callerARN failed: %v", err)
+	}
+	mine, err := ec2Client.CreateDataVolume(ctx, spec, zone, aws.InstanceMetadata{App: "lens-jupyter", Owner: owner})
+	if err != nil {
+		t.Fatalf("CreateDataVolume failed: %v", err)
+	}
+	spec.Shared = false
+	if existing, _, err := DataVolumeForLaunch(ctx, ec2Client, "default", spec, ""); err != nil || existing == nil || existing.ID != mine.ID {
+		t.Errorf("DataVolumeForLaunch = %+v, %v; want the caller's %s", existing, err, mine.ID)
+	}
+	if err := RunVolumesDelete(ctx, VolumesOptions{Profile: "default"}, "thesis-data", true); err != nil {
+		t.Fatalf("RunVolumesDelete failed: %v", err)
+	}
+	for _, v := range cloud.Volumes(fakecloud.DefaultRegion) {
+		if *v.VolumeId == mine.ID {
+			t.Errorf("volume %s still exists after delete", mine.ID)
+		}
+	}
+	if volumes := cloud.Volumes(fakecloud.DefaultRegion); len(volumes) != 1 || *volumes[0].VolumeId != theirs.ID {
+		t.Errorf("expected only %s to be left, got %d volumes", theirs.ID, len(volumes))
+	}
+}
+
+func TestParseDataVolumeSpec(t *testing.T) {
+	tests := []struct {
+		value string
+		want  aws.DataVolumeSpec
+		ok    bool
+	}{
+		{"thesis-data:100", aws.DataVolumeSpec{Name: "thesis-data", SizeGB: 100}, true},
+		{"thesis-data", aws.DataVolumeSpec{Name: "thesis-data"}, true},
+		{"thesis-data:0", aws.DataVolumeSpec{}, false},
+		{"thesis-data:big", aws.DataVolumeSpec{}, false},
+		{":100", aws.DataVolumeSpec{}, false},
+		{"my data:10", aws.DataVolumeSpec{}, false},
+	}
+	for _, tt := range tests {
+		got, err := aws.ParseDataVolumeSpec(tt.value)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseDataVolumeSpec(%q) = %+v, %v", tt.value, got, err)
+		}
+	}
+}
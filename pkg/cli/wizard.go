@@ -1,11 +1,13 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/scttfrdmn/lens/pkg/config"
+	"github.com/scttfrdmn/lens/pkg/cost"
 )
 
 // WizardConfig holds the configuration options for the wizard
@@ -35,6 +37,12 @@ func RunLaunchWizard(cfg WizardConfig) (*WizardResult, error) {
 		prefs = &config.WizardPreferences{}
 	}
 
+	// Prices are for the configured region, or us-east-1 when none is set
+	region := ""
+	if userCfg, err := config.LoadConfig(); err == nil {
+		region = userCfg.DefaultRegion
+	}
+
 	fmt.Println()
 	fmt.Println("═══════════════════════════════════════════════════════════════")
 	fmt.Printf("  Welcome to %s! Let's set up your cloud environment.\n", cfg.AppName)
@@ -101,16 +109,11 @@ func RunLaunchWizard(cfg WizardConfig) (*WizardResult, error) {
 
 	// Question 2: How powerful a computer do you need?
 	var powerChoice string
-	powerOptions := []string{
-		"Light work (browsing data, small datasets) - $0.0042/hour",
-		"Normal work (typical analysis) - $0.0084/hour [Recommended]",
-		"Heavy work (large datasets, complex models) - $0.0168/hour",
-		"Very heavy work (big data, deep learning) - $0.0336/hour",
-	}
-	defaultPower := "Normal work (typical analysis) - $0.0084/hour [Recommended]"
-	if prefs.LastInstanceType != "" {
-		defaultPower = mapInstanceTypeToChoice(prefs.LastInstanceType)
+	var powerOptions []string
+	for _, tier := range powerTiers {
+		powerOptions = append(powerOptions, powerTierChoice(tier.instanceType, tier.label, region))
 	}
+	defaultPower := mapInstanceTypeToChoice(prefs.LastInstanceType, region)
 	powerPrompt := &survey.Select{
 		Message: "How powerful a computer do you need?",
 		Options: powerOptions,
@@ -208,14 +211,17 @@ func RunLaunchWizard(cfg WizardConfig) (*WizardResult, error) {
 	fmt.Println()
 
 	// Calculate estimated cost
-	hourlyCost := getHourlyCost(result.InstanceType)
-	storageCost := float64(result.EBSSize) * 0.0001 // Approximate EBS cost per GB-hour
-	totalHourlyCost := hourlyCost + storageCost
-	monthlyEstimate := totalHourlyCost * 730 // Approximate hours per month
+	price := getHourlyCost(result.InstanceType, region)
+	storageCost := float64(result.EBSSize) * cost.EBSPricePerGBMonth / cost.HoursPerMonth
+	totalHourlyCost := price.Hourly + storageCost
+	monthlyEstimate := totalHourlyCost * cost.HoursPerMonth
 
 	fmt.Printf("  Estimated cost:\n")
 	fmt.Printf("    Per hour:      $%.4f\n", totalHourlyCost)
 	fmt.Printf("    Per month:     $%.2f (if running 24/7)\n", monthlyEstimate)
+	if note := price.Note(); note != "" {
+		fmt.Printf("    Note: %s price is %s\n", result.InstanceType, note)
+	}
 	if result.IdleTimeout != "" {
 		fmt.Printf("    Note: With auto-stop enabled, your actual cost will be much lower!\n")
 	}
@@ -340,35 +346,41 @@ func mapIdleChoiceToTimeout(choice string) string {
 	}
 }
 
-func getHourlyCost(instanceType string) float64 {
-	// Approximate costs for t4g instances in us-east-1
-	costs := map[string]float64{
-		"t4g.small":  0.0042,
-		"t4g.medium": 0.0084,
-		"t4g.large":  0.0168,
-		"t4g.xlarge": 0.0336,
-	}
-	if cost, ok := costs[instanceType]; ok {
-		return cost
+// getHourlyCost returns the on-demand price of a Linux instance type in the
+// region, or us-east-1 when region is empty
+func getHourlyCost(instanceType, region string) cost.Price {
+	return cost.LookupPrice(context.Background(), cost.PriceQuery{InstanceType: instanceType, Region: region})
+}
+
+// powerTiers are the instance types offered by the wizard
+var powerTiers = []struct {
+	instanceType string
+	label        string
+}{
+	{"t4g.small", "Light work (browsing data, small datasets)"},
+	{"t4g.medium", "Normal work (typical analysis)"},
+	{"t4g.large", "Heavy work (large datasets, complex models)"},
+	{"t4g.xlarge", "Very heavy work (big data, deep learning)"},
+}
+
+// powerTierChoice formats a power tier option with its price in the region
+func powerTierChoice(instanceType, label, region string) string {
+	choice := label + " - " + getHourlyCost(instanceType, region).FormatHourly()
+	if instanceType == "t4g.medium" {
+		choice += " [Recommended]"
 	}
-	return 0.01 // Default fallback
+	return choice
 }
 
 // Helper functions to map between instance types/sizes and user-friendly choices
 
-func mapInstanceTypeToChoice(instanceType string) string {
-	switch instanceType {
-	case "t4g.small":
-		return "Light work (browsing data, small datasets) - $0.0042/hour"
-	case "t4g.medium":
-		return "Normal work (typical analysis) - $0.0084/hour [Recommended]"
-	case "t4g.large":
-		return "Heavy work (large datasets, complex models) - $0.0168/hour"
-	case "t4g.xlarge":
-		return "Very heavy work (big data, deep learning) - $0.0336/hour"
-	default:
-		return "Normal work (typical analysis) - $0.0084/hour [Recommended]"
+func mapInstanceTypeToChoice(instanceType, region string) string {
+	for _, tier := range powerTiers {
+		if tier.instanceType == instanceType {
+			return powerTierChoice(tier.instanceType, tier.label, region)
+		}
 	}
+	return powerTierChoice("t4g.medium", "Normal work (typical analysis)", region)
 }
 
 func mapGBToChoice(gb int) string {
@@ -0,0 +1,242 @@
+// Command lens-agent runs on lens instances. It watches for user activity,
+// writes its status to a JSON file and stops the instance once it has been
+// idle for the idle timeout.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/scttfrdmn/lens/pkg/agent"
+)
+
+const usage = `Usage: lens-agent <command> [flags]
+
+Commands:
+  run        Watch for activity and stop the instance when idle
+  check      Check every signal once and print the results
+  status     Print the status written by the running agent
+  keepalive  Postpone auto-stop, e.g. keepalive --for 3h
+  version    Print the version
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "run":
+		err = run(os.Args[2:])
+	case "check":
+		err = check(os.Args[2:])
+	case "status":
+		err = status(os.Args[2:])
+	case "keepalive":
+		err = keepalive(os.Args[2:])
+	case "version", "--version":
+		fmt.Printf("lens-agent %s\n", versionString())
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func versionString() string {
+	if version := agent.ReleaseVersion(); version != "" {
+		return version
+	}
+	return "dev"
+}
+
+// signalFlags registers the flags that select and tune signals
+func signalFlags(fs *flag.FlagSet) (*string, *agent.SignalConfig) {
+	cfg := agent.DefaultSignalConfig()
+	names := fs.String("signals", "cpu,sessions", "Comma-separated activity signals: "+strings.Join(agent.SignalNames, ", "))
+	fs.StringVar(&cfg.JupyterURL, "jupyter-url", cfg.JupyterURL, "Jupyter server URL")
+	fs.StringVar(&cfg.CodeServerHeartbeat, "code-server-heartbeat", cfg.CodeServerHeartbeat, "code-server heartbeat file")
+	fs.StringVar(&cfg.DCVSession, "dcv-session", cfg.DCVSession, "DCV session name")
+	fs.Float64Var(&cfg.CPUThreshold, "cpu-threshold", cfg.CPUThreshold, "CPU usage percent that counts as activity")
+	fs.Float64Var(&cfg.GPUThreshold, "gpu-threshold", cfg.GPUThreshold, "GPU utilization percent that counts as activity")
+	fs.Float64Var(&cfg.NetworkThreshold, "network-threshold", cfg.NetworkThreshold, "Network throughput in KB/s that counts as activity")
+	return names, &cfg
+}
+
+func newSignals(names string, cfg agent.SignalConfig) ([]agent.Signal, error) {
+	var list []string
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			list = append(list, name)
+		}
+	}
+	return agent.NewSignals(list, cfg)
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	app := fs.String("app", "", "App the instance runs, e.g. lens-jupyter")
+	idleTimeout := fs.Duration("idle-timeout", 4*time.Hour, "Idle time before the action runs; 0 never runs it")
+	actionName := fs.String("action", string(agent.ActionStop), "Action when idle: stop, hibernate or none")
+	interval := fs.Duration("interval", agent.DefaultInterval, "How often to check for activity")
+	statusFile := fs.String("status-file", agent.DefaultStatusFile, "Status file to write")
+	disableFile := fs.String("disable-file", agent.DefaultDisableFile, "Auto-stop is off while this file exists")
+	keepaliveFile := fs.String("keepalive-file", agent.DefaultKeepaliveFile, "File holding the time auto-stop is postponed until")
+	warnBefore := fs.Duration("warn-before", agent.DefaultWarnBefore, "Warn logged-in users this long before stopping; 0 never warns")
+	names, signalConfig := signalFlags(fs)
+	fs.Parse(args)
+
+	action, err := agent.ParseAction(*actionName)
+	if err != nil {
+		return err
+	}
+	signals, err := newSignals(*names, *signalConfig)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var stopper agent.Stopper
+	if action != agent.ActionNone && *idleTimeout > 0 {
+		ec2Stopper, err := agent.NewEC2Stopper(ctx)
+		if err != nil {
+			return err
+		}
+		stopper = ec2Stopper
+	}
+
+	// The idle alarm, if the instance has one, sees keepalives through CloudWatch
+	var publisher agent.KeepalivePublisher
+	if cwPublisher, err := agent.NewCloudWatchPublisher(ctx); err != nil {
+		log.Printf("Warning: keepalives will not reach the idle alarm: %v", err)
+	} else {
+		publisher = cwPublisher
+	}
+
+	monitor := agent.NewMonitor(agent.Config{
+		App:           *app,
+		IdleTimeout:   *idleTimeout,
+		Action:        action,
+		Interval:      *interval,
+		StatusFile:    *statusFile,
+		DisableFile:   *disableFile,
+		KeepaliveFile: *keepaliveFile,
+		WarnBefore:    *warnBefore,
+	}, signals, stopper, publisher)
+	fmt.Printf("lens-agent %s watching %s, %s after %s idle\n", versionString(), *names, action, *idleTimeout)
+	return monitor.Run(ctx)
+}
+
+func check(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	names, signalConfig := signalFlags(fs)
+	fs.Parse(args)
+
+	signals, err := newSignals(*names, *signalConfig)
+	if err != nil {
+		return err
+	}
+	// Rate-based signals need two samples
+	ctx := context.Background()
+	since := time.Now()
+	for _, s := range signals {
+		s.Check(ctx, since)
+	}
+	time.Sleep(time.Second)
+	for _, s := range signals {
+		activity, err := s.Check(ctx, since)
+		switch {
+		case err != nil:
+			fmt.Printf("%-12s error: %v\n", s.Name(), err)
+		case activity.Active:
+			fmt.Printf("%-12s active  %s\n", s.Name(), activity.Detail)
+		default:
+			fmt.Printf("%-12s idle    %s\n", s.Name(), activity.Detail)
+		}
+	}
+	return nil
+}
+
+func status(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	statusFile := fs.String("status-file", agent.DefaultStatusFile, "Status file to read")
+	asJSON := fs.Bool("json", false, "Print the raw JSON status")
+	fs.Parse(args)
+
+	if *asJSON {
+		data, err := os.ReadFile(*statusFile)
+		if err != nil {
+			return fmt.Errorf("failed to read status file: %w", err)
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	st, err := agent.ReadStatus(*statusFile)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("State:         %s\n", st.State)
+	fmt.Printf("Last activity: %s (idle %s)\n", st.LastActivity.Local().Format(time.RFC1123), time.Duration(st.IdleSeconds)*time.Second)
+	if st.KeepaliveUntil != nil {
+		fmt.Printf("Keepalive:     until %s\n", st.KeepaliveUntil.Local().Format(time.RFC1123))
+	}
+	if st.StopAt != nil {
+		fmt.Printf("%-15s%s\n", "Auto-"+string(st.Action)+":", st.StopAt.Local().Format(time.RFC1123))
+	} else {
+		fmt.Printf("Auto-stop:     off\n")
+	}
+	fmt.Printf("Checked:       %s\n", st.CheckedAt.Local().Format(time.RFC1123))
+	fmt.Println("Signals:")
+	for _, s := range st.Signals {
+		state := "idle"
+		if s.Active {
+			state = "active"
+		}
+		if s.Error != "" {
+			state = "error: " + s.Error
+		}
+		fmt.Printf("  %-12s %-8s %s\n", s.Name, state, s.Detail)
+	}
+	return nil
+}
+
+func keepalive(args []string) error {
+	fs := flag.NewFlagSet("keepalive", flag.ExitOnError)
+	duration := fs.Duration("for", time.Hour, "How long to postpone auto-stop; 0 removes the keepalive")
+	keepaliveFile := fs.String("keepalive-file", agent.DefaultKeepaliveFile, "Keepalive file to write")
+	fs.Parse(args)
+
+	if *duration < 0 {
+		return fmt.Errorf("--for must not be negative")
+	}
+	if *duration == 0 {
+		if err := agent.WriteKeepalive(*keepaliveFile, time.Time{}); err != nil {
+			return err
+		}
+		fmt.Println("Keepalive removed")
+		return nil
+	}
+	until := time.Now().Add(*duration).Truncate(time.Second)
+	if err := agent.WriteKeepalive(*keepaliveFile, until); err != nil {
+		return err
+	}
+	fmt.Printf("Auto-stop postponed until %s\n", until.UTC().Format(time.RFC3339))
+	return nil
+}
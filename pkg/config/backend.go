@@ -0,0 +1,158 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrStateNotShared is returned by StateBackend.LoadAll when the backend
+// only holds the state of the current user
+var ErrStateNotShared = errors.New("state backend is not shared; set state_backend to an s3:// URL to share state with your team")
+
+// StateBackend persists LocalState
+type StateBackend interface {
+	// Load returns the current user's state, or an empty state if none was saved
+	Load() (*LocalState, error)
+
+	// Save replaces the current user's state
+	Save(state *LocalState) error
+
+	// Update applies fn to the current user's state and saves the result
+	// without overwriting concurrent changes. fn may be called more than once.
+	Update(fn func(*LocalState) error) error
+
+	// LoadAll returns the state of every user sharing the backend, by owner
+	LoadAll() (map[string]*LocalState, error)
+
+	// String describes where state is stored
+	String() string
+}
+
+var (
+	backendMu       sync.Mutex
+	backendOverride StateBackend
+)
+
+// UseStateBackend makes LoadState, Save, UpdateState and LoadAllStates use b
+// instead of the backend configured in the user config. It returns a function
+// that restores the previous backend.
+func UseStateBackend(b StateBackend) (restore func()) {
+	backendMu.Lock()
+	previous := backendOverride
+	backendOverride = b
+	backendMu.Unlock()
+
+	return func() {
+		backendMu.Lock()
+		backendOverride = previous
+		backendMu.Unlock()
+	}
+}
+
+// CurrentStateBackend returns the backend selected by the state_backend
+// setting of the user config: the local state file unless it is an s3:// URL
+func CurrentStateBackend() (StateBackend, error) {
+	return currentStateBackend()
+}
+
+func currentStateBackend() (StateBackend, error) {
+	backendMu.Lock()
+	override := backendOverride
+	backendMu.Unlock()
+	if override != nil {
+		return override, nil
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case cfg.StateBackend == "" || cfg.StateBackend == "local":
+		return FileBackend{}, nil
+	case strings.HasPrefix(cfg.StateBackend, "s3://"):
+		return NewS3Backend(context.Background(), S3BackendOptions{
+			URL:      cfg.StateBackend,
+			Profile:  cfg.DefaultProfile,
+			Region:   cfg.StateRegion,
+			Endpoint: cfg.StateEndpoint,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported state_backend %q (use \"local\" or s3://bucket/prefix)", cfg.StateBackend)
+	}
+}
+
+// LoadAllStates returns the state of every user sharing the configured
+// backend, by owner. It returns ErrStateNotShared for the local state file.
+func LoadAllStates() (map[string]*LocalState, error) {
+	backend, err := currentStateBackend()
+	if err != nil {
+		return nil, err
+	}
+	return backend.LoadAll()
+}
+
+// FileBackend stores state in ~/.lens/state.json. Updates are serialised with
+// an advisory lock and the file is replaced atomically.
+type FileBackend struct{}
+
+// statePath returns the path of the state file
+func (FileBackend) statePath() string {
+	return filepath.Join(GetConfigDir(), "state.json")
+}
+
+// Load reads the state file
+func (b FileBackend) Load() (*LocalState, error) {
+	data, err := os.ReadFile(b.statePath())
+	if os.IsNotExist(err) {
+		return newLocalState(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeState(data)
+}
+
+// Save replaces the state file
+func (b FileBackend) Save(state *LocalState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(b.statePath(), data, permStateFile)
+}
+
+// Update applies fn while holding the state lock
+func (b FileBackend) Update(fn func(*LocalState) error) error {
+	unlock, err := lockState()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state, err := b.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	if err := fn(state); err != nil {
+		return err
+	}
+	return b.Save(state)
+}
+
+// LoadAll is not supported: the state file only holds the current user's state
+func (FileBackend) LoadAll() (map[string]*LocalState, error) {
+	return nil, ErrStateNotShared
+}
+
+// String returns the path of the state file
+func (b FileBackend) String() string {
+	return b.statePath()
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/scttfrdmn/lens/pkg/aws"
+)
+
+// s3UpdateAttempts is how often S3Backend.Update retries after another
+// writer changed the state between read and write
+const s3UpdateAttempts = 5
+
+// S3BackendOptions configures an S3 state backend
+type S3BackendOptions struct {
+	URL      string // s3://bucket/prefix
+	Profile  string
+	Region   string // Bucket region; the profile's region if empty
+	Endpoint string // Endpoint of an S3-compatible store, e.g. http://localhost:9000
+	Owner    string // Owner of the state; the caller's IAM user or session name if empty
+}
+
+// S3Backend stores each user's state as <prefix>/<owner>.json in a shared
+// bucket. Writes are conditional on the ETag read, so concurrent writers
+// never overwrite each other's changes.
+type S3Backend struct {
+	client *aws.S3Client
+	bucket string
+	prefix string
+	owner  string
+}
+
+// ParseS3URL splits an s3://bucket/prefix URL into bucket and prefix
+func ParseS3URL(url string) (bucket, prefix string, err error) {
+	if !strings.HasPrefix(url, "s3://") {
+		return "", "", fmt.Errorf("invalid S3 URL %q: must start with s3://", url)
+	}
+	bucket, prefix, _ = strings.Cut(strings.TrimPrefix(url, "s3://"), "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("invalid S3 URL %q: missing bucket name", url)
+	}
+	return bucket, strings.Trim(prefix, "/"), nil
+}
+
+// NewS3Backend creates an S3 state backend. The owner defaults to the name of
+// the IAM user or role session of the configured credentials.
+func NewS3Backend(ctx context.Context, opts S3BackendOptions) (*S3Backend, error) {
+	bucket, prefix, err := ParseS3URL(opts.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := aws.NewS3Client(ctx, aws.S3Options{
+		Profile:  opts.Profile,
+		Region:   opts.Region,
+		Endpoint: opts.Endpoint,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	owner := opts.Owner
+	if owner == "" {
+		stsClient, err := aws.NewSTSClient(ctx, opts.Profile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create STS client: %w", err)
+		}
+		identity, err := stsClient.GetCallerIdentity(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine state owner: %w", err)
+		}
+		owner = identity.Name()
+	}
+
+	return &S3Backend{client: client, bucket: bucket, prefix: prefix, owner: owner}, nil
+}
+
+// Owner returns the owner whose state is read and written
+func (b *S3Backend) Owner() string {
+	return b.owner
+}
+
+// key returns the object key holding an owner's state
+func (b *S3Backend) key(owner string) string {
+	return path.Join(b.prefix, owner+".json")
+}
+
+// load reads the current user's state and its ETag, which is empty when no
+// state has been saved yet
+func (b *S3Backend) load(ctx context.Context) (*LocalState, string, error) {
+	data, etag, err := b.client.GetObject(ctx, b.bucket, b.key(b.owner))
+	if errors.Is(err, aws.ErrObjectNotFound) {
+		return newLocalState(), "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	state, err := decodeState(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse %s: %w", b, err)
+	}
+	return state, etag, nil
+}
+
+// Load reads the current user's state
+func (b *S3Backend) Load() (*LocalState, error) {
+	state, _, err := b.load(context.Background())
+	return state, err
+}
+
+// Save replaces the current user's state
+func (b *S3Backend) Save(state *LocalState) error {
+	return b.Update(func(current *LocalState) error {
+		*current = *state
+		return nil
+	})
+}
+
+// Update reads the state, applies fn and writes it back if the object is
+// unchanged, retrying from a fresh read when another writer got there first
+func (b *S3Backend) Update(fn func(*LocalState) error) error {
+	ctx := context.Background()
+	for attempt := 0; attempt < s3UpdateAttempts; attempt++ {
+		state, etag, err := b.load(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load state: %w", err)
+		}
+		if err := fn(state); err != nil {
+			return err
+		}
+
+		data, err := json.MarshalIndent(state, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = b.client.PutObject(ctx, b.bucket, b.key(b.owner), data, etag)
+		if errors.Is(err, aws.ErrPreconditionFailed) {
+			continue
+		}
+		return err
+	}
+	return fmt.Errorf("failed to save state to %s: it was modified concurrently %d times", b, s3UpdateAttempts)
+}
+
+// LoadAll reads the state of every owner under the prefix
+func (b *S3Backend) LoadAll() (map[string]*LocalState, error) {
+	ctx := context.Background()
+
+	listPrefix := ""
+	if b.prefix != "" {
+		listPrefix = b.prefix + "/"
+	}
+	keys, err := b.client.ListObjects(ctx, b.bucket, listPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make(map[string]*LocalState)
+	for _, key := range keys {
+		owner := strings.TrimPrefix(key, listPrefix)
+		if !strings.HasSuffix(owner, ".json") || strings.Contains(owner, "/") {
+			continue
+		}
+		owner = strings.TrimSuffix(owner, ".json")
+
+		data, _, err := b.client.GetObject(ctx, b.bucket, key)
+		if errors.Is(err, aws.ErrObjectNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		state, err := decodeState(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse s3://%s/%s: %w", b.bucket, key, err)
+		}
+		states[owner] = state
+	}
+	return states, nil
+}
+
+// String returns the S3 URL of the current user's state
+func (b *S3Backend) String() string {
+	return fmt.Sprintf("s3://%s/%s", b.bucket, b.key(b.owner))
+}
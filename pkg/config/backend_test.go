@@ -0,0 +1,177 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/scttfrdmn/lens/pkg/fakecloud"
+)
+
+const testStateBucket = "team-state"
+
+// setupS3State isolates HOME, routes AWS calls to a fake cloud with a state
+// bucket and configures the S3 state backend
+func setupS3State(t *testing.T) *fakecloud.Cloud {
+	t.Helper()
+
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+	cloud.CreateBucket(testStateBucket)
+
+	if err := SaveUserConfig(&UserConfig{StateBackend: "s3://" + testStateBucket + "/lens"}); err != nil {
+		t.Fatalf("Failed to save user config: %v", err)
+	}
+	return cloud
+}
+
+func addInstance(t *testing.T, id string) {
+	t.Helper()
+
+	err := UpdateState(func(state *LocalState) error {
+		state.Instances[id] = &Instance{ID: id, InstanceType: "t4g.medium"}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateState failed: %v", err)
+	}
+}
+
+func TestS3Backend_StoresStatePerOwner(t *testing.T) {
+	cloud := setupS3State(t)
+
+	addInstance(t, "i-researcher")
+	cloud.SetCaller("arn:aws:sts::123456789012:assumed-role/lens-users/alice")
+	addInstance(t, "i-alice")
+
+	if _, ok := cloud.Object(testStateBucket, "lens/researcher.json"); !ok {
+		t.Error("Expected state object for researcher")
+	}
+	if _, ok := cloud.Object(testStateBucket, "lens/alice.json"); !ok {
+		t.Error("Expected state object for alice")
+	}
+
+	state, err := LoadState()
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if len(state.Instances) != 1 || state.Instances["i-alice"] == nil {
+		t.Errorf("Expected only alice's instance in her state, got %v", state.Instances)
+	}
+
+	states, err := LoadAllStates()
+	if err != nil {
+		t.Fatalf("LoadAllStates failed: %v", err)
+	}
+	if len(states) != 2 {
+		t.Fatalf("Expected state of 2 owners, got %d", len(states))
+	}
+	if states["researcher"].Instances["i-researcher"] == nil || states["alice"].Instances["i-alice"] == nil {
+		t.Errorf("Expected each owner's instance in team state, got %+v", states)
+	}
+}
+
+func TestS3Backend_UpdateRetriesAfterConcurrentWrite(t *testing.T) {
+	setupS3State(t)
+
+	opts := S3BackendOptions{URL: "s3://" + testStateBucket + "/lens", Owner: "alice"}
+	backend, err := NewS3Backend(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("NewS3Backend failed: %v", err)
+	}
+	other, err := NewS3Backend(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("NewS3Backend failed: %v", err)
+	}
+
+	calls := 0
+	err = backend.Update(func(state *LocalState) error {
+		calls++
+		if calls == 1 {
+			// Another process saves its change between our read and write
+			if err := other.Update(func(state *LocalState) error {
+				state.Instances["i-other"] = &Instance{ID: "i-other"}
+				return nil
+			}); err != nil {
+				t.Fatalf("Concurrent update failed: %v", err)
+			}
+		}
+		state.Instances["i-mine"] = &Instance{ID: "i-mine"}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected update to be retried once, got %d calls", calls)
+	}
+
+	state, err := backend.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if state.Instances["i-mine"] == nil || state.Instances["i-other"] == nil {
+		t.Errorf("Expected both changes to be kept, got %v", state.Instances)
+	}
+}
+
+func TestS3Backend_UpdateErrorDiscardsChanges(t *testing.T) {
+	cloud := setupS3State(t)
+
+	err := UpdateState(func(state *LocalState) error {
+		state.Instances["i-1"] = &Instance{ID: "i-1"}
+		return errors.New("boom")
+	})
+	if err == nil {
+		t.Fatal("Expected UpdateState to fail")
+	}
+	if _, ok := cloud.Object(testStateBucket, "lens/researcher.json"); ok {
+		t.Error("Expected no state to be written after a failed update")
+	}
+}
+
+func TestLoadAllStates_LocalStateIsNotShared(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if _, err := LoadAllStates(); !errors.Is(err, ErrStateNotShared) {
+		t.Errorf("Expected ErrStateNotShared, got %v", err)
+	}
+}
+
+func TestCurrentStateBackend_RejectsUnknownBackend(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := SaveUserConfig(&UserConfig{StateBackend: "gs://bucket"}); err != nil {
+		t.Fatalf("Failed to save user config: %v", err)
+	}
+
+	if _, err := LoadState(); err == nil {
+		t.Error("Expected an unsupported state backend to be rejected")
+	}
+}
+
+func TestParseS3URL(t *testing.T) {
+	tests := []struct {
+		url    string
+		bucket string
+		prefix string
+		valid  bool
+	}{
+		{"s3://bucket", "bucket", "", true},
+		{"s3://bucket/", "bucket", "", true},
+		{"s3://bucket/team/lens/", "bucket", "team/lens", true},
+		{"s3:///prefix", "", "", false},
+		{"bucket/prefix", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			bucket, prefix, err := ParseS3URL(tt.url)
+			if (err == nil) != tt.valid {
+				t.Fatalf("ParseS3URL(%q) error = %v, want valid=%v", tt.url, err, tt.valid)
+			}
+			if bucket != tt.bucket || prefix != tt.prefix {
+				t.Errorf("ParseS3URL(%q) = %q, %q, want %q, %q", tt.url, bucket, prefix, tt.bucket, tt.prefix)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/scttfrdmn/lens/pkg/aws"
+)
+
+// Budget caps the spend of the instances it matches in a calendar month.
+// Selectors that are empty match every instance, so a budget without
+// selectors caps all instances.
+type Budget struct {
+	Name string `yaml:"name"`
+
+	// Selectors
+	User    string `yaml:"user,omitempty"`    // IAM user or role session name of the owner
+	App     string `yaml:"app,omitempty"`     // e.g. "jupyter" or "lens-jupyter"
+	Project string `yaml:"project,omitempty"` // lens:project tag
+
+	// Caps in dollars; zero means no cap
+	Monthly     float64 `yaml:"monthly,omitempty"`      // Spend of all matched instances this month
+	PerInstance float64 `yaml:"per_instance,omitempty"` // Spend of each matched instance this month
+}
+
+// Validate checks that the budget has a name and at least one cap
+func (b Budget) Validate() error {
+	if b.Name == "" {
+		return fmt.Errorf("budget name is required")
+	}
+	if b.Monthly < 0 || b.PerInstance < 0 {
+		return fmt.Errorf("budget %s: caps cannot be negative", b.Name)
+	}
+	if b.Monthly == 0 && b.PerInstance == 0 {
+		return fmt.Errorf("budget %s: set a monthly or per-instance cap", b.Name)
+	}
+	return nil
+}
+
+// Matches reports whether the budget covers an instance
+func (b Budget) Matches(instance *Instance) bool {
+	if b.User != "" && !strings.EqualFold(b.User, aws.CallerIdentity{ARN: instance.Owner}.Name()) {
+		return false
+	}
+	if b.App != "" && instance.App != b.App && instance.App != "lens-"+b.App {
+		return false
+	}
+	if b.Project != "" && instance.Project != b.Project {
+		return false
+	}
+	return true
+}
+
+// Scope describes the instances the budget matches, e.g. "user alice, app jupyter"
+func (b Budget) Scope() string {
+	var parts []string
+	if b.User != "" {
+		parts = append(parts, "user "+b.User)
+	}
+	if b.App != "" {
+		parts = append(parts, "app "+b.App)
+	}
+	if b.Project != "" {
+		parts = append(parts, "project "+b.Project)
+	}
+	if len(parts) == 0 {
+		return "all instances"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// FindBudget returns the index of the named budget, or -1
+func (c *UserConfig) FindBudget(name string) int {
+	for i, budget := range c.Budgets {
+		if budget.Name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Sources of environments and export configs
+const (
+	SourceUser    = "user"     // ~/.lens/environments or ~/.lens/configs
+	SourceBuiltin = "built-in" // Embedded in the app binary
+)
+
+// Directories of environments and export configs, in the user config
+// directory and in the built-in file system
+const (
+	environmentsDir  = "environments"
+	exportConfigsDir = "configs"
+)
+
+// builtins holds the environments and export configs shipped with the app,
+// laid out as environments/<name>.yaml and configs/<name>.yaml
+var builtins fs.FS = emptyFS{}
+
+// emptyFS is the file system of an app without built-ins
+type emptyFS struct{}
+
+func (emptyFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// errDefinitionNotFound is returned by readDefinition when neither the user
+// config directory nor the built-ins have the definition
+var errDefinitionNotFound = errors.New("definition not found")
+
+// UseBuiltins sets the app's built-in environments and export configs, usually
+// an embed.FS. It returns a function that restores the previous built-ins.
+func UseBuiltins(fsys fs.FS) (restore func()) {
+	previous := builtins
+	builtins = fsys
+	return func() { builtins = previous }
+}
+
+// DefinitionInfo describes an available environment or export config
+type DefinitionInfo struct {
+	Name      string
+	Source    string // SourceUser or SourceBuiltin
+	Overrides bool   // A user definition replaces a built-in one of the same name
+}
+
+// SourceDescription describes the source, e.g. "user (overrides built-in)"
+func (d DefinitionInfo) SourceDescription() string {
+	if d.Overrides {
+		return d.Source + " (overrides " + SourceBuiltin + ")"
+	}
+	return d.Source
+}
+
+// readDefinition reads kind/name.yaml from the user config directory, then
+// from the built-ins. builtinOnly skips the user config directory.
+func readDefinition(kind, name string, builtinOnly bool) (data []byte, source string, err error) {
+	if !builtinOnly {
+		data, err := os.ReadFile(filepath.Join(GetConfigDir(), kind, name+".yaml"))
+		if err == nil {
+			return data, SourceUser, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, "", err
+		}
+	}
+
+	data, err = fs.ReadFile(builtins, path.Join(kind, name+".yaml"))
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) {
+		return nil, "", errDefinitionNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return data, SourceBuiltin, nil
+}
+
+// listDefinitions returns the definitions of kind in the user config
+// directory and the built-ins, sorted by name
+func listDefinitions(kind string) ([]DefinitionInfo, error) {
+	byName := make(map[string]*DefinitionInfo)
+
+	if entries, err := fs.ReadDir(builtins, kind); err == nil {
+		for _, entry := range entries {
+			if name, ok := definitionName(entry); ok {
+				byName[name] = &DefinitionInfo{Name: name, Source: SourceBuiltin}
+			}
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	if entries, err := os.ReadDir(filepath.Join(GetConfigDir(), kind)); err == nil {
+		for _, entry := range entries {
+			if name, ok := definitionName(entry); ok {
+				_, builtin := byName[name]
+				byName[name] = &DefinitionInfo{Name: name, Source: SourceUser, Overrides: builtin}
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	infos := make([]DefinitionInfo, 0, len(byName))
+	for _, info := range byName {
+		infos = append(infos, *info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos, nil
+}
+
+func definitionName(entry fs.DirEntry) (string, bool) {
+	if entry.IsDir() || filepath.Ext(entry.Name()) != ".yaml" {
+		return "", false
+	}
+	return strings.TrimSuffix(entry.Name(), ".yaml"), true
+}
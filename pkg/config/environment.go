@@ -2,8 +2,7 @@ package config
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -20,68 +19,230 @@ type Environment struct {
 	JuliaPackages     []string          `yaml:"julia_packages,omitempty"`
 	JupyterExtensions []string          `yaml:"jupyter_extensions"`
 	EnvironmentVars   map[string]string `yaml:"environment_vars"`
+
+	// Inheritance: parents are merged in order, then this environment on top
+	Extends Parents              `yaml:"extends,omitempty"`
+	Remove  *EnvironmentRemovals `yaml:"remove,omitempty"` // Inherited entries to drop
 }
 
-// LoadEnvironment loads an environment configuration by name from built-in or user configs
+// Parents lists the environments an environment extends. In YAML it is
+// either a single name or a list of names.
+type Parents []string
+
+// UnmarshalYAML accepts a single name as well as a list
+func (p *Parents) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*p = Parents{value.Value}
+		return nil
+	}
+	var names []string
+	if err := value.Decode(&names); err != nil {
+		return fmt.Errorf("extends must be an environment name or a list of names: %w", err)
+	}
+	*p = names
+	return nil
+}
+
+// EnvironmentRemovals lists inherited entries that an environment drops
+type EnvironmentRemovals struct {
+	Packages          []string `yaml:"packages,omitempty"`
+	PipPackages       []string `yaml:"pip_packages,omitempty"`
+	RPackages         []string `yaml:"r_packages,omitempty"`
+	JuliaPackages     []string `yaml:"julia_packages,omitempty"`
+	JupyterExtensions []string `yaml:"jupyter_extensions,omitempty"`
+	EnvironmentVars   []string `yaml:"environment_vars,omitempty"` // Variable names
+}
+
+// LoadEnvironment loads an environment by name and resolves its inheritance:
+// lists are appended without duplicates, maps are merged and scalars set by
+// the child override its parents.
+//
+// A user environment that extends its own name extends the built-in
+// environment it overrides.
 func LoadEnvironment(name string) (*Environment, error) {
-	// Try user config first, then built-in, then Homebrew pkgshare
-	userPath := filepath.Join(GetConfigDir(), "environments", name+".yaml")
-	builtinPath := filepath.Join("environments", name+".yaml")
-	homebrewPath := filepath.Join("/opt/homebrew/share/lens-jupyter/environments", name+".yaml")
-	linuxbrewPath := filepath.Join("/home/linuxbrew/.linuxbrew/share/lens-jupyter/environments", name+".yaml")
-
-	var path string
-	if _, err := os.Stat(userPath); err == nil {
-		path = userPath
-	} else if _, err := os.Stat(builtinPath); err == nil {
-		path = builtinPath
-	} else if _, err := os.Stat(homebrewPath); err == nil {
-		path = homebrewPath
-	} else if _, err := os.Stat(linuxbrewPath); err == nil {
-		path = linuxbrewPath
-	} else {
-		return nil, fmt.Errorf("environment %s not found", name)
-	}
-
-	data, err := os.ReadFile(path)
+	return resolveEnvironment(name, false, nil)
+}
+
+func resolveEnvironment(name string, builtinOnly bool, chain []string) (*Environment, error) {
+	label := name
+	if builtinOnly {
+		label = name + " (" + SourceBuiltin + ")"
+	}
+	for i, seen := range chain {
+		if seen == label {
+			cycle := append(append([]string{}, chain[i:]...), label)
+			return nil, fmt.Errorf("environment inheritance cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	env, source, err := loadEnvironmentDefinition(name, builtinOnly)
 	if err != nil {
+		if len(chain) > 0 {
+			return nil, fmt.Errorf("environment %s extends %s: %w", chain[len(chain)-1], name, err)
+		}
 		return nil, err
 	}
+	if len(env.Extends) == 0 {
+		if env.Remove != nil {
+			return nil, fmt.Errorf("environment %s removes entries but extends no environment", name)
+		}
+		return env, nil
+	}
 
-	var env Environment
-	if err := yaml.Unmarshal(data, &env); err != nil {
-		return nil, err
+	chain = append(chain[:len(chain):len(chain)], label)
+	inherited := &Environment{}
+	for _, parentName := range env.Extends {
+		parent, err := resolveEnvironment(parentName, parentName == name && source == SourceUser, chain)
+		if err != nil {
+			return nil, err
+		}
+		inherited = mergeEnvironments(inherited, parent)
+	}
+	if err := inherited.removeEntries(env.Remove); err != nil {
+		return nil, fmt.Errorf("environment %s: %w", name, err)
 	}
 
-	return &env, nil
+	resolved := mergeEnvironments(inherited, env)
+	resolved.Extends = nil
+	resolved.Remove = nil
+	return resolved, nil
 }
 
-// ListEnvironments returns a list of all available environment names from built-in and user configs
-func ListEnvironments() ([]string, error) {
-	var envs []string
-	seen := make(map[string]bool)
-
-	// Check directories in order of precedence
-	dirs := []string{
-		filepath.Join(GetConfigDir(), "environments"),
-		"environments",
-		"/opt/homebrew/share/lens-jupyter/environments",
-		"/home/linuxbrew/.linuxbrew/share/lens-jupyter/environments",
-	}
-
-	for _, dir := range dirs {
-		if entries, err := os.ReadDir(dir); err == nil {
-			for _, entry := range entries {
-				if filepath.Ext(entry.Name()) == ".yaml" {
-					name := entry.Name()[:len(entry.Name())-5]
-					if !seen[name] {
-						envs = append(envs, name)
-						seen[name] = true
-					}
+// mergeEnvironments returns base with over applied on top
+func mergeEnvironments(base, over *Environment) *Environment {
+	merged := *base
+	if over.Name != "" {
+		merged.Name = over.Name
+	}
+	if over.InstanceType != "" {
+		merged.InstanceType = over.InstanceType
+	}
+	if over.AMIBase != "" {
+		merged.AMIBase = over.AMIBase
+	}
+	if over.EBSVolumeSize != 0 {
+		merged.EBSVolumeSize = over.EBSVolumeSize
+	}
+
+	merged.Packages = appendUnique(base.Packages, over.Packages)
+	merged.PipPackages = appendUnique(base.PipPackages, over.PipPackages)
+	merged.RPackages = appendUnique(base.RPackages, over.RPackages)
+	merged.JuliaPackages = appendUnique(base.JuliaPackages, over.JuliaPackages)
+	merged.JupyterExtensions = appendUnique(base.JupyterExtensions, over.JupyterExtensions)
+
+	if len(base.EnvironmentVars)+len(over.EnvironmentVars) > 0 {
+		merged.EnvironmentVars = make(map[string]string, len(base.EnvironmentVars)+len(over.EnvironmentVars))
+		for k, v := range base.EnvironmentVars {
+			merged.EnvironmentVars[k] = v
+		}
+		for k, v := range over.EnvironmentVars {
+			merged.EnvironmentVars[k] = v
+		}
+	}
+	return &merged
+}
+
+// appendUnique returns the entries of a followed by those of b, keeping the
+// first occurrence of duplicates
+func appendUnique(a, b []string) []string {
+	if len(a)+len(b) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(a)+len(b))
+	result := make([]string, 0, len(a)+len(b))
+	for _, list := range [][]string{a, b} {
+		for _, entry := range list {
+			if !seen[entry] {
+				seen[entry] = true
+				result = append(result, entry)
+			}
+		}
+	}
+	return result
+}
+
+// removeEntries drops inherited entries. Removing an entry that is not
+// inherited is an error so typos don't go unnoticed.
+func (e *Environment) removeEntries(remove *EnvironmentRemovals) error {
+	if remove == nil {
+		return nil
+	}
+
+	lists := []struct {
+		field   string
+		entries *[]string
+		remove  []string
+	}{
+		{"packages", &e.Packages, remove.Packages},
+		{"pip_packages", &e.PipPackages, remove.PipPackages},
+		{"r_packages", &e.RPackages, remove.RPackages},
+		{"julia_packages", &e.JuliaPackages, remove.JuliaPackages},
+		{"jupyter_extensions", &e.JupyterExtensions, remove.JupyterExtensions},
+	}
+	for _, list := range lists {
+		for _, entry := range list.remove {
+			kept := (*list.entries)[:0:0]
+			for _, existing := range *list.entries {
+				if existing != entry {
+					kept = append(kept, existing)
 				}
 			}
+			if len(kept) == len(*list.entries) {
+				return fmt.Errorf("cannot remove %s %q: it is not inherited", list.field, entry)
+			}
+			*list.entries = kept
+		}
+	}
+
+	for _, name := range remove.EnvironmentVars {
+		if _, ok := e.EnvironmentVars[name]; !ok {
+			return fmt.Errorf("cannot remove environment_vars %q: it is not inherited", name)
 		}
+		delete(e.EnvironmentVars, name)
+	}
+	return nil
+}
+
+// LoadEnvironmentDefinition loads an environment by name as written, without
+// resolving its inheritance. Environments in the user config directory take
+// precedence over the app's built-in ones.
+func LoadEnvironmentDefinition(name string) (*Environment, error) {
+	env, _, err := loadEnvironmentDefinition(name, false)
+	return env, err
+}
+
+func loadEnvironmentDefinition(name string, builtinOnly bool) (*Environment, string, error) {
+	data, source, err := readDefinition(environmentsDir, name, builtinOnly)
+	if err == errDefinitionNotFound {
+		return nil, "", fmt.Errorf("environment %s not found", name)
+	}
+	if err != nil {
+		return nil, "", err
 	}
 
+	var env Environment
+	if err := yaml.Unmarshal(data, &env); err != nil {
+		return nil, "", fmt.Errorf("failed to parse environment %s: %w", name, err)
+	}
+
+	return &env, source, nil
+}
+
+// ListEnvironments returns the names of all available environments from built-in and user configs
+func ListEnvironments() ([]string, error) {
+	infos, err := ListEnvironmentInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	envs := make([]string, 0, len(infos))
+	for _, info := range infos {
+		envs = append(envs, info.Name)
+	}
 	return envs, nil
 }
+
+// ListEnvironmentInfo returns all available environments with their source
+func ListEnvironmentInfo() ([]DefinitionInfo, error) {
+	return listDefinitions(environmentsDir)
+}
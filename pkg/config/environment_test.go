@@ -3,7 +3,9 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 func TestEnvironmentStruct(t *testing.T) {
@@ -249,23 +251,13 @@ func TestListEnvironments_Deduplication(t *testing.T) {
 		}
 	}()
 
-	// Create both user and built-in directories
+	// Create the user directory
 	configDir := GetConfigDir()
 	userEnvDir := filepath.Join(configDir, "environments")
 	if err := os.MkdirAll(userEnvDir, 0755); err != nil {
 		t.Fatalf("Failed to create user env dir: %v", err)
 	}
 
-	builtinEnvDir := "environments"
-	if err := os.MkdirAll(builtinEnvDir, 0755); err != nil {
-		t.Fatalf("Failed to create builtin env dir: %v", err)
-	}
-	defer func() {
-		if err := os.RemoveAll(builtinEnvDir); err != nil {
-			t.Logf("Warning: failed to remove builtin env dir: %v", err)
-		}
-	}()
-
 	// Create same environment in both locations
 	content := `name: "duplicate"
 instance_type: "t3.medium"
@@ -281,10 +273,9 @@ pip_packages: []
 	}
 
 	// Built-in version
-	builtinPath := filepath.Join(builtinEnvDir, "duplicate.yaml")
-	if err := os.WriteFile(builtinPath, []byte(content), 0644); err != nil {
-		t.Fatalf("Failed to write builtin environment: %v", err)
-	}
+	defer UseBuiltins(fstest.MapFS{
+		"environments/duplicate.yaml": {Data: []byte(content)},
+	})()
 
 	// List should deduplicate
 	envs, err := ListEnvironments()
@@ -303,3 +294,189 @@ pip_packages: []
 		t.Errorf("Expected 1 'duplicate' environment (deduplicated), got %d", count)
 	}
 }
+
+// writeUserEnvironments isolates HOME and writes environments to the user
+// environments directory
+func writeUserEnvironments(t *testing.T, envs map[string]string) {
+	t.Helper()
+
+	t.Setenv("HOME", t.TempDir())
+	envDir := filepath.Join(GetConfigDir(), "environments")
+	if err := os.MkdirAll(envDir, 0755); err != nil {
+		t.Fatalf("Failed to create env dir: %v", err)
+	}
+	for name, content := range envs {
+		if err := os.WriteFile(filepath.Join(envDir, name+".yaml"), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write environment %s: %v", name, err)
+		}
+	}
+}
+
+func TestLoadEnvironment_Extends(t *testing.T) {
+	writeUserEnvironments(t, map[string]string{
+		"test-base": `name: "Base"
+instance_type: "t4g.medium"
+ami_base: "ubuntu24-arm64"
+ebs_volume_size: 20
+packages: [git, htop, python3-pip]
+pip_packages: [pandas]
+environment_vars:
+  PYTHONPATH: "/home/ubuntu/notebooks"
+  EDITOR: "vim"
+`,
+		"test-gpu": `name: "GPU"
+packages: [nvidia-driver]
+pip_packages: [torch]
+`,
+		"test-child": `name: "Child"
+extends: [test-base, test-gpu]
+instance_type: "m7g.large"
+packages: [git, build-essential]
+pip_packages: [scikit-learn]
+environment_vars:
+  EDITOR: "nano"
+remove:
+  packages: [htop]
+  environment_vars: [PYTHONPATH]
+`,
+	})
+
+	env, err := LoadEnvironment("test-child")
+	if err != nil {
+		t.Fatalf("LoadEnvironment failed: %v", err)
+	}
+
+	if env.Name != "Child" || env.InstanceType != "m7g.large" {
+		t.Errorf("Expected child scalars to override, got name=%q type=%q", env.Name, env.InstanceType)
+	}
+	if env.AMIBase != "ubuntu24-arm64" || env.EBSVolumeSize != 20 {
+		t.Errorf("Expected unset scalars to be inherited, got ami=%q ebs=%d", env.AMIBase, env.EBSVolumeSize)
+	}
+	if got := strings.Join(env.Packages, ","); got != "git,python3-pip,nvidia-driver,build-essential" {
+		t.Errorf("Unexpected packages: %s", got)
+	}
+	if got := strings.Join(env.PipPackages, ","); got != "pandas,torch,scikit-learn" {
+		t.Errorf("Unexpected pip packages: %s", got)
+	}
+	if len(env.EnvironmentVars) != 1 || env.EnvironmentVars["EDITOR"] != "nano" {
+		t.Errorf("Unexpected environment vars: %v", env.EnvironmentVars)
+	}
+	if env.Extends != nil || env.Remove != nil {
+		t.Error("Expected resolved environment to have no inheritance left")
+	}
+
+	// The definition is returned as written
+	definition, err := LoadEnvironmentDefinition("test-child")
+	if err != nil {
+		t.Fatalf("LoadEnvironmentDefinition failed: %v", err)
+	}
+	if len(definition.Extends) != 2 || len(definition.Packages) != 2 {
+		t.Errorf("Expected unresolved definition, got %+v", definition)
+	}
+}
+
+func TestLoadEnvironment_ExtendsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		envs map[string]string
+		want string
+	}{
+		{
+			name: "cycle",
+			envs: map[string]string{
+				"test-a": "extends: test-b\n",
+				"test-b": "extends: test-c\n",
+				"test-c": "extends: test-a\n",
+			},
+			want: "environment inheritance cycle: test-a -> test-b -> test-c -> test-a",
+		},
+		{
+			name: "missing parent",
+			envs: map[string]string{
+				"test-a": "extends: test-missing\n",
+			},
+			want: "environment test-a extends test-missing: environment test-missing not found",
+		},
+		{
+			name: "remove not inherited",
+			envs: map[string]string{
+				"test-a": "extends: test-b\nremove:\n  pip_packages: [pands]\n",
+				"test-b": "pip_packages: [pandas]\n",
+			},
+			want: `environment test-a: cannot remove pip_packages "pands": it is not inherited`,
+		},
+		{
+			name: "remove without extends",
+			envs: map[string]string{
+				"test-a": "remove:\n  packages: [git]\n",
+			},
+			want: "environment test-a removes entries but extends no environment",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeUserEnvironments(t, tt.envs)
+
+			_, err := LoadEnvironment("test-a")
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Expected error %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadEnvironment_Builtins(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	defer UseBuiltins(fstest.MapFS{
+		"environments/base.yaml":    {Data: []byte("name: Base\ninstance_type: t4g.medium\npackages: [git]\n")},
+		"environments/science.yaml": {Data: []byte("name: Science\nextends: base\npip_packages: [numpy]\n")},
+		"environments/README.md":    {Data: []byte("not an environment")},
+	})()
+
+	env, err := LoadEnvironment("science")
+	if err != nil {
+		t.Fatalf("LoadEnvironment failed: %v", err)
+	}
+	if env.InstanceType != "t4g.medium" || len(env.Packages) != 1 || len(env.PipPackages) != 1 {
+		t.Errorf("Unexpected built-in environment: %+v", env)
+	}
+
+	// A user environment overrides the built-in one and can extend it
+	envDir := filepath.Join(GetConfigDir(), "environments")
+	if err := os.MkdirAll(envDir, 0755); err != nil {
+		t.Fatalf("Failed to create env dir: %v", err)
+	}
+	override := "extends: science\ninstance_type: m7g.xlarge\npip_packages: [pandas]\n"
+	if err := os.WriteFile(filepath.Join(envDir, "science.yaml"), []byte(override), 0644); err != nil {
+		t.Fatalf("Failed to write environment: %v", err)
+	}
+
+	env, err = LoadEnvironment("science")
+	if err != nil {
+		t.Fatalf("LoadEnvironment failed: %v", err)
+	}
+	if env.Name != "Science" || env.InstanceType != "m7g.xlarge" || strings.Join(env.PipPackages, ",") != "numpy,pandas" {
+		t.Errorf("Expected user override on top of the built-in, got %+v", env)
+	}
+
+	infos, err := ListEnvironmentInfo()
+	if err != nil {
+		t.Fatalf("ListEnvironmentInfo failed: %v", err)
+	}
+	want := []DefinitionInfo{
+		{Name: "base", Source: SourceBuiltin},
+		{Name: "science", Source: SourceUser, Overrides: true},
+	}
+	if len(infos) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, infos)
+	}
+	for i := range want {
+		if infos[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want[i], infos[i])
+		}
+	}
+	if got := infos[1].SourceDescription(); got != "user (overrides built-in)" {
+		t.Errorf("Unexpected source description: %s", got)
+	}
+}
@@ -2,8 +2,6 @@ package config
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
 
 	"gopkg.in/yaml.v3"
 )
@@ -34,28 +32,13 @@ type ExportConfig struct {
 	RestoreCommands []RestoreCommand `yaml:"restore_commands"`
 }
 
-// LoadExportConfig loads an export configuration by name from built-in or user configs
+// LoadExportConfig loads an export configuration by name. Configs in the
+// user config directory take precedence over the app's built-in ones.
 func LoadExportConfig(name string) (*ExportConfig, error) {
-	// Try user config first, then built-in, then Homebrew pkgshare
-	userPath := filepath.Join(GetConfigDir(), "configs", name+".yaml")
-	builtinPath := filepath.Join("configs", name+".yaml")
-	homebrewPath := filepath.Join("/opt/homebrew/share/aws-ide/configs", name+".yaml")
-	linuxbrewPath := filepath.Join("/home/linuxbrew/.linuxbrew/share/aws-ide/configs", name+".yaml")
-
-	var path string
-	if _, err := os.Stat(userPath); err == nil {
-		path = userPath
-	} else if _, err := os.Stat(builtinPath); err == nil {
-		path = builtinPath
-	} else if _, err := os.Stat(homebrewPath); err == nil {
-		path = homebrewPath
-	} else if _, err := os.Stat(linuxbrewPath); err == nil {
-		path = linuxbrewPath
-	} else {
+	data, _, err := readDefinition(exportConfigsDir, name, false)
+	if err == errDefinitionNotFound {
 		return nil, fmt.Errorf("export config %s not found", name)
 	}
-
-	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
@@ -68,33 +51,17 @@ func LoadExportConfig(name string) (*ExportConfig, error) {
 	return &cfg, nil
 }
 
-// ListExportConfigs returns a list of all available export config names from built-in and user configs
+// ListExportConfigs returns the names of all available export configs from built-in and user configs
 func ListExportConfigs() ([]string, error) {
-	var configs []string
-	seen := make(map[string]bool)
-
-	// Check directories in order of precedence
-	dirs := []string{
-		filepath.Join(GetConfigDir(), "configs"),
-		"configs",
-		"/opt/homebrew/share/aws-ide/configs",
-		"/home/linuxbrew/.linuxbrew/share/aws-ide/configs",
+	infos, err := listDefinitions(exportConfigsDir)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, dir := range dirs {
-		if entries, err := os.ReadDir(dir); err == nil {
-			for _, entry := range entries {
-				if filepath.Ext(entry.Name()) == ".yaml" {
-					name := entry.Name()[:len(entry.Name())-5]
-					if !seen[name] {
-						configs = append(configs, name)
-						seen[name] = true
-					}
-				}
-			}
-		}
+	configs := make([]string, 0, len(infos))
+	for _, info := range infos {
+		configs = append(configs, info.Name)
 	}
-
 	return configs, nil
 }
 
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/lens/pkg/aws"
+)
+
+// Sources of backfilled state changes
+const (
+	StateSourceEC2        = "ec2"        // Launch time and state transition reason of the instance
+	StateSourceCloudTrail = "cloudtrail" // Start, stop and terminate events in CloudTrail
+	StateSourceSchedule   = "schedule"   // Starts and stops of the instance's schedule
+)
+
+// Origin describes where a state change came from, e.g. "inferred from EC2"
+func (c StateChange) Origin() string {
+	origin := "observed"
+	if c.Inferred {
+		origin = "inferred"
+	}
+	switch c.Source {
+	case StateSourceEC2:
+		return origin + " from EC2"
+	case StateSourceCloudTrail:
+		return origin + " in CloudTrail"
+	case StateSourceSchedule:
+		return origin + " from schedule"
+	default:
+		return "recorded by lens"
+	}
+}
+
+// stateMergeWindow is how close a backfilled change must be to a known
+// change to the same state to be taken as the same change
+const stateMergeWindow = 10 * time.Minute
+
+// transitionTime matches the time in an EC2 state transition reason, e.g.
+// "User initiated (2026-01-05 14:22:11 GMT)"
+var transitionTime = regexp.MustCompile(`\((\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}) GMT\)`)
+
+// ParseTransitionTime returns the time in an EC2 state transition reason
+func ParseTransitionTime(reason string) (time.Time, bool) {
+	match := transitionTime.FindStringSubmatch(reason)
+	if match == nil {
+		return time.Time{}, false
+	}
+	at, err := time.Parse("2006-01-02 15:04:05", match[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return at, true
+}
+
+// InferStateChanges derives the state changes visible in the EC2 description
+// of an instance: its last start, since EC2 updates the launch time on every
+// start, and for a stopped instance the time it stopped from its state
+// transition reason. Changes in between are not visible.
+func InferStateChanges(ec2Instance types.Instance) []StateChange {
+	var changes []StateChange
+	if ec2Instance.LaunchTime != nil {
+		changes = append(changes, StateChange{
+			State:     "running",
+			Timestamp: *ec2Instance.LaunchTime,
+			Source:    StateSourceEC2,
+			Inferred:  true,
+		})
+	}
+	if ec2Instance.State != nil {
+		switch ec2Instance.State.Name {
+		case types.InstanceStateNameStopping, types.InstanceStateNameStopped:
+			reason := ""
+			if ec2Instance.StateTransitionReason != nil {
+				reason = *ec2Instance.StateTransitionReason
+			}
+			if at, ok := ParseTransitionTime(reason); ok {
+				changes = append(changes, StateChange{
+					State:     "stopped",
+					Timestamp: at,
+					Source:    StateSourceEC2,
+					Inferred:  true,
+				})
+			}
+		}
+	}
+	return changes
+}
+
+// StateChangesFromEvents converts the CloudTrail events of an instance into
+// observed state changes
+func StateChangesFromEvents(events []aws.InstanceStateEvent) []StateChange {
+	changes := make([]StateChange, len(events))
+	for i, event := range events {
+		changes[i] = StateChange{
+			State:     event.State,
+			Timestamp: event.Time,
+			Source:    StateSourceCloudTrail,
+		}
+	}
+	return changes
+}
+
+// MergeStateChanges adds backfilled state changes to the instance history
+// and returns how many were added. A change close to a known change to the
+// same state is taken as that change, with an observed time replacing an
+// inferred one. Changes before launch or after termination, and backfilled
+// changes that would not change the state, are dropped.
+func (i *Instance) MergeStateChanges(changes []StateChange) int {
+	history := append([]StateChange(nil), i.StateChanges...)
+	before := backfilledCount(history)
+
+	var terminatedAt time.Time
+	if n := len(history); n > 0 && history[n-1].State == "terminated" {
+		terminatedAt = history[n-1].Timestamp
+	}
+
+	for _, change := range changes {
+		if change.Timestamp.Before(i.LaunchedAt) || (!terminatedAt.IsZero() && change.Timestamp.After(terminatedAt)) {
+			continue
+		}
+		known := -1
+		for j, existing := range history {
+			if existing.State == change.State && absDuration(existing.Timestamp.Sub(change.Timestamp)) <= stateMergeWindow {
+				known = j
+				break
+			}
+		}
+		if known >= 0 {
+			if history[known].Inferred && !change.Inferred {
+				change.ResizedFrom = history[known].ResizedFrom
+				history[known] = change
+			}
+			continue
+		}
+		history = append(history, change)
+	}
+
+	sort.SliceStable(history, func(a, b int) bool {
+		return history[a].Timestamp.Before(history[b].Timestamp)
+	})
+	merged := history[:0]
+	for _, change := range history {
+		if n := len(merged); n > 0 && change.Source != "" && merged[n-1].State == change.State {
+			continue
+		}
+		merged = append(merged, change)
+	}
+
+	i.StateChanges = merged
+	return backfilledCount(merged) - before
+}
+
+// backfilledCount returns the number of changes that lens did not record itself
+func backfilledCount(changes []StateChange) int {
+	count := 0
+	for _, change := range changes {
+		if change.Source != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// absDuration returns the absolute value of d
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// Backfill merges observed state changes, e.g. from CloudTrail, into the
+// history of tracked instances and returns the instances whose history
+// gained changes
+func (s *LocalState) Backfill(changes map[string][]StateChange) []string {
+	var backfilled []string
+	for _, id := range sortedIDs(changes) {
+		instance, ok := s.Instances[id]
+		if ok && instance.MergeStateChanges(changes[id]) > 0 {
+			backfilled = append(backfilled, id)
+		}
+	}
+	return backfilled
+}
+
+// sortedIDs returns the instance IDs of a map in sorted order
+func sortedIDs(changes map[string][]StateChange) []string {
+	ids := make([]string, 0, len(changes))
+	for id := range changes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func TestInferStateChanges(t *testing.T) {
+	started := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	stopped := types.Instance{
+		LaunchTime:            &started,
+		State:                 &types.InstanceState{Name: types.InstanceStateNameStopped},
+		StateTransitionReason: ptr("User initiated (2026-03-02 17:30:05 GMT)"),
+	}
+
+	changes := InferStateChanges(stopped)
+	want := []StateChange{
+		{State: "running", Timestamp: started, Source: StateSourceEC2, Inferred: true},
+		{State: "stopped", Timestamp: time.Date(2026, 3, 2, 17, 30, 5, 0, time.UTC), Source: StateSourceEC2, Inferred: true},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Expected %d changes, got %+v", len(want), changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("Change %d: expected %+v, got %+v", i, want[i], changes[i])
+		}
+	}
+
+	running := types.Instance{LaunchTime: &started, State: &types.InstanceState{Name: types.InstanceStateNameRunning}}
+	if changes := InferStateChanges(running); len(changes) != 1 || changes[0].State != "running" {
+		t.Errorf("Expected only the last start of a running instance, got %+v", changes)
+	}
+}
+
+func TestMergeStateChanges(t *testing.T) {
+	launched := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	at := func(hours float64) time.Time { return launched.Add(time.Duration(hours * float64(time.Hour))) }
+	instance := &Instance{
+		InstanceType: "t4g.medium",
+		LaunchedAt:   launched,
+		StateChanges: []StateChange{{State: "running", Timestamp: at(0)}}, // Launched by lens
+	}
+
+	// Stopped on the instance after 4 hours, which EC2 shows
+	added := instance.MergeStateChanges([]StateChange{
+		{State: "running", Timestamp: at(0), Source: StateSourceEC2, Inferred: true},
+		{State: "stopped", Timestamp: at(4), Source: StateSourceEC2, Inferred: true},
+	})
+	if added != 1 {
+		t.Fatalf("Expected 1 inferred change, got %d: %+v", added, instance.StateChanges)
+	}
+
+	// Resized while stopped
+	instance.RecordTypeChange("m7g.large")
+	if n := len(instance.StateChanges); n != 2 || instance.InstanceType != "m7g.large" {
+		t.Fatalf("Expected the resize on the inferred stop, got %s with %+v", instance.InstanceType, instance.StateChanges)
+	}
+
+	// CloudTrail has the stop, and a start and stop from the console since
+	added = instance.MergeStateChanges([]StateChange{
+		{State: "running", Timestamp: at(-1), Source: StateSourceCloudTrail},                 // Before launch
+		{State: "running", Timestamp: at(0).Add(time.Minute), Source: StateSourceCloudTrail}, // Recorded by lens
+		{State: "stopped", Timestamp: at(4).Add(-time.Minute), Source: StateSourceCloudTrail},
+		{State: "running", Timestamp: at(24), Source: StateSourceCloudTrail},
+		{State: "stopped", Timestamp: at(30), Source: StateSourceCloudTrail},
+	})
+	if added != 2 {
+		t.Errorf("Expected 2 added changes, got %d", added)
+	}
+
+	want := []StateChange{
+		{State: "running", Timestamp: at(0)},
+		{State: "stopped", Timestamp: at(4).Add(-time.Minute), Source: StateSourceCloudTrail, ResizedFrom: "t4g.medium"}, // Observed replaces inferred
+		{State: "running", Timestamp: at(24), Source: StateSourceCloudTrail},
+		{State: "stopped", Timestamp: at(30), Source: StateSourceCloudTrail},
+	}
+	if len(instance.StateChanges) != len(want) {
+		t.Fatalf("Expected %+v, got %+v", want, instance.StateChanges)
+	}
+	for i := range want {
+		if instance.StateChanges[i] != want[i] {
+			t.Errorf("Change %d: expected %+v, got %+v", i, want[i], instance.StateChanges[i])
+		}
+	}
+	if origin := instance.StateChanges[1].Origin(); origin != "observed in CloudTrail" {
+		t.Errorf("Expected origin %q, got %q", "observed in CloudTrail", origin)
+	}
+
+	// A backfilled change that does not change the state is dropped
+	if added := instance.MergeStateChanges([]StateChange{{State: "stopped", Timestamp: at(40), Source: StateSourceEC2, Inferred: true}}); added != 0 {
+		t.Errorf("Expected a repeated stop to be dropped, got %+v", instance.StateChanges)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestScheduledStateChanges(t *testing.T) {
+	// Set on a Friday at noon: stops that evening, starts and stops on Monday
+	since := time.Date(2026, 3, 6, 12, 0, 0, 0, time.UTC)
+	instance := &Instance{Schedule: &Schedule{Start: "0 8 * * 1-5", Stop: "0 18 * * 1-5", Timezone: "UTC", Since: since}}
+
+	changes := instance.ScheduledStateChanges(time.Date(2026, 3, 9, 20, 0, 0, 0, time.UTC))
+	want := []StateChange{
+		{State: "stopped", Timestamp: time.Date(2026, 3, 6, 18, 0, 0, 0, time.UTC), Source: StateSourceSchedule, Inferred: true},
+		{State: "running", Timestamp: time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC), Source: StateSourceSchedule, Inferred: true},
+		{State: "stopped", Timestamp: time.Date(2026, 3, 9, 18, 0, 0, 0, time.UTC), Source: StateSourceSchedule, Inferred: true},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Expected %+v, got %+v", want, changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("Change %d: expected %+v, got %+v", i, want[i], changes[i])
+		}
+	}
+	if origin := changes[0].Origin(); origin != "inferred from schedule" {
+		t.Errorf("Expected origin %q, got %q", "inferred from schedule", origin)
+	}
+}
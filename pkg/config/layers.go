@@ -0,0 +1,285 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration layers, from lowest to highest precedence
+const (
+	LayerDefault = "default" // Built-in defaults
+	LayerSystem  = "system"  // System-wide config file
+	LayerUser    = "user"    // ~/.lens/config.yaml
+	LayerProject = "project" // .lens.yaml in the current directory or a parent
+	LayerEnv     = "env"     // LENS_* environment variables
+	LayerFlag    = "flag"    // Command-line flags
+)
+
+// ProjectConfigName is the name of the per-project config file
+const ProjectConfigName = ".lens.yaml"
+
+// EnvPrefix is the prefix of environment variables that override config keys
+const EnvPrefix = "LENS_"
+
+// systemConfigPath is the system-wide config file; a variable so tests can
+// point it elsewhere
+var systemConfigPath = defaultSystemConfigPath()
+
+// SystemConfigPath returns the path of the system-wide config file
+func SystemConfigPath() string {
+	return systemConfigPath
+}
+
+func defaultSystemConfigPath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramData"), "lens", "config.yaml")
+	}
+	return "/etc/lens/config.yaml"
+}
+
+// ValueSource records where an effective configuration value came from
+type ValueSource struct {
+	Layer  string // One of the Layer constants
+	Origin string // File, environment variable or flag that set the value; empty for defaults
+}
+
+// String describes the source, e.g. "user (/home/me/.lens/config.yaml)"
+func (s ValueSource) String() string {
+	if s.Origin == "" {
+		return s.Layer
+	}
+	return fmt.Sprintf("%s (%s)", s.Layer, s.Origin)
+}
+
+// ResolvedConfig is the effective configuration after merging all layers,
+// with the source of every value
+type ResolvedConfig struct {
+	*UserConfig
+
+	values  map[string]interface{} // Effective value by key
+	sources map[string]ValueSource // Source of each value by key
+	files   []ValueSource          // Config files that were read
+}
+
+// ConfigKey describes one configuration key
+type ConfigKey struct {
+	Name string       // Dotted key as used in YAML, e.g. "jupyter.default_environment"
+	Kind reflect.Kind // String, Bool, Int or Float64
+}
+
+// EnvVar returns the environment variable that overrides the key,
+// e.g. LENS_JUPYTER_DEFAULT_ENVIRONMENT
+func (k ConfigKey) EnvVar() string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(k.Name, ".", "_"))
+}
+
+// ConfigKeys returns every configuration key, in the order of UserConfig
+func ConfigKeys() []ConfigKey {
+	return structKeys(reflect.TypeOf(UserConfig{}), "")
+}
+
+func structKeys(t reflect.Type, prefix string) []ConfigKey {
+	var keys []ConfigKey
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct {
+			keys = append(keys, structKeys(fieldType, prefix+name+".")...)
+			continue
+		}
+		if fieldType.Kind() == reflect.Slice {
+			continue // Lists such as budgets are only set in config files
+		}
+		keys = append(keys, ConfigKey{Name: prefix + name, Kind: fieldType.Kind()})
+	}
+	return keys
+}
+
+// LoadConfig returns the effective configuration for the current directory:
+// built-in defaults, overridden by the system config, the user config, the
+// nearest .lens.yaml and LENS_* environment variables, in that order.
+// Command-line flags are applied on top by each command.
+func LoadConfig() (*ResolvedConfig, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current directory: %w", err)
+	}
+	return LoadConfigFrom(dir)
+}
+
+// LoadConfigFrom is LoadConfig with the search for .lens.yaml starting in dir
+func LoadConfigFrom(dir string) (*ResolvedConfig, error) {
+	r := &ResolvedConfig{
+		values:  make(map[string]interface{}),
+		sources: make(map[string]ValueSource),
+	}
+
+	defaults, err := yaml.Marshal(getDefaultConfig())
+	if err != nil {
+		return nil, err
+	}
+	if err := r.mergeYAML(defaults, ValueSource{Layer: LayerDefault}); err != nil {
+		return nil, err
+	}
+
+	files := []ValueSource{
+		{Layer: LayerSystem, Origin: systemConfigPath},
+		{Layer: LayerUser, Origin: GetUserConfigPath()},
+	}
+	if project, ok := FindProjectConfig(dir); ok {
+		files = append(files, ValueSource{Layer: LayerProject, Origin: project})
+	}
+	for _, source := range files {
+		data, err := os.ReadFile(source.Origin)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := r.mergeYAML(data, source); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", source.Origin, err)
+		}
+		r.files = append(r.files, source)
+	}
+
+	for _, key := range ConfigKeys() {
+		raw, ok := os.LookupEnv(key.EnvVar())
+		if !ok {
+			continue
+		}
+		if err := r.set(key, raw, ValueSource{Layer: LayerEnv, Origin: key.EnvVar()}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := r.rebuild(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// FindProjectConfig looks for .lens.yaml in dir and its parents
+func FindProjectConfig(dir string) (string, bool) {
+	for {
+		path := filepath.Join(dir, ProjectConfigName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// mergeYAML overrides values with the keys set in a YAML document
+func (r *ResolvedConfig) mergeYAML(data []byte, source ValueSource) error {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	flattenInto(doc, "", func(key string, value interface{}) {
+		r.values[key] = value
+		r.sources[key] = source
+	})
+	return nil
+}
+
+func flattenInto(doc map[string]interface{}, prefix string, set func(key string, value interface{})) {
+	for name, value := range doc {
+		key := prefix + name
+		switch v := value.(type) {
+		case nil:
+			continue
+		case map[string]interface{}:
+			flattenInto(v, key+".", set)
+		default:
+			set(key, v)
+		}
+	}
+}
+
+// set overrides a key with a value parsed from a string, as given in an
+// environment variable
+func (r *ResolvedConfig) set(key ConfigKey, raw string, source ValueSource) error {
+	var value interface{}
+	var err error
+	switch key.Kind {
+	case reflect.Bool:
+		value, err = strconv.ParseBool(raw)
+	case reflect.Int:
+		value, err = strconv.Atoi(raw)
+	case reflect.Float64:
+		value, err = strconv.ParseFloat(raw, 64)
+	default:
+		value = raw
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value %q for %s from %s: %w", raw, key.Name, source, err)
+	}
+
+	r.values[key.Name] = value
+	r.sources[key.Name] = source
+	return nil
+}
+
+// rebuild decodes the merged values into UserConfig
+func (r *ResolvedConfig) rebuild() error {
+	nested := make(map[string]interface{})
+	for key, value := range r.values {
+		parts := strings.Split(key, ".")
+		m := nested
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := m[part].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				m[part] = child
+			}
+			m = child
+		}
+		m[parts[len(parts)-1]] = value
+	}
+
+	data, err := yaml.Marshal(nested)
+	if err != nil {
+		return err
+	}
+	var cfg UserConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	r.UserConfig = &cfg
+	return nil
+}
+
+// Get returns the effective value of a key formatted as a string and its
+// source. ok is false for keys that are not set in any layer.
+func (r *ResolvedConfig) Get(name string) (value string, source ValueSource, ok bool) {
+	v, ok := r.values[name]
+	if !ok {
+		return "", ValueSource{Layer: LayerDefault}, false
+	}
+	return fmt.Sprint(v), r.sources[name], true
+}
+
+// Files returns the config files that were found, from lowest to highest
+// precedence
+func (r *ResolvedConfig) Files() []ValueSource {
+	return r.files
+}
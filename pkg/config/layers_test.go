@@ -0,0 +1,209 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupLayers isolates HOME and the system config file and returns a project
+// directory to resolve the configuration from
+func setupLayers(t *testing.T) (systemPath, projectDir string) {
+	t.Helper()
+
+	t.Setenv("HOME", t.TempDir())
+
+	systemPath = filepath.Join(t.TempDir(), "config.yaml")
+	previous := systemConfigPath
+	systemConfigPath = systemPath
+	t.Cleanup(func() { systemConfigPath = previous })
+
+	return systemPath, t.TempDir()
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func assertValue(t *testing.T, cfg *ResolvedConfig, key, wantValue, wantLayer string) {
+	t.Helper()
+
+	value, source, ok := cfg.Get(key)
+	if !ok {
+		t.Fatalf("Expected %s to be set", key)
+	}
+	if value != wantValue || source.Layer != wantLayer {
+		t.Errorf("%s = %q from %s, want %q from %s", key, value, source, wantValue, wantLayer)
+	}
+}
+
+func TestLoadConfig_LayerPrecedence(t *testing.T) {
+	systemPath, projectDir := setupLayers(t)
+
+	writeFile(t, systemPath, "default_region: us-east-1\nidle_timeout: 2h\ndefault_profile: org\n")
+	writeFile(t, GetUserConfigPath(), "idle_timeout: 3h\njupyter:\n  port: 9999\n")
+	writeFile(t, filepath.Join(projectDir, ProjectConfigName), "idle_timeout: 8h\njupyter:\n  default_environment: ml-pytorch\n")
+	t.Setenv("LENS_DEFAULT_PROFILE", "research")
+
+	cfg, err := LoadConfigFrom(projectDir)
+	if err != nil {
+		t.Fatalf("LoadConfigFrom failed: %v", err)
+	}
+
+	assertValue(t, cfg, "default_region", "us-east-1", LayerSystem)
+	assertValue(t, cfg, "idle_timeout", "8h", LayerProject)
+	assertValue(t, cfg, "default_profile", "research", LayerEnv)
+	assertValue(t, cfg, "jupyter.port", "9999", LayerUser)
+	assertValue(t, cfg, "jupyter.default_environment", "ml-pytorch", LayerProject)
+	assertValue(t, cfg, "default_instance_type", "t4g.medium", LayerDefault)
+
+	// Nested values merge key by key instead of replacing the whole section
+	if cfg.Jupyter.Port != 9999 || cfg.Jupyter.DefaultEnvironment != "ml-pytorch" {
+		t.Errorf("Expected merged jupyter config, got %+v", cfg.Jupyter)
+	}
+	if cfg.RStudio.Port != 8787 {
+		t.Errorf("Expected rstudio defaults to be kept, got %+v", cfg.RStudio)
+	}
+	if cfg.IdleTimeout != "8h" || cfg.DefaultProfile != "research" {
+		t.Errorf("Unexpected effective config: %+v", cfg.UserConfig)
+	}
+
+	if files := cfg.Files(); len(files) != 3 {
+		t.Errorf("Expected 3 config files, got %+v", files)
+	}
+}
+
+func TestSaveUserConfig_LeavesOutDefaults(t *testing.T) {
+	systemPath, projectDir := setupLayers(t)
+
+	writeFile(t, systemPath, "default_instance_type: m7g.large\nidle_timeout: 2h\n")
+	if err := InitUserConfig(); err != nil {
+		t.Fatalf("InitUserConfig failed: %v", err)
+	}
+	cfg, err := LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig failed: %v", err)
+	}
+	cfg.IdleTimeout = "1h"
+	cfg.Jupyter.DefaultEnvironment = "ml-pytorch"
+	if err := SaveUserConfig(cfg); err != nil {
+		t.Fatalf("SaveUserConfig failed: %v", err)
+	}
+
+	resolved, err := LoadConfigFrom(projectDir)
+	if err != nil {
+		t.Fatalf("LoadConfigFrom failed: %v", err)
+	}
+	assertValue(t, resolved, "idle_timeout", "1h", LayerUser)
+	assertValue(t, resolved, "jupyter.default_environment", "ml-pytorch", LayerUser)
+	assertValue(t, resolved, "default_instance_type", "m7g.large", LayerSystem)
+	assertValue(t, resolved, "idle_warning", "15m", LayerDefault)
+	assertValue(t, resolved, "rstudio.port", "8787", LayerDefault)
+}
+
+func TestLoadConfig_EnvironmentVariablesAreTyped(t *testing.T) {
+	_, projectDir := setupLayers(t)
+
+	t.Setenv("LENS_ENABLE_COST_TRACKING", "false")
+	t.Setenv("LENS_COST_ALERT_THRESHOLD", "250.5")
+	t.Setenv("LENS_VSCODE_PORT", "8443")
+
+	cfg, err := LoadConfigFrom(projectDir)
+	if err != nil {
+		t.Fatalf("LoadConfigFrom failed: %v", err)
+	}
+	if cfg.EnableCostTracking || cfg.CostAlertThreshold != 250.5 || cfg.VSCode.Port != 8443 {
+		t.Errorf("Unexpected effective config: %+v, vscode %+v", cfg.UserConfig, cfg.VSCode)
+	}
+	assertValue(t, cfg, "vscode.port", "8443", LayerEnv)
+
+	t.Setenv("LENS_VSCODE_PORT", "not-a-port")
+	if _, err := LoadConfigFrom(projectDir); err == nil {
+		t.Error("Expected an invalid LENS_VSCODE_PORT to be rejected")
+	}
+}
+
+func TestLoadConfig_ProjectConfigFoundInParent(t *testing.T) {
+	_, projectDir := setupLayers(t)
+
+	writeFile(t, filepath.Join(projectDir, ProjectConfigName), "default_region: eu-west-1\n")
+	nested := filepath.Join(projectDir, "analysis", "notebooks")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	cfg, err := LoadConfigFrom(nested)
+	if err != nil {
+		t.Fatalf("LoadConfigFrom failed: %v", err)
+	}
+	value, source, _ := cfg.Get("default_region")
+	if value != "eu-west-1" || source.Origin != filepath.Join(projectDir, ProjectConfigName) {
+		t.Errorf("Expected default_region from the parent .lens.yaml, got %q from %s", value, source)
+	}
+}
+
+func TestLoadConfig_InvalidFile(t *testing.T) {
+	_, projectDir := setupLayers(t)
+
+	writeFile(t, filepath.Join(projectDir, ProjectConfigName), "jupyter:\n  port: lots\n")
+	if _, err := LoadConfigFrom(projectDir); err == nil {
+		t.Error("Expected an invalid project config to be rejected")
+	}
+}
+
+func TestConfigKeys_EnvVar(t *testing.T) {
+	want := map[string]string{
+		"idle_timeout":                "LENS_IDLE_TIMEOUT",
+		"jupyter.default_environment": "LENS_JUPYTER_DEFAULT_ENVIRONMENT",
+		"hooks.on_launch_failed":      "LENS_HOOKS_ON_LAUNCH_FAILED",
+	}
+
+	found := 0
+	for _, key := range ConfigKeys() {
+		if envVar, ok := want[key.Name]; ok {
+			found++
+			if key.EnvVar() != envVar {
+				t.Errorf("%s.EnvVar() = %s, want %s", key.Name, key.EnvVar(), envVar)
+			}
+		}
+	}
+	if found != len(want) {
+		t.Errorf("Expected %d keys, found %d", len(want), found)
+	}
+}
+
+func TestMigrateFromLegacy_MovesUserConfig(t *testing.T) {
+	setupLayers(t)
+
+	writeFile(t, GetLegacyUserConfigPath(), "default_region: ap-southeast-2\n")
+	// The unified directory already exists, so only the config is migrated
+	writeFile(t, filepath.Join(GetConfigDir(), "state.json"), `{"instances":{}}`)
+
+	if err := MigrateFromLegacy(); err != nil {
+		t.Fatalf("MigrateFromLegacy failed: %v", err)
+	}
+
+	cfg, err := LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig failed: %v", err)
+	}
+	if cfg.DefaultRegion != "ap-southeast-2" {
+		t.Errorf("Expected migrated default_region, got %q", cfg.DefaultRegion)
+	}
+
+	// An existing config is never overwritten
+	writeFile(t, GetLegacyUserConfigPath(), "default_region: us-west-2\n")
+	if err := MigrateFromLegacy(); err != nil {
+		t.Fatalf("MigrateFromLegacy failed: %v", err)
+	}
+	if cfg, _ := LoadUserConfig(); cfg.DefaultRegion != "ap-southeast-2" {
+		t.Errorf("Expected existing config to be kept, got %q", cfg.DefaultRegion)
+	}
+}
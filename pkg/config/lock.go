@@ -0,0 +1,92 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// errLocked is returned by tryLockFile when another process holds the lock
+var errLocked = errors.New("file is locked")
+
+var (
+	// stateLockTimeout is how long UpdateState waits for another lens
+	// process to release the state lock
+	stateLockTimeout = 30 * time.Second
+
+	// stateLockPollInterval is how often a held state lock is retried
+	stateLockPollInterval = 50 * time.Millisecond
+)
+
+// lockState takes the advisory lock guarding state.json and returns a
+// function that releases it. The lock lives in a separate file because
+// state.json itself is replaced on every save.
+func lockState() (func(), error) {
+	if err := os.MkdirAll(GetConfigDir(), permConfigDir); err != nil {
+		return nil, err
+	}
+
+	lockPath := filepath.Join(GetConfigDir(), "state.json.lock")
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, permStateFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state lock: %w", err)
+	}
+
+	deadline := time.Now().Add(stateLockTimeout)
+	for {
+		err := tryLockFile(f)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errLocked) {
+			_ = f.Close()
+			return nil, fmt.Errorf("failed to lock state: %w", err)
+		}
+		if time.Now().After(deadline) {
+			_ = f.Close()
+			return nil, fmt.Errorf("timed out after %s waiting for another lens command to release %s", stateLockTimeout, lockPath)
+		}
+		time.Sleep(stateLockPollInterval)
+	}
+
+	return func() {
+		_ = unlockFile(f)
+		_ = f.Close()
+	}, nil
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory and
+// renames it over path, so readers never see a partially written file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUpdateState_ConcurrentUpdatesAreNotLost(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	const writers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- UpdateState(func(state *LocalState) error {
+				id := fmt.Sprintf("i-%02d", i)
+				state.Instances[id] = &Instance{ID: id}
+				return nil
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("UpdateState failed: %v", err)
+		}
+	}
+
+	state, err := LoadState()
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if len(state.Instances) != writers {
+		t.Errorf("Expected %d instances after concurrent updates, got %d", writers, len(state.Instances))
+	}
+}
+
+func TestUpdateState_ErrorDiscardsChanges(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if err := UpdateState(func(state *LocalState) error {
+		state.Instances["i-kept"] = &Instance{ID: "i-kept"}
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateState failed: %v", err)
+	}
+
+	errAbort := errors.New("abort")
+	err := UpdateState(func(state *LocalState) error {
+		delete(state.Instances, "i-kept")
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Expected fn error to be returned, got %v", err)
+	}
+
+	state, err := LoadState()
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if _, ok := state.Instances["i-kept"]; !ok {
+		t.Error("Changes of a failed update were saved")
+	}
+}
+
+func TestUpdateInstance_NotFound(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	err := UpdateInstance("i-missing", func(instance *Instance) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestUpdateState_TimesOutWhileLocked(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	previous := stateLockTimeout
+	stateLockTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { stateLockTimeout = previous })
+
+	unlock, err := lockState()
+	if err != nil {
+		t.Fatalf("lockState failed: %v", err)
+	}
+	defer unlock()
+
+	err = UpdateState(func(state *LocalState) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected timeout while the state is locked, got %v", err)
+	}
+}
+
+func TestSave_LeavesNoTemporaryFiles(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := EnsureConfigDir(); err != nil {
+		t.Fatalf("EnsureConfigDir failed: %v", err)
+	}
+
+	state := &LocalState{Instances: map[string]*Instance{}, KeyPairs: map[string]string{}}
+	if err := state.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(GetConfigDir())
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Errorf("Temporary file left behind: %s", entry.Name())
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(GetConfigDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != permStateFile {
+		t.Errorf("Expected state file mode %o, got %o", permStateFile, info.Mode().Perm())
+	}
+}
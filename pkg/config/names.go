@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// instanceNamePattern matches valid instance names: letters, digits, '.',
+// '_' and '-', starting with a letter or digit
+var instanceNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,62}$`)
+
+// ValidateInstanceName checks that name can be used as a friendly instance name.
+// Names that look like instance IDs are rejected so references stay unambiguous.
+func ValidateInstanceName(name string) error {
+	if !instanceNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid instance name %q: use up to 63 letters, digits, '.', '_' or '-', starting with a letter or digit", name)
+	}
+	if strings.HasPrefix(name, "i-") {
+		return fmt.Errorf("invalid instance name %q: names must not start with \"i-\"", name)
+	}
+	return nil
+}
+
+// CheckNameAvailable returns an error if another tracked instance than
+// exceptID already uses name
+func (s *LocalState) CheckNameAvailable(name, exceptID string) error {
+	for id, instance := range s.Instances {
+		if id != exceptID && instance.Name == name {
+			return fmt.Errorf("name %q is already used by instance %s", name, id)
+		}
+	}
+	return nil
+}
+
+// DisplayName returns "name (id)" for named instances and the ID otherwise
+func (i *Instance) DisplayName() string {
+	if i.Name == "" {
+		return i.ID
+	}
+	return fmt.Sprintf("%s (%s)", i.Name, i.ID)
+}
+
+// FindInstance resolves a reference to a tracked instance. The reference may
+// be an instance ID, a name, or a prefix of either that matches exactly one
+// instance.
+func (s *LocalState) FindInstance(ref string) (*Instance, error) {
+	if ref == "" {
+		return nil, fmt.Errorf("no instance specified")
+	}
+	if instance, ok := s.Instances[ref]; ok {
+		return instance, nil
+	}
+	for _, instance := range s.Instances {
+		if instance.Name == ref {
+			return instance, nil
+		}
+	}
+
+	var matches []*Instance
+	for _, instance := range s.Instances {
+		if strings.HasPrefix(instance.ID, ref) || (instance.Name != "" && strings.HasPrefix(instance.Name, ref)) {
+			matches = append(matches, instance)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("instance %s not found in local state", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		names := make([]string, len(matches))
+		for i, match := range matches {
+			names[i] = match.DisplayName()
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("%q matches %d instances: %s", ref, len(matches), strings.Join(names, ", "))
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func namedState() *LocalState {
+	return &LocalState{Instances: map[string]*Instance{
+		"i-0abc111": {ID: "i-0abc111", Name: "thesis-analysis"},
+		"i-0abc222": {ID: "i-0abc222", Name: "thesis-draft"},
+		"i-0def333": {ID: "i-0def333"},
+	}}
+}
+
+func TestFindInstance(t *testing.T) {
+	state := namedState()
+
+	tests := []struct {
+		ref     string
+		wantID  string
+		wantErr string
+	}{
+		{ref: "i-0abc111", wantID: "i-0abc111"},
+		{ref: "thesis-draft", wantID: "i-0abc222"},
+		{ref: "i-0d", wantID: "i-0def333"},
+		{ref: "thesis-a", wantID: "i-0abc111"},
+		{ref: "thesis", wantErr: "matches 2 instances"},
+		{ref: "i-0abc", wantErr: "matches 2 instances"},
+		{ref: "nope", wantErr: "not found"},
+		{ref: "", wantErr: "no instance specified"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			instance, err := state.FindInstance(tt.ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("FindInstance(%q) error = %v, want %q", tt.ref, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FindInstance(%q) failed: %v", tt.ref, err)
+			}
+			if instance.ID != tt.wantID {
+				t.Errorf("FindInstance(%q) = %s, want %s", tt.ref, instance.ID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestFindInstance_ExactNameWinsOverPrefix(t *testing.T) {
+	state := namedState()
+	state.Instances["i-0abc444"] = &Instance{ID: "i-0abc444", Name: "thesis"}
+
+	instance, err := state.FindInstance("thesis")
+	if err != nil {
+		t.Fatalf("FindInstance failed: %v", err)
+	}
+	if instance.ID != "i-0abc444" {
+		t.Errorf("Expected exact name match i-0abc444, got %s", instance.ID)
+	}
+}
+
+func TestValidateInstanceName(t *testing.T) {
+	valid := []string{"thesis-analysis", "run_2", "v1.2", "A"}
+	for _, name := range valid {
+		if err := ValidateInstanceName(name); err != nil {
+			t.Errorf("ValidateInstanceName(%q) failed: %v", name, err)
+		}
+	}
+
+	invalid := []string{"", "-leading", "has space", "i-0abc", strings.Repeat("x", 64)}
+	for _, name := range invalid {
+		if err := ValidateInstanceName(name); err == nil {
+			t.Errorf("ValidateInstanceName(%q) should fail", name)
+		}
+	}
+}
+
+func TestCheckNameAvailable(t *testing.T) {
+	state := namedState()
+
+	if err := state.CheckNameAvailable("thesis-analysis", ""); err == nil {
+		t.Error("Expected name used by another instance to be unavailable")
+	}
+	if err := state.CheckNameAvailable("thesis-analysis", "i-0abc111"); err != nil {
+		t.Errorf("Expected an instance's own name to be available to it: %v", err)
+	}
+	if err := state.CheckNameAvailable("new-name", ""); err != nil {
+		t.Errorf("Expected unused name to be available: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"time"
+
+	"github.com/scttfrdmn/lens/pkg/schedule"
+)
+
+// Schedule is the start/stop schedule of an instance, as cron expressions in
+// a time zone
+type Schedule struct {
+	Start    string    `json:"start,omitempty"` // e.g. "0 8 * * 1-5"
+	Stop     string    `json:"stop,omitempty"`  // e.g. "0 18 * * 1-5"
+	Timezone string    `json:"timezone"`        // IANA name, e.g. "America/New_York"
+	Since    time.Time `json:"since"`           // When the schedule was set
+}
+
+// Parse parses the schedule's expressions
+func (s *Schedule) Parse() (*schedule.Schedule, error) {
+	return schedule.New(s.Start, s.Stop, s.Timezone)
+}
+
+// ScheduledStateChanges returns the starts and stops the instance's schedule
+// made from when it was set until a time. They are inferred: EventBridge
+// Scheduler does not report whether they succeeded, and changes observed in
+// CloudTrail or EC2 replace them when merged.
+func (i *Instance) ScheduledStateChanges(until time.Time) []StateChange {
+	if i.Schedule == nil {
+		return nil
+	}
+	parsed, err := i.Schedule.Parse()
+	if err != nil {
+		return nil
+	}
+	transitions := parsed.Transitions(i.Schedule.Since, until)
+	changes := make([]StateChange, len(transitions))
+	for j, transition := range transitions {
+		changes[j] = StateChange{
+			State:     transition.State,
+			Timestamp: transition.At.UTC(),
+			Source:    StateSourceSchedule,
+			Inferred:  true,
+		}
+	}
+	return changes
+}
+
+// ScheduledHoursPerWeek returns how many hours a week the instance's schedule
+// keeps it running, if it has a schedule with both a start and a stop
+func (i *Instance) ScheduledHoursPerWeek() (float64, bool) {
+	if i.Schedule == nil {
+		return 0, false
+	}
+	parsed, err := i.Schedule.Parse()
+	if err != nil {
+		return 0, false
+	}
+	return parsed.HoursPerWeek()
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"time"
@@ -17,11 +18,24 @@ const (
 type StateChange struct {
 	State     string    `json:"state"` // "running", "stopped", "terminated"
 	Timestamp time.Time `json:"timestamp"`
+
+	// Source is where a backfilled change came from: StateSourceEC2 or
+	// StateSourceCloudTrail. Empty for changes lens recorded as it made them.
+	Source string `json:"source,omitempty"`
+	// Inferred changes were derived after the fact, so their time may be
+	// approximate; the others were observed when they happened
+	Inferred bool `json:"inferred,omitempty"`
+	// ResizedFrom is set on a stop after which the instance type was
+	// changed: the type it had before, at which running time until this
+	// change is priced
+	ResizedFrom string `json:"resized_from,omitempty"`
 }
 
 // Instance represents a tracked EC2 instance with its metadata
 type Instance struct {
 	ID            string        `json:"id"`
+	Name          string        `json:"name,omitempty"` // Unique friendly name, also the EC2 Name tag
+	App           string        `json:"app,omitempty"`  // CLI that launched the instance, e.g. "lens-jupyter"
 	Environment   string        `json:"environment"`
 	InstanceType  string        `json:"instance_type"`
 	PublicIP      string        `json:"public_ip"`
@@ -35,6 +49,15 @@ type Instance struct {
 	S3Bucket      string        `json:"s3_bucket,omitempty"`     // S3 bucket for data sync
 	S3MountPath   string        `json:"s3_mount_path,omitempty"` // Local path where S3 is mounted
 	EBSSize       int           `json:"ebs_size,omitempty"`      // EBS volume size in GB
+	Owner         string        `json:"owner,omitempty"`         // ARN of the principal that launched the instance
+	Project       string        `json:"project,omitempty"`       // Project the instance is charged to, also the lens:project tag
+	PendingType   string        `json:"pending_type,omitempty"`  // Instance type to change to on the next stop
+	DataVolume    string        `json:"data_volume,omitempty"`   // Name of the persistent data volume attached at launch
+	IdleWarning   *time.Time    `json:"idle_warning,omitempty"`  // Auto-stop time the idle_warning hook last fired for
+	IdleAlarm     string        `json:"idle_alarm,omitempty"`    // CloudWatch alarm that stops the instance if lens-agent does not
+	Schedule      *Schedule     `json:"schedule,omitempty"`      // Start/stop schedule set with the schedule command
+	ExpiresAt     *time.Time    `json:"expires_at,omitempty"`    // When the instance's TTL runs out, also the lens:expires-at tag
+	TTLWarning    *time.Time    `json:"ttl_warning,omitempty"`   // Expiry the ttl_warning hook last fired for
 	StateChanges  []StateChange `json:"state_changes,omitempty"` // History of state changes for cost tracking
 }
 
@@ -42,6 +65,9 @@ type Instance struct {
 type LocalState struct {
 	Instances map[string]*Instance `json:"instances"`
 	KeyPairs  map[string]string    `json:"key_pairs"` // name -> private key path
+
+	// Terminated instances are kept with their history for cost reports
+	Terminated []*Instance `json:"terminated,omitempty"`
 }
 
 // GetConfigDir returns the path to the lens configuration directory
@@ -66,22 +92,60 @@ func EnsureConfigDir() error {
 	return os.MkdirAll(filepath.Join(configDir, "environments"), permConfigDir)
 }
 
-// LoadState loads the local state file or creates a new one if it doesn't exist
+// LoadState loads the state from the configured backend, or an empty state
+// if none has been saved yet
 func LoadState() (*LocalState, error) {
-	statePath := filepath.Join(GetConfigDir(), "state.json")
+	backend, err := currentStateBackend()
+	if err != nil {
+		return nil, err
+	}
+	return backend.Load()
+}
 
-	if _, err := os.Stat(statePath); os.IsNotExist(err) {
-		return &LocalState{
-			Instances: make(map[string]*Instance),
-			KeyPairs:  make(map[string]string),
-		}, nil
+// Save writes the state to the configured backend, replacing what is stored
+// there. Save does not guard against concurrent read-modify-write cycles;
+// commands that change state use UpdateState.
+func (s *LocalState) Save() error {
+	backend, err := currentStateBackend()
+	if err != nil {
+		return err
 	}
+	return backend.Save(s)
+}
 
-	data, err := os.ReadFile(statePath)
+// UpdateState loads the state, applies fn and saves the result without
+// losing changes made concurrently by other lens processes. The state is not
+// saved if fn returns an error. fn may be called more than once when the
+// backend retries after a conflicting write, so it must only change state.
+func UpdateState(fn func(*LocalState) error) error {
+	backend, err := currentStateBackend()
 	if err != nil {
-		return nil, err
+		return err
 	}
+	return backend.Update(fn)
+}
 
+// UpdateInstance applies fn to one tracked instance within UpdateState
+func UpdateInstance(instanceID string, fn func(*Instance) error) error {
+	return UpdateState(func(state *LocalState) error {
+		instance, exists := state.Instances[instanceID]
+		if !exists {
+			return fmt.Errorf("instance %s not found in local state", instanceID)
+		}
+		return fn(instance)
+	})
+}
+
+// newLocalState returns an empty state
+func newLocalState() *LocalState {
+	return &LocalState{
+		Instances: make(map[string]*Instance),
+		KeyPairs:  make(map[string]string),
+	}
+}
+
+// decodeState parses a serialised state
+func decodeState(data []byte) (*LocalState, error) {
 	var state LocalState
 	if err := json.Unmarshal(data, &state); err != nil {
 		return nil, err
@@ -97,18 +161,32 @@ func LoadState() (*LocalState, error) {
 	return &state, nil
 }
 
-// Save writes the current state to the local state file
-func (s *LocalState) Save() error {
-	statePath := filepath.Join(GetConfigDir(), "state.json")
-	data, err := json.MarshalIndent(s, "", "  ")
-	if err != nil {
-		return err
+// RecordStateChange records a state change for an instance
+func (i *Instance) RecordStateChange(state string) {
+	i.recordStateChangeAt(state, time.Now())
+}
+
+// RecordTypeChange changes the type of the stopped instance, recording the
+// old type so the time it ran before the change keeps its price
+func (i *Instance) RecordTypeChange(instanceType string) {
+	if instanceType == i.InstanceType {
+		return
 	}
-	return os.WriteFile(statePath, data, permStateFile)
+	if n := len(i.StateChanges); n > 0 && i.StateChanges[n-1].State == "stopped" && i.StateChanges[n-1].ResizedFrom == "" {
+		i.StateChanges[n-1].ResizedFrom = i.InstanceType
+	} else {
+		// The stop was not recorded, or the type already changed since
+		i.StateChanges = append(i.StateChanges, StateChange{
+			State:       "stopped",
+			Timestamp:   time.Now(),
+			ResizedFrom: i.InstanceType,
+		})
+	}
+	i.InstanceType = instanceType
 }
 
-// RecordStateChange records a state change for an instance
-func (i *Instance) RecordStateChange(state string) {
+// recordStateChangeAt records a state change that happened at the given time
+func (i *Instance) recordStateChangeAt(state string, at time.Time) {
 	// Don't record duplicate state changes
 	if len(i.StateChanges) > 0 {
 		lastState := i.StateChanges[len(i.StateChanges)-1].State
@@ -119,13 +197,35 @@ func (i *Instance) RecordStateChange(state string) {
 
 	i.StateChanges = append(i.StateChanges, StateChange{
 		State:     state,
-		Timestamp: time.Now(),
+		Timestamp: at,
 	})
 }
 
+// RecordTermination moves a tracked instance to the terminated history,
+// recording that it was terminated at the given time
+func (s *LocalState) RecordTermination(instanceID string, at time.Time) {
+	instance, ok := s.Instances[instanceID]
+	if !ok {
+		return
+	}
+	delete(s.Instances, instanceID)
+	instance.TunnelPID = 0
+	instance.recordStateChangeAt("terminated", at)
+	s.Terminated = append(s.Terminated, instance)
+}
+
 // MigrateFromLegacy migrates config from legacy aws-* directories to unified .lens directory
 // This function is safe to call multiple times - it will only migrate once
 func MigrateFromLegacy() error {
+	if err := migrateLegacyDirs(); err != nil {
+		return err
+	}
+	return migrateLegacyUserConfig()
+}
+
+// migrateLegacyDirs copies state and environments from the first legacy
+// per-app directory found, unless the unified directory already exists
+func migrateLegacyDirs() error {
 	newConfigDir := GetConfigDir()
 	
 	// If new config already exists, no migration needed
@@ -190,3 +290,28 @@ func MigrateFromLegacy() error {
 	
 	return nil
 }
+
+// migrateLegacyUserConfig copies the user config from ~/.aws-ide into the lens
+// configuration directory unless one already exists there
+func migrateLegacyUserConfig() error {
+	newPath := GetUserConfigPath()
+	if _, err := os.Stat(newPath); err == nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(GetLegacyUserConfigPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read legacy config file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newPath), permConfigDir); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(newPath, data, permStateFile); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
@@ -604,3 +604,31 @@ func TestStateChange_EmptyStateString(t *testing.T) {
 		t.Errorf("Expected empty state, got '%s'", instance.StateChanges[0].State)
 	}
 }
+
+func TestRecordTermination_KeepsHistory(t *testing.T) {
+	launchedAt := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	state := &LocalState{
+		Instances: map[string]*Instance{
+			"i-test123": {ID: "i-test123", LaunchedAt: launchedAt, TunnelPID: 4242},
+		},
+	}
+
+	terminatedAt := launchedAt.Add(48 * time.Hour)
+	state.RecordTermination("i-test123", terminatedAt)
+	state.RecordTermination("i-missing", terminatedAt)
+
+	if len(state.Instances) != 0 {
+		t.Errorf("Expected the instance to be untracked, got %v", state.Instances)
+	}
+	if len(state.Terminated) != 1 {
+		t.Fatalf("Expected 1 terminated instance, got %d", len(state.Terminated))
+	}
+	instance := state.Terminated[0]
+	if instance.TunnelPID != 0 {
+		t.Errorf("Expected tunnel PID to be reset, got %d", instance.TunnelPID)
+	}
+	want := StateChange{State: "terminated", Timestamp: terminatedAt}
+	if n := len(instance.StateChanges); n == 0 || instance.StateChanges[n-1] != want {
+		t.Errorf("Expected last state change %+v, got %+v", want, instance.StateChanges)
+	}
+}
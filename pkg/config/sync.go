@@ -0,0 +1,131 @@
+package config
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/lens/pkg/aws"
+)
+
+// SyncResult summarises the changes made to local state by Reconcile
+type SyncResult struct {
+	Imported []string // Instances found in AWS that were not tracked locally
+	Updated  []string // Tracked instances refreshed from AWS
+	Removed  []string // Tracked instances that no longer exist in AWS
+
+	Backfilled []string // Tracked instances whose state history gained changes it had missed
+}
+
+// Reconcile brings the tracked instances of one region in line with the lens
+// instances that exist there. instances must be every live lens instance in
+// the region: tracked instances missing from it are removed.
+func (s *LocalState) Reconcile(region string, instances []types.Instance, now time.Time) SyncResult {
+	var result SyncResult
+
+	found := make(map[string]bool)
+	for _, ec2Instance := range instances {
+		id := *ec2Instance.InstanceId
+		found[id] = true
+
+		if existing, ok := s.Instances[id]; ok {
+			if existing.refresh(region, ec2Instance, now) > 0 {
+				result.Backfilled = append(result.Backfilled, id)
+			}
+			result.Updated = append(result.Updated, id)
+			continue
+		}
+
+		imported := &Instance{ID: id}
+		if ec2Instance.LaunchTime != nil {
+			// The launch time is that of the last start; earlier history is unknown
+			imported.LaunchedAt = *ec2Instance.LaunchTime
+		}
+		imported.refresh(region, ec2Instance, now)
+		s.Instances[id] = imported
+		result.Imported = append(result.Imported, id)
+	}
+
+	for id, instance := range s.Instances {
+		if instance.Region == region && !found[id] {
+			// Terminated at some point since it was last seen
+			s.RecordTermination(id, now)
+			result.Removed = append(result.Removed, id)
+		}
+	}
+
+	return result
+}
+
+// refresh updates the instance from its EC2 description and lens tags, and
+// returns the number of state changes added to its history. Tag values only
+// replace local values when set, so state recorded before tags were written
+// is kept.
+func (i *Instance) refresh(region string, ec2Instance types.Instance, now time.Time) int {
+	metadata := aws.ParseInstanceMetadata(ec2Instance.Tags)
+
+	i.Region = region
+	i.InstanceType = string(ec2Instance.InstanceType)
+	i.PublicIP = ""
+	if ec2Instance.PublicIpAddress != nil {
+		i.PublicIP = *ec2Instance.PublicIpAddress
+	}
+	if len(ec2Instance.SecurityGroups) > 0 && ec2Instance.SecurityGroups[0].GroupId != nil {
+		i.SecurityGroup = *ec2Instance.SecurityGroups[0].GroupId
+	}
+	if ec2Instance.KeyName != nil {
+		i.KeyPair = *ec2Instance.KeyName
+	}
+
+	setIfSet := func(dst *string, value string) {
+		if value != "" {
+			*dst = value
+		}
+	}
+	setIfSet(&i.Name, metadata.Name)
+	setIfSet(&i.App, metadata.App)
+	setIfSet(&i.Environment, metadata.Environment)
+	setIfSet(&i.IdleTimeout, metadata.IdleTimeout)
+	setIfSet(&i.KeyPair, metadata.KeyPair)
+	setIfSet(&i.AMIBase, metadata.AMIBase)
+	setIfSet(&i.S3Bucket, metadata.S3Bucket)
+	setIfSet(&i.S3MountPath, metadata.S3SyncPath)
+	setIfSet(&i.Owner, metadata.Owner)
+	setIfSet(&i.Project, metadata.Project)
+	setIfSet(&i.DataVolume, metadata.DataVolume)
+	setIfSet(&i.IdleAlarm, metadata.IdleAlarm)
+	if metadata.EBSSize > 0 {
+		i.EBSSize = metadata.EBSSize
+	}
+	if !metadata.ExpiresAt.IsZero() {
+		expiresAt := metadata.ExpiresAt
+		i.ExpiresAt = &expiresAt
+	}
+
+	return i.RefreshState(ec2Instance, now)
+}
+
+// RefreshState adds the state changes that lens did not record, such as
+// stops made on the instance or by its schedule, to the history, inferring
+// them from the EC2 description and the schedule, and returns how many were
+// added. When neither says when the instance reached its current state, now
+// is used.
+func (i *Instance) RefreshState(ec2Instance types.Instance, now time.Time) int {
+	changes := append(InferStateChanges(ec2Instance), i.ScheduledStateChanges(now)...)
+	sort.SliceStable(changes, func(a, b int) bool {
+		return changes[a].Timestamp.Before(changes[b].Timestamp)
+	})
+	if ec2Instance.State != nil {
+		current := ""
+		switch ec2Instance.State.Name {
+		case types.InstanceStateNamePending, types.InstanceStateNameRunning:
+			current = "running"
+		case types.InstanceStateNameStopping, types.InstanceStateNameStopped:
+			current = "stopped"
+		}
+		if current != "" && (len(changes) == 0 || changes[len(changes)-1].State != current) {
+			changes = append(changes, StateChange{State: current, Timestamp: now, Source: StateSourceEC2, Inferred: true})
+		}
+	}
+	return i.MergeStateChanges(changes)
+}
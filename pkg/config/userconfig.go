@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 
 	"gopkg.in/yaml.v3"
 )
@@ -14,6 +15,11 @@ type UserConfig struct {
 	DefaultRegion  string `yaml:"default_region,omitempty"`
 	DefaultProfile string `yaml:"default_profile,omitempty"`
 
+	// State storage: "local" (default) or s3://bucket/prefix to share state with a team
+	StateBackend  string `yaml:"state_backend,omitempty"`
+	StateRegion   string `yaml:"state_region,omitempty"`   // Region of the state bucket
+	StateEndpoint string `yaml:"state_endpoint,omitempty"` // Endpoint of an S3-compatible store
+
 	// Instance defaults
 	DefaultInstanceType string `yaml:"default_instance_type,omitempty"`
 	DefaultEBSSize      int    `yaml:"default_ebs_size,omitempty"`
@@ -23,14 +29,27 @@ type UserConfig struct {
 	DefaultSubnetType string `yaml:"default_subnet_type,omitempty"` // "public" or "private"
 	PreferIPv6        bool   `yaml:"prefer_ipv6,omitempty"`
 
+	// Project tagged on launched instances, usually set in .lens.yaml
+	DefaultProject string `yaml:"default_project,omitempty"`
+
 	// Behavior settings
 	IdleTimeout        string `yaml:"idle_timeout,omitempty"`
-	AutoTerminate      bool   `yaml:"auto_terminate,omitempty"`
+	IdleWarning        string `yaml:"idle_warning,omitempty"`        // How long before an idle auto-stop the idle_warning hook fires, e.g. "15m"
+	IdleAlarm          bool   `yaml:"idle_alarm,omitempty"`          // Also create a CloudWatch alarm that stops idle instances
+	AutoTerminate      bool   `yaml:"auto_terminate,omitempty"`      // Let reap terminate instances whose TTL ran out; otherwise it stops them
+	DefaultTTL         string `yaml:"default_ttl,omitempty"`         // Maximum lifetime of launched instances, e.g. "30d"; none if empty
+	TTLWarning         string `yaml:"ttl_warning,omitempty"`         // How long before a TTL runs out the ttl_warning hook fires, e.g. "48h"
+	TTLPolicy          string `yaml:"ttl_policy,omitempty"`          // What reap does with an expired instance: "terminate" or "snapshot" (then terminate)
 	ConfirmDestructive bool   `yaml:"confirm_destructive,omitempty"` // Confirm before terminate/delete
 
 	// Cost tracking
 	EnableCostTracking bool    `yaml:"enable_cost_tracking,omitempty"`
 	CostAlertThreshold float64 `yaml:"cost_alert_threshold,omitempty"` // Alert when monthly cost exceeds this
+	PricingSource      string  `yaml:"pricing_source,omitempty"`       // "live" (AWS Price List) or "offline" (bundled snapshot)
+	PricingCacheTTL    string  `yaml:"pricing_cache_ttl,omitempty"`    // How long live prices are cached, e.g. "168h"
+
+	// Budgets enforced at launch and by "budget check"
+	Budgets []Budget `yaml:"budgets,omitempty"`
 
 	// App-specific settings
 	Jupyter *AppConfig `yaml:"jupyter,omitempty"`
@@ -41,6 +60,19 @@ type UserConfig struct {
 	Hooks *HooksConfig `yaml:"hooks,omitempty"`
 }
 
+// Pricing sources
+const (
+	PricingSourceLive    = "live"    // AWS Price List API, cached, falling back to the snapshot
+	PricingSourceOffline = "offline" // Bundled price snapshot only
+)
+
+// TTL policies: what reap does with an instance whose TTL ran out when
+// auto_terminate is set
+const (
+	TTLPolicyTerminate = "terminate" // Terminate the instance
+	TTLPolicySnapshot  = "snapshot"  // Snapshot its volumes, then terminate it
+)
+
 // HooksConfig contains notification hook commands
 type HooksConfig struct {
 	OnLaunchStarted   string `yaml:"on_launch_started,omitempty"`
@@ -51,6 +83,10 @@ type HooksConfig struct {
 	OnStopFailed      string `yaml:"on_stop_failed,omitempty"`
 	OnConnectStarted  string `yaml:"on_connect_started,omitempty"`
 	OnConnectFailed   string `yaml:"on_connect_failed,omitempty"`
+	OnBudgetExceeded  string `yaml:"on_budget_exceeded,omitempty"`
+	OnIdleWarning     string `yaml:"on_idle_warning,omitempty"`
+	OnTTLWarning      string `yaml:"on_ttl_warning,omitempty"`
+	OnTTLExpired      string `yaml:"on_ttl_expired,omitempty"`
 }
 
 // AppConfig contains app-specific configuration
@@ -63,12 +99,20 @@ type AppConfig struct {
 
 // GetUserConfigPath returns the path to the user config file
 func GetUserConfigPath() string {
+	return filepath.Join(GetConfigDir(), "config.yaml")
+}
+
+// GetLegacyUserConfigPath returns the path of the user config file used
+// before it moved into the lens configuration directory
+func GetLegacyUserConfigPath() string {
 	home, _ := os.UserHomeDir()
 	return filepath.Join(home, ".aws-ide", "config.yaml")
 }
 
 // LoadUserConfig loads the user configuration file
-// Returns default config if file doesn't exist
+// Returns default config if file doesn't exist.
+// Use LoadConfig for the effective configuration of all layers; this only
+// reads the file edited by "config set".
 func LoadUserConfig() (*UserConfig, error) {
 	configPath := GetUserConfigPath()
 
@@ -94,6 +138,8 @@ func LoadUserConfig() (*UserConfig, error) {
 }
 
 // SaveUserConfig saves the user configuration to file
+// Values equal to the built-in defaults are left out, so they keep resolving
+// from the default layer instead of overriding the system config.
 func SaveUserConfig(config *UserConfig) error {
 	configPath := GetUserConfigPath()
 
@@ -103,14 +149,14 @@ func SaveUserConfig(config *UserConfig) error {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	data, err := yaml.Marshal(config)
+	data, err := yaml.Marshal(withoutDefaults(config))
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
 	// Add header comment
-	header := `# aws-ide User Configuration
-# This file contains default settings for all aws-ide tools
+	header := `# lens User Configuration
+# This file contains default settings for all lens tools
 # Edit this file to customize your preferences
 
 `
@@ -124,11 +170,47 @@ func SaveUserConfig(config *UserConfig) error {
 }
 
 // InitUserConfig creates a new config file with defaults
+// Built-in defaults are not written; the file starts out empty.
 func InitUserConfig() error {
 	config := getDefaultConfig()
 	return SaveUserConfig(config)
 }
 
+// withoutDefaults returns a copy of config with every value that equals its
+// built-in default cleared, and app sections left with no values removed
+func withoutDefaults(config *UserConfig) *UserConfig {
+	stripped := *config
+	clearDefaults(reflect.ValueOf(&stripped).Elem(), reflect.ValueOf(getDefaultConfig()).Elem())
+	return &stripped
+}
+
+// clearDefaults zeroes the fields of v that equal those of defaults
+func clearDefaults(v, defaults reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field, def := v.Field(i), defaults.Field(i)
+		switch field.Kind() {
+		case reflect.Ptr:
+			if field.IsNil() || def.IsNil() || field.Elem().Kind() != reflect.Struct {
+				continue
+			}
+			section := reflect.New(field.Elem().Type())
+			section.Elem().Set(field.Elem())
+			clearDefaults(section.Elem(), def.Elem())
+			if section.Elem().IsZero() {
+				field.Set(reflect.Zero(field.Type()))
+			} else {
+				field.Set(section)
+			}
+		case reflect.Slice, reflect.Map:
+			continue
+		default:
+			if field.Interface() == def.Interface() {
+				field.Set(reflect.Zero(field.Type()))
+			}
+		}
+	}
+}
+
 // getDefaultConfig returns a config with sensible defaults
 func getDefaultConfig() *UserConfig {
 	return &UserConfig{
@@ -140,22 +222,27 @@ func getDefaultConfig() *UserConfig {
 		DefaultSubnetType:   "public",
 		PreferIPv6:          false,
 		IdleTimeout:         "4h",
+		IdleWarning:         "15m",
 		AutoTerminate:       false,
+		TTLWarning:          "48h",
+		TTLPolicy:           TTLPolicyTerminate,
 		ConfirmDestructive:  true,
 		EnableCostTracking:  true,
 		CostAlertThreshold:  100.0, // $100/month
+		PricingSource:       PricingSourceLive,
+		PricingCacheTTL:     "168h",
 		Jupyter: &AppConfig{
 			DefaultEnvironment:  "data-science",
 			DefaultInstanceType: "", // Use global default
 			Port:                8888,
 		},
 		RStudio: &AppConfig{
-			DefaultEnvironment:  "r-statistics",
+			DefaultEnvironment:  "data-science",
 			DefaultInstanceType: "", // Use global default
 			Port:                8787,
 		},
 		VSCode: &AppConfig{
-			DefaultEnvironment:  "web",
+			DefaultEnvironment:  "web-dev",
 			DefaultInstanceType: "", // Use global default
 			Port:                8080,
 		},
@@ -181,9 +268,24 @@ func applyDefaults(config *UserConfig) {
 	if config.IdleTimeout == "" {
 		config.IdleTimeout = defaults.IdleTimeout
 	}
+	if config.IdleWarning == "" {
+		config.IdleWarning = defaults.IdleWarning
+	}
+	if config.TTLWarning == "" {
+		config.TTLWarning = defaults.TTLWarning
+	}
+	if config.TTLPolicy == "" {
+		config.TTLPolicy = defaults.TTLPolicy
+	}
 	if config.CostAlertThreshold == 0 {
 		config.CostAlertThreshold = defaults.CostAlertThreshold
 	}
+	if config.PricingSource == "" {
+		config.PricingSource = defaults.PricingSource
+	}
+	if config.PricingCacheTTL == "" {
+		config.PricingCacheTTL = defaults.PricingCacheTTL
+	}
 
 	// App-specific defaults
 	if config.Jupyter == nil {
@@ -0,0 +1,94 @@
+package cost
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// cachedPrice is a price stored in the cache file
+type cachedPrice struct {
+	Price
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// CachedProvider caches the prices of another provider in a JSON file.
+// Prices older than the TTL are looked up again; if that fails, the stale
+// price is used rather than none.
+type CachedProvider struct {
+	path string
+	ttl  time.Duration
+	next PricingProvider
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cachedPrice
+}
+
+// NewCachedProvider returns a provider that caches the prices of next in the
+// file at path for ttl
+func NewCachedProvider(path string, ttl time.Duration, next PricingProvider) *CachedProvider {
+	return &CachedProvider{path: path, ttl: ttl, next: next, now: time.Now}
+}
+
+// HourlyPrice implements PricingProvider
+func (c *CachedProvider) HourlyPrice(ctx context.Context, query PriceQuery) (Price, error) {
+	query = query.normalized()
+	key := query.Region + "/" + query.OS + "/" + query.InstanceType
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.load()
+	cached, ok := c.entries[key]
+	if ok && c.now().Sub(cached.FetchedAt) < c.ttl {
+		return cached.Price, nil
+	}
+
+	price, err := c.next.HourlyPrice(ctx, query)
+	if err != nil {
+		if ok {
+			return cached.Price, nil
+		}
+		return Price{}, err
+	}
+
+	c.entries[key] = cachedPrice{Price: price, FetchedAt: c.now()}
+	if err := c.save(); err != nil {
+		fmt.Printf("Warning: Failed to save price cache: %v\n", err)
+	}
+	return price, nil
+}
+
+// load reads the cache file once. A missing or unreadable file is treated as
+// an empty cache. Callers must hold c.mu.
+func (c *CachedProvider) load() {
+	if c.entries != nil {
+		return
+	}
+	c.entries = make(map[string]cachedPrice)
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		c.entries = make(map[string]cachedPrice)
+	}
+}
+
+// save writes the cache file. Callers must hold c.mu.
+func (c *CachedProvider) save() error {
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0600)
+}
@@ -1,90 +1,76 @@
 package cost
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
 
-// InstancePricing contains hourly pricing for different instance types
-// Prices are for on-demand instances in us-east-1 (as of 2025)
-var InstancePricing = map[string]float64{
-	// Graviton (ARM) instances - t4g
-	"t4g.nano":    0.0042,
-	"t4g.micro":   0.0084,
-	"t4g.small":   0.0168,
-	"t4g.medium":  0.0336,
-	"t4g.large":   0.0672,
-	"t4g.xlarge":  0.1344,
-	"t4g.2xlarge": 0.2688,
-
-	// Graviton compute-optimized - c7g
-	"c7g.medium":   0.0363,
-	"c7g.large":    0.0725,
-	"c7g.xlarge":   0.1450,
-	"c7g.2xlarge":  0.2900,
-	"c7g.4xlarge":  0.5800,
-	"c7g.8xlarge":  1.1600,
-	"c7g.12xlarge": 1.7400,
-	"c7g.16xlarge": 2.3200,
-
-	// Graviton memory-optimized - m7g
-	"m7g.medium":   0.0408,
-	"m7g.large":    0.0816,
-	"m7g.xlarge":   0.1632,
-	"m7g.2xlarge":  0.3264,
-	"m7g.4xlarge":  0.6528,
-	"m7g.8xlarge":  1.3056,
-	"m7g.12xlarge": 1.9584,
-	"m7g.16xlarge": 2.6112,
-
-	// Graviton memory-optimized - r7g
-	"r7g.medium":   0.0504,
-	"r7g.large":    0.1008,
-	"r7g.xlarge":   0.2016,
-	"r7g.2xlarge":  0.4032,
-	"r7g.4xlarge":  0.8064,
-	"r7g.8xlarge":  1.6128,
-	"r7g.12xlarge": 2.4192,
-	"r7g.16xlarge": 3.2256,
-
-	// x86 instances for comparison - t3
-	"t3.nano":    0.0052,
-	"t3.micro":   0.0104,
-	"t3.small":   0.0208,
-	"t3.medium":  0.0416,
-	"t3.large":   0.0832,
-	"t3.xlarge":  0.1664,
-	"t3.2xlarge": 0.3328,
-
-	// x86 instances for comparison - m6i
-	"m6i.large":    0.0960,
-	"m6i.xlarge":   0.1920,
-	"m6i.2xlarge":  0.3840,
-	"m6i.4xlarge":  0.7680,
-	"m6i.8xlarge":  1.5360,
-	"m6i.12xlarge": 2.3040,
-	"m6i.16xlarge": 3.0720,
-	"m6i.24xlarge": 4.6080,
-	"m6i.32xlarge": 6.1440,
-}
-
 // EBS pricing per GB-month (gp3)
 const EBSPricePerGBMonth = 0.08
 
+// Pricing for resources that are billed for as long as they exist (us-east-1)
+const (
+	// HoursPerMonth is the number of hours used for monthly estimates (30 days)
+	HoursPerMonth = 24.0 * 30.0
+
+	// NATGatewayPricePerHour is the hourly charge of a NAT Gateway, excluding data processing
+	NATGatewayPricePerHour = 0.045
+
+	// PublicIPv4PricePerHour is the hourly charge of a public IPv4 address, including Elastic IPs
+	PublicIPv4PricePerHour = 0.005
+
+	// EBSSnapshotPricePerGBMonth is the standard-tier snapshot storage price
+	EBSSnapshotPricePerGBMonth = 0.05
+
+	// NATGatewayPricePerGB is the data processing charge of a NAT Gateway
+	NATGatewayPricePerGB = 0.045
+
+	// DataTransferOutPricePerGB is the charge for data sent to the internet
+	// (first 10 TB/month tier)
+	DataTransferOutPricePerGB = 0.09
+)
+
 // StateChange represents a change in instance state
 type StateChange struct {
 	State     string // "running", "stopped", "terminated"
 	Timestamp time.Time
+
+	// ResizedFrom is the instance type the instance had until this change
+	// when its type was changed after it; running time before it is priced
+	// at that type
+	ResizedFrom string
 }
 
 // CostCalculation contains cost breakdown for an instance
 type CostCalculation struct {
 	InstanceType string
+	Region       string
+	OS           string
 	LaunchedAt   time.Time
 	StateChanges []StateChange
 	CurrentState string
 	EBSSize      int // GB
 
+	// Price is the on-demand price used for compute. Compute costs of an
+	// unknown price are $0; check Price.Note before presenting them.
+	Price      Price
+	HourlyRate float64 // Price.Hourly
+
+	// PastPrices are the prices of the types the instance had before it was
+	// resized, by type. Running time before a resize is priced at them.
+	PastPrices map[string]Price
+
+	// Resources are charges beyond compute and the root volume, such as this
+	// instance's share of a NAT Gateway. They are added by the caller.
+	Resources []ResourceCost
+
+	// ScheduledHoursPerWeek is how long a start/stop schedule keeps the
+	// instance running each week. When Scheduled, monthly estimates use it in
+	// place of the usage so far. It is set by the caller.
+	Scheduled             bool
+	ScheduledHoursPerWeek float64
+
 	// Computed costs
 	TotalRunningHours    float64 // Actual hours in "running" state
 	TotalElapsedHours    float64 // Total hours since launch
@@ -97,26 +83,40 @@ type CostCalculation struct {
 	OnPremComparison string // Comparison to on-premise hardware
 }
 
-// CalculateCost computes the cost breakdown for an instance
-func CalculateCost(instanceType string, launchedAt time.Time, stateChanges []StateChange, ebsSize int) *CostCalculation {
+// CalculateCost computes the cost breakdown for an instance, priced for the
+// query's instance type, region and OS by the current PricingProvider
+func CalculateCost(query PriceQuery, launchedAt time.Time, stateChanges []StateChange, ebsSize int) *CostCalculation {
+	query = query.normalized()
+	price := LookupPrice(context.Background(), query)
+
 	calc := &CostCalculation{
-		InstanceType: instanceType,
+		InstanceType: query.InstanceType,
+		Region:       query.Region,
+		OS:           query.OS,
 		LaunchedAt:   launchedAt,
 		StateChanges: stateChanges,
 		EBSSize:      ebsSize,
-	}
-
-	// Get hourly rate
-	hourlyRate, exists := InstancePricing[instanceType]
-	if !exists {
-		// Default to t4g.medium if unknown
-		hourlyRate = InstancePricing["t4g.medium"]
+		Price:        price,
+		HourlyRate:   price.Hourly,
 	}
 
 	// Calculate total elapsed time
 	now := time.Now()
 	calc.TotalElapsedHours = now.Sub(launchedAt).Hours()
 
+	// Price the types the instance had before it was resized
+	for _, change := range stateChanges {
+		if change.ResizedFrom == "" || change.ResizedFrom == query.InstanceType {
+			continue
+		}
+		if calc.PastPrices == nil {
+			calc.PastPrices = make(map[string]Price)
+		}
+		past := query
+		past.InstanceType = change.ResizedFrom
+		calc.PastPrices[change.ResizedFrom] = LookupPrice(context.Background(), past)
+	}
+
 	// Calculate running hours by processing state changes
 	calc.TotalRunningHours = calculateRunningHours(launchedAt, stateChanges, now)
 
@@ -127,8 +127,9 @@ func CalculateCost(instanceType string, launchedAt time.Time, stateChanges []Sta
 		calc.CurrentState = "running"
 	}
 
-	// Calculate compute cost (only charged when running)
-	calc.ComputeCost = calc.TotalRunningHours * hourlyRate
+	// Calculate compute cost (only charged when running), at the type the
+	// instance had at the time
+	_, calc.ComputeCost = calc.runningBetween(launchedAt, now)
 
 	// Calculate storage cost (charged for all elapsed time)
 	storageHours := calc.TotalElapsedHours
@@ -143,7 +144,7 @@ func CalculateCost(instanceType string, launchedAt time.Time, stateChanges []Sta
 	}
 
 	// Generate on-prem comparison
-	calc.OnPremComparison = generateOnPremComparison(calc.TotalCost, calc.TotalElapsedHours, instanceType)
+	calc.OnPremComparison = generateOnPremComparison(calc.TotalCost, calc.TotalElapsedHours, calc.InstanceType)
 
 	return calc
 }
@@ -177,6 +178,111 @@ func calculateRunningHours(launchedAt time.Time, stateChanges []StateChange, now
 	return totalRunningHours
 }
 
+// SpendBetween returns the compute and storage cost incurred between from
+// and to, e.g. this month's share of an instance launched earlier
+func (c *CostCalculation) SpendBetween(from, to time.Time) float64 {
+	usage := c.UsageBetween(from, to)
+	return usage.ComputeCost + usage.StorageCost
+}
+
+// Usage is what an instance ran and cost in a period
+type Usage struct {
+	RunningHours float64
+	ComputeCost  float64
+	StorageCost  float64
+}
+
+// UsageBetween returns the running hours and costs incurred between from and
+// to. Storage is charged from launch until the instance is terminated.
+func (c *CostCalculation) UsageBetween(from, to time.Time) Usage {
+	if from.Before(c.LaunchedAt) {
+		from = c.LaunchedAt
+	}
+	if n := len(c.StateChanges); n > 0 && c.StateChanges[n-1].State == "terminated" && c.StateChanges[n-1].Timestamp.Before(to) {
+		to = c.StateChanges[n-1].Timestamp
+	}
+	if !from.Before(to) {
+		return Usage{}
+	}
+	running, compute := c.runningBetween(from, to)
+	return Usage{
+		RunningHours: running,
+		ComputeCost:  compute,
+		StorageCost:  float64(c.EBSSize) * EBSPricePerGBMonth * to.Sub(from).Hours() / HoursPerMonth,
+	}
+}
+
+// runningBetween returns the hours the instance was running between from and
+// to and their compute cost, each hour priced at the type the instance had
+func (c *CostCalculation) runningBetween(from, to time.Time) (hours, compute float64) {
+	// typeBefore[k] is the type the instance had until change k; the last
+	// entry is the current type
+	typeBefore := make([]string, len(c.StateChanges)+1)
+	instanceType := c.InstanceType
+	typeBefore[len(c.StateChanges)] = instanceType
+	for k := len(c.StateChanges) - 1; k >= 0; k-- {
+		if c.StateChanges[k].ResizedFrom != "" {
+			instanceType = c.StateChanges[k].ResizedFrom
+		}
+		typeBefore[k] = instanceType
+	}
+
+	add := func(start, end time.Time, instanceType string) {
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			hours += end.Sub(start).Hours()
+			compute += end.Sub(start).Hours() * c.hourlyRateOf(instanceType)
+		}
+	}
+
+	running := true // Instances start in running state
+	lastTransition := c.LaunchedAt
+	for k, change := range c.StateChanges {
+		if running {
+			add(lastTransition, change.Timestamp, typeBefore[k])
+		}
+		running = change.State == "running"
+		lastTransition = change.Timestamp
+	}
+	if running {
+		add(lastTransition, to, typeBefore[len(c.StateChanges)])
+	}
+	return hours, compute
+}
+
+// hourlyRateOf returns the hourly price of the current or a past type of the
+// instance
+func (c *CostCalculation) hourlyRateOf(instanceType string) float64 {
+	if price, ok := c.PastPrices[instanceType]; ok && instanceType != c.InstanceType {
+		return price.Hourly
+	}
+	return c.HourlyRate
+}
+
+// PricesExact reports whether the current and all past types of the instance
+// have exact prices
+func (c *CostCalculation) PricesExact() bool {
+	if c.Price.Quality != PriceExact {
+		return false
+	}
+	for _, price := range c.PastPrices {
+		if price.Quality != PriceExact {
+			return false
+		}
+	}
+	return true
+}
+
+// MonthStart returns the start of the calendar month of t
+func MonthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
 // generateOnPremComparison creates a comparison message to on-premise hardware
 func generateOnPremComparison(totalCost float64, elapsedHours float64, instanceType string) string {
 	// Rough estimates for comparable hardware
@@ -269,28 +375,28 @@ func FormatHours(hours float64) string {
 	}
 }
 
-// EstimateMonthly estimates monthly cost based on current usage pattern
+// EstimateMonthly estimates monthly cost based on the instance's schedule, or
+// without one its usage pattern so far, including Resources
 func (c *CostCalculation) EstimateMonthly() float64 {
-	if c.TotalElapsedHours == 0 {
+	if c.TotalElapsedHours == 0 && !c.Scheduled {
 		return 0
 	}
 
 	// Calculate average usage pattern
-	runningRatio := c.TotalRunningHours / c.TotalElapsedHours
+	var runningRatio float64
+	if c.Scheduled {
+		runningRatio = c.ScheduledHoursPerWeek / (24 * 7)
+	} else {
+		runningRatio = c.TotalRunningHours / c.TotalElapsedHours
+	}
 
 	// Estimate for 30 days
-	hoursPerMonth := 24.0 * 30.0
-	estimatedRunningHours := hoursPerMonth * runningRatio
-
-	hourlyRate, exists := InstancePricing[c.InstanceType]
-	if !exists {
-		hourlyRate = InstancePricing["t4g.medium"]
-	}
+	estimatedRunningHours := HoursPerMonth * runningRatio
 
-	computeCost := estimatedRunningHours * hourlyRate
+	computeCost := estimatedRunningHours * c.HourlyRate
 	storageCost := float64(c.EBSSize) * EBSPricePerGBMonth
 
-	return computeCost + storageCost
+	return computeCost + storageCost + c.ResourcesMonthly()
 }
 
 // GetUtilizationPercentage returns the percentage of time instance was running
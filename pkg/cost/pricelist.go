@@ -0,0 +1,59 @@
+package cost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/scttfrdmn/lens/pkg/aws"
+)
+
+// PriceListProvider prices instances with the AWS Price List API.
+// After a failure other than a missing price (no credentials, no network) it
+// stops calling the API, so that commands pricing many instances fall back
+// to other providers without waiting on each one.
+type PriceListProvider struct {
+	profile string
+
+	mu       sync.Mutex
+	client   *aws.PricingClient
+	disabled error
+}
+
+// NewPriceListProvider returns a provider using the given AWS profile
+func NewPriceListProvider(profile string) *PriceListProvider {
+	return &PriceListProvider{profile: profile}
+}
+
+// HourlyPrice implements PricingProvider
+func (p *PriceListProvider) HourlyPrice(ctx context.Context, query PriceQuery) (Price, error) {
+	query = query.normalized()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.disabled != nil {
+		return Price{}, p.disabled
+	}
+
+	if p.client == nil {
+		client, err := aws.NewPricingClient(ctx, p.profile)
+		if err != nil {
+			p.disabled = fmt.Errorf("AWS Price List unavailable: %w", err)
+			return Price{}, p.disabled
+		}
+		p.client = client
+	}
+
+	hourly, err := p.client.OnDemandHourlyPrice(ctx, query.InstanceType, query.Region, query.OS)
+	if errors.Is(err, aws.ErrPriceNotFound) {
+		return Price{}, fmt.Errorf("%w: %s is not in the AWS Price List", ErrPriceNotFound, query)
+	}
+	if err != nil {
+		p.disabled = fmt.Errorf("AWS Price List unavailable: %w", err)
+		return Price{}, p.disabled
+	}
+
+	return Price{Hourly: hourly, Quality: PriceExact, Source: "AWS Price List"}, nil
+}
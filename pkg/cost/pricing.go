@@ -0,0 +1,152 @@
+package cost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Price quality, from most to least reliable
+const (
+	// PriceExact is a published on-demand price for the region and OS
+	PriceExact = "exact"
+
+	// PriceEstimated is derived from another region's price
+	PriceEstimated = "estimated"
+
+	// PriceUnknown means no price was found; costs are reported as $0
+	PriceUnknown = "unknown"
+)
+
+// Default price query values
+const (
+	DefaultPricingRegion = "us-east-1"
+	DefaultOS            = "Linux"
+)
+
+// ErrPriceNotFound is returned by a PricingProvider that has no price for a query
+var ErrPriceNotFound = errors.New("price not found")
+
+// PriceQuery identifies the on-demand price of an instance type
+type PriceQuery struct {
+	InstanceType string
+	Region       string // Defaults to DefaultPricingRegion
+	OS           string // Price List operating system, defaults to DefaultOS
+}
+
+// normalized returns the query with defaults filled in
+func (q PriceQuery) normalized() PriceQuery {
+	if q.Region == "" {
+		q.Region = DefaultPricingRegion
+	}
+	if q.OS == "" {
+		q.OS = DefaultOS
+	}
+	return q
+}
+
+// String describes the query, e.g. "g5.xlarge Linux in eu-west-1"
+func (q PriceQuery) String() string {
+	q = q.normalized()
+	return fmt.Sprintf("%s %s in %s", q.InstanceType, q.OS, q.Region)
+}
+
+// Price is the hourly on-demand price of an instance type
+type Price struct {
+	Hourly  float64 `json:"hourly"`  // USD per hour
+	Quality string  `json:"quality"` // PriceExact, PriceEstimated or PriceUnknown
+	Source  string  `json:"source"`  // Where the price came from, e.g. "AWS Price List" or "snapshot 2025-01"
+}
+
+// Known reports whether the price is exact or estimated
+func (p Price) Known() bool {
+	return p.Quality == PriceExact || p.Quality == PriceEstimated
+}
+
+// Note describes how reliable the price is, e.g. "estimated from us-east-1
+// snapshot 2025-01" or "unknown", or returns "" for an exact price
+func (p Price) Note() string {
+	switch p.Quality {
+	case PriceExact:
+		return ""
+	case PriceEstimated:
+		return "estimated from " + p.Source
+	default:
+		return "unknown"
+	}
+}
+
+// FormatHourly formats the price as "$0.0336/hour", flagging estimated and
+// unknown prices
+func (p Price) FormatHourly() string {
+	switch p.Quality {
+	case PriceExact:
+		return FormatCost(p.Hourly) + "/hour"
+	case PriceEstimated:
+		return FormatCost(p.Hourly) + "/hour (estimated)"
+	default:
+		return "unknown/hour"
+	}
+}
+
+// PricingProvider looks up on-demand instance prices
+type PricingProvider interface {
+	// HourlyPrice returns the price for the query, or an error wrapping
+	// ErrPriceNotFound when the provider has no price for it
+	HourlyPrice(ctx context.Context, query PriceQuery) (Price, error)
+}
+
+var (
+	pricingMu sync.Mutex
+	pricing   PricingProvider = NewSnapshotProvider()
+)
+
+// UsePricing makes CalculateCost and LookupPrice use p. The default provider
+// is the bundled snapshot, which works offline. It returns a function that
+// restores the previous provider.
+func UsePricing(p PricingProvider) (restore func()) {
+	pricingMu.Lock()
+	previous := pricing
+	pricing = p
+	pricingMu.Unlock()
+
+	return func() {
+		pricingMu.Lock()
+		pricing = previous
+		pricingMu.Unlock()
+	}
+}
+
+// LookupPrice returns the price for the query from the current provider.
+// A price that cannot be found is returned with PriceUnknown quality.
+func LookupPrice(ctx context.Context, query PriceQuery) Price {
+	pricingMu.Lock()
+	provider := pricing
+	pricingMu.Unlock()
+
+	price, err := provider.HourlyPrice(ctx, query.normalized())
+	if err != nil {
+		return Price{Quality: PriceUnknown}
+	}
+	return price
+}
+
+// ChainProvider asks each provider in turn and returns the first price found
+type ChainProvider []PricingProvider
+
+// HourlyPrice implements PricingProvider
+func (c ChainProvider) HourlyPrice(ctx context.Context, query PriceQuery) (Price, error) {
+	var errs []error
+	for _, provider := range c {
+		price, err := provider.HourlyPrice(ctx, query)
+		if err == nil {
+			return price, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return Price{}, fmt.Errorf("%w: %s", ErrPriceNotFound, query)
+	}
+	return Price{}, errors.Join(errs...)
+}
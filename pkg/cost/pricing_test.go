@@ -0,0 +1,170 @@
+package cost
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/lens/pkg/fakecloud"
+)
+
+// countingProvider returns a fixed price and counts lookups
+type countingProvider struct {
+	price Price
+	err   error
+	calls int
+}
+
+func (p *countingProvider) HourlyPrice(ctx context.Context, query PriceQuery) (Price, error) {
+	p.calls++
+	return p.price, p.err
+}
+
+func TestSnapshotProvider(t *testing.T) {
+	snapshot := NewSnapshotProvider()
+	ctx := context.Background()
+
+	price, err := snapshot.HourlyPrice(ctx, PriceQuery{InstanceType: "g5.xlarge"})
+	if err != nil {
+		t.Fatalf("HourlyPrice failed: %v", err)
+	}
+	if price.Hourly != 1.006 || price.Quality != PriceExact {
+		t.Errorf("Expected exact us-east-1 price, got %+v", price)
+	}
+
+	price, err = snapshot.HourlyPrice(ctx, PriceQuery{InstanceType: "t4g.medium", Region: "eu-central-1"})
+	if err != nil {
+		t.Fatalf("HourlyPrice failed: %v", err)
+	}
+	if price.Quality != PriceEstimated || price.Hourly <= 0.0336 {
+		t.Errorf("Expected an estimated eu-central-1 price above us-east-1, got %+v", price)
+	}
+
+	if _, err := snapshot.HourlyPrice(ctx, PriceQuery{InstanceType: "t4g.medium", OS: "Windows"}); !errors.Is(err, ErrPriceNotFound) {
+		t.Errorf("Expected ErrPriceNotFound for Windows, got %v", err)
+	}
+	if _, err := snapshot.HourlyPrice(ctx, PriceQuery{InstanceType: "x99.huge"}); !errors.Is(err, ErrPriceNotFound) {
+		t.Errorf("Expected ErrPriceNotFound for an unknown type, got %v", err)
+	}
+}
+
+func TestPriceListProvider(t *testing.T) {
+	cloud := fakecloud.Install(t)
+	cloud.SetPrice("eu-west-1", "Linux", "c6i.large", 0.096)
+	provider := NewPriceListProvider("default")
+	ctx := context.Background()
+
+	price, err := provider.HourlyPrice(ctx, PriceQuery{InstanceType: "c6i.large", Region: "eu-west-1"})
+	if err != nil {
+		t.Fatalf("HourlyPrice failed: %v", err)
+	}
+	if price.Hourly != 0.096 || price.Quality != PriceExact || price.Source != "AWS Price List" {
+		t.Errorf("Unexpected price: %+v", price)
+	}
+
+	if _, err := provider.HourlyPrice(ctx, PriceQuery{InstanceType: "c6i.large", Region: "eu-west-2"}); !errors.Is(err, ErrPriceNotFound) {
+		t.Errorf("Expected ErrPriceNotFound, got %v", err)
+	}
+
+	// An API failure disables the provider instead of retrying every lookup
+	cloud.FailNext("GetProducts", fakecloud.APIError("AccessDeniedException", "not authorized"))
+	if _, err := provider.HourlyPrice(ctx, PriceQuery{InstanceType: "c6i.xlarge", Region: "eu-west-1"}); err == nil || errors.Is(err, ErrPriceNotFound) {
+		t.Errorf("Expected an API error, got %v", err)
+	}
+	if _, err := provider.HourlyPrice(ctx, PriceQuery{InstanceType: "c6i.large", Region: "eu-west-1"}); err == nil {
+		t.Error("Expected the provider to stay disabled")
+	}
+}
+
+func TestChainProvider_FallsBackToSnapshot(t *testing.T) {
+	cloud := fakecloud.Install(t)
+	cloud.FailNext("GetProducts", fakecloud.APIError("UnrecognizedClientException", "invalid token"))
+
+	chain := ChainProvider{NewPriceListProvider("default"), NewSnapshotProvider()}
+	price, err := chain.HourlyPrice(context.Background(), PriceQuery{InstanceType: "t4g.medium"})
+	if err != nil {
+		t.Fatalf("HourlyPrice failed: %v", err)
+	}
+	if price.Hourly != 0.0336 || price.Source != "snapshot 2025-01" {
+		t.Errorf("Expected the snapshot price, got %+v", price)
+	}
+
+	if _, err := chain.HourlyPrice(context.Background(), PriceQuery{InstanceType: "x99.huge"}); !errors.Is(err, ErrPriceNotFound) {
+		t.Errorf("Expected ErrPriceNotFound, got %v", err)
+	}
+}
+
+func TestCachedProvider_TTL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pricing-cache.json")
+	next := &countingProvider{price: Price{Hourly: 0.5, Quality: PriceExact, Source: "AWS Price List"}}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	newCache := func() *CachedProvider {
+		cache := NewCachedProvider(path, 24*time.Hour, next)
+		cache.now = func() time.Time { return now }
+		return cache
+	}
+	query := PriceQuery{InstanceType: "g4dn.xlarge", Region: "us-west-2"}
+	ctx := context.Background()
+
+	if _, err := newCache().HourlyPrice(ctx, query); err != nil {
+		t.Fatalf("HourlyPrice failed: %v", err)
+	}
+
+	// A new cache reads the saved price instead of asking again
+	now = now.Add(time.Hour)
+	price, err := newCache().HourlyPrice(ctx, query)
+	if err != nil {
+		t.Fatalf("HourlyPrice failed: %v", err)
+	}
+	if next.calls != 1 || price.Hourly != 0.5 {
+		t.Errorf("Expected a cached price after 1 lookup, got %+v after %d", price, next.calls)
+	}
+
+	// Expired prices are looked up again
+	now = now.Add(24 * time.Hour)
+	next.price.Hourly = 0.526
+	if price, _ := newCache().HourlyPrice(ctx, query); next.calls != 2 || price.Hourly != 0.526 {
+		t.Errorf("Expected a refreshed price, got %+v after %d lookups", price, next.calls)
+	}
+
+	// A stale price is better than none when the lookup fails
+	now = now.Add(48 * time.Hour)
+	next.err = errors.New("offline")
+	if price, err := newCache().HourlyPrice(ctx, query); err != nil || price.Hourly != 0.526 {
+		t.Errorf("Expected the stale price, got %+v, %v", price, err)
+	}
+}
+
+func TestCalculateCost_RegionAndUnknownPrices(t *testing.T) {
+	launchedAt := time.Now().Add(-10 * time.Hour)
+
+	calc := CalculateCost(PriceQuery{InstanceType: "g5.xlarge", Region: "ap-southeast-2"}, launchedAt, nil, 0)
+	if calc.Region != "ap-southeast-2" || calc.Price.Quality != PriceEstimated {
+		t.Errorf("Expected an estimated ap-southeast-2 price, got %+v", calc.Price)
+	}
+	if calc.HourlyRate <= 1.006 || calc.ComputeCost < 10*1.006 {
+		t.Errorf("Expected the regional rate to be applied, got %.4f/hour and %.2f", calc.HourlyRate, calc.ComputeCost)
+	}
+	if calc.Price.Note() == "" || calc.Price.FormatHourly() != FormatCost(calc.HourlyRate)+"/hour (estimated)" {
+		t.Errorf("Expected the estimate to be flagged, got %q", calc.Price.FormatHourly())
+	}
+
+	// Unknown types are no longer priced as a t4g.medium
+	calc = CalculateCost(PriceQuery{InstanceType: "x99.huge"}, launchedAt, nil, 100)
+	if calc.Price.Quality != PriceUnknown || calc.ComputeCost != 0 {
+		t.Errorf("Expected an unknown price with no compute cost, got %+v and %.2f", calc.Price, calc.ComputeCost)
+	}
+	if calc.StorageCost <= 0 || calc.Price.Note() != "unknown" {
+		t.Errorf("Expected storage to be charged and the price flagged, got %.4f and %q", calc.StorageCost, calc.Price.Note())
+	}
+
+	restore := UsePricing(&countingProvider{price: Price{Hourly: 2, Quality: PriceExact}})
+	defer restore()
+	calc = CalculateCost(PriceQuery{InstanceType: "x99.huge"}, launchedAt, nil, 0)
+	if monthly := calc.EstimateMonthly(); monthly < 2*HoursPerMonth-1 {
+		t.Errorf("Expected EstimateMonthly to use the provider's rate, got %.2f", monthly)
+	}
+}
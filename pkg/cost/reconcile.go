@@ -0,0 +1,84 @@
+package cost
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// BilledCost is the cost billed for an instance in a calendar month
+type BilledCost struct {
+	Month      string // e.g. "2026-01"
+	InstanceID string
+	Amount     float64
+	Estimated  bool // The month is not final yet
+}
+
+// Reconciliation compares the estimated and billed cost of an instance in a
+// calendar month
+type Reconciliation struct {
+	Month         string
+	InstanceID    string
+	Estimated     float64
+	Billed        float64
+	BillEstimated bool // The billed cost is not final yet
+	Tracked       bool // The instance has an estimate from recorded history
+	HasBill       bool // Cost Explorer reported a cost for the instance
+}
+
+// Difference is the billed cost minus the estimated cost
+func (r Reconciliation) Difference() float64 {
+	return r.Billed - r.Estimated
+}
+
+// DifferencePercent is the difference relative to the estimated cost, or
+// NaN without an estimate
+func (r Reconciliation) DifferencePercent() float64 {
+	if r.Estimated == 0 {
+		return math.NaN()
+	}
+	return r.Difference() / r.Estimated * 100
+}
+
+// Reconcile pairs the monthly estimates of a report grouped by instance with
+// the costs billed for the same instances and months. Instances that were
+// billed without an estimate, or estimated without a bill, are included.
+func Reconcile(report *Report, billed []BilledCost) ([]Reconciliation, error) {
+	if len(report.GroupBy) != 1 || report.GroupBy[0] != GroupInstance {
+		return nil, fmt.Errorf("reconciliation needs a report grouped by %s", GroupInstance)
+	}
+
+	type key struct{ month, instanceID string }
+	rows := make(map[key]*Reconciliation)
+	row := func(month, instanceID string) *Reconciliation {
+		k := key{month, instanceID}
+		if rows[k] == nil {
+			rows[k] = &Reconciliation{Month: month, InstanceID: instanceID}
+		}
+		return rows[k]
+	}
+
+	for _, estimate := range report.Rows {
+		r := row(estimate.Month, estimate.Group[GroupInstance])
+		r.Estimated += estimate.TotalCost
+		r.Tracked = true
+	}
+	for _, bill := range billed {
+		r := row(bill.Month, bill.InstanceID)
+		r.Billed += bill.Amount
+		r.BillEstimated = r.BillEstimated || bill.Estimated
+		r.HasBill = true
+	}
+
+	reconciliations := make([]Reconciliation, 0, len(rows))
+	for _, r := range rows {
+		reconciliations = append(reconciliations, *r)
+	}
+	sort.Slice(reconciliations, func(i, j int) bool {
+		if reconciliations[i].Month != reconciliations[j].Month {
+			return reconciliations[i].Month < reconciliations[j].Month
+		}
+		return reconciliations[i].InstanceID < reconciliations[j].InstanceID
+	})
+	return reconciliations, nil
+}
@@ -0,0 +1,378 @@
+package cost
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dimensions a report can be grouped by
+const (
+	GroupProject  = "project"
+	GroupEnv      = "env"
+	GroupApp      = "app"
+	GroupUser     = "user"
+	GroupType     = "type"
+	GroupRegion   = "region"
+	GroupInstance = "instance"
+)
+
+// ReportGroups lists every dimension a report can be grouped by
+var ReportGroups = []string{GroupProject, GroupEnv, GroupApp, GroupUser, GroupType, GroupRegion, GroupInstance}
+
+// Report output formats
+const (
+	ReportCSV      = "csv"
+	ReportJSON     = "json"
+	ReportMarkdown = "markdown"
+	ReportHTML     = "html"
+)
+
+// noGroupValue is shown for instances without a value for a dimension, e.g.
+// instances launched without a project
+const noGroupValue = "(none)"
+
+// ReportInstance is an instance to report on
+type ReportInstance struct {
+	Calc   *CostCalculation
+	Groups map[string]string // Value of each dimension, e.g. "project": "genomics"
+}
+
+// ReportRow is the usage of one group, in one month or over the whole report
+type ReportRow struct {
+	Month        string            `json:"month,omitempty"` // e.g. "2026-01"; empty for totals
+	Group        map[string]string `json:"group,omitempty"`
+	Instances    int               `json:"instances"`
+	RunningHours float64           `json:"running_hours"`
+	ComputeCost  float64           `json:"compute_cost"`
+	StorageCost  float64           `json:"storage_cost"`
+	TotalCost    float64           `json:"total_cost"`
+	Estimated    bool              `json:"estimated"` // Includes estimated or unknown prices
+}
+
+// Report is the cost of instances between two dates, per calendar month and
+// group
+type Report struct {
+	From    time.Time   `json:"from"`
+	To      time.Time   `json:"to"`
+	GroupBy []string    `json:"group_by"`
+	Rows    []ReportRow `json:"rows"`   // By month, then group
+	Totals  []ReportRow `json:"totals"` // By group over the whole period, empty without a group-by
+	Total   ReportRow   `json:"total"`
+}
+
+// ParseGroupBy parses a comma-separated list of dimensions
+func ParseGroupBy(value string) ([]string, error) {
+	var groupBy []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" {
+			continue
+		}
+		if name == "environment" {
+			name = GroupEnv
+		}
+		valid := false
+		for _, group := range ReportGroups {
+			valid = valid || group == name
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid group %q (use %s)", name, strings.Join(ReportGroups, ", "))
+		}
+		groupBy = append(groupBy, name)
+	}
+	return groupBy, nil
+}
+
+// reportAccumulator sums the usage of instances into a row
+type reportAccumulator struct {
+	row       ReportRow
+	instances map[*CostCalculation]bool
+}
+
+func (a *reportAccumulator) add(calc *CostCalculation, usage Usage) {
+	a.row.RunningHours += usage.RunningHours
+	a.row.ComputeCost += usage.ComputeCost
+	a.row.StorageCost += usage.StorageCost
+	a.row.TotalCost += usage.ComputeCost + usage.StorageCost
+	a.row.Estimated = a.row.Estimated || !calc.PricesExact()
+	if !a.instances[calc] {
+		a.instances[calc] = true
+		a.row.Instances++
+	}
+}
+
+// BuildReport sums the usage of instances between from and to by calendar
+// month and by the values of the groupBy dimensions. Instances without usage
+// in a month are left out of it.
+func BuildReport(instances []ReportInstance, from, to time.Time, groupBy []string) (*Report, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("report period is empty: %s is not before %s", from.Format("2006-01-02"), to.Format("2006-01-02"))
+	}
+
+	rows := make(map[string]*reportAccumulator)
+	totals := make(map[string]*reportAccumulator)
+	total := &reportAccumulator{instances: make(map[*CostCalculation]bool)}
+	accumulator := func(m map[string]*reportAccumulator, month string, group map[string]string) *reportAccumulator {
+		key := month
+		for _, name := range groupBy {
+			key += "\x00" + group[name]
+		}
+		if m[key] == nil {
+			m[key] = &reportAccumulator{
+				row:       ReportRow{Month: month, Group: group},
+				instances: make(map[*CostCalculation]bool),
+			}
+		}
+		return m[key]
+	}
+
+	for _, instance := range instances {
+		group := make(map[string]string, len(groupBy))
+		for _, name := range groupBy {
+			group[name] = instance.Groups[name]
+			if group[name] == "" {
+				group[name] = noGroupValue
+			}
+		}
+
+		for month := MonthStart(from); month.Before(to); month = month.AddDate(0, 1, 0) {
+			start, end := month, month.AddDate(0, 1, 0)
+			if start.Before(from) {
+				start = from
+			}
+			if end.After(to) {
+				end = to
+			}
+			usage := instance.Calc.UsageBetween(start, end)
+			if usage.RunningHours == 0 && usage.StorageCost == 0 {
+				continue
+			}
+			accumulator(rows, month.Format("2006-01"), group).add(instance.Calc, usage)
+			accumulator(totals, "", group).add(instance.Calc, usage)
+			total.add(instance.Calc, usage)
+		}
+	}
+
+	report := &Report{From: from, To: to, GroupBy: groupBy, Total: total.row}
+	report.Rows = sortedRows(rows, groupBy)
+	// Without a group-by the single implicit group is the overall total
+	if len(groupBy) > 0 {
+		report.Totals = sortedRows(totals, groupBy)
+	}
+	return report, nil
+}
+
+// sortedRows returns the accumulated rows by month, then group values
+func sortedRows(m map[string]*reportAccumulator, groupBy []string) []ReportRow {
+	rows := make([]ReportRow, 0, len(m))
+	for _, a := range m {
+		rows = append(rows, a.row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Month != rows[j].Month {
+			return rows[i].Month < rows[j].Month
+		}
+		for _, name := range groupBy {
+			if rows[i].Group[name] != rows[j].Group[name] {
+				return rows[i].Group[name] < rows[j].Group[name]
+			}
+		}
+		return false
+	})
+	return rows
+}
+
+// Write writes the report in the given format
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case ReportCSV:
+		return r.writeCSV(w)
+	case ReportJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	case ReportMarkdown:
+		return r.writeMarkdown(w)
+	case ReportHTML:
+		return reportHTML.Execute(w, r)
+	default:
+		return fmt.Errorf("invalid format %q (use csv, json, markdown or html)", format)
+	}
+}
+
+// Period describes the report period with an inclusive end date
+func (r *Report) Period() string {
+	return fmt.Sprintf("%s to %s", r.From.Format("2006-01-02"), r.To.Add(-time.Nanosecond).Format("2006-01-02"))
+}
+
+// groupTitles returns the column titles of the groups
+func (r *Report) groupTitles() []string {
+	titles := make([]string, len(r.GroupBy))
+	for i, name := range r.GroupBy {
+		titles[i] = strings.ToUpper(name[:1]) + name[1:]
+	}
+	return titles
+}
+
+// groupValues returns the values of a row's groups in column order
+func (r *Report) groupValues(row ReportRow) []string {
+	values := make([]string, len(r.GroupBy))
+	for i, name := range r.GroupBy {
+		values[i] = row.Group[name]
+	}
+	return values
+}
+
+// writeCSV writes one line per month and group, followed by the totals per
+// group (month "total") and the overall total (groups "all")
+func (r *Report) writeCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	header := append([]string{"month"}, r.GroupBy...)
+	header = append(header, "instances", "running_hours", "compute_cost", "storage_cost", "total_cost", "estimated")
+	if err := out.Write(header); err != nil {
+		return err
+	}
+
+	record := func(month string, groups []string, row ReportRow) []string {
+		fields := append([]string{month}, groups...)
+		return append(fields,
+			strconv.Itoa(row.Instances),
+			strconv.FormatFloat(row.RunningHours, 'f', 2, 64),
+			strconv.FormatFloat(row.ComputeCost, 'f', 2, 64),
+			strconv.FormatFloat(row.StorageCost, 'f', 2, 64),
+			strconv.FormatFloat(row.TotalCost, 'f', 2, 64),
+			strconv.FormatBool(row.Estimated),
+		)
+	}
+	for _, row := range r.Rows {
+		if err := out.Write(record(row.Month, r.groupValues(row), row)); err != nil {
+			return err
+		}
+	}
+	for _, row := range r.Totals {
+		if err := out.Write(record("total", r.groupValues(row), row)); err != nil {
+			return err
+		}
+	}
+	all := make([]string, len(r.GroupBy))
+	for i := range all {
+		all[i] = "all"
+	}
+	if err := out.Write(record("total", all, r.Total)); err != nil {
+		return err
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// markdownCost formats a cost for a table, marking estimates with "*"
+func markdownCost(cost float64, estimated bool) string {
+	if estimated {
+		return FormatCostShort(cost) + "*"
+	}
+	return FormatCostShort(cost)
+}
+
+// writeMarkdown writes a table by month and a table of totals
+func (r *Report) writeMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Cost report %s\n\n", r.Period())
+	if len(r.GroupBy) > 0 {
+		fmt.Fprintf(&b, "Grouped by %s. ", strings.Join(r.GroupBy, ", "))
+	}
+	b.WriteString("Costs in USD for compute and root volumes.\n\n")
+
+	table := func(title []string, rows []ReportRow, month bool) {
+		columns := append(title, "Instances", "Running hours", "Compute", "Storage", "Total")
+		fmt.Fprintf(&b, "| %s |\n", strings.Join(columns, " | "))
+		fmt.Fprintf(&b, "|%s\n", strings.Repeat(" --- |", len(columns)))
+		for _, row := range rows {
+			cells := r.groupValues(row)
+			if month {
+				cells = append([]string{row.Month}, cells...)
+			}
+			cells = append(cells,
+				strconv.Itoa(row.Instances),
+				fmt.Sprintf("%.1f", row.RunningHours),
+				FormatCostShort(row.ComputeCost),
+				FormatCostShort(row.StorageCost),
+				markdownCost(row.TotalCost, row.Estimated),
+			)
+			fmt.Fprintf(&b, "| %s |\n", strings.Join(cells, " | "))
+		}
+	}
+
+	b.WriteString("## By month\n\n")
+	table(append([]string{"Month"}, r.groupTitles()...), r.Rows, true)
+
+	b.WriteString("\n## Totals\n\n")
+	totals := append(append([]ReportRow{}, r.Totals...), r.Total)
+	if len(r.GroupBy) > 0 {
+		totals[len(totals)-1].Group = map[string]string{r.GroupBy[0]: "**All**"}
+	}
+	table(r.groupTitles(), totals, false)
+
+	if r.Total.Estimated {
+		b.WriteString("\n\\* Includes estimated or unknown instance prices.\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// reportHTML renders a Report as a standalone HTML page
+var reportHTML = template.Must(template.New("report").Funcs(template.FuncMap{
+	"cost":   FormatCostShort,
+	"hours":  func(hours float64) string { return fmt.Sprintf("%.1f", hours) },
+	"groups": func(r *Report, row ReportRow) []string { return r.groupValues(row) },
+	"titles": func(r *Report) []string { return r.groupTitles() },
+	"period": func(r *Report) string { return r.Period() },
+	"join":   strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Cost report {{period .}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; }
+td.num { text-align: right; }
+tr.total { font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Cost report {{period .}}</h1>
+<p>{{if .GroupBy}}Grouped by {{join .GroupBy ", "}}. {{end}}Costs in USD for compute and root volumes.</p>
+{{- $r := . }}
+<h2>By month</h2>
+<table>
+<tr><th>Month</th>{{range titles $r}}<th>{{.}}</th>{{end}}<th>Instances</th><th>Running hours</th><th>Compute</th><th>Storage</th><th>Total</th></tr>
+{{- range .Rows}}
+<tr><td>{{.Month}}</td>{{range groups $r .}}<td>{{.}}</td>{{end}}<td class="num">{{.Instances}}</td><td class="num">{{hours .RunningHours}}</td><td class="num">{{cost .ComputeCost}}</td><td class="num">{{cost .StorageCost}}</td><td class="num">{{cost .TotalCost}}{{if .Estimated}}*{{end}}</td></tr>
+{{- end}}
+</table>
+<h2>Totals</h2>
+<table>
+<tr>{{range titles $r}}<th>{{.}}</th>{{end}}<th>Instances</th><th>Running hours</th><th>Compute</th><th>Storage</th><th>Total</th></tr>
+{{- range .Totals}}
+<tr>{{range groups $r .}}<td>{{.}}</td>{{end}}<td class="num">{{.Instances}}</td><td class="num">{{hours .RunningHours}}</td><td class="num">{{cost .ComputeCost}}</td><td class="num">{{cost .StorageCost}}</td><td class="num">{{cost .TotalCost}}{{if .Estimated}}*{{end}}</td></tr>
+{{- end}}
+{{- with .Total}}
+<tr class="total">{{if $r.GroupBy}}<td colspan="{{len $r.GroupBy}}">All</td>{{end}}<td class="num">{{.Instances}}</td><td class="num">{{hours .RunningHours}}</td><td class="num">{{cost .ComputeCost}}</td><td class="num">{{cost .StorageCost}}</td><td class="num">{{cost .TotalCost}}{{if .Estimated}}*{{end}}</td></tr>
+{{- end}}
+</table>
+{{- if .Total.Estimated}}
+<p>* Includes estimated or unknown instance prices.</p>
+{{- end}}
+</body>
+</html>
+`))
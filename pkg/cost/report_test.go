@@ -0,0 +1,171 @@
+package cost
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildReport_BucketsByMonthAndGroup(t *testing.T) {
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 0, 0, 0, 0, time.UTC) }
+	query := PriceQuery{InstanceType: "t4g.medium", Region: "us-east-1"}
+
+	// Runs 10 hours in January and 9 in February, then is terminated
+	genomics := CalculateCost(query, day(1, 31).Add(14*time.Hour), []StateChange{
+		{State: "running", Timestamp: day(1, 31).Add(14 * time.Hour)},
+		{State: "stopped", Timestamp: day(2, 1).Add(5 * time.Hour)},
+		{State: "running", Timestamp: day(2, 3)},
+		{State: "terminated", Timestamp: day(2, 3).Add(4 * time.Hour)},
+	}, 0)
+	// Runs 2 hours in February, without a project
+	scratch := CalculateCost(query, day(2, 10), []StateChange{
+		{State: "running", Timestamp: day(2, 10)},
+		{State: "stopped", Timestamp: day(2, 10).Add(2 * time.Hour)},
+	}, 0)
+
+	instances := []ReportInstance{
+		{Calc: genomics, Groups: map[string]string{GroupProject: "genomics", GroupEnv: "ml"}},
+		{Calc: scratch, Groups: map[string]string{GroupEnv: "ml"}},
+	}
+	groupBy, err := ParseGroupBy("project, environment")
+	if err != nil {
+		t.Fatalf("ParseGroupBy failed: %v", err)
+	}
+	report, err := BuildReport(instances, day(1, 1), day(7, 1), groupBy)
+	if err != nil {
+		t.Fatalf("BuildReport failed: %v", err)
+	}
+
+	want := []struct {
+		month, project string
+		hours          float64
+	}{
+		{"2026-01", "genomics", 10},
+		{"2026-02", "(none)", 2},
+		{"2026-02", "genomics", 9},
+	}
+	if len(report.Rows) != len(want) {
+		t.Fatalf("Expected %d rows, got %+v", len(want), report.Rows)
+	}
+	for i, w := range want {
+		row := report.Rows[i]
+		if row.Month != w.month || row.Group[GroupProject] != w.project || row.Group[GroupEnv] != "ml" {
+			t.Errorf("Row %d: expected %s %s/ml, got %s %v", i, w.month, w.project, row.Month, row.Group)
+		}
+		if math.Abs(row.RunningHours-w.hours) > 1e-9 || row.Instances != 1 {
+			t.Errorf("Row %d: expected %.0f hours of 1 instance, got %.2f of %d", i, w.hours, row.RunningHours, row.Instances)
+		}
+	}
+
+	if len(report.Totals) != 2 || report.Totals[1].RunningHours != 19 || report.Totals[1].Instances != 1 {
+		t.Errorf("Expected genomics total of 19 hours, got %+v", report.Totals)
+	}
+	if report.Total.RunningHours != 21 || report.Total.Instances != 2 {
+		t.Errorf("Expected overall total of 21 hours over 2 instances, got %+v", report.Total)
+	}
+	if math.Abs(report.Total.TotalCost-21*genomics.HourlyRate) > 1e-9 {
+		t.Errorf("Expected total cost %.4f, got %.4f", 21*genomics.HourlyRate, report.Total.TotalCost)
+	}
+
+	var out bytes.Buffer
+	if err := report.Write(&out, ReportCSV); err != nil {
+		t.Fatalf("Write csv failed: %v", err)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v", err)
+	}
+	if got := strings.Join(records[0], ","); got != "month,project,env,instances,running_hours,compute_cost,storage_cost,total_cost,estimated" {
+		t.Errorf("Unexpected CSV header %s", got)
+	}
+	if last := records[len(records)-1]; last[0] != "total" || last[1] != "all" || last[4] != "21.00" {
+		t.Errorf("Expected the overall total last, got %v", last)
+	}
+
+	for _, format := range []string{ReportJSON, ReportMarkdown, ReportHTML} {
+		out.Reset()
+		if err := report.Write(&out, format); err != nil {
+			t.Errorf("Write %s failed: %v", format, err)
+		}
+		if !strings.Contains(out.String(), "genomics") {
+			t.Errorf("Expected the %s report to list genomics", format)
+		}
+	}
+	if err := report.Write(&out, "pdf"); err == nil {
+		t.Error("Expected an unknown format to be rejected")
+	}
+}
+
+func TestBuildReport_WithoutGroupByPrintsTotalOnce(t *testing.T) {
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	calc := CalculateCost(PriceQuery{InstanceType: "t4g.medium", Region: "us-east-1"}, start, []StateChange{
+		{State: "running", Timestamp: start},
+		{State: "stopped", Timestamp: start.Add(3 * time.Hour)},
+	}, 0)
+	report, err := BuildReport([]ReportInstance{{Calc: calc}}, start, start.AddDate(0, 1, 0), nil)
+	if err != nil {
+		t.Fatalf("BuildReport failed: %v", err)
+	}
+	if len(report.Totals) != 0 {
+		t.Errorf("Expected no per-group totals without a group-by, got %+v", report.Totals)
+	}
+
+	var out bytes.Buffer
+	if err := report.Write(&out, ReportCSV); err != nil {
+		t.Fatalf("Write csv failed: %v", err)
+	}
+	if count := strings.Count(out.String(), "\ntotal,"); count != 1 {
+		t.Errorf("Expected one CSV total row, got %d:\n%s", count, out.String())
+	}
+
+	for _, format := range []string{ReportMarkdown, ReportHTML} {
+		out.Reset()
+		if err := report.Write(&out, format); err != nil {
+			t.Fatalf("Write %s failed: %v", format, err)
+		}
+		if count := strings.Count(out.String(), "3.0"); count != 2 {
+			t.Errorf("Expected the 3 hours once by month and once in the total of the %s report, got %d:\n%s", format, count, out.String())
+		}
+	}
+}
+
+func TestCalculateCost_PricesRunningTimeAtTheTypeOfTheTime(t *testing.T) {
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 0, 0, 0, 0, time.UTC) }
+	small := LookupPrice(context.Background(), PriceQuery{InstanceType: "t4g.medium", Region: "us-east-1"}).Hourly
+	large := LookupPrice(context.Background(), PriceQuery{InstanceType: "m7g.xlarge", Region: "us-east-1"}).Hourly
+	if small == 0 || large == 0 || small == large {
+		t.Fatalf("Expected distinct known prices, got %f and %f", small, large)
+	}
+
+	// Runs 10 hours as t4g.medium in January, is resized, then runs 4 hours
+	// as m7g.xlarge in June
+	calc := CalculateCost(PriceQuery{InstanceType: "m7g.xlarge", Region: "us-east-1"}, day(1, 5), []StateChange{
+		{State: "running", Timestamp: day(1, 5)},
+		{State: "stopped", Timestamp: day(1, 5).Add(10 * time.Hour), ResizedFrom: "t4g.medium"},
+		{State: "running", Timestamp: day(6, 2)},
+		{State: "stopped", Timestamp: day(6, 2).Add(4 * time.Hour)},
+	}, 0)
+
+	if january := calc.UsageBetween(day(1, 1), day(2, 1)); math.Abs(january.ComputeCost-10*small) > 1e-9 {
+		t.Errorf("Expected January at the t4g.medium price %.4f, got %.4f", 10*small, january.ComputeCost)
+	}
+	if june := calc.UsageBetween(day(6, 1), day(7, 1)); math.Abs(june.ComputeCost-4*large) > 1e-9 {
+		t.Errorf("Expected June at the m7g.xlarge price %.4f, got %.4f", 4*large, june.ComputeCost)
+	}
+	if want := 10*small + 4*large; math.Abs(calc.ComputeCost-want) > 1e-9 {
+		t.Errorf("Expected total compute %.4f, got %.4f", want, calc.ComputeCost)
+	}
+	if calc.HourlyRate != large {
+		t.Errorf("Expected the current rate to be the m7g.xlarge price, got %.4f", calc.HourlyRate)
+	}
+}
+
+func TestParseGroupBy_RejectsUnknownDimension(t *testing.T) {
+	if _, err := ParseGroupBy("project,team"); err == nil {
+		t.Error("Expected an unknown dimension to be rejected")
+	}
+}
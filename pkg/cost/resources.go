@@ -0,0 +1,192 @@
+package cost
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/scttfrdmn/lens/pkg/aws"
+)
+
+// Kinds of resources billed beyond compute and root volumes
+const (
+	ResourceNATGateway = "NAT Gateway"
+	ResourceElasticIP  = "Elastic IP"
+	ResourcePublicIPv4 = "Public IPv4"
+	ResourceSnapshot   = "AMI snapshot"
+	ResourceDataVolume = "Data volume"
+	ResourceBackup     = "Backup"
+	ResourceTransfer   = "Data transfer"
+)
+
+// ResourceCost is the recurring charge of a resource
+type ResourceCost struct {
+	Kind        string
+	ID          string // Empty for data transfer
+	Region      string
+	Description string   // e.g. "shared by 3 instances" or "idle"
+	Monthly     float64  // Cost per month of the whole resource
+	Instances   []string // Instances the cost is split between in equal shares
+	Estimated   bool     // Derived from measured usage or an upper bound
+
+	// WhileRunning resources are only charged while their instance runs;
+	// Monthly is the cost of running all month
+	WhileRunning bool
+}
+
+// ShareFor returns the part of the resource charged to the instance of calc,
+// and false if the instance does not use the resource
+func (r ResourceCost) ShareFor(instanceID string, calc *CostCalculation) (ResourceCost, bool) {
+	for _, id := range r.Instances {
+		if id != instanceID {
+			continue
+		}
+		share := r
+		share.Monthly = r.Monthly / float64(len(r.Instances))
+		if r.WhileRunning && calc.TotalElapsedHours > 0 {
+			share.Monthly *= calc.TotalRunningHours / calc.TotalElapsedHours
+		}
+		return share, true
+	}
+	return ResourceCost{}, false
+}
+
+// AddResources attaches the instance's share of each resource it uses
+func (c *CostCalculation) AddResources(instanceID string, resources []ResourceCost) {
+	for _, resource := range resources {
+		if share, ok := resource.ShareFor(instanceID, c); ok {
+			c.Resources = append(c.Resources, share)
+		}
+	}
+}
+
+// ResourcesMonthly returns the monthly cost of the instance's resources
+func (c *CostCalculation) ResourcesMonthly() float64 {
+	return SumMonthly(c.Resources)
+}
+
+// SumMonthly returns the total monthly cost of resources
+func SumMonthly(resources []ResourceCost) float64 {
+	var total float64
+	for _, resource := range resources {
+		total += resource.Monthly
+	}
+	return total
+}
+
+// TransferCost estimates the charge for network traffic. Traffic from an
+// instance in a private subnet is processed by its NAT Gateway in both
+// directions; traffic sent out is charged as internet egress. Traffic to
+// S3 or other instances in the region is free without a NAT Gateway, so the
+// estimate is an upper bound.
+func TransferCost(inGB, outGB float64, viaNAT bool) float64 {
+	total := outGB * DataTransferOutPricePerGB
+	if viaNAT {
+		total += (inGB + outGB) * NATGatewayPricePerGB
+	}
+	return total
+}
+
+// FootprintCosts returns the monthly cost of the NAT Gateways, Elastic IPs,
+// auto-assigned public IPv4 addresses, snapshots and data volumes of a
+// region. A NAT Gateway's cost includes its Elastic IPs. Detached data
+// volumes are charged to no instance.
+func FootprintCosts(footprint *aws.Footprint) []ResourceCost {
+	var resources []ResourceCost
+	addressMonthly := PublicIPv4PricePerHour * HoursPerMonth
+
+	for _, nat := range footprint.NATGateways {
+		description := "unused"
+		if n := len(nat.Instances); n == 1 {
+			description = "used by 1 instance"
+		} else if n > 1 {
+			description = fmt.Sprintf("shared by %d instances", n)
+		}
+		resources = append(resources, ResourceCost{
+			Kind:        ResourceNATGateway,
+			ID:          nat.ID,
+			Region:      footprint.Region,
+			Description: description,
+			Monthly:     NATGatewayPricePerHour*HoursPerMonth + float64(len(nat.Addresses))*addressMonthly,
+			Instances:   nat.Instances,
+		})
+	}
+
+	for _, address := range footprint.Addresses {
+		if address.NATGatewayID != "" {
+			continue // Included in the NAT Gateway
+		}
+		resource := ResourceCost{
+			Kind:        ResourceElasticIP,
+			ID:          address.AllocationID,
+			Region:      footprint.Region,
+			Description: address.PublicIP + ", idle",
+			Monthly:     addressMonthly,
+		}
+		if address.InstanceID != "" {
+			resource.Description = address.PublicIP
+			resource.Instances = []string{address.InstanceID}
+		}
+		resources = append(resources, resource)
+	}
+
+	instanceIDs := make([]string, 0, len(footprint.PublicIPs))
+	for instanceID := range footprint.PublicIPs {
+		instanceIDs = append(instanceIDs, instanceID)
+	}
+	sort.Strings(instanceIDs)
+	for _, instanceID := range instanceIDs {
+		resources = append(resources, ResourceCost{
+			Kind:         ResourcePublicIPv4,
+			ID:           footprint.PublicIPs[instanceID],
+			Region:       footprint.Region,
+			Description:  "while running",
+			Monthly:      addressMonthly,
+			Instances:    []string{instanceID},
+			WhileRunning: true,
+		})
+	}
+
+	for _, snapshot := range footprint.Snapshots {
+		description := fmt.Sprintf("%d GB", snapshot.SizeGB)
+		if snapshot.Name != "" {
+			description = fmt.Sprintf("%s, %s", snapshot.Name, description)
+		}
+		kind := ResourceSnapshot
+		if snapshot.Backup != "" {
+			kind = ResourceBackup
+		} else if snapshot.ImageID == "" {
+			description += ", AMI deleted"
+		}
+		resource := ResourceCost{
+			Kind:        kind,
+			ID:          snapshot.ID,
+			Region:      footprint.Region,
+			Description: description,
+			// Snapshots are billed for changed blocks only, so the volume
+			// size is an upper bound
+			Monthly:   float64(snapshot.SizeGB) * EBSSnapshotPricePerGBMonth,
+			Estimated: true,
+		}
+		if snapshot.SourceInstance != "" {
+			resource.Instances = []string{snapshot.SourceInstance}
+		}
+		resources = append(resources, resource)
+	}
+
+	for _, volume := range footprint.DataVolumes {
+		resource := ResourceCost{
+			Kind:        ResourceDataVolume,
+			ID:          volume.ID,
+			Region:      footprint.Region,
+			Description: fmt.Sprintf("%s, %d GB, detached", volume.Name, volume.SizeGB),
+			Monthly:     float64(volume.SizeGB) * EBSPricePerGBMonth,
+		}
+		if volume.Attached() {
+			resource.Description = fmt.Sprintf("%s, %d GB", volume.Name, volume.SizeGB)
+			resource.Instances = []string{volume.InstanceID}
+		}
+		resources = append(resources, resource)
+	}
+
+	return resources
+}
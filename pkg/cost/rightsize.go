@@ -0,0 +1,147 @@
+package cost
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/scttfrdmn/lens/pkg/aws"
+)
+
+// Rightsizing thresholds
+const (
+	TargetCPUPercent    = 80 // Peak CPU the recommended type should run at
+	TargetMemoryPercent = 80 // Peak memory use the recommended type should run at
+	MinUtilizationHours = 24 // Hours of CPU data needed for a recommendation
+
+	burstableBaselinePercent = 20 // Average CPU a burstable type sustains on its credits
+	idleGPUPercent           = 5  // Peak GPU use below which the GPUs are taken as unused
+)
+
+// Rightsizing verdicts
+const (
+	RightsizeFits     = "fits"     // The current type is the cheapest that fits
+	RightsizeDownsize = "downsize" // A cheaper type fits
+	RightsizeUpsize   = "upsize"   // The current type is too small
+)
+
+// Utilization is the observed use of an instance over its lifetime, as
+// percentages of its capacity
+type Utilization struct {
+	CPU    aws.MetricStats
+	Memory aws.MetricStats // Only with the CloudWatch agent
+	Disk   aws.MetricStats // Only with the CloudWatch agent
+	GPU    aws.MetricStats // Only with the CloudWatch agent on GPU instances
+}
+
+// Sizing is the capacity an instance needs for its observed use
+type Sizing struct {
+	VCPUs          float64 // For the peak CPU use at TargetCPUPercent
+	AverageVCPUs   float64 // vCPUs in use on average
+	MemoryGiB      float64 // For the peak memory use at TargetMemoryPercent
+	MemoryMeasured bool    // Without memory data the current memory is kept
+	GPUs           int
+}
+
+// RequiredSizing returns the capacity an instance of a type needs for its
+// observed use
+func RequiredSizing(current aws.InstanceTypeSpec, u Utilization) Sizing {
+	sizing := Sizing{
+		VCPUs:        float64(current.VCPUs) * u.CPU.Peak / TargetCPUPercent,
+		AverageVCPUs: float64(current.VCPUs) * u.CPU.Average / 100,
+		MemoryGiB:    current.MemoryGiB(),
+		GPUs:         current.GPUs,
+	}
+	if u.Memory.Datapoints > 0 {
+		sizing.MemoryGiB = current.MemoryGiB() * u.Memory.Peak / TargetMemoryPercent
+		sizing.MemoryMeasured = true
+	}
+	if current.GPUs > 0 && u.GPU.Datapoints > 0 && u.GPU.Peak < idleGPUPercent {
+		sizing.GPUs = 0
+	}
+	return sizing
+}
+
+// Fits reports whether an instance type has the capacity. Types with GPUs
+// only fit when GPUs are needed, and burstable types only when the average
+// CPU use stays within their baseline.
+func (s Sizing) Fits(spec aws.InstanceTypeSpec) bool {
+	if spec.VCPUs == 0 || float64(spec.VCPUs) < s.VCPUs || spec.MemoryGiB() < s.MemoryGiB {
+		return false
+	}
+	if spec.GPUs < s.GPUs || (s.GPUs == 0 && spec.GPUs > 0) {
+		return false
+	}
+	if spec.Burstable && s.AverageVCPUs/float64(spec.VCPUs)*100 > burstableBaselinePercent {
+		return false
+	}
+	return true
+}
+
+// RightsizeCandidate is an instance type with its on-demand hourly price
+type RightsizeCandidate struct {
+	aws.InstanceTypeSpec
+	HourlyRate float64
+}
+
+// Recommendation is the outcome of rightsizing an instance
+type Recommendation struct {
+	Verdict     string
+	Current     RightsizeCandidate
+	Recommended RightsizeCandidate // The current type when it fits best
+	Needed      Sizing
+}
+
+// Changed reports whether a different instance type is recommended
+func (r Recommendation) Changed() bool {
+	return r.Recommended.InstanceType != r.Current.InstanceType
+}
+
+// Rightsize recommends the cheapest candidate that fits the observed use of
+// an instance: a cheaper type when the current one is oversized, a larger
+// one when it is too small, or the current type. Candidates without a price
+// are skipped.
+func Rightsize(current RightsizeCandidate, candidates []RightsizeCandidate, u Utilization) (Recommendation, error) {
+	needed := RequiredSizing(current.InstanceTypeSpec, u)
+	recommendation := Recommendation{
+		Verdict:     RightsizeFits,
+		Current:     current,
+		Recommended: current,
+		Needed:      needed,
+	}
+
+	var fitting []RightsizeCandidate
+	for _, candidate := range candidates {
+		if candidate.HourlyRate > 0 && needed.Fits(candidate.InstanceTypeSpec) {
+			fitting = append(fitting, candidate)
+		}
+	}
+	sort.Slice(fitting, func(i, j int) bool {
+		a, b := fitting[i], fitting[j]
+		if a.HourlyRate != b.HourlyRate {
+			return a.HourlyRate < b.HourlyRate
+		}
+		if a.VCPUs != b.VCPUs {
+			return a.VCPUs < b.VCPUs
+		}
+		return a.InstanceType < b.InstanceType
+	})
+
+	currentFits := needed.Fits(current.InstanceTypeSpec)
+	if len(fitting) == 0 {
+		if !currentFits {
+			return recommendation, fmt.Errorf("no instance type fits %.1f vCPUs and %.1f GiB of memory", needed.VCPUs, needed.MemoryGiB)
+		}
+		return recommendation, nil
+	}
+
+	best := fitting[0]
+	switch {
+	case current.HourlyRate > 0 && best.HourlyRate < current.HourlyRate:
+		recommendation.Verdict = RightsizeDownsize
+		recommendation.Recommended = best
+	case !currentFits:
+		recommendation.Verdict = RightsizeUpsize
+		recommendation.Recommended = best
+	}
+	return recommendation, nil
+}
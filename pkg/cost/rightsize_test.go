@@ -0,0 +1,118 @@
+package cost
+
+import (
+	"testing"
+
+	"github.com/scttfrdmn/lens/pkg/aws"
+)
+
+func TestRightsize(t *testing.T) {
+	candidate := func(name string, vcpus int, memoryGiB float64, gpus int, burstable bool, rate float64) RightsizeCandidate {
+		return RightsizeCandidate{
+			InstanceTypeSpec: aws.InstanceTypeSpec{
+				InstanceType: name,
+				VCPUs:        vcpus,
+				MemoryMiB:    int64(memoryGiB * 1024),
+				GPUs:         gpus,
+				Burstable:    burstable,
+			},
+			HourlyRate: rate,
+		}
+	}
+	stats := func(average, peak float64) aws.MetricStats {
+		return aws.MetricStats{Average: average, Peak: peak, Datapoints: 100}
+	}
+
+	m7gXLarge := candidate("m7g.xlarge", 4, 16, 0, false, 0.1632)
+	candidates := []RightsizeCandidate{
+		candidate("t4g.medium", 2, 4, 0, true, 0.0336),
+		candidate("t4g.large", 2, 8, 0, true, 0.0672),
+		candidate("c7g.large", 2, 4, 0, false, 0.0725),
+		candidate("m7g.large", 2, 8, 0, false, 0.0816),
+		m7gXLarge,
+		candidate("m7g.2xlarge", 8, 32, 0, false, 0.3264),
+		candidate("r7g.xlarge", 4, 32, 0, false, 0.2142),
+		candidate("g5g.xlarge", 4, 8, 1, false, 0.42),
+		candidate("m7g.4xlarge", 16, 64, 0, false, 0), // No price
+	}
+
+	tests := []struct {
+		name        string
+		current     RightsizeCandidate
+		utilization Utilization
+		verdict     string
+		recommended string
+	}{
+		{
+			name:        "idle with little memory fits a burstable type",
+			current:     m7gXLarge,
+			utilization: Utilization{CPU: stats(5, 30), Memory: stats(10, 20)},
+			verdict:     RightsizeDownsize,
+			recommended: "t4g.medium",
+		},
+		{
+			name:        "busy average rules out burstable types",
+			current:     m7gXLarge,
+			utilization: Utilization{CPU: stats(30, 35), Memory: stats(10, 20)},
+			verdict:     RightsizeDownsize,
+			recommended: "c7g.large",
+		},
+		{
+			name:        "memory is kept without the CloudWatch agent",
+			current:     m7gXLarge,
+			utilization: Utilization{CPU: stats(30, 35)},
+			verdict:     RightsizeFits,
+			recommended: "m7g.xlarge",
+		},
+		{
+			name:        "saturated CPU needs more vCPUs",
+			current:     m7gXLarge,
+			utilization: Utilization{CPU: stats(70, 100), Memory: stats(40, 50)},
+			verdict:     RightsizeUpsize,
+			recommended: "m7g.2xlarge",
+		},
+		{
+			name:        "high memory use needs more memory",
+			current:     m7gXLarge,
+			utilization: Utilization{CPU: stats(30, 60), Memory: stats(80, 95)},
+			verdict:     RightsizeUpsize,
+			recommended: "r7g.xlarge",
+		},
+		{
+			name:        "unused GPUs are dropped",
+			current:     candidate("g5g.xlarge", 4, 8, 1, false, 0.42),
+			utilization: Utilization{CPU: stats(30, 35), Memory: stats(30, 60), GPU: stats(0, 1)},
+			verdict:     RightsizeDownsize,
+			recommended: "m7g.large",
+		},
+		{
+			name:        "used GPUs are kept",
+			current:     candidate("g5g.xlarge", 4, 8, 1, false, 0.42),
+			utilization: Utilization{CPU: stats(30, 50), Memory: stats(30, 60), GPU: stats(40, 90)},
+			verdict:     RightsizeFits,
+			recommended: "g5g.xlarge",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recommendation, err := Rightsize(tt.current, candidates, tt.utilization)
+			if err != nil {
+				t.Fatalf("Rightsize failed: %v", err)
+			}
+			if recommendation.Verdict != tt.verdict || recommendation.Recommended.InstanceType != tt.recommended {
+				t.Errorf("Rightsize = %s %s, want %s %s (needed %+v)", recommendation.Verdict,
+					recommendation.Recommended.InstanceType, tt.verdict, tt.recommended, recommendation.Needed)
+			}
+			if recommendation.Changed() != (tt.recommended != tt.current.InstanceType) {
+				t.Errorf("Changed() = %v", recommendation.Changed())
+			}
+		})
+	}
+
+	// Nothing fits
+	_, err := Rightsize(m7gXLarge, candidates[:3], Utilization{CPU: stats(90, 100), Memory: stats(90, 100)})
+	if err == nil {
+		t.Error("expected an error when no candidate fits")
+	}
+}
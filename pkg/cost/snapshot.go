@@ -0,0 +1,68 @@
+package cost
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+//go:embed pricing_snapshot.json
+var snapshotJSON []byte
+
+// PriceSnapshot is a set of on-demand prices for one region and OS, with
+// factors that estimate the prices of other regions
+type PriceSnapshot struct {
+	AsOf            string             `json:"as_of"`
+	Region          string             `json:"region"`
+	OperatingSystem string             `json:"operating_system"`
+	Prices          map[string]float64 `json:"prices"`         // Hourly USD by instance type
+	RegionFactors   map[string]float64 `json:"region_factors"` // Price of a region relative to Region
+}
+
+// SnapshotProvider prices instances from a PriceSnapshot. Prices in the
+// snapshot's region are exact; prices in other regions are estimated with
+// the region's factor, or the snapshot's price when the region has none.
+type SnapshotProvider struct {
+	snapshot PriceSnapshot
+}
+
+// NewSnapshotProvider returns a provider for the snapshot bundled with lens
+func NewSnapshotProvider() *SnapshotProvider {
+	var snapshot PriceSnapshot
+	if err := json.Unmarshal(snapshotJSON, &snapshot); err != nil {
+		panic(fmt.Sprintf("invalid bundled price snapshot: %v", err))
+	}
+	return NewSnapshotProviderFrom(snapshot)
+}
+
+// NewSnapshotProviderFrom returns a provider for the given snapshot
+func NewSnapshotProviderFrom(snapshot PriceSnapshot) *SnapshotProvider {
+	return &SnapshotProvider{snapshot: snapshot}
+}
+
+// HourlyPrice implements PricingProvider
+func (s *SnapshotProvider) HourlyPrice(ctx context.Context, query PriceQuery) (Price, error) {
+	query = query.normalized()
+
+	hourly, ok := s.snapshot.Prices[query.InstanceType]
+	if !ok || query.OS != s.snapshot.OperatingSystem {
+		return Price{}, fmt.Errorf("%w: %s is not in the %s price snapshot", ErrPriceNotFound, query, s.snapshot.AsOf)
+	}
+
+	source := "snapshot " + s.snapshot.AsOf
+	if query.Region == s.snapshot.Region {
+		return Price{Hourly: hourly, Quality: PriceExact, Source: source}, nil
+	}
+
+	factor, ok := s.snapshot.RegionFactors[query.Region]
+	if !ok {
+		factor = 1
+	}
+	return Price{
+		Hourly:  math.Round(hourly*factor*10000) / 10000,
+		Quality: PriceEstimated,
+		Source:  fmt.Sprintf("%s %s", s.snapshot.Region, source),
+	}, nil
+}
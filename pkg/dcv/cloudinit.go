@@ -3,6 +3,9 @@ package dcv
 import (
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/scttfrdmn/lens/pkg/agent"
 )
 
 // CloudInitOptions holds options for generating DCV cloud-init scripts
@@ -159,53 +162,12 @@ func GenerateGPUSetupScript() string {
 	return sb.String()
 }
 
-// GenerateIdleMonitorScript generates idle timeout monitoring
+// GenerateIdleMonitorScript installs lens-agent to stop the instance once no
+// client has been connected to the DCV session for the idle timeout
 func GenerateIdleMonitorScript(cfg *Config, idleTimeoutSeconds int) string {
-	var sb strings.Builder
-
-	sb.WriteString("# Setup idle timeout monitoring\n")
-	sb.WriteString(fmt.Sprintf("log_progress 'Configuring auto-stop after %d seconds of idle'\n\n", idleTimeoutSeconds))
-
-	sb.WriteString("# Create idle monitor script\n")
-	sb.WriteString("cat > /usr/local/bin/dcv-idle-monitor.sh << 'IDLESCRIPT'\n")
-	sb.WriteString("#!/bin/bash\n")
-	sb.WriteString(fmt.Sprintf("IDLE_TIMEOUT=%d\n", idleTimeoutSeconds))
-	sb.WriteString(fmt.Sprintf("SESSION_NAME=\"%s\"\n", cfg.SessionName))
-	sb.WriteString("\n")
-	sb.WriteString("while true; do\n")
-	sb.WriteString("  # Check if DCV session exists\n")
-	sb.WriteString("  if dcv list-sessions | grep -q \"$SESSION_NAME\"; then\n")
-	sb.WriteString("    # Check for active connections\n")
-	sb.WriteString("    CONNECTIONS=$(dcv list-connections -session $SESSION_NAME 2>/dev/null | wc -l)\n")
-	sb.WriteString("    if [ $CONNECTIONS -eq 0 ]; then\n")
-	sb.WriteString("      logger \"DCV idle timeout reached, shutting down\"\n")
-	sb.WriteString("      shutdown -h now\n")
-	sb.WriteString("    fi\n")
-	sb.WriteString("  fi\n")
-	sb.WriteString("  sleep 60\n")
-	sb.WriteString("done\n")
-	sb.WriteString("IDLESCRIPT\n\n")
-
-	sb.WriteString("chmod +x /usr/local/bin/dcv-idle-monitor.sh\n\n")
-
-	sb.WriteString("# Create systemd service for idle monitor\n")
-	sb.WriteString("cat > /etc/systemd/system/dcv-idle-monitor.service << 'IDLESERVICE'\n")
-	sb.WriteString("[Unit]\n")
-	sb.WriteString("Description=DCV Idle Monitor\n")
-	sb.WriteString("After=dcvserver.service\n")
-	sb.WriteString("\n")
-	sb.WriteString("[Service]\n")
-	sb.WriteString("Type=simple\n")
-	sb.WriteString("ExecStart=/usr/local/bin/dcv-idle-monitor.sh\n")
-	sb.WriteString("Restart=on-failure\n")
-	sb.WriteString("\n")
-	sb.WriteString("[Install]\n")
-	sb.WriteString("WantedBy=multi-user.target\n")
-	sb.WriteString("IDLESERVICE\n\n")
-
-	sb.WriteString("systemctl daemon-reload\n")
-	sb.WriteString("systemctl enable dcv-idle-monitor.service\n")
-	sb.WriteString("systemctl start dcv-idle-monitor.service\n\n")
-
-	return sb.String()
+	return agent.InstallScript(agent.InstallOptions{
+		IdleTimeout: time.Duration(idleTimeoutSeconds) * time.Second,
+		Signals:     []string{"dcv", "cpu", "gpu", "sessions"},
+		DCVSession:  cfg.SessionName,
+	})
 }
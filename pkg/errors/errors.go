@@ -102,7 +102,7 @@ func ConfigFileError(operation string, cause error) *ContextualError {
 		WithContext("There was an issue with your configuration file.").
 		WithSuggestions(
 			"Initialize config with: lens-vscode config init",
-			"Check config file at: ~/.aws-ide/config.yaml",
+			"Check config file at: ~/.lens/config.yaml",
 			"Validate YAML syntax if editing manually",
 		)
 }
@@ -0,0 +1,112 @@
+package fakecloud
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
+)
+
+// trailEvent is a management event recorded in the CloudTrail event history
+type trailEvent struct {
+	id         string
+	region     string
+	name       string
+	resourceID string
+	at         time.Time
+	username   string
+}
+
+// recordEvent adds an API call on a resource to the event history, made by
+// the current caller. Callers must hold c.mu.
+func (c *Cloud) recordEvent(region, name, resourceID string) {
+	c.trail = append(c.trail, trailEvent{
+		id:         c.nextID("event"),
+		region:     c.region(region).name,
+		name:       name,
+		resourceID: resourceID,
+		at:         c.now().UTC(),
+		username:   c.callerARN[strings.LastIndex(c.callerARN, "/")+1:],
+	})
+}
+
+// AddCloudTrailEvent records an event on an instance in the CloudTrail event
+// history, e.g. a "StopInstances" call made by a script on the instance or a
+// Spot interruption ("BidEvictedEvent")
+func (c *Cloud) AddCloudTrailEvent(region, name, instanceID string, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.trail = append(c.trail, trailEvent{
+		id:         c.nextID("event"),
+		region:     c.region(region).name,
+		name:       name,
+		resourceID: instanceID,
+		at:         at.UTC(),
+	})
+}
+
+// cloudTrailAPI implements aws.CloudTrailAPI for one region
+type cloudTrailAPI struct {
+	cloud  *Cloud
+	region string
+}
+
+// LookupEvents returns the events of the region between StartTime and
+// EndTime, newest first. Only the ResourceName and EventName lookup
+// attributes are supported.
+func (t *cloudTrailAPI) LookupEvents(ctx context.Context, params *cloudtrail.LookupEventsInput, optFns ...func(*cloudtrail.Options)) (*cloudtrail.LookupEventsOutput, error) {
+	t.cloud.mu.Lock()
+	defer t.cloud.mu.Unlock()
+	if err := t.cloud.injected("LookupEvents"); err != nil {
+		return nil, err
+	}
+	if len(params.LookupAttributes) > 1 {
+		return nil, APIError("InvalidLookupAttributesException", "You cannot specify more than one lookup attribute")
+	}
+
+	region := t.cloud.region(t.region).name
+	out := &cloudtrail.LookupEventsOutput{}
+	for i := len(t.cloud.trail) - 1; i >= 0; i-- {
+		event := t.cloud.trail[i]
+		if event.region != region {
+			continue
+		}
+		if params.StartTime != nil && event.at.Before(*params.StartTime) {
+			continue
+		}
+		if params.EndTime != nil && event.at.After(*params.EndTime) {
+			continue
+		}
+		if len(params.LookupAttributes) == 1 {
+			attribute := params.LookupAttributes[0]
+			switch attribute.AttributeKey {
+			case types.LookupAttributeKeyResourceName:
+				if event.resourceID != ptrValue(attribute.AttributeValue) {
+					continue
+				}
+			case types.LookupAttributeKeyEventName:
+				if event.name != ptrValue(attribute.AttributeValue) {
+					continue
+				}
+			default:
+				return nil, APIError("InvalidLookupAttributesException", "unsupported lookup attribute %s", attribute.AttributeKey)
+			}
+		}
+
+		result := types.Event{
+			EventId:     ptr(event.id),
+			EventName:   ptr(event.name),
+			EventSource: ptr("ec2.amazonaws.com"),
+			EventTime:   ptr(event.at),
+			ReadOnly:    ptr("false"),
+			Resources:   []types.Resource{{ResourceType: ptr("AWS::EC2::Instance"), ResourceName: ptr(event.resourceID)}},
+		}
+		if event.username != "" {
+			result.Username = ptr(event.username)
+		}
+		out.Events = append(out.Events, result)
+	}
+	return out, nil
+}
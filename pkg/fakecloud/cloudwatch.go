@@ -0,0 +1,444 @@
+package fakecloud
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// metricKey identifies a metric of an instance
+type metricKey struct {
+	region, namespace, instanceID, metric string
+}
+
+// metricSample is a raw value published at a point in time
+type metricSample struct {
+	at    time.Time
+	value float64
+}
+
+// AddInstanceMetric records a raw AWS/EC2 metric value (e.g. "NetworkOut"
+// bytes or "CPUUtilization" percent) of an instance at a point in time
+func (c *Cloud) AddInstanceMetric(region, instanceID, metric string, at time.Time, value float64) {
+	c.addMetric(region, "AWS/EC2", instanceID, metric, at, value)
+}
+
+// AddAgentMetric records a raw CloudWatch agent metric value (e.g.
+// "mem_used_percent") of an instance at a point in time, as published by an
+// agent that aggregates by InstanceId
+func (c *Cloud) AddAgentMetric(region, instanceID, metric string, at time.Time, value float64) {
+	c.addMetric(region, "CWAgent", instanceID, metric, at, value)
+}
+
+// AddKeepaliveMetric records the keepalive lens-agent publishes for an
+// instance at a point in time
+func (c *Cloud) AddKeepaliveMetric(region, instanceID string, at time.Time) {
+	c.addMetric(region, "Lens/Agent", instanceID, "Keepalive", at, 1)
+}
+
+// addMetric records a raw metric value in a namespace
+func (c *Cloud) addMetric(region, namespace, instanceID, metric string, at time.Time, value float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := metricKey{c.region(region).name, namespace, instanceID, metric}
+	c.metrics[key] = append(c.metrics[key], metricSample{at: at, value: value})
+}
+
+// cloudWatchAPI implements aws.CloudWatchAPI for one region
+type cloudWatchAPI struct {
+	cloud  *Cloud
+	region string
+}
+
+// GetMetricStatistics aggregates the samples of an instance metric
+// into periods starting at StartTime. Periods without samples are omitted.
+func (w *cloudWatchAPI) GetMetricStatistics(ctx context.Context, params *cloudwatch.GetMetricStatisticsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.GetMetricStatisticsOutput, error) {
+	w.cloud.mu.Lock()
+	defer w.cloud.mu.Unlock()
+	if err := w.cloud.injected("GetMetricStatistics"); err != nil {
+		return nil, err
+	}
+
+	period := time.Duration(ptrValue(params.Period)) * time.Second
+	if period <= 0 || params.StartTime == nil || params.EndTime == nil {
+		return nil, APIError("InvalidParameterCombination", "StartTime, EndTime and Period are required")
+	}
+
+	instanceID := ""
+	for _, dimension := range params.Dimensions {
+		if ptrValue(dimension.Name) == "InstanceId" {
+			instanceID = ptrValue(dimension.Value)
+		}
+	}
+	out := &cloudwatch.GetMetricStatisticsOutput{Label: params.MetricName}
+	if instanceID == "" {
+		return out, nil
+	}
+
+	start, end := *params.StartTime, *params.EndTime
+	buckets := make(map[time.Time][]float64)
+	key := metricKey{w.cloud.region(w.region).name, ptrValue(params.Namespace), instanceID, ptrValue(params.MetricName)}
+	for _, sample := range w.cloud.metrics[key] {
+		if sample.at.Before(start) || !sample.at.Before(end) {
+			continue
+		}
+		bucket := start.Add(sample.at.Sub(start) / period * period)
+		buckets[bucket] = append(buckets[bucket], sample.value)
+	}
+
+	for bucket, values := range buckets {
+		point := types.Datapoint{Timestamp: ptr(bucket), Unit: types.StandardUnitNone}
+		sum, minimum, maximum := 0.0, values[0], values[0]
+		for _, value := range values {
+			sum += value
+			minimum = min(minimum, value)
+			maximum = max(maximum, value)
+		}
+		for _, statistic := range params.Statistics {
+			switch statistic {
+			case types.StatisticSum:
+				point.Sum = ptr(sum)
+			case types.StatisticAverage:
+				point.Average = ptr(sum / float64(len(values)))
+			case types.StatisticMinimum:
+				point.Minimum = ptr(minimum)
+			case types.StatisticMaximum:
+				point.Maximum = ptr(maximum)
+			case types.StatisticSampleCount:
+				point.SampleCount = ptr(float64(len(values)))
+			}
+		}
+		out.Datapoints = append(out.Datapoints, point)
+	}
+	sort.Slice(out.Datapoints, func(i, j int) bool {
+		return out.Datapoints[i].Timestamp.Before(*out.Datapoints[j].Timestamp)
+	})
+	return out, nil
+}
+
+// alarmKey identifies an alarm
+type alarmKey struct {
+	region, name string
+}
+
+// maxAlarmEvaluationSeconds is the longest range an alarm of periods shorter
+// than an hour can evaluate; alarms of hourly periods can evaluate a week
+const (
+	maxAlarmEvaluationSeconds       = 24 * 60 * 60
+	maxHourlyAlarmEvaluationSeconds = 7 * 24 * 60 * 60
+)
+
+// PutMetricAlarm creates or replaces an alarm on a metric or a metric math
+// expression. A new or replaced alarm starts in INSUFFICIENT_DATA; alarms are
+// only evaluated by EvaluateAlarm, or moved with SetAlarmState.
+func (w *cloudWatchAPI) PutMetricAlarm(ctx context.Context, params *cloudwatch.PutMetricAlarmInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricAlarmOutput, error) {
+	w.cloud.mu.Lock()
+	defer w.cloud.mu.Unlock()
+	if err := w.cloud.injected("PutMetricAlarm"); err != nil {
+		return nil, err
+	}
+
+	name := ptrValue(params.AlarmName)
+	periods := ptrValue(params.EvaluationPeriods)
+	period := ptrValue(params.Period)
+	if name == "" || periods <= 0 || params.ComparisonOperator == "" {
+		return nil, APIError("ValidationError", "AlarmName, EvaluationPeriods and ComparisonOperator are required")
+	}
+	if (params.MetricName == nil) == (len(params.Metrics) == 0) {
+		return nil, APIError("ValidationError", "Exactly one of MetricName and Metrics must be given")
+	}
+	for _, query := range params.Metrics {
+		if query.MetricStat != nil {
+			period = ptrValue(query.MetricStat.Period)
+		} else if _, err := parseExpression(ptrValue(query.Expression)); err != nil {
+			return nil, APIError("ValidationError", "Invalid metrics expression %s: %v", ptrValue(query.Expression), err)
+		}
+	}
+	if period < 3600 && period*periods > maxAlarmEvaluationSeconds {
+		return nil, APIError("ValidationError", "Metrics cannot be checked across more than a day (EvaluationPeriods * Period must be <= 86400)")
+	}
+	if period*periods > maxHourlyAlarmEvaluationSeconds {
+		return nil, APIError("ValidationError", "Metrics cannot be checked across more than a week (EvaluationPeriods * Period must be <= 604800)")
+	}
+
+	region := w.cloud.region(w.region).name
+	w.cloud.alarms[alarmKey{region, name}] = &types.MetricAlarm{
+		AlarmName:             ptr(name),
+		AlarmArn:              ptr(fmt.Sprintf("arn:aws:cloudwatch:%s:%s:alarm:%s", region, AccountID, name)),
+		AlarmDescription:      params.AlarmDescription,
+		Namespace:             params.Namespace,
+		MetricName:            params.MetricName,
+		Dimensions:            params.Dimensions,
+		Metrics:               params.Metrics,
+		Statistic:             params.Statistic,
+		Period:                params.Period,
+		EvaluationPeriods:     params.EvaluationPeriods,
+		Threshold:             params.Threshold,
+		ComparisonOperator:    params.ComparisonOperator,
+		TreatMissingData:      params.TreatMissingData,
+		AlarmActions:          params.AlarmActions,
+		ActionsEnabled:        ptr(params.ActionsEnabled == nil || *params.ActionsEnabled),
+		StateValue:            types.StateValueInsufficientData,
+		StateReason:           ptr("Unchecked: Initial alarm creation"),
+		StateUpdatedTimestamp: ptr(w.cloud.now()),
+	}
+	return &cloudwatch.PutMetricAlarmOutput{}, nil
+}
+
+// DescribeAlarms returns metric alarms by name or name prefix, sorted by name
+func (w *cloudWatchAPI) DescribeAlarms(ctx context.Context, params *cloudwatch.DescribeAlarmsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.DescribeAlarmsOutput, error) {
+	w.cloud.mu.Lock()
+	defer w.cloud.mu.Unlock()
+	if err := w.cloud.injected("DescribeAlarms"); err != nil {
+		return nil, err
+	}
+
+	region := w.cloud.region(w.region).name
+	prefix := ptrValue(params.AlarmNamePrefix)
+	names := make(map[string]bool, len(params.AlarmNames))
+	for _, name := range params.AlarmNames {
+		names[name] = true
+	}
+	out := &cloudwatch.DescribeAlarmsOutput{}
+	for key, alarm := range w.cloud.alarms {
+		if key.region != region || !strings.HasPrefix(key.name, prefix) || (len(names) > 0 && !names[key.name]) {
+			continue
+		}
+		out.MetricAlarms = append(out.MetricAlarms, *alarm)
+	}
+	sort.Slice(out.MetricAlarms, func(i, j int) bool {
+		return *out.MetricAlarms[i].AlarmName < *out.MetricAlarms[j].AlarmName
+	})
+	return out, nil
+}
+
+// DeleteAlarms deletes alarms. Nothing is deleted if any of them does not exist.
+func (w *cloudWatchAPI) DeleteAlarms(ctx context.Context, params *cloudwatch.DeleteAlarmsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.DeleteAlarmsOutput, error) {
+	w.cloud.mu.Lock()
+	defer w.cloud.mu.Unlock()
+	if err := w.cloud.injected("DeleteAlarms"); err != nil {
+		return nil, err
+	}
+
+	region := w.cloud.region(w.region).name
+	for _, name := range params.AlarmNames {
+		if _, ok := w.cloud.alarms[alarmKey{region, name}]; !ok {
+			return nil, APIError("ResourceNotFound", "%s not found", name)
+		}
+	}
+	for _, name := range params.AlarmNames {
+		delete(w.cloud.alarms, alarmKey{region, name})
+	}
+	return &cloudwatch.DeleteAlarmsOutput{}, nil
+}
+
+// Alarm returns a copy of a metric alarm
+func (c *Cloud) Alarm(region, name string) (types.MetricAlarm, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	alarm, ok := c.alarms[alarmKey{c.region(region).name, name}]
+	if !ok {
+		return types.MetricAlarm{}, false
+	}
+	return *alarm, true
+}
+
+// SetAlarmState moves an alarm into a state, e.g. to simulate CloudWatch
+// finding an instance idle. An alarm entering ALARM with the EC2 stop action
+// stops the instance of its InstanceId dimension.
+func (c *Cloud) SetAlarmState(region, name string, state types.StateValue) error {
+	return c.setAlarmState(region, name, state, "Set by test")
+}
+
+// EvaluateAlarm evaluates an alarm over its evaluation periods up to now from
+// the recorded metric samples, as CloudWatch does after every period, moves
+// it into the resulting state and returns the state. The alarm is
+// INSUFFICIENT_DATA if any period has no data, and ALARM if every period
+// breaches its threshold.
+func (c *Cloud) EvaluateAlarm(region, name string) (types.StateValue, error) {
+	c.mu.Lock()
+	alarm, ok := c.alarms[alarmKey{c.region(region).name, name}]
+	if !ok {
+		c.mu.Unlock()
+		return "", fmt.Errorf("alarm %s not found", name)
+	}
+	values, err := c.alarmValues(c.region(region).name, *alarm)
+	c.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	state := types.StateValueAlarm
+	threshold := ptrValue(alarm.Threshold)
+	for _, value := range values {
+		if math.IsNaN(value) {
+			state = types.StateValueInsufficientData
+			break
+		}
+		if !breaches(alarm.ComparisonOperator, value, threshold) {
+			state = types.StateValueOk
+		}
+	}
+	return state, c.setAlarmState(region, name, state, fmt.Sprintf("Evaluated %v against %g", values, threshold))
+}
+
+// alarmValues returns the value an alarm watches in each of its evaluation
+// periods up to now, NaN where there is no data. Callers must hold c.mu.
+func (c *Cloud) alarmValues(region string, alarm types.MetricAlarm) ([]float64, error) {
+	queries := alarm.Metrics
+	if len(queries) == 0 {
+		queries = []types.MetricDataQuery{{
+			Id: ptr("m"),
+			MetricStat: &types.MetricStat{
+				Metric: &types.Metric{Namespace: alarm.Namespace, MetricName: alarm.MetricName, Dimensions: alarm.Dimensions},
+				Period: alarm.Period,
+				Stat:   ptr(string(alarm.Statistic)),
+			},
+		}}
+	}
+	var returned types.MetricDataQuery
+	period := time.Duration(0)
+	for _, query := range queries {
+		if query.MetricStat != nil {
+			period = time.Duration(ptrValue(query.MetricStat.Period)) * time.Second
+		}
+		if len(queries) == 1 || ptrValue(query.ReturnData) {
+			returned = query
+		}
+	}
+	var expression metricExpression
+	if returned.Expression != nil {
+		var err error
+		if expression, err = parseExpression(*returned.Expression); err != nil {
+			return nil, err
+		}
+	}
+
+	periods := int(ptrValue(alarm.EvaluationPeriods))
+	values := make([]float64, periods)
+	end := c.now()
+	for i := range values {
+		start := end.Add(-time.Duration(periods-i) * period)
+		stats := make(map[string]float64)
+		for _, query := range queries {
+			if query.MetricStat != nil {
+				stats[ptrValue(query.Id)] = c.statistic(region, *query.MetricStat, start, start.Add(period))
+			}
+		}
+		if expression != nil {
+			values[i] = expression(stats)
+		} else {
+			values[i] = stats[ptrValue(returned.Id)]
+		}
+	}
+	return values, nil
+}
+
+// statistic returns a statistic of the samples of an instance metric from
+// start until end, or NaN if there are none. Callers must hold c.mu.
+func (c *Cloud) statistic(region string, stat types.MetricStat, start, end time.Time) float64 {
+	key := metricKey{region, ptrValue(stat.Metric.Namespace), dimensionValue(stat.Metric.Dimensions, "InstanceId"), ptrValue(stat.Metric.MetricName)}
+	var values []float64
+	for _, sample := range c.metrics[key] {
+		if !sample.at.Before(start) && sample.at.Before(end) {
+			values = append(values, sample.value)
+		}
+	}
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	sum, minimum, maximum := 0.0, values[0], values[0]
+	for _, value := range values {
+		sum += value
+		minimum = min(minimum, value)
+		maximum = max(maximum, value)
+	}
+	switch types.Statistic(ptrValue(stat.Stat)) {
+	case types.StatisticSum:
+		return sum
+	case types.StatisticMinimum:
+		return minimum
+	case types.StatisticMaximum:
+		return maximum
+	case types.StatisticSampleCount:
+		return float64(len(values))
+	}
+	return sum / float64(len(values))
+}
+
+// breaches reports whether a value breaches an alarm's threshold
+func breaches(operator types.ComparisonOperator, value, threshold float64) bool {
+	switch operator {
+	case types.ComparisonOperatorLessThanThreshold:
+		return value < threshold
+	case types.ComparisonOperatorLessThanOrEqualToThreshold:
+		return value <= threshold
+	case types.ComparisonOperatorGreaterThanThreshold:
+		return value > threshold
+	}
+	return value >= threshold
+}
+
+// dimensionValue returns the value of a dimension, or "" if it is not set
+func dimensionValue(dimensions []types.Dimension, name string) string {
+	for _, dimension := range dimensions {
+		if ptrValue(dimension.Name) == name {
+			return ptrValue(dimension.Value)
+		}
+	}
+	return ""
+}
+
+// setAlarmState moves an alarm into a state and runs its EC2 stop action
+// when it enters ALARM
+func (c *Cloud) setAlarmState(region, name string, state types.StateValue, reason string) error {
+	c.mu.Lock()
+	alarm, ok := c.alarms[alarmKey{c.region(region).name, name}]
+	if !ok {
+		c.mu.Unlock()
+		return fmt.Errorf("alarm %s not found", name)
+	}
+	entered := alarm.StateValue != state
+	alarm.StateValue = state
+	alarm.StateReason = ptr(reason)
+	alarm.StateUpdatedTimestamp = ptr(c.now())
+
+	stop := ""
+	if state == types.StateValueAlarm && entered && ptrValue(alarm.ActionsEnabled) {
+		for _, action := range alarm.AlarmActions {
+			if strings.HasPrefix(action, "arn:aws:automate:") && strings.HasSuffix(action, ":ec2:stop") {
+				stop = alarmInstanceID(*alarm)
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	if stop == "" {
+		return nil
+	}
+	return c.SetInstanceState(stop, ec2types.InstanceStateNameStopped)
+}
+
+// alarmInstanceID returns the instance of an alarm's InstanceId dimension,
+// on its metric or on the metrics of its metric math
+func alarmInstanceID(alarm types.MetricAlarm) string {
+	if id := dimensionValue(alarm.Dimensions, "InstanceId"); id != "" {
+		return id
+	}
+	for _, query := range alarm.Metrics {
+		if query.MetricStat != nil {
+			if id := dimensionValue(query.MetricStat.Metric.Dimensions, "InstanceId"); id != "" {
+				return id
+			}
+		}
+	}
+	return ""
+}
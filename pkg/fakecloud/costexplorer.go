@@ -0,0 +1,96 @@
+package fakecloud
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+// billedCost is a cost billed on a day to resources with the given tags
+type billedCost struct {
+	day    time.Time
+	tags   map[string]string
+	amount float64
+}
+
+// AddBilledCost records a cost billed on a day to resources carrying the
+// given tags, as reported by Cost Explorer
+func (c *Cloud) AddBilledCost(day time.Time, tags map[string]string, amount float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.costs = append(c.costs, billedCost{day: day.UTC(), tags: tags, amount: amount})
+}
+
+// costExplorerAPI implements aws.CostExplorerAPI
+type costExplorerAPI struct {
+	cloud *Cloud
+}
+
+// GetCostAndUsage sums the billed costs between the start and end dates per
+// calendar month, grouped by the value of one tag. Only MONTHLY granularity
+// and grouping by a single tag are supported. Months that have not ended are
+// reported as estimated.
+func (x *costExplorerAPI) GetCostAndUsage(ctx context.Context, params *costexplorer.GetCostAndUsageInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetCostAndUsageOutput, error) {
+	x.cloud.mu.Lock()
+	defer x.cloud.mu.Unlock()
+	if err := x.cloud.injected("GetCostAndUsage"); err != nil {
+		return nil, err
+	}
+
+	if params.TimePeriod == nil || params.Granularity != types.GranularityMonthly || len(params.Metrics) == 0 {
+		return nil, APIError("ValidationException", "TimePeriod, MONTHLY granularity and Metrics are required")
+	}
+	if len(params.GroupBy) != 1 || params.GroupBy[0].Type != types.GroupDefinitionTypeTag {
+		return nil, APIError("ValidationException", "only grouping by one tag is supported")
+	}
+	start, err := time.Parse("2006-01-02", ptrValue(params.TimePeriod.Start))
+	if err != nil {
+		return nil, APIError("ValidationException", "invalid start date %q", ptrValue(params.TimePeriod.Start))
+	}
+	end, err := time.Parse("2006-01-02", ptrValue(params.TimePeriod.End))
+	if err != nil || !start.Before(end) {
+		return nil, APIError("ValidationException", "invalid end date %q", ptrValue(params.TimePeriod.End))
+	}
+	tagKey := ptrValue(params.GroupBy[0].Key)
+	metric := params.Metrics[0]
+	now := x.cloud.now().UTC()
+
+	out := &costexplorer.GetCostAndUsageOutput{GroupDefinitions: params.GroupBy}
+	for month := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC); month.Before(end); month = month.AddDate(0, 1, 0) {
+		periodStart, periodEnd := month, month.AddDate(0, 1, 0)
+		if periodStart.Before(start) {
+			periodStart = start
+		}
+		if periodEnd.After(end) {
+			periodEnd = end
+		}
+
+		amounts := make(map[string]float64)
+		for _, cost := range x.cloud.costs {
+			if !cost.day.Before(periodStart) && cost.day.Before(periodEnd) {
+				amounts[cost.tags[tagKey]] += cost.amount
+			}
+		}
+
+		result := types.ResultByTime{
+			TimePeriod: &types.DateInterval{
+				Start: ptr(periodStart.Format("2006-01-02")),
+				End:   ptr(periodEnd.Format("2006-01-02")),
+			},
+			Estimated: now.Before(month.AddDate(0, 1, 0)),
+		}
+		for _, value := range sortedKeys(amounts) {
+			result.Groups = append(result.Groups, types.Group{
+				Keys: []string{tagKey + "$" + value},
+				Metrics: map[string]types.MetricValue{
+					metric: {Amount: ptr(strconv.FormatFloat(amounts[value], 'f', -1, 64)), Unit: ptr("USD")},
+				},
+			})
+		}
+		out.ResultsByTime = append(out.ResultsByTime, result)
+	}
+	return out, nil
+}
@@ -0,0 +1,317 @@
+// Package fakecloud provides a stateful, in-memory implementation of the EC2,
+// IAM and Systems Manager APIs used by lens.
+//
+// Installing a Cloud routes every client created through pkg/aws to it, so
+// commands such as launch, stop, start and terminate can be exercised in plain
+// `go test` runs without AWS credentials:
+//
+//	cloud := fakecloud.Install(t)
+//	// ... run the command under test ...
+//	inst, _ := cloud.Instance(instanceID)
+//
+// Lifecycle calls report the transitional state ("pending", "stopping",
+// "shutting-down") and the next describe call observes the final state, so
+// the SDK waiters used by pkg/aws complete on their first poll.
+package fakecloud
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+	"github.com/scttfrdmn/lens/pkg/aws"
+)
+
+const (
+	// DefaultRegion is the region used by clients that do not name one
+	DefaultRegion = "us-east-1"
+
+	// AccountID is the account that owns every resource created in the fake cloud
+	AccountID = "123456789012"
+
+	// CanonicalOwnerID is the owner of the seeded Ubuntu images
+	CanonicalOwnerID = "099720109477"
+
+	// AmazonOwnerID is the owner of the seeded Amazon Linux images
+	AmazonOwnerID = "137112412989"
+
+	// CallerARN is the identity returned by GetCallerIdentity unless changed with SetCaller
+	CallerARN = "arn:aws:iam::" + AccountID + ":user/researcher"
+)
+
+// Cloud is an in-memory AWS account. It implements aws.Provider.
+// The zero value is not usable; create one with New or Install.
+type Cloud struct {
+	mu sync.Mutex
+
+	defaultRegion  string
+	callerARN      string
+	enabledRegions []string
+	regions        map[string]*regionState
+	iam            *iamState
+	buckets        map[string]map[string]*s3Object
+	prices         map[priceKey]float64
+	metrics        map[metricKey][]metricSample
+	alarms         map[alarmKey]*cwtypes.MetricAlarm
+	scheduleGroups map[scheduleKey]map[string]string
+	schedules      map[scheduleKey]*aws.ScheduleDefinition
+	costs          []billedCost
+	trail          []trailEvent
+
+	seq             int
+	restrictedTypes map[string][]string
+	failures        map[string][]error
+	handler         CommandHandler
+	invocations     []Invocation
+	commands        map[string]*commandInvocation
+	now             func() time.Time
+}
+
+// New creates an empty cloud whose default region is DefaultRegion.
+// Regions are created on first use with a default VPC, two availability zones
+// (each with a public and a private subnet) and a set of public Ubuntu and
+// Amazon Linux images.
+func New() *Cloud {
+	return &Cloud{
+		defaultRegion:  DefaultRegion,
+		callerARN:      CallerARN,
+		enabledRegions: []string{DefaultRegion},
+		regions:        make(map[string]*regionState),
+		iam:            newIAMState(),
+		buckets:        make(map[string]map[string]*s3Object),
+		prices:         make(map[priceKey]float64),
+		metrics:        make(map[metricKey][]metricSample),
+		alarms:         make(map[alarmKey]*cwtypes.MetricAlarm),
+		scheduleGroups: make(map[scheduleKey]map[string]string),
+		schedules:      make(map[scheduleKey]*aws.ScheduleDefinition),
+		failures:       make(map[string][]error),
+		handler:        DefaultCommandHandler,
+		now:            time.Now,
+	}
+}
+
+// Install creates a new cloud, routes all pkg/aws clients through it and
+// restores the previous provider when the test finishes.
+func Install(tb testing.TB) *Cloud {
+	tb.Helper()
+
+	c := New()
+	tb.Cleanup(aws.UseProvider(c))
+	return c
+}
+
+// DefaultRegion returns the region used by clients that do not specify one
+func (c *Cloud) DefaultRegion() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.defaultRegion
+}
+
+// SetDefaultRegion changes the region returned to profile-based clients
+func (c *Cloud) SetDefaultRegion(region string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.defaultRegion = region
+}
+
+// SetRegions sets the regions reported as enabled by DescribeRegions, in
+// addition to any region a client has used
+func (c *Cloud) SetRegions(regions ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enabledRegions = append([]string(nil), regions...)
+}
+
+// SetClock overrides the time source used for launch times and creation dates
+func (c *Cloud) SetClock(now func() time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// EC2 returns the EC2 API for a region
+func (c *Cloud) EC2(region string) aws.EC2API {
+	return &ec2API{cloud: c, region: region}
+}
+
+// IAM returns the IAM API
+func (c *Cloud) IAM() aws.IAMAPI {
+	return &iamAPI{cloud: c}
+}
+
+// SSM returns the Systems Manager API for a region
+func (c *Cloud) SSM(region string) aws.SSMAPI {
+	return &ssmAPI{cloud: c, region: region}
+}
+
+// STS returns the Security Token Service API
+func (c *Cloud) STS() aws.STSAPI {
+	return &stsAPI{cloud: c}
+}
+
+// S3 returns the S3 API. Buckets are shared by all regions.
+func (c *Cloud) S3(region string) aws.S3API {
+	return &s3API{cloud: c}
+}
+
+// Pricing returns the Price List API
+func (c *Cloud) Pricing() aws.PricingAPI {
+	return &pricingAPI{cloud: c}
+}
+
+// CloudWatch returns the CloudWatch API for a region
+func (c *Cloud) CloudWatch(region string) aws.CloudWatchAPI {
+	return &cloudWatchAPI{cloud: c, region: region}
+}
+
+// CostExplorer returns the Cost Explorer API
+func (c *Cloud) CostExplorer() aws.CostExplorerAPI {
+	return &costExplorerAPI{cloud: c}
+}
+
+// CloudTrail returns the CloudTrail API for a region
+func (c *Cloud) CloudTrail(region string) aws.CloudTrailAPI {
+	return &cloudTrailAPI{cloud: c, region: region}
+}
+
+// Scheduler returns the EventBridge Scheduler API for a region
+func (c *Cloud) Scheduler(region string) aws.SchedulerAPI {
+	return &schedulerAPI{cloud: c, region: region}
+}
+
+// FailNext makes the next call to the named operation (for example
+// "RunInstances" or "CreateRole") return err instead of executing.
+// Calls queue up: registering two errors fails the next two calls.
+func (c *Cloud) FailNext(operation string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures[operation] = append(c.failures[operation], err)
+}
+
+// injected pops a queued failure for the operation. Callers must hold c.mu.
+func (c *Cloud) injected(operation string) error {
+	queue := c.failures[operation]
+	if len(queue) == 0 {
+		return nil
+	}
+	c.failures[operation] = queue[1:]
+	return queue[0]
+}
+
+// nextID returns a new resource ID with the given prefix (e.g. "i", "sg").
+// IDs have a fixed width so that sorting them preserves creation order.
+// Callers must hold c.mu.
+func (c *Cloud) nextID(prefix string) string {
+	c.seq++
+	return fmt.Sprintf("%s-%017x", prefix, c.seq)
+}
+
+// APIError builds an error that satisfies smithy.APIError, matching what the
+// AWS SDK returns for service faults.
+func APIError(code, format string, args ...interface{}) error {
+	return &smithy.GenericAPIError{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+		Fault:   smithy.FaultClient,
+	}
+}
+
+// matchFilters reports whether a resource matches every filter. The lookup
+// function returns the resource's values for a filter name, and false when
+// the filter name is not supported for the resource type. Filter values may
+// contain * and ? wildcards as in the EC2 API.
+func matchFilters(filters []types.Filter, lookup func(name string) ([]string, bool)) (bool, error) {
+	for _, filter := range filters {
+		name := ptrValue(filter.Name)
+		values, ok := lookup(name)
+		if !ok {
+			return false, APIError("InvalidParameterValue", "The filter '%s' is invalid", name)
+		}
+		if !matchAny(filter.Values, values) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// matchAny reports whether any actual value matches any of the patterns
+func matchAny(patterns, actual []string) bool {
+	for _, pattern := range patterns {
+		for _, value := range actual {
+			if ok, _ := path.Match(pattern, value); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tagLookup resolves the tag:<key> and tag-key filters against a tag set
+func tagLookup(tags []types.Tag, name string) ([]string, bool) {
+	if name == "tag-key" {
+		keys := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			keys = append(keys, ptrValue(tag.Key))
+		}
+		return keys, true
+	}
+	if key, ok := strings.CutPrefix(name, "tag:"); ok {
+		for _, tag := range tags {
+			if ptrValue(tag.Key) == key {
+				return []string{ptrValue(tag.Value)}, true
+			}
+		}
+		return nil, true
+	}
+	return nil, false
+}
+
+// tagsFor collects the tags requested for a resource type in tag specifications
+func tagsFor(specs []types.TagSpecification, resourceType types.ResourceType) []types.Tag {
+	var tags []types.Tag
+	for _, spec := range specs {
+		if spec.ResourceType == resourceType {
+			tags = append(tags, spec.Tags...)
+		}
+	}
+	return tags
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// contains reports whether list contains value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func ptrValue[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
@@ -0,0 +1,323 @@
+package fakecloud_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/fakecloud"
+)
+
+// launchTestInstance launches an instance through pkg/aws against the fake cloud
+func launchTestInstance(t *testing.T, ctx context.Context, client *aws.EC2Client, profile string) *types.Instance {
+	t.Helper()
+
+	subnet, err := client.GetSubnet(ctx, "public", "")
+	if err != nil {
+		t.Fatalf("GetSubnet failed: %v", err)
+	}
+
+	sg, err := client.GetOrCreateSecurityGroup(ctx, aws.DefaultSecurityGroupStrategy(subnet.VpcID))
+	if err != nil {
+		t.Fatalf("GetOrCreateSecurityGroup failed: %v", err)
+	}
+
+	amiID, err := aws.NewAMISelector(client.GetRegion()).GetAMI(ctx, client, "ubuntu24-arm64")
+	if err != nil {
+		t.Fatalf("GetAMI failed: %v", err)
+	}
+
+	inst, err := client.LaunchInstance(ctx, aws.LaunchParams{
+		AMI:             amiID,
+		InstanceType:    "t4g.medium",
+		SecurityGroupID: sg.ID,
+		SubnetID:        subnet.ID,
+		EBSVolumeSize:   20,
+		Environment:     "minimal",
+		InstanceProfile: profile,
+	})
+	if err != nil {
+		t.Fatalf("LaunchInstance failed: %v", err)
+	}
+	return inst
+}
+
+func TestInstall_RoutesClientsToFake(t *testing.T) {
+	cloud := fakecloud.Install(t)
+	ctx := context.Background()
+
+	client, err := aws.NewEC2Client(ctx, "default")
+	if err != nil {
+		t.Fatalf("NewEC2Client failed: %v", err)
+	}
+	if client.GetRegion() != fakecloud.DefaultRegion {
+		t.Errorf("Expected region %s, got %s", fakecloud.DefaultRegion, client.GetRegion())
+	}
+
+	regional, err := aws.NewEC2ClientForRegion(ctx, "eu-west-1")
+	if err != nil {
+		t.Fatalf("NewEC2ClientForRegion failed: %v", err)
+	}
+	if regional.GetRegion() != "eu-west-1" {
+		t.Errorf("Expected region eu-west-1, got %s", regional.GetRegion())
+	}
+
+	inst := launchTestInstance(t, ctx, regional, "")
+	if _, ok := cloud.Instance(*inst.InstanceId); !ok {
+		t.Error("Expected launched instance to exist in the fake cloud")
+	}
+	if len(cloud.Instances("us-east-1")) != 0 {
+		t.Error("Expected no instances in us-east-1")
+	}
+}
+
+func TestInstanceLifecycle(t *testing.T) {
+	fakecloud.Install(t)
+	ctx := context.Background()
+
+	client, err := aws.NewEC2ClientForRegion(ctx, "")
+	if err != nil {
+		t.Fatalf("NewEC2ClientForRegion failed: %v", err)
+	}
+
+	inst := launchTestInstance(t, ctx, client, "")
+	id := *inst.InstanceId
+
+	if inst.State.Name != types.InstanceStateNamePending {
+		t.Errorf("Expected new instance to be pending, got %s", inst.State.Name)
+	}
+	if inst.PublicIpAddress == nil {
+		t.Error("Expected instance in public subnet to have a public IP")
+	}
+
+	if err := client.WaitForInstanceRunning(ctx, id); err != nil {
+		t.Fatalf("WaitForInstanceRunning failed: %v", err)
+	}
+
+	steps := []struct {
+		name  string
+		act   func() error
+		final types.InstanceStateName
+	}{
+		{"stop", func() error { return client.StopInstance(ctx, id, false) }, types.InstanceStateNameStopped},
+		{"start", func() error { return client.StartInstance(ctx, id) }, types.InstanceStateNameRunning},
+		{"terminate", func() error { return client.TerminateInstance(ctx, id) }, types.InstanceStateNameTerminated},
+	}
+
+	for _, step := range steps {
+		if err := step.act(); err != nil {
+			t.Fatalf("%s failed: %v", step.name, err)
+		}
+		info, err := client.GetInstanceInfo(ctx, id)
+		if err != nil {
+			t.Fatalf("GetInstanceInfo failed: %v", err)
+		}
+		if info.State.Name != step.final {
+			t.Errorf("After %s: expected state %s, got %s", step.name, step.final, info.State.Name)
+		}
+	}
+
+	if err := client.StartInstance(ctx, id); err == nil {
+		t.Error("Expected starting a terminated instance to fail")
+	}
+}
+
+func TestGetAMI_SelectsNewestImage(t *testing.T) {
+	cloud := fakecloud.Install(t)
+	ctx := context.Background()
+
+	newer := cloud.AddImage(fakecloud.DefaultRegion,
+		"ubuntu/images/hvm-ssd/ubuntu-noble-24.04-arm64-server-20990101",
+		types.ArchitectureValuesArm64, fakecloud.CanonicalOwnerID, time.Now())
+
+	client, _ := aws.NewEC2Client(ctx, "default")
+	amiID, err := aws.NewAMISelector(client.GetRegion()).GetAMI(ctx, client, "ubuntu24-arm64")
+	if err != nil {
+		t.Fatalf("GetAMI failed: %v", err)
+	}
+	if amiID != newer {
+		t.Errorf("Expected newest image %s, got %s", newer, amiID)
+	}
+
+	for _, base := range []string{"ubuntu22-x86_64", "ubuntu20-arm64", "amazonlinux2-arm64", "amazonlinux2-x86_64"} {
+		if _, err := aws.NewAMISelector(client.GetRegion()).GetAMI(ctx, client, base); err != nil {
+			t.Errorf("GetAMI(%s) failed: %v", base, err)
+		}
+	}
+}
+
+func TestKeyPairs(t *testing.T) {
+	cloud := fakecloud.Install(t)
+	ctx := context.Background()
+	client, _ := aws.NewEC2Client(ctx, "default")
+
+	strategy := aws.DefaultKeyPairStrategy(client.GetRegion())
+	created, err := client.GetOrCreateKeyPair(ctx, strategy)
+	if err != nil {
+		t.Fatalf("GetOrCreateKeyPair failed: %v", err)
+	}
+	if created.PrivateKey == "" {
+		t.Error("Expected private key material for a new key pair")
+	}
+
+	reused, err := client.GetOrCreateKeyPair(ctx, strategy)
+	if err != nil {
+		t.Fatalf("GetOrCreateKeyPair (reuse) failed: %v", err)
+	}
+	if reused.PrivateKey != "" {
+		t.Error("Expected no private key material for an existing key pair")
+	}
+
+	exists, err := client.KeyPairExists(ctx, "missing")
+	if err != nil || exists {
+		t.Errorf("KeyPairExists(missing) = %v, %v; want false, nil", exists, err)
+	}
+
+	if err := client.DeleteKeyPair(ctx, created.Name); err != nil {
+		t.Fatalf("DeleteKeyPair failed: %v", err)
+	}
+	if len(cloud.KeyPairs(fakecloud.DefaultRegion)) != 0 {
+		t.Error("Expected key pair to be deleted")
+	}
+}
+
+func TestSecurityGroupInUseCannotBeDeleted(t *testing.T) {
+	cloud := fakecloud.Install(t)
+	ctx := context.Background()
+	client, _ := aws.NewEC2Client(ctx, "default")
+
+	inst := launchTestInstance(t, ctx, client, "")
+	groupID := *inst.SecurityGroups[0].GroupId
+
+	api := cloud.EC2(fakecloud.DefaultRegion)
+	_, err := api.DeleteSecurityGroup(ctx, &ec2.DeleteSecurityGroupInput{GroupId: &groupID})
+	assertErrorCode(t, err, "DependencyViolation")
+
+	if err := client.TerminateInstance(ctx, *inst.InstanceId); err != nil {
+		t.Fatalf("TerminateInstance failed: %v", err)
+	}
+	_ = cloud.SetInstanceState(*inst.InstanceId, types.InstanceStateNameTerminated)
+
+	if _, err := api.DeleteSecurityGroup(ctx, &ec2.DeleteSecurityGroupInput{GroupId: &groupID}); err != nil {
+		t.Errorf("Expected delete to succeed after termination, got %v", err)
+	}
+}
+
+func TestSessionManagerRoleAndSSM(t *testing.T) {
+	cloud := fakecloud.Install(t)
+	ctx := context.Background()
+
+	iamClient, err := aws.NewIAMClient(ctx, "default")
+	if err != nil {
+		t.Fatalf("NewIAMClient failed: %v", err)
+	}
+	profile, err := iamClient.GetOrCreateSessionManagerRole(ctx, "lens-test")
+	if err != nil {
+		t.Fatalf("GetOrCreateSessionManagerRole failed: %v", err)
+	}
+	if _, ok := cloud.Role("lens-test-session-manager-role"); !ok {
+		t.Error("Expected session manager role to be created")
+	}
+	if err := iamClient.ValidateSessionManagerRole(ctx, "lens-test-session-manager-role"); err != nil {
+		t.Errorf("ValidateSessionManagerRole failed: %v", err)
+	}
+
+	client, _ := aws.NewEC2Client(ctx, "default")
+	inst := launchTestInstance(t, ctx, client, profile.Name)
+	id := *inst.InstanceId
+	if err := client.WaitForInstanceRunning(ctx, id); err != nil {
+		t.Fatalf("WaitForInstanceRunning failed: %v", err)
+	}
+
+	ssmClient, err := aws.NewSSMClientForProfile(ctx, "default")
+	if err != nil {
+		t.Fatalf("NewSSMClientForProfile failed: %v", err)
+	}
+	if err := ssmClient.WaitForSSMAgent(ctx, id, time.Second); err != nil {
+		t.Fatalf("WaitForSSMAgent failed: %v", err)
+	}
+
+	ready, err := ssmClient.CheckServiceReadiness(ctx, id, 8888)
+	if err != nil || !ready {
+		t.Errorf("CheckServiceReadiness = %v, %v; want true, nil", ready, err)
+	}
+
+	cloud.HandleCommands(func(inv fakecloud.Invocation) fakecloud.CommandResult {
+		return fakecloud.CommandResult{Stdout: "000"}
+	})
+	ready, err = ssmClient.CheckServiceReadiness(ctx, id, 8888)
+	if err != nil || ready {
+		t.Errorf("CheckServiceReadiness = %v, %v; want false, nil", ready, err)
+	}
+
+	invocations := cloud.Invocations()
+	if len(invocations) != 2 || !strings.Contains(invocations[0].Script(), "localhost:8888") {
+		t.Errorf("Unexpected invocations recorded: %+v", invocations)
+	}
+}
+
+func TestFailNext(t *testing.T) {
+	cloud := fakecloud.Install(t)
+	ctx := context.Background()
+	client, _ := aws.NewEC2Client(ctx, "default")
+
+	injected := fakecloud.APIError("UnauthorizedOperation", "You are not authorized to perform this operation.")
+	cloud.FailNext("DescribeKeyPairs", injected)
+
+	if _, err := client.ListKeyPairs(ctx); !errors.Is(err, injected) {
+		t.Errorf("Expected injected error, got %v", err)
+	}
+	if _, err := client.ListKeyPairs(ctx); err != nil {
+		t.Errorf("Expected failure to apply only once, got %v", err)
+	}
+}
+
+func TestUnsupportedFilterIsRejected(t *testing.T) {
+	cloud := fakecloud.New()
+	ctx := context.Background()
+
+	name := "no-such-filter"
+	_, err := cloud.EC2("").DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
+		Filters: []types.Filter{{Name: &name, Values: []string{"x"}}},
+	})
+	assertErrorCode(t, err, "InvalidParameterValue")
+}
+
+func TestRestrictInstanceType(t *testing.T) {
+	cloud := fakecloud.Install(t)
+	ctx := context.Background()
+	client, _ := aws.NewEC2Client(ctx, "default")
+
+	cloud.RestrictInstanceType(fakecloud.DefaultRegion, "p5.48xlarge", "us-east-1b")
+
+	az, err := client.FindCompatibleAvailabilityZone(ctx, "p5.48xlarge", "public")
+	if err != nil {
+		t.Fatalf("FindCompatibleAvailabilityZone failed: %v", err)
+	}
+	if az != "us-east-1b" {
+		t.Errorf("Expected us-east-1b, got %s", az)
+	}
+
+	supported, err := client.IsInstanceTypeSupported(ctx, "p5.48xlarge", "us-east-1a")
+	if err != nil || supported {
+		t.Errorf("IsInstanceTypeSupported = %v, %v; want false, nil", supported, err)
+	}
+}
+
+func assertErrorCode(t *testing.T, err error, code string) {
+	t.Helper()
+
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected API error %s, got %v", code, err)
+	}
+	if apiErr.ErrorCode() != code {
+		t.Errorf("Expected error code %s, got %s", code, apiErr.ErrorCode())
+	}
+}
@@ -0,0 +1,429 @@
+package fakecloud
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// iamState holds the account's IAM roles and instance profiles
+type iamState struct {
+	roles          map[string]*types.Role
+	inlinePolicies map[string]map[string]string
+	attached       map[string][]string
+	profiles       map[string]*types.InstanceProfile
+}
+
+func newIAMState() *iamState {
+	return &iamState{
+		roles:          make(map[string]*types.Role),
+		inlinePolicies: make(map[string]map[string]string),
+		attached:       make(map[string][]string),
+		profiles:       make(map[string]*types.InstanceProfile),
+	}
+}
+
+// iamAPI implements aws.IAMAPI
+type iamAPI struct {
+	cloud *Cloud
+}
+
+// begin locks the cloud and applies injected failures.
+// On success the caller must call c.mu.Unlock when done.
+func (a *iamAPI) begin(operation string) (*iamState, error) {
+	a.cloud.mu.Lock()
+	if err := a.cloud.injected(operation); err != nil {
+		a.cloud.mu.Unlock()
+		return nil, err
+	}
+	return a.cloud.iam, nil
+}
+
+func noSuchRole(name string) error {
+	return APIError("NoSuchEntity", "The role with name %s cannot be found.", name)
+}
+
+// ListRoles lists roles whose path starts with the path prefix, by name
+func (a *iamAPI) ListRoles(ctx context.Context, params *iam.ListRolesInput, optFns ...func(*iam.Options)) (*iam.ListRolesOutput, error) {
+	s, err := a.begin("ListRoles")
+	if err != nil {
+		return nil, err
+	}
+	defer a.cloud.mu.Unlock()
+
+	out := &iam.ListRolesOutput{}
+	for _, name := range sortedKeys(s.roles) {
+		role := s.roles[name]
+		if prefix := ptrValue(params.PathPrefix); prefix != "" && !strings.HasPrefix(ptrValue(role.Path), prefix) {
+			continue
+		}
+		// Like IAM, ListRoles does not return tags
+		copied := *role
+		copied.Tags = nil
+		out.Roles = append(out.Roles, copied)
+	}
+	return out, nil
+}
+
+// GetRole returns a role by name
+func (a *iamAPI) GetRole(ctx context.Context, params *iam.GetRoleInput, optFns ...func(*iam.Options)) (*iam.GetRoleOutput, error) {
+	s, err := a.begin("GetRole")
+	if err != nil {
+		return nil, err
+	}
+	defer a.cloud.mu.Unlock()
+
+	role, ok := s.roles[ptrValue(params.RoleName)]
+	if !ok {
+		return nil, noSuchRole(ptrValue(params.RoleName))
+	}
+	copied := *role
+	return &iam.GetRoleOutput{Role: &copied}, nil
+}
+
+// CreateRole creates a role
+func (a *iamAPI) CreateRole(ctx context.Context, params *iam.CreateRoleInput, optFns ...func(*iam.Options)) (*iam.CreateRoleOutput, error) {
+	s, err := a.begin("CreateRole")
+	if err != nil {
+		return nil, err
+	}
+	defer a.cloud.mu.Unlock()
+
+	name := ptrValue(params.RoleName)
+	if _, ok := s.roles[name]; ok {
+		return nil, APIError("EntityAlreadyExists", "Role with name %s already exists.", name)
+	}
+
+	role := &types.Role{
+		RoleName:                 ptr(name),
+		RoleId:                   ptr(a.cloud.nextID("AROA")),
+		Arn:                      ptr("arn:aws:iam::" + AccountID + ":role/" + name),
+		Path:                     ptr("/"),
+		AssumeRolePolicyDocument: params.AssumeRolePolicyDocument,
+		Description:              params.Description,
+		CreateDate:               ptr(a.cloud.now().UTC()),
+		Tags:                     params.Tags,
+	}
+	s.roles[name] = role
+	copied := *role
+	return &iam.CreateRoleOutput{Role: &copied}, nil
+}
+
+// DeleteRole deletes a role with no attached policies or instance profiles
+func (a *iamAPI) DeleteRole(ctx context.Context, params *iam.DeleteRoleInput, optFns ...func(*iam.Options)) (*iam.DeleteRoleOutput, error) {
+	s, err := a.begin("DeleteRole")
+	if err != nil {
+		return nil, err
+	}
+	defer a.cloud.mu.Unlock()
+
+	name := ptrValue(params.RoleName)
+	if _, ok := s.roles[name]; !ok {
+		return nil, noSuchRole(name)
+	}
+	if len(s.attached[name]) > 0 || len(s.inlinePolicies[name]) > 0 {
+		return nil, APIError("DeleteConflict", "Cannot delete entity, must detach all policies first.")
+	}
+	for _, profile := range s.profiles {
+		for _, role := range profile.Roles {
+			if ptrValue(role.RoleName) == name {
+				return nil, APIError("DeleteConflict", "Cannot delete entity, must remove roles from instance profile first.")
+			}
+		}
+	}
+
+	delete(s.roles, name)
+	delete(s.attached, name)
+	delete(s.inlinePolicies, name)
+	return &iam.DeleteRoleOutput{}, nil
+}
+
+// AttachRolePolicy attaches a managed policy to a role
+func (a *iamAPI) AttachRolePolicy(ctx context.Context, params *iam.AttachRolePolicyInput, optFns ...func(*iam.Options)) (*iam.AttachRolePolicyOutput, error) {
+	s, err := a.begin("AttachRolePolicy")
+	if err != nil {
+		return nil, err
+	}
+	defer a.cloud.mu.Unlock()
+
+	name := ptrValue(params.RoleName)
+	if _, ok := s.roles[name]; !ok {
+		return nil, noSuchRole(name)
+	}
+	if !contains(s.attached[name], ptrValue(params.PolicyArn)) {
+		s.attached[name] = append(s.attached[name], ptrValue(params.PolicyArn))
+	}
+	return &iam.AttachRolePolicyOutput{}, nil
+}
+
+// DetachRolePolicy detaches a managed policy from a role
+func (a *iamAPI) DetachRolePolicy(ctx context.Context, params *iam.DetachRolePolicyInput, optFns ...func(*iam.Options)) (*iam.DetachRolePolicyOutput, error) {
+	s, err := a.begin("DetachRolePolicy")
+	if err != nil {
+		return nil, err
+	}
+	defer a.cloud.mu.Unlock()
+
+	name := ptrValue(params.RoleName)
+	if _, ok := s.roles[name]; !ok {
+		return nil, noSuchRole(name)
+	}
+	var remaining []string
+	found := false
+	for _, arn := range s.attached[name] {
+		if arn == ptrValue(params.PolicyArn) {
+			found = true
+			continue
+		}
+		remaining = append(remaining, arn)
+	}
+	if !found {
+		return nil, APIError("NoSuchEntity", "Policy %s was not found.", ptrValue(params.PolicyArn))
+	}
+	s.attached[name] = remaining
+	return &iam.DetachRolePolicyOutput{}, nil
+}
+
+// ListAttachedRolePolicies lists the managed policies attached to a role
+func (a *iamAPI) ListAttachedRolePolicies(ctx context.Context, params *iam.ListAttachedRolePoliciesInput, optFns ...func(*iam.Options)) (*iam.ListAttachedRolePoliciesOutput, error) {
+	s, err := a.begin("ListAttachedRolePolicies")
+	if err != nil {
+		return nil, err
+	}
+	defer a.cloud.mu.Unlock()
+
+	name := ptrValue(params.RoleName)
+	if _, ok := s.roles[name]; !ok {
+		return nil, noSuchRole(name)
+	}
+	out := &iam.ListAttachedRolePoliciesOutput{}
+	for _, arn := range s.attached[name] {
+		out.AttachedPolicies = append(out.AttachedPolicies, types.AttachedPolicy{
+			PolicyArn:  ptr(arn),
+			PolicyName: ptr(arn[strings.LastIndex(arn, "/")+1:]),
+		})
+	}
+	return out, nil
+}
+
+// PutRolePolicy adds or replaces an inline policy on a role
+func (a *iamAPI) PutRolePolicy(ctx context.Context, params *iam.PutRolePolicyInput, optFns ...func(*iam.Options)) (*iam.PutRolePolicyOutput, error) {
+	s, err := a.begin("PutRolePolicy")
+	if err != nil {
+		return nil, err
+	}
+	defer a.cloud.mu.Unlock()
+
+	name := ptrValue(params.RoleName)
+	if _, ok := s.roles[name]; !ok {
+		return nil, noSuchRole(name)
+	}
+	if s.inlinePolicies[name] == nil {
+		s.inlinePolicies[name] = make(map[string]string)
+	}
+	s.inlinePolicies[name][ptrValue(params.PolicyName)] = ptrValue(params.PolicyDocument)
+	return &iam.PutRolePolicyOutput{}, nil
+}
+
+// ListRolePolicies lists the names of a role's inline policies
+func (a *iamAPI) ListRolePolicies(ctx context.Context, params *iam.ListRolePoliciesInput, optFns ...func(*iam.Options)) (*iam.ListRolePoliciesOutput, error) {
+	s, err := a.begin("ListRolePolicies")
+	if err != nil {
+		return nil, err
+	}
+	defer a.cloud.mu.Unlock()
+
+	name := ptrValue(params.RoleName)
+	if _, ok := s.roles[name]; !ok {
+		return nil, noSuchRole(name)
+	}
+	return &iam.ListRolePoliciesOutput{PolicyNames: sortedKeys(s.inlinePolicies[name])}, nil
+}
+
+// DeleteRolePolicy removes an inline policy from a role
+func (a *iamAPI) DeleteRolePolicy(ctx context.Context, params *iam.DeleteRolePolicyInput, optFns ...func(*iam.Options)) (*iam.DeleteRolePolicyOutput, error) {
+	s, err := a.begin("DeleteRolePolicy")
+	if err != nil {
+		return nil, err
+	}
+	defer a.cloud.mu.Unlock()
+
+	name := ptrValue(params.RoleName)
+	if _, ok := s.roles[name]; !ok {
+		return nil, noSuchRole(name)
+	}
+	if _, ok := s.inlinePolicies[name][ptrValue(params.PolicyName)]; !ok {
+		return nil, APIError("NoSuchEntity", "The role policy with name %s cannot be found.", ptrValue(params.PolicyName))
+	}
+	delete(s.inlinePolicies[name], ptrValue(params.PolicyName))
+	return &iam.DeleteRolePolicyOutput{}, nil
+}
+
+// GetRolePolicy returns an inline policy of a role
+func (a *iamAPI) GetRolePolicy(ctx context.Context, params *iam.GetRolePolicyInput, optFns ...func(*iam.Options)) (*iam.GetRolePolicyOutput, error) {
+	s, err := a.begin("GetRolePolicy")
+	if err != nil {
+		return nil, err
+	}
+	defer a.cloud.mu.Unlock()
+
+	name := ptrValue(params.RoleName)
+	if _, ok := s.roles[name]; !ok {
+		return nil, noSuchRole(name)
+	}
+	document, ok := s.inlinePolicies[name][ptrValue(params.PolicyName)]
+	if !ok {
+		return nil, APIError("NoSuchEntity", "The role policy with name %s cannot be found.", ptrValue(params.PolicyName))
+	}
+	return &iam.GetRolePolicyOutput{
+		RoleName:       params.RoleName,
+		PolicyName:     params.PolicyName,
+		PolicyDocument: ptr(document),
+	}, nil
+}
+
+// GetInstanceProfile returns an instance profile by name
+func (a *iamAPI) GetInstanceProfile(ctx context.Context, params *iam.GetInstanceProfileInput, optFns ...func(*iam.Options)) (*iam.GetInstanceProfileOutput, error) {
+	s, err := a.begin("GetInstanceProfile")
+	if err != nil {
+		return nil, err
+	}
+	defer a.cloud.mu.Unlock()
+
+	profile, ok := s.profiles[ptrValue(params.InstanceProfileName)]
+	if !ok {
+		return nil, APIError("NoSuchEntity", "Instance Profile %s cannot be found.", ptrValue(params.InstanceProfileName))
+	}
+	copied := *profile
+	return &iam.GetInstanceProfileOutput{InstanceProfile: &copied}, nil
+}
+
+// CreateInstanceProfile creates an empty instance profile
+func (a *iamAPI) CreateInstanceProfile(ctx context.Context, params *iam.CreateInstanceProfileInput, optFns ...func(*iam.Options)) (*iam.CreateInstanceProfileOutput, error) {
+	s, err := a.begin("CreateInstanceProfile")
+	if err != nil {
+		return nil, err
+	}
+	defer a.cloud.mu.Unlock()
+
+	name := ptrValue(params.InstanceProfileName)
+	if _, ok := s.profiles[name]; ok {
+		return nil, APIError("EntityAlreadyExists", "Instance Profile %s already exists.", name)
+	}
+	profile := &types.InstanceProfile{
+		InstanceProfileName: ptr(name),
+		InstanceProfileId:   ptr(a.cloud.nextID("AIPA")),
+		Arn:                 ptr("arn:aws:iam::" + AccountID + ":instance-profile/" + name),
+		Path:                ptr("/"),
+		CreateDate:          ptr(a.cloud.now().UTC()),
+		Tags:                params.Tags,
+	}
+	s.profiles[name] = profile
+	copied := *profile
+	return &iam.CreateInstanceProfileOutput{InstanceProfile: &copied}, nil
+}
+
+// DeleteInstanceProfile deletes an instance profile with no roles
+func (a *iamAPI) DeleteInstanceProfile(ctx context.Context, params *iam.DeleteInstanceProfileInput, optFns ...func(*iam.Options)) (*iam.DeleteInstanceProfileOutput, error) {
+	s, err := a.begin("DeleteInstanceProfile")
+	if err != nil {
+		return nil, err
+	}
+	defer a.cloud.mu.Unlock()
+
+	name := ptrValue(params.InstanceProfileName)
+	profile, ok := s.profiles[name]
+	if !ok {
+		return nil, APIError("NoSuchEntity", "Instance Profile %s cannot be found.", name)
+	}
+	if len(profile.Roles) > 0 {
+		return nil, APIError("DeleteConflict", "Cannot delete entity, must remove roles from instance profile first.")
+	}
+	delete(s.profiles, name)
+	return &iam.DeleteInstanceProfileOutput{}, nil
+}
+
+// AddRoleToInstanceProfile adds a role to an instance profile
+func (a *iamAPI) AddRoleToInstanceProfile(ctx context.Context, params *iam.AddRoleToInstanceProfileInput, optFns ...func(*iam.Options)) (*iam.AddRoleToInstanceProfileOutput, error) {
+	s, err := a.begin("AddRoleToInstanceProfile")
+	if err != nil {
+		return nil, err
+	}
+	defer a.cloud.mu.Unlock()
+
+	profile, ok := s.profiles[ptrValue(params.InstanceProfileName)]
+	if !ok {
+		return nil, APIError("NoSuchEntity", "Instance Profile %s cannot be found.", ptrValue(params.InstanceProfileName))
+	}
+	role, ok := s.roles[ptrValue(params.RoleName)]
+	if !ok {
+		return nil, noSuchRole(ptrValue(params.RoleName))
+	}
+	if len(profile.Roles) > 0 {
+		return nil, APIError("LimitExceeded", "Cannot exceed quota for InstanceSessionsPerInstanceProfile: 1")
+	}
+	profile.Roles = []types.Role{*role}
+	return &iam.AddRoleToInstanceProfileOutput{}, nil
+}
+
+// RemoveRoleFromInstanceProfile removes a role from an instance profile
+func (a *iamAPI) RemoveRoleFromInstanceProfile(ctx context.Context, params *iam.RemoveRoleFromInstanceProfileInput, optFns ...func(*iam.Options)) (*iam.RemoveRoleFromInstanceProfileOutput, error) {
+	s, err := a.begin("RemoveRoleFromInstanceProfile")
+	if err != nil {
+		return nil, err
+	}
+	defer a.cloud.mu.Unlock()
+
+	profile, ok := s.profiles[ptrValue(params.InstanceProfileName)]
+	if !ok {
+		return nil, APIError("NoSuchEntity", "Instance Profile %s cannot be found.", ptrValue(params.InstanceProfileName))
+	}
+	var remaining []types.Role
+	found := false
+	for _, role := range profile.Roles {
+		if ptrValue(role.RoleName) == ptrValue(params.RoleName) {
+			found = true
+			continue
+		}
+		remaining = append(remaining, role)
+	}
+	if !found {
+		return nil, noSuchRole(ptrValue(params.RoleName))
+	}
+	profile.Roles = remaining
+	return &iam.RemoveRoleFromInstanceProfileOutput{}, nil
+}
+
+// Role returns a copy of an IAM role
+func (c *Cloud) Role(name string) (types.Role, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	role, ok := c.iam.roles[name]
+	if !ok {
+		return types.Role{}, false
+	}
+	return *role, true
+}
+
+// AttachedPolicies returns the managed policy ARNs attached to a role
+func (c *Cloud) AttachedPolicies(roleName string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string(nil), c.iam.attached[roleName]...)
+}
+
+// InstanceProfile returns a copy of an IAM instance profile
+func (c *Cloud) InstanceProfile(name string) (types.InstanceProfile, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	profile, ok := c.iam.profiles[name]
+	if !ok {
+		return types.InstanceProfile{}, false
+	}
+	return *profile, true
+}
@@ -0,0 +1,184 @@
+package fakecloud
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// Instance returns a copy of an instance from any region
+func (c *Cloud) Instance(instanceID string) (types.Instance, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, r := range c.regions {
+		if inst, ok := r.instances[instanceID]; ok {
+			return *inst, true
+		}
+	}
+	return types.Instance{}, false
+}
+
+// Instances returns copies of all instances in a region, including terminated ones
+func (c *Cloud) Instances(region string) []types.Instance {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := c.region(region)
+	instances := make([]types.Instance, 0, len(r.instances))
+	for _, id := range sortedKeys(r.instances) {
+		instances = append(instances, *r.instances[id])
+	}
+	return instances
+}
+
+// UserData returns the user data an instance was launched with
+func (c *Cloud) UserData(instanceID string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, r := range c.regions {
+		if data, ok := r.userData[instanceID]; ok {
+			return data
+		}
+	}
+	return ""
+}
+
+// SetInstanceState forces an instance into a state, e.g. to simulate an
+// instance stopped by the idle monitor
+func (c *Cloud) SetInstanceState(instanceID string, state types.InstanceStateName) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, r := range c.regions {
+		if inst, ok := r.instances[instanceID]; ok {
+			inst.State = instanceState(state)
+			if state == types.InstanceStateNameTerminated {
+				releaseVolumes(r, inst)
+			}
+			if state != types.InstanceStateNameRunning && state != types.InstanceStateNamePending {
+				inst.PublicIpAddress = nil
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("instance %s not found", instanceID)
+}
+
+// RestrictInstanceType limits the availability zones of a region in which an
+// instance type is offered. Passing no zones makes the type unavailable.
+func (c *Cloud) RestrictInstanceType(region, instanceType string, zones ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.restrictedTypes == nil {
+		c.restrictedTypes = make(map[string][]string)
+	}
+	c.restrictedTypes[c.region(region).name+"/"+instanceType] = zones
+}
+
+// SecurityGroups returns copies of the security groups in a region
+func (c *Cloud) SecurityGroups(region string) []types.SecurityGroup {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := c.region(region)
+	groups := make([]types.SecurityGroup, 0, len(r.securityGroups))
+	for _, id := range sortedKeys(r.securityGroups) {
+		groups = append(groups, *r.securityGroups[id])
+	}
+	return groups
+}
+
+// KeyPairs returns copies of the key pairs in a region
+func (c *Cloud) KeyPairs(region string) []types.KeyPairInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := c.region(region)
+	keys := make([]types.KeyPairInfo, 0, len(r.keyPairs))
+	for _, name := range sortedKeys(r.keyPairs) {
+		keys = append(keys, *r.keyPairs[name])
+	}
+	return keys
+}
+
+// Images returns copies of the images owned by the account in a region
+func (c *Cloud) Images(region string) []types.Image {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := c.region(region)
+	var images []types.Image
+	for _, id := range sortedKeys(r.images) {
+		if ptrValue(r.images[id].OwnerId) == AccountID {
+			images = append(images, *r.images[id])
+		}
+	}
+	return images
+}
+
+// Snapshots returns copies of the snapshots owned by the account in a region
+func (c *Cloud) Snapshots(region string) []types.Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := c.region(region)
+	var snapshots []types.Snapshot
+	for _, id := range sortedKeys(r.snapshots) {
+		if ptrValue(r.snapshots[id].OwnerId) == AccountID {
+			snapshots = append(snapshots, *r.snapshots[id])
+		}
+	}
+	return snapshots
+}
+
+// Volumes returns copies of the EBS volumes in a region
+func (c *Cloud) Volumes(region string) []types.Volume {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := c.region(region)
+	var volumes []types.Volume
+	for _, id := range sortedKeys(r.volumes) {
+		volumes = append(volumes, *r.volumes[id])
+	}
+	return volumes
+}
+
+// AddImage registers an available image in a region and returns its ID
+func (c *Cloud) AddImage(region, name string, arch types.ArchitectureValues, owner string, created time.Time) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	image := c.addImage(c.region(region), name, arch, owner, owner != AccountID, created)
+	return ptrValue(image.ImageId)
+}
+
+// Addresses returns copies of the Elastic IPs allocated in a region
+func (c *Cloud) Addresses(region string) []types.Address {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := c.region(region)
+	addresses := make([]types.Address, 0, len(r.addresses))
+	for _, id := range sortedKeys(r.addresses) {
+		addresses = append(addresses, *r.addresses[id])
+	}
+	return addresses
+}
+
+// NatGateways returns copies of the NAT gateways in a region
+func (c *Cloud) NatGateways(region string) []types.NatGateway {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := c.region(region)
+	gateways := make([]types.NatGateway, 0, len(r.natGateways))
+	for _, id := range sortedKeys(r.natGateways) {
+		gateways = append(gateways, *r.natGateways[id])
+	}
+	return gateways
+}
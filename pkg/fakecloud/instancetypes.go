@@ -0,0 +1,154 @@
+package fakecloud
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// instanceFamily describes the sizes of an instance family in the catalog
+type instanceFamily struct {
+	name         string
+	architecture types.ArchitectureType
+	memoryPerCPU float64  // GiB per vCPU for sizes from large
+	sizes        []string // Offered sizes, smallest first
+	gpus         int      // GPUs per instance
+}
+
+// Sizes of the families in the catalog
+var (
+	burstableSizes = []string{"nano", "micro", "small", "medium", "large", "xlarge", "2xlarge"}
+	gravitonSizes  = []string{"medium", "large", "xlarge", "2xlarge", "4xlarge", "8xlarge", "12xlarge", "16xlarge"}
+	x86Sizes       = []string{"large", "xlarge", "2xlarge", "4xlarge", "8xlarge", "12xlarge", "16xlarge"}
+	gpuSizes       = []string{"xlarge", "2xlarge", "4xlarge", "8xlarge"}
+)
+
+// instanceFamilies is the catalog of instance types DescribeInstanceTypes reports
+var instanceFamilies = []instanceFamily{
+	{"t4g", types.ArchitectureTypeArm64, 4, burstableSizes, 0},
+	{"c7g", types.ArchitectureTypeArm64, 2, gravitonSizes, 0},
+	{"m7g", types.ArchitectureTypeArm64, 4, gravitonSizes, 0},
+	{"r7g", types.ArchitectureTypeArm64, 8, gravitonSizes, 0},
+	{"g5g", types.ArchitectureTypeArm64, 2, gpuSizes, 1},
+	{"t3", types.ArchitectureTypeX8664, 4, burstableSizes, 0},
+	{"t3a", types.ArchitectureTypeX8664, 4, burstableSizes, 0},
+	{"c6i", types.ArchitectureTypeX8664, 2, x86Sizes, 0},
+	{"m6i", types.ArchitectureTypeX8664, 4, x86Sizes, 0},
+	{"r6i", types.ArchitectureTypeX8664, 8, x86Sizes, 0},
+	{"g5", types.ArchitectureTypeX8664, 4, gpuSizes, 1},
+}
+
+// burstableSpecs are the vCPUs and GiB of memory of burstable sizes
+var burstableSpecs = map[string][2]float64{
+	"nano": {2, 0.5}, "micro": {2, 1}, "small": {2, 2}, "medium": {2, 4},
+	"large": {2, 8}, "xlarge": {4, 16}, "2xlarge": {8, 32},
+}
+
+// sizeCPUs are the vCPUs of non-burstable sizes
+var sizeCPUs = map[string]int32{
+	"medium": 1, "large": 2, "xlarge": 4, "2xlarge": 8, "4xlarge": 16,
+	"8xlarge": 32, "12xlarge": 48, "16xlarge": 64,
+}
+
+// instanceTypeCatalog returns the description of every instance type in the catalog
+func instanceTypeCatalog() map[string]types.InstanceTypeInfo {
+	catalog := make(map[string]types.InstanceTypeInfo)
+	for _, family := range instanceFamilies {
+		burstable := strings.HasPrefix(family.name, "t")
+		for _, size := range family.sizes {
+			cpus := sizeCPUs[size]
+			memory := float64(cpus) * family.memoryPerCPU
+			if burstable {
+				cpus, memory = int32(burstableSpecs[size][0]), burstableSpecs[size][1]
+			}
+			name := family.name + "." + size
+			info := types.InstanceTypeInfo{
+				InstanceType:                  types.InstanceType(name),
+				CurrentGeneration:             ptr(true),
+				BurstablePerformanceSupported: ptr(burstable),
+				VCpuInfo:                      &types.VCpuInfo{DefaultVCpus: ptr(cpus)},
+				MemoryInfo:                    &types.MemoryInfo{SizeInMiB: ptr(int64(memory * 1024))},
+				ProcessorInfo:                 &types.ProcessorInfo{SupportedArchitectures: []types.ArchitectureType{family.architecture}},
+			}
+			if family.gpus > 0 {
+				info.GpuInfo = &types.GpuInfo{Gpus: []types.GpuDeviceInfo{{Count: ptr(int32(family.gpus)), Manufacturer: ptr("NVIDIA")}}}
+			}
+			catalog[name] = info
+		}
+	}
+	return catalog
+}
+
+// DescribeInstanceTypes describes instance types from a fixed catalog of
+// common families, all in one page. The instance-type,
+// processor-info.supported-architecture and current-generation filters are
+// supported.
+func (e *ec2API) DescribeInstanceTypes(ctx context.Context, params *ec2.DescribeInstanceTypesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceTypesOutput, error) {
+	_, err := e.begin("DescribeInstanceTypes")
+	if err != nil {
+		return nil, err
+	}
+	defer e.cloud.mu.Unlock()
+
+	catalog := instanceTypeCatalog()
+	for _, name := range params.InstanceTypes {
+		if _, ok := catalog[string(name)]; !ok {
+			return nil, APIError("InvalidInstanceType", "The following supplied instance types do not exist: [%s]", name)
+		}
+	}
+
+	out := &ec2.DescribeInstanceTypesOutput{}
+	for _, name := range sortedKeys(catalog) {
+		info := catalog[name]
+		if len(params.InstanceTypes) > 0 && !contains(instanceTypeNames(params.InstanceTypes), name) {
+			continue
+		}
+		if !matchesTypeFilters(info, params.Filters) {
+			continue
+		}
+		out.InstanceTypes = append(out.InstanceTypes, info)
+	}
+	return out, nil
+}
+
+// instanceTypeNames converts instance types to strings
+func instanceTypeNames(instanceTypes []types.InstanceType) []string {
+	names := make([]string, len(instanceTypes))
+	for i, name := range instanceTypes {
+		names[i] = string(name)
+	}
+	return names
+}
+
+// matchesTypeFilters reports whether an instance type matches every filter
+func matchesTypeFilters(info types.InstanceTypeInfo, filters []types.Filter) bool {
+	for _, filter := range filters {
+		var value string
+		switch ptrValue(filter.Name) {
+		case "processor-info.supported-architecture":
+			value = string(info.ProcessorInfo.SupportedArchitectures[0])
+		case "current-generation":
+			value = "true"
+		case "instance-type":
+			value = string(info.InstanceType)
+		default:
+			continue
+		}
+		if !contains(filter.Values, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsArchitecture reports whether an architecture is in the list
+func containsArchitecture(architectures []types.ArchitectureType, architecture types.ArchitectureValues) bool {
+	for _, supported := range architectures {
+		if string(supported) == string(architecture) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,217 @@
+package fakecloud
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// metricExpression evaluates a metric math expression for one period, given
+// the value of each metric query by Id. Missing values are NaN.
+type metricExpression func(values map[string]float64) float64
+
+// parseExpression parses the subset of CloudWatch metric math that alarms use
+// here: numbers, query Ids, + - * /, parentheses, MAX and MIN of a list and
+// FILL of missing values
+func parseExpression(text string) (metricExpression, error) {
+	p := &expressionParser{text: text}
+	expression, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.text) {
+		return nil, fmt.Errorf("unexpected %q at %d", p.text[p.pos:], p.pos)
+	}
+	return expression, nil
+}
+
+// expressionParser is a recursive descent parser over an expression
+type expressionParser struct {
+	text string
+	pos  int
+}
+
+// skipSpace advances past whitespace
+func (p *expressionParser) skipSpace() {
+	for p.pos < len(p.text) && unicode.IsSpace(rune(p.text[p.pos])) {
+		p.pos++
+	}
+}
+
+// accept consumes the next character if it is c
+func (p *expressionParser) accept(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.text) && p.text[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// sum parses terms joined by + and -
+func (p *expressionParser) sum() (metricExpression, error) {
+	left, err := p.product()
+	for err == nil {
+		var op byte
+		switch {
+		case p.accept('+'):
+			op = '+'
+		case p.accept('-'):
+			op = '-'
+		default:
+			return left, nil
+		}
+		var right metricExpression
+		if right, err = p.product(); err == nil {
+			left = binary(op, left, right)
+		}
+	}
+	return nil, err
+}
+
+// product parses factors joined by * and /
+func (p *expressionParser) product() (metricExpression, error) {
+	left, err := p.factor()
+	for err == nil {
+		var op byte
+		switch {
+		case p.accept('*'):
+			op = '*'
+		case p.accept('/'):
+			op = '/'
+		default:
+			return left, nil
+		}
+		var right metricExpression
+		if right, err = p.factor(); err == nil {
+			left = binary(op, left, right)
+		}
+	}
+	return nil, err
+}
+
+// factor parses a number, an Id, a negation, a parenthesized expression, a
+// MAX or MIN of a list or a FILL
+func (p *expressionParser) factor() (metricExpression, error) {
+	if p.accept('-') {
+		operand, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return func(values map[string]float64) float64 { return -operand(values) }, nil
+	}
+	if p.accept('(') {
+		inner, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(')') {
+			return nil, fmt.Errorf("missing ) at %d", p.pos)
+		}
+		return inner, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.text) && (p.text[p.pos] == '_' || p.text[p.pos] == '.' || unicode.IsLetter(rune(p.text[p.pos])) || unicode.IsDigit(rune(p.text[p.pos]))) {
+		p.pos++
+	}
+	word := p.text[start:p.pos]
+	if word == "" {
+		return nil, fmt.Errorf("expected a value at %d", p.pos)
+	}
+	if number, err := strconv.ParseFloat(word, 64); err == nil {
+		return func(map[string]float64) float64 { return number }, nil
+	}
+	switch strings.ToUpper(word) {
+	case "MAX":
+		return p.list(math.Max)
+	case "MIN":
+		return p.list(math.Min)
+	case "FILL":
+		return p.fill()
+	}
+	return func(values map[string]float64) float64 {
+		if value, ok := values[word]; ok {
+			return value
+		}
+		return math.NaN()
+	}, nil
+}
+
+// list parses the ([a, b, ...]) argument of MAX or MIN and folds it with
+// combine
+func (p *expressionParser) list(combine func(a, b float64) float64) (metricExpression, error) {
+	if !p.accept('(') || !p.accept('[') {
+		return nil, fmt.Errorf("expected ([ at %d", p.pos)
+	}
+	var items []metricExpression
+	for {
+		item, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if p.accept(']') {
+			break
+		}
+		if !p.accept(',') {
+			return nil, fmt.Errorf("expected , or ] at %d", p.pos)
+		}
+	}
+	if !p.accept(')') {
+		return nil, fmt.Errorf("missing ) at %d", p.pos)
+	}
+	return func(values map[string]float64) float64 {
+		result := items[0](values)
+		for _, item := range items[1:] {
+			result = combine(result, item(values))
+		}
+		return result
+	}, nil
+}
+
+// fill parses the (expression, number) arguments of FILL, which stands in the
+// number where the expression has no data
+func (p *expressionParser) fill() (metricExpression, error) {
+	if !p.accept('(') {
+		return nil, fmt.Errorf("expected ( at %d", p.pos)
+	}
+	series, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept(',') {
+		return nil, fmt.Errorf("expected , at %d", p.pos)
+	}
+	value, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept(')') {
+		return nil, fmt.Errorf("missing ) at %d", p.pos)
+	}
+	return func(values map[string]float64) float64 {
+		if result := series(values); !math.IsNaN(result) {
+			return result
+		}
+		return value(values)
+	}, nil
+}
+
+// binary combines two expressions with an arithmetic operator
+func binary(op byte, left, right metricExpression) metricExpression {
+	return func(values map[string]float64) float64 {
+		a, b := left(values), right(values)
+		switch op {
+		case '+':
+			return a + b
+		case '-':
+			return a - b
+		case '*':
+			return a * b
+		}
+		return a / b
+	}
+}
@@ -0,0 +1,99 @@
+package fakecloud
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/pricing"
+	"github.com/scttfrdmn/lens/pkg/aws"
+)
+
+// priceKey identifies an on-demand price in the Price List
+type priceKey struct {
+	region, operatingSystem, instanceType string
+}
+
+// SetPrice sets the on-demand hourly USD price returned by GetProducts for an
+// instance type. operatingSystem is a Price List value such as "Linux".
+// The Price List is empty until prices are set.
+func (c *Cloud) SetPrice(region, operatingSystem, instanceType string, hourly float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prices[priceKey{region, operatingSystem, instanceType}] = hourly
+}
+
+// pricingAPI implements aws.PricingAPI
+type pricingAPI struct {
+	cloud *Cloud
+}
+
+// GetProducts returns the product matching the instanceType, regionCode and
+// operatingSystem filters as a Price List document. Other filters are ignored.
+func (p *pricingAPI) GetProducts(ctx context.Context, params *pricing.GetProductsInput, optFns ...func(*pricing.Options)) (*pricing.GetProductsOutput, error) {
+	p.cloud.mu.Lock()
+	defer p.cloud.mu.Unlock()
+	if err := p.cloud.injected("GetProducts"); err != nil {
+		return nil, err
+	}
+
+	if ptrValue(params.ServiceCode) != "AmazonEC2" {
+		return nil, APIError("InvalidParameterException", "Unknown service code %s", ptrValue(params.ServiceCode))
+	}
+
+	var key priceKey
+	for _, filter := range params.Filters {
+		switch ptrValue(filter.Field) {
+		case "instanceType":
+			key.instanceType = ptrValue(filter.Value)
+		case "regionCode":
+			key.region = ptrValue(filter.Value)
+		case "operatingSystem":
+			key.operatingSystem = ptrValue(filter.Value)
+		}
+	}
+
+	hourly, ok := p.cloud.prices[key]
+	if !ok {
+		return &pricing.GetProductsOutput{}, nil
+	}
+	document, err := priceListDocument(key, hourly)
+	if err != nil {
+		return nil, err
+	}
+	return &pricing.GetProductsOutput{PriceList: []string{document}}, nil
+}
+
+// priceListDocument renders a product in the Price List JSON format
+func priceListDocument(key priceKey, hourly float64) (string, error) {
+	document := map[string]interface{}{
+		"product": map[string]interface{}{
+			"productFamily": "Compute Instance",
+			"attributes": map[string]string{
+				"instanceType":    key.instanceType,
+				"regionCode":      key.region,
+				"operatingSystem": key.operatingSystem,
+				"licenseModel":    aws.LicenseModel(key.operatingSystem),
+				"tenancy":         "Shared",
+			},
+		},
+		"terms": map[string]interface{}{
+			"OnDemand": map[string]interface{}{
+				"FAKE.JRTCKXETXF": map[string]interface{}{
+					"priceDimensions": map[string]interface{}{
+						"FAKE.JRTCKXETXF.6YS6EN2CT7": map[string]interface{}{
+							"unit":         "Hrs",
+							"pricePerUnit": map[string]string{"USD": strconv.FormatFloat(hourly, 'f', -1, 64)},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,190 @@
+package fakecloud
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// regionState holds the EC2 resources of a single region
+type regionState struct {
+	name  string
+	zones []string
+
+	vpcs           map[string]*types.Vpc
+	subnets        map[string]*types.Subnet
+	routeTables    map[string]*types.RouteTable
+	instances      map[string]*types.Instance
+	userData       map[string]string
+	images         map[string]*types.Image
+	snapshots      map[string]*types.Snapshot
+	keyPairs       map[string]*types.KeyPairInfo
+	securityGroups map[string]*types.SecurityGroup
+	addresses      map[string]*types.Address
+	natGateways    map[string]*types.NatGateway
+	volumes        map[string]*types.Volume
+	modifications  map[string]*types.VolumeModification // Latest modification per volume
+}
+
+// region returns the state for a region, creating and seeding it on first use.
+// An empty name selects the default region. Callers must hold c.mu.
+func (c *Cloud) region(name string) *regionState {
+	if name == "" {
+		name = c.defaultRegion
+	}
+	if r, ok := c.regions[name]; ok {
+		return r
+	}
+
+	r := &regionState{
+		name:           name,
+		zones:          []string{name + "a", name + "b"},
+		vpcs:           make(map[string]*types.Vpc),
+		subnets:        make(map[string]*types.Subnet),
+		routeTables:    make(map[string]*types.RouteTable),
+		instances:      make(map[string]*types.Instance),
+		userData:       make(map[string]string),
+		images:         make(map[string]*types.Image),
+		snapshots:      make(map[string]*types.Snapshot),
+		keyPairs:       make(map[string]*types.KeyPairInfo),
+		securityGroups: make(map[string]*types.SecurityGroup),
+		addresses:      make(map[string]*types.Address),
+		natGateways:    make(map[string]*types.NatGateway),
+		volumes:        make(map[string]*types.Volume),
+		modifications:  make(map[string]*types.VolumeModification),
+	}
+	c.regions[name] = r
+	c.seedNetwork(r)
+	c.seedImages(r)
+	return r
+}
+
+// seedNetwork creates the default VPC with a public and a private subnet in
+// each availability zone, the main route table and the default security group
+func (c *Cloud) seedNetwork(r *regionState) {
+	vpcID := c.nextID("vpc")
+	r.vpcs[vpcID] = &types.Vpc{
+		VpcId:     ptr(vpcID),
+		CidrBlock: ptr("172.31.0.0/16"),
+		IsDefault: ptr(true),
+		State:     types.VpcStateAvailable,
+		OwnerId:   ptr(AccountID),
+	}
+
+	for i, zone := range r.zones {
+		for _, public := range []bool{true, false} {
+			block := i * 16
+			if !public {
+				block += 128
+			}
+			subnetID := c.nextID("subnet")
+			r.subnets[subnetID] = &types.Subnet{
+				SubnetId:                ptr(subnetID),
+				VpcId:                   ptr(vpcID),
+				AvailabilityZone:        ptr(zone),
+				CidrBlock:               ptr(fmt.Sprintf("172.31.%d.0/20", block)),
+				MapPublicIpOnLaunch:     ptr(public),
+				DefaultForAz:            ptr(public),
+				AvailableIpAddressCount: ptr(int32(4091)),
+				State:                   types.SubnetStateAvailable,
+				OwnerId:                 ptr(AccountID),
+			}
+		}
+	}
+
+	routeTableID := c.nextID("rtb")
+	r.routeTables[routeTableID] = &types.RouteTable{
+		RouteTableId: ptr(routeTableID),
+		VpcId:        ptr(vpcID),
+		Associations: []types.RouteTableAssociation{{
+			RouteTableAssociationId: ptr(c.nextID("rtbassoc")),
+			RouteTableId:            ptr(routeTableID),
+			Main:                    ptr(true),
+		}},
+		Routes: []types.Route{{
+			DestinationCidrBlock: ptr("172.31.0.0/16"),
+			GatewayId:            ptr("local"),
+			State:                types.RouteStateActive,
+		}},
+		OwnerId: ptr(AccountID),
+	}
+
+	groupID := c.nextID("sg")
+	r.securityGroups[groupID] = &types.SecurityGroup{
+		GroupId:     ptr(groupID),
+		GroupName:   ptr("default"),
+		Description: ptr("default VPC security group"),
+		VpcId:       ptr(vpcID),
+		OwnerId:     ptr(AccountID),
+	}
+}
+
+// seedImages registers public Ubuntu and Amazon Linux images for both
+// architectures, named the way pkg/aws searches for them
+func (c *Cloud) seedImages(r *regionState) {
+	created := time.Date(2025, time.January, 15, 0, 0, 0, 0, time.UTC)
+
+	ubuntu := []struct{ codename, version string }{
+		{"noble", "24.04"},
+		{"jammy", "22.04"},
+		{"focal", "20.04"},
+	}
+	for _, release := range ubuntu {
+		for _, arch := range []types.ArchitectureValues{types.ArchitectureValuesArm64, types.ArchitectureValuesX8664} {
+			name := fmt.Sprintf("ubuntu/images/hvm-ssd/ubuntu-%s-%s-%s-server-%s", release.codename, release.version, arch, created.Format("20060102"))
+			c.addImage(r, name, arch, CanonicalOwnerID, true, created)
+		}
+	}
+
+	for _, arch := range []types.ArchitectureValues{types.ArchitectureValuesArm64, types.ArchitectureValuesX8664} {
+		name := fmt.Sprintf("amzn2-ami-hvm-2.0.%s.0-%s-gp2", created.Format("20060102"), arch)
+		c.addImage(r, name, arch, AmazonOwnerID, true, created)
+	}
+}
+
+// addImage registers an available image backed by a new snapshot. Callers must hold c.mu.
+func (c *Cloud) addImage(r *regionState, name string, arch types.ArchitectureValues, owner string, public bool, created time.Time) *types.Image {
+	snapshotID := c.nextID("snap")
+	r.snapshots[snapshotID] = &types.Snapshot{
+		SnapshotId: ptr(snapshotID),
+		OwnerId:    ptr(owner),
+		VolumeSize: ptr(int32(8)),
+		State:      types.SnapshotStateCompleted,
+		StartTime:  ptr(created),
+	}
+
+	imageID := c.nextID("ami")
+	image := &types.Image{
+		ImageId:            ptr(imageID),
+		Name:               ptr(name),
+		Architecture:       arch,
+		OwnerId:            ptr(owner),
+		Public:             ptr(public),
+		State:              types.ImageStateAvailable,
+		CreationDate:       ptr(created.UTC().Format(time.RFC3339)),
+		RootDeviceName:     ptr("/dev/sda1"),
+		RootDeviceType:     types.DeviceTypeEbs,
+		VirtualizationType: types.VirtualizationTypeHvm,
+		BlockDeviceMappings: []types.BlockDeviceMapping{{
+			DeviceName: ptr("/dev/sda1"),
+			Ebs: &types.EbsBlockDevice{
+				SnapshotId: ptr(snapshotID),
+				VolumeSize: ptr(int32(8)),
+				VolumeType: types.VolumeTypeGp3,
+			},
+		}},
+	}
+	r.images[imageID] = image
+	return image
+}
+
+// defaultVPC returns the default VPC of the region. Callers must hold c.mu.
+func (r *regionState) defaultVPC() *types.Vpc {
+	for _, id := range sortedKeys(r.vpcs) {
+		if ptrValue(r.vpcs[id].IsDefault) {
+			return r.vpcs[id]
+		}
+	}
+	return nil
+}
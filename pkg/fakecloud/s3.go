@@ -0,0 +1,153 @@
+package fakecloud
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// s3Object is an object stored in a fake bucket
+type s3Object struct {
+	data []byte
+	etag string
+}
+
+// CreateBucket creates an empty bucket. S3 bucket names are global, so the
+// bucket is reachable from clients in every region.
+func (c *Cloud) CreateBucket(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.buckets[name]; !ok {
+		c.buckets[name] = make(map[string]*s3Object)
+	}
+}
+
+// Object returns the content of an object, and false if it does not exist
+func (c *Cloud) Object(bucket, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	object, ok := c.buckets[bucket][key]
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(nil), object.data...), true
+}
+
+// s3API implements aws.S3API
+type s3API struct {
+	cloud *Cloud
+}
+
+// bucket returns the named bucket. Callers must hold c.mu.
+func (s *s3API) bucket(name string) (map[string]*s3Object, error) {
+	bucket, ok := s.cloud.buckets[name]
+	if !ok {
+		return nil, APIError("NoSuchBucket", "The specified bucket does not exist")
+	}
+	return bucket, nil
+}
+
+// GetObject returns an object with its ETag
+func (s *s3API) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	s.cloud.mu.Lock()
+	defer s.cloud.mu.Unlock()
+	if err := s.cloud.injected("GetObject"); err != nil {
+		return nil, err
+	}
+
+	bucket, err := s.bucket(ptrValue(params.Bucket))
+	if err != nil {
+		return nil, err
+	}
+	object, ok := bucket[ptrValue(params.Key)]
+	if !ok {
+		return nil, APIError("NoSuchKey", "The specified key does not exist.")
+	}
+
+	return &s3.GetObjectOutput{
+		Body:          io.NopCloser(strings.NewReader(string(object.data))),
+		ContentLength: ptr(int64(len(object.data))),
+		ETag:          ptr(object.etag),
+	}, nil
+}
+
+// PutObject stores an object, honouring If-Match and If-None-Match: "*"
+// like S3 conditional writes
+func (s *s3API) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	s.cloud.mu.Lock()
+	defer s.cloud.mu.Unlock()
+	if err := s.cloud.injected("PutObject"); err != nil {
+		return nil, err
+	}
+
+	bucket, err := s.bucket(ptrValue(params.Bucket))
+	if err != nil {
+		return nil, err
+	}
+	key := ptrValue(params.Key)
+	existing, exists := bucket[key]
+
+	if ifMatch := ptrValue(params.IfMatch); ifMatch != "" {
+		if !exists {
+			return nil, APIError("NoSuchKey", "The specified key does not exist.")
+		}
+		if ifMatch != existing.etag {
+			return nil, APIError("PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+		}
+	}
+	if ptrValue(params.IfNoneMatch) == "*" && exists {
+		return nil, APIError("PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+	}
+
+	var data []byte
+	if params.Body != nil {
+		data, err = io.ReadAll(params.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sum := md5.Sum(data)
+	object := &s3Object{data: data, etag: `"` + hex.EncodeToString(sum[:]) + `"`}
+	bucket[key] = object
+
+	return &s3.PutObjectOutput{ETag: ptr(object.etag)}, nil
+}
+
+// ListObjectsV2 lists the objects under a prefix in key order. All objects
+// are returned in one page.
+func (s *s3API) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	s.cloud.mu.Lock()
+	defer s.cloud.mu.Unlock()
+	if err := s.cloud.injected("ListObjectsV2"); err != nil {
+		return nil, err
+	}
+
+	bucket, err := s.bucket(ptrValue(params.Bucket))
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for key := range bucket {
+		if strings.HasPrefix(key, ptrValue(params.Prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	output := &s3.ListObjectsV2Output{KeyCount: ptr(int32(len(keys))), IsTruncated: ptr(false)}
+	for _, key := range keys {
+		output.Contents = append(output.Contents, types.Object{
+			Key:  ptr(key),
+			ETag: ptr(bucket[key].etag),
+			Size: ptr(int64(len(bucket[key].data))),
+		})
+	}
+	return output, nil
+}
@@ -0,0 +1,294 @@
+package fakecloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler"
+	schedulertypes "github.com/aws/aws-sdk-go-v2/service/scheduler/types"
+	"github.com/scttfrdmn/lens/pkg/aws"
+)
+
+// scheduleKey identifies a schedule by region, group and name
+type scheduleKey struct {
+	region, group, name string
+}
+
+// schedulerAPI implements aws.SchedulerAPI for one region
+type schedulerAPI struct {
+	cloud  *Cloud
+	region string
+}
+
+// begin locks the cloud and pops any failure injected for the operation. On
+// success the caller must unlock c.mu.
+func (s *schedulerAPI) begin(operation string) (string, error) {
+	s.cloud.mu.Lock()
+	if err := s.cloud.injected(operation); err != nil {
+		s.cloud.mu.Unlock()
+		return "", err
+	}
+	return s.cloud.region(s.region).name, nil
+}
+
+// CreateScheduleGroup creates a schedule group
+func (s *schedulerAPI) CreateScheduleGroup(ctx context.Context, params *scheduler.CreateScheduleGroupInput, optFns ...func(*scheduler.Options)) (*scheduler.CreateScheduleGroupOutput, error) {
+	region, err := s.begin("CreateScheduleGroup")
+	if err != nil {
+		return nil, err
+	}
+	defer s.cloud.mu.Unlock()
+
+	name := ptrValue(params.Name)
+	key := scheduleKey{region: region, group: name}
+	if _, ok := s.cloud.scheduleGroups[key]; ok {
+		return nil, APIError("ConflictException", "Schedule group %s already exists.", name)
+	}
+	tags := make(map[string]string, len(params.Tags))
+	for _, tag := range params.Tags {
+		tags[ptrValue(tag.Key)] = ptrValue(tag.Value)
+	}
+	s.cloud.scheduleGroups[key] = tags
+	return &scheduler.CreateScheduleGroupOutput{
+		ScheduleGroupArn: ptr(fmt.Sprintf("arn:aws:scheduler:%s:%s:schedule-group/%s", region, AccountID, name)),
+	}, nil
+}
+
+// CreateSchedule creates a schedule in an existing group
+func (s *schedulerAPI) CreateSchedule(ctx context.Context, params *scheduler.CreateScheduleInput, optFns ...func(*scheduler.Options)) (*scheduler.CreateScheduleOutput, error) {
+	region, err := s.begin("CreateSchedule")
+	if err != nil {
+		return nil, err
+	}
+	defer s.cloud.mu.Unlock()
+
+	schedule := scheduleDefinition(*params)
+	if err := s.validate(region, schedule); err != nil {
+		return nil, err
+	}
+	key := scheduleKey{region, schedule.GroupName, schedule.Name}
+	if _, ok := s.cloud.schedules[key]; ok {
+		return nil, APIError("ConflictException", "Schedule %s already exists.", schedule.Name)
+	}
+	s.cloud.schedules[key] = &schedule
+	return &scheduler.CreateScheduleOutput{ScheduleArn: ptr(scheduleARN(key))}, nil
+}
+
+// UpdateSchedule replaces an existing schedule
+func (s *schedulerAPI) UpdateSchedule(ctx context.Context, params *scheduler.UpdateScheduleInput, optFns ...func(*scheduler.Options)) (*scheduler.UpdateScheduleOutput, error) {
+	region, err := s.begin("UpdateSchedule")
+	if err != nil {
+		return nil, err
+	}
+	defer s.cloud.mu.Unlock()
+
+	schedule := scheduleDefinition(scheduler.CreateScheduleInput(*params))
+	if err := s.validate(region, schedule); err != nil {
+		return nil, err
+	}
+	key := scheduleKey{region, schedule.GroupName, schedule.Name}
+	if _, ok := s.cloud.schedules[key]; !ok {
+		return nil, APIError("ResourceNotFoundException", "Schedule %s does not exist.", schedule.Name)
+	}
+	s.cloud.schedules[key] = &schedule
+	return &scheduler.UpdateScheduleOutput{ScheduleArn: ptr(scheduleARN(key))}, nil
+}
+
+// scheduleDefinition converts the input of CreateSchedule. A schedule
+// without a group is in the default group.
+func scheduleDefinition(params scheduler.CreateScheduleInput) aws.ScheduleDefinition {
+	schedule := aws.ScheduleDefinition{
+		Name:        ptrValue(params.Name),
+		GroupName:   ptrValue(params.GroupName),
+		Expression:  ptrValue(params.ScheduleExpression),
+		Timezone:    ptrValue(params.ScheduleExpressionTimezone),
+		Description: ptrValue(params.Description),
+		Enabled:     params.State != schedulertypes.ScheduleStateDisabled,
+	}
+	if schedule.GroupName == "" {
+		schedule.GroupName = "default"
+	}
+	if params.Target != nil {
+		schedule.TargetArn = ptrValue(params.Target.Arn)
+		schedule.RoleArn = ptrValue(params.Target.RoleArn)
+		schedule.Input = ptrValue(params.Target.Input)
+	}
+	return schedule
+}
+
+// scheduleARN returns the ARN of a schedule
+func scheduleARN(key scheduleKey) string {
+	return fmt.Sprintf("arn:aws:scheduler:%s:%s:schedule/%s/%s", key.region, AccountID, key.group, key.name)
+}
+
+// validate checks a schedule the way CreateSchedule and UpdateSchedule do.
+// Callers must hold c.mu.
+func (s *schedulerAPI) validate(region string, schedule aws.ScheduleDefinition) error {
+	if _, ok := s.cloud.scheduleGroups[scheduleKey{region: region, group: schedule.GroupName}]; !ok {
+		return APIError("ResourceNotFoundException", "Schedule group %s does not exist.", schedule.GroupName)
+	}
+	expression := schedule.Expression
+	if !strings.HasPrefix(expression, "cron(") && !strings.HasPrefix(expression, "rate(") && !strings.HasPrefix(expression, "at(") {
+		return APIError("ValidationException", "Invalid Schedule Expression %s.", expression)
+	}
+	if schedule.TargetArn == "" {
+		return APIError("ValidationException", "Target is required.")
+	}
+	if schedule.RoleArn == "" {
+		return APIError("ValidationException", "The execution role you provide must allow AWS EventBridge Scheduler to assume the role.")
+	}
+	if schedule.Input != "" && !json.Valid([]byte(schedule.Input)) {
+		return APIError("ValidationException", "Input is not valid JSON.")
+	}
+	return nil
+}
+
+// GetSchedule returns a schedule
+func (s *schedulerAPI) GetSchedule(ctx context.Context, params *scheduler.GetScheduleInput, optFns ...func(*scheduler.Options)) (*scheduler.GetScheduleOutput, error) {
+	region, err := s.begin("GetSchedule")
+	if err != nil {
+		return nil, err
+	}
+	defer s.cloud.mu.Unlock()
+
+	key := scheduleKey{region, groupOrDefault(params.GroupName), ptrValue(params.Name)}
+	schedule, ok := s.cloud.schedules[key]
+	if !ok {
+		return nil, APIError("ResourceNotFoundException", "Schedule %s does not exist.", key.name)
+	}
+	state := schedulertypes.ScheduleStateDisabled
+	if schedule.Enabled {
+		state = schedulertypes.ScheduleStateEnabled
+	}
+	return &scheduler.GetScheduleOutput{
+		Arn:                        ptr(scheduleARN(key)),
+		Name:                       ptr(schedule.Name),
+		GroupName:                  ptr(schedule.GroupName),
+		ScheduleExpression:         ptr(schedule.Expression),
+		ScheduleExpressionTimezone: ptr(schedule.Timezone),
+		Description:                ptr(schedule.Description),
+		State:                      state,
+		Target: &schedulertypes.Target{
+			Arn:     ptr(schedule.TargetArn),
+			RoleArn: ptr(schedule.RoleArn),
+			Input:   ptr(schedule.Input),
+		},
+	}, nil
+}
+
+// ListSchedules returns the schedules in a group, in order of name. Every
+// schedule is returned on one page.
+func (s *schedulerAPI) ListSchedules(ctx context.Context, params *scheduler.ListSchedulesInput, optFns ...func(*scheduler.Options)) (*scheduler.ListSchedulesOutput, error) {
+	region, err := s.begin("ListSchedules")
+	if err != nil {
+		return nil, err
+	}
+	defer s.cloud.mu.Unlock()
+
+	group := groupOrDefault(params.GroupName)
+	if _, ok := s.cloud.scheduleGroups[scheduleKey{region: region, group: group}]; !ok {
+		return nil, APIError("ResourceNotFoundException", "Schedule group %s does not exist.", group)
+	}
+	var keys []scheduleKey
+	for key := range s.cloud.schedules {
+		if key.region == region && key.group == group && strings.HasPrefix(key.name, ptrValue(params.NamePrefix)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].name < keys[j].name })
+
+	out := &scheduler.ListSchedulesOutput{}
+	for _, key := range keys {
+		out.Schedules = append(out.Schedules, schedulertypes.ScheduleSummary{
+			Arn:       ptr(scheduleARN(key)),
+			Name:      ptr(key.name),
+			GroupName: ptr(key.group),
+		})
+	}
+	return out, nil
+}
+
+// DeleteSchedule deletes a schedule
+func (s *schedulerAPI) DeleteSchedule(ctx context.Context, params *scheduler.DeleteScheduleInput, optFns ...func(*scheduler.Options)) (*scheduler.DeleteScheduleOutput, error) {
+	region, err := s.begin("DeleteSchedule")
+	if err != nil {
+		return nil, err
+	}
+	defer s.cloud.mu.Unlock()
+
+	key := scheduleKey{region, groupOrDefault(params.GroupName), ptrValue(params.Name)}
+	if _, ok := s.cloud.schedules[key]; !ok {
+		return nil, APIError("ResourceNotFoundException", "Schedule %s does not exist.", key.name)
+	}
+	delete(s.cloud.schedules, key)
+	return &scheduler.DeleteScheduleOutput{}, nil
+}
+
+// groupOrDefault returns the group of a request, which defaults to the
+// default group
+func groupOrDefault(group *string) string {
+	if name := ptrValue(group); name != "" {
+		return name
+	}
+	return "default"
+}
+
+// Schedule returns a copy of a schedule in a group
+func (c *Cloud) Schedule(region, group, name string) (aws.ScheduleDefinition, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	schedule, ok := c.schedules[scheduleKey{c.region(region).name, group, name}]
+	if !ok {
+		return aws.ScheduleDefinition{}, false
+	}
+	return *schedule, true
+}
+
+// FireSchedule runs a schedule's target now, as EventBridge Scheduler does at
+// each time of its expression. The ec2:startInstances and ec2:stopInstances
+// universal targets are supported.
+func (c *Cloud) FireSchedule(region, group, name string) error {
+	schedule, ok := c.Schedule(region, group, name)
+	if !ok {
+		return fmt.Errorf("schedule %s not found", name)
+	}
+	if !schedule.Enabled {
+		return nil
+	}
+
+	var input struct {
+		InstanceIds []string
+	}
+	if err := json.Unmarshal([]byte(schedule.Input), &input); err != nil {
+		return fmt.Errorf("invalid input of schedule %s: %w", name, err)
+	}
+	api := c.EC2(region)
+	var err error
+	state := ec2types.InstanceStateNameRunning
+	switch schedule.TargetArn {
+	case "arn:aws:scheduler:::aws-sdk:ec2:startInstances":
+		_, err = api.StartInstances(context.Background(), &ec2.StartInstancesInput{InstanceIds: input.InstanceIds})
+	case "arn:aws:scheduler:::aws-sdk:ec2:stopInstances":
+		_, err = api.StopInstances(context.Background(), &ec2.StopInstancesInput{InstanceIds: input.InstanceIds})
+		state = ec2types.InstanceStateNameStopped
+	default:
+		return fmt.Errorf("unsupported target %s", schedule.TargetArn)
+	}
+	if err != nil {
+		return err
+	}
+	// Nobody waits on a scheduled call, so the instances reach the final
+	// state at once
+	for _, id := range input.InstanceIds {
+		if err := c.SetInstanceState(id, state); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package fakecloud
+
+import (
+	"context"
+	"strings"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// Invocation records a command sent to an instance with SendCommand
+type Invocation struct {
+	CommandID    string
+	InstanceID   string
+	Region       string
+	DocumentName string
+	Commands     []string
+}
+
+// Script returns the invocation's commands joined into one shell script
+func (i Invocation) Script() string {
+	return strings.Join(i.Commands, "\n")
+}
+
+// CommandResult is the outcome reported for an invocation
+type CommandResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int32
+}
+
+// CommandHandler decides the outcome of commands run on instances
+type CommandHandler func(Invocation) CommandResult
+
+// DefaultCommandHandler answers HTTP readiness probes (which print the status
+// code with curl -w '%{http_code}') with 200 and succeeds silently otherwise
+func DefaultCommandHandler(inv Invocation) CommandResult {
+	if strings.Contains(inv.Script(), "http_code") {
+		return CommandResult{Stdout: "200"}
+	}
+	return CommandResult{}
+}
+
+// HandleCommands replaces the handler that produces command output
+func (c *Cloud) HandleCommands(handler CommandHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handler = handler
+}
+
+// Invocations returns every command sent so far, in order
+func (c *Cloud) Invocations() []Invocation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Invocation(nil), c.invocations...)
+}
+
+// ssmAPI implements aws.SSMAPI for one region of a Cloud
+type ssmAPI struct {
+	cloud  *Cloud
+	region string
+}
+
+// commandInvocation is the stored result of one command on one instance
+type commandInvocation struct {
+	invocation Invocation
+	result     CommandResult
+}
+
+// begin locks the cloud and applies injected failures.
+// On success the caller must call c.mu.Unlock when done.
+func (s *ssmAPI) begin(operation string) (*regionState, error) {
+	s.cloud.mu.Lock()
+	if err := s.cloud.injected(operation); err != nil {
+		s.cloud.mu.Unlock()
+		return nil, err
+	}
+	return s.cloud.region(s.region), nil
+}
+
+// managed reports whether an instance is registered with Systems Manager:
+// it must be running with an instance profile attached
+func managed(inst *ec2types.Instance) bool {
+	return inst.State.Name == ec2types.InstanceStateNameRunning && inst.IamInstanceProfile != nil
+}
+
+// SendCommand runs the command handler for each target instance and stores the results
+func (s *ssmAPI) SendCommand(ctx context.Context, params *ssm.SendCommandInput, optFns ...func(*ssm.Options)) (*ssm.SendCommandOutput, error) {
+	r, err := s.begin("SendCommand")
+	if err != nil {
+		return nil, err
+	}
+	defer s.cloud.mu.Unlock()
+
+	for _, id := range params.InstanceIds {
+		inst, ok := r.instances[id]
+		if !ok || !managed(inst) {
+			return nil, APIError("InvalidInstanceId", "Instances [[%s]] not in a valid state for account %s", id, AccountID)
+		}
+	}
+
+	commandID := strings.TrimPrefix(s.cloud.nextID("cmd"), "cmd-")
+	for _, id := range params.InstanceIds {
+		inv := Invocation{
+			CommandID:    commandID,
+			InstanceID:   id,
+			Region:       r.name,
+			DocumentName: ptrValue(params.DocumentName),
+			Commands:     append([]string(nil), params.Parameters["commands"]...),
+		}
+		s.cloud.invocations = append(s.cloud.invocations, inv)
+		if s.cloud.commands == nil {
+			s.cloud.commands = make(map[string]*commandInvocation)
+		}
+		s.cloud.commands[commandID+"/"+id] = &commandInvocation{
+			invocation: inv,
+			result:     s.cloud.handler(inv),
+		}
+	}
+
+	return &ssm.SendCommandOutput{
+		Command: &types.Command{
+			CommandId:    ptr(commandID),
+			DocumentName: params.DocumentName,
+			InstanceIds:  params.InstanceIds,
+			Parameters:   params.Parameters,
+			Status:       types.CommandStatusSuccess,
+		},
+	}, nil
+}
+
+// GetCommandInvocation returns the result of a command on an instance
+func (s *ssmAPI) GetCommandInvocation(ctx context.Context, params *ssm.GetCommandInvocationInput, optFns ...func(*ssm.Options)) (*ssm.GetCommandInvocationOutput, error) {
+	if _, err := s.begin("GetCommandInvocation"); err != nil {
+		return nil, err
+	}
+	defer s.cloud.mu.Unlock()
+
+	stored, ok := s.cloud.commands[ptrValue(params.CommandId)+"/"+ptrValue(params.InstanceId)]
+	if !ok {
+		return nil, APIError("InvocationDoesNotExist", "")
+	}
+
+	status := types.CommandInvocationStatusSuccess
+	if stored.result.ExitCode != 0 {
+		status = types.CommandInvocationStatusFailed
+	}
+	return &ssm.GetCommandInvocationOutput{
+		CommandId:             params.CommandId,
+		InstanceId:            params.InstanceId,
+		DocumentName:          ptr(stored.invocation.DocumentName),
+		Status:                status,
+		StatusDetails:         ptr(string(status)),
+		ResponseCode:          stored.result.ExitCode,
+		StandardOutputContent: ptr(stored.result.Stdout),
+		StandardErrorContent:  ptr(stored.result.Stderr),
+	}, nil
+}
+
+// DescribeInstanceInformation lists managed instances, filtered by the
+// InstanceIds key. Running instances with an instance profile are online.
+func (s *ssmAPI) DescribeInstanceInformation(ctx context.Context, params *ssm.DescribeInstanceInformationInput, optFns ...func(*ssm.Options)) (*ssm.DescribeInstanceInformationOutput, error) {
+	r, err := s.begin("DescribeInstanceInformation")
+	if err != nil {
+		return nil, err
+	}
+	defer s.cloud.mu.Unlock()
+
+	var ids []string
+	for _, filter := range params.Filters {
+		switch ptrValue(filter.Key) {
+		case "InstanceIds":
+			ids = append(ids, filter.Values...)
+		default:
+			return nil, APIError("InvalidFilterKey", "The filter key %s is not valid", ptrValue(filter.Key))
+		}
+	}
+
+	out := &ssm.DescribeInstanceInformationOutput{}
+	for _, id := range sortedKeys(r.instances) {
+		inst := r.instances[id]
+		if len(ids) > 0 && !contains(ids, id) {
+			continue
+		}
+		if !managed(inst) {
+			continue
+		}
+		out.InstanceInformationList = append(out.InstanceInformationList, types.InstanceInformation{
+			InstanceId:   ptr(id),
+			PingStatus:   types.PingStatusOnline,
+			PlatformType: types.PlatformTypeLinux,
+			IPAddress:    inst.PrivateIpAddress,
+			ResourceType: types.ResourceTypeEc2Instance,
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,35 @@
+package fakecloud
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// SetCaller changes the principal ARN returned by GetCallerIdentity, to act
+// as a different user of the same account
+func (c *Cloud) SetCaller(arn string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.callerARN = arn
+}
+
+// stsAPI implements aws.STSAPI
+type stsAPI struct {
+	cloud *Cloud
+}
+
+// GetCallerIdentity returns the current caller of the account
+func (s *stsAPI) GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	s.cloud.mu.Lock()
+	defer s.cloud.mu.Unlock()
+	if err := s.cloud.injected("GetCallerIdentity"); err != nil {
+		return nil, err
+	}
+
+	return &sts.GetCallerIdentityOutput{
+		Account: ptr(AccountID),
+		Arn:     ptr(s.cloud.callerARN),
+		UserId:  ptr("AIDAFAKE" + AccountID),
+	}, nil
+}
@@ -0,0 +1,345 @@
+package fakecloud
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// volumeModificationCooldown is how long EBS makes a volume wait between modifications
+const volumeModificationCooldown = 6 * time.Hour
+
+// DescribeVolumes lists volumes by ID or with the volume-id, status,
+// attachment.instance-id, availability-zone, tag:<key> and tag-key filters,
+// advancing volumes and attachments in progress first
+func (e *ec2API) DescribeVolumes(ctx context.Context, params *ec2.DescribeVolumesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVolumesOutput, error) {
+	r, err := e.begin("DescribeVolumes")
+	if err != nil {
+		return nil, err
+	}
+	defer e.cloud.mu.Unlock()
+	e.cloud.settle(r)
+
+	for _, id := range params.VolumeIds {
+		if _, ok := r.volumes[id]; !ok {
+			return nil, APIError("InvalidVolume.NotFound", "The volume '%s' does not exist.", id)
+		}
+	}
+
+	out := &ec2.DescribeVolumesOutput{}
+	for _, id := range sortedKeys(r.volumes) {
+		volume := r.volumes[id]
+		if len(params.VolumeIds) > 0 && !contains(params.VolumeIds, id) {
+			continue
+		}
+		ok, err := matchFilters(params.Filters, func(name string) ([]string, bool) {
+			switch name {
+			case "volume-id":
+				return []string{id}, true
+			case "status":
+				return []string{string(volume.State)}, true
+			case "availability-zone":
+				return []string{ptrValue(volume.AvailabilityZone)}, true
+			case "attachment.instance-id":
+				var instances []string
+				for _, attachment := range volume.Attachments {
+					instances = append(instances, ptrValue(attachment.InstanceId))
+				}
+				return instances, true
+			}
+			return tagLookup(volume.Tags, name)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out.Volumes = append(out.Volumes, *volume)
+		}
+	}
+	return out, nil
+}
+
+// ModifyVolume grows a volume. The modification is optimizing by the next
+// DescribeVolumesModifications and completed by the one after.
+func (e *ec2API) ModifyVolume(ctx context.Context, params *ec2.ModifyVolumeInput, optFns ...func(*ec2.Options)) (*ec2.ModifyVolumeOutput, error) {
+	r, err := e.begin("ModifyVolume")
+	if err != nil {
+		return nil, err
+	}
+	defer e.cloud.mu.Unlock()
+
+	id := ptrValue(params.VolumeId)
+	volume, ok := r.volumes[id]
+	if !ok {
+		return nil, APIError("InvalidVolume.NotFound", "The volume '%s' does not exist.", id)
+	}
+	now := e.cloud.now().UTC()
+	if previous, ok := r.modifications[id]; ok && now.Sub(ptrValue(previous.StartTime)) < volumeModificationCooldown {
+		return nil, APIError("VolumeModificationRateExceeded",
+			"You've reached the maximum modification rate per volume limit. Wait at least 6 hours between modifications per EBS volume.")
+	}
+
+	size := ptrValue(volume.Size)
+	if params.Size != nil {
+		if *params.Size < size {
+			return nil, APIError("InvalidParameterValue", "New size cannot be smaller than existing size")
+		}
+		size = *params.Size
+	}
+	volumeType := volume.VolumeType
+	if params.VolumeType != "" {
+		volumeType = params.VolumeType
+	}
+	if size == ptrValue(volume.Size) && volumeType == volume.VolumeType {
+		return nil, APIError("InvalidParameterCombination", "New configuration must differ from the existing configuration")
+	}
+
+	modification := &types.VolumeModification{
+		VolumeId:           ptr(id),
+		ModificationState:  types.VolumeModificationStateModifying,
+		OriginalSize:       volume.Size,
+		TargetSize:         ptr(size),
+		OriginalVolumeType: volume.VolumeType,
+		TargetVolumeType:   volumeType,
+		Progress:           ptr(int64(0)),
+		StartTime:          ptr(now),
+	}
+	r.modifications[id] = modification
+	volume.Size = ptr(size)
+	volume.VolumeType = volumeType
+
+	copied := *modification
+	return &ec2.ModifyVolumeOutput{VolumeModification: &copied}, nil
+}
+
+// DescribeVolumesModifications returns the latest modification of each
+// requested volume, advancing modifications in progress first
+func (e *ec2API) DescribeVolumesModifications(ctx context.Context, params *ec2.DescribeVolumesModificationsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVolumesModificationsOutput, error) {
+	r, err := e.begin("DescribeVolumesModifications")
+	if err != nil {
+		return nil, err
+	}
+	defer e.cloud.mu.Unlock()
+	e.cloud.settle(r)
+
+	out := &ec2.DescribeVolumesModificationsOutput{}
+	for _, id := range sortedKeys(r.modifications) {
+		if len(params.VolumeIds) > 0 && !contains(params.VolumeIds, id) {
+			continue
+		}
+		out.VolumesModifications = append(out.VolumesModifications, *r.modifications[id])
+	}
+	return out, nil
+}
+
+// releaseVolumes deletes the volumes of a terminated instance that are
+// deleted on termination and detaches the others. Callers must hold c.mu.
+func releaseVolumes(r *regionState, inst *types.Instance) {
+	instanceID := ptrValue(inst.InstanceId)
+	for _, id := range sortedKeys(r.volumes) {
+		volume := r.volumes[id]
+		for _, attachment := range volume.Attachments {
+			if ptrValue(attachment.InstanceId) != instanceID {
+				continue
+			}
+			if ptrValue(attachment.DeleteOnTermination) {
+				delete(r.volumes, id)
+			} else {
+				volume.Attachments = nil
+				volume.State = types.VolumeStateAvailable
+			}
+			break
+		}
+	}
+}
+
+// CreateVolume creates an empty gp2 or gp3 volume, or one restored from a
+// snapshot, in an availability zone of the region. The volume is available
+// by the next DescribeVolumes.
+func (e *ec2API) CreateVolume(ctx context.Context, params *ec2.CreateVolumeInput, optFns ...func(*ec2.Options)) (*ec2.CreateVolumeOutput, error) {
+	r, err := e.begin("CreateVolume")
+	if err != nil {
+		return nil, err
+	}
+	defer e.cloud.mu.Unlock()
+
+	zone := ptrValue(params.AvailabilityZone)
+	if !contains(r.zones, zone) {
+		return nil, APIError("InvalidZone.NotFound", "The zone '%s' does not exist.", zone)
+	}
+	size := ptrValue(params.Size)
+	if snapshotID := ptrValue(params.SnapshotId); snapshotID != "" {
+		snapshot, ok := r.snapshots[snapshotID]
+		if !ok {
+			return nil, APIError("InvalidSnapshot.NotFound", "The snapshot '%s' does not exist.", snapshotID)
+		}
+		if size == 0 {
+			size = ptrValue(snapshot.VolumeSize)
+		} else if size < ptrValue(snapshot.VolumeSize) {
+			return nil, APIError("InvalidParameterValue", "Volume of %dGiB is smaller than snapshot '%s', expect size >= %dGiB", size, snapshotID, ptrValue(snapshot.VolumeSize))
+		}
+	}
+	if size < 1 || size > 16384 {
+		return nil, APIError("InvalidParameterValue", "Volume size must be between 1 and 16384 GiB")
+	}
+	volumeType := params.VolumeType
+	if volumeType == "" {
+		volumeType = types.VolumeTypeGp2
+	}
+
+	volumeID := e.cloud.nextID("vol")
+	volume := &types.Volume{
+		VolumeId:         ptr(volumeID),
+		Size:             ptr(size),
+		VolumeType:       volumeType,
+		AvailabilityZone: ptr(zone),
+		CreateTime:       ptr(e.cloud.now().UTC()),
+		State:            types.VolumeStateCreating,
+		Tags:             tagsFor(params.TagSpecifications, types.ResourceTypeVolume),
+	}
+	if params.SnapshotId != nil {
+		volume.SnapshotId = params.SnapshotId
+	}
+	r.volumes[volumeID] = volume
+
+	return &ec2.CreateVolumeOutput{
+		VolumeId:         volume.VolumeId,
+		Size:             volume.Size,
+		VolumeType:       volume.VolumeType,
+		AvailabilityZone: volume.AvailabilityZone,
+		CreateTime:       volume.CreateTime,
+		SnapshotId:       volume.SnapshotId,
+		State:            volume.State,
+		Tags:             volume.Tags,
+	}, nil
+}
+
+// AttachVolume attaches an available volume to a running or stopped instance
+// in the same availability zone. The volume is not deleted on termination.
+func (e *ec2API) AttachVolume(ctx context.Context, params *ec2.AttachVolumeInput, optFns ...func(*ec2.Options)) (*ec2.AttachVolumeOutput, error) {
+	r, err := e.begin("AttachVolume")
+	if err != nil {
+		return nil, err
+	}
+	defer e.cloud.mu.Unlock()
+
+	volumeID := ptrValue(params.VolumeId)
+	volume, ok := r.volumes[volumeID]
+	if !ok {
+		return nil, APIError("InvalidVolume.NotFound", "The volume '%s' does not exist.", volumeID)
+	}
+	instanceID := ptrValue(params.InstanceId)
+	inst, ok := r.instances[instanceID]
+	if !ok {
+		return nil, APIError("InvalidInstanceID.NotFound", "The instance ID '%s' does not exist", instanceID)
+	}
+	if volume.State != types.VolumeStateAvailable {
+		return nil, APIError("VolumeInUse", "%s is already attached to an instance", volumeID)
+	}
+	if inst.State.Name != types.InstanceStateNameRunning && inst.State.Name != types.InstanceStateNameStopped {
+		return nil, APIError("IncorrectState", "Instance '%s' is not 'running' or 'stopped'.", instanceID)
+	}
+	if ptrValue(volume.AvailabilityZone) != ptrValue(inst.Placement.AvailabilityZone) {
+		return nil, APIError("InvalidVolume.ZoneMismatch", "The volume '%s' is not in the same availability zone as instance '%s'", volumeID, instanceID)
+	}
+	for _, mapping := range inst.BlockDeviceMappings {
+		if ptrValue(mapping.DeviceName) == ptrValue(params.Device) {
+			return nil, APIError("InvalidParameterValue", "Invalid value '%s' for unixDevice. Attachment point %s is already in use", ptrValue(params.Device), ptrValue(params.Device))
+		}
+	}
+
+	now := e.cloud.now().UTC()
+	attachment := types.VolumeAttachment{
+		VolumeId:            ptr(volumeID),
+		InstanceId:          ptr(instanceID),
+		Device:              params.Device,
+		State:               types.VolumeAttachmentStateAttaching,
+		AttachTime:          ptr(now),
+		DeleteOnTermination: ptr(false),
+	}
+	volume.Attachments = []types.VolumeAttachment{attachment}
+	volume.State = types.VolumeStateInUse
+	inst.BlockDeviceMappings = append(inst.BlockDeviceMappings, types.InstanceBlockDeviceMapping{
+		DeviceName: params.Device,
+		Ebs: &types.EbsInstanceBlockDevice{
+			VolumeId:            ptr(volumeID),
+			Status:              types.AttachmentStatusAttaching,
+			AttachTime:          ptr(now),
+			DeleteOnTermination: ptr(false),
+		},
+	})
+
+	return &ec2.AttachVolumeOutput{
+		VolumeId:   attachment.VolumeId,
+		InstanceId: attachment.InstanceId,
+		Device:     attachment.Device,
+		State:      attachment.State,
+		AttachTime: attachment.AttachTime,
+	}, nil
+}
+
+// DeleteVolume deletes a volume that is not attached to an instance
+func (e *ec2API) DeleteVolume(ctx context.Context, params *ec2.DeleteVolumeInput, optFns ...func(*ec2.Options)) (*ec2.DeleteVolumeOutput, error) {
+	r, err := e.begin("DeleteVolume")
+	if err != nil {
+		return nil, err
+	}
+	defer e.cloud.mu.Unlock()
+	e.cloud.settle(r)
+
+	volumeID := ptrValue(params.VolumeId)
+	volume, ok := r.volumes[volumeID]
+	if !ok {
+		return nil, APIError("InvalidVolume.NotFound", "The volume '%s' does not exist.", volumeID)
+	}
+	if volume.State != types.VolumeStateAvailable {
+		return nil, APIError("VolumeInUse", "Volume %s is currently attached to %s", volumeID, ptrValue(volume.Attachments[0].InstanceId))
+	}
+	delete(r.volumes, volumeID)
+	delete(r.modifications, volumeID)
+	return &ec2.DeleteVolumeOutput{}, nil
+}
+
+// CreateSnapshot starts a pending snapshot of a volume, completed by the next
+// call that settles the region
+func (e *ec2API) CreateSnapshot(ctx context.Context, params *ec2.CreateSnapshotInput, optFns ...func(*ec2.Options)) (*ec2.CreateSnapshotOutput, error) {
+	r, err := e.begin("CreateSnapshot")
+	if err != nil {
+		return nil, err
+	}
+	defer e.cloud.mu.Unlock()
+
+	volumeID := ptrValue(params.VolumeId)
+	volume, ok := r.volumes[volumeID]
+	if !ok {
+		return nil, APIError("InvalidVolume.NotFound", "The volume '%s' does not exist.", volumeID)
+	}
+
+	snapshotID := e.cloud.nextID("snap")
+	snapshot := &types.Snapshot{
+		SnapshotId:  ptr(snapshotID),
+		VolumeId:    ptr(volumeID),
+		VolumeSize:  volume.Size,
+		Description: params.Description,
+		OwnerId:     ptr(AccountID),
+		State:       types.SnapshotStatePending,
+		Progress:    ptr("0%"),
+		StartTime:   ptr(e.cloud.now().UTC()),
+		Tags:        tagsFor(params.TagSpecifications, types.ResourceTypeSnapshot),
+	}
+	r.snapshots[snapshotID] = snapshot
+
+	return &ec2.CreateSnapshotOutput{
+		SnapshotId:  snapshot.SnapshotId,
+		VolumeId:    snapshot.VolumeId,
+		VolumeSize:  snapshot.VolumeSize,
+		Description: snapshot.Description,
+		OwnerId:     snapshot.OwnerId,
+		State:       snapshot.State,
+		Progress:    snapshot.Progress,
+		StartTime:   snapshot.StartTime,
+		Tags:        snapshot.Tags,
+	}, nil
+}
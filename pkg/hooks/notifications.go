@@ -22,6 +22,10 @@ const (
 	EventStopFailed      EventType = "stop_failed"
 	EventConnectStarted  EventType = "connect_started"
 	EventConnectFailed   EventType = "connect_failed"
+	EventBudgetExceeded  EventType = "budget_exceeded"
+	EventIdleWarning     EventType = "idle_warning"
+	EventTTLWarning      EventType = "ttl_warning"
+	EventTTLExpired      EventType = "ttl_expired"
 )
 
 // EventData contains information about the event
@@ -34,12 +38,25 @@ type EventData struct {
 	Timestamp    time.Time
 	Error        string // Only populated for failed events
 	AppName      string // "jupyter", "rstudio", "vscode"
+
+	// Only populated for budget events
+	Budget      string
+	BudgetLimit float64
+	BudgetSpend float64
+
+	// Only populated for idle warnings: when the idle instance will stop
+	StopAt time.Time
+
+	// Only populated for TTL events: when the instance's TTL runs out, and
+	// what reap did once it had
+	ExpiresAt time.Time
+	Action    string
 }
 
 // ExecuteHook runs a configured notification hook if one exists
 func ExecuteHook(event EventData) error {
 	// Check if hooks are configured
-	cfg, err := config.LoadUserConfig()
+	cfg, err := config.LoadConfig()
 	if err != nil {
 		// No config or error reading - skip hooks silently
 		return nil
@@ -99,6 +116,14 @@ func getHookCommand(hooks *config.HooksConfig, eventType EventType) string {
 		return hooks.OnConnectStarted
 	case EventConnectFailed:
 		return hooks.OnConnectFailed
+	case EventBudgetExceeded:
+		return hooks.OnBudgetExceeded
+	case EventIdleWarning:
+		return hooks.OnIdleWarning
+	case EventTTLWarning:
+		return hooks.OnTTLWarning
+	case EventTTLExpired:
+		return hooks.OnTTLExpired
 	default:
 		return ""
 	}
@@ -120,6 +145,25 @@ func prepareHookEnvironment(event EventData) []string {
 		env = append(env, fmt.Sprintf("AWS_IDE_ERROR=%s", event.Error))
 	}
 
+	if event.Budget != "" {
+		env = append(env,
+			fmt.Sprintf("AWS_IDE_BUDGET=%s", event.Budget),
+			fmt.Sprintf("AWS_IDE_BUDGET_LIMIT=%.2f", event.BudgetLimit),
+			fmt.Sprintf("AWS_IDE_BUDGET_SPEND=%.2f", event.BudgetSpend),
+		)
+	}
+
+	if !event.StopAt.IsZero() {
+		env = append(env, fmt.Sprintf("AWS_IDE_STOP_AT=%s", event.StopAt.Format(time.RFC3339)))
+	}
+
+	if !event.ExpiresAt.IsZero() {
+		env = append(env, fmt.Sprintf("AWS_IDE_EXPIRES_AT=%s", event.ExpiresAt.Format(time.RFC3339)))
+	}
+	if event.Action != "" {
+		env = append(env, fmt.Sprintf("AWS_IDE_ACTION=%s", event.Action))
+	}
+
 	return env
 }
 
@@ -170,6 +214,15 @@ func FormatEventMessage(event EventData) string {
 		action = "connection established"
 	case EventConnectFailed:
 		action = "connection failed"
+	case EventBudgetExceeded:
+		return fmt.Sprintf("Budget %s exceeded: $%.2f of $%.2f spent, instance %s stopped",
+			event.Budget, event.BudgetSpend, event.BudgetLimit, event.InstanceID)
+	case EventIdleWarning:
+		return fmt.Sprintf("Instance %s is idle and will stop at %s", event.InstanceID, event.StopAt.Format(time.RFC3339))
+	case EventTTLWarning:
+		return fmt.Sprintf("Instance %s expires at %s", event.InstanceID, event.ExpiresAt.Format(time.RFC3339))
+	case EventTTLExpired:
+		return fmt.Sprintf("Instance %s expired at %s and was %s", event.InstanceID, event.ExpiresAt.Format(time.RFC3339), event.Action)
 	default:
 		action = string(event.EventType)
 	}
@@ -0,0 +1,220 @@
+// Package schedule parses the cron expressions of instance start/stop
+// schedules, converts them for EventBridge Scheduler and works out when a
+// schedule starts and stops an instance
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSearchDays is how far ahead Next looks for a matching time, long enough
+// to find an expression that only matches on February 29
+const maxSearchDays = 5 * 366
+
+// field is the range and names of one cron field
+type field struct {
+	name     string
+	min, max int
+	names    []string // Names of the values from min, e.g. JAN for 1
+}
+
+var fields = [5]field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT", "SUN"}},
+}
+
+// Cron is a standard five-field cron expression: minute, hour, day of month,
+// month and day of week, e.g. "0 8 * * 1-5" for 8:00 on weekdays
+type Cron struct {
+	expr   string
+	fields [5]string
+	values [5]uint64 // Bit i is set when value i matches
+}
+
+// ParseCron parses a five-field cron expression. Fields take *, values,
+// ranges, lists and steps (e.g. */15, 1-5, MON,WED). Months and days of the
+// week may be given by name, and Sunday is 0 or 7.
+func ParseCron(expr string) (*Cron, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields (minute hour day-of-month month day-of-week), got %d", expr, len(parts))
+	}
+
+	c := &Cron{expr: strings.Join(parts, " ")}
+	for i, part := range parts {
+		values, err := parseField(strings.ToUpper(part), fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+		}
+		c.fields[i] = strings.ToUpper(part)
+		c.values[i] = values
+	}
+	// Sunday is both 0 and 7
+	if c.values[4]&(1<<7) != 0 {
+		c.values[4] = c.values[4]&^(1<<7) | 1
+	}
+	return c, nil
+}
+
+// parseField parses one comma-separated cron field into a bit set
+func parseField(value string, f field) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(value, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepPart, f.name)
+			}
+			step = n
+		}
+
+		low, high := f.min, f.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			from, to, _ := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = parseValue(from, f); err != nil {
+				return 0, err
+			}
+			if high, err = parseValue(to, f); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range %q in %s field", rangePart, f.name)
+			}
+		default:
+			n, err := parseValue(rangePart, f)
+			if err != nil {
+				return 0, err
+			}
+			low = n
+			if !hasStep {
+				high = n
+			}
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// parseValue parses a number or name within the range of a field
+func parseValue(value string, f field) (int, error) {
+	for i, name := range f.names {
+		if value == name {
+			return f.min + i, nil
+		}
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("invalid %s %q: must be %d-%d", f.name, value, f.min, f.max)
+	}
+	return n, nil
+}
+
+// String returns the expression as parsed
+func (c *Cron) String() string {
+	return c.expr
+}
+
+// matches reports whether bit v of field i is set
+func (c *Cron) matches(i, v int) bool {
+	return c.values[i]&(1<<v) != 0
+}
+
+// matchesDay reports whether the expression runs on a day. As in cron, when
+// both the day of month and the day of week are restricted, either matches.
+func (c *Cron) matchesDay(day time.Time) bool {
+	if !c.matches(3, int(day.Month())) {
+		return false
+	}
+	dom, dow := c.matches(2, day.Day()), c.matches(4, int(day.Weekday()))
+	switch {
+	case c.fields[2] != "*" && c.fields[4] != "*":
+		return dom || dow
+	case c.fields[4] != "*":
+		return dow
+	}
+	return dom
+}
+
+// Next returns the first time after after that the expression matches, in
+// after's location, or the zero time if it matches none in the next five years
+func (c *Cron) Next(after time.Time) time.Time {
+	loc := after.Location()
+	start := time.Date(after.Year(), after.Month(), after.Day(), 0, 0, 0, 0, loc)
+	for i := 0; i < maxSearchDays; i++ {
+		day := start.AddDate(0, 0, i)
+		if !c.matchesDay(day) {
+			continue
+		}
+		for hour := 0; hour < 24; hour++ {
+			if !c.matches(1, hour) {
+				continue
+			}
+			for minute := 0; minute < 60; minute++ {
+				if !c.matches(0, minute) {
+					continue
+				}
+				if at := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, loc); at.After(after) {
+					return at
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// EventBridge returns the expression in the six-field form of EventBridge
+// Scheduler, e.g. "cron(0 8 ? * MON-FRI *)". EventBridge numbers the days of
+// the week from Sunday as 1, so they are given by name, and it cannot restrict
+// both the day of month and the day of week.
+func (c *Cron) EventBridge() (string, error) {
+	dom, dow := c.fields[2], c.fields[4]
+	switch {
+	case dom != "*" && dow != "*":
+		return "", fmt.Errorf("%q restricts both the day of month and the day of week, which EventBridge Scheduler does not support", c.expr)
+	case dow == "*":
+		dow = "?"
+	default:
+		dom = "?"
+		dow = dayNames(c.values[4])
+	}
+	return fmt.Sprintf("cron(%s %s %s %s %s *)", c.fields[0], c.fields[1], dom, c.fields[3], dow), nil
+}
+
+// dayNames writes a set of days of the week as names, with runs of days as
+// ranges, e.g. "MON-FRI" or "SUN,SAT"
+func dayNames(days uint64) string {
+	names := fields[4].names
+	var parts []string
+	for day := 0; day < 7; day++ {
+		if days&(1<<day) == 0 {
+			continue
+		}
+		last := day
+		for last+1 < 7 && days&(1<<(last+1)) != 0 {
+			last++
+		}
+		switch {
+		case last == day:
+			parts = append(parts, names[day])
+		case last == day+1:
+			parts = append(parts, names[day], names[last])
+		default:
+			parts = append(parts, names[day]+"-"+names[last])
+		}
+		day = last
+	}
+	return strings.Join(parts, ",")
+}
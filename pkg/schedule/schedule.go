@@ -0,0 +1,145 @@
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Instance states a schedule moves an instance into
+const (
+	StateRunning = "running"
+	StateStopped = "stopped"
+)
+
+// maxTransitions bounds how many transitions Transitions returns, so that a
+// schedule that fires every minute over a long range stays cheap
+const maxTransitions = 10000
+
+// projectionStart is the Monday projections start from. It is fixed so that
+// projections do not change from one day to the next.
+var projectionStart = time.Date(2025, time.January, 6, 0, 0, 0, 0, time.UTC)
+
+// projectionWeeks is how many weeks a projection averages over, so that
+// schedules that depend on the day of the month are covered
+const projectionWeeks = 4
+
+// Schedule starts and stops an instance at the times of two cron expressions
+// in a time zone. Either expression may be omitted, e.g. to stop an instance
+// every evening but only start it by hand.
+type Schedule struct {
+	Start    *Cron
+	Stop     *Cron
+	Location *time.Location
+}
+
+// Transition is a scheduled start or stop
+type Transition struct {
+	State string // StateRunning or StateStopped
+	At    time.Time
+}
+
+// New creates a schedule from start and stop cron expressions and an IANA
+// time zone name such as "America/New_York". An empty time zone is UTC.
+func New(start, stop, timezone string) (*Schedule, error) {
+	if start == "" && stop == "" {
+		return nil, fmt.Errorf("a schedule needs a start or a stop expression")
+	}
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", timezone, err)
+	}
+
+	s := &Schedule{Location: loc}
+	if start != "" {
+		if s.Start, err = ParseCron(start); err != nil {
+			return nil, err
+		}
+		if _, err := s.Start.EventBridge(); err != nil {
+			return nil, err
+		}
+	}
+	if stop != "" {
+		if s.Stop, err = ParseCron(stop); err != nil {
+			return nil, err
+		}
+		if _, err := s.Stop.EventBridge(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Transitions returns the scheduled starts and stops after from and up to
+// until, in order
+func (s *Schedule) Transitions(from, until time.Time) []Transition {
+	var transitions []Transition
+	add := func(cron *Cron, state string) {
+		if cron == nil {
+			return
+		}
+		for at := cron.Next(from.In(s.Location)); !at.IsZero() && !at.After(until) && len(transitions) < maxTransitions; at = cron.Next(at) {
+			transitions = append(transitions, Transition{State: state, At: at})
+		}
+	}
+	add(s.Start, StateRunning)
+	add(s.Stop, StateStopped)
+
+	sort.SliceStable(transitions, func(i, j int) bool {
+		return transitions[i].At.Before(transitions[j].At)
+	})
+	return transitions
+}
+
+// Next returns the next scheduled start or stop after a time, or false if
+// there is none within five years
+func (s *Schedule) Next(after time.Time) (Transition, bool) {
+	var next Transition
+	for _, candidate := range []struct {
+		cron  *Cron
+		state string
+	}{{s.Start, StateRunning}, {s.Stop, StateStopped}} {
+		if candidate.cron == nil {
+			continue
+		}
+		at := candidate.cron.Next(after.In(s.Location))
+		if !at.IsZero() && (next.At.IsZero() || at.Before(next.At)) {
+			next = Transition{State: candidate.state, At: at}
+		}
+	}
+	return next, !next.At.IsZero()
+}
+
+// HoursPerWeek returns how many hours a week the schedule keeps an instance
+// running, averaged over four weeks. It is only known for schedules with both
+// a start and a stop.
+func (s *Schedule) HoursPerWeek() (float64, bool) {
+	if s.Start == nil || s.Stop == nil {
+		return 0, false
+	}
+
+	// The state at the start of the projection is that of the last
+	// transition before it
+	start := time.Date(projectionStart.Year(), projectionStart.Month(), projectionStart.Day(), 0, 0, 0, 0, s.Location)
+	end := start.AddDate(0, 0, 7*projectionWeeks)
+	state := StateStopped
+	if before := s.Transitions(start.AddDate(0, 0, -31), start); len(before) > 0 {
+		state = before[len(before)-1].State
+	}
+
+	var running time.Duration
+	since := start
+	for _, transition := range s.Transitions(start, end) {
+		if state == StateRunning {
+			running += transition.At.Sub(since)
+		}
+		state, since = transition.State, transition.At
+	}
+	if state == StateRunning {
+		running += end.Sub(since)
+	}
+	return running.Hours() / projectionWeeks, true
+}
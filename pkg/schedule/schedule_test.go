@@ -0,0 +1,133 @@
+package schedule
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCron_EventBridge(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"0 8 * * 1-5", "cron(0 8 ? * MON-FRI *)"},
+		{"30 18 * * mon-fri", "cron(30 18 ? * MON-FRI *)"},
+		{"0 9 * * 0,6", "cron(0 9 ? * SUN,SAT *)"},
+		{"0 9 * * 7", "cron(0 9 ? * SUN *)"},
+		{"0 9 * * 1,2,3,5", "cron(0 9 ? * MON-WED,FRI *)"},
+		{"*/15 * * * *", "cron(*/15 * * * ? *)"},
+		{"0 0 1 jan *", "cron(0 0 1 JAN ? *)"},
+	}
+	for _, tt := range tests {
+		cron, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		got, err := cron.EventBridge()
+		if err != nil || got != tt.want {
+			t.Errorf("EventBridge(%q) = %q, %v; want %q", tt.expr, got, err, tt.want)
+		}
+	}
+
+	cron, err := ParseCron("0 8 1 * 1")
+	if err != nil {
+		t.Fatalf("ParseCron failed: %v", err)
+	}
+	if _, err := cron.EventBridge(); err == nil {
+		t.Error("expected an error when both days are restricted")
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "0 8 * *", "60 8 * * *", "0 8 * * 1-9", "0 8 * * 5-1", "*/0 * * * *", "0 8 * foo *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) should fail", expr)
+		}
+	}
+}
+
+func TestCron_Next(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	cron, err := ParseCron("0 8 * * 1-5")
+	if err != nil {
+		t.Fatalf("ParseCron failed: %v", err)
+	}
+
+	// Friday 9:00 is followed by Monday 8:00
+	friday := time.Date(2026, time.March, 6, 9, 0, 0, 0, ny)
+	if got, want := cron.Next(friday), time.Date(2026, time.March, 9, 8, 0, 0, 0, ny); !got.Equal(want) {
+		t.Errorf("Next(%s) = %s, want %s", friday, got, want)
+	}
+	// The time itself does not match
+	monday := time.Date(2026, time.March, 9, 8, 0, 0, 0, ny)
+	if got := cron.Next(monday); !got.Equal(monday.AddDate(0, 0, 1)) {
+		t.Errorf("Next(%s) = %s, want the next day", monday, got)
+	}
+
+	leap, err := ParseCron("0 0 29 2 *")
+	if err != nil {
+		t.Fatalf("ParseCron failed: %v", err)
+	}
+	if got := leap.Next(friday); got.Year() != 2028 || got.Month() != time.February || got.Day() != 29 {
+		t.Errorf("expected the next February 29, got %s", got)
+	}
+}
+
+func TestSchedule_TransitionsAndHoursPerWeek(t *testing.T) {
+	s, err := New("0 8 * * 1-5", "0 18 * * 1-5", "America/New_York")
+	if err != nil {
+		if strings.Contains(err.Error(), "time zone") {
+			t.Skipf("time zone data unavailable: %v", err)
+		}
+		t.Fatalf("New failed: %v", err)
+	}
+
+	// Saturday to Saturday covers five starts and five stops
+	from := time.Date(2026, time.March, 7, 0, 0, 0, 0, s.Location)
+	transitions := s.Transitions(from, from.AddDate(0, 0, 7))
+	if len(transitions) != 10 {
+		t.Fatalf("expected 10 transitions, got %d", len(transitions))
+	}
+	if first := transitions[0]; first.State != StateRunning || first.At.Hour() != 8 || first.At.Weekday() != time.Monday {
+		t.Errorf("expected the first transition to be a Monday start, got %+v", first)
+	}
+	// Daylight saving time started on March 8, so 8:00 in New York is 12:00 UTC
+	if got := transitions[0].At.UTC().Hour(); got != 12 {
+		t.Errorf("expected 12:00 UTC, got %d:00", got)
+	}
+	for i := 1; i < len(transitions); i++ {
+		if transitions[i].State == transitions[i-1].State {
+			t.Fatalf("transitions should alternate, got %+v", transitions)
+		}
+	}
+
+	hours, ok := s.HoursPerWeek()
+	if !ok || math.Abs(hours-50) > 0.01 {
+		t.Errorf("expected 50 hours a week, got %.2f (%v)", hours, ok)
+	}
+
+	next, ok := s.Next(from)
+	if !ok || next.State != StateRunning || !next.At.Equal(transitions[0].At) {
+		t.Errorf("expected the next transition to be the Monday start, got %+v", next)
+	}
+
+	stopOnly, err := New("", "0 20 * * *", "")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, ok := stopOnly.HoursPerWeek(); ok {
+		t.Error("a schedule without starts should have no projection")
+	}
+	if _, err := New("", "", "UTC"); err == nil {
+		t.Error("expected an error for an empty schedule")
+	}
+	if _, err := New("0 8 * * *", "", "Mars/Olympus_Mons"); err == nil {
+		t.Error("expected an error for an unknown time zone")
+	}
+}
@@ -0,0 +1,93 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+)
+
+// AWSCleaner deletes journaled resources with clients for an AWS profile.
+// Clients are created on first use, one EC2, CloudWatch and Scheduler client
+// per region.
+type AWSCleaner struct {
+	profile    string
+	ec2        map[string]*aws.EC2Client
+	cloudWatch map[string]*aws.CloudWatchClient
+	scheduler  map[string]*aws.SchedulerClient
+	iam        *aws.IAMClient
+}
+
+// NewAWSCleaner creates a cleaner that uses the given AWS profile
+func NewAWSCleaner(profile string) *AWSCleaner {
+	return &AWSCleaner{
+		profile:    profile,
+		ec2:        make(map[string]*aws.EC2Client),
+		cloudWatch: make(map[string]*aws.CloudWatchClient),
+		scheduler:  make(map[string]*aws.SchedulerClient),
+	}
+}
+
+// DeleteResource deletes a resource. Deleting a key pair also removes its
+// private key from local storage.
+func (c *AWSCleaner) DeleteResource(ctx context.Context, resource aws.Resource) error {
+	if resource.IsIAM() {
+		if c.iam == nil {
+			iamClient, err := aws.NewIAMClient(ctx, c.profile)
+			if err != nil {
+				return fmt.Errorf("failed to create IAM client: %w", err)
+			}
+			c.iam = iamClient
+		}
+		return c.iam.DeleteResource(ctx, resource)
+	}
+
+	if resource.Kind == aws.ResourceAlarm {
+		cwClient, ok := c.cloudWatch[resource.Region]
+		if !ok {
+			var err error
+			cwClient, err = aws.NewCloudWatchClient(ctx, c.profile, resource.Region)
+			if err != nil {
+				return fmt.Errorf("failed to create CloudWatch client: %w", err)
+			}
+			c.cloudWatch[resource.Region] = cwClient
+		}
+		return cwClient.DeleteResource(ctx, resource)
+	}
+
+	if resource.Kind == aws.ResourceSchedule {
+		schedulerClient, ok := c.scheduler[resource.Region]
+		if !ok {
+			var err error
+			schedulerClient, err = aws.NewSchedulerClient(ctx, c.profile, resource.Region)
+			if err != nil {
+				return fmt.Errorf("failed to create Scheduler client: %w", err)
+			}
+			c.scheduler[resource.Region] = schedulerClient
+		}
+		return schedulerClient.DeleteResource(ctx, resource)
+	}
+
+	ec2Client, ok := c.ec2[resource.Region]
+	if !ok {
+		var err error
+		ec2Client, err = aws.NewEC2ClientForProfileRegion(ctx, c.profile, resource.Region)
+		if err != nil {
+			return fmt.Errorf("failed to create AWS client: %w", err)
+		}
+		c.ec2[resource.Region] = ec2Client
+	}
+	if err := ec2Client.DeleteResource(ctx, resource); err != nil {
+		return err
+	}
+
+	if resource.Kind == aws.ResourceKeyPair {
+		keyStorage, err := config.DefaultKeyStorage()
+		if err != nil {
+			return fmt.Errorf("failed to initialize key storage: %w", err)
+		}
+		return keyStorage.DeletePrivateKey(resource.ID)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package transaction
+
+import "os"
+
+// processAlive reports whether a process with the given PID is running.
+// On Windows FindProcess fails when the process does not exist.
+var processAlive = func(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = process.Release()
+	return true
+}
@@ -0,0 +1,324 @@
+// Package transaction journals the AWS resources created while launching an
+// instance so that a failed or interrupted launch can be rolled back.
+//
+// A launch begins a Transaction and registers it as the ResourceRecorder of
+// its EC2 and IAM clients. Every resource the clients create is appended to a
+// journal file under ~/.lens/journal before the next API call is made. On
+// success the journal is committed (removed); on failure the resources are
+// deleted in reverse order of creation. If the process dies before either
+// happens, the journal is left behind and RecoverPending rolls it back on the
+// next launch.
+package transaction
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/config"
+)
+
+const (
+	permJournalDir  = 0755
+	permJournalFile = 0600
+
+	// rollbackTimeout bounds how long a rollback may take. Deleting a NAT
+	// Gateway alone can take several minutes.
+	rollbackTimeout = 15 * time.Minute
+)
+
+// Journal is the persisted record of a launch in progress
+type Journal struct {
+	ID        string         `json:"id"`
+	App       string         `json:"app"`
+	Profile   string         `json:"profile"`
+	PID       int            `json:"pid"`
+	StartedAt time.Time      `json:"started_at"`
+	Resources []aws.Resource `json:"resources"`
+}
+
+// Transaction records resources created during a launch and can undo them
+type Transaction struct {
+	mu      sync.Mutex
+	journal Journal
+	path    string
+}
+
+// Cleaner deletes a single journaled resource
+type Cleaner interface {
+	DeleteResource(ctx context.Context, resource aws.Resource) error
+}
+
+// GetJournalDir returns the directory holding journals of unfinished launches
+func GetJournalDir() string {
+	return filepath.Join(config.GetConfigDir(), "journal")
+}
+
+// Begin starts a transaction for a launch by app (e.g. "lens-jupyter") using
+// the given AWS profile and writes an empty journal for it
+func Begin(app, profile string) (*Transaction, error) {
+	now := time.Now()
+	id := fmt.Sprintf("%s-%d", app, now.UnixNano())
+	tx := &Transaction{
+		journal: Journal{
+			ID:        id,
+			App:       app,
+			Profile:   profile,
+			PID:       os.Getpid(),
+			StartedAt: now,
+		},
+		path: filepath.Join(GetJournalDir(), id+".json"),
+	}
+
+	if err := os.MkdirAll(GetJournalDir(), permJournalDir); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	if err := tx.save(); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// ID returns the journal identifier
+func (t *Transaction) ID() string {
+	return t.journal.ID
+}
+
+// StartedAt returns when the launch began
+func (t *Transaction) StartedAt() time.Time {
+	return t.journal.StartedAt
+}
+
+// RecordResource appends a created resource to the journal.
+// It implements aws.ResourceRecorder.
+func (t *Transaction) RecordResource(resource aws.Resource) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.journal.Resources = append(t.journal.Resources, resource)
+	return t.save()
+}
+
+// Resources returns the recorded resources in creation order
+func (t *Transaction) Resources() []aws.Resource {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]aws.Resource(nil), t.journal.Resources...)
+}
+
+// Commit ends the transaction, keeping every recorded resource
+func (t *Transaction) Commit() error {
+	if err := os.Remove(t.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove launch journal: %w", err)
+	}
+	return nil
+}
+
+// Rollback deletes the recorded resources in reverse order of creation.
+// Resources that are deleted are removed from the journal as it goes; if any
+// deletion fails the journal is kept with the remaining resources so that a
+// later run can retry, and an error listing them is returned.
+func (t *Transaction) Rollback(ctx context.Context, cleaner Cleaner) error {
+	resources := t.Resources()
+
+	var remaining []aws.Resource
+	var firstErr error
+	for i := len(resources) - 1; i >= 0; i-- {
+		resource := resources[i]
+		fmt.Printf("Removing %s\n", resource)
+		if err := cleaner.DeleteResource(ctx, resource); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			remaining = append([]aws.Resource{resource}, remaining...)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		t.mu.Lock()
+		t.journal.Resources = append(append([]aws.Resource{}, resources[:i]...), remaining...)
+		err := t.save()
+		t.mu.Unlock()
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+
+	if len(remaining) == 0 {
+		return t.Commit()
+	}
+
+	names := make([]string, 0, len(remaining))
+	for _, resource := range remaining {
+		names = append(names, resource.String())
+	}
+	return fmt.Errorf("rollback incomplete, %d resource(s) remain (%s): %w", len(remaining), strings.Join(names, ", "), firstErr)
+}
+
+// Fail ends a transaction whose launch failed with cause. Unless keep is set,
+// the recorded resources are rolled back using a fresh context, so that a
+// launch cancelled with Ctrl-C is still cleaned up. With keep the resources
+// are left in place for debugging and the journal is discarded.
+// The returned error always wraps cause.
+func (t *Transaction) Fail(cause error, keep bool) error {
+	resources := t.Resources()
+	if len(resources) == 0 {
+		if err := t.Commit(); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		return cause
+	}
+
+	if keep {
+		fmt.Printf("\nLaunch failed; keeping %d resource(s) for debugging (--keep-on-failure):\n", len(resources))
+		for _, resource := range resources {
+			fmt.Printf("  - %s\n", resource)
+		}
+		if err := t.Commit(); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		return cause
+	}
+
+	fmt.Printf("\nLaunch failed; rolling back %d resource(s) created by this launch\n", len(resources))
+
+	ctx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+	defer cancel()
+
+	if err := t.Rollback(ctx, NewAWSCleaner(t.journal.Profile)); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		fmt.Println("The remaining resources will be cleaned up on the next launch")
+		return fmt.Errorf("%w (rollback incomplete)", cause)
+	}
+
+	fmt.Println("✓ Rollback complete")
+	return cause
+}
+
+// Pending returns the journals of app's launches that did not finish and whose
+// process is no longer running, oldest first
+func Pending(app string) ([]*Transaction, error) {
+	entries, err := os.ReadDir(GetJournalDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read journal directory: %w", err)
+	}
+
+	var pending []*Transaction
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		path := filepath.Join(GetJournalDir(), entry.Name())
+		tx, err := load(path)
+		if err != nil {
+			fmt.Printf("Warning: Skipping unreadable launch journal %s: %v\n", path, err)
+			continue
+		}
+		if tx.journal.App != app {
+			continue
+		}
+		if tx.journal.PID == os.Getpid() || processAlive(tx.journal.PID) {
+			continue
+		}
+		pending = append(pending, tx)
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].journal.StartedAt.Before(pending[j].journal.StartedAt)
+	})
+	return pending, nil
+}
+
+// Journaled returns the resources recorded in every journal on disk,
+// including those of launches that are still running
+func Journaled() ([]aws.Resource, error) {
+	entries, err := os.ReadDir(GetJournalDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read journal directory: %w", err)
+	}
+
+	var resources []aws.Resource
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		tx, err := load(filepath.Join(GetJournalDir(), entry.Name()))
+		if err != nil {
+			continue
+		}
+		resources = append(resources, tx.journal.Resources...)
+	}
+	return resources, nil
+}
+
+// RecoverPending rolls back the resources left behind by app's interrupted
+// launches. Journals that cannot be fully rolled back are kept for the next run.
+func RecoverPending(ctx context.Context, app string) error {
+	pending, err := Pending(app)
+	if err != nil {
+		return err
+	}
+
+	for _, tx := range pending {
+		resources := tx.Resources()
+		if len(resources) == 0 {
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		fmt.Printf("Cleaning up %d resource(s) left by an interrupted launch on %s\n",
+			len(resources), tx.journal.StartedAt.Format("2006-01-02 15:04"))
+		if err := tx.Rollback(ctx, NewAWSCleaner(tx.journal.Profile)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load reads a journal file
+func load(path string) (*Transaction, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var journal Journal
+	if err := json.Unmarshal(data, &journal); err != nil {
+		return nil, err
+	}
+	return &Transaction{journal: journal, path: path}, nil
+}
+
+// save writes the journal atomically. Callers must hold t.mu or own t exclusively.
+func (t *Transaction) save() error {
+	data, err := json.MarshalIndent(t.journal, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode launch journal: %w", err)
+	}
+
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, permJournalFile); err != nil {
+		return fmt.Errorf("failed to write launch journal: %w", err)
+	}
+	if err := os.Rename(tmp, t.path); err != nil {
+		return fmt.Errorf("failed to write launch journal: %w", err)
+	}
+	return nil
+}
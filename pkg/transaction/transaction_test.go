@@ -0,0 +1,207 @@
+package transaction
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/scttfrdmn/lens/pkg/aws"
+	"github.com/scttfrdmn/lens/pkg/fakecloud"
+)
+
+// createResources launches an instance with a new key pair and security group
+// through a client that records into tx
+func createResources(t *testing.T, tx *Transaction) (*aws.EC2Client, string) {
+	t.Helper()
+	ctx := context.Background()
+
+	ec2Client, err := aws.NewEC2Client(ctx, "default")
+	if err != nil {
+		t.Fatalf("Failed to create EC2 client: %v", err)
+	}
+	ec2Client.SetResourceRecorder(tx)
+
+	key, err := ec2Client.CreateKeyPair(ctx, "lens-test-key")
+	if err != nil {
+		t.Fatalf("CreateKeyPair failed: %v", err)
+	}
+	subnet, err := ec2Client.GetSubnet(ctx, "public", "")
+	if err != nil {
+		t.Fatalf("GetSubnet failed: %v", err)
+	}
+	sg, err := ec2Client.CreateSecurityGroup(ctx, "lens-test-sg", subnet.VpcID)
+	if err != nil {
+		t.Fatalf("CreateSecurityGroup failed: %v", err)
+	}
+	ami, err := aws.NewAMISelector(ec2Client.GetRegion()).GetAMI(ctx, ec2Client, "ubuntu24-arm64")
+	if err != nil {
+		t.Fatalf("GetAMI failed: %v", err)
+	}
+	instance, err := ec2Client.LaunchInstance(ctx, aws.LaunchParams{
+		AMI:             ami,
+		InstanceType:    "t4g.medium",
+		SecurityGroupID: sg.ID,
+		SubnetID:        subnet.ID,
+		KeyPairName:     key.Name,
+		EBSVolumeSize:   20,
+		Environment:     "test",
+	})
+	if err != nil {
+		t.Fatalf("LaunchInstance failed: %v", err)
+	}
+	return ec2Client, *instance.InstanceId
+}
+
+func TestBegin_RecordsResourcesInJournal(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	fakecloud.Install(t)
+
+	tx, err := Begin("lens-test", "default")
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	_, instanceID := createResources(t, tx)
+
+	loaded, err := load(tx.path)
+	if err != nil {
+		t.Fatalf("Failed to load journal: %v", err)
+	}
+
+	want := []aws.ResourceKind{aws.ResourceKeyPair, aws.ResourceSecurityGroup, aws.ResourceInstance}
+	got := loaded.Resources()
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d journaled resources, got %d: %v", len(want), len(got), got)
+	}
+	for i, kind := range want {
+		if got[i].Kind != kind {
+			t.Errorf("Resource %d: expected %s, got %s", i, kind, got[i].Kind)
+		}
+		if got[i].Region != fakecloud.DefaultRegion {
+			t.Errorf("Resource %d: expected region %s, got %q", i, fakecloud.DefaultRegion, got[i].Region)
+		}
+	}
+	if got[2].ID != instanceID {
+		t.Errorf("Expected instance %s, got %s", instanceID, got[2].ID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if _, err := os.Stat(tx.path); !os.IsNotExist(err) {
+		t.Error("Expected journal to be removed on commit")
+	}
+}
+
+func TestRollback_DeletesInReverseOrder(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+
+	tx, err := Begin("lens-test", "default")
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	_, instanceID := createResources(t, tx)
+
+	if err := tx.Rollback(context.Background(), NewAWSCleaner("default")); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	inst, _ := cloud.Instance(instanceID)
+	if inst.State.Name != "terminated" {
+		t.Errorf("Expected instance to be terminated, got %s", inst.State.Name)
+	}
+	if keys := cloud.KeyPairs(fakecloud.DefaultRegion); len(keys) != 0 {
+		t.Errorf("Expected key pair to be deleted, got %d", len(keys))
+	}
+	for _, group := range cloud.SecurityGroups(fakecloud.DefaultRegion) {
+		if *group.GroupName == "lens-test-sg" {
+			t.Error("Expected security group to be deleted")
+		}
+	}
+	if _, err := os.Stat(tx.path); !os.IsNotExist(err) {
+		t.Error("Expected journal to be removed after a complete rollback")
+	}
+}
+
+func TestRollback_KeepsFailedResourcesForRecovery(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+
+	tx, err := Begin("lens-test", "default")
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	createResources(t, tx)
+
+	cloud.FailNext("DeleteSecurityGroup", fakecloud.APIError("RequestLimitExceeded", "Request limit exceeded."))
+	if err := tx.Rollback(context.Background(), NewAWSCleaner("default")); err == nil {
+		t.Fatal("Expected rollback to report the failed deletion")
+	}
+
+	loaded, err := load(tx.path)
+	if err != nil {
+		t.Fatalf("Expected journal to be kept: %v", err)
+	}
+	remaining := loaded.Resources()
+	if len(remaining) != 1 || remaining[0].Kind != aws.ResourceSecurityGroup {
+		t.Fatalf("Expected only the security group to remain, got %v", remaining)
+	}
+
+	// A journal owned by a live process is never recovered
+	pending, err := Pending("lens-test")
+	if err != nil {
+		t.Fatalf("Pending failed: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("Expected journal of the running process to be skipped, got %d", len(pending))
+	}
+
+	// Pretend the journal was written by a process that has since exited
+	loaded.journal.PID = -1
+	if err := loaded.save(); err != nil {
+		t.Fatalf("Failed to rewrite journal: %v", err)
+	}
+
+	if err := RecoverPending(context.Background(), "other-app"); err != nil {
+		t.Fatalf("RecoverPending for another app failed: %v", err)
+	}
+	if _, err := os.Stat(tx.path); err != nil {
+		t.Fatal("Expected journal of another app to be left alone")
+	}
+
+	if err := RecoverPending(context.Background(), "lens-test"); err != nil {
+		t.Fatalf("RecoverPending failed: %v", err)
+	}
+	for _, group := range cloud.SecurityGroups(fakecloud.DefaultRegion) {
+		if *group.GroupName == "lens-test-sg" {
+			t.Error("Expected security group to be deleted on recovery")
+		}
+	}
+	if _, err := os.Stat(tx.path); !os.IsNotExist(err) {
+		t.Error("Expected journal to be removed after recovery")
+	}
+}
+
+func TestFail_KeepLeavesResources(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cloud := fakecloud.Install(t)
+
+	tx, err := Begin("lens-test", "default")
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	_, instanceID := createResources(t, tx)
+
+	cause := os.ErrDeadlineExceeded
+	if err := tx.Fail(cause, true); err != cause {
+		t.Errorf("Expected Fail to return the cause, got %v", err)
+	}
+
+	inst, _ := cloud.Instance(instanceID)
+	if inst.State.Name != "running" && inst.State.Name != "pending" {
+		t.Errorf("Expected instance to be kept, got %s", inst.State.Name)
+	}
+	if _, err := os.Stat(tx.path); !os.IsNotExist(err) {
+		t.Error("Expected journal to be discarded when keeping resources")
+	}
+}